@@ -181,12 +181,20 @@ create_or_update_service() {
             env_vars+=",{\"name\":\"STORE_TYPE\",\"value\":\"mongo\"}"
             env_vars+=",{\"name\":\"PROVIDER_REGISTRY_URL\",\"value\":\"http://aex-provider-registry.$service_name.local:8080\"}"
             ;;
+        aex-provider-registry)
+            env_vars+=",{\"name\":\"TRUST_BROKER_URL\",\"value\":\"http://aex-trust-broker.$service_name.local:8080\"}"
+            env_vars+=",{\"name\":\"SETTLEMENT_URL\",\"value\":\"http://aex-settlement.$service_name.local:8080\"}"
+            ;;
+        aex-trust-broker)
+            env_vars+=",{\"name\":\"PROVIDER_REGISTRY_URL\",\"value\":\"http://aex-provider-registry.$service_name.local:8080\"}"
+            ;;
         aex-bid-gateway)
             env_vars+=",{\"name\":\"PROVIDER_REGISTRY_URL\",\"value\":\"http://aex-provider-registry.$service_name.local:8080\"}"
             ;;
         aex-bid-evaluator)
             env_vars+=",{\"name\":\"BID_GATEWAY_URL\",\"value\":\"http://aex-bid-gateway.$service_name.local:8080\"}"
             env_vars+=",{\"name\":\"TRUST_BROKER_URL\",\"value\":\"http://aex-trust-broker.$service_name.local:8080\"}"
+            env_vars+=",{\"name\":\"PROVIDER_REGISTRY_URL\",\"value\":\"http://aex-provider-registry.$service_name.local:8080\"}"
             ;;
         aex-contract-engine)
             env_vars+=",{\"name\":\"BID_GATEWAY_URL\",\"value\":\"http://aex-bid-gateway.$service_name.local:8080\"}"
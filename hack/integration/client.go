@@ -119,39 +119,60 @@ func (c *Client) HealthCheck(ctx context.Context, url string) error {
 	return nil
 }
 
-// WaitForServices waits for all services to be healthy
-func (c *Client) WaitForServices(ctx context.Context, timeout time.Duration) error {
-	services := map[string]string{
-		"work-publisher":    c.urls.WorkPublisher,
-		"bid-gateway":       c.urls.BidGateway,
-		"bid-evaluator":     c.urls.BidEvaluator,
-		"contract-engine":   c.urls.ContractEngine,
-		"provider-registry": c.urls.ProviderRegistry,
-		"trust-broker":      c.urls.TrustBroker,
-		"identity":          c.urls.Identity,
-		"settlement":        c.urls.Settlement,
+// SystemHealth is the gateway's aggregate health of the upstream services
+type SystemHealth struct {
+	Status   string `json:"status"`
+	Services []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	} `json:"services"`
+}
+
+// GetSystemHealth fetches the aggregate health from the gateway, which
+// answers 503 with the same body while any service is unhealthy
+func (c *Client) GetSystemHealth(ctx context.Context) (*SystemHealth, error) {
+	resp, err := c.Request(ctx, http.MethodGet, c.urls.Gateway+"/v1/system/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result SystemHealth
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("system health: HTTP %d: %w", resp.StatusCode, err)
 	}
+	return &result, nil
+}
 
+// WaitForServices waits until the gateway reports all services healthy
+func (c *Client) WaitForServices(ctx context.Context, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
-	for name, url := range services {
-		for {
-			if time.Now().After(deadline) {
-				return fmt.Errorf("timeout waiting for %s", name)
-			}
+	for {
+		health, err := c.GetSystemHealth(ctx)
+		if err == nil && health.Status == "healthy" {
+			return nil
+		}
 
-			err := c.HealthCheck(ctx, url)
-			if err == nil {
-				break
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("timeout waiting for services: %w", err)
 			}
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(1 * time.Second):
+			var unhealthy []string
+			for _, s := range health.Services {
+				if s.Status != "healthy" {
+					unhealthy = append(unhealthy, s.Name)
+				}
 			}
+			return fmt.Errorf("timeout waiting for services: %v", unhealthy)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(1 * time.Second):
 		}
 	}
-	return nil
 }
 
 // Work Publisher API
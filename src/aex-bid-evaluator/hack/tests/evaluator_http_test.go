@@ -83,3 +83,172 @@ func TestEvaluateOverHTTPUsingRealBidGatewayHTTP(t *testing.T) {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
 }
+
+func TestEvaluateFetchesTrustScoresInOneBatch(t *testing.T) {
+	now := time.Now().UTC()
+	bid := func(id, provider string) map[string]any {
+		return map[string]any{
+			"bid_id":       id,
+			"work_id":      "work_1",
+			"provider_id":  provider,
+			"price":        0.10,
+			"confidence":   0.9,
+			"sla":          map[string]any{"max_latency_ms": 2000, "availability": 0.99},
+			"a2a_endpoint": "https://a2a/" + id,
+			"expires_at":   now.Add(5 * time.Minute).Format(time.RFC3339Nano),
+			"received_at":  now.Format(time.RFC3339Nano),
+		}
+	}
+	bg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"work_id": "work_1",
+			"bids":    []map[string]any{bid("bid_1", "prov_low"), bid("bid_2", "prov_high"), bid("bid_3", "prov_high")},
+		})
+	}))
+	t.Cleanup(bg.Close)
+
+	var calls int
+	var requested []string
+	tb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/internal/v1/trust/batch" {
+			t.Errorf("unexpected trust broker call %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		calls++
+		var req struct {
+			ProviderIDs []string `json:"provider_ids"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		requested = req.ProviderIDs
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"scores": map[string]float64{"prov_low": 0.1, "prov_high": 0.95},
+		})
+	}))
+	t.Cleanup(tb.Close)
+
+	svc, err := evalsvc.New(bg.URL, tb.URL, evalstore.NewMemoryEvaluationStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := httptest.NewServer(evalhttp.NewRouter(svc))
+	t.Cleanup(ev.Close)
+
+	b, _ := json.Marshal(map[string]any{
+		"work_id": "work_1",
+		"budget":  map[string]any{"max_price": 0.25, "bid_strategy": "best_quality"},
+	})
+	resp, err := http.Post(ev.URL+"/internal/v1/evaluate", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var out struct {
+		RankedBids []struct {
+			ProviderID string `json:"provider_id"`
+			Scores     struct {
+				Trust float64 `json:"trust"`
+			} `json:"scores"`
+		} `json:"ranked_bids"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+
+	if calls != 1 || len(requested) != 2 {
+		t.Fatalf("expected one batch call for 2 providers, got %d calls with %v", calls, requested)
+	}
+	if len(out.RankedBids) != 3 || out.RankedBids[0].ProviderID != "prov_high" || out.RankedBids[0].Scores.Trust != 0.95 {
+		t.Fatalf("expected prov_high ranked first with its batch score, got %+v", out.RankedBids)
+	}
+}
+
+func TestEvaluateAppliesProbationFilter(t *testing.T) {
+	now := time.Now().UTC()
+	bid := func(id, provider string) map[string]any {
+		return map[string]any{
+			"bid_id":       id,
+			"work_id":      "work_1",
+			"provider_id":  provider,
+			"price":        0.10,
+			"confidence":   0.9,
+			"a2a_endpoint": "https://a2a/" + id,
+			"expires_at":   now.Add(5 * time.Minute).Format(time.RFC3339Nano),
+			"received_at":  now.Format(time.RFC3339Nano),
+		}
+	}
+	bg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"work_id": "work_1",
+			"bids":    []map[string]any{bid("bid_ok", "prov_ok"), bid("bid_pb", "prov_pb")},
+		})
+	}))
+	t.Cleanup(bg.Close)
+	tb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"scores":    map[string]float64{"prov_ok": 0.6, "prov_pb": 0.8},
+			"probation": map[string]bool{"prov_pb": true},
+		})
+	}))
+	t.Cleanup(tb.Close)
+
+	type result struct {
+		RankedBids []struct {
+			ProviderID string `json:"provider_id"`
+			Probation  bool   `json:"probation"`
+			Scores     struct {
+				Trust float64 `json:"trust"`
+			} `json:"scores"`
+		} `json:"ranked_bids"`
+		DisqualifiedBids []struct {
+			BidID  string `json:"bid_id"`
+			Reason string `json:"reason"`
+		} `json:"disqualified_bids"`
+	}
+	evaluate := func(mode string) result {
+		t.Helper()
+		svc, err := evalsvc.New(bg.URL, tb.URL, evalstore.NewMemoryEvaluationStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.SetProbationFilter(mode); err != nil {
+			t.Fatal(err)
+		}
+		ev := httptest.NewServer(evalhttp.NewRouter(svc))
+		defer ev.Close()
+		b, _ := json.Marshal(map[string]any{
+			"work_id": "work_1",
+			"budget":  map[string]any{"max_price": 0.25, "bid_strategy": "best_quality"},
+		})
+		resp, err := http.Post(ev.URL+"/internal/v1/evaluate", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out result
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	hard := evaluate("hard")
+	if len(hard.RankedBids) != 1 || hard.RankedBids[0].ProviderID != "prov_ok" ||
+		len(hard.DisqualifiedBids) != 1 || hard.DisqualifiedBids[0].BidID != "bid_pb" {
+		t.Fatalf("expected hard filter to disqualify the probation bid: %+v", hard)
+	}
+
+	soft := evaluate("soft")
+	if len(soft.RankedBids) != 2 || soft.RankedBids[0].ProviderID != "prov_ok" {
+		t.Fatalf("expected soft filter to rank the probation bid last: %+v", soft)
+	}
+	if pb := soft.RankedBids[1]; !pb.Probation || pb.Scores.Trust != 0.4 {
+		t.Fatalf("expected halved trust score and probation marker: %+v", pb)
+	}
+
+	off := evaluate("off")
+	if len(off.RankedBids) != 2 || off.RankedBids[0].ProviderID != "prov_pb" || off.RankedBids[0].Scores.Trust != 0.8 {
+		t.Fatalf("expected probation to be ignored when off: %+v", off)
+	}
+
+	svc, _ := evalsvc.New(bg.URL, tb.URL, evalstore.NewMemoryEvaluationStore())
+	if err := svc.SetProbationFilter("strict"); err == nil {
+		t.Fatal("expected unknown probation filter to be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProviderState is the part of a registry provider record the evaluator
+// uses to decide whether a provider may win work.
+type ProviderState struct {
+	ProviderID string `json:"provider_id"`
+	Status     string `json:"status"`
+	Liveness   string `json:"liveness"`
+}
+
+// SLACommitment is a provider's registered default SLA for a category.
+type SLACommitment struct {
+	Capability   string  `json:"capability"`
+	MaxLatencyMs int64   `json:"max_latency_ms"`
+	Availability float64 `json:"availability"`
+}
+
+type ProviderRegistryClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func NewProviderRegistryClient(baseURL string) *ProviderRegistryClient {
+	return &ProviderRegistryClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetProviderState returns nil without error when no registry is configured
+// or the provider is unknown, so evaluation does not depend on the registry.
+func (c *ProviderRegistryClient) GetProviderState(ctx context.Context, providerID string) (*ProviderState, error) {
+	if c.baseURL == "" {
+		return nil, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v1/providers/"+url.PathEscape(providerID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider-registry returned %d", resp.StatusCode)
+	}
+	var out ProviderState
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSLA returns the provider's registered SLA commitment covering category,
+// or nil without error when there is none or no registry is configured.
+func (c *ProviderRegistryClient) GetSLA(ctx context.Context, providerID, category string) (*SLACommitment, error) {
+	if c.baseURL == "" {
+		return nil, nil
+	}
+	u := c.baseURL + "/internal/v1/providers/" + url.PathEscape(providerID) + "/sla?category=" + url.QueryEscape(category)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider-registry returned %d", resp.StatusCode)
+	}
+	var out SLACommitment
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
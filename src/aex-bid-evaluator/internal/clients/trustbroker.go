@@ -1,11 +1,11 @@
 package clients
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 )
@@ -22,31 +22,54 @@ func NewTrustBrokerClient(baseURL string) *TrustBrokerClient {
 	}
 }
 
-func (c *TrustBrokerClient) GetScore(ctx context.Context, providerID string) (float64, error) {
-	if c.baseURL == "" {
-		return 0.5, nil
+// defaultTrustScore is used for providers the trust broker could not score.
+const defaultTrustScore = 0.5
+
+// ProviderTrust is a provider's trust score and whether it is on probation.
+type ProviderTrust struct {
+	Score       float64
+	OnProbation bool
+}
+
+// GetTrust fetches the trust of all providerIDs in one batch call.
+// Providers missing from the response, and all of them when no trust broker
+// is configured or the call fails, get defaultTrustScore and no probation.
+func (c *TrustBrokerClient) GetTrust(ctx context.Context, providerIDs []string) (map[string]ProviderTrust, error) {
+	scores := make(map[string]ProviderTrust, len(providerIDs))
+	for _, id := range providerIDs {
+		scores[id] = ProviderTrust{Score: defaultTrustScore}
 	}
-	u, err := url.Parse(c.baseURL + "/v1/providers/" + providerID + "/trust")
+	if c.baseURL == "" || len(providerIDs) == 0 {
+		return scores, nil
+	}
+	body, err := json.Marshal(map[string]any{"provider_ids": providerIDs})
 	if err != nil {
-		return 0.5, err
+		return scores, err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/v1/trust/batch", bytes.NewReader(body))
 	if err != nil {
-		return 0.5, err
+		return scores, err
 	}
+	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return 0.5, err
+		return scores, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 	if resp.StatusCode != http.StatusOK {
-		return 0.5, fmt.Errorf("trust-broker returned %d", resp.StatusCode)
+		return scores, fmt.Errorf("trust-broker returned %d", resp.StatusCode)
 	}
 	var out struct {
-		TrustScore float64 `json:"trust_score"`
+		Scores    map[string]float64 `json:"scores"`
+		Probation map[string]bool    `json:"probation"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return 0.5, err
+		return scores, err
+	}
+	for id, score := range out.Scores {
+		if _, ok := scores[id]; ok {
+			scores[id] = ProviderTrust{Score: score, OnProbation: out.Probation[id]}
+		}
 	}
-	return out.TrustScore, nil
+	return scores, nil
 }
@@ -12,6 +12,10 @@ type Config struct {
 	BidGatewayURL  string // required
 	TrustBrokerURL string // optional
 
+	ProviderRegistryURL string // optional; excludes suspended/offline providers
+
+	ProbationFilter string // hard, soft (default) or off
+
 	// MongoDB (optional persistence)
 	MongoURI        string
 	MongoDatabase   string
@@ -24,15 +28,17 @@ type Config struct {
 
 func Load() Config {
 	cfg := Config{
-		Port:            getenv("PORT", "8080"),
-		BidGatewayURL:   strings.TrimRight(strings.TrimSpace(os.Getenv("BID_GATEWAY_URL")), "/"),
-		TrustBrokerURL:  strings.TrimRight(strings.TrimSpace(os.Getenv("TRUST_BROKER_URL")), "/"),
-		MongoURI:        strings.TrimSpace(os.Getenv("MONGO_URI")),
-		MongoDatabase:   getenv("MONGO_DB", "aex"),
-		MongoCollection: getenv("MONGO_COLLECTION_EVALUATIONS", "bid_evaluations"),
-		ReadTimeout:     10 * time.Second,
-		WriteTimeout:    20 * time.Second,
-		IdleTimeout:     60 * time.Second,
+		Port:                getenv("PORT", "8080"),
+		BidGatewayURL:       strings.TrimRight(strings.TrimSpace(os.Getenv("BID_GATEWAY_URL")), "/"),
+		TrustBrokerURL:      strings.TrimRight(strings.TrimSpace(os.Getenv("TRUST_BROKER_URL")), "/"),
+		ProviderRegistryURL: strings.TrimRight(strings.TrimSpace(os.Getenv("PROVIDER_REGISTRY_URL")), "/"),
+		ProbationFilter:     strings.ToLower(getenv("PROBATION_FILTER", "soft")),
+		MongoURI:            strings.TrimSpace(os.Getenv("MONGO_URI")),
+		MongoDatabase:       getenv("MONGO_DB", "aex"),
+		MongoCollection:     getenv("MONGO_COLLECTION_EVALUATIONS", "bid_evaluations"),
+		ReadTimeout:         10 * time.Second,
+		WriteTimeout:        20 * time.Second,
+		IdleTimeout:         60 * time.Second,
 	}
 	return cfg
 }
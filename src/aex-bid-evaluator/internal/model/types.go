@@ -13,6 +13,7 @@ type WorkBudget struct {
 
 type WorkSpec struct {
 	WorkID      string          `json:"work_id"`
+	Category    string          `json:"category,omitempty"`
 	Budget      WorkBudget      `json:"budget"`
 	Constraints WorkConstraints `json:"constraints"`
 	Description string          `json:"description,omitempty"`
@@ -37,8 +38,10 @@ type BidPacket struct {
 	Price      float64 `json:"price"`
 	Confidence float64 `json:"confidence"`
 
-	MVPSample *MVPSample    `json:"mvp_sample,omitempty"`
-	SLA       SLACommitment `json:"sla"`
+	MVPSample    *MVPSample    `json:"mvp_sample,omitempty"`
+	SLA          SLACommitment `json:"sla"`
+	SLADefaulted bool          `json:"sla_defaulted,omitempty"`
+	Category     string        `json:"category,omitempty"`
 
 	A2AEndpoint string    `json:"a2a_endpoint"`
 	ExpiresAt   time.Time `json:"expires_at"`
@@ -64,6 +67,9 @@ type RankedBid struct {
 	ProviderID string   `json:"provider_id"`
 	TotalScore float64  `json:"total_score"`
 	Scores     BidScore `json:"scores"`
+	SLAWarning string   `json:"sla_warning,omitempty"`
+	// Probation is set when the provider is on trust probation.
+	Probation bool `json:"probation,omitempty"`
 }
 
 type BidEvaluation struct {
@@ -83,4 +89,5 @@ type EvaluateRequest struct {
 	Budget      *WorkBudget      `json:"budget,omitempty"`
 	Constraints *WorkConstraints `json:"constraints,omitempty"`
 	Description *string          `json:"description,omitempty"`
+	Category    *string          `json:"category,omitempty"`
 }
@@ -7,8 +7,10 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"log"
 	"math"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -18,10 +20,27 @@ import (
 	"github.com/parlakisik/agent-exchange/aex-bid-evaluator/internal/store"
 )
 
+// Probation filter modes: how bids from providers the trust broker has put
+// on probation are treated.
+const (
+	// ProbationFilterHard disqualifies the bids.
+	ProbationFilterHard = "hard"
+	// ProbationFilterSoft scales the bids' trust score by probationTrustFactor.
+	ProbationFilterSoft = "soft"
+	// ProbationFilterOff ignores probation.
+	ProbationFilterOff = "off"
+)
+
+// probationTrustFactor scales the trust score of providers on probation
+// under ProbationFilterSoft.
+const probationTrustFactor = 0.5
+
 type Service struct {
-	bidGateway  *clients.BidGatewayClient
-	trustBroker *clients.TrustBrokerClient
-	store       store.EvaluationStore
+	bidGateway       *clients.BidGatewayClient
+	trustBroker      *clients.TrustBrokerClient
+	providerRegistry *clients.ProviderRegistryClient
+	store            store.EvaluationStore
+	probationFilter  string
 }
 
 func New(bidGatewayURL string, trustBrokerURL string, st store.EvaluationStore) (*Service, error) {
@@ -29,12 +48,32 @@ func New(bidGatewayURL string, trustBrokerURL string, st store.EvaluationStore)
 		return nil, errors.New("BID_GATEWAY_URL is required")
 	}
 	return &Service{
-		bidGateway:  clients.NewBidGatewayClient(bidGatewayURL),
-		trustBroker: clients.NewTrustBrokerClient(trustBrokerURL),
-		store:       st,
+		bidGateway:       clients.NewBidGatewayClient(bidGatewayURL),
+		trustBroker:      clients.NewTrustBrokerClient(trustBrokerURL),
+		providerRegistry: clients.NewProviderRegistryClient(""),
+		store:            st,
+		probationFilter:  ProbationFilterSoft,
 	}, nil
 }
 
+// SetProbationFilter sets how bids from providers on probation are treated:
+// ProbationFilterHard, ProbationFilterSoft (the default) or ProbationFilterOff.
+func (s *Service) SetProbationFilter(mode string) error {
+	switch mode {
+	case ProbationFilterHard, ProbationFilterSoft, ProbationFilterOff:
+		s.probationFilter = mode
+		return nil
+	}
+	return errors.New("probation filter must be hard, soft or off")
+}
+
+// SetProviderRegistry enables disqualification of bids from providers that
+// are suspended, deactivated or offline, and checks bid SLAs against the
+// providers' registered commitments.
+func (s *Service) SetProviderRegistry(baseURL string) {
+	s.providerRegistry = clients.NewProviderRegistryClient(baseURL)
+}
+
 func (s *Service) HandleEvaluate(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
@@ -69,6 +108,9 @@ func (s *Service) HandleEvaluate(w http.ResponseWriter, r *http.Request) {
 	if req.Description != nil {
 		work.Description = *req.Description
 	}
+	if req.Category != nil {
+		work.Category = strings.TrimSpace(*req.Category)
+	}
 	if work.Budget.MaxPrice <= 0 {
 		http.Error(w, "budget.max_price is required (work-publisher not integrated yet)", http.StatusBadRequest)
 		return
@@ -89,17 +131,46 @@ func (s *Service) evaluate(ctx context.Context, work model.WorkSpec) (model.BidE
 	}
 
 	now := time.Now().UTC()
+	slaWarnings := s.checkRegisteredSLAs(ctx, bids, work.Category)
 	valid, disq := filterValidBids(bids, work, now)
+	valid, disq = s.filterIneligibleProviders(ctx, valid, disq)
+
+	providerIDs := make([]string, 0, len(valid))
+	for _, bid := range valid {
+		if !slices.Contains(providerIDs, bid.ProviderID) {
+			providerIDs = append(providerIDs, bid.ProviderID)
+		}
+	}
+	trust, err := s.trustBroker.GetTrust(ctx, providerIDs)
+	if err != nil {
+		log.Printf("trust score lookup failed work_id=%s: %v", work.WorkID, err)
+	}
+	if s.probationFilter == ProbationFilterHard {
+		kept := valid[:0]
+		for _, bid := range valid {
+			if trust[bid.ProviderID].OnProbation {
+				disq = append(disq, model.DisqualifiedBid{BidID: bid.BidID, Reason: "Provider is on probation"})
+				continue
+			}
+			kept = append(kept, bid)
+		}
+		valid = kept
+	}
 
 	weights := weightsForStrategy(work.Budget.BidStrategy)
 	type scored struct {
 		bid        model.BidPacket
 		score      model.BidScore
 		totalScore float64
+		probation  bool
 	}
 	scoredBids := make([]scored, 0, len(valid))
 	for _, bid := range valid {
-		trust, _ := s.trustBroker.GetScore(ctx, bid.ProviderID)
+		pt := trust[bid.ProviderID]
+		trustScore := pt.Score
+		if pt.OnProbation && s.probationFilter == ProbationFilterSoft {
+			trustScore *= probationTrustFactor
+		}
 		priceScore := clamp01(1 - (bid.Price / work.Budget.MaxPrice))
 		confScore := clamp01(bid.Confidence)
 		mvpScore := 0.5
@@ -110,7 +181,7 @@ func (s *Service) evaluate(ctx context.Context, work model.WorkSpec) (model.BidE
 
 		scr := model.BidScore{
 			Price:      priceScore,
-			Trust:      clamp01(trust),
+			Trust:      clamp01(trustScore),
 			Confidence: confScore,
 			MVPSample:  clamp01(mvpScore),
 			SLA:        clamp01(slaScore),
@@ -120,7 +191,7 @@ func (s *Service) evaluate(ctx context.Context, work model.WorkSpec) (model.BidE
 			weights.Confidence*scr.Confidence +
 			weights.MVPSample*scr.MVPSample +
 			weights.SLA*scr.SLA
-		scoredBids = append(scoredBids, scored{bid: bid, score: scr, totalScore: total})
+		scoredBids = append(scoredBids, scored{bid: bid, score: scr, totalScore: total, probation: pt.OnProbation})
 	}
 
 	sort.Slice(scoredBids, func(i, j int) bool { return scoredBids[i].totalScore > scoredBids[j].totalScore })
@@ -133,6 +204,8 @@ func (s *Service) evaluate(ctx context.Context, work model.WorkSpec) (model.BidE
 			ProviderID: sb.bid.ProviderID,
 			TotalScore: sb.totalScore,
 			Scores:     sb.score,
+			SLAWarning: slaWarnings[sb.bid.BidID],
+			Probation:  sb.probation,
 		})
 	}
 
@@ -187,6 +260,84 @@ func filterValidBids(bids []model.BidPacket, work model.WorkSpec, now time.Time)
 	return valid, disq
 }
 
+// filterIneligibleProviders drops bids from providers the registry reports as
+// not ACTIVE or OFFLINE. Lookup failures leave the bid in place.
+func (s *Service) filterIneligibleProviders(ctx context.Context, bids []model.BidPacket, disq []model.DisqualifiedBid) ([]model.BidPacket, []model.DisqualifiedBid) {
+	valid := make([]model.BidPacket, 0, len(bids))
+	states := map[string]*clients.ProviderState{}
+	for _, bid := range bids {
+		st, seen := states[bid.ProviderID]
+		if !seen {
+			st, _ = s.providerRegistry.GetProviderState(ctx, bid.ProviderID)
+			states[bid.ProviderID] = st
+		}
+		if reason := providerIneligibleReason(st); reason != "" {
+			disq = append(disq, model.DisqualifiedBid{BidID: bid.BidID, Reason: reason})
+			continue
+		}
+		valid = append(valid, bid)
+	}
+	return valid, disq
+}
+
+// checkRegisteredSLAs compares each bid's SLA with the provider's registered
+// commitment for the work category (or the bid's own category). A bid that
+// promises better latency or availability than its provider registered is
+// held to the registered figures for filtering and scoring, and fields the
+// bid left out are filled from them. Bids whose SLA the gateway already defaulted, and lookup
+// failures, are left alone. It returns a warning per adjusted bid.
+func (s *Service) checkRegisteredSLAs(ctx context.Context, bids []model.BidPacket, category string) map[string]string {
+	warnings := map[string]string{}
+	registered := map[string]*clients.SLACommitment{}
+	for i := range bids {
+		bid := &bids[i]
+		if bid.SLADefaulted {
+			continue
+		}
+		cat := category
+		if cat == "" {
+			cat = bid.Category
+		}
+		key := bid.ProviderID + "|" + cat
+		reg, seen := registered[key]
+		if !seen {
+			reg, _ = s.providerRegistry.GetSLA(ctx, bid.ProviderID, cat)
+			registered[key] = reg
+		}
+		if reg == nil {
+			continue
+		}
+		switch {
+		case bid.SLA.MaxLatencyMs <= 0:
+			bid.SLA.MaxLatencyMs = reg.MaxLatencyMs
+		case bid.SLA.MaxLatencyMs < reg.MaxLatencyMs:
+			bid.SLA.MaxLatencyMs = reg.MaxLatencyMs
+			warnings[bid.BidID] = "SLA exceeds registered commitment"
+		}
+		switch {
+		case bid.SLA.Availability <= 0:
+			bid.SLA.Availability = reg.Availability
+		case bid.SLA.Availability > reg.Availability:
+			bid.SLA.Availability = reg.Availability
+			warnings[bid.BidID] = "SLA exceeds registered commitment"
+		}
+	}
+	return warnings
+}
+
+func providerIneligibleReason(st *clients.ProviderState) string {
+	if st == nil {
+		return ""
+	}
+	if st.Status != "" && st.Status != "ACTIVE" {
+		return "Provider is " + strings.ToLower(st.Status)
+	}
+	if st.Liveness == "OFFLINE" {
+		return "Provider is offline"
+	}
+	return ""
+}
+
 func calculateSLAScore(sla model.SLACommitment, c model.WorkConstraints) float64 {
 	if c.MaxLatencyMs == nil || *c.MaxLatencyMs <= 0 {
 		return 0.8
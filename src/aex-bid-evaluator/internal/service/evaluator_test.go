@@ -1,9 +1,15 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/parlakisik/agent-exchange/aex-bid-evaluator/internal/clients"
 	"github.com/parlakisik/agent-exchange/aex-bid-evaluator/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-bid-evaluator/internal/store"
 )
@@ -293,6 +299,97 @@ func TestClamp01(t *testing.T) {
 	}
 }
 
+func TestFilterIneligibleProviders(t *testing.T) {
+	states := map[string]*clients.ProviderState{
+		"prov_active":    {ProviderID: "prov_active", Status: "ACTIVE", Liveness: "ONLINE"},
+		"prov_suspended": {ProviderID: "prov_suspended", Status: "SUSPENDED"},
+		"prov_offline":   {ProviderID: "prov_offline", Status: "ACTIVE", Liveness: "OFFLINE"},
+	}
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st, ok := states[strings.TrimPrefix(r.URL.Path, "/v1/providers/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(st)
+	}))
+	defer registry.Close()
+
+	svc, err := New("http://localhost:8081", "", store.NewMemoryEvaluationStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.SetProviderRegistry(registry.URL)
+
+	bids := []model.BidPacket{
+		{BidID: "bid_active", ProviderID: "prov_active"},
+		{BidID: "bid_suspended", ProviderID: "prov_suspended"},
+		{BidID: "bid_offline", ProviderID: "prov_offline"},
+		{BidID: "bid_unknown", ProviderID: "prov_unknown"},
+	}
+	valid, disq := svc.filterIneligibleProviders(context.Background(), bids, nil)
+
+	if len(valid) != 2 || valid[0].BidID != "bid_active" || valid[1].BidID != "bid_unknown" {
+		t.Errorf("valid = %+v, want bid_active and bid_unknown", valid)
+	}
+	want := map[string]string{
+		"bid_suspended": "Provider is suspended",
+		"bid_offline":   "Provider is offline",
+	}
+	if len(disq) != len(want) {
+		t.Fatalf("disqualified = %+v", disq)
+	}
+	for _, d := range disq {
+		if want[d.BidID] != d.Reason {
+			t.Errorf("%s reason = %q, want %q", d.BidID, d.Reason, want[d.BidID])
+		}
+	}
+}
+
+func TestCheckRegisteredSLAs(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/v1/providers/prov_sla/sla" || r.URL.Query().Get("category") != "nlp.summarize" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(clients.SLACommitment{Capability: "nlp", MaxLatencyMs: 1000, Availability: 0.99})
+	}))
+	defer registry.Close()
+
+	svc, err := New("http://localhost:8081", "", store.NewMemoryEvaluationStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.SetProviderRegistry(registry.URL)
+
+	bids := []model.BidPacket{
+		{BidID: "bid_honest", ProviderID: "prov_sla", SLA: model.SLACommitment{MaxLatencyMs: 1500, Availability: 0.95}},
+		{BidID: "bid_overpromise", ProviderID: "prov_sla", SLA: model.SLACommitment{MaxLatencyMs: 200, Availability: 0.9999}},
+		{BidID: "bid_missing", ProviderID: "prov_sla"},
+		{BidID: "bid_defaulted", ProviderID: "prov_sla", SLADefaulted: true, SLA: model.SLACommitment{MaxLatencyMs: 300}},
+		{BidID: "bid_other_category", ProviderID: "prov_sla", Category: "travel.booking", SLA: model.SLACommitment{MaxLatencyMs: 100}},
+		{BidID: "bid_unknown", ProviderID: "prov_unknown", SLA: model.SLACommitment{MaxLatencyMs: 100}},
+	}
+	warnings := svc.checkRegisteredSLAs(context.Background(), bids, "nlp.summarize")
+
+	want := map[string]model.SLACommitment{
+		"bid_honest":         {MaxLatencyMs: 1500, Availability: 0.95},
+		"bid_overpromise":    {MaxLatencyMs: 1000, Availability: 0.99},
+		"bid_missing":        {MaxLatencyMs: 1000, Availability: 0.99},
+		"bid_defaulted":      {MaxLatencyMs: 300},
+		"bid_other_category": {MaxLatencyMs: 1000, Availability: 0.99},
+		"bid_unknown":        {MaxLatencyMs: 100},
+	}
+	for _, bid := range bids {
+		if bid.SLA != want[bid.BidID] {
+			t.Errorf("%s SLA = %+v, want %+v", bid.BidID, bid.SLA, want[bid.BidID])
+		}
+	}
+	if len(warnings) != 2 || warnings["bid_overpromise"] == "" || warnings["bid_other_category"] == "" {
+		t.Errorf("warnings = %v, want bid_overpromise and bid_other_category", warnings)
+	}
+}
+
 func TestEvaluate(t *testing.T) {
 	st := store.NewMemoryEvaluationStore()
 
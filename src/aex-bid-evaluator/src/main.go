@@ -45,6 +45,12 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if cfg.ProviderRegistryURL != "" {
+		svc.SetProviderRegistry(cfg.ProviderRegistryURL)
+	}
+	if err := svc.SetProbationFilter(cfg.ProbationFilter); err != nil {
+		log.Fatal(err)
+	}
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -62,3 +62,141 @@ func TestSubmitBidAndListInternal(t *testing.T) {
 		t.Fatalf("expected 200, got %d", listResp.StatusCode)
 	}
 }
+
+func TestBidRateLimitFromProviderRegistry(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal/v1/providers/validate-key":
+			_ = json.NewEncoder(w).Encode(map[string]any{"valid": true, "provider_id": "prov_noisy", "status": "ACTIVE"})
+		case "/internal/v1/providers/prov_noisy/limits":
+			_ = json.NewEncoder(w).Encode(map[string]any{"provider_id": "prov_noisy", "max_bids_per_minute": 2})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(registry.Close)
+
+	svc := service.NewWithProviderRegistry(store.NewMemoryBidStore(), registry.URL)
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	submit := func() *http.Response {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{
+			"work_id":      "work_1",
+			"price":        1.0,
+			"confidence":   0.9,
+			"a2a_endpoint": "https://agent.example.com/a2a/v1",
+			"expires_at":   time.Now().UTC().Add(5 * time.Minute).Format(time.RFC3339Nano),
+		})
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/bids", bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer noisy-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := submit(); resp.StatusCode != 200 {
+			t.Fatalf("bid %d: expected 200, got %d", i+1, resp.StatusCode)
+		}
+	}
+	resp := submit()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the limit, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header")
+	}
+}
+
+func TestBidSLADefaultsFromProviderRegistry(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal/v1/providers/validate-key":
+			_ = json.NewEncoder(w).Encode(map[string]any{"valid": true, "provider_id": "prov_sla", "status": "ACTIVE"})
+		case "/internal/v1/providers/prov_sla/limits":
+			_ = json.NewEncoder(w).Encode(map[string]any{"provider_id": "prov_sla"})
+		case "/internal/v1/providers/prov_sla/sla":
+			if r.URL.Query().Get("category") != "nlp.summarize" {
+				http.NotFound(w, r)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"capability": "nlp", "max_latency_ms": 1200, "availability": 0.995})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(registry.Close)
+
+	svc := service.NewWithProviderRegistry(store.NewMemoryBidStore(), registry.URL)
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	submit := func(workID string, extra map[string]any) {
+		t.Helper()
+		body := map[string]any{
+			"work_id":      workID,
+			"price":        1.0,
+			"confidence":   0.9,
+			"a2a_endpoint": "https://agent.example.com/a2a/v1",
+			"expires_at":   time.Now().UTC().Add(5 * time.Minute).Format(time.RFC3339Nano),
+		}
+		for k, v := range extra {
+			body[k] = v
+		}
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/bids", bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer sla-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("submit %s: expected 200, got %d", workID, resp.StatusCode)
+		}
+	}
+	type bid struct {
+		SLA struct {
+			MaxLatencyMs int64   `json:"max_latency_ms"`
+			Availability float64 `json:"availability"`
+		} `json:"sla"`
+		SLADefaulted bool `json:"sla_defaulted"`
+	}
+	listed := func(workID string) bid {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/internal/v1/bids?work_id=" + workID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Bids []bid `json:"bids"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		if len(out.Bids) != 1 {
+			t.Fatalf("expected one bid for %s, got %d", workID, len(out.Bids))
+		}
+		return out.Bids[0]
+	}
+
+	submit("work_default", map[string]any{"category": "nlp.summarize"})
+	if got := listed("work_default"); !got.SLADefaulted || got.SLA.MaxLatencyMs != 1200 || got.SLA.Availability != 0.995 {
+		t.Fatalf("expected registered SLA, got %+v", got)
+	}
+	submit("work_explicit", map[string]any{
+		"category": "nlp.summarize",
+		"sla":      map[string]any{"max_latency_ms": 3000, "availability": 0.9},
+	})
+	if got := listed("work_explicit"); got.SLADefaulted || got.SLA.MaxLatencyMs != 3000 {
+		t.Fatalf("explicit SLA should be kept, got %+v", got)
+	}
+	submit("work_uncovered", map[string]any{"category": "travel.booking"})
+	if got := listed("work_uncovered"); got.SLADefaulted || got.SLA.MaxLatencyMs != 0 {
+		t.Fatalf("expected no SLA without a registered commitment, got %+v", got)
+	}
+}
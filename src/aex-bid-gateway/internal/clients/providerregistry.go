@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -61,3 +62,74 @@ func (c *ProviderRegistryClient) ValidateAPIKey(ctx context.Context, apiKey stri
 
 	return result.ProviderID, nil
 }
+
+// SLACommitment is the provider's registered default SLA for a category.
+type SLACommitment struct {
+	Capability   string  `json:"capability"`
+	MaxLatencyMs int64   `json:"max_latency_ms"`
+	Availability float64 `json:"availability"`
+}
+
+// GetSLA fetches the provider's registered SLA commitment covering category.
+// It returns nil without error when the provider has none.
+func (c *ProviderRegistryClient) GetSLA(ctx context.Context, providerID, category string) (*SLACommitment, error) {
+	u := fmt.Sprintf("%s/internal/v1/providers/%s/sla?category=%s", c.baseURL, url.PathEscape(providerID), url.QueryEscape(category))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider sla: status %d", resp.StatusCode)
+	}
+
+	var sla SLACommitment
+	if err := json.NewDecoder(resp.Body).Decode(&sla); err != nil {
+		return nil, err
+	}
+	return &sla, nil
+}
+
+// ProviderLimits are the per-provider limits set in the provider registry.
+// Zero means unlimited.
+type ProviderLimits struct {
+	ProviderID             string `json:"provider_id"`
+	MaxBidsPerMinute       int    `json:"max_bids_per_minute"`
+	MaxConcurrentContracts int    `json:"max_concurrent_contracts"`
+}
+
+// GetLimits fetches the limits to enforce for a provider
+func (c *ProviderRegistryClient) GetLimits(ctx context.Context, providerID string) (*ProviderLimits, error) {
+	url := fmt.Sprintf("%s/internal/v1/providers/%s/limits", c.baseURL, providerID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider limits: status %d", resp.StatusCode)
+	}
+
+	var limits ProviderLimits
+	if err := json.NewDecoder(resp.Body).Decode(&limits); err != nil {
+		return nil, err
+	}
+	return &limits, nil
+}
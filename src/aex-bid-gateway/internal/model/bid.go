@@ -28,6 +28,10 @@ type BidPacket struct {
 	MVPSample *MVPSample `json:"mvp_sample,omitempty" bson:"mvp_sample,omitempty"`
 
 	SLA SLACommitment `json:"sla" bson:"sla"`
+	// SLADefaulted is set when the bid omitted its SLA block and SLA was
+	// taken from the provider's registered commitment for Category.
+	SLADefaulted bool   `json:"sla_defaulted,omitempty" bson:"sla_defaulted,omitempty"`
+	Category     string `json:"category,omitempty" bson:"category,omitempty"`
 
 	A2AEndpoint string    `json:"a2a_endpoint" bson:"a2a_endpoint"`
 	ExpiresAt   time.Time `json:"expires_at" bson:"expires_at"`
@@ -42,7 +46,8 @@ type SubmitBidRequest struct {
 	Approach         string             `json:"approach"`
 	EstimatedLatency int64              `json:"estimated_latency_ms"`
 	MVPSample        *MVPSample         `json:"mvp_sample,omitempty"`
-	SLA              SLACommitment      `json:"sla"`
+	SLA              *SLACommitment     `json:"sla,omitempty"`
+	Category         string             `json:"category,omitempty"`
 	A2AEndpoint      string             `json:"a2a_endpoint"`
 	ExpiresAt        time.Time          `json:"expires_at"`
 }
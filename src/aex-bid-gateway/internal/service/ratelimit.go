@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-bid-gateway/internal/clients"
+)
+
+// ProviderLimitsSource returns the limits configured for a provider
+type ProviderLimitsSource interface {
+	GetLimits(ctx context.Context, providerID string) (*clients.ProviderLimits, error)
+}
+
+// limitsCacheTTL bounds how long a limit change in the provider registry
+// takes to reach the gateway.
+const limitsCacheTTL = time.Minute
+
+type cachedLimit struct {
+	maxPerMinute int
+	fetchedAt    time.Time
+}
+
+// bidLimiter enforces max_bids_per_minute with a sliding one-minute window
+// per provider. State is per gateway instance.
+type bidLimiter struct {
+	source ProviderLimitsSource
+
+	mu     sync.Mutex
+	limits map[string]cachedLimit
+	recent map[string][]time.Time
+}
+
+func newBidLimiter(source ProviderLimitsSource) *bidLimiter {
+	return &bidLimiter{
+		source: source,
+		limits: map[string]cachedLimit{},
+		recent: map[string][]time.Time{},
+	}
+}
+
+// Allow records a bid for providerID and reports whether it is within the
+// limit. When it is not, retryAfter is when the oldest bid leaves the window.
+// Limits that cannot be fetched are treated as unlimited.
+func (l *bidLimiter) Allow(ctx context.Context, providerID string, now time.Time) (bool, time.Duration) {
+	limit := l.limit(ctx, providerID, now)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := now.Add(-time.Minute)
+	window := l.recent[providerID]
+	i := 0
+	for i < len(window) && !window[i].After(cutoff) {
+		i++
+	}
+	window = window[i:]
+	if limit > 0 && len(window) >= limit {
+		l.recent[providerID] = window
+		return false, window[0].Sub(cutoff)
+	}
+	l.recent[providerID] = append(window, now)
+	return true, 0
+}
+
+func (l *bidLimiter) limit(ctx context.Context, providerID string, now time.Time) int {
+	l.mu.Lock()
+	c, ok := l.limits[providerID]
+	l.mu.Unlock()
+	if ok && now.Sub(c.fetchedAt) < limitsCacheTTL {
+		return c.maxPerMinute
+	}
+
+	limits, err := l.source.GetLimits(ctx, providerID)
+	if err != nil {
+		log.Printf("provider limits lookup failed provider_id=%s: %v", providerID, err)
+		return c.maxPerMinute
+	}
+	l.mu.Lock()
+	l.limits[providerID] = cachedLimit{maxPerMinute: limits.MaxBidsPerMinute, fetchedAt: now}
+	l.mu.Unlock()
+	return limits.MaxBidsPerMinute
+}
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,6 +37,18 @@ type Service struct {
 
 	// Dynamic validation via provider registry
 	providerRegistry ProviderKeyValidator
+
+	// Per-provider bid rate limits from the provider registry (optional)
+	limiter *bidLimiter
+
+	// Registered SLA commitments for bids without an SLA block (optional)
+	slaDefaults ProviderSLASource
+}
+
+// ProviderSLASource returns a provider's registered SLA commitment for a
+// category, or nil when it has none.
+type ProviderSLASource interface {
+	GetSLA(ctx context.Context, providerID, category string) (*clients.SLACommitment, error)
 }
 
 func New(store store.BidStore, providerKeys map[string]string) *Service {
@@ -46,10 +60,13 @@ func New(store store.BidStore, providerKeys map[string]string) *Service {
 
 // NewWithProviderRegistry creates a service that validates API keys against the provider registry
 func NewWithProviderRegistry(store store.BidStore, providerRegistryURL string) *Service {
+	registry := clients.NewProviderRegistryClient(providerRegistryURL)
 	return &Service{
 		store:            store,
 		providerKeys:     map[string]string{},
-		providerRegistry: clients.NewProviderRegistryClient(providerRegistryURL),
+		providerRegistry: registry,
+		limiter:          newBidLimiter(registry),
+		slaDefaults:      registry,
 	}
 }
 
@@ -62,6 +79,14 @@ func (s *Service) HandleSubmitBid(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.limiter != nil {
+		if ok, retryAfter := s.limiter.Allow(ctx, providerID, time.Now().UTC()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+	}
+
 	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
 	if err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
@@ -86,11 +111,16 @@ func (s *Service) HandleSubmitBid(w http.ResponseWriter, r *http.Request) {
 		Approach:         req.Approach,
 		EstimatedLatency: req.EstimatedLatency,
 		MVPSample:        req.MVPSample,
-		SLA:              req.SLA,
+		Category:         strings.TrimSpace(req.Category),
 		A2AEndpoint:      req.A2AEndpoint,
 		ExpiresAt:        req.ExpiresAt,
 		ReceivedAt:       now,
 	}
+	if req.SLA != nil {
+		bid.SLA = *req.SLA
+	} else {
+		s.applyDefaultSLA(ctx, &bid)
+	}
 
 	if err := validateBid(now, bid); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -159,6 +189,24 @@ func (s *Service) validateProviderAuth(r *http.Request) (string, error) {
 	return "", ErrUnauthorized
 }
 
+// applyDefaultSLA fills in the provider's registered commitment for the
+// bid's category. Lookup failures leave the SLA empty.
+func (s *Service) applyDefaultSLA(ctx context.Context, bid *model.BidPacket) {
+	if s.slaDefaults == nil {
+		return
+	}
+	sla, err := s.slaDefaults.GetSLA(ctx, bid.ProviderID, bid.Category)
+	if err != nil {
+		log.Printf("provider sla lookup failed provider_id=%s: %v", bid.ProviderID, err)
+		return
+	}
+	if sla == nil {
+		return
+	}
+	bid.SLA = model.SLACommitment{MaxLatencyMs: sla.MaxLatencyMs, Availability: sla.Availability}
+	bid.SLADefaulted = true
+}
+
 func validateBid(now time.Time, bid model.BidPacket) error {
 	if bid.WorkID == "" || bid.Price <= 0 || bid.A2AEndpoint == "" {
 		return errors.New("missing required fields")
@@ -3,12 +3,18 @@ module github.com/parlakisik/agent-exchange/aex-contract-engine
 go 1.24.0
 
 require (
+	github.com/parlakisik/agent-exchange/internal/events v0.0.0
 	github.com/parlakisik/agent-exchange/internal/httpclient v0.0.0
+	github.com/parlakisik/agent-exchange/internal/serviceauth v0.0.0
 	go.mongodb.org/mongo-driver v1.14.0
 )
 
+replace github.com/parlakisik/agent-exchange/internal/events => ../internal/events
+
 replace github.com/parlakisik/agent-exchange/internal/httpclient => ../internal/httpclient
 
+replace github.com/parlakisik/agent-exchange/internal/serviceauth => ../internal/serviceauth
+
 require (
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/klauspost/compress v1.13.6 // indirect
@@ -2,15 +2,22 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
 	cehttp "github.com/parlakisik/agent-exchange/aex-contract-engine/internal/httpapi"
 	cesvc "github.com/parlakisik/agent-exchange/aex-contract-engine/internal/service"
 	cestore "github.com/parlakisik/agent-exchange/aex-contract-engine/internal/store"
+	cewebhook "github.com/parlakisik/agent-exchange/aex-contract-engine/internal/webhook"
 )
 
 func TestAwardProgressCompleteFlow(t *testing.T) {
@@ -97,4 +104,1338 @@ func TestAwardProgressCompleteFlow(t *testing.T) {
 	if resp2.StatusCode != 200 {
 		t.Fatalf("complete expected 200, got %d", resp2.StatusCode)
 	}
+
+	// timeline
+	resp3, err := http.Get(ts.URL + "/v1/contracts/" + awardOut.ContractID + "/timeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp3.Body.Close()
+	if resp3.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("timeline without a token expected 401, got %d", resp3.StatusCode)
+	}
+	req3, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/contracts/"+awardOut.ContractID+"/timeline", nil)
+	req3.Header.Set("Authorization", "Bearer "+awardOut.ExecutionToken)
+	resp3, err = http.DefaultClient.Do(req3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp3.Body.Close() }()
+	if resp3.StatusCode != 200 {
+		t.Fatalf("timeline expected 200, got %d", resp3.StatusCode)
+	}
+	var timeline struct {
+		Events []struct {
+			Type string `json:"type"`
+		} `json:"events"`
+	}
+	_ = json.NewDecoder(resp3.Body).Decode(&timeline)
+	var types []string
+	for _, e := range timeline.Events {
+		types = append(types, e.Type)
+	}
+	if len(types) != 3 || types[0] != "awarded" || types[1] != "progress" || types[2] != "completed" {
+		t.Fatalf("unexpected timeline: %v", types)
+	}
+}
+
+// newBidGatewayStub serves a single unexpired bid (bid_1) for any work_id.
+func newBidGatewayStub(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newBidGatewayStubWithBids(t, map[string]any{
+		"bid_id":       "bid_1",
+		"provider_id":  "prov_a",
+		"price":        0.10,
+		"a2a_endpoint": "https://a2a/a",
+	})
+}
+
+// newBidGatewayStubWithBids serves the given bids, each unexpired, for any
+// work_id.
+func newBidGatewayStubWithBids(t *testing.T, bids ...map[string]any) *httptest.Server {
+	t.Helper()
+	bg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/internal/v1/bids" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		workID := r.URL.Query().Get("work_id")
+		now := time.Now().UTC()
+		out := make([]map[string]any, 0, len(bids))
+		for _, b := range bids {
+			bid := map[string]any{
+				"work_id":     workID,
+				"expires_at":  now.Add(10 * time.Minute).Format(time.RFC3339Nano),
+				"received_at": now.Format(time.RFC3339Nano),
+			}
+			for k, v := range b {
+				bid[k] = v
+			}
+			out = append(out, bid)
+		}
+		resp := map[string]any{
+			"work_id":    workID,
+			"bids":       out,
+			"total_bids": len(out),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(bg.Close)
+	return bg
+}
+
+type awardResult struct {
+	ContractID     string `json:"contract_id"`
+	ExecutionToken string `json:"execution_token"`
+	ConsumerToken  string `json:"consumer_token"`
+}
+
+func award(t *testing.T, baseURL, workID string) awardResult {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"bid_id": "bid_1"})
+	resp, err := http.Post(baseURL+"/v1/work/"+workID+"/award", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != 200 {
+		t.Fatalf("award expected 200, got %d", resp.StatusCode)
+	}
+	var out awardResult
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return out
+}
+
+func postWithToken(t *testing.T, url, token string, payload any) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestConsumerCancelChargesFeeOnceExecuting(t *testing.T) {
+	bg := newBidGatewayStub(t)
+
+	var settled map[string]any
+	settlement := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/settlement/complete" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&settled)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	t.Cleanup(settlement.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{
+		SettlementURL:          settlement.URL,
+		CancellationFeePercent: 50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	contractURL := ts.URL + "/v1/contracts/" + a.ContractID
+
+	resp := postWithToken(t, contractURL+"/progress", a.ExecutionToken, map[string]any{"status": "working"})
+	_ = resp.Body.Close()
+
+	// The provider's execution token must not be able to cancel.
+	resp = postWithToken(t, contractURL+"/cancel", a.ExecutionToken, map[string]any{"reason": "x"})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("cancel with execution token expected 401, got %d", resp.StatusCode)
+	}
+
+	resp = postWithToken(t, contractURL+"/cancel", a.ConsumerToken, map[string]any{"reason": "no longer needed"})
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != 200 {
+		t.Fatalf("cancel expected 200, got %d", resp.StatusCode)
+	}
+	var out struct {
+		Status          string  `json:"status"`
+		CancellationFee float64 `json:"cancellation_fee"`
+		FeeSettled      bool    `json:"fee_settled"`
+		TrustOutcome    string  `json:"trust_outcome"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if out.Status != "CANCELLED" || out.TrustOutcome != "FAILURE_CONSUMER" {
+		t.Fatalf("unexpected cancel result: %+v", out)
+	}
+	if out.CancellationFee != 0.05 || !out.FeeSettled {
+		t.Fatalf("expected settled fee of 0.05, got %+v", out)
+	}
+	if settled["agreed_price"] != "0.05" || settled["provider_id"] != "prov_a" {
+		t.Fatalf("unexpected settlement payload: %v", settled)
+	}
+
+	// Cancelling twice is a conflict.
+	resp2 := postWithToken(t, contractURL+"/cancel", a.ConsumerToken, map[string]any{})
+	_ = resp2.Body.Close()
+	if resp2.StatusCode != http.StatusConflict {
+		t.Fatalf("second cancel expected 409, got %d", resp2.StatusCode)
+	}
+}
+
+func TestUnsuccessfulCompletionFailsWithoutSettlement(t *testing.T) {
+	bg := newBidGatewayStub(t)
+
+	var mu sync.Mutex
+	settlements := 0
+	settlement := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		settlements++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	t.Cleanup(settlement.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{SettlementURL: settlement.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	contractURL := ts.URL + "/v1/contracts/" + a.ContractID
+	resp := postWithToken(t, contractURL+"/complete", a.ExecutionToken, map[string]any{"success": false, "result_summary": "model crashed"})
+	defer func() { _ = resp.Body.Close() }()
+	var out struct {
+		Status        string `json:"status"`
+		FailureReason string `json:"failure_reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != http.StatusOK || out.Status != "FAILED" || out.FailureReason != "completed_unsuccessfully" {
+		t.Fatalf("unsuccessful completion: expected 200 FAILED, got %d %+v", resp.StatusCode, out)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if settlements != 0 {
+		t.Fatalf("expected no settlement for unsuccessful work, got %d", settlements)
+	}
+}
+
+func TestClosedContractsRejectExecutionReports(t *testing.T) {
+	bg := newBidGatewayStub(t)
+
+	var mu sync.Mutex
+	settlements := 0
+	settlement := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		settlements++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	t.Cleanup(settlement.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{SettlementURL: settlement.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	status := func(url, token string, payload any) int {
+		t.Helper()
+		resp := postWithToken(t, url, token, payload)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// A cancelled contract can no longer be started, completed or failed.
+	cancelled := award(t, ts.URL, "work_1")
+	cancelledURL := ts.URL + "/v1/contracts/" + cancelled.ContractID
+	if code := status(cancelledURL+"/cancel", cancelled.ConsumerToken, map[string]any{"reason": "changed plans"}); code != http.StatusOK {
+		t.Fatalf("cancel expected 200, got %d", code)
+	}
+	if code := status(cancelledURL+"/complete", cancelled.ExecutionToken, map[string]any{"success": true}); code != http.StatusConflict {
+		t.Fatalf("complete after cancel expected 409, got %d", code)
+	}
+	if code := status(cancelledURL+"/progress", cancelled.ExecutionToken, map[string]any{"status": "working"}); code != http.StatusConflict {
+		t.Fatalf("progress after cancel expected 409, got %d", code)
+	}
+	if code := status(cancelledURL+"/fail", cancelled.ExecutionToken, map[string]any{"reason": "error"}); code != http.StatusConflict {
+		t.Fatalf("fail after cancel expected 409, got %d", code)
+	}
+
+	// Racing completions settle the contract once.
+	done := award(t, ts.URL, "work_2")
+	doneURL := ts.URL + "/v1/contracts/" + done.ContractID
+	codes := make([]int, 4)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = status(doneURL+"/complete", done.ExecutionToken, map[string]any{"success": true})
+		}()
+	}
+	wg.Wait()
+	completed := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			completed++
+		}
+	}
+	if completed != 1 {
+		t.Fatalf("expected exactly one completion to succeed, got %v", codes)
+	}
+	if code := status(doneURL+"/fail", done.ConsumerToken, map[string]any{"reason": "bad result"}); code != http.StatusConflict {
+		t.Fatalf("fail after completion expected 409, got %d", code)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if settlements != 1 {
+		t.Fatalf("expected one settlement, got %d", settlements)
+	}
+}
+
+func TestAmendmentRequiresCounterpartyAcceptance(t *testing.T) {
+	bg := newBidGatewayStub(t)
+	svc, err := cesvc.New(cestore.NewMemoryContractStore(), bg.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	contractURL := ts.URL + "/v1/contracts/" + a.ContractID
+
+	resp := postWithToken(t, contractURL+"/amendments", a.ExecutionToken, map[string]any{"agreed_price": 0.25, "reason": "scope grew"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("propose expected 201, got %d", resp.StatusCode)
+	}
+	var proposed struct {
+		AmendmentID string `json:"amendment_id"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&proposed)
+	_ = resp.Body.Close()
+
+	acceptURL := contractURL + "/amendments/" + proposed.AmendmentID + "/accept"
+	resp = postWithToken(t, acceptURL, a.ExecutionToken, map[string]any{})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("self-accept expected 403, got %d", resp.StatusCode)
+	}
+
+	resp = postWithToken(t, acceptURL, a.ConsumerToken, map[string]any{})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("accept expected 200, got %d", resp.StatusCode)
+	}
+
+	getResp, err := http.Get(contractURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = getResp.Body.Close() }()
+	var c struct {
+		AgreedPrice float64 `json:"agreed_price"`
+		Revision    int     `json:"revision"`
+		Revisions   []struct {
+			Revision    int     `json:"revision"`
+			AgreedPrice float64 `json:"agreed_price"`
+		} `json:"revisions"`
+	}
+	_ = json.NewDecoder(getResp.Body).Decode(&c)
+	if c.AgreedPrice != 0.25 || c.Revision != 2 || len(c.Revisions) != 2 {
+		t.Fatalf("unexpected contract after amendment: %+v", c)
+	}
+	if c.Revisions[0].AgreedPrice != 0.10 {
+		t.Fatalf("original revision must be preserved, got %+v", c.Revisions[0])
+	}
+}
+
+func TestWebhookNotificationsAreSignedAndRetried(t *testing.T) {
+	bg := newBidGatewayStub(t)
+
+	var mu sync.Mutex
+	var received []string
+	var calls int
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			// Fail the first delivery to exercise the retry path.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get(cewebhook.SignatureHeader) != cewebhook.Sign([]byte("s3cret"), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var ev struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(body, &ev)
+		received = append(received, ev.Type)
+	}))
+	t.Cleanup(callback.Close)
+
+	wp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"work_id":      "work_1",
+			"consumer_id":  "tenant_1",
+			"callback_url": callback.URL,
+		})
+	}))
+	t.Cleanup(wp.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{
+		WorkPublisherURL: wp.URL,
+		WebhookSecret:    "s3cret",
+		WebhookBackoff:   10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1
+	})
+
+	resp := postWithToken(t, ts.URL+"/v1/contracts/"+a.ContractID+"/complete", a.ExecutionToken, map[string]any{"success": true})
+	_ = resp.Body.Close()
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2
+	})
+	if received[0] != "contract.awarded" || received[1] != "contract.completed" {
+		t.Fatalf("unexpected events: %v", received)
+	}
+
+	getResp, err := http.Get(ts.URL + "/v1/contracts/" + a.ContractID + "/webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = getResp.Body.Close()
+	if getResp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("deliveries without a token expected 401, got %d", getResp.StatusCode)
+	}
+	getReq, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/contracts/"+a.ContractID+"/webhooks", nil)
+	getReq.Header.Set("Authorization", "Bearer "+a.ConsumerToken)
+	getResp, err = http.DefaultClient.Do(getReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = getResp.Body.Close() }()
+	var out struct {
+		Deliveries []struct {
+			Status   string `json:"status"`
+			Attempts int    `json:"attempts"`
+		} `json:"deliveries"`
+	}
+	_ = json.NewDecoder(getResp.Body).Decode(&out)
+	if len(out.Deliveries) != 2 || out.Deliveries[0].Attempts != 2 || out.Deliveries[0].Status != "delivered" {
+		t.Fatalf("unexpected deliveries: %+v", out.Deliveries)
+	}
+
+	// A consumer-reported failure is a dispute, as on the event bus.
+	b := award(t, ts.URL, "work_2")
+	resp = postWithToken(t, ts.URL+"/v1/contracts/"+b.ContractID+"/fail", b.ConsumerToken, map[string]any{"reason": "quality_issue"})
+	_ = resp.Body.Close()
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 4
+	})
+	if received[3] != "contract.disputed" {
+		t.Fatalf("consumer failure: expected contract.disputed, got %v", received)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
+
+func TestConcurrentAwardsReturnSameContract(t *testing.T) {
+	bg := newBidGatewayStub(t)
+	svc, err := cesvc.New(cestore.NewMemoryContractStore(), bg.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	const n = 8
+	ids := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Post(ts.URL+"/v1/work/work_1/award", "application/json", bytes.NewReader([]byte(`{"bid_id":"bid_1"}`)))
+			if err != nil {
+				return
+			}
+			defer func() { _ = resp.Body.Close() }()
+			var out awardResult
+			_ = json.NewDecoder(resp.Body).Decode(&out)
+			ids[i] = out.ContractID
+		}(i)
+	}
+	wg.Wait()
+	for _, id := range ids {
+		if id == "" || id != ids[0] {
+			t.Fatalf("expected a single contract, got %v", ids)
+		}
+	}
+
+	// Once the contract terminates the work can be awarded again.
+	c := award(t, ts.URL, "work_1")
+	resp := postWithToken(t, ts.URL+"/v1/contracts/"+c.ContractID+"/fail", c.ConsumerToken, map[string]any{"reason": "x"})
+	_ = resp.Body.Close()
+	if again := award(t, ts.URL, "work_1"); again.ContractID == c.ContractID {
+		t.Fatalf("expected a new contract after failure")
+	}
+}
+
+func TestAutoAwardPolicyDefersBelowThreshold(t *testing.T) {
+	bg := newBidGatewayStub(t)
+	wp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"work_id":     "work_1",
+			"consumer_id": "tenant_1",
+			"budget":      map[string]any{"max_price": 0.20},
+		})
+	}))
+	t.Cleanup(wp.Close)
+	ev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"ranked_bids": []map[string]any{{"rank": 1, "bid_id": "bid_1", "provider_id": "prov_a", "total_score": 0.6}},
+		})
+	}))
+	t.Cleanup(ev.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{
+		WorkPublisherURL: wp.URL,
+		BidEvaluatorURL:  ev.URL,
+		AdminToken:       "admin",
+		GatewayToken:     "gw_secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	policyRequest := func(method, tenantID, gatewayToken, token, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+"/v1/consumers/tenant_1/award-policy", bytes.NewReader([]byte(body)))
+		if tenantID != "" {
+			req.Header.Set("X-Tenant-ID", tenantID)
+		}
+		if gatewayToken != "" {
+			req.Header.Set("X-Gateway-Token", gatewayToken)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	putPolicy := func(tenantID, token, body string) int {
+		t.Helper()
+		return policyRequest(http.MethodPut, tenantID, "gw_secret", token, body)
+	}
+	// Only the consumer, named by the gateway, or an admin may set the
+	// policy.
+	if status := policyRequest(http.MethodPut, "tenant_1", "", "", `{"min_score":0.1}`); status != http.StatusForbidden {
+		t.Fatalf("put policy without the gateway token expected 403, got %d", status)
+	}
+	if status := policyRequest(http.MethodPut, "tenant_1", "forged", "", `{"min_score":0.1}`); status != http.StatusForbidden {
+		t.Fatalf("put policy with a wrong gateway token expected 403, got %d", status)
+	}
+	if status := putPolicy("", "", `{"min_score":0.1}`); status != http.StatusForbidden {
+		t.Fatalf("put policy without credentials expected 403, got %d", status)
+	}
+	if status := putPolicy("tenant_2", "", `{"min_score":0.1}`); status != http.StatusForbidden {
+		t.Fatalf("put policy of another tenant expected 403, got %d", status)
+	}
+	if status := putPolicy("", "admin", `{"min_score":0.1}`); status != http.StatusOK {
+		t.Fatalf("put policy with admin token expected 200, got %d", status)
+	}
+	if status := putPolicy("tenant_1", "", `{"min_score":-1}`); status != http.StatusBadRequest {
+		t.Fatalf("put negative min_score expected 400, got %d", status)
+	}
+	// Consumer default: require a score of at least 0.8.
+	if status := putPolicy("tenant_1", "", `{"min_score":0.8}`); status != http.StatusOK {
+		t.Fatalf("put policy expected 200, got %d", status)
+	}
+	if status := policyRequest(http.MethodGet, "tenant_1", "", "", ""); status != http.StatusForbidden {
+		t.Fatalf("get policy without the gateway token expected 403, got %d", status)
+	}
+	if status := policyRequest(http.MethodGet, "tenant_1", "gw_secret", "", ""); status != http.StatusOK {
+		t.Fatalf("get policy expected 200, got %d", status)
+	}
+
+	resp, err := http.Post(ts.URL+"/v1/work/work_1/award", "application/json", bytes.NewReader([]byte(`{"auto_award":true,"policy":{"max_budget_percent":-5}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("auto award with invalid policy expected 400, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/v1/work/work_1/award", "application/json", bytes.NewReader([]byte(`{"auto_award":true}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("auto award below min_score expected 202, got %d", resp.StatusCode)
+	}
+
+	// A per-work policy overrides the consumer default: 0.6 score is enough
+	// and the 0.10 price is 50% of budget.
+	resp, err = http.Post(ts.URL+"/v1/work/work_1/award", "application/json",
+		bytes.NewReader([]byte(`{"auto_award":true,"policy":{"min_score":0.5,"max_budget_percent":60}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("auto award with per-work policy expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestProviderNoShowIsFailedAndReawarded(t *testing.T) {
+	bg := newBidGatewayStubWithBids(t,
+		map[string]any{"bid_id": "bid_1", "provider_id": "prov_a", "price": 0.10, "a2a_endpoint": "https://a2a/a"},
+		map[string]any{"bid_id": "bid_2", "provider_id": "prov_b", "price": 0.12, "a2a_endpoint": "https://a2a/b"},
+	)
+
+	var mu sync.Mutex
+	var outcomes []map[string]any
+	trust := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var out map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&out)
+		mu.Lock()
+		outcomes = append(outcomes, out)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"recorded":true}`))
+	}))
+	t.Cleanup(trust.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{
+		StartDeadline:   20 * time.Millisecond,
+		ReawardOnNoShow: true,
+		TrustBrokerURL:  trust.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	// A zero interval disables the monitor rather than panicking.
+	svc.RunNoShowMonitor(ctx, 0)
+	go svc.RunNoShowMonitor(ctx, 5*time.Millisecond)
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	first := award(t, ts.URL, "work_1")
+
+	var failed struct {
+		Status        string `json:"status"`
+		FailureReason string `json:"failure_reason"`
+		ReawardedTo   string `json:"reawarded_to"`
+	}
+	waitFor(t, func() bool {
+		resp, err := http.Get(ts.URL + "/v1/contracts/" + first.ContractID)
+		if err != nil {
+			return false
+		}
+		defer func() { _ = resp.Body.Close() }()
+		_ = json.NewDecoder(resp.Body).Decode(&failed)
+		return failed.ReawardedTo != ""
+	})
+	if failed.Status != "FAILED" || failed.FailureReason != "provider_no_show" {
+		t.Fatalf("unexpected no-show contract: %+v", failed)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/contracts/" + failed.ReawardedTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var next struct {
+		ProviderID         string `json:"provider_id"`
+		ReplacesContractID string `json:"replaces_contract_id"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&next)
+	if next.ProviderID != "prov_b" || next.ReplacesContractID != first.ContractID {
+		t.Fatalf("unexpected re-awarded contract: %+v", next)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(outcomes) == 0 || outcomes[0]["outcome"] != "FAILURE_PROVIDER" || outcomes[0]["provider_id"] != "prov_a" {
+		t.Fatalf("unexpected trust outcomes: %v", outcomes)
+	}
+}
+
+func TestNoShowIsReportedToTrustBrokerOnce(t *testing.T) {
+	bg := newBidGatewayStub(t)
+
+	var mu sync.Mutex
+	var direct int
+	var published []string
+	trust := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		direct++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"recorded":true}`))
+	}))
+	t.Cleanup(trust.Close)
+	bus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env struct {
+			EventType string `json:"event_type"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&env)
+		mu.Lock()
+		published = append(published, env.EventType)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(bus.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{
+		StartDeadline:  20 * time.Millisecond,
+		TrustBrokerURL: trust.URL,
+		EventsURL:      bus.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go svc.RunNoShowMonitor(ctx, 5*time.Millisecond)
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	award(t, ts.URL, "work_1")
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Contains(published, "contract.failed")
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if direct != 0 {
+		t.Fatalf("expected the outcome only on the event bus, got %d direct reports", direct)
+	}
+}
+
+func TestCancellationIsReportedToTrustBrokerWithoutEventBus(t *testing.T) {
+	bg := newBidGatewayStub(t)
+
+	var mu sync.Mutex
+	var reported []map[string]any
+	trust := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var outcome map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&outcome)
+		mu.Lock()
+		reported = append(reported, outcome)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"recorded":true}`))
+	}))
+	t.Cleanup(trust.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{TrustBrokerURL: trust.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	resp := postWithToken(t, ts.URL+"/v1/contracts/"+a.ContractID+"/cancel", a.ConsumerToken, map[string]any{"reason": "changed plans"})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("cancel expected 200, got %d", resp.StatusCode)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 1 || reported[0]["contract_id"] != a.ContractID || reported[0]["outcome"] != "FAILURE_CONSUMER" {
+		t.Fatalf("expected one consumer failure reported, got %v", reported)
+	}
+}
+
+func TestProviderConcurrentContractLimit(t *testing.T) {
+	bg := newBidGatewayStubWithBids(t,
+		map[string]any{"bid_id": "bid_1", "provider_id": "prov_a", "price": 0.10, "a2a_endpoint": "https://a2a/a"},
+		map[string]any{"bid_id": "bid_2", "provider_id": "prov_b", "price": 0.12, "a2a_endpoint": "https://a2a/b"},
+	)
+	// prov_a may hold one contract at a time; prov_b is unlimited.
+	limits := http.NewServeMux()
+	limits.HandleFunc("GET /internal/v1/providers/{provider_id}/limits", func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if r.PathValue("provider_id") == "prov_a" {
+			limit = 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"provider_id": r.PathValue("provider_id"), "max_concurrent_contracts": limit})
+	})
+	registry := httptest.NewServer(limits)
+	t.Cleanup(registry.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{ProviderRegistryURL: registry.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	awardBid := func(workID string, body string) (int, string) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/v1/work/"+workID+"/award", "application/json", bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			ProviderID string `json:"provider_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.ProviderID
+	}
+
+	first := award(t, ts.URL, "work_1")
+	if status, _ := awardBid("work_2", `{"bid_id":"bid_1"}`); status != http.StatusConflict {
+		t.Fatalf("award to a provider at its limit expected 409, got %d", status)
+	}
+	// Auto-award passes over the provider at its limit.
+	if status, provider := awardBid("work_3", `{"auto_award":true}`); status != http.StatusOK || provider != "prov_b" {
+		t.Fatalf("auto award expected prov_b, got %d %s", status, provider)
+	}
+
+	// Completing the contract frees the provider.
+	resp := postWithToken(t, ts.URL+"/v1/contracts/"+first.ContractID+"/complete", first.ExecutionToken, map[string]any{"success": true})
+	_ = resp.Body.Close()
+	if status, _ := awardBid("work_2", `{"bid_id":"bid_1"}`); status != http.StatusOK {
+		t.Fatalf("award after completion expected 200, got %d", status)
+	}
+}
+
+func TestArtifactUploadAndDownload(t *testing.T) {
+	bg := newBidGatewayStub(t)
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{MaxArtifactBytes: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	artifactsURL := ts.URL + "/v1/contracts/" + a.ContractID + "/artifacts"
+	upload := func(token string, body []byte, checksum string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, artifactsURL+"?name=out.txt", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "text/plain")
+		if checksum != "" {
+			req.Header.Set(cesvc.ChecksumHeader, checksum)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	content := []byte("hello artifact")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	for name, tc := range map[string]struct {
+		token    string
+		body     []byte
+		checksum string
+		want     int
+	}{
+		"consumer cannot upload": {a.ConsumerToken, content, "", http.StatusUnauthorized},
+		"checksum mismatch":      {a.ExecutionToken, content, "00", http.StatusBadRequest},
+		"too large":              {a.ExecutionToken, bytes.Repeat([]byte("x"), 17), "", http.StatusRequestEntityTooLarge},
+	} {
+		resp := upload(tc.token, tc.body, tc.checksum)
+		_ = resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s: expected %d, got %d", name, tc.want, resp.StatusCode)
+		}
+	}
+
+	resp := upload(a.ExecutionToken, content, digest)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload expected 201, got %d", resp.StatusCode)
+	}
+	var meta struct {
+		ArtifactID string `json:"artifact_id"`
+		SHA256     string `json:"sha256"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&meta)
+	_ = resp.Body.Close()
+	if meta.SHA256 != digest {
+		t.Fatalf("unexpected digest %q", meta.SHA256)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, artifactsURL+"/"+meta.ArtifactID, nil)
+	req.Header.Set("Authorization", "Bearer "+a.ConsumerToken)
+	dl, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dl.Body.Close() }()
+	got, _ := io.ReadAll(dl.Body)
+	if dl.StatusCode != http.StatusOK || !bytes.Equal(got, content) || dl.Header.Get(cesvc.ChecksumHeader) != digest {
+		t.Fatalf("unexpected download: status=%d body=%q", dl.StatusCode, got)
+	}
+}
+
+func TestMultiPartyContractSplitsPayout(t *testing.T) {
+	bg := newBidGatewayStub(t)
+
+	var settled struct {
+		PayoutSplits []struct {
+			ProviderID   string  `json:"provider_id"`
+			SharePercent float64 `json:"share_percent"`
+		} `json:"payout_splits"`
+	}
+	settlement := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&settled)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	t.Cleanup(settlement.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{SettlementURL: settlement.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	contractURL := ts.URL + "/v1/contracts/" + a.ContractID
+
+	// Shares must leave something for the primary provider.
+	resp := postWithToken(t, contractURL+"/parties", a.ExecutionToken, map[string]any{
+		"provider_id": "prov_b", "step": "translate", "share_percent": 100,
+	})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("full share expected 400, got %d", resp.StatusCode)
+	}
+
+	resp = postWithToken(t, contractURL+"/parties", a.ExecutionToken, map[string]any{
+		"provider_id": "prov_b", "step": "translate", "share_percent": 30,
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("add party expected 201, got %d", resp.StatusCode)
+	}
+	var party struct {
+		PartyID        string `json:"party_id"`
+		ExecutionToken string `json:"execution_token"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&party)
+	_ = resp.Body.Close()
+	if party.ExecutionToken == "" || party.ExecutionToken == a.ExecutionToken {
+		t.Fatalf("party needs its own execution token: %+v", party)
+	}
+
+	// Parties cannot subcontract further.
+	resp = postWithToken(t, contractURL+"/parties", party.ExecutionToken, map[string]any{
+		"provider_id": "prov_c", "step": "review", "share_percent": 10,
+	})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("add party with party token expected 401, got %d", resp.StatusCode)
+	}
+
+	resp = postWithToken(t, contractURL+"/progress", party.ExecutionToken, map[string]any{"status": "working"})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("party progress expected 200, got %d", resp.StatusCode)
+	}
+
+	// The contract cannot complete while a step is pending.
+	resp = postWithToken(t, contractURL+"/complete", a.ExecutionToken, map[string]any{"success": true})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("complete with pending party expected 409, got %d", resp.StatusCode)
+	}
+
+	resp = postWithToken(t, contractURL+"/complete", party.ExecutionToken, map[string]any{"success": true})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("party complete expected 200, got %d", resp.StatusCode)
+	}
+
+	resp = postWithToken(t, contractURL+"/complete", a.ExecutionToken, map[string]any{"success": true})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("complete expected 200, got %d", resp.StatusCode)
+	}
+	if len(settled.PayoutSplits) != 2 ||
+		settled.PayoutSplits[0].ProviderID != "prov_a" || settled.PayoutSplits[0].SharePercent != 70 ||
+		settled.PayoutSplits[1].ProviderID != "prov_b" || settled.PayoutSplits[1].SharePercent != 30 {
+		t.Fatalf("unexpected payout splits: %+v", settled.PayoutSplits)
+	}
+}
+
+func TestContractEventsArePublished(t *testing.T) {
+	bg := newBidGatewayStub(t)
+
+	var (
+		mu       sync.Mutex
+		received = map[string]map[string]any{}
+	)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env struct {
+			EventType      string         `json:"event_type"`
+			IdempotencyKey string         `json:"idempotency_key"`
+			Source         string         `json:"source"`
+			Data           map[string]any `json:"data"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&env)
+		if env.Source != "aex-contract-engine" || env.IdempotencyKey == "" {
+			t.Errorf("unexpected envelope: %+v", env)
+		}
+		mu.Lock()
+		received[env.EventType] = env.Data
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(sink.Close)
+
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{EventsURL: sink.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	contractURL := ts.URL + "/v1/contracts/" + a.ContractID
+	resp := postWithToken(t, contractURL+"/progress", a.ExecutionToken, map[string]any{"status": "working"})
+	_ = resp.Body.Close()
+	resp = postWithToken(t, contractURL+"/complete", a.ExecutionToken, map[string]any{"success": true})
+	_ = resp.Body.Close()
+
+	b := award(t, ts.URL, "work_2")
+	resp = postWithToken(t, ts.URL+"/v1/contracts/"+b.ContractID+"/fail", b.ConsumerToken, map[string]any{"reason": "quality_issue"})
+	_ = resp.Body.Close()
+
+	seen := func(typ string) map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return received[typ]
+	}
+	waitFor(t, func() bool {
+		for _, typ := range []string{"contract.awarded", "contract.started", "contract.completed", "contract.disputed"} {
+			if seen(typ) == nil {
+				return false
+			}
+		}
+		return true
+	})
+	if got := seen("contract.completed"); got["contract_id"] != a.ContractID || got["provider_id"] != "prov_a" {
+		t.Fatalf("unexpected contract.completed data: %v", got)
+	}
+	if got := seen("contract.disputed"); got["contract_id"] != b.ContractID || got["reason"] != "quality_issue" {
+		t.Fatalf("unexpected contract.disputed data: %v", got)
+	}
+	if seen("contract.failed") != nil {
+		t.Fatal("consumer-reported failure should be published as a dispute")
+	}
+}
+
+func TestAwardBatchCreatesAllContractsOrNone(t *testing.T) {
+	bg := newBidGatewayStubWithBids(t,
+		map[string]any{"bid_id": "bid_1", "provider_id": "prov_a", "price": 0.10},
+		map[string]any{"bid_id": "bid_2", "provider_id": "prov_b", "price": 0.08},
+		map[string]any{"bid_id": "bid_3", "provider_id": "prov_c", "price": 0.12},
+	)
+	svc, err := cesvc.New(cestore.NewMemoryContractStore(), bg.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	batch := func(workID string, payload any) (int, []map[string]any) {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(ts.URL+"/v1/work/"+workID+"/award-batch", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Contracts []map[string]any `json:"contracts"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.Contracts
+	}
+
+	// An unknown bid fails the whole batch.
+	if code, _ := batch("work_1", map[string]any{"bid_ids": []string{"bid_1", "bid_x"}}); code != http.StatusBadRequest {
+		t.Fatalf("batch with unknown bid expected 400, got %d", code)
+	}
+
+	code, contracts := batch("work_1", map[string]any{"bid_ids": []string{"bid_1", "bid_3"}})
+	if code != http.StatusOK || len(contracts) != 2 {
+		t.Fatalf("batch expected 200 with 2 contracts, got %d %v", code, contracts)
+	}
+	if contracts[0]["provider_id"] != "prov_a" || contracts[1]["provider_id"] != "prov_c" {
+		t.Fatalf("unexpected providers: %v", contracts)
+	}
+
+	// Repeating the batch is idempotent; a different batch conflicts.
+	code, again := batch("work_1", map[string]any{"bid_ids": []string{"bid_1", "bid_3"}})
+	if code != http.StatusOK || again[0]["contract_id"] != contracts[0]["contract_id"] {
+		t.Fatalf("repeat batch expected same contracts, got %d %v", code, again)
+	}
+	if code, _ := batch("work_1", map[string]any{"bid_ids": []string{"bid_2", "bid_3"}}); code != http.StatusConflict {
+		t.Fatalf("different batch expected 409, got %d", code)
+	}
+
+	// auto_award_top_n takes the cheapest bids when no evaluator is configured.
+	code, top := batch("work_2", map[string]any{"auto_award_top_n": 2})
+	if code != http.StatusOK || len(top) != 2 || top[0]["bid_id"] != "bid_2" || top[1]["bid_id"] != "bid_1" {
+		t.Fatalf("top-n batch expected bid_2 and bid_1, got %d %v", code, top)
+	}
+	if code, _ := batch("work_3", map[string]any{"auto_award_top_n": 4}); code != http.StatusBadRequest {
+		t.Fatalf("top-n beyond available bids expected 400, got %d", code)
+	}
+}
+
+func TestGetMyContractByExecutionToken(t *testing.T) {
+	bg := newBidGatewayStub(t)
+	svc, err := cesvc.New(cestore.NewMemoryContractStore(), bg.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+
+	getMe := func(token string) (int, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/contracts/me", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if code, _ := getMe(""); code != http.StatusUnauthorized {
+		t.Fatalf("missing token expected 401, got %d", code)
+	}
+	if code, _ := getMe("exec_unknown"); code != http.StatusNotFound {
+		t.Fatalf("unknown token expected 404, got %d", code)
+	}
+	// The consumer token is not an execution token.
+	if code, _ := getMe(a.ConsumerToken); code != http.StatusNotFound {
+		t.Fatalf("consumer token expected 404, got %d", code)
+	}
+
+	code, c := getMe(a.ExecutionToken)
+	if code != http.StatusOK || c["contract_id"] != a.ContractID {
+		t.Fatalf("expected own contract, got %d %v", code, c)
+	}
+	if c["consumer_token"] != "" {
+		t.Fatalf("consumer token must not be returned to the provider: %v", c["consumer_token"])
+	}
+}
+
+func TestDeadlineExtensionRequests(t *testing.T) {
+	bg := newBidGatewayStub(t)
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{
+		MaxExtensions: 2,
+		MaxExtension:  2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	a := award(t, ts.URL, "work_1")
+	contractURL := ts.URL + "/v1/contracts/" + a.ContractID
+
+	getContract := func() map[string]any {
+		t.Helper()
+		resp, err := http.Get(contractURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var c map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&c)
+		return c
+	}
+	expiresAt, _ := time.Parse(time.RFC3339Nano, getContract()["expires_at"].(string))
+
+	request := func(token string, d time.Duration) (int, string) {
+		t.Helper()
+		resp := postWithToken(t, contractURL+"/extension-request", token, map[string]any{
+			"new_deadline": expiresAt.Add(d),
+			"reason":       "upstream API is slow",
+		})
+		defer func() { _ = resp.Body.Close() }()
+		var ext struct {
+			ExtensionID string `json:"extension_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&ext)
+		return resp.StatusCode, ext.ExtensionID
+	}
+
+	if code, _ := request(a.ConsumerToken, time.Hour); code != http.StatusUnauthorized {
+		t.Fatalf("extension with consumer token expected 401, got %d", code)
+	}
+	if code, _ := request(a.ExecutionToken, 3*time.Hour); code != http.StatusBadRequest {
+		t.Fatalf("oversized extension expected 400, got %d", code)
+	}
+
+	code, first := request(a.ExecutionToken, time.Hour)
+	if code != http.StatusCreated {
+		t.Fatalf("extension request expected 201, got %d", code)
+	}
+	if code, _ := request(a.ExecutionToken, time.Hour); code != http.StatusConflict {
+		t.Fatalf("second pending extension expected 409, got %d", code)
+	}
+
+	// Only the consumer may decide.
+	resp := postWithToken(t, contractURL+"/extension-request/"+first+"/approve", a.ExecutionToken, map[string]any{})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("approve with execution token expected 401, got %d", resp.StatusCode)
+	}
+	resp = postWithToken(t, contractURL+"/extension-request/"+first+"/approve", a.ConsumerToken, map[string]any{"reason": "ok"})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("approve expected 200, got %d", resp.StatusCode)
+	}
+	c := getContract()
+	newExpiry, _ := time.Parse(time.RFC3339Nano, c["expires_at"].(string))
+	if !newExpiry.Equal(expiresAt.Add(time.Hour)) || c["revision"] != float64(2) {
+		t.Fatalf("approved extension not applied: expires_at=%v revision=%v", c["expires_at"], c["revision"])
+	}
+	expiresAt = newExpiry
+
+	code, second := request(a.ExecutionToken, 30*time.Minute)
+	if code != http.StatusCreated {
+		t.Fatalf("second extension request expected 201, got %d", code)
+	}
+	resp = postWithToken(t, contractURL+"/extension-request/"+second+"/deny", a.ConsumerToken, map[string]any{"reason": "no"})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("deny expected 200, got %d", resp.StatusCode)
+	}
+
+	// The per-contract limit counts every request, approved or denied.
+	if code, _ := request(a.ExecutionToken, 30*time.Minute); code != http.StatusConflict {
+		t.Fatalf("extension beyond limit expected 409, got %d", code)
+	}
+	if exts, _ := getContract()["extensions"].([]any); len(exts) != 2 {
+		t.Fatalf("expected 2 extension records, got %v", exts)
+	}
+}
+
+func TestTerminalContractsAreArchivedAfterRetention(t *testing.T) {
+	bg := newBidGatewayStub(t)
+	svc, err := cesvc.NewWithOptions(cestore.NewMemoryContractStore(), bg.URL, cesvc.Options{Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(cehttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	done := award(t, ts.URL, "work_1")
+	resp := postWithToken(t, ts.URL+"/v1/contracts/"+done.ContractID+"/complete", done.ExecutionToken, map[string]any{"success": true})
+	_ = resp.Body.Close()
+	active := award(t, ts.URL, "work_2")
+
+	get := func(id, query string) (int, map[string]any) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/contracts/" + id + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var c map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&c)
+		return resp.StatusCode, c
+	}
+
+	// Nothing is old enough yet.
+	if n := svc.ArchiveClosed(context.Background(), time.Now().UTC()); n != 0 {
+		t.Fatalf("expected nothing archived inside the retention window, got %d", n)
+	}
+	if n := svc.ArchiveClosed(context.Background(), time.Now().UTC().Add(2*time.Hour)); n != 1 {
+		t.Fatalf("expected 1 archived contract, got %d", n)
+	}
+
+	if code, _ := get(done.ContractID, ""); code != http.StatusNotFound {
+		t.Fatalf("archived contract without include_archived expected 404, got %d", code)
+	}
+	code, c := get(done.ContractID, "?include_archived=true")
+	if code != http.StatusOK || c["status"] != "COMPLETED" || c["archived_at"] == nil {
+		t.Fatalf("expected archived contract, got %d %v", code, c)
+	}
+	if code, _ := get(active.ContractID, ""); code != http.StatusOK {
+		t.Fatalf("active contract must stay in the hot store, got %d", code)
+	}
 }
@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"context"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/internal/httpclient"
+)
+
+type RankedBid struct {
+	Rank       int     `json:"rank"`
+	BidID      string  `json:"bid_id"`
+	ProviderID string  `json:"provider_id"`
+	TotalScore float64 `json:"total_score"`
+}
+
+type BidEvaluatorClient struct {
+	baseURL string
+	client  *httpclient.Client
+}
+
+func NewBidEvaluatorClient(baseURL string) *BidEvaluatorClient {
+	return &BidEvaluatorClient{
+		baseURL: baseURL,
+		client:  httpclient.NewClient("bid-evaluator", 10*time.Second),
+	}
+}
+
+// Evaluate asks the bid evaluator to rank the bids for a work item. The
+// result is ordered best first.
+func (c *BidEvaluatorClient) Evaluate(ctx context.Context, workID string, budget WorkBudget) ([]RankedBid, error) {
+	var response struct {
+		RankedBids []RankedBid `json:"ranked_bids"`
+	}
+	err := httpclient.NewRequest("POST", c.baseURL).
+		Path("/internal/v1/evaluate").
+		JSON(map[string]any{"work_id": workID, "budget": budget}).
+		Context(ctx).
+		ExecuteJSON(c.client, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.RankedBids, nil
+}
@@ -0,0 +1,50 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ProviderLimits are the per-provider limits set in the provider registry.
+// Zero means unlimited.
+type ProviderLimits struct {
+	ProviderID             string `json:"provider_id"`
+	MaxConcurrentContracts int    `json:"max_concurrent_contracts"`
+}
+
+type ProviderRegistryClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func NewProviderRegistryClient(baseURL string) *ProviderRegistryClient {
+	return &ProviderRegistryClient{
+		baseURL: baseURL,
+		http:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetLimits fetches the limits to enforce for a provider.
+func (c *ProviderRegistryClient) GetLimits(ctx context.Context, providerID string) (*ProviderLimits, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/internal/v1/providers/"+url.PathEscape(providerID)+"/limits", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("provider-registry returned %d", resp.StatusCode)
+	}
+	var out ProviderLimits
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
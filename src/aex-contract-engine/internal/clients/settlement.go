@@ -20,16 +20,28 @@ type ContractCompletedEvent struct {
 	Success     bool                   `json:"success"`
 	AgreedPrice string                 `json:"agreed_price"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+
+	// PayoutSplits divides the provider payout for multi-party contracts.
+	PayoutSplits []PayoutSplit `json:"payout_splits,omitempty"`
+}
+
+type PayoutSplit struct {
+	ProviderID   string  `json:"provider_id"`
+	SharePercent float64 `json:"share_percent"`
 }
 
 type SettlementClient struct {
 	baseURL string
+	auth    httpclient.AuthProvider
 	client  *httpclient.Client
 }
 
-func NewSettlementClient(baseURL string) *SettlementClient {
+// NewSettlementClient settles contracts at baseURL, authenticated with auth
+// when it is not nil.
+func NewSettlementClient(baseURL string, auth httpclient.AuthProvider) *SettlementClient {
 	return &SettlementClient{
 		baseURL: baseURL,
+		auth:    auth,
 		client:  httpclient.NewClient("settlement", 10*time.Second),
 	}
 }
@@ -42,6 +54,7 @@ func (c *SettlementClient) ProcessContractCompletion(ctx context.Context, event
 
 	err := httpclient.NewRequest("POST", c.baseURL).
 		Path("/internal/settlement/complete").
+		Auth(c.auth).
 		JSON(event).
 		Context(ctx).
 		ExecuteJSON(c.client, &response)
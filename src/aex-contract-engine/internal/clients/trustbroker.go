@@ -0,0 +1,51 @@
+package clients
+
+import (
+	"context"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/internal/httpclient"
+)
+
+// ContractOutcome is the payload accepted by the trust broker's outcome
+// ingestion endpoint.
+type ContractOutcome struct {
+	ContractID  string         `json:"contract_id"`
+	ProviderID  string         `json:"provider_id"`
+	ConsumerID  string         `json:"consumer_id"`
+	Outcome     string         `json:"outcome"`
+	Metrics     map[string]any `json:"metrics,omitempty"`
+	AgreedPrice float64        `json:"agreed_price"`
+	FinalPrice  float64        `json:"final_price"`
+	CompletedAt time.Time      `json:"completed_at"`
+}
+
+type TrustBrokerClient struct {
+	baseURL string
+	auth    httpclient.AuthProvider
+	client  *httpclient.Client
+}
+
+// NewTrustBrokerClient reports outcomes to baseURL, authenticated with
+// auth when it is not nil.
+func NewTrustBrokerClient(baseURL string, auth httpclient.AuthProvider) *TrustBrokerClient {
+	return &TrustBrokerClient{
+		baseURL: baseURL,
+		auth:    auth,
+		client:  httpclient.NewClient("trust-broker", 10*time.Second),
+	}
+}
+
+// RecordOutcome reports a contract outcome so the provider's trust score is
+// recalculated.
+func (c *TrustBrokerClient) RecordOutcome(ctx context.Context, outcome ContractOutcome) error {
+	var response struct {
+		Recorded bool `json:"recorded"`
+	}
+	return httpclient.NewRequest("POST", c.baseURL).
+		Path("/internal/v1/outcomes").
+		Auth(c.auth).
+		JSON(outcome).
+		Context(ctx).
+		ExecuteJSON(c.client, &response)
+}
@@ -0,0 +1,56 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Work is the subset of the work publisher's work spec the contract engine
+// needs when creating a contract.
+type Work struct {
+	WorkID      string     `json:"work_id"`
+	ConsumerID  string     `json:"consumer_id"`
+	Budget      WorkBudget `json:"budget"`
+	CallbackURL string     `json:"callback_url,omitempty"`
+}
+
+type WorkBudget struct {
+	MaxPrice    float64 `json:"max_price"`
+	BidStrategy string  `json:"bid_strategy,omitempty"`
+}
+
+type WorkPublisherClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func NewWorkPublisherClient(baseURL string) *WorkPublisherClient {
+	return &WorkPublisherClient{
+		baseURL: baseURL,
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *WorkPublisherClient) GetWork(ctx context.Context, workID string) (*Work, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v1/work/"+url.PathEscape(workID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("work-publisher returned %d", resp.StatusCode)
+	}
+	var out Work
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,10 +13,71 @@ type Config struct {
 	// Bid Gateway (used to fetch bid details when awarding)
 	BidGatewayURL string
 
+	// Settlement (optional; used to pay cancellation fees to providers)
+	SettlementURL string
+
+	// Percentage of the agreed price owed to the provider when the consumer
+	// cancels a contract that is already executing.
+	CancellationFeePercent float64
+
+	// Work Publisher (optional; supplies consumer_id and callback_url)
+	WorkPublisherURL   string
+	WebhookSecret      string
+	WebhookMaxAttempts int
+	WebhookBackoff     time.Duration
+	ProgressThresholds []int
+
+	// Provider no-show handling: a contract still AWARDED after StartDeadline
+	// is failed and, optionally, re-awarded to the next-best bid. Zero
+	// StartDeadline disables it.
+	StartDeadline       time.Duration
+	NoShowCheckInterval time.Duration
+	ReawardOnNoShow     bool
+	TrustBrokerURL      string
+	TrustBrokerToken    string
+
+	// Service account (optional): with all three set, calls to settlement
+	// and the trust broker carry tokens the identity service issues for it.
+	IdentityURL          string
+	ServiceAccountID     string
+	ServiceAccountSecret string
+
+	// Result artifacts: stored under ArtifactDir when set, otherwise in memory.
+	ArtifactDir      string
+	MaxArtifactBytes int64
+
+	// Deadline extension limits per contract
+	MaxExtensions int
+	MaxExtension  time.Duration
+
+	// Terminal contracts closed longer than Retention ago are archived every
+	// ArchiveInterval. Zero retention disables archival.
+	Retention       time.Duration
+	ArchiveInterval time.Duration
+
+	// Event bus endpoint for contract lifecycle events (optional)
+	EventsURL string
+	// EventsSecret signs events so subscribers can verify them (optional)
+	EventsSecret string
+
+	// AdminToken lets operators manage any consumer's award policy (optional)
+	AdminToken string
+	// GatewayToken is the gateway's UPSTREAM_TOKEN, proving the X-Tenant-ID
+	// of proxied requests (optional)
+	GatewayToken string
+
+	// Bid Evaluator (optional; enables score-based auto-award policies)
+	BidEvaluatorURL string
+
+	// Provider Registry (optional; enforces per-provider contract limits)
+	ProviderRegistryURL string
+
 	// MongoDB (optional persistence)
-	MongoURI        string
-	MongoDatabase   string
-	MongoCollection string
+	MongoURI                     string
+	MongoDatabase                string
+	MongoCollection              string
+	MongoCollectionArchive       string
+	MongoCollectionAwardPolicies string
 
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
@@ -24,14 +86,43 @@ type Config struct {
 
 func Load() Config {
 	return Config{
-		Port:            getenv("PORT", "8080"),
-		BidGatewayURL:   strings.TrimRight(strings.TrimSpace(os.Getenv("BID_GATEWAY_URL")), "/"),
-		MongoURI:        strings.TrimSpace(os.Getenv("MONGO_URI")),
-		MongoDatabase:   getenv("MONGO_DB", "aex"),
-		MongoCollection: getenv("MONGO_COLLECTION_CONTRACTS", "contracts"),
-		ReadTimeout:     10 * time.Second,
-		WriteTimeout:    20 * time.Second,
-		IdleTimeout:     60 * time.Second,
+		Port:                         getenv("PORT", "8080"),
+		BidGatewayURL:                strings.TrimRight(strings.TrimSpace(os.Getenv("BID_GATEWAY_URL")), "/"),
+		SettlementURL:                strings.TrimRight(strings.TrimSpace(os.Getenv("SETTLEMENT_URL")), "/"),
+		CancellationFeePercent:       getenvFloat("CANCELLATION_FEE_PERCENT", 10),
+		BidEvaluatorURL:              strings.TrimRight(strings.TrimSpace(os.Getenv("BID_EVALUATOR_URL")), "/"),
+		ProviderRegistryURL:          strings.TrimRight(strings.TrimSpace(os.Getenv("PROVIDER_REGISTRY_URL")), "/"),
+		EventsURL:                    strings.TrimSpace(os.Getenv("EVENTS_URL")),
+		EventsSecret:                 strings.TrimSpace(os.Getenv("EVENTS_SECRET")),
+		AdminToken:                   strings.TrimSpace(os.Getenv("ADMIN_TOKEN")),
+		GatewayToken:                 strings.TrimSpace(os.Getenv("GATEWAY_TOKEN")),
+		MaxExtensions:                getenvInt("MAX_EXTENSIONS", 3),
+		Retention:                    getenvDuration("CONTRACT_RETENTION", 0),
+		ArchiveInterval:              getenvDuration("ARCHIVE_INTERVAL", time.Hour),
+		MaxExtension:                 getenvDuration("MAX_EXTENSION_DURATION", 24*time.Hour),
+		WorkPublisherURL:             strings.TrimRight(strings.TrimSpace(os.Getenv("WORK_PUBLISHER_URL")), "/"),
+		WebhookSecret:                strings.TrimSpace(os.Getenv("WEBHOOK_SECRET")),
+		WebhookMaxAttempts:           getenvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookBackoff:               getenvDuration("WEBHOOK_BACKOFF", time.Second),
+		ProgressThresholds:           getenvInts("WEBHOOK_PROGRESS_THRESHOLDS", []int{25, 50, 75}),
+		StartDeadline:                getenvDuration("START_DEADLINE", 0),
+		NoShowCheckInterval:          getenvDuration("NO_SHOW_CHECK_INTERVAL", 30*time.Second),
+		ReawardOnNoShow:              getenvBool("REAWARD_ON_NO_SHOW", false),
+		TrustBrokerURL:               strings.TrimRight(strings.TrimSpace(os.Getenv("TRUST_BROKER_URL")), "/"),
+		TrustBrokerToken:             strings.TrimSpace(os.Getenv("TRUST_BROKER_TOKEN")),
+		IdentityURL:                  strings.TrimRight(strings.TrimSpace(os.Getenv("IDENTITY_URL")), "/"),
+		ServiceAccountID:             strings.TrimSpace(os.Getenv("SERVICE_ACCOUNT_ID")),
+		ServiceAccountSecret:         strings.TrimSpace(os.Getenv("SERVICE_ACCOUNT_SECRET")),
+		ArtifactDir:                  strings.TrimSpace(os.Getenv("ARTIFACT_DIR")),
+		MaxArtifactBytes:             int64(getenvInt("ARTIFACT_MAX_BYTES", 10<<20)),
+		MongoURI:                     strings.TrimSpace(os.Getenv("MONGO_URI")),
+		MongoDatabase:                getenv("MONGO_DB", "aex"),
+		MongoCollection:              getenv("MONGO_COLLECTION_CONTRACTS", "contracts"),
+		MongoCollectionArchive:       getenv("MONGO_COLLECTION_CONTRACTS_ARCHIVE", "contracts_archive"),
+		MongoCollectionAwardPolicies: getenv("MONGO_COLLECTION_AWARD_POLICIES", "award_policies"),
+		ReadTimeout:                  10 * time.Second,
+		WriteTimeout:                 20 * time.Second,
+		IdleTimeout:                  60 * time.Second,
 	}
 }
 
@@ -41,3 +132,68 @@ func getenv(k, def string) string {
 	}
 	return def
 }
+
+func getenvFloat(k string, def float64) float64 {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+func getenvInt(k string, def int) int {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// getenvInts parses a comma-separated list of integers.
+func getenvInts(k string, def []int) []int {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	var out []int
+	for _, part := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return def
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func getenvDuration(k string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+func getenvBool(k string, def bool) bool {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
@@ -10,13 +10,26 @@ func NewRouter(svc *service.Service) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("POST /v1/work/", func(w http.ResponseWriter, r *http.Request) {
-		if hasSuffix(r.URL.Path, "/award") {
+		switch {
+		case hasSuffix(r.URL.Path, "/award"):
 			svc.HandleAward(w, r)
-			return
+		case hasSuffix(r.URL.Path, "/award-batch"):
+			svc.HandleAwardBatch(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("GET /v1/contracts/me", svc.HandleGetMyContract)
+	mux.HandleFunc("GET /v1/contracts/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case hasSuffix(r.URL.Path, "/timeline"):
+			svc.HandleTimeline(w, r)
+		case hasSuffix(r.URL.Path, "/webhooks"):
+			svc.HandleWebhookDeliveries(w, r)
+		default:
+			svc.HandleGetContract(w, r)
 		}
-		http.NotFound(w, r)
 	})
-	mux.HandleFunc("GET /v1/contracts/", svc.HandleGetContract)
 	mux.HandleFunc("POST /v1/contracts/", func(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case hasSuffix(r.URL.Path, "/progress"):
@@ -25,11 +38,30 @@ func NewRouter(svc *service.Service) http.Handler {
 			svc.HandleComplete(w, r)
 		case hasSuffix(r.URL.Path, "/fail"):
 			svc.HandleFail(w, r)
+		case hasSuffix(r.URL.Path, "/cancel"):
+			svc.HandleCancel(w, r)
+		case hasSuffix(r.URL.Path, "/amendments"):
+			svc.HandleProposeAmendment(w, r)
+		case hasSuffix(r.URL.Path, "/accept"):
+			svc.HandleAcceptAmendment(w, r)
+		case hasSuffix(r.URL.Path, "/parties"):
+			svc.HandleAddParty(w, r)
 		default:
 			http.NotFound(w, r)
 		}
 	})
 
+	mux.HandleFunc("POST /v1/contracts/{contract_id}/artifacts", svc.HandleUploadArtifact)
+	mux.HandleFunc("GET /v1/contracts/{contract_id}/artifacts", svc.HandleListArtifacts)
+	mux.HandleFunc("GET /v1/contracts/{contract_id}/artifacts/{artifact_id}", svc.HandleDownloadArtifact)
+
+	mux.HandleFunc("POST /v1/contracts/{contract_id}/extension-request", svc.HandleRequestExtension)
+	mux.HandleFunc("POST /v1/contracts/{contract_id}/extension-request/{extension_id}/approve", svc.HandleApproveExtension)
+	mux.HandleFunc("POST /v1/contracts/{contract_id}/extension-request/{extension_id}/deny", svc.HandleDenyExtension)
+
+	mux.HandleFunc("GET /v1/consumers/{consumer_id}/award-policy", svc.HandleGetAwardPolicy)
+	mux.HandleFunc("PUT /v1/consumers/{consumer_id}/award-policy", svc.HandlePutAwardPolicy)
+
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
 	return mux
 }
@@ -11,14 +11,43 @@ const (
 	ContractStatusFailed    ContractStatus = "FAILED"
 	ContractStatusExpired   ContractStatus = "EXPIRED"
 	ContractStatusDisputed  ContractStatus = "DISPUTED"
+	ContractStatusCancelled ContractStatus = "CANCELLED"
 )
 
+// IsTerminal reports whether no further execution can happen on a contract
+// in this status.
+func (s ContractStatus) IsTerminal() bool {
+	switch s {
+	case ContractStatusCompleted, ContractStatusFailed, ContractStatusExpired, ContractStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// TrustOutcome mirrors the outcome types understood by the trust broker.
+type TrustOutcome string
+
+const (
+	TrustOutcomeFailureProvider TrustOutcome = "FAILURE_PROVIDER"
+	TrustOutcomeFailureConsumer TrustOutcome = "FAILURE_CONSUMER"
+)
+
+// FailureReasonNoShow marks contracts failed because the provider never
+// started work before its start deadline.
+const FailureReasonNoShow = "provider_no_show"
+
+// FailureReasonUnsuccessful marks contracts the provider completed with
+// success=false.
+const FailureReasonUnsuccessful = "completed_unsuccessfully"
+
 type SLACommitment struct {
 	MaxLatencyMs int64   `json:"max_latency_ms"`
 	Availability float64 `json:"availability"`
 }
 
 type ExecutionUpdate struct {
+	PartyID   string    `json:"party_id,omitempty"`
 	Status    string    `json:"status"`
 	Percent   *int      `json:"percent,omitempty"`
 	Message   *string   `json:"message,omitempty"`
@@ -33,6 +62,115 @@ type OutcomeReport struct {
 	ReportedAt     time.Time      `json:"reported_at"`
 }
 
+type AmendmentStatus string
+
+const (
+	AmendmentStatusProposed AmendmentStatus = "PROPOSED"
+	AmendmentStatusAccepted AmendmentStatus = "ACCEPTED"
+)
+
+// Amendment is a proposed change to the commercial terms of a contract. It
+// only takes effect once the counterparty accepts it.
+type Amendment struct {
+	AmendmentID string          `json:"amendment_id" bson:"amendment_id"`
+	ProposedBy  string          `json:"proposed_by" bson:"proposed_by"` // "consumer" or "provider"
+	AgreedPrice *float64        `json:"agreed_price,omitempty" bson:"agreed_price,omitempty"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	Reason      string          `json:"reason,omitempty" bson:"reason,omitempty"`
+	Status      AmendmentStatus `json:"status" bson:"status"`
+	ProposedAt  time.Time       `json:"proposed_at" bson:"proposed_at"`
+	AcceptedAt  *time.Time      `json:"accepted_at,omitempty" bson:"accepted_at,omitempty"`
+	Revision    int             `json:"revision,omitempty" bson:"revision,omitempty"`
+}
+
+type ExtensionStatus string
+
+const (
+	ExtensionStatusPending  ExtensionStatus = "PENDING"
+	ExtensionStatusApproved ExtensionStatus = "APPROVED"
+	ExtensionStatusDenied   ExtensionStatus = "DENIED"
+)
+
+// Extension is a provider's request to move the contract deadline. Every
+// request is kept, whatever the consumer decided, as the audit trail.
+type Extension struct {
+	ExtensionID      string          `json:"extension_id" bson:"extension_id"`
+	PreviousDeadline time.Time       `json:"previous_deadline" bson:"previous_deadline"`
+	NewDeadline      time.Time       `json:"new_deadline" bson:"new_deadline"`
+	Reason           string          `json:"reason" bson:"reason"`
+	Status           ExtensionStatus `json:"status" bson:"status"`
+	RequestedAt      time.Time       `json:"requested_at" bson:"requested_at"`
+	DecidedAt        *time.Time      `json:"decided_at,omitempty" bson:"decided_at,omitempty"`
+	DecisionReason   string          `json:"decision_reason,omitempty" bson:"decision_reason,omitempty"`
+	Revision         int             `json:"revision,omitempty" bson:"revision,omitempty"`
+}
+
+// ContractRevision is an immutable snapshot of the terms in force from
+// EffectiveAt onwards. Revision 1 is always the terms agreed at award time.
+type ContractRevision struct {
+	Revision    int       `json:"revision" bson:"revision"`
+	AgreedPrice float64   `json:"agreed_price" bson:"agreed_price"`
+	ExpiresAt   time.Time `json:"expires_at" bson:"expires_at"`
+	AmendmentID string    `json:"amendment_id,omitempty" bson:"amendment_id,omitempty"`
+	ExtensionID string    `json:"extension_id,omitempty" bson:"extension_id,omitempty"`
+	EffectiveAt time.Time `json:"effective_at" bson:"effective_at"`
+}
+
+type SettlementKind string
+
+const (
+	SettlementKindCompletion      SettlementKind = "completion"
+	SettlementKindCancellationFee SettlementKind = "cancellation_fee"
+)
+
+type SettlementStatus string
+
+const (
+	SettlementStatusSettled SettlementStatus = "settled"
+	SettlementStatusFailed  SettlementStatus = "failed"
+)
+
+// SettlementRecord references a charge forwarded to the settlement service.
+type SettlementRecord struct {
+	Kind        SettlementKind   `json:"kind" bson:"kind"`
+	Amount      float64          `json:"amount" bson:"amount"`
+	Status      SettlementStatus `json:"status" bson:"status"`
+	Error       *string          `json:"error,omitempty" bson:"error,omitempty"`
+	RequestedAt time.Time        `json:"requested_at" bson:"requested_at"`
+}
+
+type PartyStatus string
+
+const (
+	PartyStatusActive    PartyStatus = "ACTIVE"
+	PartyStatusCompleted PartyStatus = "COMPLETED"
+)
+
+// ContractParty is a subcontracted provider working on one step of a
+// composite contract. It has its own execution token and a share of the
+// payout; the primary provider keeps whatever share is not subcontracted.
+type ContractParty struct {
+	PartyID          string  `json:"party_id" bson:"party_id"`
+	ProviderID       string  `json:"provider_id" bson:"provider_id"`
+	Step             string  `json:"step" bson:"step"`
+	SharePercent     float64 `json:"share_percent" bson:"share_percent"`
+	ProviderEndpoint string  `json:"provider_endpoint,omitempty" bson:"provider_endpoint,omitempty"`
+	ExecutionToken   string  `json:"execution_token" bson:"execution_token"`
+	// ExecutionTokenHash is the hex SHA-256 of ExecutionToken, used to find
+	// the contract from the token alone.
+	ExecutionTokenHash string      `json:"-" bson:"execution_token_hash"`
+	Status             PartyStatus `json:"status" bson:"status"`
+	AddedAt            time.Time   `json:"added_at" bson:"added_at"`
+	CompletedAt        *time.Time  `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
+
+type Cancellation struct {
+	Reason      string    `json:"reason" bson:"reason"`
+	Fee         float64   `json:"fee" bson:"fee"`
+	FeeSettled  bool      `json:"fee_settled" bson:"fee_settled"`
+	CancelledAt time.Time `json:"cancelled_at" bson:"cancelled_at"`
+}
+
 type Contract struct {
 	ContractID string `json:"contract_id" bson:"contract_id"`
 	WorkID     string `json:"work_id" bson:"work_id"`
@@ -40,39 +178,133 @@ type Contract struct {
 	ProviderID string `json:"provider_id" bson:"provider_id"`
 	BidID      string `json:"bid_id" bson:"bid_id"`
 
+	// AwardSlot identifies the award position this contract occupies on its
+	// work while it is active; it is cleared once the contract terminates.
+	AwardSlot string `json:"award_slot,omitempty" bson:"award_slot,omitempty"`
+
 	AgreedPrice      float64       `json:"agreed_price" bson:"agreed_price"`
 	SLA              SLACommitment `json:"sla" bson:"sla"`
 	ProviderEndpoint string        `json:"provider_endpoint" bson:"provider_endpoint"`
+	CallbackURL      string        `json:"callback_url,omitempty" bson:"callback_url,omitempty"`
 
 	ExecutionToken string `json:"execution_token" bson:"execution_token"`
 	ConsumerToken  string `json:"consumer_token" bson:"consumer_token"`
+	// ExecutionTokenHash is the hex SHA-256 of ExecutionToken, used to find
+	// the contract from the token alone.
+	ExecutionTokenHash string `json:"-" bson:"execution_token_hash"`
 
 	Status    ContractStatus `json:"status" bson:"status"`
 	ExpiresAt time.Time      `json:"expires_at" bson:"expires_at"`
 
-	AwardedAt   time.Time  `json:"awarded_at" bson:"awarded_at"`
-	StartedAt   *time.Time `json:"started_at,omitempty" bson:"started_at,omitempty"`
-	CompletedAt *time.Time `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
-	FailedAt    *time.Time `json:"failed_at,omitempty" bson:"failed_at,omitempty"`
+	Parties []ContractParty `json:"parties,omitempty" bson:"parties,omitempty"`
+
+	Revision   int                `json:"revision" bson:"revision"`
+	Revisions  []ContractRevision `json:"revisions,omitempty" bson:"revisions,omitempty"`
+	Amendments []Amendment        `json:"amendments,omitempty" bson:"amendments,omitempty"`
+	Extensions []Extension        `json:"extensions,omitempty" bson:"extensions,omitempty"`
+
+	AwardedAt time.Time `json:"awarded_at" bson:"awarded_at"`
+	// StartDeadline is when the provider must have sent its first progress
+	// update; nil when no-show detection is disabled.
+	StartDeadline *time.Time `json:"start_deadline,omitempty" bson:"start_deadline,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty" bson:"failed_at,omitempty"`
+
+	ExecutionUpdates []ExecutionUpdate  `json:"execution_updates,omitempty" bson:"execution_updates,omitempty"`
+	Outcome          *OutcomeReport     `json:"outcome,omitempty" bson:"outcome,omitempty"`
+	FailureReason    *string            `json:"failure_reason,omitempty" bson:"failure_reason,omitempty"`
+	Cancellation     *Cancellation      `json:"cancellation,omitempty" bson:"cancellation,omitempty"`
+	TrustOutcome     TrustOutcome       `json:"trust_outcome,omitempty" bson:"trust_outcome,omitempty"`
+	Settlements      []SettlementRecord `json:"settlements,omitempty" bson:"settlements,omitempty"`
+	// ReplacesContractID and ReawardedTo link contracts re-awarded after a
+	// provider no-show.
+	ReplacesContractID string `json:"replaces_contract_id,omitempty" bson:"replaces_contract_id,omitempty"`
+	ReawardedTo        string `json:"reawarded_to,omitempty" bson:"reawarded_to,omitempty"`
 
-	ExecutionUpdates []ExecutionUpdate `json:"execution_updates,omitempty" bson:"execution_updates,omitempty"`
-	Outcome          *OutcomeReport    `json:"outcome,omitempty" bson:"outcome,omitempty"`
-	FailureReason    *string           `json:"failure_reason,omitempty" bson:"failure_reason,omitempty"`
+	// ArchivedAt is set once a terminal contract has been moved out of the
+	// hot collection by the retention job.
+	ArchivedAt *time.Time `json:"archived_at,omitempty" bson:"archived_at,omitempty"`
+
+	NotifiedThresholds []int `json:"-" bson:"notified_thresholds,omitempty"`
+}
+
+// ClosedAt returns when a terminal contract reached its terminal status, or
+// nil while it is still active.
+func (c Contract) ClosedAt() *time.Time {
+	switch c.Status {
+	case ContractStatusCompleted:
+		return c.CompletedAt
+	case ContractStatusFailed:
+		return c.FailedAt
+	case ContractStatusCancelled:
+		if c.Cancellation != nil {
+			return &c.Cancellation.CancelledAt
+		}
+	case ContractStatusExpired:
+		return &c.ExpiresAt
+	}
+	return nil
 }
 
 type AwardRequest struct {
 	BidID     string `json:"bid_id"`
 	AutoAward bool   `json:"auto_award"`
+	// Policy overrides the consumer's stored auto-award policy for this work.
+	Policy *AutoAwardPolicy `json:"policy,omitempty"`
+}
+
+// AutoAwardPolicy gates automatic awards. When the best-ranked bid does not
+// satisfy every configured condition the award is deferred for a manual
+// decision. A zero policy always awards the best-ranked bid.
+type AutoAwardPolicy struct {
+	// MinScore is the minimum bid-evaluator total score (0..1).
+	MinScore *float64 `json:"min_score,omitempty" bson:"min_score,omitempty"`
+	// MaxBudgetPercent caps the price as a percentage of the work budget.
+	MaxBudgetPercent *float64 `json:"max_budget_percent,omitempty" bson:"max_budget_percent,omitempty"`
+}
+
+type ConsumerAwardPolicy struct {
+	ConsumerID string          `json:"consumer_id" bson:"consumer_id"`
+	Policy     AutoAwardPolicy `json:"policy" bson:"policy"`
+	UpdatedAt  time.Time       `json:"updated_at" bson:"updated_at"`
+}
+
+// AwardDeferredResponse is returned when auto-award declines to pick a bid.
+type AwardDeferredResponse struct {
+	WorkID     string          `json:"work_id"`
+	Awarded    bool            `json:"awarded"`
+	Reason     string          `json:"reason"`
+	Policy     AutoAwardPolicy `json:"policy"`
+	TopBidID   string          `json:"top_bid_id,omitempty"`
+	TopScore   *float64        `json:"top_score,omitempty"`
+	TopPrice   float64         `json:"top_price,omitempty"`
+	BudgetUsed *float64        `json:"budget_percent,omitempty"`
+}
+
+// AwardBatchRequest awards several bids on multi-winner work at once: either
+// the listed BidIDs or the AutoAwardTopN best-ranked bids.
+type AwardBatchRequest struct {
+	BidIDs        []string         `json:"bid_ids,omitempty"`
+	AutoAwardTopN int              `json:"auto_award_top_n,omitempty"`
+	Policy        *AutoAwardPolicy `json:"policy,omitempty"`
+}
+
+type AwardBatchResponse struct {
+	WorkID    string          `json:"work_id"`
+	Contracts []AwardResponse `json:"contracts"`
 }
 
 type AwardResponse struct {
 	ContractID       string         `json:"contract_id"`
 	WorkID           string         `json:"work_id"`
+	BidID            string         `json:"bid_id"`
 	ProviderID       string         `json:"provider_id"`
 	AgreedPrice      float64        `json:"agreed_price"`
 	Status           ContractStatus `json:"status"`
 	ProviderEndpoint string         `json:"provider_endpoint"`
 	ExecutionToken   string         `json:"execution_token"`
+	ConsumerToken    string         `json:"consumer_token"`
 	ExpiresAt        time.Time      `json:"expires_at"`
 	AwardedAt        time.Time      `json:"awarded_at"`
 }
@@ -95,3 +327,72 @@ type FailRequest struct {
 	Message    string `json:"message"`
 	ReportedBy string `json:"reported_by"` // "provider" or "consumer"
 }
+
+type CancelRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ExtensionRequest struct {
+	NewDeadline time.Time `json:"new_deadline"`
+	Reason      string    `json:"reason"`
+}
+
+type ExtensionDecisionRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+type AmendmentRequest struct {
+	AgreedPrice *float64   `json:"agreed_price,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Reason      string     `json:"reason"`
+}
+
+type TimelineEventType string
+
+const (
+	TimelineAwarded            TimelineEventType = "awarded"
+	TimelineProgress           TimelineEventType = "progress"
+	TimelineAmendmentProposed  TimelineEventType = "amendment_proposed"
+	TimelineAmendmentAccepted  TimelineEventType = "amendment_accepted"
+	TimelineExtensionRequested TimelineEventType = "extension_requested"
+	TimelineExtensionApproved  TimelineEventType = "extension_approved"
+	TimelineExtensionDenied    TimelineEventType = "extension_denied"
+	TimelineCompleted          TimelineEventType = "completed"
+	TimelineFailed             TimelineEventType = "failed"
+	TimelineCancelled          TimelineEventType = "cancelled"
+	TimelineSettlement         TimelineEventType = "settlement"
+)
+
+type TimelineEvent struct {
+	Type      TimelineEventType `json:"type"`
+	Timestamp time.Time         `json:"timestamp"`
+	Status    string            `json:"status,omitempty"`
+	Percent   *int              `json:"percent,omitempty"`
+	Message   *string           `json:"message,omitempty"`
+	Details   map[string]any    `json:"details,omitempty"`
+}
+
+type TimelineResponse struct {
+	ContractID string          `json:"contract_id"`
+	Status     ContractStatus  `json:"status"`
+	Events     []TimelineEvent `json:"events"`
+}
+
+// Artifact describes a result file uploaded by the provider. The content is
+// held by the artifact store; SHA256 is the hex digest of the content.
+type Artifact struct {
+	ArtifactID  string    `json:"artifact_id" bson:"artifact_id"`
+	ContractID  string    `json:"contract_id" bson:"contract_id"`
+	Name        string    `json:"name" bson:"name"`
+	ContentType string    `json:"content_type" bson:"content_type"`
+	SizeBytes   int64     `json:"size_bytes" bson:"size_bytes"`
+	SHA256      string    `json:"sha256" bson:"sha256"`
+	UploadedAt  time.Time `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+type AddPartyRequest struct {
+	ProviderID       string  `json:"provider_id"`
+	Step             string  `json:"step"`
+	SharePercent     float64 `json:"share_percent"`
+	ProviderEndpoint string  `json:"provider_endpoint,omitempty"`
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunArchiver periodically moves terminal contracts older than the retention
+// window out of the hot store. It returns when ctx is cancelled.
+func (s *Service) RunArchiver(ctx context.Context, interval time.Duration) {
+	if s.opts.Retention <= 0 || interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.ArchiveClosed(ctx, time.Now().UTC())
+		}
+	}
+}
+
+// ArchiveClosed archives every contract closed more than the retention
+// window before now and returns how many were moved.
+func (s *Service) ArchiveClosed(ctx context.Context, now time.Time) int {
+	if s.opts.Retention <= 0 {
+		return 0
+	}
+	total := 0
+	for {
+		n, err := s.store.Archive(ctx, now.Add(-s.opts.Retention))
+		if err != nil {
+			log.Printf("contract archival failed: %v", err)
+			break
+		}
+		total += n
+		if n == 0 {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("archived contracts count=%d retention=%s", total, s.opts.Retention)
+	}
+	return total
+}
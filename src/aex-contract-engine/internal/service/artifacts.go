@@ -0,0 +1,184 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
+)
+
+// ChecksumHeader lets uploaders send the expected hex SHA-256 of the body;
+// the upload is rejected if it does not match.
+const ChecksumHeader = "X-Checksum-SHA256"
+
+const defaultMaxArtifactBytes = 10 << 20
+
+// HandleUploadArtifact stores a result artifact. Only the provider, holding
+// the execution token, may upload, and only while the contract is awarded,
+// executing or completed.
+func (s *Service) HandleUploadArtifact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	contractID := r.PathValue("contract_id")
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	c, err := s.store.Get(ctx, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if c.ExecutionToken != token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch c.Status {
+	case model.ContractStatusAwarded, model.ContractStatusExecuting, model.ContractStatusCompleted:
+	default:
+		http.Error(w, "contract does not accept artifacts", http.StatusConflict)
+		return
+	}
+
+	limit := s.maxArtifactBytes()
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			http.Error(w, "artifact exceeds size limit of "+strconv.FormatInt(limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "empty artifact", http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if want := strings.ToLower(strings.TrimSpace(r.Header.Get(ChecksumHeader))); want != "" && want != digest {
+		http.Error(w, "checksum mismatch", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		name = "result"
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	a := model.Artifact{
+		ArtifactID:  generateID("art_"),
+		ContractID:  c.ContractID,
+		Name:        name,
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+		SHA256:      digest,
+		UploadedAt:  time.Now().UTC(),
+	}
+	if err := s.artifacts.PutArtifact(ctx, a, data); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, a)
+}
+
+// HandleListArtifacts lists artifact metadata to either contract party.
+func (s *Service) HandleListArtifacts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	c, ok := s.authorizeParty(w, r, r.PathValue("contract_id"))
+	if !ok {
+		return
+	}
+	list, err := s.artifacts.ListArtifacts(ctx, c.ContractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"contract_id": c.ContractID, "artifacts": list})
+}
+
+// HandleDownloadArtifact returns artifact content to the consumer. The
+// digest is exposed in the checksum header and as a strong ETag.
+func (s *Service) HandleDownloadArtifact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	contractID := r.PathValue("contract_id")
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	c, err := s.store.Get(ctx, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if c.ConsumerToken != token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	a, data, err := s.artifacts.GetArtifact(ctx, contractID, r.PathValue("artifact_id"))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if a == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(a.Name, `"`, "")+`"`)
+	w.Header().Set(ChecksumHeader, a.SHA256)
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// authorizeParty loads a contract and checks the bearer token belongs to
+// either the consumer or the provider, writing the error response if not.
+func (s *Service) authorizeParty(w http.ResponseWriter, r *http.Request, contractID string) (*model.Contract, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	c, err := s.store.Get(r.Context(), contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	if partyForToken(c, token) == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return c, true
+}
+
+func (s *Service) maxArtifactBytes() int64 {
+	if s.opts.MaxArtifactBytes > 0 {
+		return s.opts.MaxArtifactBytes
+	}
+	return defaultMaxArtifactBytes
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/clients"
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
+)
+
+// lookupWork fetches the work spec from the work publisher, if configured.
+// Failures are logged and treated as "unknown work" so awarding still works
+// in minimal deployments.
+func (s *Service) lookupWork(ctx context.Context, workID string) *clients.Work {
+	if s.work == nil {
+		return nil
+	}
+	work, err := s.work.GetWork(ctx, workID)
+	if err != nil {
+		log.Printf("work lookup failed work_id=%s: %v", workID, err)
+		return nil
+	}
+	return work
+}
+
+// selectAutoAward picks the best unexpired bid and checks it against the
+// effective auto-award policy. It returns either the chosen bid, a deferral
+// explaining why a manual award is needed, or neither when there is nothing
+// to award.
+func (s *Service) selectAutoAward(ctx context.Context, work *clients.Work, bids []clients.Bid, override *model.AutoAwardPolicy, now time.Time) (*clients.Bid, *model.AwardDeferredResponse, error) {
+	policy, err := s.effectivePolicy(ctx, work, override)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ranked := s.rankBids(ctx, work, bids, now, s.newContractCapacity(ctx).isFull)
+	if len(ranked) == 0 {
+		return nil, nil, nil
+	}
+	chosen, score := ranked[0].bid, ranked[0].score
+
+	reason, pct := policyRejection(policy, work, ranked[0])
+	if reason == "" {
+		return chosen, nil, nil
+	}
+	return nil, &model.AwardDeferredResponse{
+		Awarded:    false,
+		Reason:     "top bid " + reason,
+		Policy:     policy,
+		TopBidID:   chosen.BidID,
+		TopScore:   score,
+		TopPrice:   chosen.Price,
+		BudgetUsed: pct,
+	}, nil
+}
+
+// policyRejection reports why rb does not satisfy policy, or "" when it
+// does, along with the share of the work budget the bid would use.
+func policyRejection(policy model.AutoAwardPolicy, work *clients.Work, rb rankedBid) (string, *float64) {
+	if policy.MinScore != nil {
+		if rb.score == nil {
+			return "cannot be scored: min_score policy requires bid evaluation, which is unavailable", nil
+		}
+		if *rb.score < *policy.MinScore {
+			return "score is below min_score", nil
+		}
+	}
+	if policy.MaxBudgetPercent != nil {
+		if work == nil || work.Budget.MaxPrice <= 0 {
+			return "cannot be priced: max_budget_percent policy requires the work budget, which is unavailable", nil
+		}
+		pct := rb.bid.Price / work.Budget.MaxPrice * 100
+		if pct > *policy.MaxBudgetPercent {
+			return "price exceeds max_budget_percent of budget", &pct
+		}
+		return "", &pct
+	}
+	return "", nil
+}
+
+type rankedBid struct {
+	bid   *clients.Bid
+	score *float64
+}
+
+// rankBids orders the unexpired bids best first, skipping any bid for which
+// exclude returns true. The bid evaluator's ranking is used when available;
+// otherwise bids are ordered by ascending price and carry no score.
+func (s *Service) rankBids(ctx context.Context, work *clients.Work, bids []clients.Bid, now time.Time, exclude func(clients.Bid) bool) []rankedBid {
+	valid := map[string]*clients.Bid{}
+	for i := range bids {
+		if bids[i].ExpiresAt.Before(now) || (exclude != nil && exclude(bids[i])) {
+			continue
+		}
+		valid[bids[i].BidID] = &bids[i]
+	}
+
+	var out []rankedBid
+	if s.evaluator != nil && work != nil && work.Budget.MaxPrice > 0 && len(valid) > 0 {
+		ranked, err := s.evaluator.Evaluate(ctx, work.WorkID, work.Budget)
+		if err != nil {
+			log.Printf("bid evaluation failed work_id=%s: %v", work.WorkID, err)
+		}
+		for _, rb := range ranked {
+			if b, ok := valid[rb.BidID]; ok {
+				sc := rb.TotalScore
+				out = append(out, rankedBid{bid: b, score: &sc})
+			}
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	for _, b := range valid {
+		out = append(out, rankedBid{bid: b})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].bid.Price != out[j].bid.Price {
+			return out[i].bid.Price < out[j].bid.Price
+		}
+		return out[i].bid.BidID < out[j].bid.BidID
+	})
+	return out
+}
+
+// effectivePolicy resolves the policy for an award: an explicit per-work
+// policy wins over the consumer's stored policy.
+func (s *Service) effectivePolicy(ctx context.Context, work *clients.Work, override *model.AutoAwardPolicy) (model.AutoAwardPolicy, error) {
+	if override != nil {
+		return *override, nil
+	}
+	if work == nil || work.ConsumerID == "" {
+		return model.AutoAwardPolicy{}, nil
+	}
+	p, err := s.policies.GetPolicy(ctx, work.ConsumerID)
+	if err != nil || p == nil {
+		return model.AutoAwardPolicy{}, err
+	}
+	return p.Policy, nil
+}
+
+// validatePolicy checks an auto-award policy, stored or given with an award.
+func validatePolicy(p model.AutoAwardPolicy) error {
+	if p.MinScore != nil && (*p.MinScore < 0 || *p.MinScore > 1) {
+		return errors.New("min_score must be between 0 and 1")
+	}
+	if p.MaxBudgetPercent != nil && *p.MaxBudgetPercent <= 0 {
+		return errors.New("max_budget_percent must be positive")
+	}
+	return nil
+}
+
+// HandleGetAwardPolicy returns a consumer's stored auto-award policy, to
+// the consumer or an admin.
+func (s *Service) HandleGetAwardPolicy(w http.ResponseWriter, r *http.Request) {
+	consumerID := strings.TrimSpace(r.PathValue("consumer_id"))
+	if !s.authorizeConsumer(w, r, consumerID) {
+		return
+	}
+	p, err := s.policies.GetPolicy(r.Context(), consumerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// HandlePutAwardPolicy stores a consumer's default auto-award policy. Only
+// the consumer or an admin may change it.
+func (s *Service) HandlePutAwardPolicy(w http.ResponseWriter, r *http.Request) {
+	consumerID := strings.TrimSpace(r.PathValue("consumer_id"))
+	if !s.authorizeConsumer(w, r, consumerID) {
+		return
+	}
+	var policy model.AutoAwardPolicy
+	if err := decodeJSON(r, &policy); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := validatePolicy(policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := model.ConsumerAwardPolicy{
+		ConsumerID: consumerID,
+		Policy:     policy,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	if err := s.policies.PutPolicy(r.Context(), p); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// authorizeConsumer lets the consumer, as the X-Tenant-ID the gateway sets,
+// or a caller with the admin token act on consumerID's policy, writing 403
+// for anyone else.
+func (s *Service) authorizeConsumer(w http.ResponseWriter, r *http.Request, consumerID string) bool {
+	if s.isAdmin(r) || (consumerID != "" && s.gatewayTenant(r) == consumerID) {
+		return true
+	}
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return false
+}
+
+// gatewayTenant is the X-Tenant-ID of a request the gateway proxied, as
+// proven by its X-Gateway-Token, and "" for any other request.
+func (s *Service) gatewayTenant(r *http.Request) string {
+	token := r.Header.Get("X-Gateway-Token")
+	if s.opts.GatewayToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.GatewayToken)) != 1 {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-Tenant-ID"))
+}
+
+// isAdmin reports whether r carries the admin token as a Bearer token.
+// Without a configured admin token no request is an admin's.
+func (s *Service) isAdmin(r *http.Request) bool {
+	return s.opts.AdminToken != "" && subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(s.opts.AdminToken)) == 1
+}
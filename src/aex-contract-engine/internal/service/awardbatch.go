@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/clients"
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/store"
+)
+
+// maxBatchAward bounds the number of winners a single batch award can create.
+const maxBatchAward = 50
+
+// HandleAwardBatch awards several bids on multi-winner work. The n winners
+// occupy award slots 0..n-1 and are saved all-or-nothing; repeating the same
+// batch returns the existing contracts.
+func (s *Service) HandleAwardBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	workID := pathParam(r.URL.Path, "/v1/work/", "/award-batch")
+	if workID == "" {
+		http.Error(w, "work_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var req model.AwardBatchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if (len(req.BidIDs) > 0) == (req.AutoAwardTopN > 0) {
+		http.Error(w, "exactly one of bid_ids or auto_award_top_n is required", http.StatusBadRequest)
+		return
+	}
+	if req.Policy != nil {
+		if err := validatePolicy(*req.Policy); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	n := req.AutoAwardTopN
+	if len(req.BidIDs) > 0 {
+		n = len(req.BidIDs)
+	}
+	if n > maxBatchAward {
+		http.Error(w, fmt.Sprintf("at most %d bids can be awarded at once", maxBatchAward), http.StatusBadRequest)
+		return
+	}
+
+	existing := make([]model.AwardResponse, 0, n)
+	for i := 0; i < n; i++ {
+		c, err := s.store.GetByAwardSlot(ctx, awardSlot(workID, i))
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if c != nil {
+			existing = append(existing, awardResponse(c))
+		}
+	}
+	if len(existing) > 0 {
+		if len(existing) == n && sameBids(existing, req.BidIDs) {
+			writeJSON(w, http.StatusOK, model.AwardBatchResponse{WorkID: workID, Contracts: existing})
+			return
+		}
+		http.Error(w, "work already awarded", http.StatusConflict)
+		return
+	}
+
+	work := s.lookupWork(ctx, workID)
+	consumerID := "unknown"
+	var callbackURL string
+	if work != nil {
+		if work.ConsumerID != "" {
+			consumerID = work.ConsumerID
+		}
+		callbackURL = work.CallbackURL
+	}
+
+	bids, err := s.bg.ListBids(ctx, workID)
+	if err != nil {
+		http.Error(w, "failed to fetch bids", http.StatusBadGateway)
+		return
+	}
+
+	now := time.Now().UTC()
+	capacity := s.newContractCapacity(ctx)
+	var chosen []*clients.Bid
+	if req.AutoAwardTopN > 0 {
+		var deferred *model.AwardDeferredResponse
+		chosen, deferred, err = s.selectTopN(ctx, work, bids, req.Policy, n, capacity, now)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if deferred != nil {
+			deferred.WorkID = workID
+			writeJSON(w, http.StatusAccepted, deferred)
+			return
+		}
+		if len(chosen) < n {
+			http.Error(w, "not enough valid bids to award", http.StatusBadRequest)
+			return
+		}
+	} else {
+		for _, bidID := range req.BidIDs {
+			i := slices.IndexFunc(bids, func(b clients.Bid) bool { return b.BidID == bidID })
+			if i < 0 {
+				http.Error(w, "invalid bid_id: "+bidID, http.StatusBadRequest)
+				return
+			}
+			if slices.Contains(chosen, &bids[i]) {
+				http.Error(w, "duplicate bid_id: "+bidID, http.StatusBadRequest)
+				return
+			}
+			if bids[i].ExpiresAt.Before(now) {
+				http.Error(w, "bid expired: "+bidID, http.StatusConflict)
+				return
+			}
+			if !capacity.take(bids[i].ProviderID) {
+				http.Error(w, "provider is at its concurrent contract limit: "+bids[i].ProviderID, http.StatusConflict)
+				return
+			}
+			chosen = append(chosen, &bids[i])
+		}
+	}
+
+	contracts := make([]model.Contract, 0, n)
+	for i, b := range chosen {
+		contracts = append(contracts, s.newContract(workID, awardSlot(workID, i), consumerID, callbackURL, b, now))
+	}
+	if err := s.store.SaveAll(ctx, contracts); err != nil {
+		if errors.Is(err, store.ErrAwardSlotTaken) {
+			http.Error(w, "work already awarded", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to save contracts", http.StatusInternalServerError)
+		return
+	}
+
+	out := model.AwardBatchResponse{WorkID: workID, Contracts: make([]model.AwardResponse, 0, n)}
+	for i := range contracts {
+		s.announceAward(&contracts[i])
+		out.Contracts = append(out.Contracts, awardResponse(&contracts[i]))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// selectTopN picks the n best-ranked bids that satisfy the effective
+// auto-award policy and whose providers have capacity, deferring when fewer
+// than n qualify.
+func (s *Service) selectTopN(ctx context.Context, work *clients.Work, bids []clients.Bid, override *model.AutoAwardPolicy, n int, capacity *contractCapacity, now time.Time) ([]*clients.Bid, *model.AwardDeferredResponse, error) {
+	policy, err := s.effectivePolicy(ctx, work, override)
+	if err != nil {
+		return nil, nil, err
+	}
+	ranked := s.rankBids(ctx, work, bids, now, capacity.isFull)
+	if len(ranked) < n {
+		return nil, nil, nil
+	}
+
+	var chosen []*clients.Bid
+	for _, rb := range ranked {
+		if reason, _ := policyRejection(policy, work, rb); reason != "" || !capacity.take(rb.bid.ProviderID) {
+			continue
+		}
+		chosen = append(chosen, rb.bid)
+		if len(chosen) == n {
+			return chosen, nil, nil
+		}
+	}
+	return nil, &model.AwardDeferredResponse{
+		Awarded:  false,
+		Reason:   fmt.Sprintf("only %d of %d requested bids satisfy the award policy", len(chosen), n),
+		Policy:   policy,
+		TopBidID: ranked[0].bid.BidID,
+		TopScore: ranked[0].score,
+		TopPrice: ranked[0].bid.Price,
+	}, nil
+}
+
+// sameBids reports whether the awarded contracts cover exactly bidIDs. An
+// empty bidIDs (auto_award_top_n) matches any set of the right size.
+func sameBids(awarded []model.AwardResponse, bidIDs []string) bool {
+	if len(bidIDs) == 0 {
+		return true
+	}
+	for _, a := range awarded {
+		if !slices.Contains(bidIDs, a.BidID) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/clients"
+)
+
+// contractCapacity tracks how many more contracts providers may take during
+// one award: their provider registry limit less the active contracts they
+// hold. Without a provider registry, or when a provider's limit or contracts
+// cannot be read, the provider is not limited.
+type contractCapacity struct {
+	s         *Service
+	ctx       context.Context
+	remaining map[string]int // -1 when unlimited
+}
+
+func (s *Service) newContractCapacity(ctx context.Context) *contractCapacity {
+	return &contractCapacity{s: s, ctx: ctx, remaining: map[string]int{}}
+}
+
+func (c *contractCapacity) left(providerID string) int {
+	if n, ok := c.remaining[providerID]; ok {
+		return n
+	}
+	n := -1
+	if c.s.registry != nil {
+		limits, err := c.s.registry.GetLimits(c.ctx, providerID)
+		if err != nil {
+			log.Printf("provider limits lookup failed provider_id=%s: %v", providerID, err)
+		} else if limits.MaxConcurrentContracts > 0 {
+			active, err := c.s.store.CountActiveByProvider(c.ctx, providerID)
+			if err != nil {
+				log.Printf("active contract count failed provider_id=%s: %v", providerID, err)
+			} else {
+				n = max(limits.MaxConcurrentContracts-active, 0)
+			}
+		}
+	}
+	c.remaining[providerID] = n
+	return n
+}
+
+// full reports whether the provider may take no more contracts.
+func (c *contractCapacity) full(providerID string) bool {
+	return c.left(providerID) == 0
+}
+
+// isFull is full for a bid's provider, to exclude bids from ranking.
+func (c *contractCapacity) isFull(b clients.Bid) bool {
+	return c.full(b.ProviderID)
+}
+
+// take reserves a contract for the provider, reporting false when it is
+// full.
+func (c *contractCapacity) take(providerID string) bool {
+	n := c.left(providerID)
+	if n == 0 {
+		return false
+	}
+	if n > 0 {
+		c.remaining[providerID] = n - 1
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/events"
+)
+
+// contractEventTypes are the events published to the shared event bus so
+// settlement, trust broker and telemetry can subscribe to contract changes.
+var contractEventTypes = []string{
+	events.EventContractAwarded,
+	events.EventContractStarted,
+	events.EventContractCompleted,
+	events.EventContractFailed,
+	events.EventContractDisputed,
+	events.EventContractCancelled,
+}
+
+// publish sends an event in the background; delivery failures are logged by
+// the publisher and never fail the request that caused them.
+func (s *Service) publish(c *model.Contract, eventType string, data map[string]any) {
+	data["contract_id"] = c.ContractID
+	data["work_id"] = c.WorkID
+	data["provider_id"] = c.ProviderID
+	data["consumer_id"] = c.ConsumerID
+	if c.ConsumerID != "" {
+		data["tenant_id"] = c.ConsumerID
+	}
+	go func() {
+		if err := s.events.Publish(context.Background(), eventType, data); err != nil {
+			log.Printf("event publish failed type=%s contract_id=%s: %v", eventType, c.ContractID, err)
+		}
+	}()
+}
+
+func (s *Service) publishAwarded(c *model.Contract) {
+	s.publish(c, events.EventContractAwarded, map[string]any{
+		"bid_id":       c.BidID,
+		"agreed_price": c.AgreedPrice,
+		"a2a_endpoint": c.ProviderEndpoint,
+		"awarded_at":   c.AwardedAt,
+		"expires_at":   c.ExpiresAt,
+	})
+}
+
+func (s *Service) publishStarted(c *model.Contract) {
+	s.publish(c, events.EventContractStarted, map[string]any{
+		"started_at": c.StartedAt,
+	})
+}
+
+func (s *Service) publishCompleted(c *model.Contract) {
+	data := map[string]any{
+		"completed_at": c.CompletedAt,
+		"billing":      map[string]any{"cost": c.AgreedPrice},
+	}
+	if c.StartedAt != nil && c.CompletedAt != nil {
+		data["started_at"] = c.StartedAt
+		data["duration_ms"] = c.CompletedAt.Sub(*c.StartedAt).Milliseconds()
+	}
+	if c.Outcome != nil {
+		data["success"] = c.Outcome.Success
+		data["metrics"] = c.Outcome.Metrics
+	}
+	s.publish(c, events.EventContractCompleted, data)
+}
+
+func (s *Service) publishFailed(c *model.Contract, reason, message, reportedBy string) {
+	s.publish(c, events.EventContractFailed, map[string]any{
+		"failure_reason": reason,
+		"error_message":  message,
+		"reported_by":    reportedBy,
+		"failed_at":      c.FailedAt,
+	})
+}
+
+// publishDisputed is used when the consumer, rather than the provider,
+// reports a contract as failed: the outcome is contested until resolved.
+func (s *Service) publishDisputed(c *model.Contract, reason, message string, at time.Time) {
+	s.publish(c, events.EventContractDisputed, map[string]any{
+		"reason":      reason,
+		"message":     message,
+		"disputed_at": at,
+	})
+}
+
+func (s *Service) publishCancelled(c *model.Contract) {
+	s.publish(c, events.EventContractCancelled, map[string]any{
+		"reason":           c.Cancellation.Reason,
+		"cancellation_fee": c.Cancellation.Fee,
+		"cancelled_at":     c.Cancellation.CancelledAt,
+	})
+}
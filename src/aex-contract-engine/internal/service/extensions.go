@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
+)
+
+const (
+	defaultMaxExtensions = 3
+	defaultMaxExtension  = 24 * time.Hour
+)
+
+// HandleRequestExtension lets the provider ask for a later deadline. The
+// request only takes effect once the consumer approves it.
+func (s *Service) HandleRequestExtension(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	contractID := r.PathValue("contract_id")
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req model.ExtensionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.NewDeadline.IsZero() || req.Reason == "" {
+		http.Error(w, "new_deadline and reason are required", http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.store.Get(ctx, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if c.ExecutionToken != token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+	for _, x := range c.Extensions {
+		if x.Status == model.ExtensionStatusPending {
+			http.Error(w, "an extension request is already pending", http.StatusConflict)
+			return
+		}
+	}
+	if max := s.maxExtensions(); len(c.Extensions) >= max {
+		http.Error(w, fmt.Sprintf("at most %d extension requests are allowed per contract", max), http.StatusConflict)
+		return
+	}
+	if !req.NewDeadline.After(c.ExpiresAt) {
+		http.Error(w, "new_deadline must be after the current deadline", http.StatusBadRequest)
+		return
+	}
+	if max := s.maxExtension(); req.NewDeadline.Sub(c.ExpiresAt) > max {
+		http.Error(w, fmt.Sprintf("new_deadline may extend the deadline by at most %s", max), http.StatusBadRequest)
+		return
+	}
+
+	ext := model.Extension{
+		ExtensionID:      generateID("ext_"),
+		PreviousDeadline: c.ExpiresAt,
+		NewDeadline:      req.NewDeadline.UTC(),
+		Reason:           req.Reason,
+		Status:           model.ExtensionStatusPending,
+		RequestedAt:      time.Now().UTC(),
+	}
+	c.Extensions = append(c.Extensions, ext)
+	if err := s.store.Update(ctx, *c); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, ext)
+}
+
+// HandleApproveExtension moves the deadline to the requested time and
+// records the change as a new contract revision.
+func (s *Service) HandleApproveExtension(w http.ResponseWriter, r *http.Request) {
+	s.decideExtension(w, r, model.ExtensionStatusApproved)
+}
+
+// HandleDenyExtension rejects a pending extension request.
+func (s *Service) HandleDenyExtension(w http.ResponseWriter, r *http.Request) {
+	s.decideExtension(w, r, model.ExtensionStatusDenied)
+}
+
+func (s *Service) decideExtension(w http.ResponseWriter, r *http.Request, decision model.ExtensionStatus) {
+	ctx := r.Context()
+	contractID := r.PathValue("contract_id")
+	extensionID := r.PathValue("extension_id")
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// The decision reason is optional, so an empty body is accepted.
+	var req model.ExtensionDecisionRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	c, err := s.store.Get(ctx, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if c.ConsumerToken != token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var ext *model.Extension
+	for i := range c.Extensions {
+		if c.Extensions[i].ExtensionID == extensionID {
+			ext = &c.Extensions[i]
+			break
+		}
+	}
+	if ext == nil {
+		http.Error(w, "extension request not found", http.StatusNotFound)
+		return
+	}
+	if ext.Status != model.ExtensionStatusPending {
+		http.Error(w, "extension request is not pending", http.StatusConflict)
+		return
+	}
+	if decision == model.ExtensionStatusApproved &&
+		c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+	ext.Status = decision
+	ext.DecidedAt = &now
+	ext.DecisionReason = strings.TrimSpace(req.Reason)
+	if decision == model.ExtensionStatusApproved {
+		ensureRevisions(c)
+		c.ExpiresAt = ext.NewDeadline
+		c.Revision++
+		ext.Revision = c.Revision
+		c.Revisions = append(c.Revisions, model.ContractRevision{
+			Revision:    c.Revision,
+			AgreedPrice: c.AgreedPrice,
+			ExpiresAt:   c.ExpiresAt,
+			ExtensionID: ext.ExtensionID,
+			EffectiveAt: now,
+		})
+	}
+	if err := s.store.Update(ctx, *c); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"contract_id":  contractID,
+		"extension_id": extensionID,
+		"status":       ext.Status,
+		"revision":     c.Revision,
+		"expires_at":   c.ExpiresAt,
+	})
+}
+
+func (s *Service) maxExtensions() int {
+	if s.opts.MaxExtensions > 0 {
+		return s.opts.MaxExtensions
+	}
+	return defaultMaxExtensions
+}
+
+func (s *Service) maxExtension() time.Duration {
+	if s.opts.MaxExtension > 0 {
+		return s.opts.MaxExtension
+	}
+	return defaultMaxExtension
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/clients"
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/webhook"
+)
+
+// RunNoShowMonitor periodically fails contracts whose provider missed the
+// start deadline. It returns at once when no start deadline or interval is
+// set, otherwise when ctx is cancelled.
+func (s *Service) RunNoShowMonitor(ctx context.Context, interval time.Duration) {
+	if s.opts.StartDeadline <= 0 || interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.sweepNoShows(ctx, time.Now().UTC())
+		}
+	}
+}
+
+func (s *Service) sweepNoShows(ctx context.Context, now time.Time) {
+	overdue, err := s.store.ListStartOverdue(ctx, now)
+	if err != nil {
+		log.Printf("no-show sweep failed: %v", err)
+		return
+	}
+	for i := range overdue {
+		s.failNoShow(ctx, &overdue[i], now)
+	}
+}
+
+// failNoShow fails c as a provider no-show, reports it to the trust broker
+// unless the event bus does and, when enabled, awards the work to the
+// next-best bid. Failing the contract releases its award slot, so the
+// replacement can take it.
+func (s *Service) failNoShow(ctx context.Context, c *model.Contract, now time.Time) {
+	// Re-read to avoid clobbering a progress update that raced the sweep.
+	cur, err := s.store.Get(ctx, c.ContractID)
+	if err != nil || cur == nil || cur.Status != model.ContractStatusAwarded {
+		return
+	}
+	c = cur
+	slot := c.AwardSlot
+	reason := model.FailureReasonNoShow
+	c.Status = model.ContractStatusFailed
+	c.FailedAt = &now
+	c.FailureReason = &reason
+	c.TrustOutcome = model.TrustOutcomeFailureProvider
+	if err := s.store.UpdateIfStatus(ctx, *c, model.ContractStatusAwarded); err != nil {
+		log.Printf("no-show update failed contract_id=%s: %v", c.ContractID, err)
+		return
+	}
+	log.Printf("provider no-show contract_id=%s provider_id=%s", c.ContractID, c.ProviderID)
+	s.notify(c, webhook.EventFailed, map[string]any{"failure_reason": reason, "reported_by": "system"})
+	s.publishFailed(c, reason, "provider did not start before the deadline", "system")
+
+	s.reportOutcome(ctx, c, map[string]any{"failure_reason": reason}, now)
+
+	if !s.opts.ReawardOnNoShow || slot == "" {
+		return
+	}
+	next := s.reaward(ctx, c, slot, now)
+	if next == nil {
+		return
+	}
+	c.ReawardedTo = next.ContractID
+	if err := s.store.Update(ctx, *c); err != nil {
+		log.Printf("no-show link update failed contract_id=%s: %v", c.ContractID, err)
+	}
+}
+
+// reportOutcome reports c's trust outcome to the trust broker. With the
+// event bus configured the broker records it from the contract's event
+// instead; reporting it here as well would count it twice.
+func (s *Service) reportOutcome(ctx context.Context, c *model.Contract, metrics map[string]any, at time.Time) {
+	if s.trust == nil || s.opts.EventsURL != "" {
+		return
+	}
+	err := s.trust.RecordOutcome(ctx, clients.ContractOutcome{
+		ContractID:  c.ContractID,
+		ProviderID:  c.ProviderID,
+		ConsumerID:  c.ConsumerID,
+		Outcome:     string(c.TrustOutcome),
+		Metrics:     metrics,
+		AgreedPrice: c.AgreedPrice,
+		CompletedAt: at,
+	})
+	if err != nil {
+		log.Printf("trust outcome report failed contract_id=%s: %v", c.ContractID, err)
+	}
+}
+
+// reaward awards the work to the best remaining bid from a different
+// provider, returning nil when there is none.
+func (s *Service) reaward(ctx context.Context, failed *model.Contract, slot string, now time.Time) *model.Contract {
+	bids, err := s.bg.ListBids(ctx, failed.WorkID)
+	if err != nil {
+		log.Printf("re-award bid fetch failed work_id=%s: %v", failed.WorkID, err)
+		return nil
+	}
+	work := s.lookupWork(ctx, failed.WorkID)
+	capacity := s.newContractCapacity(ctx)
+	ranked := s.rankBids(ctx, work, bids, now, func(b clients.Bid) bool {
+		return b.ProviderID == failed.ProviderID || capacity.isFull(b)
+	})
+	if len(ranked) == 0 {
+		log.Printf("no bids left to re-award work_id=%s", failed.WorkID)
+		return nil
+	}
+	next, err := s.createContract(ctx, failed.WorkID, slot, failed.ConsumerID, failed.CallbackURL, ranked[0].bid, now)
+	if err != nil {
+		log.Printf("re-award failed work_id=%s: %v", failed.WorkID, err)
+		return nil
+	}
+	next.ReplacesContractID = failed.ContractID
+	if err := s.store.Update(ctx, *next); err != nil {
+		log.Printf("re-award link update failed contract_id=%s: %v", next.ContractID, err)
+	}
+	return next
+}
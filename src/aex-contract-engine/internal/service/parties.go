@@ -0,0 +1,138 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/clients"
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
+)
+
+// HandleAddParty lets the primary provider subcontract a step of the work to
+// another provider. The new party receives its own execution token, which it
+// uses for progress and to complete its step.
+func (s *Service) HandleAddParty(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	contractID := pathParam(r.URL.Path, "/v1/contracts/", "/parties")
+	if contractID == "" {
+		http.Error(w, "contract_id is required", http.StatusBadRequest)
+		return
+	}
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req model.AddPartyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.ProviderID = strings.TrimSpace(req.ProviderID)
+	req.Step = strings.TrimSpace(req.Step)
+	if req.ProviderID == "" || req.Step == "" {
+		http.Error(w, "provider_id and step are required", http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.store.Get(ctx, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if c.ExecutionToken != token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+	if req.SharePercent <= 0 || req.SharePercent+subcontractedShare(c) >= 100 {
+		http.Error(w, "share_percent must be positive and leave a share for the primary provider", http.StatusBadRequest)
+		return
+	}
+
+	party := model.ContractParty{
+		PartyID:          generateID("party_"),
+		ProviderID:       req.ProviderID,
+		Step:             req.Step,
+		SharePercent:     req.SharePercent,
+		ProviderEndpoint: req.ProviderEndpoint,
+		Status:           model.PartyStatusActive,
+		AddedAt:          time.Now().UTC(),
+	}
+	party.ExecutionToken, party.ExecutionTokenHash = newExecutionToken()
+	c.Parties = append(c.Parties, party)
+	if err := s.store.Update(ctx, *c); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, party)
+}
+
+// completeParty marks a subcontracted step as done. The contract itself is
+// only completed by the primary provider once every step is done.
+func (s *Service) completeParty(w http.ResponseWriter, r *http.Request, c *model.Contract, party *model.ContractParty) {
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+	if party.Status == model.PartyStatusCompleted {
+		http.Error(w, "step already completed", http.StatusConflict)
+		return
+	}
+	now := time.Now().UTC()
+	party.Status = model.PartyStatusCompleted
+	party.CompletedAt = &now
+	if err := s.store.Update(r.Context(), *c); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"contract_id":  c.ContractID,
+		"party_id":     party.PartyID,
+		"status":       party.Status,
+		"completed_at": now,
+	})
+}
+
+// findParty returns the subcontracted party owning token, if any. The
+// returned pointer aliases c.Parties so callers can update it in place.
+func findParty(c *model.Contract, token string) *model.ContractParty {
+	if token == "" {
+		return nil
+	}
+	for i := range c.Parties {
+		if c.Parties[i].ExecutionToken == token {
+			return &c.Parties[i]
+		}
+	}
+	return nil
+}
+
+func subcontractedShare(c *model.Contract) float64 {
+	var total float64
+	for _, p := range c.Parties {
+		total += p.SharePercent
+	}
+	return total
+}
+
+// payoutSplits builds the settlement split for a completed multi-party
+// contract. Single-provider contracts and non-completion charges send none.
+func payoutSplits(c *model.Contract, kind model.SettlementKind) []clients.PayoutSplit {
+	if kind != model.SettlementKindCompletion || len(c.Parties) == 0 {
+		return nil
+	}
+	splits := []clients.PayoutSplit{{ProviderID: c.ProviderID, SharePercent: 100 - subcontractedShare(c)}}
+	for _, p := range c.Parties {
+		splits = append(splits, clients.PayoutSplit{ProviderID: p.ProviderID, SharePercent: p.SharePercent})
+	}
+	return splits
+}
@@ -1,33 +1,183 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
+	"log"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/clients"
 	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/store"
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/webhook"
+	"github.com/parlakisik/agent-exchange/internal/events"
+	"github.com/parlakisik/agent-exchange/internal/httpclient"
+	"github.com/parlakisik/agent-exchange/internal/serviceauth"
 )
 
 type Service struct {
-	store store.ContractStore
-	bg    *clients.BidGatewayClient
+	store      store.ContractStore
+	policies   store.AwardPolicyStore
+	artifacts  store.ArtifactStore
+	bg         *clients.BidGatewayClient
+	evaluator  *clients.BidEvaluatorClient
+	trust      *clients.TrustBrokerClient
+	settlement *clients.SettlementClient
+	work       *clients.WorkPublisherClient
+	registry   *clients.ProviderRegistryClient
+	notifier   *webhook.Notifier
+	events     *events.Publisher
+	opts       Options
+}
+
+// Options holds optional behaviour that is not required for the basic
+// award/progress/complete flow.
+type Options struct {
+	// SettlementURL enables payment of cancellation fees when set.
+	SettlementURL string
+	// CancellationFeePercent is the share of the agreed price paid to the
+	// provider when the consumer cancels an executing contract.
+	CancellationFeePercent float64
+
+	// WorkPublisherURL is used to look up the consumer and callback_url of
+	// the work being awarded. Without it no webhooks are sent.
+	WorkPublisherURL string
+	// WebhookSecret signs callback payloads (HMAC-SHA256).
+	WebhookSecret      string
+	WebhookMaxAttempts int
+	WebhookBackoff     time.Duration
+	// ProgressThresholds are the percentages at which an in_progress
+	// notification is sent, in addition to the start of execution.
+	ProgressThresholds []int
+
+	// BidEvaluatorURL enables score-based auto-award. Without it auto-award
+	// falls back to the lowest-priced bid.
+	BidEvaluatorURL string
+	// PolicyStore holds per-consumer auto-award policies; defaults to memory.
+	PolicyStore store.AwardPolicyStore
+
+	// StartDeadline is how long a provider has to send its first progress
+	// update before the contract is failed as a no-show. Zero disables it.
+	StartDeadline time.Duration
+	// ReawardOnNoShow awards the work to the next-best bid after a no-show.
+	ReawardOnNoShow bool
+	// TrustBrokerURL receives no-show and cancellation outcomes when set
+	// and no EventsURL is.
+	TrustBrokerURL string
+	// TrustBrokerToken authenticates outcome reports to the trust broker.
+	TrustBrokerToken string
+	// ServiceTokens, when set, authenticates calls to settlement and the
+	// trust broker with service account tokens, in place of
+	// TrustBrokerToken.
+	ServiceTokens *serviceauth.TokenSource
+
+	// ArtifactStore holds uploaded result artifacts; defaults to memory.
+	ArtifactStore store.ArtifactStore
+	// MaxArtifactBytes caps a single artifact upload (default 10 MiB).
+	MaxArtifactBytes int64
+
+	// MaxExtensions caps the number of deadline extension requests per
+	// contract (default 3); MaxExtension caps how far a single request may
+	// move the deadline (default 24h).
+	MaxExtensions int
+	MaxExtension  time.Duration
+
+	// Retention is how long terminal contracts stay in the hot store before
+	// being archived. Zero disables archival.
+	Retention time.Duration
+
+	// EventsURL receives contract lifecycle events on the shared event bus.
+	// Without it events are only logged. The trust broker subscribes to the
+	// bus, so outcomes are then not reported to it directly; settlement does
+	// not, and is still called to settle completions.
+	EventsURL string
+	// EventsSecret signs events so the trust broker can verify them.
+	EventsSecret string
+
+	// ProviderRegistryURL enables the per-provider concurrent contract limits
+	// set in the provider registry.
+	ProviderRegistryURL string
+
+	// AdminToken, when set, lets Bearer callers with it manage any
+	// consumer's award policy.
+	AdminToken string
+	// GatewayToken is the gateway's upstream token. A consumer's
+	// X-Tenant-ID is only trusted on requests carrying it in
+	// X-Gateway-Token; without it only admins manage award policies.
+	GatewayToken string
 }
 
 func New(store store.ContractStore, bidGatewayURL string) (*Service, error) {
+	return NewWithOptions(store, bidGatewayURL, Options{})
+}
+
+func NewWithOptions(st store.ContractStore, bidGatewayURL string, opts Options) (*Service, error) {
 	if strings.TrimSpace(bidGatewayURL) == "" {
 		return nil, errors.New("BID_GATEWAY_URL is required")
 	}
-	return &Service{
-		store: store,
-		bg:    clients.NewBidGatewayClient(bidGatewayURL),
-	}, nil
+	if opts.CancellationFeePercent < 0 || opts.CancellationFeePercent > 100 {
+		return nil, errors.New("CANCELLATION_FEE_PERCENT must be between 0 and 100")
+	}
+	svc := &Service{
+		store:     st,
+		policies:  opts.PolicyStore,
+		artifacts: opts.ArtifactStore,
+		bg:        clients.NewBidGatewayClient(bidGatewayURL),
+		opts:      opts,
+	}
+	if svc.policies == nil {
+		svc.policies = store.NewMemoryAwardPolicyStore()
+	}
+	if svc.artifacts == nil {
+		svc.artifacts = store.NewMemoryArtifactStore()
+	}
+	if opts.BidEvaluatorURL != "" {
+		svc.evaluator = clients.NewBidEvaluatorClient(opts.BidEvaluatorURL)
+	}
+	if opts.TrustBrokerURL != "" {
+		var auth httpclient.AuthProvider
+		switch {
+		case opts.ServiceTokens != nil:
+			auth = opts.ServiceTokens.For("aex-trust-broker")
+		case opts.TrustBrokerToken != "":
+			auth = &httpclient.BearerTokenAuth{Token: opts.TrustBrokerToken}
+		}
+		svc.trust = clients.NewTrustBrokerClient(opts.TrustBrokerURL, auth)
+	}
+	if opts.SettlementURL != "" {
+		var auth httpclient.AuthProvider
+		if opts.ServiceTokens != nil {
+			auth = opts.ServiceTokens.For("aex-settlement")
+		}
+		svc.settlement = clients.NewSettlementClient(opts.SettlementURL, auth)
+	}
+	if opts.WorkPublisherURL != "" {
+		svc.work = clients.NewWorkPublisherClient(opts.WorkPublisherURL)
+	}
+	if opts.ProviderRegistryURL != "" {
+		svc.registry = clients.NewProviderRegistryClient(opts.ProviderRegistryURL)
+	}
+	svc.notifier = webhook.NewNotifier(opts.WebhookSecret, opts.WebhookMaxAttempts, opts.WebhookBackoff)
+	svc.events = events.NewPublisher("aex-contract-engine")
+	if opts.EventsSecret != "" {
+		svc.events.SetSecret(opts.EventsSecret)
+	}
+	if opts.EventsURL != "" {
+		for _, typ := range contractEventTypes {
+			svc.events.RegisterEndpoint(typ, opts.EventsURL)
+		}
+	}
+	return svc, nil
 }
 
 func (s *Service) HandleAward(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +193,35 @@ func (s *Service) HandleAward(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
+	if req.Policy != nil {
+		if err := validatePolicy(*req.Policy); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Awarding is idempotent: while a contract is active for the work, repeat
+	// calls return it instead of creating another one.
+	slot := awardSlot(workID, 0)
+	existing, err := s.store.GetByAwardSlot(ctx, slot)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		writeJSON(w, http.StatusOK, awardResponse(existing))
+		return
+	}
+
+	work := s.lookupWork(ctx, workID)
+	consumerID := "unknown"
+	var callbackURL string
+	if work != nil {
+		if work.ConsumerID != "" {
+			consumerID = work.ConsumerID
+		}
+		callbackURL = work.CallbackURL
+	}
 
 	bids, err := s.bg.ListBids(ctx, workID)
 	if err != nil {
@@ -53,14 +232,16 @@ func (s *Service) HandleAward(w http.ResponseWriter, r *http.Request) {
 	now := time.Now().UTC()
 	var chosen *clients.Bid
 	if req.AutoAward {
-		// Simplest policy for local use: choose the lowest price among unexpired bids.
-		for i := range bids {
-			if bids[i].ExpiresAt.Before(now) {
-				continue
-			}
-			if chosen == nil || bids[i].Price < chosen.Price {
-				chosen = &bids[i]
-			}
+		var deferred *model.AwardDeferredResponse
+		chosen, deferred, err = s.selectAutoAward(ctx, work, bids, req.Policy, now)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if deferred != nil {
+			deferred.WorkID = workID
+			writeJSON(w, http.StatusAccepted, deferred)
+			return
 		}
 		if chosen == nil {
 			http.Error(w, "no valid bids to award", http.StatusBadRequest)
@@ -82,47 +263,105 @@ func (s *Service) HandleAward(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "bid expired", http.StatusConflict)
 			return
 		}
+		if !s.newContractCapacity(ctx).take(chosen.ProviderID) {
+			http.Error(w, "provider is at its concurrent contract limit", http.StatusConflict)
+			return
+		}
+	}
+
+	contract, err := s.createContract(ctx, workID, slot, consumerID, callbackURL, chosen, now)
+	if err != nil {
+		if errors.Is(err, store.ErrAwardSlotTaken) {
+			// Lost a race with a concurrent award; return the winner.
+			if existing, gerr := s.store.GetByAwardSlot(ctx, slot); gerr == nil && existing != nil {
+				writeJSON(w, http.StatusOK, awardResponse(existing))
+				return
+			}
+		}
+		http.Error(w, "failed to save contract", http.StatusInternalServerError)
+		return
 	}
+	writeJSON(w, http.StatusOK, awardResponse(contract))
+}
 
-	contractID := generateID("contract_")
-	execToken := generateID("exec_")
-	consumerToken := generateID("cons_")
+// createContract persists a new contract for the chosen bid in the given
+// award slot and notifies the consumer.
+func (s *Service) createContract(ctx context.Context, workID, slot, consumerID, callbackURL string, chosen *clients.Bid, now time.Time) (*model.Contract, error) {
+	contract := s.newContract(workID, slot, consumerID, callbackURL, chosen, now)
+	if err := s.store.Save(ctx, contract); err != nil {
+		return nil, err
+	}
+	s.announceAward(&contract)
+	return &contract, nil
+}
+
+// newContract builds an AWARDED contract for the chosen bid without saving it.
+func (s *Service) newContract(workID, slot, consumerID, callbackURL string, chosen *clients.Bid, now time.Time) model.Contract {
 	expiresAt := now.Add(1 * time.Hour)
 
-	// ConsumerID is unknown until identity/gateway integration; keep placeholder.
+	// ConsumerID stays a placeholder unless the work publisher is configured.
 	contract := model.Contract{
-		ContractID:       contractID,
+		ContractID:       generateID("contract_"),
 		WorkID:           workID,
-		ConsumerID:       "unknown",
+		AwardSlot:        slot,
+		ConsumerID:       consumerID,
+		CallbackURL:      callbackURL,
 		ProviderID:       chosen.ProviderID,
 		BidID:            chosen.BidID,
 		AgreedPrice:      chosen.Price,
 		SLA:              model.SLACommitment{},
 		ProviderEndpoint: chosen.A2AEndpoint,
-		ExecutionToken:   execToken,
-		ConsumerToken:    consumerToken,
+		ConsumerToken:    generateID("cons_"),
 		Status:           model.ContractStatusAwarded,
 		ExpiresAt:        expiresAt,
 		AwardedAt:        now,
+		Revision:         1,
+		Revisions: []model.ContractRevision{{
+			Revision:    1,
+			AgreedPrice: chosen.Price,
+			ExpiresAt:   expiresAt,
+			EffectiveAt: now,
+		}},
 	}
-
-	if err := s.store.Save(ctx, contract); err != nil {
-		http.Error(w, "failed to save contract", http.StatusInternalServerError)
-		return
+	contract.ExecutionToken, contract.ExecutionTokenHash = newExecutionToken()
+	if s.opts.StartDeadline > 0 {
+		deadline := now.Add(s.opts.StartDeadline)
+		contract.StartDeadline = &deadline
 	}
 
-	resp := model.AwardResponse{
-		ContractID:       contract.ContractID,
-		WorkID:           contract.WorkID,
-		ProviderID:       contract.ProviderID,
-		AgreedPrice:      contract.AgreedPrice,
-		Status:           contract.Status,
-		ProviderEndpoint: contract.ProviderEndpoint,
-		ExecutionToken:   contract.ExecutionToken,
-		ExpiresAt:        contract.ExpiresAt,
-		AwardedAt:        contract.AwardedAt,
+	return contract
+}
+
+// announceAward tells the consumer and the event bus about a new contract.
+func (s *Service) announceAward(c *model.Contract) {
+	s.notify(c, webhook.EventAwarded, map[string]any{
+		"provider_id":  c.ProviderID,
+		"agreed_price": c.AgreedPrice,
+		"expires_at":   c.ExpiresAt,
+	})
+	s.publishAwarded(c)
+}
+
+func awardResponse(c *model.Contract) model.AwardResponse {
+	return model.AwardResponse{
+		ContractID:       c.ContractID,
+		WorkID:           c.WorkID,
+		BidID:            c.BidID,
+		ProviderID:       c.ProviderID,
+		AgreedPrice:      c.AgreedPrice,
+		Status:           c.Status,
+		ProviderEndpoint: c.ProviderEndpoint,
+		ExecutionToken:   c.ExecutionToken,
+		ConsumerToken:    c.ConsumerToken,
+		ExpiresAt:        c.ExpiresAt,
+		AwardedAt:        c.AwardedAt,
 	}
-	writeJSON(w, http.StatusOK, resp)
+}
+
+// awardSlot names the n-th award position on a work. Single-winner work only
+// ever uses slot 0.
+func awardSlot(workID string, n int) string {
+	return workID + "#" + strconv.Itoa(n)
 }
 
 func (s *Service) HandleGetContract(w http.ResponseWriter, r *http.Request) {
@@ -133,6 +372,30 @@ func (s *Service) HandleGetContract(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	c, err := s.store.Get(ctx, contractID)
+	if err == nil && c == nil && r.URL.Query().Get("include_archived") == "true" {
+		c, err = s.store.GetArchived(ctx, contractID)
+	}
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// HandleGetMyContract returns the contract for the caller's execution token,
+// so stateless provider workers can recover it from the token alone. Tokens
+// belonging to other parties are not included.
+func (s *Service) HandleGetMyContract(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	c, err := s.store.GetByExecutionTokenHash(r.Context(), hashToken(token))
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -141,6 +404,15 @@ func (s *Service) HandleGetContract(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
+	c.ConsumerToken = ""
+	if c.ExecutionToken != token {
+		c.ExecutionToken = ""
+	}
+	for i := range c.Parties {
+		if c.Parties[i].ExecutionToken != token {
+			c.Parties[i].ExecutionToken = ""
+		}
+	}
 	writeJSON(w, http.StatusOK, c)
 }
 
@@ -171,26 +443,47 @@ func (s *Service) HandleProgress(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	if c.ExecutionToken != token {
+	party := findParty(c, token)
+	if c.ExecutionToken != token && party == nil {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+	var partyID string
+	if party != nil {
+		partyID = party.PartyID
+	}
 
 	now := time.Now().UTC()
+	from := c.Status
 	c.ExecutionUpdates = append(c.ExecutionUpdates, model.ExecutionUpdate{
+		PartyID:   partyID,
 		Status:    req.Status,
 		Percent:   req.Percent,
 		Message:   req.Message,
 		Timestamp: now,
 	})
+	started := false
 	if c.Status == model.ContractStatusAwarded {
 		c.Status = model.ContractStatusExecuting
 		c.StartedAt = &now
+		started = true
 	}
-	if err := s.store.Update(ctx, *c); err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	crossed := s.crossedThresholds(c, req.Percent)
+	if err := s.store.UpdateIfStatus(ctx, *c, from); err != nil {
+		writeUpdateError(w, err)
 		return
 	}
+	if started {
+		s.notify(c, webhook.EventInProgress, map[string]any{"percent": 0})
+		s.publishStarted(c)
+	}
+	for _, t := range crossed {
+		s.notify(c, webhook.EventInProgress, map[string]any{"percent": t, "message": req.Message})
+	}
 	writeJSON(w, http.StatusOK, map[string]any{"acknowledged": true, "contract_id": contractID})
 }
 
@@ -221,12 +514,50 @@ func (s *Service) HandleComplete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
+	if party := findParty(c, token); party != nil {
+		s.completeParty(w, r, c, party)
+		return
+	}
 	if c.ExecutionToken != token {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
 	now := time.Now().UTC()
+	if !req.Success {
+		// Unsuccessful work is not paid for: the contract fails as the
+		// provider reported it, without a settlement.
+		c.Outcome = &model.OutcomeReport{
+			ResultSummary:  req.ResultSummary,
+			Metrics:        req.Metrics,
+			ResultLocation: req.ResultLocation,
+			ReportedAt:     now,
+		}
+		if err := s.fail(ctx, c, model.FailureReasonUnsuccessful, req.ResultSummary, "provider", now); err != nil {
+			writeUpdateError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"contract_id":    contractID,
+			"status":         c.Status,
+			"failure_reason": model.FailureReasonUnsuccessful,
+			"failed_at":      now,
+		})
+		return
+	}
+	for _, p := range c.Parties {
+		if p.Status != model.PartyStatusCompleted {
+			http.Error(w, "subcontracted steps are still pending", http.StatusConflict)
+			return
+		}
+	}
+
+	// The contract is completed before it is settled, so a completion
+	// racing this one, or a cancellation, cannot get it paid twice.
+	from := c.Status
 	c.Status = model.ContractStatusCompleted
 	c.CompletedAt = &now
 	c.Outcome = &model.OutcomeReport{
@@ -236,10 +567,20 @@ func (s *Service) HandleComplete(w http.ResponseWriter, r *http.Request) {
 		ResultLocation: req.ResultLocation,
 		ReportedAt:     now,
 	}
-	if err := s.store.Update(ctx, *c); err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	if err := s.store.UpdateIfStatus(ctx, *c, from); err != nil {
+		writeUpdateError(w, err)
 		return
 	}
+	if s.settle(ctx, c, model.SettlementKindCompletion, c.AgreedPrice, true, nil) != nil {
+		if err := s.store.Update(ctx, *c); err != nil {
+			log.Printf("settlement record update failed contract_id=%s: %v", c.ContractID, err)
+		}
+	}
+	s.notify(c, webhook.EventCompleted, map[string]any{
+		"success":        req.Success,
+		"result_summary": req.ResultSummary,
+	})
+	s.publishCompleted(c)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"contract_id":  contractID,
 		"status":       c.Status,
@@ -279,13 +620,18 @@ func (s *Service) HandleFail(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
 
 	now := time.Now().UTC()
-	c.Status = model.ContractStatusFailed
-	c.FailedAt = &now
-	c.FailureReason = &req.Reason
-	if err := s.store.Update(ctx, *c); err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	reportedBy := "provider"
+	if token == c.ConsumerToken {
+		reportedBy = "consumer"
+	}
+	if err := s.fail(ctx, c, req.Reason, req.Message, reportedBy, now); err != nil {
+		writeUpdateError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
@@ -296,6 +642,526 @@ func (s *Service) HandleFail(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// fail marks c failed as reportedBy, "provider" or "consumer", reported it.
+// A consumer's report contests the outcome, so it is sent to the webhook and
+// published as a dispute. It returns store.ErrStatusChanged when c left its
+// status since it was read.
+func (s *Service) fail(ctx context.Context, c *model.Contract, reason, message, reportedBy string, now time.Time) error {
+	from := c.Status
+	c.Status = model.ContractStatusFailed
+	c.FailedAt = &now
+	c.FailureReason = &reason
+	if err := s.store.UpdateIfStatus(ctx, *c, from); err != nil {
+		return err
+	}
+	if reportedBy == "consumer" {
+		s.notify(c, webhook.EventDisputed, map[string]any{"reason": reason, "message": message, "reported_by": reportedBy})
+		s.publishDisputed(c, reason, message, now)
+		return nil
+	}
+	s.notify(c, webhook.EventFailed, map[string]any{"failure_reason": reason, "reported_by": reportedBy})
+	s.publishFailed(c, reason, message, reportedBy)
+	return nil
+}
+
+// HandleCancel lets the consumer abandon an active contract. Cancelling
+// before the provider has started is free; once execution is under way the
+// provider is owed the configured cancellation fee. Either way the outcome is
+// attributed to the consumer for trust purposes.
+func (s *Service) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	contractID := pathParam(r.URL.Path, "/v1/contracts/", "/cancel")
+	if contractID == "" {
+		http.Error(w, "contract_id is required", http.StatusBadRequest)
+		return
+	}
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req model.CancelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.store.Get(ctx, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if c.ConsumerToken != token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+	var fee float64
+	if c.Status == model.ContractStatusExecuting {
+		fee = c.AgreedPrice * s.opts.CancellationFeePercent / 100
+	}
+	cancellation := &model.Cancellation{
+		Reason:      req.Reason,
+		Fee:         fee,
+		CancelledAt: now,
+	}
+
+	from := c.Status
+	c.Status = model.ContractStatusCancelled
+	c.Cancellation = cancellation
+	c.TrustOutcome = model.TrustOutcomeFailureConsumer
+	if err := s.store.UpdateIfStatus(ctx, *c, from); err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+	if fee > 0 {
+		rec := s.settle(ctx, c, model.SettlementKindCancellationFee, fee, false, map[string]interface{}{
+			"cancellation":   true,
+			"cancel_reason":  req.Reason,
+			"original_price": c.AgreedPrice,
+			"fee_percent":    s.opts.CancellationFeePercent,
+		})
+		cancellation.FeeSettled = rec != nil && rec.Status == model.SettlementStatusSettled
+		if rec != nil {
+			if err := s.store.Update(ctx, *c); err != nil {
+				log.Printf("settlement record update failed contract_id=%s: %v", c.ContractID, err)
+			}
+		}
+	}
+	s.notify(c, webhook.EventCancelled, map[string]any{"reason": req.Reason, "cancellation_fee": fee})
+	s.publishCancelled(c)
+	s.reportOutcome(ctx, c, map[string]any{"cancel_reason": req.Reason, "cancellation_fee": fee}, now)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"contract_id":      contractID,
+		"status":           c.Status,
+		"cancellation_fee": fee,
+		"fee_settled":      cancellation.FeeSettled,
+		"trust_outcome":    c.TrustOutcome,
+		"cancelled_at":     now,
+	})
+}
+
+// HandleProposeAmendment records a proposed change to price and/or deadline.
+// Either party may propose; the terms are unchanged until the other party
+// accepts via HandleAcceptAmendment.
+func (s *Service) HandleProposeAmendment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	contractID := pathParam(r.URL.Path, "/v1/contracts/", "/amendments")
+	if contractID == "" {
+		http.Error(w, "contract_id is required", http.StatusBadRequest)
+		return
+	}
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req model.AmendmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.AgreedPrice == nil && req.ExpiresAt == nil {
+		http.Error(w, "agreed_price or expires_at is required", http.StatusBadRequest)
+		return
+	}
+	if req.AgreedPrice != nil && *req.AgreedPrice < 0 {
+		http.Error(w, "agreed_price must be non-negative", http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.store.Get(ctx, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	party := partyForToken(c, token)
+	if party == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+	for _, a := range c.Amendments {
+		if a.Status == model.AmendmentStatusProposed {
+			http.Error(w, "an amendment is already pending", http.StatusConflict)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	amendment := model.Amendment{
+		AmendmentID: generateID("amend_"),
+		ProposedBy:  party,
+		AgreedPrice: req.AgreedPrice,
+		ExpiresAt:   req.ExpiresAt,
+		Reason:      req.Reason,
+		Status:      model.AmendmentStatusProposed,
+		ProposedAt:  now,
+	}
+	c.Amendments = append(c.Amendments, amendment)
+	if err := s.store.Update(ctx, *c); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, amendment)
+}
+
+// HandleAcceptAmendment applies a pending amendment proposed by the other
+// party. The previous terms stay in Revisions so the original agreement can
+// always be audited.
+func (s *Service) HandleAcceptAmendment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	contractID := pathParam(r.URL.Path, "/v1/contracts/", "/accept")
+	amendmentID := pathParam(r.URL.Path, "/v1/contracts/"+contractID+"/amendments/", "/accept")
+	if contractID == "" || amendmentID == "" {
+		http.Error(w, "contract_id and amendment_id are required", http.StatusBadRequest)
+		return
+	}
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	c, err := s.store.Get(ctx, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	party := partyForToken(c, token)
+	if party == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	idx := -1
+	for i := range c.Amendments {
+		if c.Amendments[i].AmendmentID == amendmentID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		http.Error(w, "amendment not found", http.StatusNotFound)
+		return
+	}
+	a := &c.Amendments[idx]
+	if a.Status != model.AmendmentStatusProposed {
+		http.Error(w, "amendment is not pending", http.StatusConflict)
+		return
+	}
+	if a.ProposedBy == party {
+		http.Error(w, "amendment must be accepted by the other party", http.StatusForbidden)
+		return
+	}
+	if c.Status != model.ContractStatusAwarded && c.Status != model.ContractStatusExecuting {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+	ensureRevisions(c)
+	if a.AgreedPrice != nil {
+		c.AgreedPrice = *a.AgreedPrice
+	}
+	if a.ExpiresAt != nil {
+		c.ExpiresAt = *a.ExpiresAt
+	}
+	c.Revision++
+	a.Status = model.AmendmentStatusAccepted
+	a.AcceptedAt = &now
+	a.Revision = c.Revision
+	c.Revisions = append(c.Revisions, model.ContractRevision{
+		Revision:    c.Revision,
+		AgreedPrice: c.AgreedPrice,
+		ExpiresAt:   c.ExpiresAt,
+		AmendmentID: a.AmendmentID,
+		EffectiveAt: now,
+	})
+	if err := s.store.Update(ctx, *c); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"contract_id":  contractID,
+		"amendment_id": amendmentID,
+		"revision":     c.Revision,
+		"agreed_price": c.AgreedPrice,
+		"expires_at":   c.ExpiresAt,
+	})
+}
+
+// settle forwards a charge to the settlement service, when one is configured,
+// and records the attempt on the contract so it shows up in the timeline.
+func (s *Service) settle(ctx context.Context, c *model.Contract, kind model.SettlementKind, amount float64, success bool, metadata map[string]interface{}) *model.SettlementRecord {
+	if s.settlement == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	startedAt := c.AwardedAt
+	if c.StartedAt != nil {
+		startedAt = *c.StartedAt
+	}
+	rec := model.SettlementRecord{
+		Kind:        kind,
+		Amount:      amount,
+		Status:      model.SettlementStatusSettled,
+		RequestedAt: now,
+	}
+	err := s.settlement.ProcessContractCompletion(ctx, clients.ContractCompletedEvent{
+		ContractID:   c.ContractID,
+		WorkID:       c.WorkID,
+		ConsumerID:   c.ConsumerID,
+		ProviderID:   c.ProviderID,
+		StartedAt:    startedAt,
+		CompletedAt:  now,
+		Success:      success,
+		AgreedPrice:  strconv.FormatFloat(amount, 'f', -1, 64),
+		Metadata:     metadata,
+		PayoutSplits: payoutSplits(c, kind),
+	})
+	if err != nil {
+		log.Printf("settlement failed contract_id=%s kind=%s: %v", c.ContractID, kind, err)
+		msg := err.Error()
+		rec.Status = model.SettlementStatusFailed
+		rec.Error = &msg
+	}
+	c.Settlements = append(c.Settlements, rec)
+	return &c.Settlements[len(c.Settlements)-1]
+}
+
+// notify sends a state-change notification to the consumer's callback_url,
+// if the work was submitted with one.
+func (s *Service) notify(c *model.Contract, typ webhook.EventType, data map[string]any) {
+	if c.CallbackURL == "" {
+		return
+	}
+	s.notifier.Notify(c.CallbackURL, webhook.Event{
+		EventID:    generateID("evt_"),
+		Type:       typ,
+		ContractID: c.ContractID,
+		WorkID:     c.WorkID,
+		Status:     string(c.Status),
+		Timestamp:  time.Now().UTC(),
+		Data:       data,
+	})
+}
+
+// crossedThresholds returns the progress thresholds reached for the first
+// time by percent, and marks them as notified on the contract.
+func (s *Service) crossedThresholds(c *model.Contract, percent *int) []int {
+	if percent == nil || c.CallbackURL == "" {
+		return nil
+	}
+	var out []int
+	for _, t := range s.opts.ProgressThresholds {
+		if *percent < t || slices.Contains(c.NotifiedThresholds, t) {
+			continue
+		}
+		c.NotifiedThresholds = append(c.NotifiedThresholds, t)
+		out = append(out, t)
+	}
+	return out
+}
+
+// HandleWebhookDeliveries reports the delivery status of callback
+// notifications sent for a contract, to the consumer or the provider.
+func (s *Service) HandleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	contractID := pathParam(r.URL.Path, "/v1/contracts/", "/webhooks")
+	if contractID == "" {
+		http.Error(w, "contract_id is required", http.StatusBadRequest)
+		return
+	}
+	c, ok := s.authorizeParty(w, r, contractID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"contract_id":  contractID,
+		"callback_url": c.CallbackURL,
+		"deliveries":   s.notifier.Deliveries(contractID),
+	})
+}
+
+// HandleTimeline returns everything that has happened to a contract in
+// chronological order, to the consumer or the provider.
+func (s *Service) HandleTimeline(w http.ResponseWriter, r *http.Request) {
+	contractID := pathParam(r.URL.Path, "/v1/contracts/", "/timeline")
+	if contractID == "" {
+		http.Error(w, "contract_id is required", http.StatusBadRequest)
+		return
+	}
+	c, ok := s.authorizeParty(w, r, contractID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, model.TimelineResponse{
+		ContractID: c.ContractID,
+		Status:     c.Status,
+		Events:     buildTimeline(c),
+	})
+}
+
+func buildTimeline(c *model.Contract) []model.TimelineEvent {
+	events := []model.TimelineEvent{{
+		Type:      model.TimelineAwarded,
+		Timestamp: c.AwardedAt,
+		Details: map[string]any{
+			"provider_id":  c.ProviderID,
+			"bid_id":       c.BidID,
+			"agreed_price": originalPrice(c),
+		},
+	}}
+	for _, u := range c.ExecutionUpdates {
+		events = append(events, model.TimelineEvent{
+			Type:      model.TimelineProgress,
+			Timestamp: u.Timestamp,
+			Status:    u.Status,
+			Percent:   u.Percent,
+			Message:   u.Message,
+		})
+	}
+	for _, a := range c.Amendments {
+		events = append(events, model.TimelineEvent{
+			Type:      model.TimelineAmendmentProposed,
+			Timestamp: a.ProposedAt,
+			Details:   map[string]any{"amendment_id": a.AmendmentID, "proposed_by": a.ProposedBy},
+		})
+		if a.AcceptedAt != nil {
+			events = append(events, model.TimelineEvent{
+				Type:      model.TimelineAmendmentAccepted,
+				Timestamp: *a.AcceptedAt,
+				Details:   map[string]any{"amendment_id": a.AmendmentID, "revision": a.Revision},
+			})
+		}
+	}
+	for _, x := range c.Extensions {
+		events = append(events, model.TimelineEvent{
+			Type:      model.TimelineExtensionRequested,
+			Timestamp: x.RequestedAt,
+			Details:   map[string]any{"extension_id": x.ExtensionID, "new_deadline": x.NewDeadline, "reason": x.Reason},
+		})
+		if x.DecidedAt != nil {
+			typ := model.TimelineExtensionDenied
+			details := map[string]any{"extension_id": x.ExtensionID}
+			if x.DecisionReason != "" {
+				details["reason"] = x.DecisionReason
+			}
+			if x.Status == model.ExtensionStatusApproved {
+				typ = model.TimelineExtensionApproved
+				details["revision"] = x.Revision
+			}
+			events = append(events, model.TimelineEvent{
+				Type:      typ,
+				Timestamp: *x.DecidedAt,
+				Details:   details,
+			})
+		}
+	}
+	if c.CompletedAt != nil {
+		ev := model.TimelineEvent{Type: model.TimelineCompleted, Timestamp: *c.CompletedAt}
+		if c.Outcome != nil {
+			ev.Details = map[string]any{"success": c.Outcome.Success, "result_summary": c.Outcome.ResultSummary}
+		}
+		events = append(events, ev)
+	}
+	if c.FailedAt != nil {
+		ev := model.TimelineEvent{Type: model.TimelineFailed, Timestamp: *c.FailedAt}
+		if c.FailureReason != nil {
+			ev.Details = map[string]any{"failure_reason": *c.FailureReason}
+		}
+		events = append(events, ev)
+	}
+	if c.Cancellation != nil {
+		events = append(events, model.TimelineEvent{
+			Type:      model.TimelineCancelled,
+			Timestamp: c.Cancellation.CancelledAt,
+			Details:   map[string]any{"reason": c.Cancellation.Reason, "fee": c.Cancellation.Fee},
+		})
+	}
+	for _, rec := range c.Settlements {
+		details := map[string]any{"kind": rec.Kind, "amount": rec.Amount, "status": rec.Status}
+		if rec.Error != nil {
+			details["error"] = *rec.Error
+		}
+		events = append(events, model.TimelineEvent{
+			Type:      model.TimelineSettlement,
+			Timestamp: rec.RequestedAt,
+			Details:   details,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	return events
+}
+
+// ensureRevisions records the award-time terms as revision 1 on contracts
+// awarded before revisions were tracked.
+func ensureRevisions(c *model.Contract) {
+	if len(c.Revisions) > 0 {
+		return
+	}
+	c.Revision = 1
+	c.Revisions = []model.ContractRevision{{
+		Revision:    1,
+		AgreedPrice: c.AgreedPrice,
+		ExpiresAt:   c.ExpiresAt,
+		EffectiveAt: c.AwardedAt,
+	}}
+}
+
+// originalPrice is the price agreed at award time, before any amendments.
+func originalPrice(c *model.Contract) float64 {
+	if len(c.Revisions) > 0 {
+		return c.Revisions[0].AgreedPrice
+	}
+	return c.AgreedPrice
+}
+
+// partyForToken reports which side of the contract a bearer token belongs to.
+func partyForToken(c *model.Contract, token string) string {
+	switch token {
+	case c.ConsumerToken:
+		return "consumer"
+	case c.ExecutionToken:
+		return "provider"
+	default:
+		return ""
+	}
+}
+
+// writeUpdateError answers a failed contract update: 409 when the contract
+// changed status under the request, 500 otherwise.
+func writeUpdateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrStatusChanged) {
+		http.Error(w, "contract is not active", http.StatusConflict)
+		return
+	}
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
 func decodeJSON(r *http.Request, v any) error {
 	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
 	if err != nil {
@@ -319,6 +1185,17 @@ func bearerToken(r *http.Request) string {
 	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
 }
 
+// newExecutionToken returns a fresh execution token and its lookup hash.
+func newExecutionToken() (string, string) {
+	token := generateID("exec_")
+	return token, hashToken(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func generateID(prefix string) string {
 	var b [16]byte
 	_, _ = rand.Read(b[:])
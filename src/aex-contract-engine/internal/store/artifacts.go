@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
+)
+
+type memoryArtifact struct {
+	meta model.Artifact
+	data []byte
+}
+
+type MemoryArtifactStore struct {
+	mu         sync.RWMutex
+	byContract map[string]map[string]memoryArtifact
+}
+
+func NewMemoryArtifactStore() *MemoryArtifactStore {
+	return &MemoryArtifactStore{byContract: map[string]map[string]memoryArtifact{}}
+}
+
+func (s *MemoryArtifactStore) PutArtifact(ctx context.Context, a model.Artifact, data []byte) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byContract[a.ContractID] == nil {
+		s.byContract[a.ContractID] = map[string]memoryArtifact{}
+	}
+	s.byContract[a.ContractID][a.ArtifactID] = memoryArtifact{meta: a, data: append([]byte(nil), data...)}
+	return nil
+}
+
+func (s *MemoryArtifactStore) GetArtifact(ctx context.Context, contractID, artifactID string) (*model.Artifact, []byte, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.byContract[contractID][artifactID]
+	if !ok {
+		return nil, nil, nil
+	}
+	meta := a.meta
+	return &meta, a.data, nil
+}
+
+func (s *MemoryArtifactStore) ListArtifacts(ctx context.Context, contractID string) ([]model.Artifact, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.Artifact, 0, len(s.byContract[contractID]))
+	for _, a := range s.byContract[contractID] {
+		out = append(out, a.meta)
+	}
+	sortArtifacts(out)
+	return out, nil
+}
+
+// FileArtifactStore keeps artifacts on a local or mounted filesystem as
+// <dir>/<contract_id>/<artifact_id>.bin with a JSON metadata sidecar.
+type FileArtifactStore struct {
+	dir string
+}
+
+func NewFileArtifactStore(dir string) (*FileArtifactStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileArtifactStore{dir: dir}, nil
+}
+
+func (s *FileArtifactStore) PutArtifact(ctx context.Context, a model.Artifact, data []byte) error {
+	_ = ctx
+	cdir := filepath.Join(s.dir, filepath.Base(a.ContractID))
+	if err := os.MkdirAll(cdir, 0o750); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	base := filepath.Join(cdir, filepath.Base(a.ArtifactID))
+	if err := os.WriteFile(base+".bin", data, 0o640); err != nil {
+		return err
+	}
+	// Metadata is written last so a listed artifact always has its content.
+	return os.WriteFile(base+".json", meta, 0o640)
+}
+
+func (s *FileArtifactStore) GetArtifact(ctx context.Context, contractID, artifactID string) (*model.Artifact, []byte, error) {
+	_ = ctx
+	base := filepath.Join(s.dir, filepath.Base(contractID), filepath.Base(artifactID))
+	raw, err := os.ReadFile(base + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var meta model.Artifact
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(base + ".bin")
+	if err != nil {
+		return nil, nil, err
+	}
+	return &meta, data, nil
+}
+
+func (s *FileArtifactStore) ListArtifacts(ctx context.Context, contractID string) ([]model.Artifact, error) {
+	_ = ctx
+	matches, err := filepath.Glob(filepath.Join(s.dir, filepath.Base(contractID), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.Artifact, 0, len(matches))
+	for _, m := range matches {
+		raw, err := os.ReadFile(m)
+		if err != nil {
+			return nil, err
+		}
+		var meta model.Artifact
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, err
+		}
+		out = append(out, meta)
+	}
+	sortArtifacts(out)
+	return out, nil
+}
+
+func sortArtifacts(out []model.Artifact) {
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].UploadedAt.Equal(out[j].UploadedAt) {
+			return out[i].UploadedAt.Before(out[j].UploadedAt)
+		}
+		return out[i].ArtifactID < out[j].ArtifactID
+	})
+}
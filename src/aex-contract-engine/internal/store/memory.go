@@ -3,27 +3,94 @@ package store
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
 )
 
 type MemoryContractStore struct {
-	mu   sync.RWMutex
-	byID map[string]model.Contract
+	mu       sync.RWMutex
+	byID     map[string]model.Contract
+	bySlot   map[string]string
+	archived map[string]model.Contract
 }
 
 func NewMemoryContractStore() *MemoryContractStore {
-	return &MemoryContractStore{byID: map[string]model.Contract{}}
+	return &MemoryContractStore{
+		byID:     map[string]model.Contract{},
+		bySlot:   map[string]string{},
+		archived: map[string]model.Contract{},
+	}
 }
 
 func (s *MemoryContractStore) Save(ctx context.Context, c model.Contract) error {
 	_ = ctx
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if c.AwardSlot != "" {
+		if id, ok := s.bySlot[c.AwardSlot]; ok && id != c.ContractID {
+			return ErrAwardSlotTaken
+		}
+		s.bySlot[c.AwardSlot] = c.ContractID
+	}
 	s.byID[c.ContractID] = c
 	return nil
 }
 
+func (s *MemoryContractStore) SaveAll(ctx context.Context, cs []model.Contract) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := map[string]bool{}
+	for _, c := range cs {
+		if c.AwardSlot == "" {
+			continue
+		}
+		if _, ok := s.bySlot[c.AwardSlot]; ok || seen[c.AwardSlot] {
+			return ErrAwardSlotTaken
+		}
+		seen[c.AwardSlot] = true
+	}
+	for _, c := range cs {
+		if c.AwardSlot != "" {
+			s.bySlot[c.AwardSlot] = c.ContractID
+		}
+		s.byID[c.ContractID] = c
+	}
+	return nil
+}
+
+func (s *MemoryContractStore) GetByAwardSlot(ctx context.Context, slot string) (*model.Contract, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.bySlot[slot]
+	if !ok {
+		return nil, nil
+	}
+	out := s.byID[id]
+	return &out, nil
+}
+
+func (s *MemoryContractStore) GetByExecutionTokenHash(ctx context.Context, hash string) (*model.Contract, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, c := range s.byID {
+		if c.ExecutionTokenHash == hash {
+			out := c
+			return &out, nil
+		}
+		for _, p := range c.Parties {
+			if p.ExecutionTokenHash == hash {
+				out := c
+				return &out, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
 func (s *MemoryContractStore) Get(ctx context.Context, contractID string) (*model.Contract, error) {
 	_ = ctx
 	s.mu.RLock()
@@ -36,6 +103,116 @@ func (s *MemoryContractStore) Get(ctx context.Context, contractID string) (*mode
 	return &out, nil
 }
 
+func (s *MemoryContractStore) ListStartOverdue(ctx context.Context, before time.Time) ([]model.Contract, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []model.Contract
+	for _, c := range s.byID {
+		if c.Status == model.ContractStatusAwarded && c.StartDeadline != nil && !c.StartDeadline.After(before) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryContractStore) CountActiveByProvider(ctx context.Context, providerID string) (int, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, c := range s.byID {
+		if c.ProviderID == providerID && (c.Status == model.ContractStatusAwarded || c.Status == model.ContractStatusExecuting) {
+			n++
+		}
+	}
+	return n, nil
+}
+
 func (s *MemoryContractStore) Update(ctx context.Context, c model.Contract) error {
-	return s.Save(ctx, c)
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.update(c)
+	return nil
+}
+
+func (s *MemoryContractStore) UpdateIfStatus(ctx context.Context, c model.Contract, from model.ContractStatus) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.byID[c.ContractID]; !ok || cur.Status != from {
+		return ErrStatusChanged
+	}
+	s.update(c)
+	return nil
+}
+
+func (s *MemoryContractStore) update(c model.Contract) {
+	if c.Status.IsTerminal() && c.AwardSlot != "" {
+		if s.bySlot[c.AwardSlot] == c.ContractID {
+			delete(s.bySlot, c.AwardSlot)
+		}
+		c.AwardSlot = ""
+	}
+	s.byID[c.ContractID] = c
+}
+
+func (s *MemoryContractStore) Archive(ctx context.Context, before time.Time) (int, error) {
+	_ = ctx
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, c := range s.byID {
+		closedAt := c.ClosedAt()
+		if closedAt == nil || closedAt.After(before) {
+			continue
+		}
+		c.ArchivedAt = &now
+		s.archived[id] = c
+		delete(s.byID, id)
+		n++
+	}
+	return n, nil
+}
+
+func (s *MemoryContractStore) GetArchived(ctx context.Context, contractID string) (*model.Contract, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.archived[contractID]
+	if !ok {
+		return nil, nil
+	}
+	out := c
+	return &out, nil
+}
+
+type MemoryAwardPolicyStore struct {
+	mu         sync.RWMutex
+	byConsumer map[string]model.ConsumerAwardPolicy
+}
+
+func NewMemoryAwardPolicyStore() *MemoryAwardPolicyStore {
+	return &MemoryAwardPolicyStore{byConsumer: map[string]model.ConsumerAwardPolicy{}}
+}
+
+func (s *MemoryAwardPolicyStore) GetPolicy(ctx context.Context, consumerID string) (*model.ConsumerAwardPolicy, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.byConsumer[consumerID]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (s *MemoryAwardPolicyStore) PutPolicy(ctx context.Context, p model.ConsumerAwardPolicy) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byConsumer[p.ConsumerID] = p
+	return nil
 }
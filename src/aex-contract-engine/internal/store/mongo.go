@@ -11,15 +11,39 @@ import (
 )
 
 type MongoContractStore struct {
-	coll *mongo.Collection
+	coll    *mongo.Collection
+	archive *mongo.Collection
 }
 
-func NewMongoContractStore(client *mongo.Client, dbName string, collName string) *MongoContractStore {
-	return &MongoContractStore{coll: client.Database(dbName).Collection(collName)}
+// NewMongoContractStore keeps active and recently closed contracts in
+// collName and moves contracts past their retention window to archiveName.
+func NewMongoContractStore(client *mongo.Client, dbName, collName, archiveName string) *MongoContractStore {
+	db := client.Database(dbName)
+	return &MongoContractStore{coll: db.Collection(collName), archive: db.Collection(archiveName)}
 }
 
 func (s *MongoContractStore) EnsureIndexes(ctx context.Context) error {
-	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "contract_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// award_slot is only present while a contract is active, so the
+			// partial unique index allows re-awarding once it terminates.
+			Keys: bson.D{{Key: "award_slot", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"award_slot": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "execution_token_hash", Value: 1}}},
+		{Keys: bson.D{{Key: "parties.execution_token_hash", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "provider_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.archive.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys:    bson.D{{Key: "contract_id", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	})
@@ -30,9 +54,77 @@ func (s *MongoContractStore) Save(ctx context.Context, c model.Contract) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	_, err := s.coll.InsertOne(ctx, c)
+	if mongo.IsDuplicateKeyError(err) && c.AwardSlot != "" {
+		return ErrAwardSlotTaken
+	}
+	return err
+}
+
+// SaveAll inserts the contracts in order. Standalone deployments have no
+// multi-document transactions, so a failed batch is rolled back by deleting
+// whatever was inserted before the failure.
+func (s *MongoContractStore) SaveAll(ctx context.Context, cs []model.Contract) error {
+	if len(cs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	docs := make([]interface{}, 0, len(cs))
+	ids := make([]string, 0, len(cs))
+	for _, c := range cs {
+		docs = append(docs, c)
+		ids = append(ids, c.ContractID)
+	}
+	_, err := s.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(true))
+	if err == nil {
+		return nil
+	}
+	if _, derr := s.coll.DeleteMany(ctx, bson.M{"contract_id": bson.M{"$in": ids}}); derr != nil {
+		return derr
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAwardSlotTaken
+	}
 	return err
 }
 
+func (s *MongoContractStore) GetByAwardSlot(ctx context.Context, slot string) (*model.Contract, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.coll.FindOne(ctx, bson.M{"award_slot": slot})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var c model.Contract
+	if err := res.Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *MongoContractStore) GetByExecutionTokenHash(ctx context.Context, hash string) (*model.Contract, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.coll.FindOne(ctx, bson.M{"$or": bson.A{
+		bson.M{"execution_token_hash": hash},
+		bson.M{"parties.execution_token_hash": hash},
+	}})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var c model.Contract
+	if err := res.Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
 func (s *MongoContractStore) Get(ctx context.Context, contractID string) (*model.Contract, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
@@ -50,9 +142,155 @@ func (s *MongoContractStore) Get(ctx context.Context, contractID string) (*model
 	return &c, nil
 }
 
+func (s *MongoContractStore) ListStartOverdue(ctx context.Context, before time.Time) ([]model.Contract, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	cur, err := s.coll.Find(ctx, bson.M{
+		"status":         model.ContractStatusAwarded,
+		"start_deadline": bson.M{"$lte": before},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []model.Contract
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *MongoContractStore) CountActiveByProvider(ctx context.Context, providerID string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	n, err := s.coll.CountDocuments(ctx, bson.M{
+		"provider_id": providerID,
+		"status":      bson.M{"$in": bson.A{model.ContractStatusAwarded, model.ContractStatusExecuting}},
+	})
+	return int(n), err
+}
+
 func (s *MongoContractStore) Update(ctx context.Context, c model.Contract) error {
+	if c.Status.IsTerminal() {
+		c.AwardSlot = ""
+	}
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	_, err := s.coll.ReplaceOne(ctx, bson.M{"contract_id": c.ContractID}, c, options.Replace().SetUpsert(false))
 	return err
 }
+
+func (s *MongoContractStore) UpdateIfStatus(ctx context.Context, c model.Contract, from model.ContractStatus) error {
+	if c.Status.IsTerminal() {
+		c.AwardSlot = ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res, err := s.coll.ReplaceOne(ctx, bson.M{"contract_id": c.ContractID, "status": from}, c, options.Replace().SetUpsert(false))
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrStatusChanged
+	}
+	return nil
+}
+
+// archiveBatchSize bounds how many contracts one Archive call moves, so a
+// large backlog is drained over several runs instead of one long operation.
+const archiveBatchSize = 500
+
+// Archive copies closed contracts into the archive collection before deleting
+// them from the hot one. The copy is an upsert, so a run interrupted between
+// the two steps is completed by the next one.
+func (s *MongoContractStore) Archive(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cur, err := s.coll.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"status": model.ContractStatusCompleted, "completed_at": bson.M{"$lte": before}},
+		bson.M{"status": model.ContractStatusFailed, "failed_at": bson.M{"$lte": before}},
+		bson.M{"status": model.ContractStatusCancelled, "cancellation.cancelled_at": bson.M{"$lte": before}},
+		bson.M{"status": model.ContractStatusExpired, "expires_at": bson.M{"$lte": before}},
+	}}, options.Find().SetLimit(archiveBatchSize))
+	if err != nil {
+		return 0, err
+	}
+	var batch []model.Contract
+	if err := cur.All(ctx, &batch); err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now().UTC()
+	ids := make([]string, 0, len(batch))
+	for _, c := range batch {
+		c.ArchivedAt = &now
+		if _, err := s.archive.ReplaceOne(ctx, bson.M{"contract_id": c.ContractID}, c, options.Replace().SetUpsert(true)); err != nil {
+			return 0, err
+		}
+		ids = append(ids, c.ContractID)
+	}
+	res, err := s.coll.DeleteMany(ctx, bson.M{"contract_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+func (s *MongoContractStore) GetArchived(ctx context.Context, contractID string) (*model.Contract, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.archive.FindOne(ctx, bson.M{"contract_id": contractID})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var c model.Contract
+	if err := res.Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+type MongoAwardPolicyStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAwardPolicyStore(client *mongo.Client, dbName string, collName string) *MongoAwardPolicyStore {
+	return &MongoAwardPolicyStore{coll: client.Database(dbName).Collection(collName)}
+}
+
+func (s *MongoAwardPolicyStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "consumer_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (s *MongoAwardPolicyStore) GetPolicy(ctx context.Context, consumerID string) (*model.ConsumerAwardPolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.coll.FindOne(ctx, bson.M{"consumer_id": consumerID})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var p model.ConsumerAwardPolicy
+	if err := res.Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *MongoAwardPolicyStore) PutPolicy(ctx context.Context, p model.ConsumerAwardPolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"consumer_id": p.ConsumerID}, p, options.Replace().SetUpsert(true))
+	return err
+}
@@ -2,12 +2,61 @@ package store
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/model"
 )
 
+// ErrAwardSlotTaken is returned by Save when another active contract already
+// holds the contract's award slot.
+var ErrAwardSlotTaken = errors.New("award slot already has an active contract")
+
+// ErrStatusChanged is returned by UpdateIfStatus when the stored contract is
+// no longer in the status the caller read it in.
+var ErrStatusChanged = errors.New("contract status changed")
+
+// ContractStore persists contracts. Implementations must guarantee that at
+// most one contract holds a given non-empty AwardSlot, and must release the
+// slot when a contract is updated into a terminal status.
 type ContractStore interface {
 	Save(ctx context.Context, c model.Contract) error
+	// SaveAll saves every contract or none of them; it returns
+	// ErrAwardSlotTaken if any award slot is already held.
+	SaveAll(ctx context.Context, cs []model.Contract) error
 	Get(ctx context.Context, contractID string) (*model.Contract, error)
+	GetByAwardSlot(ctx context.Context, slot string) (*model.Contract, error)
+	// GetByExecutionTokenHash finds the contract whose primary provider or
+	// one of whose parties holds the execution token with the given hash.
+	GetByExecutionTokenHash(ctx context.Context, hash string) (*model.Contract, error)
 	Update(ctx context.Context, c model.Contract) error
+	// UpdateIfStatus updates c only while the stored contract is still in
+	// status from, and returns ErrStatusChanged otherwise, so concurrent
+	// transitions out of one status cannot both succeed.
+	UpdateIfStatus(ctx context.Context, c model.Contract, from model.ContractStatus) error
+	// ListStartOverdue returns AWARDED contracts whose start deadline is at
+	// or before the given time.
+	ListStartOverdue(ctx context.Context, before time.Time) ([]model.Contract, error)
+	// CountActiveByProvider counts the AWARDED and EXECUTING contracts whose
+	// primary provider is providerID.
+	CountActiveByProvider(ctx context.Context, providerID string) (int, error)
+	// Archive moves terminal contracts closed at or before the given time out
+	// of the hot store and returns how many were moved.
+	Archive(ctx context.Context, before time.Time) (int, error)
+	// GetArchived returns an archived contract, or nil, nil if there is none.
+	GetArchived(ctx context.Context, contractID string) (*model.Contract, error)
+}
+
+// AwardPolicyStore holds per-consumer auto-award policies.
+type AwardPolicyStore interface {
+	GetPolicy(ctx context.Context, consumerID string) (*model.ConsumerAwardPolicy, error)
+	PutPolicy(ctx context.Context, p model.ConsumerAwardPolicy) error
+}
+
+// ArtifactStore holds result artifacts. GetArtifact returns nil, nil, nil
+// when the artifact does not exist.
+type ArtifactStore interface {
+	PutArtifact(ctx context.Context, a model.Artifact, data []byte) error
+	GetArtifact(ctx context.Context, contractID, artifactID string) (*model.Artifact, []byte, error)
+	ListArtifacts(ctx context.Context, contractID string) ([]model.Artifact, error)
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/httpapi"
 	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/service"
 	"github.com/parlakisik/agent-exchange/aex-contract-engine/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/serviceauth"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -21,6 +22,7 @@ func main() {
 	cfg := config.Load()
 
 	var st store.ContractStore
+	var policies store.AwardPolicyStore
 	var mongoClient *mongo.Client
 	if cfg.MongoURI != "" {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -30,22 +32,76 @@ func main() {
 			log.Fatal(err)
 		}
 		mongoClient = c
-		ms := store.NewMongoContractStore(c, cfg.MongoDatabase, cfg.MongoCollection)
+		ms := store.NewMongoContractStore(c, cfg.MongoDatabase, cfg.MongoCollection, cfg.MongoCollectionArchive)
 		if err := ms.EnsureIndexes(ctx); err != nil {
 			log.Printf("mongo index creation failed: %v", err)
 		}
 		st = ms
+		ps := store.NewMongoAwardPolicyStore(c, cfg.MongoDatabase, cfg.MongoCollectionAwardPolicies)
+		if err := ps.EnsureIndexes(ctx); err != nil {
+			log.Printf("mongo award policy index creation failed: %v", err)
+		}
+		policies = ps
 		log.Printf("mongo enabled uri=%s db=%s collection=%s", cfg.MongoURI, cfg.MongoDatabase, cfg.MongoCollection)
 	} else {
 		st = store.NewMemoryContractStore()
+		policies = store.NewMemoryAwardPolicyStore()
 		log.Printf("mongo disabled (set MONGO_URI to enable)")
 	}
 
-	svc, err := service.New(st, cfg.BidGatewayURL)
+	var artifacts store.ArtifactStore
+	if cfg.ArtifactDir != "" {
+		fs, err := store.NewFileArtifactStore(cfg.ArtifactDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		artifacts = fs
+		log.Printf("artifact storage dir=%s", cfg.ArtifactDir)
+	} else {
+		artifacts = store.NewMemoryArtifactStore()
+	}
+
+	var serviceTokens *serviceauth.TokenSource
+	if cfg.IdentityURL != "" && cfg.ServiceAccountID != "" && cfg.ServiceAccountSecret != "" {
+		serviceTokens = serviceauth.NewTokenSource(cfg.IdentityURL, cfg.ServiceAccountID, cfg.ServiceAccountSecret)
+		log.Printf("service account tokens enabled service_account_id=%s", cfg.ServiceAccountID)
+	}
+
+	svc, err := service.NewWithOptions(st, cfg.BidGatewayURL, service.Options{
+		SettlementURL:          cfg.SettlementURL,
+		CancellationFeePercent: cfg.CancellationFeePercent,
+		WorkPublisherURL:       cfg.WorkPublisherURL,
+		WebhookSecret:          cfg.WebhookSecret,
+		WebhookMaxAttempts:     cfg.WebhookMaxAttempts,
+		WebhookBackoff:         cfg.WebhookBackoff,
+		ProgressThresholds:     cfg.ProgressThresholds,
+		BidEvaluatorURL:        cfg.BidEvaluatorURL,
+		ProviderRegistryURL:    cfg.ProviderRegistryURL,
+		PolicyStore:            policies,
+		StartDeadline:          cfg.StartDeadline,
+		ReawardOnNoShow:        cfg.ReawardOnNoShow,
+		TrustBrokerURL:         cfg.TrustBrokerURL,
+		TrustBrokerToken:       cfg.TrustBrokerToken,
+		ServiceTokens:          serviceTokens,
+		ArtifactStore:          artifacts,
+		MaxArtifactBytes:       cfg.MaxArtifactBytes,
+		EventsURL:              cfg.EventsURL,
+		EventsSecret:           cfg.EventsSecret,
+		MaxExtensions:          cfg.MaxExtensions,
+		MaxExtension:           cfg.MaxExtension,
+		Retention:              cfg.Retention,
+		AdminToken:             cfg.AdminToken,
+		GatewayToken:           cfg.GatewayToken,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	go svc.RunNoShowMonitor(monitorCtx, cfg.NoShowCheckInterval)
+	go svc.RunArchiver(monitorCtx, cfg.ArchiveInterval)
+
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      httpapi.NewRouter(svc),
@@ -64,6 +120,7 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
+	stopMonitor()
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
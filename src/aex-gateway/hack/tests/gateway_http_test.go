@@ -1,9 +1,21 @@
 package tests
 
 import (
+	"compress/flate"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -101,6 +113,61 @@ func TestInfoEndpoint(t *testing.T) {
 	}
 }
 
+func TestSystemHealth(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	get := func(cfg *config.Config) (int, map[string]any) {
+		t.Helper()
+		ts := httptest.NewServer(httpapi.NewRouter(cfg))
+		defer ts.Close()
+		resp, err := http.Get(ts.URL + "/v1/system/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var result map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, result
+	}
+
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		WorkPublisherURL:   healthy.URL,
+		SettlementURL:      healthy.URL,
+		ContractEngineURL:  failing.URL,
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		RequestTimeout:     30 * time.Second,
+	}
+	status, result := get(cfg)
+	if status != http.StatusServiceUnavailable || result["status"] != "degraded" {
+		t.Fatalf("expected 503 degraded, got %d %v", status, result)
+	}
+	services, _ := result["services"].([]any)
+	if len(services) != 3 {
+		t.Fatalf("expected 3 services, got %v", result["services"])
+	}
+	first, _ := services[0].(map[string]any)
+	if first["name"] != "contract-engine" || first["status"] != "unhealthy" {
+		t.Fatalf("expected contract-engine unhealthy, got %v", first)
+	}
+
+	cfg.ContractEngineURL = healthy.URL
+	if status, result := get(cfg); status != http.StatusOK || result["status"] != "healthy" {
+		t.Fatalf("expected 200 healthy, got %d %v", status, result)
+	}
+}
+
 func TestAuthRequiredForAPI(t *testing.T) {
 	cfg := &config.Config{
 		Port:               "8080",
@@ -182,6 +249,59 @@ func TestAuthWithAPIKey(t *testing.T) {
 	}
 }
 
+func TestTelemetryRoutingCarriesGatewayToken(t *testing.T) {
+	var seen http.Header
+	telemetry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"logs":[]}`))
+	}))
+	defer telemetry.Close()
+
+	get := func(upstreamToken string) int {
+		t.Helper()
+		cfg := &config.Config{
+			Port:               "8080",
+			Environment:        "test",
+			TelemetryURL:       telemetry.URL,
+			UpstreamToken:      upstreamToken,
+			RateLimitPerMinute: 1000,
+			RateLimitBurstSize: 50,
+			RequestTimeout:     30 * time.Second,
+		}
+		ts := httptest.NewServer(httpapi.NewRouter(cfg))
+		defer ts.Close()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/logs", nil)
+		req.Header.Set("X-API-Key", "dev-api-key")
+		req.Header.Set("X-Tenant-ID", "tenant_other")
+		req.Header.Set("X-Gateway-Token", "forged")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get("gw_secret"); status != http.StatusOK {
+		t.Fatalf("expected 200 from telemetry, got %d", status)
+	}
+	if seen.Get("X-Gateway-Token") != "gw_secret" {
+		t.Fatalf("expected the gateway's upstream token, got %q", seen.Get("X-Gateway-Token"))
+	}
+	if tenant := seen.Get("X-Tenant-ID"); tenant == "" || tenant == "tenant_other" {
+		t.Fatalf("expected the key's tenant, got %q", tenant)
+	}
+
+	// A client's own token never reaches the upstream.
+	if status := get(""); status != http.StatusOK {
+		t.Fatalf("expected 200 from telemetry, got %d", status)
+	}
+	if seen.Get("X-Gateway-Token") != "" {
+		t.Fatalf("expected the client's gateway token to be dropped, got %q", seen.Get("X-Gateway-Token"))
+	}
+}
+
 func TestInvalidAPIKey(t *testing.T) {
 	// Start a mock upstream (needed because auth happens before proxy)
 	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -216,6 +336,98 @@ func TestInvalidAPIKey(t *testing.T) {
 	}
 }
 
+func TestScopeAuthorization(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	scopes := map[string][]string{
+		"reader-key": {"work:read", "contracts:write"},
+		"bids-key":   {"bids:*"},
+		"write-key":  {"write"},
+		"admin-key":  {"*"},
+		"manage-key": {"contracts:manage"},
+	}
+	// Grants, when the identity service sends them, decide over scopes.
+	grants := map[string][]map[string]any{
+		"manage-key": {{"resource": "contracts", "actions": []string{"read", "write"}}},
+	}
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			APIKey string `json:"api_key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		granted, ok := scopes[req.APIKey]
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"tenant_id": "tenant_" + req.APIKey,
+			"scopes":    granted,
+			"grants":    grants[req.APIKey],
+		})
+	}))
+	defer identity.Close()
+
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		WorkPublisherURL:   upstream.URL,
+		BidGatewayURL:      upstream.URL,
+		ContractEngineURL:  upstream.URL,
+		IdentityURL:        identity.URL,
+		APIKeyValidator:    "identity",
+		APIKeyCacheTTL:     time.Minute,
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		RequestTimeout:     30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		key, method, path string
+		want              int
+	}{
+		{"reader-key", http.MethodGet, "/v1/work/work_1", http.StatusOK},
+		{"reader-key", http.MethodPost, "/v1/work/work_1/cancel", http.StatusForbidden},
+		{"reader-key", http.MethodGet, "/v2/work/work_1", http.StatusOK},
+		{"reader-key", http.MethodPost, "/v2/work/work_1/cancel", http.StatusForbidden},
+		{"reader-key", http.MethodGet, "/v1/contracts/contract_1", http.StatusOK},
+		{"reader-key", http.MethodPost, "/v1/bids", http.StatusForbidden},
+		{"bids-key", http.MethodPost, "/v1/bids", http.StatusOK},
+		{"bids-key", http.MethodGet, "/v1/work/work_1", http.StatusForbidden},
+		{"write-key", http.MethodGet, "/v1/contracts/contract_1", http.StatusOK},
+		{"write-key", http.MethodPost, "/v1/bids", http.StatusOK},
+		{"admin-key", http.MethodPost, "/v1/work/work_1/cancel", http.StatusOK},
+		{"manage-key", http.MethodPost, "/v1/contracts/contract_1/cancel", http.StatusOK},
+		{"manage-key", http.MethodGet, "/v1/contracts/contract_1", http.StatusOK},
+		{"manage-key", http.MethodGet, "/v1/work/work_1", http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest(tc.method, ts.URL+tc.path, nil)
+		req.Header.Set("X-API-Key", tc.key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s %s with %s: expected %d, got %d", tc.method, tc.path, tc.key, tc.want, resp.StatusCode)
+		}
+		if tc.want == http.StatusForbidden {
+			if errBody, _ := result["error"].(map[string]any); errBody["code"] != "insufficient_scope" {
+				t.Fatalf("expected insufficient_scope, got %v", result)
+			}
+		}
+	}
+}
+
 func TestRateLimiting(t *testing.T) {
 	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -258,6 +470,1595 @@ func TestRateLimiting(t *testing.T) {
 	}
 }
 
+func TestAPIKeyRestrictions(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	restrictions := map[string]map[string]any{
+		"bids-key":   {"allowed_routes": []string{"/v1/bids"}},
+		"office-key": {"allowed_cidrs": []string{"10.0.0.0/8"}},
+	}
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			APIKey string `json:"api_key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		body, ok := restrictions[req.APIKey]
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body["tenant_id"] = "tenant_" + req.APIKey
+		body["scopes"] = []string{"*"}
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	defer identity.Close()
+
+	newGateway := func(trustedProxies ...string) *httptest.Server {
+		ts := httptest.NewServer(httpapi.NewRouter(&config.Config{
+			Port:               "8080",
+			Environment:        "test",
+			WorkPublisherURL:   upstream.URL,
+			BidGatewayURL:      upstream.URL,
+			IdentityURL:        identity.URL,
+			APIKeyValidator:    "identity",
+			APIKeyCacheTTL:     time.Minute,
+			TrustedProxies:     trustedProxies,
+			RateLimitPerMinute: 1000,
+			RateLimitBurstSize: 50,
+			RequestTimeout:     30 * time.Second,
+		}))
+		t.Cleanup(ts.Close)
+		return ts
+	}
+	call := func(ts *httptest.Server, apiKey, path, forwardedFor string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("X-API-Key", apiKey)
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Error.Code
+	}
+
+	direct := newGateway()
+	if code, _ := call(direct, "bids-key", "/v1/bids", ""); code != http.StatusOK {
+		t.Errorf("bidding key on /v1/bids: expected 200, got %d", code)
+	}
+	if code, errCode := call(direct, "bids-key", "/v1/work", ""); code != http.StatusForbidden || errCode != "route_not_allowed" {
+		t.Errorf("bidding key on /v1/work: expected 403 route_not_allowed, got %d %s", code, errCode)
+	}
+	// Without trusted proxies X-Forwarded-For is ignored.
+	if code, errCode := call(direct, "office-key", "/v1/work", "10.1.2.3"); code != http.StatusForbidden || errCode != "address_not_allowed" {
+		t.Errorf("office key from loopback: expected 403 address_not_allowed, got %d %s", code, errCode)
+	}
+
+	proxied := newGateway("127.0.0.0/8")
+	if code, _ := call(proxied, "office-key", "/v1/work", "10.1.2.3"); code != http.StatusOK {
+		t.Errorf("office key from 10.1.2.3: expected 200, got %d", code)
+	}
+	// Only the hops added by trusted proxies count; a client cannot claim
+	// an address by sending X-Forwarded-For itself.
+	if code, _ := call(proxied, "office-key", "/v1/work", "10.1.2.3, 192.0.2.7"); code != http.StatusForbidden {
+		t.Errorf("office key from 192.0.2.7: expected 403, got %d", code)
+	}
+}
+
+func TestRateLimitingPerAPIKeyQuotas(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	quotas := map[string]map[string]int{
+		"minute-key": {"requests_per_minute": 2, "requests_per_day": 100},
+		"daily-key":  {"requests_per_minute": 100, "requests_per_day": 3},
+	}
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			APIKey string `json:"api_key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		q, ok := quotas[req.APIKey]
+		if r.URL.Path != "/internal/v1/apikeys/validate" || !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"tenant_id":     "tenant_" + req.APIKey,
+			"tenant_status": "ACTIVE",
+			"scopes":        []string{"*"},
+			"quotas":        q,
+		})
+	}))
+	defer identity.Close()
+
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		WorkPublisherURL:   upstream.URL,
+		IdentityURL:        identity.URL,
+		APIKeyValidator:    "identity",
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		RequestTimeout:     30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	call := func(apiKey string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/work", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	if resp := call("unknown-key"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", resp.StatusCode)
+	}
+
+	for i, want := range []string{"1", "0"} {
+		resp := call("minute-key")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, resp.StatusCode)
+		}
+		if resp.Header.Get("X-RateLimit-Limit") != "2" || resp.Header.Get("X-RateLimit-Remaining") != want {
+			t.Fatalf("request %d: unexpected rate limit headers %v", i, resp.Header)
+		}
+	}
+	resp := call("minute-key")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", resp.StatusCode)
+	}
+
+	// Another key has its own limits.
+	for i := 0; i < 3; i++ {
+		if resp := call("daily-key"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, resp.StatusCode)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/work", nil)
+	req.Header.Set("X-API-Key", "daily-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("X-RateLimit-Limit") != "3" {
+		t.Fatalf("expected the daily quota to apply, got %d %v", resp.StatusCode, resp.Header)
+	}
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error.Code != "daily_quota_exceeded" {
+		t.Fatalf("expected daily_quota_exceeded, got %q", body.Error.Code)
+	}
+}
+
+func TestAPIKeyValidationCache(t *testing.T) {
+	var seen http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			APIKey string `json:"api_key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		calls[req.APIKey]++
+		mu.Unlock()
+		switch req.APIKey {
+		case "good-key":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"tenant_id":     "tenant_good",
+				"tenant_status": "ACTIVE",
+				"scopes":        []string{"work:read", "work:write"},
+			})
+		case "user-key":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"tenant_id":     "tenant_good",
+				"tenant_status": "ACTIVE",
+				"scopes":        []string{"work:read"},
+				"user_id":       "user_1",
+				"user_role":     "DEVELOPER",
+			})
+		case "expiring-key":
+			// The key expires now, so its validation may not be cached.
+			w.Header().Set("Cache-Control", "private, max-age=0")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"tenant_id": "tenant_good",
+				"scopes":    []string{"work:read"},
+			})
+		case "flaky-key":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		default:
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}))
+	defer identity.Close()
+
+	cfg := &config.Config{
+		Port:                   "8080",
+		Environment:            "test",
+		WorkPublisherURL:       upstream.URL,
+		IdentityURL:            identity.URL,
+		APIKeyValidator:        "identity",
+		APIKeyCacheTTL:         time.Minute,
+		APIKeyNegativeCacheTTL: time.Minute,
+		RateLimitPerMinute:     1000,
+		RateLimitBurstSize:     50,
+		RequestTimeout:         30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	call := func(header, value string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/work", nil)
+		req.Header.Set(header, value)
+		req.Header.Set("X-Scopes", "admin")
+		req.Header.Set("X-User-Role", "OWNER")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := call("X-API-Key", "good-key"); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if seen.Get("X-Tenant-ID") != "tenant_good" || seen.Get("X-Scopes") != "work:read,work:write" || seen.Get("X-User-Role") != "" {
+		t.Fatalf("unexpected downstream headers %v", seen)
+	}
+	if status := call("X-API-Key", "user-key"); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if seen.Get("X-User-ID") != "user_1" || seen.Get("X-User-Role") != "DEVELOPER" {
+		t.Fatalf("expected the key's user downstream, got %v", seen)
+	}
+	if status := call("Authorization", "Bearer good-key"); status != http.StatusOK {
+		t.Fatalf("expected 200 for a bearer token, got %d", status)
+	}
+	for i := 0; i < 2; i++ {
+		if status := call("Authorization", "Bearer bad-key"); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", status)
+		}
+		if status := call("X-API-Key", "flaky-key"); status != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %d", status)
+		}
+		if status := call("X-API-Key", "expiring-key"); status != http.StatusOK {
+			t.Fatalf("expected 200, got %d", status)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["good-key"] != 1 || calls["bad-key"] != 1 {
+		t.Fatalf("expected valid and invalid keys to be cached, got %v", calls)
+	}
+	if calls["flaky-key"] != 2 {
+		t.Fatalf("expected identity failures not to be cached, got %v", calls)
+	}
+	if calls["expiring-key"] != 2 {
+		t.Fatalf("expected the identity service's max-age to be respected, got %v", calls)
+	}
+}
+
+func TestUpstreamRetriesAndCircuitBreaker(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	healthy := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.Method]++
+		ok := healthy
+		mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer other.Close()
+
+	cfg := &config.Config{
+		Port:                    "8080",
+		Environment:             "test",
+		ContractEngineURL:       upstream.URL,
+		WorkPublisherURL:        other.URL,
+		RateLimitPerMinute:      1000,
+		RateLimitBurstSize:      50,
+		BreakerFailureThreshold: 4,
+		BreakerOpenDuration:     200 * time.Millisecond,
+		BreakerHalfOpenProbes:   1,
+		UpstreamMaxRetries:      2,
+		UpstreamRetryBackoff:    time.Millisecond,
+		RequestTimeout:          30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	call := func(method, path string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(`{}`))
+		req.Header.Set("X-API-Key", "dev-api-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	// POST is not idempotent, so it is sent once; GET is retried twice.
+	if resp := call(http.MethodPost, "/v1/contracts"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the upstream 503, got %d", resp.StatusCode)
+	}
+	if resp := call(http.MethodGet, "/v1/contracts/c1"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the upstream 503, got %d", resp.StatusCode)
+	}
+	mu.Lock()
+	if hits[http.MethodPost] != 1 || hits[http.MethodGet] != 3 {
+		t.Fatalf("expected 1 POST and 3 GET attempts, got %v", hits)
+	}
+	mu.Unlock()
+
+	// Four failures opened the breaker: requests fail fast.
+	resp := call(http.MethodGet, "/v1/contracts/c1")
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected a fast 503 with Retry-After, got %d", resp.StatusCode)
+	}
+	mu.Lock()
+	if hits[http.MethodGet] != 3 {
+		t.Fatalf("expected no attempt while open, got %v", hits)
+	}
+	healthy = true
+	mu.Unlock()
+	if resp := call(http.MethodGet, "/v1/work"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected other upstreams to be unaffected, got %d", resp.StatusCode)
+	}
+
+	// After the open period a probe goes through and closes the breaker.
+	time.Sleep(250 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if resp := call(http.MethodGet, "/v1/contracts/c1"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: expected 200 after recovery, got %d", i, resp.StatusCode)
+		}
+	}
+}
+
+func TestAuditLogForwarding(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"work_id":"work_123"}`))
+	}))
+	defer upstream.Close()
+
+	type logEntry struct {
+		Level   string         `json:"level"`
+		Service string         `json:"service"`
+		Fields  map[string]any `json:"fields"`
+	}
+	received := make(chan []logEntry, 1)
+	telemetry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []logEntry
+		if r.URL.Path != "/v1/logs" || json.NewDecoder(r.Body).Decode(&batch) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- batch
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer telemetry.Close()
+
+	cfg := &config.Config{
+		Port:                "8080",
+		Environment:         "test",
+		WorkPublisherURL:    upstream.URL,
+		RateLimitPerMinute:  1000,
+		RateLimitBurstSize:  50,
+		AuditLogEnabled:     true,
+		AuditBodySampleRate: 1,
+		AuditMaxBodyBytes:   12,
+		AuditTelemetryURL:   telemetry.URL,
+		RequestTimeout:      30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/work", strings.NewReader(`{"category":"translation"}`))
+	req.Header.Set("X-API-Key", "dev-api-key")
+	req.Header.Set("X-Request-ID", "audit-request-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	var batch []logEntry
+	select {
+	case batch = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("audit record was not forwarded")
+	}
+	if len(batch) != 1 || batch[0].Service != "aex-gateway" || batch[0].Level != "info" {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+	f := batch[0].Fields
+	for k, want := range map[string]any{
+		"request_id":    "audit-request-1",
+		"tenant_id":     "tenant_dev",
+		"method":        "POST",
+		"route":         "/v1/work",
+		"status":        float64(http.StatusCreated),
+		"request_body":  `{"category":...[truncated]`,
+		"response_body": `{"work_id":"...[truncated]`,
+	} {
+		if f[k] != want {
+			t.Errorf("expected %s=%v, got %v", k, want, f[k])
+		}
+	}
+	headers, _ := f["headers"].(map[string]any)
+	if headers["X-Api-Key"] != "[REDACTED]" {
+		t.Errorf("expected the API key to be redacted, got %v", headers)
+	}
+	if f["upstream"] == "" {
+		t.Error("upstream not recorded")
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	var proxied int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		WorkPublisherURL:   upstream.URL,
+		BidGatewayURL:      upstream.URL,
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		MaxBodyBytes:       1024,
+		RouteMaxBodyBytes:  map[string]int64{"/v1/bids": 64},
+		SchemaDir:          "../../../../shared/schemas/json",
+		RequestTimeout:     30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	post := func(path, body string) (*http.Response, map[string]any) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "dev-api-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	resp, _ := post("/v1/work", `{"category":"translation","description":"Translate","budget":{"max_price":10}}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected valid work to be proxied, got %d", resp.StatusCode)
+	}
+
+	resp, result := post("/v1/work", `{"category":"translation","budget":{"max_price":-1}}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+	errBody, _ := result["error"].(map[string]any)
+	if errBody["code"] != "validation_failed" {
+		t.Fatalf("expected validation_failed, got %v", errBody)
+	}
+	details, _ := errBody["details"].([]any)
+	var fields []string
+	for _, d := range details {
+		fields = append(fields, d.(map[string]any)["field"].(string))
+	}
+	if strings.Join(fields, ",") != "budget.max_price,description" {
+		t.Fatalf("unexpected violations %v", details)
+	}
+
+	resp, result = post("/v1/work", `{"category":`)
+	if errBody, _ := result["error"].(map[string]any); resp.StatusCode != http.StatusBadRequest || errBody["code"] != "invalid_json" {
+		t.Fatalf("expected invalid_json 400, got %d %v", resp.StatusCode, result)
+	}
+
+	resp, _ = post("/v1/bids", `{"work_id":"`+strings.Repeat("w", 64)+`"}`)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for the bids route limit, got %d", resp.StatusCode)
+	}
+
+	// Routes without a schema are only size limited.
+	resp, _ = post("/v1/work/work_123/cancel", `not json`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected unvalidated route to be proxied, got %d", resp.StatusCode)
+	}
+	resp, _ = post("/v1/work/work_123/cancel", strings.Repeat("x", 1025))
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for the default limit, got %d", resp.StatusCode)
+	}
+
+	if proxied != 2 {
+		t.Fatalf("expected 2 requests to reach the upstream, got %d", proxied)
+	}
+}
+
+func TestTracePropagationAndSpans(t *testing.T) {
+	traceparents := make(chan string, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	type span struct {
+		TraceID      string            `json:"trace_id"`
+		SpanID       string            `json:"span_id"`
+		ParentSpanID string            `json:"parent_span_id"`
+		Service      string            `json:"service"`
+		Operation    string            `json:"operation"`
+		Status       string            `json:"status"`
+		Attributes   map[string]string `json:"attributes"`
+	}
+	received := make(chan span, 2)
+	telemetry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []span
+		if r.URL.Path != "/v1/spans" || json.NewDecoder(r.Body).Decode(&batch) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, s := range batch {
+			received <- s
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer telemetry.Close()
+
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		ContractEngineURL:  upstream.URL,
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		TraceTelemetryURL:  telemetry.URL,
+		RequestTimeout:     30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	get := func(traceparent string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/contracts/contract_1", nil)
+		req.Header.Set("X-API-Key", "dev-api-key")
+		if traceparent != "" {
+			req.Header.Set("traceparent", traceparent)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	// The caller's trace is continued, with the gateway span in between.
+	const traceID, callerSpan = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	resp := get("00-" + traceID + "-" + callerSpan + "-01")
+	if resp.Header.Get("X-Trace-ID") != traceID {
+		t.Fatalf("expected X-Trace-ID %s, got %q", traceID, resp.Header.Get("X-Trace-ID"))
+	}
+	forwarded := strings.Split(<-traceparents, "-")
+	if len(forwarded) != 4 || forwarded[1] != traceID || forwarded[2] == callerSpan || forwarded[3] != "01" {
+		t.Fatalf("unexpected upstream traceparent %v", forwarded)
+	}
+
+	var s span
+	select {
+	case s = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("span was not emitted")
+	}
+	if s.TraceID != traceID || s.SpanID != forwarded[2] || s.ParentSpanID != callerSpan {
+		t.Fatalf("span not linked into the trace: %+v", s)
+	}
+	if s.Service != "aex-gateway" || s.Operation != "GET /v1/contracts" || s.Status != "OK" {
+		t.Fatalf("unexpected span %+v", s)
+	}
+	if s.Attributes["tenant_id"] != "tenant_dev" || s.Attributes["http.status_code"] != "200" || s.Attributes["peer.service"] == "" {
+		t.Fatalf("unexpected span attributes %v", s.Attributes)
+	}
+
+	// Without a valid traceparent the gateway starts a trace.
+	resp = get("not-a-traceparent")
+	started := strings.Split(<-traceparents, "-")
+	if len(started) != 4 || started[1] != resp.Header.Get("X-Trace-ID") || started[1] == traceID {
+		t.Fatalf("expected a new trace, got %v", started)
+	}
+	select {
+	case s = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("span was not emitted")
+	}
+	if s.TraceID != started[1] || s.ParentSpanID != "" {
+		t.Fatalf("expected a root span of the new trace, got %+v", s)
+	}
+}
+
+func TestAPIVersionTranslation(t *testing.T) {
+	type proxied struct {
+		path string
+		body map[string]any
+	}
+	received := make(chan proxied, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received <- proxied{path: r.URL.Path, body: body}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		WorkPublisherURL:   upstream.URL,
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		SchemaDir:          "../../../../shared/schemas/json",
+		RequestTimeout:     30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	post := func(path, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "dev-api-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	// v1 clients sending the deprecated budget amount are translated.
+	resp := post("/v1/work", `{"category":"translation","description":"Translate","budget":{"max_amount":"12.5","currency":"USD"}}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Deprecation") != "true" || resp.Header.Get("X-Deprecated-Fields") != "budget.max_amount" {
+		t.Fatalf("expected deprecation headers, got %v", resp.Header)
+	}
+	if link := resp.Header.Get("Link"); link != `</v2/work>; rel="successor-version"` {
+		t.Fatalf("unexpected Link %q", link)
+	}
+	got := <-received
+	budget, _ := got.body["budget"].(map[string]any)
+	if got.path != "/v1/work" || budget["max_price"] != 12.5 || budget["max_amount"] != nil {
+		t.Fatalf("deprecated field not translated: %s %v", got.path, got.body)
+	}
+
+	// Current v1 bodies pass unchanged.
+	resp = post("/v1/work", `{"category":"translation","description":"Translate","budget":{"max_price":3}}`)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Deprecation") != "" {
+		t.Fatalf("expected current v1 body to pass unmarked, got %d %v", resp.StatusCode, resp.Header)
+	}
+	<-received
+
+	// v2 is proxied to the upstream v1 paths and validated strictly.
+	resp = post("/v2/work", `{"category":"translation","description":"Translate","budget":{"max_price":3}}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if got := <-received; got.path != "/v1/work" {
+		t.Fatalf("expected v2 to reach /v1/work, got %s", got.path)
+	}
+	resp = post("/v2/work", `{"category":"translation","description":"Translate","budget":{"max_amount":"12.5"}}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected deprecated field to be rejected on v2, got %d", resp.StatusCode)
+	}
+}
+
+func TestCanaryRouting(t *testing.T) {
+	newUpstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}))
+	}
+	stable, canary := newUpstream("stable"), newUpstream("canary")
+	defer stable.Close()
+	defer canary.Close()
+
+	send := func(ts *httptest.Server, canaryHeader string) (string, *http.Response) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/work/work_1", nil)
+		req.Header.Set("X-API-Key", "dev-api-key")
+		if canaryHeader != "" {
+			req.Header.Set("X-Canary", canaryHeader)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp
+	}
+	newGateway := func(weight int) *httptest.Server {
+		cfg := &config.Config{
+			Port:               "8080",
+			Environment:        "test",
+			WorkPublisherURL:   stable.URL,
+			Canaries:           []config.Canary{{Service: "work-publisher", URL: canary.URL, Weight: weight}},
+			RateLimitPerMinute: 1000,
+			RateLimitBurstSize: 50,
+			RequestTimeout:     30 * time.Second,
+		}
+		return httptest.NewServer(httpapi.NewRouter(cfg))
+	}
+
+	// An unweighted canary only receives the requests that ask for it.
+	ts := newGateway(0)
+	defer ts.Close()
+	for i := 0; i < 20; i++ {
+		if got, _ := send(ts, ""); got != "stable" {
+			t.Fatalf("expected the stable replica, got %s", got)
+		}
+	}
+	got, resp := send(ts, "true")
+	if got != "canary" || resp.Header.Get("X-Canary") != "true" {
+		t.Fatalf("expected X-Canary: true to reach the canary, got %s %v", got, resp.Header)
+	}
+
+	// A fully weighted canary takes all traffic except opt-outs.
+	full := newGateway(100)
+	defer full.Close()
+	for i := 0; i < 20; i++ {
+		if got, _ := send(full, ""); got != "canary" {
+			t.Fatalf("expected the canary, got %s", got)
+		}
+	}
+	if got, resp := send(full, "false"); got != "stable" || resp.Header.Get("X-Canary") != "" {
+		t.Fatalf("expected X-Canary: false to reach the stable replica, got %s", got)
+	}
+
+	// A weighted canary gets roughly its share.
+	split := newGateway(30)
+	defer split.Close()
+	canaryHits := 0
+	for i := 0; i < 400; i++ {
+		if got, _ := send(split, ""); got == "canary" {
+			canaryHits++
+		}
+	}
+	if canaryHits < 70 || canaryHits > 170 {
+		t.Fatalf("expected about 120 of 400 requests on the canary, got %d", canaryHits)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		SchemaDir:          "../../../../shared/schemas/json",
+		RequestTimeout:     30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 without authentication, got %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Fatalf("expected OpenAPI 3.1.0, got %q", doc.OpenAPI)
+	}
+
+	submit := doc.Paths["/v2/work"]["post"]
+	body, _ := json.Marshal(submit["requestBody"])
+	if !strings.Contains(string(body), "#/components/schemas/WorkSubmit") {
+		t.Fatalf("expected work submission to reference its schema, got %s", body)
+	}
+	if doc.Components.Schemas["WorkSubmit"]["title"] != "WorkSubmission" {
+		t.Fatalf("expected the shared work schema in components, got %v", doc.Components.Schemas["WorkSubmit"])
+	}
+	if doc.Paths["/v1/work"]["post"]["deprecated"] != true || submit["deprecated"] != nil {
+		t.Fatal("expected only the v1 operation to be deprecated")
+	}
+	params, _ := doc.Paths["/v2/contracts/{contract_id}/artifacts/{artifact_id}"]["get"]["parameters"].([]any)
+	if len(params) != 2 {
+		t.Fatalf("expected two path parameters, got %v", params)
+	}
+	if _, ok := doc.Paths["/v1/system/health"]["get"]; !ok {
+		t.Fatal("expected the gateway's own endpoints to be described")
+	}
+}
+
+func TestReloadKeepsInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	oldUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("old"))
+	}))
+	defer oldUpstream.Close()
+	newUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("new"))
+	}))
+	defer newUpstream.Close()
+
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		WorkPublisherURL:   oldUpstream.URL,
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		RequestTimeout:     30 * time.Second,
+	}
+	gateway, err := httpapi.NewGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(gateway)
+	defer ts.Close()
+
+	get := func() (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/work/work_1", nil)
+		req.Header.Set("X-API-Key", "dev-api-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	type result struct {
+		status int
+		body   string
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		status, body := get()
+		inFlight <- result{status, body}
+	}()
+	<-started
+
+	reloaded := *cfg
+	reloaded.WorkPublisherURL = newUpstream.URL
+	if err := gateway.Reload(&reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := get(); status != http.StatusOK || body != "new" {
+		t.Fatalf("expected the reloaded upstream, got %d %q", status, body)
+	}
+
+	close(release)
+	if r := <-inFlight; r.status != http.StatusOK || r.body != "old" {
+		t.Fatalf("expected the in-flight request to finish on the old upstream, got %d %q", r.status, r.body)
+	}
+
+	// A configuration that fails to load leaves the current one in place.
+	broken := reloaded
+	broken.SchemaDir = t.TempDir()
+	if err := gateway.Reload(&broken); err == nil {
+		t.Fatal("expected reload with a missing routes.json to fail")
+	}
+	if status, body := get(); status != http.StatusOK || body != "new" {
+		t.Fatalf("expected the previous configuration to stay, got %d %q", status, body)
+	}
+}
+
+func TestResponseCompression(t *testing.T) {
+	large := `{"items":"` + strings.Repeat("work ", 500) + `"}`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/work/large":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(large))
+		case "/v1/work/small":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/v1/work/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte(large))
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Port:                "8080",
+		Environment:         "test",
+		WorkPublisherURL:    upstream.URL,
+		RateLimitPerMinute:  1000,
+		RateLimitBurstSize:  50,
+		CompressionMinBytes: 1024,
+		RequestTimeout:      30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Setting Accept-Encoding turns off the client's transparent gzip
+	// decoding, so the encoding on the wire can be checked.
+	get := func(path, acceptEncoding string) (*http.Response, []byte) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("X-API-Key", "dev-api-key")
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var body io.Reader = resp.Body
+		switch resp.Header.Get("Content-Encoding") {
+		case "gzip":
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = zr
+		case "deflate":
+			body = flate.NewReader(resp.Body)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, data
+	}
+
+	for _, tc := range []struct {
+		path, accept, want string
+	}{
+		{"/v1/work/large", "gzip, deflate", "gzip"},
+		{"/v1/work/large", "gzip;q=0.5, deflate", "deflate"},
+		{"/v1/work/large", "br", ""},
+		{"/v1/work/large", "gzip;q=0", ""},
+		{"/v1/work/small", "gzip", ""},
+		{"/v1/work/binary", "gzip", ""},
+	} {
+		resp, body := get(tc.path, tc.accept)
+		if got := resp.Header.Get("Content-Encoding"); got != tc.want {
+			t.Fatalf("%s with %q: expected encoding %q, got %q", tc.path, tc.accept, tc.want, got)
+		}
+		if tc.path == "/v1/work/large" && string(body) != large {
+			t.Fatalf("%s with %q: body does not round-trip", tc.path, tc.accept)
+		}
+		if !strings.Contains(resp.Header.Get("Vary"), "Accept-Encoding") {
+			t.Fatalf("expected Vary: Accept-Encoding, got %v", resp.Header)
+		}
+	}
+}
+
+func TestKillSwitches(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Port:                  "8080",
+		Environment:           "test",
+		WorkPublisherURL:      upstream.URL,
+		BidGatewayURL:         upstream.URL,
+		RateLimitPerMinute:    1000,
+		RateLimitBurstSize:    50,
+		DisabledRoutes:        []string{"bid-gateway"},
+		MaintenanceRetryAfter: 120 * time.Second,
+		AdminToken:            "admin-secret",
+		RequestTimeout:        30 * time.Second,
+	}
+	gateway, err := httpapi.NewGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(gateway)
+	defer ts.Close()
+
+	do := func(method, path string) (*http.Response, map[string]any) {
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		req.Header.Set("X-API-Key", "dev-api-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var body map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp, body
+	}
+	admin := func(method, body, token string) (int, map[string]any) {
+		req, _ := http.NewRequest(method, ts.URL+"/admin/switches", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var state map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&state)
+		return resp.StatusCode, state
+	}
+	expectCode := func(resp *http.Response, body map[string]any, code string) {
+		t.Helper()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %d", resp.StatusCode)
+		}
+		if got := body["error"].(map[string]any)["code"]; got != code {
+			t.Fatalf("expected %s, got %v", code, got)
+		}
+		if got := resp.Header.Get("Retry-After"); got != "120" {
+			t.Fatalf("expected Retry-After 120, got %q", got)
+		}
+	}
+
+	// The configured switch disables bidding, under both API versions.
+	resp, body := do(http.MethodPost, "/v1/bids")
+	expectCode(resp, body, "route_disabled")
+	resp, body = do(http.MethodPost, "/v2/bids")
+	expectCode(resp, body, "route_disabled")
+	if resp, _ := do(http.MethodGet, "/v1/work/work_1"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected other routes to be served, got %d", resp.StatusCode)
+	}
+
+	// The admin endpoint flips the switches at runtime.
+	if status, _ := admin(http.MethodGet, "", "wrong"); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong admin token, got %d", status)
+	}
+	if status, _ := admin(http.MethodPut, `{"disabled_routes":["no-such-service"]}`, "admin-secret"); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown service, got %d", status)
+	}
+	status, state := admin(http.MethodPut, `{"maintenance":true}`, "admin-secret")
+	if status != http.StatusOK || state["maintenance"] != true || state["retry_after_seconds"] != float64(120) {
+		t.Fatalf("unexpected admin response %d %v", status, state)
+	}
+	resp, body = do(http.MethodGet, "/v1/work/work_1")
+	expectCode(resp, body, "maintenance")
+	if resp, _ := do(http.MethodGet, "/health"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected health checks to pass in maintenance, got %d", resp.StatusCode)
+	}
+
+	status, state = admin(http.MethodPut, `{"maintenance":false,"disabled_routes":[]}`, "admin-secret")
+	if status != http.StatusOK || state["maintenance"] != false {
+		t.Fatalf("unexpected admin response %d %v", status, state)
+	}
+	if resp, _ := do(http.MethodPost, "/v1/bids"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected bidding to be re-enabled, got %d", resp.StatusCode)
+	}
+
+	// Reloading restores the configured switches.
+	if err := gateway.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	resp, body = do(http.MethodPost, "/v1/bids")
+	expectCode(resp, body, "route_disabled")
+}
+
+func TestTenantConcurrencyLimit(t *testing.T) {
+	entered := make(chan struct{}, 2)
+	release1, release2 := make(chan struct{}), make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/work/slow_1":
+			entered <- struct{}{}
+			<-release1
+		case "/v1/work/slow_2":
+			entered <- struct{}{}
+			<-release2
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Port:               "8080",
+		Environment:        "test",
+		WorkPublisherURL:   upstream.URL,
+		RateLimitPerMinute: 1000,
+		RateLimitBurstSize: 50,
+		TenantMaxInFlight:  1,
+		TenantMaxQueued:    1,
+		TenantQueueTimeout: 5 * time.Second,
+		RequestTimeout:     30 * time.Second,
+	}
+	gateway, err := httpapi.NewGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(gateway)
+	defer ts.Close()
+	defer close(release2)
+
+	get := func(path, apiKey string) int {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("X-API-Key", apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// tenant_dev's one slot is taken; of two more requests one waits in
+	// its queue and the other is turned away.
+	slow := make(chan int, 1)
+	go func() { slow <- get("/v1/work/slow_1", "dev-api-key") }()
+	<-entered
+	statuses := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { statuses <- get("/v1/work/fast", "dev-api-key") }()
+	}
+	if status := <-statuses; status != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the queue is full, got %d", status)
+	}
+
+	// Other tenants are unaffected.
+	if status := get("/v1/work/fast", "test-api-key"); status != http.StatusOK {
+		t.Fatalf("expected another tenant to be served, got %d", status)
+	}
+
+	close(release1)
+	if status := <-slow; status != http.StatusOK {
+		t.Fatalf("expected the slow request to succeed, got %d", status)
+	}
+	if status := <-statuses; status != http.StatusOK {
+		t.Fatalf("expected the queued request to be served, got %d", status)
+	}
+
+	// Queued requests give up after the queue timeout.
+	reloaded := *cfg
+	reloaded.TenantQueueTimeout = 50 * time.Millisecond
+	if err := gateway.Reload(&reloaded); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = get("/v1/work/slow_2", "dev-api-key") }()
+	<-entered
+	if status := get("/v1/work/fast", "dev-api-key"); status != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after the queue timeout, got %d", status)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	downHost := strings.TrimPrefix(down.URL, "http://")
+	down.Close()
+
+	cfg := &config.Config{
+		Port:                    "8080",
+		Environment:             "test",
+		WorkPublisherURL:        upstream.URL,
+		BidGatewayURL:           down.URL,
+		RateLimitPerMinute:      3,
+		RateLimitBurstSize:      3,
+		BreakerFailureThreshold: 1,
+		BreakerOpenDuration:     time.Minute,
+		RequestTimeout:          30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	do := func(method, path string) int {
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		req.Header.Set("X-API-Key", "dev-api-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	do(http.MethodGet, "/v1/work/work_1")
+	do(http.MethodGet, "/v1/work/work_1")
+	do(http.MethodPost, "/v1/bids") // fails and opens the breaker
+	if status := do(http.MethodGet, "/v1/work/work_1"); status != http.StatusTooManyRequests {
+		t.Fatalf("expected the rate limit to be hit, got %d", status)
+	}
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("expected the Prometheus text format, got %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	workHost := strings.TrimPrefix(upstream.URL, "http://")
+	for _, want := range []string{
+		`aex_gateway_requests_total{route="/v1/work",upstream="` + workHost + `",method="GET",status="2xx"} 2`,
+		`aex_gateway_requests_total{route="/v1/bids",upstream="` + downHost + `",method="POST",status="5xx"} 1`,
+		`aex_gateway_requests_total{route="none",upstream="",method="GET",status="4xx"} 1`,
+		`aex_gateway_request_duration_seconds_count{route="/v1/work",upstream="` + workHost + `"} 2`,
+		`aex_gateway_request_duration_seconds_bucket{route="/v1/work",upstream="` + workHost + `",le="+Inf"} 2`,
+		`aex_gateway_rate_limit_rejections_total{reason="rate_limit"} 1`,
+		`aex_gateway_rate_limit_rejections_total{reason="concurrency"} 0`,
+		`aex_gateway_circuit_breaker_state{upstream="` + downHost + `",state="open"} 1`,
+		`aex_gateway_circuit_breaker_state{upstream="` + workHost + `",state="closed"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %s in:\n%s", want, body)
+		}
+	}
+}
+
+func TestJWTVerification(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	sign := func(claims map[string]any) string {
+		h, _ := json.Marshal(map[string]string{"alg": "ES256", "typ": "JWT", "kid": "k1"})
+		p, _ := json.Marshal(claims)
+		input := b64(h) + "." + b64(p)
+		digest := sha256.Sum256([]byte(input))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return input + "." + b64(sig)
+	}
+	claims := func(exp time.Time) map[string]any {
+		return map[string]any{
+			"iss":       "aex-identity",
+			"sub":       "tenant_jwt",
+			"tenant_id": "tenant_jwt",
+			"scopes":    []string{"work:read"},
+			"exp":       exp.Unix(),
+		}
+	}
+
+	var seen http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/.well-known/jwks.json":
+			x := make([]byte, 32)
+			y := make([]byte, 32)
+			key.X.FillBytes(x)
+			key.Y.FillBytes(y)
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+				{"kty": "EC", "crv": "P-256", "x": b64(x), "y": b64(y), "kid": "k1", "use": "sig", "alg": "ES256"},
+			}})
+		case "/v1/token":
+			if r.Header.Get("X-API-Key") != "good-key" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"access_token": sign(claims(time.Now().Add(time.Minute))),
+				"token_type":   "Bearer",
+			})
+		default:
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}))
+	defer identity.Close()
+
+	cfg := &config.Config{
+		Port:                   "8080",
+		Environment:            "test",
+		WorkPublisherURL:       upstream.URL,
+		IdentityURL:            identity.URL,
+		APIKeyValidator:        "identity",
+		JWTIssuer:              "aex-identity",
+		APIKeyCacheTTL:         time.Minute,
+		APIKeyNegativeCacheTTL: time.Minute,
+		RateLimitPerMinute:     1000,
+		RateLimitBurstSize:     50,
+		RequestTimeout:         30 * time.Second,
+	}
+
+	router := httpapi.NewRouter(cfg)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// The token exchange is passed through to the identity service.
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/token", nil)
+	req.Header.Set("X-API-Key", "good-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issued struct {
+		AccessToken string `json:"access_token"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&issued)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || issued.AccessToken == "" {
+		t.Fatalf("expected a token, got %d", resp.StatusCode)
+	}
+
+	call := func(token string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/work", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for i := 0; i < 3; i++ {
+		if status := call(issued.AccessToken); status != http.StatusOK {
+			t.Fatalf("expected 200 for a token, got %d", status)
+		}
+	}
+	if seen.Get("X-Tenant-ID") != "tenant_jwt" || seen.Get("X-Scopes") != "work:read" {
+		t.Fatalf("unexpected downstream headers %v", seen)
+	}
+
+	tampered := issued.AccessToken[:len(issued.AccessToken)-4] + "AAAA"
+	if status := call(tampered); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a tampered token, got %d", status)
+	}
+	if status := call(sign(claims(time.Now().Add(-time.Second)))); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an expired token, got %d", status)
+	}
+	other := claims(time.Now().Add(time.Minute))
+	other["iss"] = "someone-else"
+	if status := call(sign(other)); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for another issuer's token, got %d", status)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["/.well-known/jwks.json"] != 1 {
+		t.Fatalf("expected the key set to be fetched once, got %v", calls)
+	}
+	if calls["/internal/v1/apikeys/validate"] != 0 {
+		t.Fatalf("expected tokens to be verified without the identity service, got %v", calls)
+	}
+}
+
+func TestIdentityLifecycleEvents(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	h, _ := json.Marshal(map[string]string{"alg": "ES256", "typ": "JWT", "kid": "k1"})
+	p, _ := json.Marshal(map[string]any{
+		"iss": "aex-identity", "tenant_id": "tenant_a", "key_id": "key_a", "scopes": []string{"*"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	input := b64(h) + "." + b64(p)
+	digest := sha256.Sum256([]byte(input))
+	r, sv, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	sv.FillBytes(sig[32:])
+	token := input + "." + b64(sig)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	var mu sync.Mutex
+	validations := 0
+	keys := map[string]map[string]any{
+		"key-a": {"key_id": "key_a", "tenant_id": "tenant_a", "scopes": []string{"*"}},
+		"key-b": {"key_id": "key_b", "tenant_id": "tenant_a", "scopes": []string{"*"}},
+		"key-c": {"key_id": "key_c", "tenant_id": "tenant_c", "scopes": []string{"*"}},
+	}
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/jwks.json":
+			x, y := make([]byte, 32), make([]byte, 32)
+			key.X.FillBytes(x)
+			key.Y.FillBytes(y)
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+				{"kty": "EC", "crv": "P-256", "x": b64(x), "y": b64(y), "kid": "k1"},
+			}})
+		case "/internal/v1/apikeys/validate":
+			mu.Lock()
+			validations++
+			mu.Unlock()
+			var body struct {
+				APIKey string `json:"api_key"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			info, ok := keys[body.APIKey]
+			if !ok {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(info)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer identity.Close()
+
+	cfg := &config.Config{
+		Port:                   "8080",
+		Environment:            "test",
+		WorkPublisherURL:       upstream.URL,
+		IdentityURL:            identity.URL,
+		APIKeyValidator:        "identity",
+		JWTIssuer:              "aex-identity",
+		APIKeyCacheTTL:         time.Hour,
+		APIKeyNegativeCacheTTL: time.Minute,
+		IdentityWebhookSecret:  "hook-secret",
+		RateLimitPerMinute:     1000,
+		RateLimitBurstSize:     50,
+		RequestTimeout:         30 * time.Second,
+	}
+	ts := httptest.NewServer(httpapi.NewRouter(cfg))
+	defer ts.Close()
+
+	call := func(credential string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/work", nil)
+		req.Header.Set("Authorization", "Bearer "+credential)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	send := func(secret string, event map[string]any) int {
+		t.Helper()
+		body, _ := json.Marshal(event)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/internal/identity/events", strings.NewReader(string(body)))
+		req.Header.Set("X-AEX-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	expect := func(step string, want map[string]int) {
+		t.Helper()
+		for credential, status := range want {
+			if got := call(credential); got != status {
+				t.Errorf("%s: expected %d for %s, got %d", step, status, credential[:min(len(credential), 8)], got)
+			}
+		}
+	}
+
+	expect("before any event", map[string]int{"key-a": 200, "key-b": 200, "key-c": 200, token: 200})
+
+	revokeA := map[string]any{"event_type": "apikey.revoked", "tenant_id": "tenant_a", "data": map[string]any{"key_id": "key_a"}}
+	if status := send("wrong-secret", revokeA); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a badly signed event, got %d", status)
+	}
+	expect("after a badly signed event", map[string]int{"key-a": 200})
+
+	if status := send("hook-secret", revokeA); status != http.StatusNoContent {
+		t.Fatalf("expected 204 for apikey.revoked, got %d", status)
+	}
+	// The token issued for the revoked key goes with it.
+	expect("after apikey.revoked", map[string]int{"key-a": 401, "key-b": 200, token: 401})
+
+	send("hook-secret", map[string]any{"event_type": "tenant.suspended", "tenant_id": "tenant_a", "data": map[string]any{}})
+	expect("after tenant.suspended", map[string]int{"key-b": 401, "key-c": 200})
+
+	send("hook-secret", map[string]any{"event_type": "tenant.activated", "tenant_id": "tenant_a", "data": map[string]any{}})
+	expect("after tenant.activated", map[string]int{"key-a": 401, "key-b": 200})
+
+	if status := send("hook-secret", map[string]any{"event_type": "apikey.rotated", "data": map[string]any{}}); status != http.StatusNoContent {
+		t.Errorf("expected other events to be ignored with 204, got %d", status)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if validations != 3 {
+		t.Errorf("expected each key to be validated once and cached, got %d validations", validations)
+	}
+}
+
 func TestCORS(t *testing.T) {
 	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,11 +21,96 @@ type Config struct {
 	ContractEngineURL   string
 	TrustBrokerURL      string
 	IdentityURL         string
+	// TelemetryURL, when set, routes the query APIs of aex-telemetry
+	// through the gateway
+	TelemetryURL string
 
-	// Rate limiting
+	// UpstreamToken is sent to the upstream services in X-Gateway-Token
+	// on every proxied request, so a service can trust the X-Tenant-ID the
+	// gateway sets only on requests that came through it
+	UpstreamToken string
+
+	// Canaries are extra replicas of the upstream services that receive a
+	// share of their traffic, or the requests sent with X-Canary: true
+	Canaries []Canary
+
+	// APIKeyValidator is "identity" to validate API keys, and read their
+	// quotas, with the identity service, or "memory" for the development
+	// keys
+	APIKeyValidator string
+	// JWTIssuer is the issuer of the identity service's tokens, which the
+	// "identity" validator verifies against its published keys
+	JWTIssuer string
+	// APIKeyCacheTTL is how long a validated key is trusted without asking
+	// the identity service again; APIKeyNegativeCacheTTL how long an
+	// invalid key is rejected without asking.
+	APIKeyCacheTTL         time.Duration
+	APIKeyNegativeCacheTTL time.Duration
+	// TrustedProxies are the CIDR ranges of the load balancers in front of
+	// the gateway, whose X-Forwarded-For gives the client address that
+	// API keys restricted to CIDR ranges are checked against
+	TrustedProxies []string
+
+	// Rate limiting; RateLimitPerMinute applies to keys without a quota
 	RateLimitPerMinute int
 	RateLimitBurstSize int
 
+	// Concurrency: each tenant may have TenantMaxInFlight requests in
+	// flight, and TenantMaxQueued more waiting up to TenantQueueTimeout for
+	// a slot; zero TenantMaxInFlight means no limit
+	TenantMaxInFlight  int
+	TenantMaxQueued    int
+	TenantQueueTimeout time.Duration
+
+	// Upstream resilience: each upstream's circuit breaker opens after
+	// BreakerFailureThreshold consecutive failures for BreakerOpenDuration,
+	// then lets BreakerHalfOpenProbes requests through. Idempotent requests
+	// are retried up to UpstreamMaxRetries times.
+	BreakerFailureThreshold int
+	BreakerOpenDuration     time.Duration
+	BreakerHalfOpenProbes   int
+	UpstreamMaxRetries      int
+	UpstreamRetryBackoff    time.Duration
+
+	// Audit logging of proxied requests: AuditBodySampleRate is the
+	// fraction of requests logged with their bodies, up to
+	// AuditMaxBodyBytes; records also go to AuditTelemetryURL when set
+	AuditLogEnabled     bool
+	AuditBodySampleRate float64
+	AuditMaxBodyBytes   int
+	AuditTelemetryURL   string
+
+	// Tracing: gateway spans go to TraceTelemetryURL when set; trace
+	// context is propagated upstream either way
+	TraceTelemetryURL string
+
+	// Request validation: bodies larger than MaxBodyBytes, or the
+	// RouteMaxBodyBytes of their path prefix, are rejected; zero means no
+	// limit. SchemaDir holds the JSON schemas request bodies are validated
+	// against; empty disables schema validation.
+	MaxBodyBytes      int64
+	RouteMaxBodyBytes map[string]int64
+	SchemaDir         string
+
+	// CompressionMinBytes is the smallest response compressed for clients
+	// that accept gzip or deflate; zero disables compression
+	CompressionMinBytes int
+
+	// Kill switches: MaintenanceMode turns away every API request, and
+	// DisabledRoutes those of the listed route prefixes or upstream
+	// services, with a 503 telling clients to retry after
+	// MaintenanceRetryAfter. AdminToken, when set, enables the admin
+	// endpoint that flips them at runtime.
+	MaintenanceMode       bool
+	DisabledRoutes        []string
+	MaintenanceRetryAfter time.Duration
+	AdminToken            string
+
+	// IdentityWebhookSecret, when set, enables the endpoint the identity
+	// service sends tenant suspensions and API key revocations to, signed
+	// with it, so they take effect before cached validations expire.
+	IdentityWebhookSecret string
+
 	// Timeouts
 	RequestTimeout time.Duration
 	ProxyTimeout   time.Duration
@@ -35,39 +122,181 @@ type Config struct {
 	LogLevel string
 }
 
-func Load() *Config {
+// Canary is a replica of the named upstream service, such as
+// "work-publisher", receiving Weight percent of its traffic.
+type Canary struct {
+	Service string
+	URL     string
+	Weight  int
+}
+
+// Load reads the configuration from the environment. When CONFIG_FILE
+// names a file of KEY=VALUE lines, its values take precedence, so the
+// configuration can be changed and reloaded without a restart.
+func Load() (*Config, error) {
+	values, err := readConfigFile(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	e := env(values)
+
 	return &Config{
-		Port:                getEnv("PORT", "8080"),
-		Environment:         getEnv("ENVIRONMENT", "development"),
-		WorkPublisherURL:    getEnv("WORK_PUBLISHER_URL", "http://localhost:8081"),
-		ProviderRegistryURL: getEnv("PROVIDER_REGISTRY_URL", "http://localhost:8085"),
-		SettlementURL:       getEnv("SETTLEMENT_URL", "http://localhost:8088"),
-		BidGatewayURL:       getEnv("BID_GATEWAY_URL", "http://localhost:8082"),
-		BidEvaluatorURL:     getEnv("BID_EVALUATOR_URL", "http://localhost:8083"),
-		ContractEngineURL:   getEnv("CONTRACT_ENGINE_URL", "http://localhost:8084"),
-		TrustBrokerURL:      getEnv("TRUST_BROKER_URL", "http://localhost:8086"),
-		IdentityURL:         getEnv("IDENTITY_URL", "http://localhost:8087"),
-		RateLimitPerMinute:  getEnvInt("RATE_LIMIT_PER_MINUTE", 1000),
-		RateLimitBurstSize:  getEnvInt("RATE_LIMIT_BURST_SIZE", 50),
-		RequestTimeout:      time.Duration(getEnvInt("REQUEST_TIMEOUT_SECONDS", 30)) * time.Second,
-		ProxyTimeout:        time.Duration(getEnvInt("PROXY_TIMEOUT_SECONDS", 25)) * time.Second,
-		AllowedOrigins:      []string{"*"},
-		LogLevel:            getEnv("LOG_LEVEL", "info"),
+		Port:                    e.getEnv("PORT", "8080"),
+		Environment:             e.getEnv("ENVIRONMENT", "development"),
+		WorkPublisherURL:        e.getEnv("WORK_PUBLISHER_URL", "http://localhost:8081"),
+		ProviderRegistryURL:     e.getEnv("PROVIDER_REGISTRY_URL", "http://localhost:8085"),
+		SettlementURL:           e.getEnv("SETTLEMENT_URL", "http://localhost:8088"),
+		BidGatewayURL:           e.getEnv("BID_GATEWAY_URL", "http://localhost:8082"),
+		BidEvaluatorURL:         e.getEnv("BID_EVALUATOR_URL", "http://localhost:8083"),
+		ContractEngineURL:       e.getEnv("CONTRACT_ENGINE_URL", "http://localhost:8084"),
+		TrustBrokerURL:          e.getEnv("TRUST_BROKER_URL", "http://localhost:8086"),
+		IdentityURL:             e.getEnv("IDENTITY_URL", "http://localhost:8087"),
+		TelemetryURL:            e.getEnv("TELEMETRY_URL", ""),
+		UpstreamToken:           e.getEnv("UPSTREAM_TOKEN", ""),
+		Canaries:                e.getEnvCanaries("CANARY_UPSTREAMS"),
+		APIKeyValidator:         e.getEnv("API_KEY_VALIDATOR", "memory"),
+		JWTIssuer:               e.getEnv("JWT_ISSUER", "aex-identity"),
+		APIKeyCacheTTL:          time.Duration(e.getEnvInt("API_KEY_CACHE_TTL_SECONDS", 300)) * time.Second,
+		APIKeyNegativeCacheTTL:  time.Duration(e.getEnvInt("API_KEY_NEGATIVE_CACHE_TTL_SECONDS", 30)) * time.Second,
+		TrustedProxies:          e.getEnvList("TRUSTED_PROXIES"),
+		RateLimitPerMinute:      e.getEnvInt("RATE_LIMIT_PER_MINUTE", 1000),
+		RateLimitBurstSize:      e.getEnvInt("RATE_LIMIT_BURST_SIZE", 50),
+		TenantMaxInFlight:       e.getEnvInt("TENANT_MAX_IN_FLIGHT", 50),
+		TenantMaxQueued:         e.getEnvInt("TENANT_MAX_QUEUED", 20),
+		TenantQueueTimeout:      time.Duration(e.getEnvInt("TENANT_QUEUE_TIMEOUT_MS", 5000)) * time.Millisecond,
+		BreakerFailureThreshold: e.getEnvInt("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerOpenDuration:     time.Duration(e.getEnvInt("BREAKER_OPEN_SECONDS", 30)) * time.Second,
+		BreakerHalfOpenProbes:   e.getEnvInt("BREAKER_HALF_OPEN_PROBES", 1),
+		UpstreamMaxRetries:      e.getEnvInt("UPSTREAM_MAX_RETRIES", 2),
+		UpstreamRetryBackoff:    time.Duration(e.getEnvInt("UPSTREAM_RETRY_BACKOFF_MS", 100)) * time.Millisecond,
+		AuditLogEnabled:         e.getEnv("AUDIT_LOG_ENABLED", "true") == "true",
+		AuditBodySampleRate:     e.getEnvFloat("AUDIT_BODY_SAMPLE_RATE", 0),
+		AuditMaxBodyBytes:       e.getEnvInt("AUDIT_MAX_BODY_BYTES", 4096),
+		AuditTelemetryURL:       e.getEnv("AUDIT_TELEMETRY_URL", ""),
+		TraceTelemetryURL:       e.getEnv("TRACE_TELEMETRY_URL", ""),
+		MaxBodyBytes:            int64(e.getEnvInt("MAX_BODY_BYTES", 1<<20)),
+		RouteMaxBodyBytes:       e.getEnvSizes("ROUTE_MAX_BODY_BYTES"),
+		SchemaDir:               e.getEnv("SCHEMA_DIR", ""),
+		CompressionMinBytes:     e.getEnvInt("COMPRESSION_MIN_BYTES", 1024),
+		MaintenanceMode:         e.getEnv("MAINTENANCE_MODE", "false") == "true",
+		DisabledRoutes:          e.getEnvList("DISABLED_ROUTES"),
+		MaintenanceRetryAfter:   time.Duration(e.getEnvInt("MAINTENANCE_RETRY_AFTER_SECONDS", 300)) * time.Second,
+		AdminToken:              e.getEnv("ADMIN_TOKEN", ""),
+		IdentityWebhookSecret:   e.getEnv("IDENTITY_WEBHOOK_SECRET", ""),
+		RequestTimeout:          time.Duration(e.getEnvInt("REQUEST_TIMEOUT_SECONDS", 30)) * time.Second,
+		ProxyTimeout:            time.Duration(e.getEnvInt("PROXY_TIMEOUT_SECONDS", 25)) * time.Second,
+		AllowedOrigins:          []string{"*"},
+		LogLevel:                e.getEnv("LOG_LEVEL", "info"),
+	}, nil
+}
+
+// readConfigFile parses a file of KEY=VALUE lines; blank lines and lines
+// starting with # are skipped. An empty path reads nothing.
+func readConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
+	values := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, i+1)
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return values, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if v := os.Getenv(key); v != "" {
+// env looks values up in the config file, then the environment.
+type env map[string]string
+
+func (e env) lookup(key string) string {
+	if v, ok := e[key]; ok {
+		return v
+	}
+	return os.Getenv(key)
+}
+
+func (e env) getEnv(key, defaultValue string) string {
+	if v := e.lookup(key); v != "" {
 		return v
 	}
 	return defaultValue
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if v := os.Getenv(key); v != "" {
+func (e env) getEnvInt(key string, defaultValue int) int {
+	if v := e.lookup(key); v != "" {
 		if i, err := strconv.Atoi(v); err == nil {
 			return i
 		}
 	}
 	return defaultValue
 }
+
+func (e env) getEnvFloat(key string, defaultValue float64) float64 {
+	if v := e.lookup(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList parses a comma-separated list, skipping empty entries.
+func (e env) getEnvList(key string) []string {
+	var list []string
+	for _, entry := range strings.Split(e.lookup(key), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// getEnvSizes parses a comma-separated list of prefix=bytes pairs, such as
+// "/v1/work=65536,/v1/bids=16384", skipping malformed entries.
+func (e env) getEnvSizes(key string) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, entry := range strings.Split(e.lookup(key), ",") {
+		prefix, n, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || prefix == "" {
+			continue
+		}
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			sizes[prefix] = i
+		}
+	}
+	return sizes
+}
+
+// getEnvCanaries parses a comma-separated list of service=url@weight
+// entries, such as "work-publisher=http://work-publisher-canary:8080@5",
+// skipping malformed entries. The weight defaults to 0, for replicas only
+// reached with X-Canary: true.
+func (e env) getEnvCanaries(key string) []Canary {
+	var canaries []Canary
+	for _, entry := range strings.Split(e.lookup(key), ",") {
+		service, target, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || service == "" || target == "" {
+			continue
+		}
+		c := Canary{Service: service, URL: target}
+		if i := strings.LastIndex(target, "@"); i >= 0 {
+			w, err := strconv.Atoi(target[i+1:])
+			if err != nil || w < 0 || w > 100 {
+				continue
+			}
+			c.URL, c.Weight = target[:i], w
+		}
+		canaries = append(canaries, c)
+	}
+	return canaries
+}
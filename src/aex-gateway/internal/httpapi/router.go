@@ -2,37 +2,193 @@ package httpapi
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync/atomic"
 
 	"github.com/parlakisik/agent-exchange/aex-gateway/internal/config"
 	"github.com/parlakisik/agent-exchange/aex-gateway/internal/middleware"
+	"github.com/parlakisik/agent-exchange/aex-gateway/internal/openapi"
 	"github.com/parlakisik/agent-exchange/aex-gateway/internal/proxy"
 )
 
+// version is the gateway's API version, reported by /v1/info and in the
+// OpenAPI document.
+const version = "1.0.0"
+
+// Gateway is the gateway's HTTP handler. Reload swaps in a handler built
+// from new configuration; requests in flight finish on the one they
+// started with, so reloading drops none of them.
+type Gateway struct {
+	current atomic.Pointer[http.Handler]
+
+	// Built once and kept across reloads: the rate and concurrency limiters
+	// keep their buckets and slots, the metrics their counts, and the audit
+	// logger and tracer their forwarders, and the revocations the tenants and
+	// keys the identity service reported. The kill switches are reset to the
+	// configured state on each reload.
+	rateLimiter *middleware.RateLimiter
+	concurrency *middleware.ConcurrencyLimiter
+	metrics     *middleware.Metrics
+	auditLogger *middleware.AuditLogger
+	tracer      *middleware.Tracer
+	switches    *middleware.Switches
+	revocations *middleware.Revocations
+}
+
 func NewRouter(cfg *config.Config) http.Handler {
+	g, err := NewGateway(cfg)
+	if err != nil {
+		log.Fatalf("Failed to build the gateway: %v", err)
+	}
+	return g
+}
+
+func NewGateway(cfg *config.Config) (*Gateway, error) {
+	g := &Gateway{
+		rateLimiter: middleware.NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurstSize),
+		concurrency: middleware.NewConcurrencyLimiter(cfg.TenantMaxInFlight, cfg.TenantMaxQueued, cfg.TenantQueueTimeout),
+		metrics:     middleware.NewMetrics(),
+		tracer:      middleware.NewTracer(cfg.TraceTelemetryURL),
+		switches:    middleware.NewSwitches(proxy.ServicePrefixes()),
+		revocations: middleware.NewRevocations(),
+	}
+	if cfg.AuditLogEnabled {
+		g.auditLogger = middleware.NewAuditLogger(os.Stdout, middleware.AuditOptions{
+			BodySampleRate: cfg.AuditBodySampleRate,
+			MaxBodyBytes:   cfg.AuditMaxBodyBytes,
+			TelemetryURL:   cfg.AuditTelemetryURL,
+		})
+	}
+	if err := g.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*g.current.Load()).ServeHTTP(w, r)
+}
+
+// Reload applies cfg's upstreams, canaries, rate and concurrency limits,
+// kill switches, API key validation, timeouts and request validation. The
+// audit and tracing settings, and the port, take a restart. On error the
+// current configuration stays in place.
+func (g *Gateway) Reload(cfg *config.Config) error {
+	handler, err := g.build(cfg)
+	if err != nil {
+		return err
+	}
+	err = g.switches.Set(middleware.SwitchState{
+		Maintenance:    cfg.MaintenanceMode,
+		DisabledRoutes: cfg.DisabledRoutes,
+		RetryAfter:     cfg.MaintenanceRetryAfter,
+	})
+	if err != nil {
+		return fmt.Errorf("setting kill switches: %w", err)
+	}
+	g.rateLimiter.SetLimits(cfg.RateLimitPerMinute, cfg.RateLimitBurstSize)
+	g.concurrency.SetLimits(cfg.TenantMaxInFlight, cfg.TenantMaxQueued, cfg.TenantQueueTimeout)
+	g.current.Store(&handler)
+	return nil
+}
+
+func (g *Gateway) build(cfg *config.Config) (http.Handler, error) {
 	mux := http.NewServeMux()
 
 	// Create dependencies
-	apiKeyValidator := middleware.NewInMemoryAPIKeyValidator()
-	rateLimiter := middleware.NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurstSize)
+	var apiKeyValidator middleware.APIKeyValidator = middleware.NewInMemoryAPIKeyValidator()
+	if cfg.APIKeyValidator == "identity" {
+		apiKeyValidator = middleware.NewJWTValidator(
+			middleware.NewHTTPAPIKeyValidator(cfg.IdentityURL, cfg.APIKeyCacheTTL, cfg.APIKeyNegativeCacheTTL),
+			cfg.IdentityURL+"/.well-known/jwks.json", cfg.JWTIssuer)
+		apiKeyValidator = g.revocations.Validator(apiKeyValidator)
+	}
 	proxyRouter := proxy.NewRouter(cfg)
+	requestValidator, err := middleware.NewRequestValidator(middleware.ValidationOptions{
+		MaxBodyBytes:      cfg.MaxBodyBytes,
+		RouteMaxBodyBytes: cfg.RouteMaxBodyBytes,
+		SchemaDir:         cfg.SchemaDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading request schemas: %w", err)
+	}
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	openAPIDoc, err := openapi.Document(version, cfg.SchemaDir)
+	if err != nil {
+		return nil, fmt.Errorf("building the OpenAPI document: %w", err)
+	}
 
 	// Health endpoints (no auth required)
 	mux.HandleFunc("GET /health", healthHandler)
 	mux.HandleFunc("GET /ready", readyHandler)
 	mux.HandleFunc("GET /v1/info", infoHandler)
+	mux.HandleFunc("GET /v2/info", infoHandler)
+	mux.Handle("GET /v1/system/health", newSystemHealth(cfg))
+	mux.HandleFunc("GET /openapi.json", openAPIHandler(openAPIDoc))
+	mux.Handle("GET /metrics", middleware.MetricsHandler(g.metrics, proxyRouter.BreakerStates))
+
+	// Token exchange and the keys tokens are verified with; the identity
+	// service authenticates the API key itself
+	if identity, err := url.Parse(cfg.IdentityURL); err == nil {
+		identityProxy := httputil.NewSingleHostReverseProxy(identity)
+		mux.Handle("POST /v1/token", identityProxy)
+		mux.Handle("GET /.well-known/jwks.json", identityProxy)
+	}
+
+	// Admin endpoints, authenticated with the admin token
+	if cfg.AdminToken != "" {
+		mux.Handle("/admin/switches", middleware.SwitchesAdmin(g.switches, cfg.AdminToken))
+	}
+
+	// Tenant suspensions and key revocations from the identity service,
+	// authenticated by their signature
+	if cfg.IdentityWebhookSecret != "" {
+		mux.Handle("POST /internal/identity/events", middleware.IdentityEvents(g.revocations, cfg.IdentityWebhookSecret))
+	}
 
 	// OPTIONS preflight handler (no auth required)
 	mux.HandleFunc("OPTIONS /v1/", preflightHandler)
+	mux.HandleFunc("OPTIONS /v2/", preflightHandler)
 
-	// API routes with middleware stack
-	apiHandler := applyMiddleware(proxyRouter,
-		middleware.RateLimit(rateLimiter),
+	// API routes with middleware stack; every request is counted, and
+	// disabled routes are turned away before anything else, sparing the
+	// identity service during incidents; rate limits are per API key and
+	// audit records and spans per tenant, so authentication comes next;
+	// bodies are validated last, once the caller is known to be allowed
+	apiMiddleware := []func(http.Handler) http.Handler{
+		middleware.Instrument(g.metrics),
+		middleware.KillSwitch(g.switches),
 		middleware.Auth(apiKeyValidator),
+		middleware.Tracing(g.tracer),
+	}
+	if g.auditLogger != nil {
+		apiMiddleware = append(apiMiddleware, middleware.Audit(g.auditLogger))
+	}
+	// Requests over their rate limit never take a concurrency slot.
+	// Versions are translated after the audit log, which records requests as
+	// sent, and before key restrictions and scopes are checked and bodies
+	// validated against the upstream routes and current schemas
+	apiMiddleware = append(apiMiddleware,
+		middleware.RateLimit(g.rateLimiter),
+		middleware.ConcurrencyLimit(g.concurrency),
+		middleware.APIVersion,
+		middleware.Restrict(trustedProxies),
+		middleware.Authorize,
+		middleware.Validate(requestValidator),
 	)
+	apiHandler := applyMiddleware(proxyRouter, apiMiddleware...)
 
-	// Mount API handler for all /v1/* paths
+	// Mount API handler for all /v1/* and /v2/* paths
 	mux.Handle("/v1/", apiHandler)
+	mux.Handle("/v2/", apiHandler)
 
 	// Apply global middleware
 	handler := applyMiddleware(mux,
@@ -41,9 +197,10 @@ func NewRouter(cfg *config.Config) http.Handler {
 		middleware.Recovery,
 		middleware.Logging,
 		middleware.RequestID,
+		middleware.Compress(cfg.CompressionMinBytes),
 	)
 
-	return handler
+	return handler, nil
 }
 
 func applyMiddleware(handler http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
@@ -70,12 +227,22 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(map[string]any{
-		"name":    "Agent Exchange Gateway",
-		"version": "1.0.0",
-		"phase":   "A",
+		"name":         "Agent Exchange Gateway",
+		"version":      version,
+		"phase":        "A",
+		"api_versions": []string{"v1", "v2"},
 	})
 }
 
+// openAPIHandler serves the OpenAPI document of the public API.
+func openAPIHandler(doc []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(doc)
+	}
+}
+
 func preflightHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-gateway/internal/config"
+)
+
+// upstreamHealthTimeout bounds one upstream health check.
+const upstreamHealthTimeout = 3 * time.Second
+
+type UpstreamHealth struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	StatusCode int     `json:"status_code,omitempty"`
+	LatencyMs  float64 `json:"latency_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// systemHealth checks the /health endpoint of every configured upstream.
+type systemHealth struct {
+	upstreams map[string]string
+	client    *http.Client
+}
+
+func newSystemHealth(cfg *config.Config) *systemHealth {
+	upstreams := map[string]string{}
+	for name, url := range map[string]string{
+		"work-publisher":    cfg.WorkPublisherURL,
+		"provider-registry": cfg.ProviderRegistryURL,
+		"settlement":        cfg.SettlementURL,
+		"bid-gateway":       cfg.BidGatewayURL,
+		"bid-evaluator":     cfg.BidEvaluatorURL,
+		"contract-engine":   cfg.ContractEngineURL,
+		"trust-broker":      cfg.TrustBrokerURL,
+		"identity":          cfg.IdentityURL,
+		"telemetry":         cfg.TelemetryURL,
+	} {
+		if url != "" {
+			upstreams[name] = url
+		}
+	}
+	return &systemHealth{
+		upstreams: upstreams,
+		client:    &http.Client{Timeout: upstreamHealthTimeout},
+	}
+}
+
+// ServeHTTP handles GET /v1/system/health
+//
+// It checks all upstreams concurrently. The verdict is healthy when all
+// are, unhealthy when none is and degraded otherwise; anything but healthy
+// answers 503 so the endpoint can gate readiness.
+func (h *systemHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	results := make([]UpstreamHealth, 0, len(h.upstreams))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, url := range h.upstreams {
+		wg.Add(1)
+		go func(name, url string) {
+			defer wg.Done()
+			res := h.check(r.Context(), name, url)
+			mu.Lock()
+			results = append(results, res)
+			mu.Unlock()
+		}(name, url)
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	healthy := 0
+	for _, res := range results {
+		if res.Status == "healthy" {
+			healthy++
+		}
+	}
+	status, code := "degraded", http.StatusServiceUnavailable
+	switch healthy {
+	case len(results):
+		status, code = "healthy", http.StatusOK
+	case 0:
+		status = "unhealthy"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status":     status,
+		"services":   results,
+		"checked_at": time.Now().UTC(),
+	})
+}
+
+func (h *systemHealth) check(ctx context.Context, name, url string) UpstreamHealth {
+	res := UpstreamHealth{Name: name, Status: "unhealthy"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/health", nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	start := time.Now()
+	resp, err := h.client.Do(req)
+	res.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	res.StatusCode = resp.StatusCode
+	if resp.StatusCode == http.StatusOK {
+		res.Status = "healthy"
+	}
+	return res
+}
@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+const RouteKey contextKey = "route"
+
+const redacted = "[REDACTED]"
+
+// redactedHeaders hold credentials and are never logged.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"X-Api-Key":           true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// AuditOptions configure the audit log. BodySampleRate is the fraction of
+// requests, from 0 to 1, whose request and response bodies are logged, up
+// to MaxBodyBytes each. TelemetryURL, when set, is the aex-telemetry
+// service the records are also sent to as logs.
+type AuditOptions struct {
+	BodySampleRate float64
+	MaxBodyBytes   int
+	TelemetryURL   string
+}
+
+// AuditLogger writes one structured record per proxied request.
+type AuditLogger struct {
+	logger    *slog.Logger
+	opts      AuditOptions
+	forwarder *telemetryForwarder[telemetryLog]
+}
+
+// routeInfo is filled in by the proxy with where the request went, for the
+// audit log, the request's span and metrics, and by the limits with which
+// of them turned it away.
+type routeInfo struct {
+	route    string
+	upstream string
+	rejected string
+}
+
+// telemetryLog is a log entry of the aex-telemetry ingestion API.
+type telemetryLog struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Level     string         `json:"level"`
+	Service   string         `json:"service"`
+	Message   string         `json:"message"`
+	Fields    map[string]any `json:"fields"`
+	TraceID   string         `json:"trace_id,omitempty"`
+	SpanID    string         `json:"span_id,omitempty"`
+}
+
+func NewAuditLogger(out io.Writer, opts AuditOptions) *AuditLogger {
+	a := &AuditLogger{
+		logger: slog.New(slog.NewJSONHandler(out, nil)),
+		opts:   opts,
+	}
+	if opts.TelemetryURL != "" {
+		a.forwarder = newTelemetryForwarder[telemetryLog](opts.TelemetryURL, "/v1/logs")
+	}
+	return a
+}
+
+// SetRoute records the route and upstream a request is proxied to.
+func SetRoute(ctx context.Context, route, upstream string) {
+	if info, ok := ctx.Value(RouteKey).(*routeInfo); ok {
+		info.route = route
+		info.upstream = upstream
+	}
+}
+
+// withRouteInfo returns the request's routeInfo, adding one to r if an
+// outer middleware has not.
+func withRouteInfo(r *http.Request) (*http.Request, *routeInfo) {
+	if info, ok := r.Context().Value(RouteKey).(*routeInfo); ok {
+		return r, info
+	}
+	info := &routeInfo{}
+	return r.WithContext(context.WithValue(r.Context(), RouteKey, info)), info
+}
+
+// Audit logs every request that reaches it, attributed to the tenant Auth
+// found. It must run after Auth.
+func Audit(a *AuditLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			// The proxy rewrites the headers; log them as received.
+			headers := redactHeaders(r.Header)
+			r, info := withRouteInfo(r)
+
+			sampled := a.opts.BodySampleRate > 0 && rand.Float64() < a.opts.BodySampleRate
+			var reqBody *capture
+			if sampled && r.Body != nil && r.Body != http.NoBody {
+				reqBody = &capture{max: a.opts.MaxBodyBytes}
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.TeeReader(r.Body, reqBody), r.Body}
+			}
+			wrapped := &auditWriter{ResponseWriter: w, status: http.StatusOK}
+			if sampled {
+				wrapped.body = &capture{max: a.opts.MaxBodyBytes}
+			}
+
+			next.ServeHTTP(wrapped, r)
+
+			fields := map[string]any{
+				"request_id":     GetRequestID(r.Context()),
+				"tenant_id":      GetTenantID(r.Context()),
+				"method":         r.Method,
+				"route":          info.route,
+				"path":           r.URL.Path,
+				"upstream":       info.upstream,
+				"status":         wrapped.status,
+				"latency_ms":     float64(time.Since(start).Microseconds()) / 1000,
+				"response_bytes": wrapped.size,
+				"remote_addr":    r.RemoteAddr,
+				"headers":        headers,
+			}
+			if reqBody != nil {
+				fields["request_body"] = reqBody.String()
+			}
+			if wrapped.body != nil {
+				fields["response_body"] = wrapped.body.String()
+			}
+			a.record(r.Context(), fields)
+		})
+	}
+}
+
+func (a *AuditLogger) record(ctx context.Context, fields map[string]any) {
+	status, _ := fields["status"].(int)
+	level, slogLevel := "info", slog.LevelInfo
+	switch {
+	case status >= 500:
+		level, slogLevel = "error", slog.LevelError
+	case status >= 400:
+		level, slogLevel = "warn", slog.LevelWarn
+	}
+
+	attrs := make([]slog.Attr, 0, len(fields))
+	for k, v := range fields {
+		attrs = append(attrs, slog.Any(k, v))
+	}
+	tc, traced := GetTraceContext(ctx)
+	if traced {
+		attrs = append(attrs, slog.String("trace_id", tc.TraceID), slog.String("span_id", tc.SpanID))
+	}
+	a.logger.LogAttrs(ctx, slogLevel, "proxied request", attrs...)
+
+	if a.forwarder == nil {
+		return
+	}
+	if !a.forwarder.enqueue(telemetryLog{
+		Timestamp: time.Now().UTC(),
+		Level:     level,
+		Service:   "aex-gateway",
+		Message:   "proxied request",
+		Fields:    fields,
+		TraceID:   tc.TraceID,
+		SpanID:    tc.SpanID,
+	}) {
+		log.Printf("audit: forward queue full, dropping record request_id=%v", fields["request_id"])
+	}
+}
+
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			out[k] = redacted
+			continue
+		}
+		if len(v) > 0 {
+			out[k] = v[0]
+		}
+	}
+	return out
+}
+
+// capture keeps the first max bytes written to it.
+type capture struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	if room := c.max - c.buf.Len(); room < len(p) {
+		c.truncated = true
+		c.buf.Write(p[:max(room, 0)])
+	} else {
+		c.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (c *capture) String() string {
+	if c.truncated {
+		return c.buf.String() + "...[truncated]"
+	}
+	return c.buf.String()
+}
+
+type auditWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+	body        *capture
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	if w.body != nil {
+		_, _ = w.body.Write(b[:n])
+	}
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streamed responses are still flushed.
+func (w *auditWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
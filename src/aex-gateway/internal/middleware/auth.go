@@ -2,8 +2,12 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +15,9 @@ import (
 
 const TenantIDKey contextKey = "tenant_id"
 const RolesKey contextKey = "roles"
+const QuotasKey contextKey = "quotas"
+const RateLimitKey contextKey = "rate_limit_key"
+const UserKey contextKey = "user"
 
 // APIKeyValidator validates API keys against the identity service
 type APIKeyValidator interface {
@@ -18,9 +25,32 @@ type APIKeyValidator interface {
 }
 
 type APIKeyInfo struct {
+	// KeyID identifies the key, or the key a token was issued for, when
+	// the identity service reports it.
+	KeyID    string   `json:"key_id,omitempty"`
 	TenantID string   `json:"tenant_id"`
 	Scopes   []string `json:"scopes"`
 	Status   string   `json:"status"`
+	Quotas   Quotas   `json:"quotas"`
+	// UserID and UserRole are set for the keys of tenant users, whose role
+	// the identity service checks on its management endpoints.
+	UserID   string `json:"user_id,omitempty"`
+	UserRole string `json:"user_role,omitempty"`
+	// Restrictions limit the addresses and routes the key may be used for.
+	Restrictions KeyRestrictions `json:"-"`
+}
+
+// User is the tenant user an API key belongs to.
+type User struct {
+	ID   string
+	Role string
+}
+
+// Quotas are the request limits of the key's tenant in the identity
+// service; zero means the gateway default.
+type Quotas struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	RequestsPerDay    int `json:"requests_per_day"`
 }
 
 // InMemoryAPIKeyValidator is a simple in-memory validator for development
@@ -61,12 +91,22 @@ func (v *InMemoryAPIKeyValidator) AddKey(apiKey string, info *APIKeyInfo) {
 	v.keys[apiKey] = info
 }
 
-// HTTPAPIKeyValidator validates API keys via HTTP call to identity service
+// maxCachedKeys bounds the validation cache, which unknown keys would
+// otherwise grow without limit.
+const maxCachedKeys = 100000
+
+// HTTPAPIKeyValidator validates API keys via HTTP call to identity service.
+// Results are cached by key hash, valid keys for cacheTTL or the max-age the
+// identity service allows if that is shorter, invalid keys for the shorter
+// negativeTTL so a newly created key works soon; failures to reach the
+// service are not cached.
 type HTTPAPIKeyValidator struct {
 	identityURL string
 	client      *http.Client
-	cache       sync.Map
+	mu          sync.Mutex
+	cache       map[string]cachedKey
 	cacheTTL    time.Duration
+	negativeTTL time.Duration
 }
 
 type cachedKey struct {
@@ -74,106 +114,187 @@ type cachedKey struct {
 	expiresAt time.Time
 }
 
-func NewHTTPAPIKeyValidator(identityURL string) *HTTPAPIKeyValidator {
+func NewHTTPAPIKeyValidator(identityURL string, cacheTTL, negativeTTL time.Duration) *HTTPAPIKeyValidator {
 	return &HTTPAPIKeyValidator{
 		identityURL: identityURL,
 		client:      &http.Client{Timeout: 5 * time.Second},
-		cacheTTL:    5 * time.Minute,
+		cache:       make(map[string]cachedKey),
+		cacheTTL:    cacheTTL,
+		negativeTTL: negativeTTL,
 	}
 }
 
 func (v *HTTPAPIKeyValidator) Validate(ctx context.Context, apiKey string) (*APIKeyInfo, error) {
 	// Check cache first
-	if cached, ok := v.cache.Load(apiKey); ok {
-		c := cached.(*cachedKey)
-		if time.Now().Before(c.expiresAt) {
-			return c.info, nil
+	hash := hashKey(apiKey)
+	v.mu.Lock()
+	c, ok := v.cache[hash]
+	v.mu.Unlock()
+	if ok && time.Now().Before(c.expiresAt) {
+		return c.info, nil
+	}
+
+	info, maxAge, err := v.lookup(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	ttl := min(v.cacheTTL, maxAge)
+	if info == nil {
+		ttl = v.negativeTTL
+	}
+	if ttl > 0 {
+		v.store(hash, cachedKey{info: info, expiresAt: time.Now().Add(ttl)})
+	}
+	return info, nil
+}
+
+func (v *HTTPAPIKeyValidator) store(hash string, c cachedKey) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.cache) >= maxCachedKeys {
+		now := time.Now()
+		for k, old := range v.cache {
+			if now.After(old.expiresAt) {
+				delete(v.cache, k)
+			}
+		}
+		if len(v.cache) >= maxCachedKeys {
+			return
 		}
-		v.cache.Delete(apiKey)
 	}
+	v.cache[hash] = c
+}
 
-	// Call identity service
+// lookup asks the identity service about apiKey; nil means it is invalid.
+// It also returns how long a valid key's validation may be cached.
+func (v *HTTPAPIKeyValidator) lookup(ctx context.Context, apiKey string) (*APIKeyInfo, time.Duration, error) {
 	reqBody, _ := json.Marshal(map[string]string{"api_key": apiKey})
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.identityURL+"/internal/v1/apikeys/validate", strings.NewReader(string(reqBody)))
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := v.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return nil, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("identity service returned %d", resp.StatusCode)
+	}
+	maxAge := v.cacheTTL
+	if seconds, ok := cacheMaxAge(resp.Header.Get("Cache-Control")); ok {
+		maxAge = time.Duration(seconds) * time.Second
 	}
 
 	var result struct {
-		Valid    bool     `json:"valid"`
-		TenantID string   `json:"tenant_id"`
-		Scopes   []string `json:"scopes"`
+		Valid    *bool        `json:"valid"`
+		KeyID    string       `json:"key_id"`
+		TenantID string       `json:"tenant_id"`
+		Scopes   []string     `json:"scopes"`
+		Grants   []scopeGrant `json:"grants"`
+		Quotas   Quotas       `json:"quotas"`
+		UserID   string       `json:"user_id"`
+		UserRole string       `json:"user_role"`
+
+		AllowedCIDRs  []string `json:"allowed_cidrs"`
+		AllowedRoutes []string `json:"allowed_routes"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	if !result.Valid {
-		return nil, nil
+	// The identity service answers 200 only for valid keys.
+	if (result.Valid != nil && !*result.Valid) || result.TenantID == "" {
+		return nil, 0, nil
 	}
 
-	info := &APIKeyInfo{
+	scopes := result.Scopes
+	if len(result.Grants) > 0 {
+		scopes = grantScopes(result.Grants)
+	}
+	return &APIKeyInfo{
+		KeyID:    result.KeyID,
 		TenantID: result.TenantID,
-		Scopes:   result.Scopes,
+		Scopes:   scopes,
 		Status:   "ACTIVE",
-	}
+		Quotas:   result.Quotas,
+		UserID:   result.UserID,
+		UserRole: result.UserRole,
 
-	// Cache the result
-	v.cache.Store(apiKey, &cachedKey{
-		info:      info,
-		expiresAt: time.Now().Add(v.cacheTTL),
-	})
+		Restrictions: parseRestrictions(result.AllowedCIDRs, result.AllowedRoutes),
+	}, maxAge, nil
+}
 
-	return info, nil
+// cacheMaxAge returns the max-age of a Cache-Control header, or 0 for
+// no-store and no-cache; ok is false when it has neither.
+func cacheMaxAge(cacheControl string) (seconds int, ok bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-store" || directive == "no-cache" {
+			return 0, true
+		}
+		if v, found := strings.CutPrefix(directive, "max-age="); found {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				return n, true
+			}
+		}
+	}
+	return 0, false
 }
 
+// Auth validates the X-API-Key header, or a bearer token holding an API
+// key or an identity token, and puts the key's tenant, scopes and quotas in
+// the request context.
 func Auth(validator APIKeyValidator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// 1. Check API Key header
-			if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
-				info, err := validator.Validate(r.Context(), apiKey)
-				if err != nil {
-					respondError(w, http.StatusInternalServerError, "auth_error", "Authentication service unavailable", r)
+			// 1. Check API Key header, then 2. Bearer token
+			apiKey, code, message := r.Header.Get("X-API-Key"), "invalid_api_key", "Invalid API key"
+			if apiKey == "" {
+				auth := r.Header.Get("Authorization")
+				if !strings.HasPrefix(auth, "Bearer ") {
+					respondError(w, http.StatusUnauthorized, "authentication_required", "Authentication required", r)
 					return
 				}
-				if info == nil {
-					respondError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid API key", r)
+				apiKey, code, message = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")), "invalid_token", "Invalid bearer token"
+				if apiKey == "" {
+					respondError(w, http.StatusUnauthorized, code, message, r)
 					return
 				}
-				ctx := context.WithValue(r.Context(), TenantIDKey, info.TenantID)
-				ctx = context.WithValue(ctx, RolesKey, info.Scopes)
-				next.ServeHTTP(w, r.WithContext(ctx))
-				return
 			}
 
-			// 2. Check Bearer token
-			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
-				token := strings.TrimPrefix(auth, "Bearer ")
-				// For Phase A, we accept any non-empty bearer token with a development tenant
-				// In production, this would validate against Firebase Auth
-				if token != "" {
-					ctx := context.WithValue(r.Context(), TenantIDKey, "tenant_bearer")
-					ctx = context.WithValue(ctx, RolesKey, []string{"*"})
-					next.ServeHTTP(w, r.WithContext(ctx))
-					return
-				}
-				respondError(w, http.StatusUnauthorized, "invalid_token", "Invalid bearer token", r)
+			info, err := validator.Validate(r.Context(), apiKey)
+			if err != nil {
+				respondError(w, http.StatusServiceUnavailable, "auth_error", "Authentication service unavailable", r)
 				return
 			}
-
-			respondError(w, http.StatusUnauthorized, "authentication_required", "Authentication required", r)
+			if info == nil {
+				respondError(w, http.StatusUnauthorized, code, message, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), TenantIDKey, info.TenantID)
+			ctx = context.WithValue(ctx, RolesKey, info.Scopes)
+			ctx = context.WithValue(ctx, QuotasKey, info.Quotas)
+			if info.UserID != "" {
+				ctx = context.WithValue(ctx, UserKey, User{ID: info.UserID, Role: info.UserRole})
+			}
+			if !info.Restrictions.empty() {
+				ctx = context.WithValue(ctx, RestrictionsKey, info.Restrictions)
+			}
+			rateLimitKey := "key:" + hashKey(apiKey)
+			if isJWT(apiKey) {
+				// Each token is new, so limit them by tenant.
+				rateLimitKey = "tenant:" + info.TenantID
+			}
+			ctx = context.WithValue(ctx, RateLimitKey, rateLimitKey)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
@@ -192,6 +313,34 @@ func GetRoles(ctx context.Context) []string {
 	return nil
 }
 
+// GetUser returns the tenant user whose API key authenticated the request;
+// ok is false for keys without a user.
+func GetUser(ctx context.Context) (u User, ok bool) {
+	u, ok = ctx.Value(UserKey).(User)
+	return u, ok
+}
+
+// GetQuotas returns the request quotas of the authenticated caller.
+func GetQuotas(ctx context.Context) Quotas {
+	q, _ := ctx.Value(QuotasKey).(Quotas)
+	return q
+}
+
+// GetRateLimitKey returns the key the caller is rate limited by: its API
+// key, or its tenant for bearer tokens.
+func GetRateLimitKey(ctx context.Context) string {
+	if key, ok := ctx.Value(RateLimitKey).(string); ok {
+		return key
+	}
+	return ""
+}
+
+// hashKey identifies an API key without keeping the secret in memory.
+func hashKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
 func respondError(w http.ResponseWriter, status int, code, message string, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
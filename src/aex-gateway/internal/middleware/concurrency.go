@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	errQueueFull    = errors.New("too many requests queued")
+	errQueueTimeout = errors.New("timed out waiting for a request slot")
+)
+
+// ConcurrencyLimiter caps the requests each tenant has in flight. Requests
+// past the cap wait in the tenant's own bounded queue and are admitted in
+// arrival order as the tenant's earlier requests finish, so a burst from
+// one tenant queues behind itself rather than in front of everyone else.
+type ConcurrencyLimiter struct {
+	mu           sync.Mutex
+	maxInFlight  int
+	maxQueued    int
+	queueTimeout time.Duration
+	tenants      map[string]*tenantSlots
+}
+
+type tenantSlots struct {
+	inFlight int
+	waiting  []chan struct{} // closed to hand the waiter a slot
+}
+
+// NewConcurrencyLimiter allows each tenant maxInFlight requests at once and
+// maxQueued more waiting up to queueTimeout for one to finish. A
+// maxInFlight of zero or less disables the limit.
+func NewConcurrencyLimiter(maxInFlight, maxQueued int, queueTimeout time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		maxInFlight:  maxInFlight,
+		maxQueued:    maxQueued,
+		queueTimeout: queueTimeout,
+		tenants:      make(map[string]*tenantSlots),
+	}
+}
+
+// SetLimits changes the limits. Requests in flight or queued keep their
+// places; a raised cap admits queued requests as the next slot is
+// released.
+func (l *ConcurrencyLimiter) SetLimits(maxInFlight, maxQueued int, queueTimeout time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxInFlight = maxInFlight
+	l.maxQueued = maxQueued
+	l.queueTimeout = queueTimeout
+}
+
+// Acquire takes one of key's slots, waiting in its queue when they are all
+// in use. The returned function gives the slot back.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, key string) (func(), error) {
+	l.mu.Lock()
+	if l.maxInFlight <= 0 {
+		l.mu.Unlock()
+		return func() {}, nil
+	}
+	t, ok := l.tenants[key]
+	if !ok {
+		t = &tenantSlots{}
+		l.tenants[key] = t
+	}
+	release := func() { l.release(key) }
+	if t.inFlight < l.maxInFlight && len(t.waiting) == 0 {
+		t.inFlight++
+		l.mu.Unlock()
+		return release, nil
+	}
+	if len(t.waiting) >= l.maxQueued {
+		l.mu.Unlock()
+		return nil, errQueueFull
+	}
+	ready := make(chan struct{})
+	t.waiting = append(t.waiting, ready)
+	timeout := l.queueTimeout
+	l.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	err := errQueueTimeout
+	select {
+	case <-ready:
+		return release, nil
+	case <-timer.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	for i, ch := range t.waiting {
+		if ch == ready {
+			t.waiting = append(t.waiting[:i], t.waiting[i+1:]...)
+			l.mu.Unlock()
+			return nil, err
+		}
+	}
+	l.mu.Unlock()
+	// The slot was handed over as the wait ended; pass it on.
+	release()
+	return nil, err
+}
+
+// release frees one of key's slots and admits as many queued requests as
+// the cap now allows.
+func (l *ConcurrencyLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := l.tenants[key]
+	t.inFlight--
+	for len(t.waiting) > 0 && (l.maxInFlight <= 0 || t.inFlight < l.maxInFlight) {
+		close(t.waiting[0])
+		t.waiting = t.waiting[1:]
+		t.inFlight++
+	}
+	if t.inFlight == 0 {
+		delete(l.tenants, key)
+	}
+}
+
+// ConcurrencyLimit holds each request until its tenant has a free slot,
+// answering 429 when the tenant's queue is full or the wait times out. It
+// must run after Auth.
+func ConcurrencyLimit(limiter *ConcurrencyLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := GetTenantID(r.Context())
+			if key == "" {
+				key = "anonymous"
+			}
+			release, err := limiter.Acquire(r.Context(), key)
+			if err != nil {
+				message := "Too many concurrent requests for this tenant"
+				if errors.Is(err, errQueueTimeout) || errors.Is(err, context.DeadlineExceeded) {
+					message = "Timed out waiting for one of this tenant's requests to finish"
+				}
+				noteRejection(r.Context(), "concurrency")
+				w.Header().Set("Retry-After", "1")
+				respondError(w, http.StatusTooManyRequests, "concurrency_limit_exceeded", message, r)
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -48,7 +48,11 @@ func CORSAllowAll(next http.Handler) http.Handler {
 			"X-API-Key",
 			"X-Request-ID",
 			"X-Idempotency-Key",
+			"X-Canary",
+			"traceparent",
+			"tracestate",
 		}, ", "))
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Trace-ID, X-Canary")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 
 		// Handle preflight immediately
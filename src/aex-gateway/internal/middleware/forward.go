@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// forwardQueueSize is how many items may wait to be forwarded; more
+	// are dropped rather than slowing requests down.
+	forwardQueueSize = 1000
+	// forwardBatchSize and forwardFlushInterval bound how long an item
+	// waits before it is forwarded.
+	forwardBatchSize     = 100
+	forwardFlushInterval = time.Second
+)
+
+// telemetryForwarder sends items to an aex-telemetry ingestion endpoint in
+// batches, in the background.
+type telemetryForwarder[T any] struct {
+	url    string
+	queue  chan T
+	client *http.Client
+}
+
+// newTelemetryForwarder starts forwarding to path of the aex-telemetry
+// service at baseURL.
+func newTelemetryForwarder[T any](baseURL, path string) *telemetryForwarder[T] {
+	f := &telemetryForwarder[T]{
+		url:    baseURL + path,
+		queue:  make(chan T, forwardQueueSize),
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+	go f.run()
+	return f
+}
+
+// enqueue queues item without blocking; it reports false if the queue is
+// full and the item was dropped.
+func (f *telemetryForwarder[T]) enqueue(item T) bool {
+	select {
+	case f.queue <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *telemetryForwarder[T]) run() {
+	ticker := time.NewTicker(forwardFlushInterval)
+	defer ticker.Stop()
+	batch := make([]T, 0, forwardBatchSize)
+	for {
+		select {
+		case item := <-f.queue:
+			batch = append(batch, item)
+			if len(batch) < forwardBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := f.send(batch); err != nil {
+			log.Printf("telemetry: forwarding %d items to %s failed: %v", len(batch), f.url, err)
+		}
+		batch = batch[:0]
+	}
+}
+
+func (f *telemetryForwarder[T]) send(batch []T) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Post(f.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval bounds how often tokens naming an unknown key make
+// the validator fetch the key set again.
+const jwksRefreshInterval = time.Minute
+
+// JWTValidator verifies the tokens the identity service exchanges for API
+// keys locally, against its published key set, and passes API keys on to
+// the next validator. Tokens stay valid until they expire, even if their
+// tenant is suspended meanwhile, unless the gateway hears of it through
+// Revocations; that is why they are short-lived.
+type JWTValidator struct {
+	next    APIKeyValidator
+	jwksURL string
+	issuer  string
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*ecdsa.PublicKey // kid -> key
+	fetchedAt time.Time
+}
+
+func NewJWTValidator(next APIKeyValidator, jwksURL, issuer string) *JWTValidator {
+	return &JWTValidator{
+		next:    next,
+		jwksURL: jwksURL,
+		issuer:  issuer,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		keys:    make(map[string]*ecdsa.PublicKey),
+	}
+}
+
+// isJWT reports whether a credential is a token rather than an API key.
+func isJWT(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
+
+func (v *JWTValidator) Validate(ctx context.Context, credential string) (*APIKeyInfo, error) {
+	if !isJWT(credential) {
+		return v.next.Validate(ctx, credential)
+	}
+	parts := strings.Split(credential, ".")
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "ES256" {
+		return nil, nil
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return nil, nil
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return nil, nil
+	}
+
+	var claims struct {
+		Issuer    string       `json:"iss"`
+		KeyID     string       `json:"key_id"`
+		TenantID  string       `json:"tenant_id"`
+		Scopes    []string     `json:"scopes"`
+		Grants    []scopeGrant `json:"grants"`
+		Quotas    Quotas       `json:"quotas"`
+		UserID    string       `json:"user_id"`
+		UserRole  string       `json:"user_role"`
+		ExpiresAt int64        `json:"exp"`
+
+		AllowedCIDRs  []string `json:"allowed_cidrs"`
+		AllowedRoutes []string `json:"allowed_routes"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, nil
+	}
+	if claims.Issuer != v.issuer || claims.TenantID == "" || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, nil
+	}
+	scopes := claims.Scopes
+	if len(claims.Grants) > 0 {
+		scopes = grantScopes(claims.Grants)
+	}
+	return &APIKeyInfo{
+		KeyID:    claims.KeyID,
+		TenantID: claims.TenantID,
+		Scopes:   scopes,
+		Status:   "ACTIVE",
+		Quotas:   claims.Quotas,
+		UserID:   claims.UserID,
+		UserRole: claims.UserRole,
+
+		Restrictions: parseRestrictions(claims.AllowedCIDRs, claims.AllowedRoutes),
+	}, nil
+}
+
+// key returns the public key named kid, fetching the key set when it is
+// unknown; nil means no such key.
+func (v *JWTValidator) key(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(v.fetchedAt) < jwksRefreshInterval {
+		return nil, nil
+	}
+	keys, err := v.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys, v.fetchedAt = keys, time.Now()
+	return v.keys[kid], nil
+}
+
+// fetch reads the P-256 keys of the key set.
+func (v *JWTValidator) fetch(ctx context.Context) (map[string]*ecdsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("identity service returned %d for its key set", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*ecdsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "EC" || k.Crv != "P-256" {
+			continue
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			continue
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency
+// histogram.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// breakerStates are reported for every upstream, 1 for its current state.
+var breakerStates = []string{"closed", "half-open", "open"}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+type requestLabels struct {
+	route, upstream, method, statusClass string
+}
+
+type latencyLabels struct {
+	route, upstream string
+}
+
+// Metrics holds the counters served on /metrics.
+type Metrics struct {
+	mu         sync.Mutex
+	requests   map[requestLabels]uint64
+	latency    map[latencyLabels]*histogram
+	rejections map[string]uint64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:   map[requestLabels]uint64{},
+		latency:    map[latencyLabels]*histogram{},
+		rejections: map[string]uint64{},
+	}
+}
+
+func (m *Metrics) observe(l requestLabels, d time.Duration, rejected string) {
+	secs := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[l]++
+	if rejected != "" {
+		m.rejections[rejected]++
+	}
+	key := latencyLabels{l.route, l.upstream}
+	h := m.latency[key]
+	if h == nil {
+		h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		m.latency[key] = h
+	}
+	for i, le := range latencyBuckets {
+		if secs <= le {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += secs
+}
+
+// noteRejection records which limit turned the request away, for the
+// rejection counters.
+func noteRejection(ctx context.Context, reason string) {
+	if info, ok := ctx.Value(RouteKey).(*routeInfo); ok {
+		info.rejected = reason
+	}
+}
+
+// Instrument counts every request that reaches it and times it, by the
+// route and upstream the proxy chose; requests turned away before the
+// proxy are counted under the route "none".
+func Instrument(m *Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r, info := withRouteInfo(r)
+			wrapped := &auditWriter{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(wrapped, r)
+
+			route := info.route
+			if route == "" {
+				route = "none"
+			}
+			m.observe(requestLabels{
+				route:       route,
+				upstream:    info.upstream,
+				method:      r.Method,
+				statusClass: strconv.Itoa(wrapped.status/100) + "xx",
+			}, time.Since(start), info.rejected)
+		})
+	}
+}
+
+// MetricsHandler serves the Prometheus text exposition of request counts
+// and latencies, rate limit rejections and, from breakers, the state of
+// each upstream's circuit breaker.
+func MetricsHandler(m *Metrics, breakers func() map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		requests := make(map[requestLabels]uint64, len(m.requests))
+		for l, n := range m.requests {
+			requests[l] = n
+		}
+		latency := make(map[latencyLabels]histogram, len(m.latency))
+		for l, h := range m.latency {
+			latency[l] = histogram{buckets: slices.Clone(h.buckets), count: h.count, sum: h.sum}
+		}
+		rejections := make(map[string]uint64, len(m.rejections))
+		for reason, n := range m.rejections {
+			rejections[reason] = n
+		}
+		m.mu.Unlock()
+		states := breakers()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		defer func() { _ = bw.Flush() }()
+
+		fmt.Fprintln(bw, "# HELP aex_gateway_requests_total API requests, by route, upstream, method and status class.")
+		fmt.Fprintln(bw, "# TYPE aex_gateway_requests_total counter")
+		requestKeys := make([]requestLabels, 0, len(requests))
+		for l := range requests {
+			requestKeys = append(requestKeys, l)
+		}
+		slices.SortFunc(requestKeys, func(a, b requestLabels) int {
+			return cmp.Or(cmp.Compare(a.route, b.route), cmp.Compare(a.upstream, b.upstream),
+				cmp.Compare(a.method, b.method), cmp.Compare(a.statusClass, b.statusClass))
+		})
+		for _, l := range requestKeys {
+			fmt.Fprintf(bw, "aex_gateway_requests_total{route=%q,upstream=%q,method=%q,status=%q} %d\n",
+				l.route, l.upstream, l.method, l.statusClass, requests[l])
+		}
+
+		fmt.Fprintln(bw, "# HELP aex_gateway_request_duration_seconds Time taken to answer API requests, by route and upstream.")
+		fmt.Fprintln(bw, "# TYPE aex_gateway_request_duration_seconds histogram")
+		latencyKeys := make([]latencyLabels, 0, len(latency))
+		for l := range latency {
+			latencyKeys = append(latencyKeys, l)
+		}
+		slices.SortFunc(latencyKeys, func(a, b latencyLabels) int {
+			return cmp.Or(cmp.Compare(a.route, b.route), cmp.Compare(a.upstream, b.upstream))
+		})
+		for _, l := range latencyKeys {
+			h := latency[l]
+			for i, le := range latencyBuckets {
+				fmt.Fprintf(bw, "aex_gateway_request_duration_seconds_bucket{route=%q,upstream=%q,le=%q} %d\n",
+					l.route, l.upstream, strconv.FormatFloat(le, 'g', -1, 64), h.buckets[i])
+			}
+			fmt.Fprintf(bw, "aex_gateway_request_duration_seconds_bucket{route=%q,upstream=%q,le=\"+Inf\"} %d\n", l.route, l.upstream, h.count)
+			fmt.Fprintf(bw, "aex_gateway_request_duration_seconds_sum{route=%q,upstream=%q} %s\n", l.route, l.upstream, strconv.FormatFloat(h.sum, 'g', -1, 64))
+			fmt.Fprintf(bw, "aex_gateway_request_duration_seconds_count{route=%q,upstream=%q} %d\n", l.route, l.upstream, h.count)
+		}
+
+		fmt.Fprintln(bw, "# HELP aex_gateway_rate_limit_rejections_total Requests turned away by a limit, by reason.")
+		fmt.Fprintln(bw, "# TYPE aex_gateway_rate_limit_rejections_total counter")
+		for _, reason := range []string{"rate_limit", "daily_quota", "concurrency"} {
+			fmt.Fprintf(bw, "aex_gateway_rate_limit_rejections_total{reason=%q} %d\n", reason, rejections[reason])
+		}
+
+		fmt.Fprintln(bw, "# HELP aex_gateway_circuit_breaker_state Circuit breaker state of each upstream, 1 for the current state.")
+		fmt.Fprintln(bw, "# TYPE aex_gateway_circuit_breaker_state gauge")
+		upstreams := make([]string, 0, len(states))
+		for u := range states {
+			upstreams = append(upstreams, u)
+		}
+		sort.Strings(upstreams)
+		for _, u := range upstreams {
+			for _, s := range breakerStates {
+				v := 0
+				if states[u] == s {
+					v = 1
+				}
+				fmt.Fprintf(bw, "aex_gateway_circuit_breaker_state{upstream=%q,state=%q} %d\n", u, s, v)
+			}
+		}
+	})
+}
@@ -8,7 +8,8 @@ import (
 	"time"
 )
 
-// RateLimiter implements in-memory rate limiting using token bucket algorithm
+// RateLimiter implements in-memory rate limiting per key: a token bucket
+// refilled over a minute and a request count per UTC day.
 type RateLimiter struct {
 	mu         sync.Mutex
 	buckets    map[string]*bucket
@@ -20,6 +21,22 @@ type RateLimiter struct {
 type bucket struct {
 	tokens     int
 	lastRefill time.Time
+	lastSeen   time.Time
+
+	// day is the start of the UTC day dayCount counts requests of.
+	day      time.Time
+	dayCount int
+}
+
+// RateLimitResult is the outcome of a request against the window closest
+// to its limit, which the X-RateLimit-* headers report.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+	// Daily reports whether the window is the daily quota.
+	Daily bool
 }
 
 func NewRateLimiter(limitPerMinute, burstSize int) *RateLimiter {
@@ -34,13 +51,24 @@ func NewRateLimiter(limitPerMinute, burstSize int) *RateLimiter {
 	return rl
 }
 
+// SetLimits changes the defaults for keys without a quota. Buckets keep
+// their tokens and refill to the new limit.
+func (rl *RateLimiter) SetLimits(limitPerMinute, burstSize int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit = limitPerMinute
+	rl.burstSize = burstSize
+}
+
 func (rl *RateLimiter) cleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
 	for range ticker.C {
 		rl.mu.Lock()
 		now := time.Now()
+		today := now.UTC().Truncate(24 * time.Hour)
 		for key, b := range rl.buckets {
-			if now.Sub(b.lastRefill) > 10*time.Minute {
+			// Daily counts are kept until their day is over.
+			if now.Sub(b.lastSeen) > 10*time.Minute && b.day.Before(today) {
 				delete(rl.buckets, key)
 			}
 		}
@@ -48,71 +76,111 @@ func (rl *RateLimiter) cleanup() {
 	}
 }
 
-func (rl *RateLimiter) Allow(key string) (allowed bool, remaining int, resetAt time.Time) {
+// Allow takes a request of key against its quotas. A quota of zero falls
+// back to the limiter's limit per minute, or no daily limit.
+func (rl *RateLimiter) Allow(key string, quotas Quotas) RateLimitResult {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
+	perMinute := quotas.RequestsPerMinute
+	if perMinute <= 0 {
+		perMinute = rl.limit
+	}
+
 	now := time.Now()
 	b, exists := rl.buckets[key]
 
 	if !exists {
 		b = &bucket{
-			tokens:     rl.limit,
+			tokens:     perMinute,
 			lastRefill: now,
 		}
 		rl.buckets[key] = b
 	}
+	b.lastSeen = now
 
 	// Refill tokens based on time elapsed
 	elapsed := now.Sub(b.lastRefill)
 	if elapsed >= rl.windowSize {
-		b.tokens = rl.limit
+		b.tokens = perMinute
 		b.lastRefill = now
 	} else {
 		// Partial refill
-		tokensToAdd := int(float64(rl.limit) * (float64(elapsed) / float64(rl.windowSize)))
-		b.tokens = min(b.tokens+tokensToAdd, rl.limit)
+		tokensToAdd := int(float64(perMinute) * (float64(elapsed) / float64(rl.windowSize)))
+		b.tokens = min(b.tokens+tokensToAdd, perMinute)
 		if tokensToAdd > 0 {
 			b.lastRefill = now
 		}
 	}
 
-	resetAt = b.lastRefill.Add(rl.windowSize)
+	if today := now.UTC().Truncate(24 * time.Hour); !b.day.Equal(today) {
+		b.day = today
+		b.dayCount = 0
+	}
 
-	if b.tokens > 0 {
-		b.tokens--
-		return true, b.tokens, resetAt
+	minute := RateLimitResult{
+		Allowed:   b.tokens > 0,
+		Limit:     perMinute,
+		Remaining: b.tokens,
+		ResetAt:   b.lastRefill.Add(rl.windowSize),
+	}
+	result := minute
+	if perDay := quotas.RequestsPerDay; perDay > 0 {
+		daily := RateLimitResult{
+			Allowed:   b.dayCount < perDay,
+			Limit:     perDay,
+			Remaining: max(perDay-b.dayCount, 0),
+			ResetAt:   b.day.Add(24 * time.Hour),
+			Daily:     true,
+		}
+		if !daily.Allowed || (minute.Allowed && daily.Remaining < minute.Remaining) {
+			result = daily
+		}
 	}
 
-	return false, 0, resetAt
+	if !minute.Allowed || !result.Allowed {
+		result.Allowed = false
+		return result
+	}
+	b.tokens--
+	b.dayCount++
+	result.Remaining--
+	return result
 }
 
+// RateLimit limits each API key, or each tenant for bearer tokens, to the
+// quotas Auth found for it. It must run after Auth.
 func RateLimit(limiter *RateLimiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			tenantID := GetTenantID(r.Context())
-			if tenantID == "" {
-				tenantID = "anonymous"
+			key := GetRateLimitKey(r.Context())
+			if key == "" {
+				key = "anonymous"
 			}
 
-			allowed, remaining, resetAt := limiter.Allow(tenantID)
+			result := limiter.Allow(key, GetQuotas(r.Context()))
 
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limiter.limit))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
 
-			if !allowed {
-				retryAfter := int(time.Until(resetAt).Seconds())
+			if !result.Allowed {
+				retryAfter := int(time.Until(result.ResetAt).Seconds())
 				if retryAfter < 1 {
 					retryAfter = 1
 				}
+				code, message, reason := "rate_limit_exceeded", "Rate limit exceeded.", "rate_limit"
+				if result.Daily {
+					code, message, reason = "daily_quota_exceeded", "Daily request quota exceeded.", "daily_quota"
+				}
+				noteRejection(r.Context(), reason)
 				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusTooManyRequests)
 				_ = json.NewEncoder(w).Encode(map[string]any{
 					"error": map[string]any{
-						"code":       "rate_limit_exceeded",
-						"message":    "Rate limit exceeded. Please retry after " + strconv.Itoa(retryAfter) + " seconds.",
+						"code":       code,
+						"message":    message + " Please retry after " + strconv.Itoa(retryAfter) + " seconds.",
 						"request_id": GetRequestID(r.Context()),
 					},
 				})
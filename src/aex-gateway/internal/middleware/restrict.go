@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+const RestrictionsKey contextKey = "key_restrictions"
+
+// KeyRestrictions limit where an API key may be used from and what it may
+// call, as the identity service reports them; empty means unrestricted.
+type KeyRestrictions struct {
+	CIDRs []netip.Prefix
+	// Routes are path prefixes such as /v1/bids.
+	Routes []string
+}
+
+func (k KeyRestrictions) empty() bool {
+	return len(k.CIDRs) == 0 && len(k.Routes) == 0
+}
+
+// parseRestrictions turns the identity service's restrictions into
+// KeyRestrictions. A range that does not parse stays in as one that
+// contains no address, so the key is refused rather than unrestricted.
+func parseRestrictions(cidrs, routes []string) KeyRestrictions {
+	k := KeyRestrictions{Routes: routes}
+	for _, c := range cidrs {
+		p, _ := netip.ParsePrefix(c)
+		k.CIDRs = append(k.CIDRs, p)
+	}
+	return k
+}
+
+// ParseTrustedProxies parses the CIDR ranges of trusted proxies.
+func ParseTrustedProxies(cidrs []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, c := range cidrs {
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", c, err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// ClientIP returns the address a request came from: its peer, or, when the
+// peer is a trusted proxy, the last address in X-Forwarded-For that is not
+// one. The zero Addr means it is unknown.
+func ClientIP(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	addr = addr.Unmap()
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && isTrusted(addr, trusted); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}
+		}
+		addr = hop.Unmap()
+	}
+	return addr
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	return slices.ContainsFunc(trusted, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// Restrict rejects requests of API keys restricted to CIDR ranges the
+// client address is outside of, or to route prefixes the path is not
+// under. It must run after Auth, and after APIVersion so routes are
+// matched against the upstream path.
+func Restrict(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, _ := r.Context().Value(RestrictionsKey).(KeyRestrictions)
+			if k.empty() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(k.CIDRs) > 0 {
+				addr := ClientIP(r, trusted)
+				if !addr.IsValid() || !slices.ContainsFunc(k.CIDRs, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+					respondError(w, http.StatusForbidden, "address_not_allowed", "API key may not be used from this address", r)
+					return
+				}
+			}
+			if len(k.Routes) > 0 && !slices.ContainsFunc(k.Routes, func(p string) bool {
+				return p == "/" || r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/")
+			}) {
+				respondError(w, http.StatusForbidden, "route_not_allowed", "API key may not be used for this route", r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// revokedKeyRetention is how long a revoked key is remembered: longer than
+// validations are cached and tokens issued for the key live.
+const revokedKeyRetention = 24 * time.Hour
+
+// Revocations are the suspended tenants and revoked API keys the identity
+// service has reported. Credentials of either are refused at once, rather
+// than when cached validations or tokens issued earlier expire. They are
+// not persisted; after a restart nothing is cached, but tokens of tenants
+// suspended before stay valid until they expire.
+type Revocations struct {
+	mu        sync.RWMutex
+	suspended map[string]bool      // tenant ID
+	revoked   map[string]time.Time // key ID -> when it may be forgotten
+}
+
+func NewRevocations() *Revocations {
+	return &Revocations{
+		suspended: make(map[string]bool),
+		revoked:   make(map[string]time.Time),
+	}
+}
+
+// SuspendTenant refuses the tenant's credentials until ActivateTenant.
+func (v *Revocations) SuspendTenant(tenantID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.suspended[tenantID] = true
+}
+
+func (v *Revocations) ActivateTenant(tenantID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.suspended, tenantID)
+}
+
+// RevokeKey refuses the API key, and tokens issued for it.
+func (v *Revocations) RevokeKey(keyID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	for id, until := range v.revoked {
+		if now.After(until) {
+			delete(v.revoked, id)
+		}
+	}
+	v.revoked[keyID] = now.Add(revokedKeyRetention)
+}
+
+// refused reports whether info's tenant is suspended or its key revoked.
+func (v *Revocations) refused(info *APIKeyInfo) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.suspended[info.TenantID] {
+		return true
+	}
+	_, revoked := v.revoked[info.KeyID]
+	return info.KeyID != "" && revoked
+}
+
+// Validator wraps next so the credentials it accepts are refused while
+// their tenant is suspended or their key revoked.
+func (v *Revocations) Validator(next APIKeyValidator) APIKeyValidator {
+	return revocationValidator{next: next, revocations: v}
+}
+
+type revocationValidator struct {
+	next        APIKeyValidator
+	revocations *Revocations
+}
+
+func (rv revocationValidator) Validate(ctx context.Context, apiKey string) (*APIKeyInfo, error) {
+	info, err := rv.next.Validate(ctx, apiKey)
+	if err != nil || info == nil || rv.revocations.refused(info) {
+		return nil, err
+	}
+	return info, nil
+}
+
+// IdentityEvents receives the identity service's tenant.suspended,
+// tenant.activated and apikey.revoked events, signed with secret in the
+// X-AEX-Signature header, and applies them to v. Other events are ignored.
+func IdentityEvents(v *Revocations, secret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid_request", "Request body could not be read", r)
+			return
+		}
+		if !signatureMatches(r.Header.Get("X-AEX-Signature"), secret, body) {
+			respondError(w, http.StatusUnauthorized, "invalid_signature", "Invalid event signature", r)
+			return
+		}
+		var event struct {
+			EventType string `json:"event_type"`
+			TenantID  string `json:"tenant_id"`
+			Data      struct {
+				KeyID string `json:"key_id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &event); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON", r)
+			return
+		}
+
+		switch event.EventType {
+		case "tenant.suspended", "tenant.activated":
+			if event.TenantID == "" {
+				respondError(w, http.StatusBadRequest, "invalid_request", "tenant_id is required", r)
+				return
+			}
+			if event.EventType == "tenant.suspended" {
+				v.SuspendTenant(event.TenantID)
+			} else {
+				v.ActivateTenant(event.TenantID)
+			}
+		case "apikey.revoked":
+			if event.Data.KeyID == "" {
+				respondError(w, http.StatusBadRequest, "invalid_request", "data.key_id is required", r)
+				return
+			}
+			v.RevokeKey(event.Data.KeyID)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		log.Printf("identity event applied type=%s tenant_id=%s key_id=%s", event.EventType, event.TenantID, event.Data.KeyID)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// signatureMatches checks a "sha256=<hex HMAC-SHA256 of body>" signature.
+func signatureMatches(signature, secret string, body []byte) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return secret != "" && hmac.Equal([]byte(signature), []byte(want))
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// routeResources names the resource each route prefix acts on. A route
+// needs the scope "<resource>:read" for GET and HEAD requests and
+// "<resource>:write" for the rest.
+var routeResources = map[string]string{
+	"/v1/work":          "work",
+	"/v1/bids":          "bids",
+	"/v1/contracts":     "contracts",
+	"/v1/consumers":     "contracts",
+	"/v1/providers":     "providers",
+	"/v1/subscriptions": "providers",
+	"/v1/capabilities":  "providers",
+	"/v1/usage":         "settlement",
+	"/v1/balance":       "settlement",
+	"/v1/deposits":      "settlement",
+	"/v1/tenants":       "tenants",
+	"/v1/organizations": "tenants",
+}
+
+// scopeGrant is what a key's scopes allow on one resource, as the identity
+// service resolves them; "*" means every resource or every action.
+type scopeGrant struct {
+	Resource string   `json:"resource"`
+	Actions  []string `json:"actions"`
+}
+
+// grantScopes turns grants into the scopes hasScope checks, so scopes the
+// gateway does not know, such as "contracts:manage", are enforced as the
+// identity service defines them.
+func grantScopes(grants []scopeGrant) []string {
+	var scopes []string
+	for _, g := range grants {
+		for _, action := range g.Actions {
+			if g.Resource == "*" && action == "*" {
+				scopes = append(scopes, "*")
+			} else {
+				scopes = append(scopes, g.Resource+":"+action)
+			}
+		}
+	}
+	return scopes
+}
+
+// requiredScope returns the scope a request needs, or "" for routes
+// without one.
+func requiredScope(method, path string) string {
+	resource, matched := "", ""
+	for prefix, r := range routeResources {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			resource, matched = r, prefix
+		}
+	}
+	if resource == "" {
+		return ""
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
+
+// hasScope reports whether granted allows required. "*" allows everything,
+// "<resource>:*" all actions on a resource, "*:<action>" or the bare
+// action an action on all resources, and a write scope also allows
+// reading.
+func hasScope(granted []string, required string) bool {
+	resource, action, _ := strings.Cut(required, ":")
+	for _, s := range granted {
+		switch s {
+		case "*", required, resource + ":*", "*:" + action, action:
+			return true
+		case resource + ":write", "*:write", "write":
+			if action == "read" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authorize rejects requests whose API key lacks the scope their route
+// requires. It must run after Auth, and after APIVersion so it sees the
+// upstream path.
+func Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := requiredScope(r.Method, r.URL.Path)
+		if required != "" && !hasScope(GetRoles(r.Context()), required) {
+			respondError(w, http.StatusForbidden, "insufficient_scope", "API key lacks the "+required+" scope", r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
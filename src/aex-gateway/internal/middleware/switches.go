@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SwitchState is the state of the gateway's kill switches. Maintenance
+// turns away every API request; DisabledRoutes turns away those of the
+// listed route prefixes, such as "/v1/bids", or upstream services, such as
+// "bid-gateway". Rejected requests are told to retry after RetryAfter.
+type SwitchState struct {
+	Maintenance    bool
+	DisabledRoutes []string
+	RetryAfter     time.Duration
+}
+
+// switchStateJSON is SwitchState as the admin endpoint reads and writes it.
+type switchStateJSON struct {
+	Maintenance       bool     `json:"maintenance"`
+	DisabledRoutes    []string `json:"disabled_routes"`
+	RetryAfterSeconds int      `json:"retry_after_seconds"`
+}
+
+// Switches holds the kill switches, which can be flipped while the gateway
+// serves requests.
+type Switches struct {
+	services map[string][]string // upstream service -> its route prefixes
+
+	mu       sync.RWMutex
+	state    SwitchState
+	disabled []string // route prefixes of state.DisabledRoutes
+}
+
+// NewSwitches returns switches, all off, that can disable the given
+// services by name.
+func NewSwitches(services map[string][]string) *Switches {
+	return &Switches{services: services}
+}
+
+// State returns the current state.
+func (s *Switches) State() SwitchState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	state := s.state
+	state.DisabledRoutes = append([]string{}, s.state.DisabledRoutes...)
+	return state
+}
+
+// Set replaces the state. Disabled routes must be route prefixes or known
+// services.
+func (s *Switches) Set(state SwitchState) error {
+	var disabled []string
+	for _, route := range state.DisabledRoutes {
+		switch prefixes, ok := s.services[route]; {
+		case ok:
+			disabled = append(disabled, prefixes...)
+		case strings.HasPrefix(route, "/"):
+			disabled = append(disabled, route)
+		default:
+			return fmt.Errorf("unknown route or service %q", route)
+		}
+	}
+	state.DisabledRoutes = append([]string{}, state.DisabledRoutes...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	s.disabled = disabled
+	return nil
+}
+
+// check returns the error code rejecting a request for path, or "".
+func (s *Switches) check(path string) (string, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.state.Maintenance {
+		return "maintenance", s.state.RetryAfter
+	}
+	// Match /v2 paths against the /v1 prefixes they are served by.
+	if rest, ok := strings.CutPrefix(path, "/"+currentVersion+"/"); ok {
+		path = "/v1/" + rest
+	}
+	for _, prefix := range s.disabled {
+		if strings.HasPrefix(path, prefix) {
+			return "route_disabled", s.state.RetryAfter
+		}
+	}
+	return "", 0
+}
+
+// KillSwitch answers requests the switches turn away with a 503 and a
+// Retry-After header, before they reach authentication or an upstream.
+func KillSwitch(s *Switches) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			code, retryAfter := s.check(r.URL.Path)
+			if code == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			message := "The exchange is down for maintenance"
+			if code == "route_disabled" {
+				message = "This endpoint is temporarily disabled"
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(retryAfter.Seconds()), 1)))
+			respondError(w, http.StatusServiceUnavailable, code, message, r)
+		})
+	}
+}
+
+// SwitchesAdmin serves the switches' state on GET and replaces it on PUT.
+// Callers authenticate with "Authorization: Bearer <token>". Changes last
+// until the gateway's configuration is next reloaded.
+func SwitchesAdmin(s *Switches, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !bearerTokenMatches(r, token) {
+			respondError(w, http.StatusUnauthorized, "unauthorized", "Invalid admin token", r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body switchStateJSON
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				respondError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON", r)
+				return
+			}
+			state := SwitchState{
+				Maintenance:    body.Maintenance,
+				DisabledRoutes: body.DisabledRoutes,
+				RetryAfter:     s.State().RetryAfter,
+			}
+			if body.RetryAfterSeconds > 0 {
+				state.RetryAfter = time.Duration(body.RetryAfterSeconds) * time.Second
+			}
+			if err := s.Set(state); err != nil {
+				respondError(w, http.StatusBadRequest, "invalid_request", err.Error(), r)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			respondError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", r)
+			return
+		}
+
+		state := s.State()
+		disabled := state.DisabledRoutes
+		sort.Strings(disabled)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(switchStateJSON{
+			Maintenance:       state.Maintenance,
+			DisabledRoutes:    disabled,
+			RetryAfterSeconds: int(state.RetryAfter.Seconds()),
+		})
+	})
+}
+
+// bearerTokenMatches reports whether r carries token as its bearer token.
+func bearerTokenMatches(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) == 1
+}
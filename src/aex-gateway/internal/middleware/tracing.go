@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const TraceKey contextKey = "trace"
+
+// TraceparentHeader is the W3C Trace Context header.
+const TraceparentHeader = "traceparent"
+
+// spanService is the service name of the spans the gateway emits.
+const spanService = "aex-gateway"
+
+// TraceContext is the trace a request belongs to and the gateway span
+// created for it. ParentID is the caller's span, empty when the gateway
+// started the trace.
+type TraceContext struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Sampled  bool
+}
+
+// Traceparent formats the context as a traceparent header value, with the
+// gateway span as the parent of the next hop.
+func (tc TraceContext) Traceparent() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + flags
+}
+
+// traceSpan is a span of the aex-telemetry ingestion API.
+type traceSpan struct {
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Service      string            `json:"service"`
+	Operation    string            `json:"operation"`
+	StartTime    time.Time         `json:"start_time"`
+	EndTime      time.Time         `json:"end_time"`
+	DurationMs   int64             `json:"duration_ms"`
+	Status       string            `json:"status"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// Tracer emits a span per proxied request to aex-telemetry. A nil
+// forwarder only propagates trace context.
+type Tracer struct {
+	forwarder *telemetryForwarder[traceSpan]
+}
+
+// NewTracer sends spans to the aex-telemetry service at telemetryURL, or
+// nowhere when it is empty.
+func NewTracer(telemetryURL string) *Tracer {
+	t := &Tracer{}
+	if telemetryURL != "" {
+		t.forwarder = newTelemetryForwarder[traceSpan](telemetryURL, "/v1/spans")
+	}
+	return t
+}
+
+// Tracing continues the trace of the request's traceparent header, or
+// starts one, and records a gateway span for the request. The trace ID is
+// returned in X-Trace-ID so callers can look the trace up. It must run
+// after Auth, whose tenant the span is attributed to.
+func Tracing(t *Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			tc, ok := parseTraceparent(r.Header.Get(TraceparentHeader))
+			if !ok {
+				tc = TraceContext{TraceID: randomHex(16), Sampled: true}
+			}
+			tc.ParentID, tc.SpanID = tc.SpanID, randomHex(8)
+
+			w.Header().Set("X-Trace-ID", tc.TraceID)
+			r, info := withRouteInfo(r.WithContext(context.WithValue(r.Context(), TraceKey, tc)))
+			wrapped := &auditWriter{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(wrapped, r)
+
+			if t.forwarder == nil || !tc.Sampled {
+				return
+			}
+			t.emit(r, tc, info, wrapped.status, start)
+		})
+	}
+}
+
+func (t *Tracer) emit(r *http.Request, tc TraceContext, info *routeInfo, status int, start time.Time) {
+	end := time.Now()
+	route := info.route
+	if route == "" {
+		route = r.URL.Path
+	}
+	spanStatus := "OK"
+	if status >= 500 {
+		spanStatus = "ERROR"
+	}
+	attrs := map[string]string{
+		"http.method":      r.Method,
+		"http.route":       route,
+		"http.target":      r.URL.Path,
+		"http.status_code": strconv.Itoa(status),
+		"request_id":       GetRequestID(r.Context()),
+	}
+	if info.upstream != "" {
+		attrs["peer.service"] = info.upstream
+	}
+	if tenant := GetTenantID(r.Context()); tenant != "" {
+		attrs["tenant_id"] = tenant
+	}
+
+	if !t.forwarder.enqueue(traceSpan{
+		TraceID:      tc.TraceID,
+		SpanID:       tc.SpanID,
+		ParentSpanID: tc.ParentID,
+		Service:      spanService,
+		Operation:    r.Method + " " + route,
+		StartTime:    start.UTC(),
+		EndTime:      end.UTC(),
+		DurationMs:   end.Sub(start).Milliseconds(),
+		Status:       spanStatus,
+		Attributes:   attrs,
+	}) {
+		log.Printf("tracing: span queue full, dropping span trace_id=%s", tc.TraceID)
+	}
+}
+
+// GetTraceContext returns the trace context Tracing put on the request.
+func GetTraceContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(TraceKey).(TraceContext)
+	return tc, ok
+}
+
+// parseTraceparent parses a version 00 traceparent header; later versions
+// are read as 00 as the specification asks.
+func parseTraceparent(h string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}, false
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isLowerHex(parts[0]) || len(traceID) != 32 || !isLowerHex(traceID) || isZero(traceID) ||
+		len(spanID) != 16 || !isLowerHex(spanID) || isZero(spanID) ||
+		len(flags) != 2 || !isLowerHex(flags) {
+		return TraceContext{}, false
+	}
+	f, _ := strconv.ParseUint(flags, 16, 8)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Sampled: f&1 == 1}, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/parlakisik/agent-exchange/aex-gateway/internal/schema"
+)
+
+// ValidationOptions configure request validation. MaxBodyBytes is the
+// default body size limit and RouteMaxBodyBytes overrides it by path
+// prefix, the longest matching prefix winning; zero means no limit.
+// SchemaDir, when set, holds routes.json and the JSON schemas it names.
+type ValidationOptions struct {
+	MaxBodyBytes      int64
+	RouteMaxBodyBytes map[string]int64
+	SchemaDir         string
+}
+
+// RequestValidator rejects oversized and malformed request bodies before
+// they are proxied.
+type RequestValidator struct {
+	opts    ValidationOptions
+	schemas map[string]*schema.Schema // "METHOD /path" -> schema
+}
+
+func NewRequestValidator(opts ValidationOptions) (*RequestValidator, error) {
+	v := &RequestValidator{opts: opts, schemas: make(map[string]*schema.Schema)}
+	if opts.SchemaDir == "" {
+		return v, nil
+	}
+
+	routes, err := schema.LoadRoutes(opts.SchemaDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		s, err := schema.Load(filepath.Join(opts.SchemaDir, route.Schema))
+		if err != nil {
+			return nil, err
+		}
+		v.schemas[route.Method+" "+route.Path] = s
+	}
+	return v, nil
+}
+
+// maxBodyBytes returns the body size limit for path.
+func (v *RequestValidator) maxBodyBytes(path string) int64 {
+	limit, matched := v.opts.MaxBodyBytes, ""
+	for prefix, n := range v.opts.RouteMaxBodyBytes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			limit, matched = n, prefix
+		}
+	}
+	return limit
+}
+
+// Validate enforces the body size limit of the request's route and, when
+// the route has a schema, checks the body against it. Rejections are 413s
+// for oversized bodies and 400s listing the violations otherwise.
+func Validate(v *RequestValidator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := v.maxBodyBytes(r.URL.Path)
+			s := v.schemas[r.Method+" "+strings.TrimSuffix(r.URL.Path, "/")]
+			if limit <= 0 && s == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Body == nil || r.Body == http.NoBody {
+				if s != nil {
+					respondError(w, http.StatusBadRequest, "invalid_request", "Request body is required", r)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if limit > 0 && r.ContentLength > limit {
+				respondTooLarge(w, limit, r)
+				return
+			}
+
+			// Read one byte past the limit to tell a body of exactly the
+			// limit from a longer one.
+			body := r.Body
+			if limit > 0 {
+				body = struct {
+					io.Reader
+					io.Closer
+				}{io.LimitReader(r.Body, limit+1), r.Body}
+			}
+			data, err := io.ReadAll(body)
+			_ = r.Body.Close()
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "invalid_request", "Failed to read request body", r)
+				return
+			}
+			if limit > 0 && int64(len(data)) > limit {
+				respondTooLarge(w, limit, r)
+				return
+			}
+
+			if s != nil {
+				var doc any
+				if err := json.Unmarshal(data, &doc); err != nil {
+					respondError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON", r)
+					return
+				}
+				if errs := s.Validate(doc); len(errs) > 0 {
+					respondValidationError(w, errs, r)
+					return
+				}
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			r.ContentLength = int64(len(data))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func respondTooLarge(w http.ResponseWriter, limit int64, r *http.Request) {
+	respondError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+		fmt.Sprintf("Request body exceeds %d bytes", limit), r)
+}
+
+func respondValidationError(w http.ResponseWriter, errs []schema.FieldError, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":       "validation_failed",
+			"message":    "Request body failed validation",
+			"request_id": GetRequestID(r.Context()),
+			"details":    errs,
+		},
+	})
+}
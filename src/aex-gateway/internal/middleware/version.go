@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// currentVersion is the current public API version, whose request schemas
+// the upstream services implement under their /v1 paths.
+const currentVersion = "v2"
+
+// maxTranslatedBodyBytes bounds the bodies read for translation; larger
+// ones are passed on as sent, for Validate to reject.
+const maxTranslatedBodyBytes = 1 << 20
+
+// fieldTranslation moves a deprecated field, a dotted path into the body,
+// to its current name, converting its value.
+type fieldTranslation struct {
+	from    string
+	to      string
+	convert func(any) (any, bool)
+}
+
+// deprecatedFields are the deprecated request fields v1 clients may still
+// send, by route.
+var deprecatedFields = map[string][]fieldTranslation{
+	"POST /v1/work": {
+		{from: "budget.max_amount", to: "budget.max_price", convert: toNumber},
+	},
+}
+
+// APIVersion serves the public API versions side by side. v2 is the
+// current API and is proxied to the upstream v1 paths unchanged. v1 is
+// the deprecated API: its deprecated fields are translated to their v2
+// names, and the response is marked with a Deprecation header naming them.
+func APIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, rest, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if !ok || (version != "v1" && version != currentVersion) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if version == currentVersion {
+			r.URL.Path = "/v1/" + rest
+			r.URL.RawPath = ""
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if translations := deprecatedFields[r.Method+" "+strings.TrimSuffix(r.URL.Path, "/")]; len(translations) > 0 {
+			if translated := translateBody(r, translations); len(translated) > 0 {
+				w.Header().Set("Deprecation", "true")
+				w.Header().Set("X-Deprecated-Fields", strings.Join(translated, ", "))
+				w.Header().Set("Link", "</"+currentVersion+"/"+rest+">; rel=\"successor-version\"")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// translateBody rewrites the deprecated fields of r's JSON body and returns
+// the ones it found. Bodies that are not JSON objects, or too large, are
+// left for the upstream and Validate to answer.
+func translateBody(r *http.Request, translations []fieldTranslation) []string {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxTranslatedBodyBytes+1))
+	if err != nil || len(data) > maxTranslatedBodyBytes {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+		return nil
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	// Numbers are kept as sent, not rounded through float64.
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if dec.Decode(&doc) != nil {
+		return nil
+	}
+	var translated []string
+	for _, t := range translations {
+		if moveField(doc, t) {
+			translated = append(translated, t.from)
+		}
+	}
+	if len(translated) == 0 {
+		return nil
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return translated
+}
+
+// moveField applies t to doc. A deprecated field is dropped without
+// overwriting the current one when the client sent both.
+func moveField(doc map[string]any, t fieldTranslation) bool {
+	fromParent, fromName := lookupParent(doc, t.from, false)
+	if fromParent == nil {
+		return false
+	}
+	value, ok := fromParent[fromName]
+	if !ok {
+		return false
+	}
+	delete(fromParent, fromName)
+	toParent, toName := lookupParent(doc, t.to, true)
+	if toParent == nil {
+		return true
+	}
+	if _, exists := toParent[toName]; exists {
+		return true
+	}
+	if converted, ok := t.convert(value); ok {
+		value = converted
+	}
+	toParent[toName] = value
+	return true
+}
+
+// lookupParent returns the object holding the field at path and the
+// field's name, creating missing objects on the way when create is set.
+func lookupParent(doc map[string]any, path string, create bool) (map[string]any, string) {
+	names := strings.Split(path, ".")
+	obj := doc
+	for _, name := range names[:len(names)-1] {
+		child, ok := obj[name].(map[string]any)
+		if !ok {
+			if _, exists := obj[name]; exists || !create {
+				return nil, ""
+			}
+			child = map[string]any{}
+			obj[name] = child
+		}
+		obj = child
+	}
+	return obj, names[len(names)-1]
+}
+
+// toNumber converts the numeric strings v1 clients sent amounts as.
+func toNumber(v any) (any, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
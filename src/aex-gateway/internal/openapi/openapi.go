@@ -0,0 +1,284 @@
+// Package openapi describes the public API the gateway serves as an
+// OpenAPI 3.1 document, built from the gateway's route catalog and the
+// shared request schemas.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/parlakisik/agent-exchange/aex-gateway/internal/schema"
+)
+
+// route is a public operation. path is its upstream /v1 path, served
+// under /v2 as the current version and /v1 as the deprecated one.
+type route struct {
+	method  string
+	path    string
+	tag     string
+	summary string
+}
+
+// routes are the operations the gateway proxies to the upstream services.
+var routes = []route{
+	{"POST", "/v1/work", "Work", "Submit work"},
+	{"GET", "/v1/work/{work_id}", "Work", "Get work"},
+	{"POST", "/v1/work/{work_id}/cancel", "Work", "Cancel work"},
+
+	{"POST", "/v1/bids", "Bids", "Submit a bid"},
+
+	{"GET", "/v1/contracts/me", "Contracts", "Get the caller's contract"},
+	{"GET", "/v1/contracts/{contract_id}", "Contracts", "Get a contract"},
+	{"GET", "/v1/contracts/{contract_id}/timeline", "Contracts", "Get a contract's timeline"},
+	{"GET", "/v1/contracts/{contract_id}/webhooks", "Contracts", "List a contract's webhook deliveries"},
+	{"POST", "/v1/contracts/{contract_id}/progress", "Contracts", "Report progress"},
+	{"POST", "/v1/contracts/{contract_id}/complete", "Contracts", "Complete a contract"},
+	{"POST", "/v1/contracts/{contract_id}/fail", "Contracts", "Fail a contract"},
+	{"POST", "/v1/contracts/{contract_id}/cancel", "Contracts", "Cancel a contract"},
+	{"POST", "/v1/contracts/{contract_id}/amendments", "Contracts", "Propose an amendment"},
+	{"POST", "/v1/contracts/{contract_id}/amendments/{amendment_id}/accept", "Contracts", "Accept an amendment"},
+	{"POST", "/v1/contracts/{contract_id}/parties", "Contracts", "Add a party"},
+	{"POST", "/v1/contracts/{contract_id}/artifacts", "Contracts", "Upload an artifact"},
+	{"GET", "/v1/contracts/{contract_id}/artifacts", "Contracts", "List artifacts"},
+	{"GET", "/v1/contracts/{contract_id}/artifacts/{artifact_id}", "Contracts", "Download an artifact"},
+	{"POST", "/v1/contracts/{contract_id}/extension-request", "Contracts", "Request a deadline extension"},
+	{"POST", "/v1/contracts/{contract_id}/extension-request/{extension_id}/approve", "Contracts", "Approve an extension"},
+	{"POST", "/v1/contracts/{contract_id}/extension-request/{extension_id}/deny", "Contracts", "Deny an extension"},
+	{"GET", "/v1/consumers/{consumer_id}/award-policy", "Contracts", "Get a consumer's auto-award policy"},
+	{"PUT", "/v1/consumers/{consumer_id}/award-policy", "Contracts", "Set a consumer's auto-award policy"},
+
+	{"POST", "/v1/providers", "Providers", "Register a provider"},
+	{"GET", "/v1/providers", "Providers", "List providers"},
+	{"GET", "/v1/providers/search", "Providers", "Search providers"},
+	{"GET", "/v1/providers/schema", "Providers", "Get the provider registration schema"},
+	{"GET", "/v1/providers/me", "Providers", "Get the caller's provider"},
+	{"PATCH", "/v1/providers/me", "Providers", "Update the caller's provider"},
+	{"GET", "/v1/providers/{provider_id}", "Providers", "Get a provider"},
+	{"DELETE", "/v1/providers/{provider_id}", "Providers", "Delete a provider"},
+	{"GET", "/v1/providers/{provider_id}/a2a", "Providers", "Get a provider's A2A endpoint"},
+	{"GET", "/v1/providers/{provider_id}/agent-card", "Providers", "Get a provider's agent card"},
+	{"POST", "/v1/providers/{provider_id}/agent-card", "Providers", "Refresh a provider's agent card"},
+	{"POST", "/v1/providers/{provider_id}/verify-endpoint", "Providers", "Verify a provider's endpoint"},
+	{"POST", "/v1/providers/{provider_id}/verify-domain", "Providers", "Verify a provider's domain"},
+	{"POST", "/v1/providers/{provider_id}/heartbeat", "Providers", "Send a heartbeat"},
+	{"GET", "/v1/providers/{provider_id}/stats", "Providers", "Get a provider's stats"},
+	{"GET", "/v1/providers/{provider_id}/api-keys", "Providers", "List a provider's API keys"},
+	{"POST", "/v1/providers/{provider_id}/api-keys", "Providers", "Create a provider API key"},
+	{"DELETE", "/v1/providers/{provider_id}/api-keys/{key_id}", "Providers", "Revoke a provider API key"},
+	{"GET", "/v1/capabilities", "Providers", "List capabilities"},
+
+	{"POST", "/v1/subscriptions", "Subscriptions", "Subscribe to work"},
+	{"GET", "/v1/subscriptions", "Subscriptions", "List subscriptions"},
+	{"GET", "/v1/subscriptions/{subscription_id}", "Subscriptions", "Get a subscription"},
+	{"PATCH", "/v1/subscriptions/{subscription_id}", "Subscriptions", "Update a subscription"},
+	{"DELETE", "/v1/subscriptions/{subscription_id}", "Subscriptions", "Delete a subscription"},
+	{"POST", "/v1/subscriptions/{subscription_id}/pause", "Subscriptions", "Pause a subscription"},
+	{"POST", "/v1/subscriptions/{subscription_id}/resume", "Subscriptions", "Resume a subscription"},
+	{"GET", "/v1/subscriptions/{subscription_id}/deliveries", "Subscriptions", "List a subscription's deliveries"},
+
+	{"GET", "/v1/usage", "Settlement", "Get usage"},
+	{"GET", "/v1/usage/transactions", "Settlement", "List transactions"},
+	{"GET", "/v1/balance", "Settlement", "Get the balance"},
+	{"POST", "/v1/deposits", "Settlement", "Deposit funds"},
+
+	{"POST", "/v1/tenants", "Identity", "Create a tenant"},
+	{"GET", "/v1/tenants", "Identity", "List tenants"},
+	{"GET", "/v1/tenants/{tenant_id}", "Identity", "Get a tenant"},
+	{"PATCH", "/v1/tenants/{tenant_id}", "Identity", "Update a tenant"},
+	{"DELETE", "/v1/tenants/{tenant_id}", "Identity", "Delete a tenant"},
+	{"POST", "/v1/tenants/{tenant_id}/suspend", "Identity", "Suspend a tenant"},
+	{"POST", "/v1/tenants/{tenant_id}/activate", "Identity", "Activate a tenant"},
+	{"GET", "/v1/tenants/{tenant_id}/api-keys", "Identity", "List API keys"},
+	{"POST", "/v1/tenants/{tenant_id}/api-keys", "Identity", "Create an API key"},
+	{"DELETE", "/v1/tenants/{tenant_id}/api-keys/{key_id}", "Identity", "Revoke an API key"},
+	{"POST", "/v1/tenants/{tenant_id}/api-keys/{key_id}/rotate", "Identity", "Rotate an API key"},
+	{"GET", "/v1/tenants/{tenant_id}/audit-log", "Identity", "List a tenant's audit log"},
+	{"GET", "/v1/tenants/{tenant_id}/sso", "Identity", "Get a tenant's OIDC single sign-on settings"},
+	{"PUT", "/v1/tenants/{tenant_id}/sso", "Identity", "Configure OIDC single sign-on"},
+	{"DELETE", "/v1/tenants/{tenant_id}/sso", "Identity", "Remove OIDC single sign-on"},
+	{"GET", "/v1/tenants/{tenant_id}/users", "Identity", "List users"},
+	{"POST", "/v1/tenants/{tenant_id}/users", "Identity", "Add a user"},
+	{"GET", "/v1/tenants/{tenant_id}/users/{user_id}", "Identity", "Get a user"},
+	{"PATCH", "/v1/tenants/{tenant_id}/users/{user_id}", "Identity", "Change a user's role or status"},
+	{"DELETE", "/v1/tenants/{tenant_id}/users/{user_id}", "Identity", "Remove a user"},
+	{"GET", "/v1/tenants/{tenant_id}/users/{user_id}/api-keys", "Identity", "List a user's API keys"},
+	{"POST", "/v1/tenants/{tenant_id}/users/{user_id}/api-keys", "Identity", "Create an API key for a user"},
+	{"POST", "/v1/organizations", "Identity", "Create an organization"},
+	{"GET", "/v1/organizations/{org_id}", "Identity", "Get an organization"},
+	{"PATCH", "/v1/organizations/{org_id}", "Identity", "Update an organization"},
+	{"GET", "/v1/organizations/{org_id}/tenants", "Identity", "List an organization's tenants"},
+	{"POST", "/v1/organizations/{org_id}/tenants", "Identity", "Add a tenant to an organization"},
+	{"DELETE", "/v1/organizations/{org_id}/tenants/{tenant_id}", "Identity", "Remove a tenant from an organization"},
+	{"GET", "/v1/organizations/{org_id}/api-keys", "Identity", "List organization API keys"},
+	{"POST", "/v1/organizations/{org_id}/api-keys", "Identity", "Create an organization API key"},
+	{"DELETE", "/v1/organizations/{org_id}/api-keys/{key_id}", "Identity", "Revoke an organization API key"},
+	{"GET", "/v1/scopes", "Identity", "List the scopes API keys can be granted"},
+}
+
+// gatewayRoutes are served by the gateway itself, without authentication.
+var gatewayRoutes = []route{
+	{"GET", "/health", "Health", "Gateway liveness"},
+	{"GET", "/ready", "Health", "Gateway readiness"},
+	{"GET", "/v1/info", "Health", "Gateway information"},
+	{"GET", "/v2/info", "Health", "Gateway information"},
+	{"GET", "/v1/system/health", "Health", "Health of the upstream services"},
+	{"GET", "/openapi.json", "Health", "This document"},
+	{"POST", "/v1/token", "Identity", "Exchange an API key, or an ID token of the tenant's OIDC issuer, for a token"},
+	{"GET", "/.well-known/jwks.json", "Identity", "Get the keys tokens are signed with"},
+}
+
+// Document builds the OpenAPI document. When schemaDir is set, the
+// request bodies of the routes in its routes.json are described by their
+// schemas.
+func Document(version, schemaDir string) ([]byte, error) {
+	bodies := map[string]string{} // "METHOD /path" -> component name
+	components := map[string]json.RawMessage{}
+	if schemaDir != "" {
+		schemaRoutes, err := schema.LoadRoutes(schemaDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, sr := range schemaRoutes {
+			data, err := os.ReadFile(filepath.Join(schemaDir, sr.Schema))
+			if err != nil {
+				return nil, err
+			}
+			if !json.Valid(data) {
+				return nil, fmt.Errorf("%s: invalid JSON", sr.Schema)
+			}
+			name := componentName(sr.Schema)
+			components[name] = data
+			bodies[sr.Method+" "+sr.Path] = name
+		}
+	}
+
+	paths := map[string]map[string]any{}
+	add := func(path, method string, op map[string]any) {
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = op
+	}
+	for _, r := range routes {
+		body := bodies[r.method+" "+r.path]
+		add("/v2/"+strings.TrimPrefix(r.path, "/v1/"), r.method, describe(r, body, false))
+		add(r.path, r.method, describe(r, body, true))
+	}
+	for _, r := range gatewayRoutes {
+		op := describe(r, "", false)
+		op["security"] = []any{}
+		add(r.path, r.method, op)
+	}
+
+	schemas := map[string]any{
+		"Error": map[string]any{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": map[string]any{
+				"error": map[string]any{
+					"type":     "object",
+					"required": []string{"code", "message"},
+					"properties": map[string]any{
+						"code":       map[string]any{"type": "string"},
+						"message":    map[string]any{"type": "string"},
+						"request_id": map[string]any{"type": "string"},
+						"details": map[string]any{
+							"type": "array",
+							"items": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"field":   map[string]any{"type": "string"},
+									"message": map[string]any{"type": "string"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for name, s := range components {
+		schemas[name] = s
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Agent Exchange API",
+			"version":     version,
+			"description": "The public API of the Agent Exchange, served through the gateway. /v2 is the current version; /v1 is deprecated and accepts the older request fields.",
+		},
+		"servers":  []any{map[string]any{"url": "/"}},
+		"security": []any{map[string]any{"ApiKeyAuth": []string{}}, map[string]any{"BearerAuth": []string{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"ApiKeyAuth": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"BearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+			"schemas": schemas,
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "Error",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}},
+					},
+				},
+			},
+		},
+	}, "", "  ")
+}
+
+// describe returns the operation object of r, whose request body is the
+// named schema component, if any.
+func describe(r route, body string, deprecated bool) map[string]any {
+	op := map[string]any{
+		"tags":    []string{r.tag},
+		"summary": r.summary,
+		"responses": map[string]any{
+			"2XX":     map[string]any{"description": "Success"},
+			"default": map[string]any{"$ref": "#/components/responses/Error"},
+		},
+	}
+	if deprecated {
+		op["deprecated"] = true
+	}
+	var params []any
+	for _, segment := range strings.Split(r.path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, map[string]any{
+				"name":     strings.Trim(segment, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if body != "" {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/" + body}},
+			},
+		}
+	}
+	return op
+}
+
+// componentName names a schema file's component: "work-submit.json"
+// becomes "WorkSubmit".
+func componentName(file string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(strings.TrimSuffix(filepath.Base(file), ".json"), func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for requests to an upstream whose circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is the circuit breaker of one upstream. It opens after threshold
+// consecutive failures and rejects requests for openFor; it then lets up to
+// probes requests through, closing again when one succeeds and reopening
+// when one fails. A threshold of zero disables it.
+type Breaker struct {
+	mu        sync.Mutex
+	name      string
+	threshold int
+	openFor   time.Duration
+	probes    int
+
+	state    breakerState
+	failures int
+	openedAt time.Time
+	inFlight int
+}
+
+func NewBreaker(name string, threshold int, openFor time.Duration, probes int) *Breaker {
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		openFor:   openFor,
+		probes:    max(probes, 1),
+	}
+}
+
+// Allow reports whether a request may go to the upstream, and whether it
+// is a half-open probe. Every allowed request must be followed by Record.
+func (b *Breaker) Allow() (allowed, probe bool) {
+	if b.threshold <= 0 {
+		return true, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.openFor {
+			return false, false
+		}
+		b.setState(stateHalfOpen)
+		fallthrough
+	case stateHalfOpen:
+		if b.inFlight >= b.probes {
+			return false, false
+		}
+		b.inFlight++
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// Record reports the outcome of a request Allow let through.
+func (b *Breaker) Record(probe, success bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.inFlight--
+	}
+	switch b.state {
+	case stateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	case stateHalfOpen:
+		// Requests let through before the breaker opened do not decide.
+		if !probe {
+			return
+		}
+		if success {
+			b.failures = 0
+			b.setState(stateClosed)
+		} else {
+			b.open()
+		}
+	}
+}
+
+// Release ends a request Allow let through without an outcome, such as
+// one the client cancelled.
+func (b *Breaker) Release(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	b.inFlight--
+	b.mu.Unlock()
+}
+
+// RetryAfter is how long the breaker stays open.
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != stateOpen {
+		return 0
+	}
+	return max(b.openFor-time.Since(b.openedAt), 0)
+}
+
+// State returns "closed", "open" or "half-open".
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.String()
+}
+
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(stateOpen)
+}
+
+func (b *Breaker) setState(s breakerState) {
+	if b.state == s {
+		return
+	}
+	log.Printf("circuit breaker upstream=%s state=%s->%s failures=%d", b.name, b.state, s, b.failures)
+	b.state = s
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+)
+
+// CanaryHeader lets a caller choose the canary replicas of a service
+// ("true") or its stable one ("false") instead of the weighted split.
+// Responses from a canary carry it set to true.
+const CanaryHeader = "X-Canary"
+
+// replica is one deployment of an upstream service.
+type replica struct {
+	host   string
+	weight int // percent of traffic; the stable replica takes the rest
+	canary bool
+	proxy  *httputil.ReverseProxy
+}
+
+// pool is the replicas of an upstream service: the stable one and any
+// canaries, whose weights sum to at most 100.
+type pool struct {
+	stable   *replica
+	canaries []*replica
+}
+
+// pick chooses the replica to send req to.
+func (p *pool) pick(req *http.Request) *replica {
+	if len(p.canaries) == 0 {
+		return p.stable
+	}
+	total := 0
+	for _, c := range p.canaries {
+		total += c.weight
+	}
+	switch forced, err := strconv.ParseBool(req.Header.Get(CanaryHeader)); {
+	case err == nil && !forced:
+		return p.stable
+	case err == nil && forced:
+		if total == 0 {
+			return p.canaries[rand.IntN(len(p.canaries))]
+		}
+		return p.weighted(rand.IntN(total))
+	}
+	if r := p.weighted(rand.IntN(100)); r != nil {
+		return r
+	}
+	return p.stable
+}
+
+// weighted returns the canary whose share of the weights n falls in, or
+// nil when n is past all of them.
+func (p *pool) weighted(n int) *replica {
+	for _, c := range p.canaries {
+		if n < c.weight {
+			return c
+		}
+		n -= c.weight
+	}
+	return nil
+}
@@ -2,47 +2,152 @@ package proxy
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/parlakisik/agent-exchange/aex-gateway/internal/config"
 	"github.com/parlakisik/agent-exchange/aex-gateway/internal/middleware"
 )
 
+// routes maps each route prefix to the upstream service serving it.
+var routes = map[string]string{
+	"/v1/work":          "work-publisher",
+	"/v1/providers":     "provider-registry",
+	"/v1/subscriptions": "provider-registry",
+	"/v1/capabilities":  "provider-registry",
+	"/v1/usage":         "settlement",
+	"/v1/balance":       "settlement",
+	"/v1/deposits":      "settlement",
+	"/v1/bids":          "bid-gateway",
+	"/v1/contracts":     "contract-engine",
+	"/v1/consumers":     "contract-engine",
+	"/v1/tenants":       "identity",
+	"/v1/organizations": "identity",
+	"/v1/scopes":        "identity",
+	"/v1/logs":          "telemetry",
+	"/v1/metrics":       "telemetry",
+	"/v1/traces":        "telemetry",
+	"/v1/service-map":   "telemetry",
+	"/v1/events":        "telemetry",
+	"/v1/dead-letters":  "telemetry",
+	"/v1/alerts":        "telemetry",
+	"/v1/slos":          "telemetry",
+	"/v1/retention":     "telemetry",
+	"/v1/probes":        "telemetry",
+	"/v1/export":        "telemetry",
+	"/v1/stats":         "telemetry",
+}
+
+// GatewayTokenHeader carries the gateway's upstream token on proxied
+// requests; a value sent by the client is dropped.
+const GatewayTokenHeader = "X-Gateway-Token"
+
+// ServicePrefixes returns the route prefixes of each upstream service.
+func ServicePrefixes() map[string][]string {
+	prefixes := make(map[string][]string)
+	for prefix, service := range routes {
+		prefixes[service] = append(prefixes[service], prefix)
+	}
+	return prefixes
+}
+
 type Router struct {
-	routes  map[string]string
-	proxies map[string]*httputil.ReverseProxy
+	routes        map[string]*pool // prefix -> replicas of its upstream service
+	breakers      map[string]*Breaker
+	upstreamToken string
 }
 
 func NewRouter(cfg *config.Config) *Router {
-	routes := map[string]string{
-		"/v1/work":          cfg.WorkPublisherURL,
-		"/v1/providers":     cfg.ProviderRegistryURL,
-		"/v1/subscriptions": cfg.ProviderRegistryURL,
-		"/v1/capabilities":  cfg.ProviderRegistryURL,
-		"/v1/usage":         cfg.SettlementURL,
-		"/v1/balance":       cfg.SettlementURL,
-		"/v1/deposits":      cfg.SettlementURL,
-		"/v1/bids":          cfg.BidGatewayURL,
-		"/v1/contracts":     cfg.ContractEngineURL,
-		"/v1/tenants":       cfg.IdentityURL,
+	services := map[string]string{
+		"work-publisher":    cfg.WorkPublisherURL,
+		"provider-registry": cfg.ProviderRegistryURL,
+		"settlement":        cfg.SettlementURL,
+		"bid-gateway":       cfg.BidGatewayURL,
+		"contract-engine":   cfg.ContractEngineURL,
+		"identity":          cfg.IdentityURL,
+		"telemetry":         cfg.TelemetryURL,
 	}
-
-	proxies := make(map[string]*httputil.ReverseProxy)
-	for prefix, upstream := range routes {
+	// Each replica has its own circuit breaker, shared by the prefixes of
+	// its service, so a failing canary does not trip the stable replica.
+	breakers := make(map[string]*Breaker)
+	newReplica := func(upstream string, weight int, canary bool) *replica {
 		u, err := url.Parse(upstream)
 		if err != nil {
+			return nil
+		}
+		breaker, ok := breakers[upstream]
+		if !ok {
+			breaker = NewBreaker(u.Host, cfg.BreakerFailureThreshold, cfg.BreakerOpenDuration, cfg.BreakerHalfOpenProbes)
+			breakers[upstream] = breaker
+		}
+		p := httputil.NewSingleHostReverseProxy(u)
+		p.Transport = &upstreamTransport{
+			base:    http.DefaultTransport,
+			breaker: breaker,
+			retry:   RetryPolicy{MaxRetries: cfg.UpstreamMaxRetries, Backoff: cfg.UpstreamRetryBackoff},
+		}
+		p.ErrorHandler = upstreamErrorHandler(breaker)
+		return &replica{host: u.Host, weight: weight, canary: canary, proxy: p}
+	}
+
+	pools := make(map[string]*pool)
+	for service, upstream := range services {
+		if upstream == "" {
+			continue
+		}
+		stable := newReplica(upstream, 0, false)
+		if stable == nil {
 			continue
 		}
-		proxies[prefix] = httputil.NewSingleHostReverseProxy(u)
+		p := &pool{stable: stable}
+		total := 0
+		for _, c := range cfg.Canaries {
+			if c.Service != service {
+				continue
+			}
+			canary := newReplica(c.URL, c.Weight, true)
+			if canary == nil || canary.host == "" {
+				log.Printf("ignoring canary of %s with invalid URL %q", service, c.URL)
+				continue
+			}
+			total += c.Weight
+			p.canaries = append(p.canaries, canary)
+		}
+		if total > 100 {
+			log.Printf("canary weights of %s sum to %d%%; canaries past 100%% get no share", service, total)
+		}
+		pools[service] = p
 	}
 
-	return &Router{
-		routes:  routes,
-		proxies: proxies,
+	prefixes := make(map[string]*pool)
+	for prefix, service := range routes {
+		if p, ok := pools[service]; ok {
+			prefixes[prefix] = p
+		}
 	}
+	byHost := make(map[string]*Breaker, len(breakers))
+	for _, b := range breakers {
+		if b.name != "" {
+			byHost[b.name] = b
+		}
+	}
+	return &Router{routes: prefixes, breakers: byHost, upstreamToken: cfg.UpstreamToken}
+}
+
+// BreakerStates returns the circuit breaker state of each upstream replica,
+// by host.
+func (r *Router) BreakerStates() map[string]string {
+	states := make(map[string]string, len(r.breakers))
+	for host, b := range r.breakers {
+		states[host] = b.State()
+	}
+	return states
 }
 
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -50,35 +155,71 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	// Find matching route
 	var matchedPrefix string
-	var proxy *httputil.ReverseProxy
+	var upstreams *pool
 
-	for prefix := range r.routes {
+	for prefix, p := range r.routes {
 		if strings.HasPrefix(path, prefix) {
 			if len(prefix) > len(matchedPrefix) {
 				matchedPrefix = prefix
-				proxy = r.proxies[prefix]
+				upstreams = p
 			}
 		}
 	}
 
-	if proxy == nil {
+	if upstreams == nil {
 		respondError(w, http.StatusNotFound, "endpoint_not_found", "Endpoint not found", req)
 		return
 	}
 
+	target := upstreams.pick(req)
+	middleware.SetRoute(req.Context(), matchedPrefix, target.host)
+	if target.canary {
+		w.Header().Set(CanaryHeader, "true")
+	}
+
 	// Add internal headers
 	tenantID := middleware.GetTenantID(req.Context())
 	requestID := middleware.GetRequestID(req.Context())
 
 	req.Header.Set("X-Tenant-ID", tenantID)
 	req.Header.Set("X-Request-ID", requestID)
+	if tc, ok := middleware.GetTraceContext(req.Context()); ok {
+		req.Header.Set(middleware.TraceparentHeader, tc.Traceparent())
+	}
+	req.Header.Del("X-Scopes")
+	if scopes := middleware.GetRoles(req.Context()); len(scopes) > 0 {
+		req.Header.Set("X-Scopes", strings.Join(scopes, ","))
+	}
+	req.Header.Del("X-User-ID")
+	req.Header.Del("X-User-Role")
+	if user, ok := middleware.GetUser(req.Context()); ok {
+		req.Header.Set("X-User-ID", user.ID)
+		req.Header.Set("X-User-Role", user.Role)
+	}
 
 	// Remove external auth headers (already validated)
 	req.Header.Del("X-API-Key")
 	req.Header.Del("Authorization")
+	req.Header.Del(GatewayTokenHeader)
+	if r.upstreamToken != "" {
+		req.Header.Set(GatewayTokenHeader, r.upstreamToken)
+	}
 
 	// Proxy the request
-	proxy.ServeHTTP(w, req)
+	target.proxy.ServeHTTP(w, req)
+}
+
+func upstreamErrorHandler(breaker *Breaker) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, ErrCircuitOpen) {
+			retryAfter := int(math.Ceil(breaker.RetryAfter().Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			respondError(w, http.StatusServiceUnavailable, "upstream_unavailable", "Upstream service unavailable", r)
+			return
+		}
+		log.Printf("proxy error path=%s request_id=%s: %v", r.URL.Path, middleware.GetRequestID(r.Context()), err)
+		respondError(w, http.StatusBadGateway, "upstream_error", "Upstream service error", r)
+	}
 }
 
 func respondError(w http.ResponseWriter, status int, code, message string, r *http.Request) {
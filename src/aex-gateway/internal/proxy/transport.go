@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// maxRetryBody is the largest request body buffered so the request can be
+// retried; larger requests are sent once.
+const maxRetryBody = 1 << 20
+
+// RetryPolicy bounds the retries of idempotent requests: up to MaxRetries
+// after the first attempt, waiting about Backoff, doubled each time, with
+// jitter.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// upstreamTransport sends requests through the upstream's circuit breaker
+// and retries idempotent ones that failed in transit or got 502, 503 or
+// 504.
+type upstreamTransport struct {
+	base    http.RoundTripper
+	breaker *Breaker
+	retry   RetryPolicy
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retryable := isIdempotent(req.Method) && t.retry.MaxRetries > 0
+	var body []byte
+	if retryable && req.Body != nil && req.Body != http.NoBody {
+		if req.ContentLength < 0 || req.ContentLength > maxRetryBody {
+			retryable = false
+		} else {
+			var err error
+			if body, err = io.ReadAll(req.Body); err != nil {
+				return nil, err
+			}
+			_ = req.Body.Close()
+		}
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		allowed, probe := t.breaker.Allow()
+		if !allowed {
+			return nil, ErrCircuitOpen
+		}
+		out := req
+		if body != nil {
+			out = req.Clone(ctx)
+			out.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := t.base.RoundTrip(out)
+		if ctx.Err() != nil {
+			t.breaker.Release(probe)
+			return resp, err
+		}
+		t.breaker.Record(probe, err == nil && resp.StatusCode < http.StatusInternalServerError)
+
+		if !retryable || attempt >= t.retry.MaxRetries || !shouldRetry(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff(t.retry.Backoff, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff is base doubled per attempt, scaled by a random factor in
+// [0.5, 1.5) so retries of concurrent requests spread out.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << attempt
+	return d/2 + time.Duration(rand.Int64N(int64(d)+1))
+}
@@ -14,11 +14,18 @@ import (
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	gateway, err := httpapi.NewGateway(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
-		Handler:      httpapi.NewRouter(cfg),
+		Handler:      gateway,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: cfg.RequestTimeout + 5*time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -31,6 +38,24 @@ func main() {
 		}
 	}()
 
+	// SIGHUP reloads the configuration, changed in CONFIG_FILE; requests in
+	// flight finish against the old one.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			cfg, err := config.Load()
+			if err == nil {
+				err = gateway.Reload(cfg)
+			}
+			if err != nil {
+				log.Printf("reload failed, keeping the current configuration: %v", err)
+				continue
+			}
+			log.Println("configuration reloaded")
+		}
+	}()
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
@@ -2,14 +2,30 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	idhttp "github.com/parlakisik/agent-exchange/aex-identity/internal/httpapi"
 	idsvc "github.com/parlakisik/agent-exchange/aex-identity/internal/service"
 	idst "github.com/parlakisik/agent-exchange/aex-identity/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/events"
+	"github.com/parlakisik/agent-exchange/internal/serviceauth"
 )
 
 func TestTenantCreateAPIKeyAndValidate(t *testing.T) {
@@ -91,3 +107,1978 @@ func TestTenantCreateAPIKeyAndValidate(t *testing.T) {
 		t.Fatalf("expected %d got %d", http.StatusOK, resp4.StatusCode)
 	}
 }
+
+func TestTokenExchangeAndJWKS(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{"name": "tenant-jwt"})
+	resp, err := http.Post(ts.URL+"/v1/tenants", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var created struct {
+		ID     string `json:"id"`
+		APIKey struct {
+			Key string `json:"key"`
+		} `json:"api_key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	// Exchange the API key for a token
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/token", nil)
+	req.Header.Set("X-API-Key", created.APIKey.Key)
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp2.Body.Close() }()
+	if resp2.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, resp2.StatusCode)
+	}
+	var issued struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp2.Body).Decode(&issued); err != nil {
+		t.Fatal(err)
+	}
+	if issued.TokenType != "Bearer" || issued.ExpiresIn <= 0 || issued.ExpiresIn > 900 {
+		t.Fatalf("unexpected token response %+v", issued)
+	}
+	parts := strings.Split(issued.AccessToken, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected a JWT, got %q", issued.AccessToken)
+	}
+
+	// The token verifies against the published key
+	resp3, err := http.Get(ts.URL + "/.well-known/jwks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp3.Body.Close() }()
+	var jwks struct {
+		Keys []struct {
+			Kid, Kty, Crv, X, Y string
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp3.Body).Decode(&jwks); err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "EC" || jwks.Keys[0].Crv != "P-256" {
+		t.Fatalf("unexpected JWKS %+v", jwks)
+	}
+	var header struct{ Alg, Kid string }
+	hb, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	_ = json.Unmarshal(hb, &header)
+	if header.Alg != "ES256" || header.Kid != jwks.Keys[0].Kid {
+		t.Fatalf("unexpected token header %+v", header)
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+	y, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].Y)
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Fatal("token signature does not verify against the JWKS")
+	}
+	var claims struct {
+		TenantID string   `json:"tenant_id"`
+		Scopes   []string `json:"scopes"`
+		Exp      int64    `json:"exp"`
+	}
+	cb, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	_ = json.Unmarshal(cb, &claims)
+	if claims.TenantID != created.ID || len(claims.Scopes) != 1 || claims.Scopes[0] != "*" || claims.Exp == 0 {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	validate := func(credential string) int {
+		b, _ := json.Marshal(map[string]any{"api_key": credential})
+		resp, err := http.Post(ts.URL+"/internal/v1/apikeys/validate", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The validate endpoint accepts the token too, and rejects tampered ones
+	if status := validate(issued.AccessToken); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if status := validate(parts[0] + "." + parts[1] + "x." + parts[2]); status != http.StatusUnauthorized {
+		t.Fatalf("expected %d got %d", http.StatusUnauthorized, status)
+	}
+
+	// Unknown keys get no token
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/v1/token", strings.NewReader(`{"api_key":"aexk_unknown"}`))
+	resp4, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp4.Body.Close()
+	if resp4.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected %d got %d", http.StatusUnauthorized, resp4.StatusCode)
+	}
+
+	// Tokens of suspended tenants stop validating
+	resp5, err := http.Post(ts.URL+"/v1/tenants/"+created.ID+"/suspend", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp5.Body.Close()
+	if status := validate(issued.AccessToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected %d got %d", http.StatusUnauthorized, status)
+	}
+}
+
+func TestScopeCatalog(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Get(ts.URL + "/v1/scopes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var catalog []struct {
+		Name     string   `json:"name"`
+		Resource string   `json:"resource"`
+		Actions  []string `json:"actions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, sc := range catalog {
+		names[sc.Name] = true
+	}
+	for _, want := range []string{"work:read", "work:write", "bids:write", "contracts:manage", "settlement:read", "admin:*"} {
+		if !names[want] {
+			t.Fatalf("scope %s missing from the catalog %+v", want, catalog)
+		}
+	}
+
+	b, _ := json.Marshal(map[string]any{"name": "tenant-scopes"})
+	resp2, err := http.Post(ts.URL+"/v1/tenants", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp2.Body.Close() }()
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp2.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	createKey := func(scopes ...string) (int, string) {
+		b, _ := json.Marshal(map[string]any{"name": "k", "scopes": scopes})
+		resp, err := http.Post(ts.URL+"/v1/tenants/"+created.ID+"/api-keys", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var key struct {
+			Key string `json:"key"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&key)
+		return resp.StatusCode, key.Key
+	}
+
+	// Scopes outside the catalog are rejected
+	for _, scopes := range [][]string{{"work:delete"}, {"tasks:write"}, {"work:read", "nonsense"}, {"unknown:*"}} {
+		if status, _ := createKey(scopes...); status != http.StatusBadRequest {
+			t.Fatalf("scopes %v: expected %d got %d", scopes, http.StatusBadRequest, status)
+		}
+	}
+
+	// The validate endpoint resolves scopes into grants by resource
+	status, key := createKey("work:write", "contracts:manage", "settlement:read", "bids:*")
+	if status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+	b, _ = json.Marshal(map[string]any{"api_key": key})
+	resp3, err := http.Post(ts.URL+"/internal/v1/apikeys/validate", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp3.Body.Close() }()
+	var validated struct {
+		Grants []struct {
+			Resource string   `json:"resource"`
+			Actions  []string `json:"actions"`
+		} `json:"grants"`
+	}
+	if err := json.NewDecoder(resp3.Body).Decode(&validated); err != nil {
+		t.Fatal(err)
+	}
+	grants := map[string]string{}
+	for _, g := range validated.Grants {
+		grants[g.Resource] = strings.Join(g.Actions, ",")
+	}
+	want := map[string]string{"bids": "*", "contracts": "read,write", "settlement": "read", "work": "read,write"}
+	if len(grants) != len(want) {
+		t.Fatalf("unexpected grants %v", grants)
+	}
+	for resource, actions := range want {
+		if grants[resource] != actions {
+			t.Fatalf("unexpected grants %v", grants)
+		}
+	}
+}
+
+func TestTenantListUpdateAndDelete(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	svc.SetAdminToken("admin-secret")
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any, header ...string) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+
+	type tenant struct {
+		ID           string         `json:"id"`
+		Name         string         `json:"name"`
+		Type         string         `json:"type"`
+		Status       string         `json:"status"`
+		ContactEmail string         `json:"contact_email"`
+		Metadata     map[string]any `json:"metadata"`
+		Quotas       struct {
+			RequestsPerMinute int `json:"requests_per_minute"`
+			RequestsPerDay    int `json:"requests_per_day"`
+		} `json:"quotas"`
+		DeletedAt *string `json:"deleted_at"`
+		APIKey    struct {
+			Key string `json:"key"`
+		} `json:"api_key"`
+	}
+	var ids []string
+	var firstKey string
+	for i, typ := range []string{"REQUESTOR", "PROVIDER", "REQUESTOR", "BOTH", "REQUESTOR"} {
+		var created tenant
+		if status := do(http.MethodPost, "/v1/tenants", map[string]any{"name": "t" + string(rune('a'+i)), "type": typ}, &created); status != http.StatusCreated {
+			t.Fatalf("expected %d got %d", http.StatusCreated, status)
+		}
+		ids = append(ids, created.ID)
+		if i == 0 {
+			firstKey = created.APIKey.Key
+		}
+	}
+
+	// Listing pages through the tenants by id
+	admin := []string{"Authorization", "Bearer admin-secret"}
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		var page struct {
+			Tenants    []tenant `json:"tenants"`
+			NextCursor string   `json:"next_cursor"`
+		}
+		if status := do(http.MethodGet, "/v1/tenants?type=requestor&limit=2&cursor="+cursor, nil, &page, admin...); status != http.StatusOK {
+			t.Fatalf("expected %d got %d", http.StatusOK, status)
+		}
+		for _, tn := range page.Tenants {
+			if tn.Type != "REQUESTOR" {
+				t.Fatalf("expected only requestors, got %+v", tn)
+			}
+			seen = append(seen, tn.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if pages > 3 {
+			t.Fatal("listing does not end")
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 requestors, got %v", seen)
+	}
+	if status := do(http.MethodGet, "/v1/tenants?status=bogus", nil, nil, admin...); status != http.StatusBadRequest {
+		t.Fatalf("expected %d got %d", http.StatusBadRequest, status)
+	}
+
+	// Tenants only list themselves
+	if status := do(http.MethodGet, "/v1/tenants", nil, nil); status != http.StatusForbidden {
+		t.Fatalf("expected listing without credentials to be refused, got %d", status)
+	}
+	var own struct {
+		Tenants []tenant `json:"tenants"`
+	}
+	if status := do(http.MethodGet, "/v1/tenants", nil, &own, "X-Tenant-ID", ids[1]); status != http.StatusOK || len(own.Tenants) != 1 || own.Tenants[0].ID != ids[1] {
+		t.Fatalf("expected a tenant to list only itself, got %d %+v", status, own.Tenants)
+	}
+	own.Tenants = nil
+	if status := do(http.MethodGet, "/v1/tenants?type=requestor", nil, &own, "X-Tenant-ID", ids[1]); status != http.StatusOK || len(own.Tenants) != 0 {
+		t.Fatalf("expected the type filter to apply, got %d %+v", status, own.Tenants)
+	}
+
+	// PATCH changes only the fields it sets
+	var updated tenant
+	patch := map[string]any{
+		"contact_email": "ops@example.com",
+		"metadata":      map[string]any{"tier": "gold"},
+		"quotas":        map[string]any{"requests_per_minute": 600, "requests_per_day": 50000},
+	}
+	if status := do(http.MethodPatch, "/v1/tenants/"+ids[0], patch, &updated); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if updated.Name != "ta" || updated.ContactEmail != "ops@example.com" || updated.Metadata["tier"] != "gold" ||
+		updated.Quotas.RequestsPerMinute != 600 || updated.Quotas.RequestsPerDay != 50000 {
+		t.Fatalf("unexpected tenant after update %+v", updated)
+	}
+	if status := do(http.MethodPatch, "/v1/tenants/"+ids[0], map[string]any{"name": " "}, nil); status != http.StatusBadRequest {
+		t.Fatalf("expected %d got %d", http.StatusBadRequest, status)
+	}
+	if status := do(http.MethodPatch, "/v1/tenants/tenant_missing", map[string]any{"name": "x"}, nil); status != http.StatusNotFound {
+		t.Fatalf("expected %d got %d", http.StatusNotFound, status)
+	}
+
+	// Tenants cannot delete each other
+	if status := do(http.MethodDelete, "/v1/tenants/"+ids[0], nil, nil, "X-Tenant-ID", ids[1]); status != http.StatusForbidden {
+		t.Fatalf("expected another tenant's delete to be refused, got %d", status)
+	}
+
+	// Deleting anonymizes the tenant and revokes its keys
+	var deleted struct {
+		Status         string `json:"status"`
+		APIKeysRevoked int    `json:"api_keys_revoked"`
+	}
+	if status := do(http.MethodDelete, "/v1/tenants/"+ids[0], nil, &deleted); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if deleted.Status != "TERMINATED" || deleted.APIKeysRevoked != 1 {
+		t.Fatalf("unexpected delete response %+v", deleted)
+	}
+	var tomb tenant
+	do(http.MethodGet, "/v1/tenants/"+ids[0], nil, &tomb)
+	if tomb.ContactEmail != "" || len(tomb.Metadata) != 0 || tomb.Name == "ta" || tomb.DeletedAt == nil {
+		t.Fatalf("expected the tenant to be anonymized, got %+v", tomb)
+	}
+	if status := do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": firstKey}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected %d got %d", http.StatusUnauthorized, status)
+	}
+	var keys []struct {
+		Status string `json:"status"`
+	}
+	do(http.MethodGet, "/v1/tenants/"+ids[0]+"/api-keys", nil, &keys)
+	if len(keys) != 1 || keys[0].Status != "REVOKED" {
+		t.Fatalf("expected the key to be revoked, got %+v", keys)
+	}
+	for _, path := range []string{"/v1/tenants/" + ids[0], "/v1/tenants/" + ids[0] + "/activate"} {
+		method := http.MethodDelete
+		if strings.HasSuffix(path, "/activate") {
+			method = http.MethodPost
+		}
+		if status := do(method, path, nil, nil); status != http.StatusConflict {
+			t.Fatalf("%s %s: expected %d got %d", method, path, http.StatusConflict, status)
+		}
+	}
+
+	// Purging removes the tenant and its keys
+	if status := do(http.MethodDelete, "/v1/tenants/"+ids[0]+"?purge=true", nil, nil); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if status := do(http.MethodGet, "/v1/tenants/"+ids[0], nil, nil); status != http.StatusNotFound {
+		t.Fatalf("expected %d got %d", http.StatusNotFound, status)
+	}
+	keys = nil
+	do(http.MethodGet, "/v1/tenants/"+ids[0]+"/api-keys", nil, &keys)
+	if len(keys) != 0 {
+		t.Fatalf("expected the keys to be deleted, got %+v", keys)
+	}
+}
+
+func TestUsageTrackingAndEnforcement(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	type usage struct {
+		Requests    int64  `json:"requests"`
+		ActiveTasks int64  `json:"active_tasks"`
+		Agents      int64  `json:"agents"`
+		Exceeded    string `json:"exceeded"`
+	}
+	do := func(method, path string, body any, out any) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-usage"}, &created)
+	quotas := map[string]any{"requests_per_day": 10, "max_concurrent_tasks": 2, "max_agents": 1}
+	if status := do(http.MethodPatch, "/v1/tenants/"+created.ID, map[string]any{"quotas": quotas}, nil); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	path := "/internal/v1/tenants/" + created.ID + "/usage"
+
+	// Concurrent enforced increments never pass the quota
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	counts := map[int]int{}
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := do(http.MethodPost, path, map[string]any{"requests": 1, "enforce": true}, nil)
+			mu.Lock()
+			counts[status]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if counts[http.StatusOK] != 10 || counts[http.StatusTooManyRequests] != 15 {
+		t.Fatalf("expected 10 requests admitted and 15 refused, got %v", counts)
+	}
+
+	// Unenforced increments are only counted
+	var u usage
+	if status := do(http.MethodPost, path, map[string]any{"requests": 5}, &u); status != http.StatusOK || u.Requests != 15 {
+		t.Fatalf("expected 15 requests, got %d %+v", status, u)
+	}
+
+	// Tasks are counted up and down against max_concurrent_tasks
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		u = usage{}
+		status := do(http.MethodPost, path, map[string]any{"tasks": 1, "enforce": true}, &u)
+		if status != want {
+			t.Fatalf("task %d: expected %d got %d", i, want, status)
+		}
+		if status == http.StatusTooManyRequests && (u.Exceeded != "max_concurrent_tasks" || u.ActiveTasks != 2) {
+			t.Fatalf("unexpected refusal %+v", u)
+		}
+	}
+	do(http.MethodPost, path, map[string]any{"tasks": -1}, nil)
+	if status := do(http.MethodPost, path, map[string]any{"tasks": 1, "enforce": true}, nil); status != http.StatusOK {
+		t.Fatalf("expected a finished task to free a slot, got %d", status)
+	}
+
+	// A refused increment adds nothing, not even to counters within quota
+	u = usage{}
+	if status := do(http.MethodPost, path, map[string]any{"tasks": -1, "agents": 2, "enforce": true}, &u); status != http.StatusTooManyRequests || u.Exceeded != "max_agents" {
+		t.Fatalf("expected max_agents to be exceeded, got %d %+v", status, u)
+	}
+	u = usage{}
+	do(http.MethodGet, path, nil, &u)
+	if u.Requests != 15 || u.ActiveTasks != 2 || u.Agents != 0 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	if status := do(http.MethodPost, path, map[string]any{"requests": -1}, nil); status != http.StatusBadRequest {
+		t.Fatalf("expected %d got %d", http.StatusBadRequest, status)
+	}
+	if status := do(http.MethodGet, "/internal/v1/tenants/tenant_missing/usage", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("expected %d got %d", http.StatusNotFound, status)
+	}
+}
+
+func TestOrganizations(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any, header ...string) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	type tenant struct {
+		ID             string `json:"id"`
+		OrganizationID string `json:"organization_id"`
+		BillingEmail   string `json:"billing_email"`
+	}
+	type validation struct {
+		TenantID       string   `json:"tenant_id"`
+		OrganizationID string   `json:"organization_id"`
+		Scopes         []string `json:"scopes"`
+	}
+
+	var org struct {
+		ID     string `json:"id"`
+		APIKey struct {
+			Key  string `json:"key"`
+			Role string `json:"role"`
+		} `json:"api_key"`
+	}
+	if status := do(http.MethodPost, "/v1/organizations", map[string]any{"name": "acme", "billing_email": "billing@acme.test"}, &org); status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+	if org.APIKey.Key == "" || org.APIKey.Role != "ADMIN" {
+		t.Fatalf("expected an ADMIN key, got %+v", org.APIKey)
+	}
+	orgAdmin := []string{"X-API-Key", org.APIKey.Key}
+
+	// Tenants created in, or added to, the organization are billed to it
+	var member, other tenant
+	if status := do(http.MethodPost, "/v1/tenants", map[string]any{"name": "member", "organization_id": org.ID}, &member, orgAdmin...); status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+	do(http.MethodGet, "/v1/tenants/"+member.ID, nil, &member)
+	if member.OrganizationID != org.ID || member.BillingEmail != "billing@acme.test" {
+		t.Fatalf("expected tenant billed to the organization, got %+v", member)
+	}
+	if status := do(http.MethodPost, "/v1/tenants", map[string]any{"name": "x", "organization_id": "org_missing"}, nil, orgAdmin...); status != http.StatusForbidden {
+		t.Fatalf("expected %d got %d", http.StatusForbidden, status)
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "other"}, &other)
+	if status := do(http.MethodPatch, "/v1/tenants/"+member.ID, map[string]any{"billing_email": "x@y.test"}, nil); status != http.StatusConflict {
+		t.Fatalf("expected %d got %d", http.StatusConflict, status)
+	}
+
+	// Organization keys act for a tenant of the organization
+	validate := func(key, tenantID string, out *validation) int {
+		return do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": key, "tenant_id": tenantID}, out)
+	}
+	if status := validate(org.APIKey.Key, "", nil); status != http.StatusBadRequest {
+		t.Fatalf("expected %d got %d", http.StatusBadRequest, status)
+	}
+	var v validation
+	if status := validate(org.APIKey.Key, member.ID, &v); status != http.StatusOK || v.TenantID != member.ID || v.OrganizationID != org.ID {
+		t.Fatalf("expected the member tenant, got %d %+v", status, v)
+	}
+	if status := validate(org.APIKey.Key, other.ID, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected %d got %d", http.StatusUnauthorized, status)
+	}
+
+	// Roles narrow the key's scopes
+	var viewer struct {
+		Key string `json:"key"`
+	}
+	body := map[string]any{"name": "ro", "role": "viewer", "scopes": []string{"work:write", "contracts:manage"}}
+	if status := do(http.MethodPost, "/v1/organizations/"+org.ID+"/api-keys", body, &viewer, orgAdmin...); status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+	v = validation{}
+	validate(viewer.Key, member.ID, &v)
+	if strings.Join(v.Scopes, ",") != "work:read,contracts:read" {
+		t.Fatalf("expected read-only scopes, got %v", v.Scopes)
+	}
+	if status := do(http.MethodPost, "/v1/organizations/"+org.ID+"/api-keys", map[string]any{"role": "owner"}, nil, orgAdmin...); status != http.StatusBadRequest {
+		t.Fatalf("expected %d got %d", http.StatusBadRequest, status)
+	}
+
+	// Tokens are issued for the tenant named in the body
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if status := do(http.MethodPost, "/v1/token", map[string]any{"api_key": org.APIKey.Key, "tenant_id": member.ID}, &tok); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	v = validation{}
+	if status := validate(tok.AccessToken, "", &v); status != http.StatusOK || v.TenantID != member.ID || v.OrganizationID != org.ID {
+		t.Fatalf("expected a token for the member tenant, got %d %+v", status, v)
+	}
+
+	// Only organization admins manage the organization
+	var rival struct {
+		ID     string `json:"id"`
+		APIKey struct {
+			Key string `json:"key"`
+		} `json:"api_key"`
+	}
+	do(http.MethodPost, "/v1/organizations", map[string]any{"name": "globex"}, &rival)
+	otherOwner := []string{"X-Tenant-ID", other.ID, "X-User-Role", "OWNER"}
+	for _, c := range []struct {
+		name   string
+		header []string
+	}{
+		{"no credentials", nil},
+		{"another organization's key", []string{"X-API-Key", rival.APIKey.Key}},
+		{"a viewer key", []string{"X-API-Key", viewer.Key}},
+		{"an owner of a tenant outside it", otherOwner},
+		{"an admin of one of its tenants", []string{"X-Tenant-ID", member.ID, "X-User-Role", "ADMIN"}},
+	} {
+		if status := do(http.MethodGet, "/v1/organizations/"+org.ID, nil, nil, c.header...); status != http.StatusForbidden {
+			t.Fatalf("%s: expected %d got %d", c.name, http.StatusForbidden, status)
+		}
+		if status := do(http.MethodPost, "/v1/organizations/"+org.ID+"/api-keys", map[string]any{}, nil, c.header...); status != http.StatusForbidden {
+			t.Fatalf("%s: expected %d got %d", c.name, http.StatusForbidden, status)
+		}
+	}
+	if status := do(http.MethodGet, "/v1/organizations/"+org.ID, nil, nil, "X-Tenant-ID", member.ID, "X-User-Role", "OWNER"); status != http.StatusOK {
+		t.Fatalf("expected an owner of a member tenant to manage it, got %d", status)
+	}
+	if status := do(http.MethodPost, "/v1/tenants", map[string]any{"name": "y", "organization_id": org.ID}, nil, "X-API-Key", rival.APIKey.Key); status != http.StatusForbidden {
+		t.Fatalf("expected a tenant in another organization to be refused, got %d", status)
+	}
+
+	// A tenant is only added at its owner's request
+	add := map[string]any{"tenant_id": other.ID}
+	if status := do(http.MethodPost, "/v1/organizations/"+org.ID+"/tenants", add, nil, orgAdmin...); status != http.StatusForbidden {
+		t.Fatalf("expected %d got %d", http.StatusForbidden, status)
+	}
+	if status := do(http.MethodPost, "/v1/organizations/"+org.ID+"/tenants", add, nil, append(orgAdmin, "X-Tenant-ID", member.ID, "X-User-Role", "OWNER")...); status != http.StatusForbidden {
+		t.Fatalf("expected %d got %d", http.StatusForbidden, status)
+	}
+	if status := do(http.MethodPost, "/v1/organizations/"+rival.ID+"/tenants", add, nil, otherOwner...); status != http.StatusForbidden {
+		t.Fatalf("expected %d got %d", http.StatusForbidden, status)
+	}
+
+	// A new billing email reaches every tenant; removed tenants lose access
+	if status := do(http.MethodPost, "/v1/organizations/"+org.ID+"/tenants", add, nil, append(orgAdmin, otherOwner...)...); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if status := do(http.MethodPatch, "/v1/organizations/"+org.ID, map[string]any{"billing_email": "ap@acme.test"}, nil, orgAdmin...); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	var list struct {
+		Tenants []tenant `json:"tenants"`
+		Total   int      `json:"total"`
+	}
+	do(http.MethodGet, "/v1/organizations/"+org.ID+"/tenants", nil, &list, orgAdmin...)
+	if list.Total != 2 {
+		t.Fatalf("expected 2 tenants, got %+v", list)
+	}
+	for _, tn := range list.Tenants {
+		if tn.BillingEmail != "ap@acme.test" {
+			t.Fatalf("expected billing email to propagate, got %+v", tn)
+		}
+	}
+	if status := do(http.MethodDelete, "/v1/organizations/"+org.ID+"/tenants/"+other.ID, nil, nil, orgAdmin...); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if status := validate(org.APIKey.Key, other.ID, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected %d got %d", http.StatusUnauthorized, status)
+	}
+}
+
+func TestTenantUsersAndRoles(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	type user struct {
+		ID   string `json:"id"`
+		Role string `json:"role"`
+	}
+	// as makes requests the way the gateway forwards those of a user's key.
+	as := func(tenantID string, u *user) func(method, path string, body any, out any) int {
+		return func(method, path string, body any, out any) int {
+			t.Helper()
+			var b []byte
+			if body != nil {
+				b, _ = json.Marshal(body)
+			}
+			req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+			if u != nil {
+				req.Header.Set("X-Tenant-ID", tenantID)
+				req.Header.Set("X-User-ID", u.ID)
+				req.Header.Set("X-User-Role", u.Role)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if out != nil {
+				_ = json.NewDecoder(resp.Body).Decode(out)
+			}
+			return resp.StatusCode
+		}
+	}
+
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	as("", nil)(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-users"}, &tenant)
+	base := "/v1/tenants/" + tenant.ID
+	do := as(tenant.ID, nil)
+
+	users := map[string]*user{}
+	for _, role := range []string{"OWNER", "ADMIN", "DEVELOPER", "VIEWER"} {
+		u := &user{}
+		body := map[string]any{"email": strings.ToLower(role) + "@example.test", "role": role}
+		if status := do(http.MethodPost, base+"/users", body, u); status != http.StatusCreated || u.Role != role {
+			t.Fatalf("expected %s user, got %d %+v", role, status, u)
+		}
+		users[role] = u
+	}
+	owner, admin, dev, viewer := as(tenant.ID, users["OWNER"]), as(tenant.ID, users["ADMIN"]), as(tenant.ID, users["DEVELOPER"]), as(tenant.ID, users["VIEWER"])
+	if status := do(http.MethodPost, base+"/users", map[string]any{"email": "Admin@example.test"}, nil); status != http.StatusConflict {
+		t.Fatalf("expected %d got %d", http.StatusConflict, status)
+	}
+	if status := do(http.MethodPost, base+"/users", map[string]any{"email": "x@example.test", "role": "root"}, nil); status != http.StatusBadRequest {
+		t.Fatalf("expected %d got %d", http.StatusBadRequest, status)
+	}
+	if status := admin(http.MethodPost, base+"/users", map[string]any{"email": "o2@example.test", "role": "OWNER"}, nil); status != http.StatusForbidden {
+		t.Fatalf("expected admins not to add owners, got %d", status)
+	}
+
+	// Users create their own keys; only admins create anyone's
+	var devKey, viewerKey struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if status := dev(http.MethodPost, base+"/users/"+users["DEVELOPER"].ID+"/api-keys", map[string]any{}, &devKey); status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+	if status := dev(http.MethodPost, base+"/users/"+users["VIEWER"].ID+"/api-keys", map[string]any{}, nil); status != http.StatusForbidden {
+		t.Fatalf("expected %d got %d", http.StatusForbidden, status)
+	}
+	if status := admin(http.MethodPost, base+"/users/"+users["VIEWER"].ID+"/api-keys", map[string]any{}, &viewerKey); status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+
+	// Keys act within their user's role
+	var v struct {
+		UserID   string   `json:"user_id"`
+		UserRole string   `json:"user_role"`
+		Scopes   []string `json:"scopes"`
+	}
+	do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": devKey.Key}, &v)
+	if v.UserID != users["DEVELOPER"].ID || v.UserRole != "DEVELOPER" || slices.Contains(v.Scopes, "*") || slices.Contains(v.Scopes, "settlement:write") {
+		t.Fatalf("expected a developer's scopes, got %+v", v)
+	}
+	do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": viewerKey.Key}, &v)
+	if strings.Join(v.Scopes, ",") != "*:read" {
+		t.Fatalf("expected read-only scopes, got %v", v.Scopes)
+	}
+
+	// Management endpoints check the role
+	for _, c := range []struct {
+		do     func(string, string, any, any) int
+		method string
+		path   string
+		body   any
+		want   int
+	}{
+		{viewer, http.MethodGet, base, nil, http.StatusOK},
+		{viewer, http.MethodPatch, base, map[string]any{"name": "x"}, http.StatusForbidden},
+		{dev, http.MethodPost, base + "/api-keys", map[string]any{}, http.StatusForbidden},
+		{admin, http.MethodPatch, base, map[string]any{"name": "renamed"}, http.StatusOK},
+		{admin, http.MethodPost, base + "/suspend", nil, http.StatusForbidden},
+		{admin, http.MethodDelete, base, nil, http.StatusForbidden},
+		{admin, http.MethodPatch, base + "/users/" + users["OWNER"].ID, map[string]any{"role": "VIEWER"}, http.StatusForbidden},
+		{as("tenant_other", users["OWNER"]), http.MethodGet, base, nil, http.StatusForbidden},
+		{owner, http.MethodPatch, base + "/users/" + users["OWNER"].ID, map[string]any{"role": "ADMIN"}, http.StatusConflict},
+		{dev, http.MethodDelete, base + "/api-keys/" + viewerKey.ID, nil, http.StatusForbidden},
+		{dev, http.MethodDelete, base + "/api-keys/" + devKey.ID, nil, http.StatusOK},
+	} {
+		if status := c.do(c.method, c.path, c.body, nil); status != c.want {
+			t.Fatalf("%s %s: expected %d got %d", c.method, c.path, c.want, status)
+		}
+	}
+
+	// Disabled and removed users' keys stop working
+	if status := admin(http.MethodPatch, base+"/users/"+users["VIEWER"].ID, map[string]any{"status": "disabled"}, nil); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if status := do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": viewerKey.Key}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected %d got %d", http.StatusUnauthorized, status)
+	}
+	var removed struct {
+		Revoked int `json:"api_keys_revoked"`
+	}
+	if status := admin(http.MethodDelete, base+"/users/"+users["VIEWER"].ID, nil, &removed); status != http.StatusOK || removed.Revoked != 1 {
+		t.Fatalf("expected the user's key revoked, got %d %+v", status, removed)
+	}
+	var list struct {
+		Total int `json:"total"`
+	}
+	owner(http.MethodGet, base+"/users", nil, &list)
+	if list.Total != 3 {
+		t.Fatalf("expected 3 users, got %d", list.Total)
+	}
+}
+
+func TestTenantRoutesRequireCallerTenant(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	svc.SetAdminToken("admin-secret")
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any, header ...string) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	var victim, other struct {
+		ID string `json:"id"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "victim"}, &victim)
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "other"}, &other)
+	base := "/v1/tenants/" + victim.ID
+
+	// A tenant-wide key of another tenant carries no user role.
+	for _, c := range []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodGet, base, nil},
+		{http.MethodPatch, base, map[string]any{"name": "taken"}},
+		{http.MethodGet, base + "/users", nil},
+		{http.MethodPost, base + "/users", map[string]any{"email": "intruder@example.test", "role": "OWNER"}},
+		{http.MethodPost, base + "/api-keys", map[string]any{}},
+		{http.MethodGet, base + "/audit-log", nil},
+		{http.MethodDelete, base, nil},
+	} {
+		if code := do(c.method, c.path, c.body, nil, "X-Tenant-ID", other.ID); code != http.StatusForbidden {
+			t.Fatalf("%s %s from another tenant: expected 403, got %d", c.method, c.path, code)
+		}
+	}
+	if code := do(http.MethodGet, base, nil, nil, "X-Tenant-ID", victim.ID); code != http.StatusOK {
+		t.Fatalf("expected the tenant's own key to be allowed, got %d", code)
+	}
+
+	// Operators and services act for any tenant.
+	if code := do(http.MethodGet, base, nil, nil, "X-Tenant-ID", other.ID, "Authorization", "Bearer admin-secret"); code != http.StatusOK {
+		t.Fatalf("expected the admin token to be allowed, got %d", code)
+	}
+	var account struct {
+		ID           string `json:"id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	do(http.MethodPost, "/admin/v1/service-accounts", map[string]any{"name": "aex-gateway", "audiences": []string{"aex-identity", "aex-settlement"}}, &account, "Authorization", "Bearer admin-secret")
+	tokenFor := func(audience string) string {
+		var out struct {
+			AccessToken string `json:"access_token"`
+		}
+		do(http.MethodPost, "/internal/v1/service-accounts/token", map[string]any{"client_id": account.ID, "client_secret": account.ClientSecret, "audience": audience}, &out)
+		return out.AccessToken
+	}
+	if code := do(http.MethodGet, base, nil, nil, "X-Tenant-ID", other.ID, "Authorization", "Bearer "+tokenFor("aex-identity")); code != http.StatusOK {
+		t.Fatalf("expected a service token for the identity service to be allowed, got %d", code)
+	}
+	if code := do(http.MethodGet, base, nil, nil, "X-Tenant-ID", other.ID, "Authorization", "Bearer "+tokenFor("aex-settlement")); code != http.StatusForbidden {
+		t.Fatalf("expected a service token for another service to be refused, got %d", code)
+	}
+}
+
+func TestAPIKeyRotationAndExpiry(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []map[string]any
+	)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env struct {
+			EventType string         `json:"event_type"`
+			Data      map[string]any `json:"data"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&env)
+		env.Data["event_type"] = env.EventType
+		mu.Lock()
+		received = append(received, env.Data)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(sink.Close)
+
+	svc := idsvc.New(idst.NewMemoryStore())
+	svc.SetEventsURL(sink.URL)
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	validate := func(key string) int {
+		return do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": key}, nil)
+	}
+	events := func(typ string) []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		var out []map[string]any
+		for _, d := range received {
+			if d["event_type"] == typ {
+				out = append(out, d)
+			}
+		}
+		return out
+	}
+	waitFor := func(typ string, n int) []map[string]any {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if got := events(typ); len(got) >= n {
+				return got
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %d %s events, got %d", n, typ, len(events(typ)))
+		return nil
+	}
+
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-rotation"}, &tenant)
+	base := "/v1/tenants/" + tenant.ID + "/api-keys"
+
+	type key struct {
+		ID                   string     `json:"id"`
+		Key                  string     `json:"key"`
+		Scopes               []string   `json:"scopes"`
+		ExpiresAt            *time.Time `json:"expires_at"`
+		Replaces             string     `json:"replaces"`
+		ReplacedKeyExpiresAt time.Time  `json:"replaced_key_expires_at"`
+	}
+	var original key
+	body := map[string]any{"name": "ci", "scopes": []string{"work:write"}, "expires_at": time.Now().Add(2 * time.Hour)}
+	if status := do(http.MethodPost, base, body, &original); status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+
+	// The replacement keeps the scopes and lifetime; the old key works
+	// until the grace period ends
+	var rotated key
+	if status := do(http.MethodPost, base+"/"+original.ID+"/rotate", map[string]any{"grace_seconds": 60}, &rotated); status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+	if rotated.Replaces != original.ID || rotated.Key == "" || !slices.Equal(rotated.Scopes, []string{"work:write"}) {
+		t.Fatalf("unexpected rotation %+v", rotated)
+	}
+	if rotated.ExpiresAt == nil || rotated.ExpiresAt.Before(time.Now().Add(110*time.Minute)) {
+		t.Fatalf("expected the replacement to keep the key's lifetime, got %v", rotated.ExpiresAt)
+	}
+	if d := time.Until(rotated.ReplacedKeyExpiresAt); d <= 0 || d > time.Minute {
+		t.Fatalf("expected the old key to expire within the grace period, got %v", rotated.ReplacedKeyExpiresAt)
+	}
+	if validate(original.Key) != http.StatusOK || validate(rotated.Key) != http.StatusOK {
+		t.Fatal("expected both keys to validate during the grace period")
+	}
+	if status := do(http.MethodPost, base+"/"+original.ID+"/rotate", nil, nil); status != http.StatusConflict {
+		t.Fatalf("expected a rotated key not to rotate again, got %d", status)
+	}
+	if got := waitFor("apikey.rotated", 1); got[0]["key_id"] != original.ID || got[0]["new_key_id"] != rotated.ID {
+		t.Fatalf("unexpected apikey.rotated %+v", got[0])
+	}
+
+	// Without grace the old key stops working at once
+	var second key
+	if status := do(http.MethodPost, base+"/"+rotated.ID+"/rotate", map[string]any{"grace_seconds": 0}, &second); status != http.StatusCreated {
+		t.Fatalf("expected %d got %d", http.StatusCreated, status)
+	}
+	if validate(rotated.Key) != http.StatusUnauthorized || validate(second.Key) != http.StatusOK {
+		t.Fatal("expected only the replacement to validate")
+	}
+	if status := do(http.MethodPost, base+"/"+rotated.ID+"/rotate", nil, nil); status != http.StatusConflict {
+		t.Fatalf("expected a revoked key not to rotate, got %d", status)
+	}
+	if status := do(http.MethodPost, base+"/"+second.ID+"/rotate", map[string]any{"grace_seconds": -1}, nil); status != http.StatusBadRequest {
+		t.Fatalf("expected %d got %d", http.StatusBadRequest, status)
+	}
+
+	// The monitor reports keys nearing expiry once, skipping rotated ones,
+	// and marks expired keys
+	var stale key
+	do(http.MethodPost, base, map[string]any{"name": "stale", "expires_at": time.Now().Add(-time.Minute)}, &stale)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go svc.RunKeyExpiryMonitor(ctx, 10*time.Millisecond, 24*time.Hour)
+
+	if got := waitFor("apikey.expiring", 1); got[0]["key_id"] != second.ID || got[0]["tenant_id"] != tenant.ID {
+		t.Fatalf("unexpected apikey.expiring %+v", got[0])
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := events("apikey.expiring"); len(got) != 1 {
+		t.Fatalf("expected one apikey.expiring event, got %d", len(got))
+	}
+	var keys []struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	do(http.MethodGet, base, nil, &keys)
+	for _, k := range keys {
+		if k.ID == stale.ID && k.Status != "EXPIRED" {
+			t.Fatalf("expected the stale key to be marked EXPIRED, got %s", k.Status)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any, header ...string) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	validate := func(key string) int {
+		return do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": key}, nil)
+	}
+
+	var tenant struct {
+		ID     string `json:"id"`
+		APIKey struct {
+			ID  string `json:"id"`
+			Key string `json:"key"`
+		} `json:"api_key"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-audit"}, &tenant)
+	base := "/v1/tenants/" + tenant.ID
+
+	var key struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	do(http.MethodPost, base+"/api-keys", map[string]any{"name": "ci"}, &key, "X-User-ID", "user_1")
+	do(http.MethodDelete, base+"/api-keys/"+key.ID, nil, nil)
+	if validate(key.Key) != http.StatusUnauthorized {
+		t.Fatal("expected the revoked key to be refused")
+	}
+	do(http.MethodPost, base+"/suspend", nil, nil)
+	if validate(tenant.APIKey.Key) != http.StatusUnauthorized {
+		t.Fatal("expected the suspended tenant's key to be refused")
+	}
+	do(http.MethodPost, base+"/activate", nil, nil)
+
+	type page struct {
+		Entries []struct {
+			ID       string         `json:"id"`
+			Action   string         `json:"action"`
+			ActorID  string         `json:"actor_id"`
+			TargetID string         `json:"target_id"`
+			Details  map[string]any `json:"details"`
+		} `json:"entries"`
+		NextCursor string `json:"next_cursor"`
+	}
+	var all page
+	if status := do(http.MethodGet, base+"/audit-log", nil, &all); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	var actions []string
+	for _, e := range all.Entries {
+		actions = append(actions, e.Action)
+	}
+	want := []string{
+		"tenant.activated",
+		"apikey.validation_failed",
+		"tenant.suspended",
+		"apikey.validation_failed",
+		"apikey.revoked",
+		"apikey.created",
+		"apikey.created",
+		"tenant.created",
+	}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("expected %v, got %v", want, actions)
+	}
+	if e := all.Entries[5]; e.TargetID != key.ID || e.ActorID != "user_1" {
+		t.Fatalf("unexpected key creation entry %+v", e)
+	}
+
+	var failures page
+	do(http.MethodGet, base+"/audit-log?action=apikey.validation_failed", nil, &failures)
+	if len(failures.Entries) != 2 ||
+		failures.Entries[0].Details["reason"] != "tenant not active" || failures.Entries[0].TargetID != tenant.APIKey.ID ||
+		failures.Entries[1].Details["reason"] != "key revoked" || failures.Entries[1].TargetID != key.ID {
+		t.Fatalf("unexpected validation failures %+v", failures.Entries)
+	}
+	var byKey page
+	do(http.MethodGet, base+"/audit-log?target_id="+key.ID, nil, &byKey)
+	if len(byKey.Entries) != 3 {
+		t.Fatalf("expected 3 entries for the key, got %d", len(byKey.Entries))
+	}
+
+	// Pages continue after the cursor
+	var first, second page
+	do(http.MethodGet, base+"/audit-log?limit=5", nil, &first)
+	if len(first.Entries) != 5 || first.NextCursor == "" {
+		t.Fatalf("expected a first page of 5 with a cursor, got %d %q", len(first.Entries), first.NextCursor)
+	}
+	do(http.MethodGet, base+"/audit-log?limit=5&cursor="+first.NextCursor, nil, &second)
+	if len(second.Entries) != 3 || second.NextCursor != "" || second.Entries[0].ID != all.Entries[5].ID {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+
+	var none page
+	do(http.MethodGet, base+"/audit-log?until=2000-01-01T00:00:00Z", nil, &none)
+	if len(none.Entries) != 0 {
+		t.Fatalf("expected no entries before 2000, got %d", len(none.Entries))
+	}
+	if status := do(http.MethodGet, base+"/audit-log?since=yesterday", nil, nil); status != http.StatusBadRequest {
+		t.Fatalf("expected %d got %d", http.StatusBadRequest, status)
+	}
+	if status := do(http.MethodGet, base+"/audit-log", nil, nil, "X-Tenant-ID", tenant.ID, "X-User-ID", "user_2", "X-User-Role", "VIEWER"); status != http.StatusForbidden {
+		t.Fatalf("expected viewers to be refused the audit log, got %d", status)
+	}
+}
+
+func TestOIDCFederation(t *testing.T) {
+	idpKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]any{"issuer": idp.URL, "jwks_uri": idp.URL + "/keys"})
+		case "/keys":
+			e := big.NewInt(int64(idpKey.E)).Bytes()
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]any{{
+				"kty": "RSA", "kid": "idp-1", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(idpKey.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(e),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(idp.Close)
+	// idToken signs an ID token for the tenant's client, with claims
+	// overriding the defaults.
+	idToken := func(claims map[string]any) string {
+		c := map[string]any{
+			"iss":   idp.URL,
+			"aud":   "aex-console",
+			"sub":   "operator-1",
+			"email": "Ops@Example.test",
+			"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		}
+		for k, v := range claims {
+			c[k] = v
+		}
+		h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "idp-1"})
+		p, _ := json.Marshal(c)
+		input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+		digest := sha256.Sum256([]byte(input))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, idpKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+	do := func(method, path string, body any, out any) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-sso"}, &tenant)
+	base := "/v1/tenants/" + tenant.ID
+	signIn := func(claims map[string]any) (int, string) {
+		var out struct {
+			AccessToken string `json:"access_token"`
+		}
+		status := do(http.MethodPost, "/v1/token", map[string]any{"tenant_id": tenant.ID, "id_token": idToken(claims)}, &out)
+		return status, out.AccessToken
+	}
+	if status, _ := signIn(nil); status != http.StatusBadRequest {
+		t.Fatalf("expected sign-in without sso configured to fail with %d, got %d", http.StatusBadRequest, status)
+	}
+
+	sso := map[string]any{
+		"issuer":      "http://idp.example.test",
+		"client_id":   "aex-console",
+		"group_roles": map[string]string{"platform-admins": "admin", "developers": "DEVELOPER"},
+	}
+	if status := do(http.MethodPut, base+"/sso", sso, nil); status != http.StatusBadRequest {
+		t.Fatalf("expected plain http issuers to be refused, got %d", status)
+	}
+	sso["issuer"] = idp.URL
+	var cfg struct {
+		GroupsClaim string            `json:"groups_claim"`
+		GroupRoles  map[string]string `json:"group_roles"`
+	}
+	if status := do(http.MethodPut, base+"/sso", sso, &cfg); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if cfg.GroupsClaim != "groups" || cfg.GroupRoles["platform-admins"] != "ADMIN" {
+		t.Fatalf("unexpected sso config %+v", cfg)
+	}
+
+	// The highest role of the user's groups applies, and the user is added
+	// on first sign-in
+	status, accessToken := signIn(map[string]any{"groups": []string{"developers", "platform-admins"}})
+	if status != http.StatusOK || accessToken == "" {
+		t.Fatalf("expected sign-in to succeed, got %d", status)
+	}
+	var v struct {
+		TenantID string `json:"tenant_id"`
+		UserID   string `json:"user_id"`
+		UserRole string `json:"user_role"`
+	}
+	if status := do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": accessToken}, &v); status != http.StatusOK {
+		t.Fatalf("expected the token to validate, got %d", status)
+	}
+	if v.TenantID != tenant.ID || v.UserRole != "ADMIN" || v.UserID == "" {
+		t.Fatalf("unexpected validation %+v", v)
+	}
+	var u struct {
+		Email      string `json:"email"`
+		Role       string `json:"role"`
+		SSOSubject string `json:"sso_subject"`
+	}
+	do(http.MethodGet, base+"/users/"+v.UserID, nil, &u)
+	if u.Email != "ops@example.test" || u.SSOSubject != "operator-1" {
+		t.Fatalf("unexpected user %+v", u)
+	}
+
+	// Roles follow the groups at each sign-in
+	status, accessToken = signIn(map[string]any{"groups": []string{"developers"}})
+	do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": accessToken}, &v)
+	if status != http.StatusOK || v.UserRole != "DEVELOPER" {
+		t.Fatalf("expected the user to be a DEVELOPER now, got %d %+v", status, v)
+	}
+	var users struct {
+		Total int `json:"total"`
+	}
+	do(http.MethodGet, base+"/users", nil, &users)
+	if users.Total != 1 {
+		t.Fatalf("expected one user, got %d", users.Total)
+	}
+
+	if status, _ := signIn(map[string]any{"groups": []string{"sales"}}); status != http.StatusForbidden {
+		t.Fatalf("expected users without a mapped group to be refused, got %d", status)
+	}
+	if status, _ := signIn(map[string]any{"aud": "other-app"}); status != http.StatusUnauthorized {
+		t.Fatalf("expected tokens for another client to be refused, got %d", status)
+	}
+	if status, _ := signIn(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}); status != http.StatusUnauthorized {
+		t.Fatalf("expected expired tokens to be refused, got %d", status)
+	}
+
+	var failures struct {
+		Entries []struct{} `json:"entries"`
+	}
+	do(http.MethodGet, base+"/audit-log?action=user.sso_login_failed", nil, &failures)
+	if len(failures.Entries) != 3 {
+		t.Fatalf("expected 3 failed sign-ins in the audit log, got %d", len(failures.Entries))
+	}
+
+	if status := do(http.MethodDelete, base+"/sso", nil, nil); status != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, status)
+	}
+	if status, _ := signIn(map[string]any{"groups": []string{"developers"}}); status != http.StatusBadRequest {
+		t.Fatalf("expected sign-in to stop once sso is removed, got %d", status)
+	}
+}
+
+func TestServiceAccounts(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	svc.SetAdminToken("admin-secret")
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any, header ...string) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	admin := []string{"Authorization", "Bearer admin-secret"}
+
+	create := map[string]any{"name": "aex-contract-engine", "audiences": []string{"aex-settlement", "aex-trust-broker", "aex-settlement"}}
+	if code := do(http.MethodPost, "/admin/v1/service-accounts", create, nil); code != http.StatusUnauthorized {
+		t.Fatalf("create without admin token: expected 401, got %d", code)
+	}
+
+	// Without a configured admin token the admin routes are closed.
+	unconfigured := httptest.NewServer(idhttp.NewRouter(idsvc.New(idst.NewMemoryStore())))
+	t.Cleanup(unconfigured.Close)
+	for _, c := range []struct{ method, path string }{
+		{http.MethodPost, "/admin/v1/service-accounts"},
+		{http.MethodGet, "/admin/v1/service-accounts"},
+		{http.MethodDelete, "/admin/v1/service-accounts/sa_x"},
+	} {
+		b, _ := json.Marshal(create)
+		req, _ := http.NewRequest(c.method, unconfigured.URL+c.path, bytes.NewReader(b))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("%s %s without an admin token configured: expected 403, got %d", c.method, c.path, resp.StatusCode)
+		}
+	}
+	var account struct {
+		ID           string   `json:"id"`
+		Audiences    []string `json:"audiences"`
+		Status       string   `json:"status"`
+		ClientSecret string   `json:"client_secret"`
+	}
+	if code := do(http.MethodPost, "/admin/v1/service-accounts", create, &account, admin...); code != http.StatusCreated {
+		t.Fatalf("create service account: expected 201, got %d", code)
+	}
+	if !strings.HasPrefix(account.ID, "sa_") || account.ClientSecret == "" || len(account.Audiences) != 2 || account.Status != "ACTIVE" {
+		t.Fatalf("unexpected service account: %+v", account)
+	}
+
+	var listed struct {
+		ServiceAccounts []map[string]any `json:"service_accounts"`
+		Total           int              `json:"total"`
+	}
+	do(http.MethodGet, "/admin/v1/service-accounts", nil, &listed, admin...)
+	if listed.Total != 1 || listed.ServiceAccounts[0]["client_secret"] != nil || listed.ServiceAccounts[0]["secret_hash"] != nil {
+		t.Fatalf("list must show the account without its secret: %+v", listed)
+	}
+
+	token := func(secret, audience string) (int, string) {
+		var out struct {
+			AccessToken string `json:"access_token"`
+		}
+		code := do(http.MethodPost, "/internal/v1/service-accounts/token",
+			map[string]any{"client_id": account.ID, "client_secret": secret, "audience": audience}, &out)
+		return code, out.AccessToken
+	}
+	code, tok := token(account.ClientSecret, "aex-settlement")
+	if code != http.StatusOK || tok == "" {
+		t.Fatalf("service token: expected 200, got %d", code)
+	}
+	if code, _ := token("aexs_wrong", "aex-settlement"); code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: expected 401, got %d", code)
+	}
+	if code, _ := token(account.ClientSecret, "aex-token-bank"); code != http.StatusForbidden {
+		t.Errorf("audience not granted: expected 403, got %d", code)
+	}
+
+	// Services verify the token with the published keys; it is only good
+	// for its audience and is never taken for a tenant's token.
+	settlement := serviceauth.NewVerifier(ts.URL, "aex-settlement")
+	claims, err := settlement.Verify(context.Background(), tok)
+	if err != nil {
+		t.Fatalf("verify service token: %v", err)
+	}
+	if claims.Subject != account.ID || claims.Service != "aex-contract-engine" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if _, err := serviceauth.NewVerifier(ts.URL, "aex-trust-broker").Verify(context.Background(), tok); err != serviceauth.ErrUnauthorized {
+		t.Errorf("token for another audience: expected ErrUnauthorized, got %v", err)
+	}
+	if code := do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": tok}, nil); code != http.StatusUnauthorized {
+		t.Errorf("service token as tenant credential: expected 401, got %d", code)
+	}
+	var tenant struct {
+		APIKey struct {
+			Key string `json:"key"`
+		} `json:"api_key"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-sa"}, &tenant)
+	var tenantToken struct {
+		AccessToken string `json:"access_token"`
+	}
+	do(http.MethodPost, "/v1/token", map[string]any{"api_key": tenant.APIKey.Key}, &tenantToken)
+	if _, err := settlement.Verify(context.Background(), tenantToken.AccessToken); err != serviceauth.ErrUnauthorized {
+		t.Errorf("tenant token as service token: expected ErrUnauthorized, got %v", err)
+	}
+
+	// Rotating the secret stops the old one at once.
+	var rotated struct {
+		ClientSecret string `json:"client_secret"`
+	}
+	if code := do(http.MethodPost, "/admin/v1/service-accounts/"+account.ID+"/rotate-secret", nil, &rotated, admin...); code != http.StatusOK {
+		t.Fatalf("rotate secret: expected 200, got %d", code)
+	}
+	if code, _ := token(account.ClientSecret, "aex-settlement"); code != http.StatusUnauthorized {
+		t.Errorf("old secret after rotation: expected 401, got %d", code)
+	}
+	if code, _ := token(rotated.ClientSecret, "aex-settlement"); code != http.StatusOK {
+		t.Errorf("new secret: expected 200, got %d", code)
+	}
+
+	if code := do(http.MethodDelete, "/admin/v1/service-accounts/"+account.ID, nil, nil, admin...); code != http.StatusOK {
+		t.Fatalf("disable: expected 200, got %d", code)
+	}
+	if code, _ := token(rotated.ClientSecret, "aex-settlement"); code != http.StatusUnauthorized {
+		t.Errorf("disabled account: expected 401, got %d", code)
+	}
+	var got struct {
+		Status     string `json:"status"`
+		LastUsedAt string `json:"last_used_at"`
+	}
+	do(http.MethodGet, "/admin/v1/service-accounts/"+account.ID, nil, &got, admin...)
+	if got.Status != "DISABLED" || got.LastUsedAt == "" {
+		t.Errorf("unexpected account after disabling: %+v", got)
+	}
+}
+
+func TestAPIKeyRestrictions(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-restricted"}, &tenant)
+	base := "/v1/tenants/" + tenant.ID
+
+	for _, bad := range []map[string]any{
+		{"name": "k", "allowed_cidrs": []string{"10.0.0.0/33"}},
+		{"name": "k", "allowed_routes": []string{"v1/bids"}},
+	} {
+		if code := do(http.MethodPost, base+"/api-keys", bad, nil); code != http.StatusBadRequest {
+			t.Errorf("create key with %v: expected 400, got %d", bad, code)
+		}
+	}
+
+	var key struct {
+		ID            string   `json:"id"`
+		Key           string   `json:"key"`
+		AllowedCIDRs  []string `json:"allowed_cidrs"`
+		AllowedRoutes []string `json:"allowed_routes"`
+	}
+	create := map[string]any{
+		"name":           "bidder",
+		"scopes":         []string{"bids:write"},
+		"allowed_cidrs":  []string{"10.1.2.3/8", "2001:db8::1"},
+		"allowed_routes": []string{"/v1/bids/"},
+	}
+	if code := do(http.MethodPost, base+"/api-keys", create, &key); code != http.StatusCreated {
+		t.Fatalf("create restricted key: expected 201, got %d", code)
+	}
+	if !slices.Equal(key.AllowedCIDRs, []string{"10.0.0.0/8", "2001:db8::1/128"}) || !slices.Equal(key.AllowedRoutes, []string{"/v1/bids"}) {
+		t.Fatalf("restrictions not normalized: %v %v", key.AllowedCIDRs, key.AllowedRoutes)
+	}
+
+	validate := func(credential string, extra map[string]any) (int, map[string]any) {
+		body := map[string]any{"api_key": credential}
+		for k, v := range extra {
+			body[k] = v
+		}
+		var out map[string]any
+		return do(http.MethodPost, "/internal/v1/apikeys/validate", body, &out), out
+	}
+	code, out := validate(key.Key, nil)
+	if code != http.StatusOK || out["allowed_routes"] == nil || out["allowed_cidrs"] == nil {
+		t.Fatalf("validate without context: expected 200 with the restrictions, got %d %v", code, out)
+	}
+	cases := []struct {
+		extra map[string]any
+		want  int
+	}{
+		{map[string]any{"client_ip": "10.9.9.9", "route": "/v1/bids/bid_1"}, http.StatusOK},
+		{map[string]any{"client_ip": "2001:db8::1", "route": "/v1/bids"}, http.StatusOK},
+		{map[string]any{"client_ip": "192.0.2.1"}, http.StatusForbidden},
+		{map[string]any{"route": "/v1/bidsx"}, http.StatusForbidden},
+		{map[string]any{"route": "/v1/work"}, http.StatusForbidden},
+		{map[string]any{"client_ip": "not-an-ip"}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if code, _ := validate(key.Key, c.extra); code != c.want {
+			t.Errorf("validate with %v: expected %d, got %d", c.extra, c.want, code)
+		}
+	}
+
+	// Tokens carry the key's restrictions.
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	do(http.MethodPost, "/v1/token", map[string]any{"api_key": key.Key}, &tok)
+	if code, _ := validate(tok.AccessToken, map[string]any{"route": "/v1/work"}); code != http.StatusForbidden {
+		t.Errorf("token on /v1/work: expected 403, got %d", code)
+	}
+	if code, out := validate(tok.AccessToken, map[string]any{"client_ip": "10.0.0.1"}); code != http.StatusOK || out["allowed_routes"] == nil {
+		t.Errorf("token from 10.0.0.1: expected 200 with the restrictions, got %d %v", code, out)
+	}
+
+	// Rotation keeps them.
+	var rotated struct {
+		Key           string   `json:"key"`
+		AllowedRoutes []string `json:"allowed_routes"`
+	}
+	do(http.MethodPost, base+"/api-keys/"+key.ID+"/rotate", nil, &rotated)
+	if !slices.Equal(rotated.AllowedRoutes, []string{"/v1/bids"}) {
+		t.Errorf("rotated key restrictions: %v", rotated.AllowedRoutes)
+	}
+	if code, _ := validate(rotated.Key, map[string]any{"route": "/v1/work"}); code != http.StatusForbidden {
+		t.Errorf("rotated key on /v1/work: expected 403, got %d", code)
+	}
+
+	var audit struct {
+		Entries []struct {
+			TargetID string         `json:"target_id"`
+			Details  map[string]any `json:"details"`
+		} `json:"entries"`
+	}
+	do(http.MethodGet, base+"/audit-log?action=apikey.validation_failed", nil, &audit)
+	reasons := map[any]int{}
+	for _, e := range audit.Entries {
+		reasons[e.Details["reason"]]++
+	}
+	if reasons["address not allowed"] != 1 || reasons["route not allowed"] != 4 {
+		t.Errorf("unexpected validation failures in the audit log: %v", reasons)
+	}
+}
+
+func TestLifecycleWebhooks(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []map[string]any
+	)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(events.SignatureHeader); got != events.Sign([]byte("hook-secret"), body) {
+			t.Errorf("event signature %q does not match its body", got)
+		}
+		var env struct {
+			EventType string         `json:"event_type"`
+			TenantID  string         `json:"tenant_id"`
+			Data      map[string]any `json:"data"`
+		}
+		_ = json.Unmarshal(body, &env)
+		env.Data["event_type"] = env.EventType
+		mu.Lock()
+		received = append(received, env.Data)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(gateway.Close)
+
+	svc := idsvc.New(idst.NewMemoryStore())
+	svc.SetLifecycleWebhooks([]string{gateway.URL}, "hook-secret")
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any) int {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+	// waitFor returns the events of type typ once there are n of them.
+	waitFor := func(typ string, n int) []map[string]any {
+		t.Helper()
+		var got []map[string]any
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			mu.Lock()
+			got = nil
+			for _, d := range received {
+				if d["event_type"] == typ {
+					got = append(got, d)
+				}
+			}
+			mu.Unlock()
+			if len(got) >= n {
+				return got
+			}
+		}
+		t.Fatalf("expected %d %s events, got %d", n, typ, len(got))
+		return nil
+	}
+
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-lifecycle"}, &tenant)
+	base := "/v1/tenants/" + tenant.ID
+
+	var key struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	do(http.MethodPost, base+"/api-keys", map[string]any{"name": "k"}, &key)
+	var validated struct {
+		KeyID string `json:"key_id"`
+	}
+	do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": key.Key}, &validated)
+	if validated.KeyID != key.ID {
+		t.Errorf("validation key_id = %q, want %q", validated.KeyID, key.ID)
+	}
+
+	if code := do(http.MethodPost, base+"/suspend", nil, nil); code != http.StatusOK {
+		t.Fatalf("suspend: expected 200, got %d", code)
+	}
+	if got := waitFor("tenant.suspended", 1); got[0]["tenant_id"] != tenant.ID || got[0]["suspended_at"] == nil {
+		t.Errorf("unexpected tenant.suspended event: %v", got[0])
+	}
+	if code := do(http.MethodPost, base+"/activate", nil, nil); code != http.StatusOK {
+		t.Fatalf("activate: expected 200, got %d", code)
+	}
+	if got := waitFor("tenant.activated", 1); got[0]["tenant_id"] != tenant.ID {
+		t.Errorf("unexpected tenant.activated event: %v", got[0])
+	}
+
+	if code := do(http.MethodDelete, base+"/api-keys/"+key.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("revoke: expected 200, got %d", code)
+	}
+	if got := waitFor("apikey.revoked", 1); got[0]["key_id"] != key.ID || got[0]["tenant_id"] != tenant.ID {
+		t.Errorf("unexpected apikey.revoked event: %v", got[0])
+	}
+
+	// Rotating without a grace period revokes the old key at once.
+	do(http.MethodPost, base+"/api-keys", map[string]any{"name": "rotated"}, &key)
+	if code := do(http.MethodPost, base+"/api-keys/"+key.ID+"/rotate", map[string]any{"grace_seconds": 0}, nil); code != http.StatusCreated {
+		t.Fatalf("rotate: expected 201, got %d", code)
+	}
+	if got := waitFor("apikey.revoked", 2); got[1]["key_id"] != key.ID || got[1]["reason"] != "rotated" {
+		t.Errorf("unexpected apikey.revoked event for the rotated key: %v", got[1])
+	}
+
+	// Deleting the tenant revokes its remaining key.
+	if code := do(http.MethodDelete, base, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete tenant: expected 200, got %d", code)
+	}
+	if got := waitFor("apikey.revoked", 3); got[2]["reason"] != "tenant deleted" {
+		t.Errorf("unexpected apikey.revoked event for the deleted tenant: %v", got[2])
+	}
+}
+
+func TestBatchValidationAndCachingHints(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any, header ...string) *http.Response {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp
+	}
+
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-batch"}, &tenant)
+	var key, expiring struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	do(http.MethodPost, "/v1/tenants/"+tenant.ID+"/api-keys", map[string]any{"name": "k"}, &key)
+	do(http.MethodPost, "/v1/tenants/"+tenant.ID+"/api-keys", map[string]any{
+		"name": "expiring", "expires_at": time.Now().Add(30 * time.Second),
+	}, &expiring)
+
+	// Single validations may be cached for the default minute, and are
+	// answered 304 when the caller's copy is current.
+	resp := do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": key.Key}, nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" || resp.Header.Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("validate: expected 200 with an ETag and max-age=60, got %d %v", resp.StatusCode, resp.Header)
+	}
+	resp = do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": key.Key}, nil, "If-None-Match", etag)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("validate with If-None-Match: expected 304, got %d", resp.StatusCode)
+	}
+	resp = do(http.MethodPost, "/internal/v1/apikeys/validate", map[string]any{"api_key": "aexk_unknown"}, nil)
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Errorf("validate unknown key: expected 401 with no-store, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	type result struct {
+		Valid         bool   `json:"valid"`
+		Status        int    `json:"status"`
+		Error         string `json:"error"`
+		MaxAgeSeconds int    `json:"max_age_seconds"`
+		KeyID         string `json:"key_id"`
+		TenantID      string `json:"tenant_id"`
+	}
+	var batch struct {
+		Results []result `json:"results"`
+	}
+	resp = do(http.MethodPost, "/internal/v1/api-keys/validate-batch", map[string]any{"keys": []map[string]any{
+		{"api_key": key.Key},
+		{"api_key": expiring.Key},
+		{"api_key": "aexk_unknown"},
+		{"api_key": ""},
+	}}, &batch)
+	if resp.StatusCode != http.StatusOK || len(batch.Results) != 4 {
+		t.Fatalf("validate-batch: expected 200 with 4 results, got %d %+v", resp.StatusCode, batch)
+	}
+	if r := batch.Results[0]; !r.Valid || r.Status != 200 || r.KeyID != key.ID || r.TenantID != tenant.ID || r.MaxAgeSeconds != 60 {
+		t.Errorf("result 0: %+v", r)
+	}
+	if r := batch.Results[1]; !r.Valid || r.KeyID != expiring.ID || r.MaxAgeSeconds > 30 || r.MaxAgeSeconds < 25 {
+		t.Errorf("result 1 should be cacheable only until the key expires: %+v", r)
+	}
+	if r := batch.Results[2]; r.Valid || r.Status != http.StatusUnauthorized || r.TenantID != "" {
+		t.Errorf("result 2: %+v", r)
+	}
+	if r := batch.Results[3]; r.Valid || r.Status != http.StatusBadRequest || r.Error != "api_key is required" {
+		t.Errorf("result 3: %+v", r)
+	}
+	if got := resp.Header.Get("Cache-Control"); got != "private, max-age=0" {
+		t.Errorf("batch with invalid keys: Cache-Control = %q, want max-age=0", got)
+	}
+
+	resp = do(http.MethodPost, "/internal/v1/api-keys/validate-batch", map[string]any{"keys": []map[string]any{
+		{"api_key": key.Key}, {"api_key": expiring.Key},
+	}}, nil)
+	if cc := resp.Header.Get("Cache-Control"); !strings.HasPrefix(cc, "private, max-age=") || cc == "private, max-age=60" || cc == "private, max-age=0" {
+		t.Errorf("batch of valid keys: Cache-Control = %q, want the shortest result's max-age", cc)
+	}
+	resp = do(http.MethodPost, "/internal/v1/api-keys/validate-batch", map[string]any{"keys": []map[string]any{
+		{"api_key": key.Key}, {"api_key": expiring.Key},
+	}}, nil, "If-None-Match", resp.Header.Get("ETag"))
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("batch with If-None-Match: expected 304, got %d", resp.StatusCode)
+	}
+
+	tooMany := make([]map[string]any, 101)
+	for i := range tooMany {
+		tooMany[i] = map[string]any{"api_key": key.Key}
+	}
+	for _, keys := range [][]map[string]any{nil, tooMany} {
+		if resp := do(http.MethodPost, "/internal/v1/api-keys/validate-batch", map[string]any{"keys": keys}, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("batch of %d keys: expected 400, got %d", len(keys), resp.StatusCode)
+		}
+	}
+}
+
+func TestValidationRateLimit(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	svc.SetValidationRateLimit(1, 0)
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	post := func(path string, body any) *http.Response {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		resp, err := http.Post(ts.URL+path, "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	// The burst is at least a full batch; each key counts.
+	keys := make([]map[string]any, 100)
+	for i := range keys {
+		keys[i] = map[string]any{"api_key": "aexk_unknown"}
+	}
+	if resp := post("/internal/v1/api-keys/validate-batch", map[string]any{"keys": keys}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first batch: expected 200, got %d", resp.StatusCode)
+	}
+	resp := post("/internal/v1/apikeys/validate", map[string]any{"api_key": "aexk_unknown"})
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("validation over the limit: expected 429 with Retry-After, got %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	svc := idsvc.New(idst.NewMemoryStore())
+	ts := httptest.NewServer(idhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any, out any) *http.Response {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if out != nil {
+			_ = json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp
+	}
+
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	do(http.MethodPost, "/v1/tenants", map[string]any{"name": "tenant-routing"}, &tenant)
+	base := "/v1/tenants/" + tenant.ID
+	var key struct {
+		ID string `json:"id"`
+	}
+	if resp := do(http.MethodPost, base+"/api-keys", map[string]any{}, &key); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create key: expected 201, got %d", resp.StatusCode)
+	}
+	if resp := do(http.MethodPost, base+"/api-keys/"+key.ID+"/rotate", map[string]any{}, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("rotate key: expected 201, got %d", resp.StatusCode)
+	}
+
+	// Paths with segments no route has are not found, rather than handled
+	// by the route they end like.
+	for _, path := range []string{
+		base + "/bogus",
+		base + "/bogus/api-keys",
+		base + "/sso/extra",
+		base + "/users/x/y/api-keys",
+		"/v1/organizations/o/bogus",
+	} {
+		if resp := do(http.MethodGet, path, nil, nil); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("GET %s: expected 404, got %d", path, resp.StatusCode)
+		}
+	}
+
+	// Known paths answer other methods 405 with those they allow.
+	resp := do(http.MethodPut, base, map[string]any{}, nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("PUT tenant: expected 405, got %d", resp.StatusCode)
+	}
+	if allow := resp.Header.Get("Allow"); !strings.Contains(allow, http.MethodPatch) || !strings.Contains(allow, http.MethodDelete) {
+		t.Fatalf("PUT tenant: expected Allow with PATCH and DELETE, got %q", allow)
+	}
+	if resp := do(http.MethodGet, base+"/suspend", nil, nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET suspend: expected 405, got %d", resp.StatusCode)
+	}
+	if resp := do(http.MethodGet, "/internal/v1/apikeys/validate", nil, nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET validate: expected 405, got %d", resp.StatusCode)
+	}
+}
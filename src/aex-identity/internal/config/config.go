@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,7 +13,44 @@ type Config struct {
 	MongoURI               string
 	MongoDatabase          string
 	MongoCollectionTenants string
+	MongoCollectionOrgs    string
 	MongoCollectionAPIKeys string
+	MongoCollectionUsers   string
+	MongoCollectionUsage   string
+	MongoCollectionAudit   string
+	MongoCollectionSvcAccs string
+
+	// Tokens issued by POST /v1/token are signed with the P-256 key in
+	// JWTSigningKeyFile, or a key generated at startup when it is unset.
+	JWTSigningKeyFile string
+	JWTIssuer         string
+	JWTTTL            time.Duration
+
+	// EventsURL receives tenant and API key lifecycle events (optional).
+	EventsURL string
+	// LifecycleWebhookURLs, such as the gateways', also receive tenant
+	// suspensions and activations and API key revocations, so they stop
+	// accepting credentials at once rather than when their caches expire.
+	// Events are signed with WebhookSecret.
+	LifecycleWebhookURLs []string
+	WebhookSecret        string
+	// Active API keys expiring within APIKeyExpiryWarning are reported
+	// with an apikey.expiring event, checked every APIKeyExpiryCheckInterval.
+	APIKeyExpiryWarning       time.Duration
+	APIKeyExpiryCheckInterval time.Duration
+	// APIKeyRotationGrace is how long a rotated key keeps working by default.
+	APIKeyRotationGrace time.Duration
+
+	// Validations may be cached for up to ValidationMaxAge. Each caller may
+	// validate ValidationRatePerSecond keys, in bursts of up to
+	// ValidationBurst; zero removes the limit.
+	ValidationMaxAge        time.Duration
+	ValidationRatePerSecond int
+	ValidationBurst         int
+
+	// AdminToken protects the /admin routes, which manage service
+	// accounts, when set.
+	AdminToken string
 
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
@@ -21,14 +59,32 @@ type Config struct {
 
 func Load() Config {
 	return Config{
-		Port:                   getenv("PORT", "8080"),
-		MongoURI:               strings.TrimSpace(os.Getenv("MONGO_URI")),
-		MongoDatabase:          getenv("MONGO_DB", "aex"),
-		MongoCollectionTenants: getenv("MONGO_COLLECTION_TENANTS", "tenants"),
-		MongoCollectionAPIKeys: getenv("MONGO_COLLECTION_APIKEYS", "api_keys"),
-		ReadTimeout:            10 * time.Second,
-		WriteTimeout:           20 * time.Second,
-		IdleTimeout:            60 * time.Second,
+		Port:                      getenv("PORT", "8080"),
+		MongoURI:                  strings.TrimSpace(os.Getenv("MONGO_URI")),
+		MongoDatabase:             getenv("MONGO_DB", "aex"),
+		MongoCollectionTenants:    getenv("MONGO_COLLECTION_TENANTS", "tenants"),
+		MongoCollectionOrgs:       getenv("MONGO_COLLECTION_ORGANIZATIONS", "organizations"),
+		MongoCollectionAPIKeys:    getenv("MONGO_COLLECTION_APIKEYS", "api_keys"),
+		MongoCollectionUsers:      getenv("MONGO_COLLECTION_USERS", "tenant_users"),
+		MongoCollectionUsage:      getenv("MONGO_COLLECTION_USAGE", "tenant_usage"),
+		MongoCollectionAudit:      getenv("MONGO_COLLECTION_AUDIT", "audit_log"),
+		MongoCollectionSvcAccs:    getenv("MONGO_COLLECTION_SERVICE_ACCOUNTS", "service_accounts"),
+		JWTSigningKeyFile:         strings.TrimSpace(os.Getenv("JWT_SIGNING_KEY_FILE")),
+		JWTIssuer:                 getenv("JWT_ISSUER", "aex-identity"),
+		JWTTTL:                    time.Duration(getenvInt("JWT_TTL_SECONDS", 900)) * time.Second,
+		EventsURL:                 strings.TrimSpace(os.Getenv("EVENTS_URL")),
+		LifecycleWebhookURLs:      getenvList("LIFECYCLE_WEBHOOK_URLS"),
+		WebhookSecret:             strings.TrimSpace(os.Getenv("WEBHOOK_SECRET")),
+		APIKeyExpiryWarning:       time.Duration(getenvInt("API_KEY_EXPIRY_WARNING_HOURS", 168)) * time.Hour,
+		APIKeyExpiryCheckInterval: time.Duration(getenvInt("API_KEY_EXPIRY_CHECK_SECONDS", 3600)) * time.Second,
+		APIKeyRotationGrace:       time.Duration(getenvInt("API_KEY_ROTATION_GRACE_SECONDS", 86400)) * time.Second,
+		ValidationMaxAge:          time.Duration(getenvInt("API_KEY_VALIDATION_MAX_AGE_SECONDS", 60)) * time.Second,
+		ValidationRatePerSecond:   getenvIntOrZero("API_KEY_VALIDATION_RATE_PER_SECOND", 1000),
+		ValidationBurst:           getenvInt("API_KEY_VALIDATION_BURST", 2000),
+		AdminToken:                strings.TrimSpace(os.Getenv("ADMIN_TOKEN")),
+		ReadTimeout:               10 * time.Second,
+		WriteTimeout:              20 * time.Second,
+		IdleTimeout:               60 * time.Second,
 	}
 }
 
@@ -38,3 +94,29 @@ func getenv(k, def string) string {
 	}
 	return def
 }
+
+// getenvList reads a comma-separated list, skipping empty entries.
+func getenvList(k string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(k), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func getenvInt(k string, def int) int {
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv(k))); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// getenvIntOrZero is getenvInt for settings that zero turns off.
+func getenvIntOrZero(k string, def int) int {
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv(k))); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
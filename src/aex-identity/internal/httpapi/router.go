@@ -2,75 +2,67 @@ package httpapi
 
 import (
 	"net/http"
-	"strings"
 
 	"github.com/parlakisik/agent-exchange/aex-identity/internal/service"
 )
 
+// NewRouter routes the identity service's endpoints. Handlers read the IDs
+// in the path with r.PathValue; a path known for other methods is answered
+// 405 with the methods it allows.
 func NewRouter(svc *service.Service) http.Handler {
 	mux := http.NewServeMux()
 
 	// External
 	mux.HandleFunc("POST /v1/tenants", svc.HandleCreateTenant)
-	mux.HandleFunc("GET /v1/tenants/", dispatchTenantGET(svc))       // /v1/tenants/{id} OR /v1/tenants/{id}/api-keys
-	mux.HandleFunc("POST /v1/tenants/", dispatchTenantPOST(svc))     // /v1/tenants/{id}/suspend|activate|api-keys
-	mux.HandleFunc("DELETE /v1/tenants/", dispatchTenantDELETE(svc)) // /v1/tenants/{id}/api-keys/{key_id}
+	mux.HandleFunc("GET /v1/tenants", svc.HandleListTenants)
+	mux.HandleFunc("GET /v1/tenants/{tenant_id}", svc.HandleGetTenant)
+	mux.HandleFunc("PATCH /v1/tenants/{tenant_id}", svc.HandleUpdateTenant)
+	mux.HandleFunc("DELETE /v1/tenants/{tenant_id}", svc.HandleDeleteTenant)
+	mux.HandleFunc("POST /v1/tenants/{tenant_id}/suspend", svc.HandleSuspendTenant)
+	mux.HandleFunc("POST /v1/tenants/{tenant_id}/activate", svc.HandleActivateTenant)
+	mux.HandleFunc("GET /v1/tenants/{tenant_id}/api-keys", svc.HandleListAPIKeys)
+	mux.HandleFunc("POST /v1/tenants/{tenant_id}/api-keys", svc.HandleCreateAPIKey)
+	mux.HandleFunc("DELETE /v1/tenants/{tenant_id}/api-keys/{key_id}", svc.HandleRevokeAPIKey)
+	mux.HandleFunc("POST /v1/tenants/{tenant_id}/api-keys/{key_id}/rotate", svc.HandleRotateAPIKey)
+	mux.HandleFunc("GET /v1/tenants/{tenant_id}/audit-log", svc.HandleGetAuditLog)
+	mux.HandleFunc("GET /v1/tenants/{tenant_id}/sso", svc.HandleGetSSO)
+	mux.HandleFunc("PUT /v1/tenants/{tenant_id}/sso", svc.HandleConfigureSSO)
+	mux.HandleFunc("DELETE /v1/tenants/{tenant_id}/sso", svc.HandleDeleteSSO)
+	mux.HandleFunc("GET /v1/tenants/{tenant_id}/users", svc.HandleListUsers)
+	mux.HandleFunc("POST /v1/tenants/{tenant_id}/users", svc.HandleCreateUser)
+	mux.HandleFunc("GET /v1/tenants/{tenant_id}/users/{user_id}", svc.HandleGetUser)
+	mux.HandleFunc("PATCH /v1/tenants/{tenant_id}/users/{user_id}", svc.HandleUpdateUser)
+	mux.HandleFunc("DELETE /v1/tenants/{tenant_id}/users/{user_id}", svc.HandleDeleteUser)
+	mux.HandleFunc("GET /v1/tenants/{tenant_id}/users/{user_id}/api-keys", svc.HandleListUserAPIKeys)
+	mux.HandleFunc("POST /v1/tenants/{tenant_id}/users/{user_id}/api-keys", svc.HandleCreateUserAPIKey)
+	mux.HandleFunc("POST /v1/organizations", svc.HandleCreateOrganization)
+	mux.HandleFunc("GET /v1/organizations/{org_id}", svc.HandleGetOrganization)
+	mux.HandleFunc("PATCH /v1/organizations/{org_id}", svc.HandleUpdateOrganization)
+	mux.HandleFunc("GET /v1/organizations/{org_id}/tenants", svc.HandleListOrganizationTenants)
+	mux.HandleFunc("POST /v1/organizations/{org_id}/tenants", svc.HandleAddOrganizationTenant)
+	mux.HandleFunc("DELETE /v1/organizations/{org_id}/tenants/{tenant_id}", svc.HandleRemoveOrganizationTenant)
+	mux.HandleFunc("GET /v1/organizations/{org_id}/api-keys", svc.HandleListOrganizationAPIKeys)
+	mux.HandleFunc("POST /v1/organizations/{org_id}/api-keys", svc.HandleCreateOrganizationAPIKey)
+	mux.HandleFunc("DELETE /v1/organizations/{org_id}/api-keys/{key_id}", svc.HandleRevokeOrganizationAPIKey)
+	mux.HandleFunc("GET /v1/scopes", svc.HandleListScopes)
+	mux.HandleFunc("POST /v1/token", svc.HandleIssueToken)
+	mux.HandleFunc("GET /.well-known/jwks.json", svc.HandleJWKS)
+
+	// Admin
+	mux.HandleFunc("POST /admin/v1/service-accounts", svc.HandleCreateServiceAccount)
+	mux.HandleFunc("GET /admin/v1/service-accounts", svc.HandleListServiceAccounts)
+	mux.HandleFunc("GET /admin/v1/service-accounts/{account_id}", svc.HandleGetServiceAccount)
+	mux.HandleFunc("DELETE /admin/v1/service-accounts/{account_id}", svc.HandleDisableServiceAccount)
+	mux.HandleFunc("POST /admin/v1/service-accounts/{account_id}/rotate-secret", svc.HandleRotateServiceAccountSecret)
 
 	// Internal
 	mux.HandleFunc("POST /internal/v1/apikeys/validate", svc.HandleValidateAPIKey)
-	mux.HandleFunc("GET /internal/v1/tenants/", dispatchInternalQuotas(svc))
+	mux.HandleFunc("POST /internal/v1/api-keys/validate-batch", svc.HandleValidateAPIKeys)
+	mux.HandleFunc("POST /internal/v1/service-accounts/token", svc.HandleIssueServiceToken)
+	mux.HandleFunc("GET /internal/v1/tenants/{tenant_id}/quotas", svc.HandleGetQuotas)
+	mux.HandleFunc("GET /internal/v1/tenants/{tenant_id}/usage", svc.HandleGetUsage)
+	mux.HandleFunc("POST /internal/v1/tenants/{tenant_id}/usage", svc.HandleRecordUsage)
 
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
 	return mux
 }
-
-func dispatchTenantGET(svc *service.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch {
-		case strings.HasSuffix(r.URL.Path, "/api-keys"):
-			svc.HandleListAPIKeys(w, r)
-		default:
-			svc.HandleGetTenant(w, r)
-		}
-	}
-}
-
-func dispatchTenantPOST(svc *service.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			switch {
-			case strings.HasSuffix(r.URL.Path, "/suspend"):
-				svc.HandleSuspendTenant(w, r)
-			case strings.HasSuffix(r.URL.Path, "/activate"):
-				svc.HandleActivateTenant(w, r)
-			case strings.HasSuffix(r.URL.Path, "/api-keys"):
-				svc.HandleCreateAPIKey(w, r)
-			default:
-				http.NotFound(w, r)
-			}
-		default:
-			http.NotFound(w, r)
-		}
-	}
-}
-
-func dispatchTenantDELETE(svc *service.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodDelete || !strings.Contains(r.URL.Path, "/api-keys/") {
-			http.NotFound(w, r)
-			return
-		}
-		svc.HandleRevokeAPIKey(w, r)
-	}
-}
-
-func dispatchInternalQuotas(svc *service.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/quotas") {
-			http.NotFound(w, r)
-			return
-		}
-		svc.HandleGetQuotas(w, r)
-	}
-}
@@ -30,6 +30,7 @@ type Quotas struct {
 type Tenant struct {
 	ID               string         `json:"id" bson:"id"`
 	ExternalID       string         `json:"external_id" bson:"external_id"`
+	OrganizationID   string         `json:"organization_id,omitempty" bson:"organization_id,omitempty"`
 	Name             string         `json:"name" bson:"name"`
 	Type             TenantType     `json:"type" bson:"type"`
 	Status           TenantStatus   `json:"status" bson:"status"`
@@ -37,10 +38,118 @@ type Tenant struct {
 	BillingEmail     string         `json:"billing_email" bson:"billing_email"`
 	Quotas           Quotas         `json:"quotas" bson:"quotas"`
 	Metadata         map[string]any `json:"metadata" bson:"metadata"`
+	SSO              *OIDCConfig    `json:"sso,omitempty" bson:"sso,omitempty"`
 	CreatedAt        time.Time      `json:"created_at" bson:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at" bson:"updated_at"`
 	SuspendedAt      *time.Time     `json:"suspended_at,omitempty" bson:"suspended_at,omitempty"`
 	SuspensionReason *string        `json:"suspension_reason,omitempty" bson:"suspension_reason,omitempty"`
+	DeletedAt        *time.Time     `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// OIDCConfig federates the sign-in of a tenant's operators to an external
+// OpenID Connect issuer: its ID tokens for ClientID are exchanged for
+// tokens of the tenant user with the same subject or email, who gets the
+// highest role their groups map to.
+type OIDCConfig struct {
+	Issuer   string `json:"issuer" bson:"issuer"`
+	ClientID string `json:"client_id" bson:"client_id"`
+	// JWKSURL is the issuer's key set, discovered from the issuer when
+	// empty.
+	JWKSURL string `json:"jwks_url,omitempty" bson:"jwks_url,omitempty"`
+	// GroupsClaim names the ID token claim listing the user's groups,
+	// "groups" by default.
+	GroupsClaim string              `json:"groups_claim" bson:"groups_claim"`
+	GroupRoles  map[string]UserRole `json:"group_roles" bson:"group_roles"`
+	// DefaultRole is the role of users in none of the mapped groups, who
+	// are refused when it is empty.
+	DefaultRole UserRole  `json:"default_role,omitempty" bson:"default_role,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// TenantQuery selects tenants to list. Empty fields match every tenant.
+type TenantQuery struct {
+	Status         TenantStatus
+	Type           TenantType
+	OrganizationID string
+	Cursor         string
+	Limit          int
+}
+
+// Organization owns tenants, which are billed to it, and API keys that act
+// for any of them.
+type Organization struct {
+	ID           string         `json:"id" bson:"id"`
+	Name         string         `json:"name" bson:"name"`
+	BillingEmail string         `json:"billing_email" bson:"billing_email"`
+	Metadata     map[string]any `json:"metadata" bson:"metadata"`
+	CreatedAt    time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" bson:"updated_at"`
+}
+
+// OrgRole limits what an organization's API key may do for its tenants.
+type OrgRole string
+
+const (
+	// OrgRoleAdmin keys have their scopes in every tenant.
+	OrgRoleAdmin OrgRole = "ADMIN"
+	// OrgRoleViewer keys have only the read side of their scopes.
+	OrgRoleViewer OrgRole = "VIEWER"
+	// OrgRoleBilling keys may only read settlement.
+	OrgRoleBilling OrgRole = "BILLING"
+)
+
+// UserRole is what a tenant user may manage. Each role may do everything
+// the roles below it may.
+type UserRole string
+
+const (
+	// UserRoleOwner users may also delete, suspend and activate the tenant
+	// and manage other owners.
+	UserRoleOwner UserRole = "OWNER"
+	// UserRoleAdmin users manage the tenant's settings, users and API keys.
+	UserRoleAdmin UserRole = "ADMIN"
+	// UserRoleDeveloper users manage their own API keys, which may not
+	// deposit funds or administer the tenant.
+	UserRoleDeveloper UserRole = "DEVELOPER"
+	// UserRoleViewer users and their API keys may only read.
+	UserRoleViewer UserRole = "VIEWER"
+)
+
+type UserStatus string
+
+const (
+	UserStatusActive   UserStatus = "ACTIVE"
+	UserStatusDisabled UserStatus = "DISABLED"
+)
+
+// TenantUser is a person administering a tenant, with API keys of their
+// own that act within their role.
+type TenantUser struct {
+	ID       string     `json:"id" bson:"id"`
+	TenantID string     `json:"tenant_id" bson:"tenant_id"`
+	Email    string     `json:"email" bson:"email"`
+	Name     string     `json:"name" bson:"name"`
+	Role     UserRole   `json:"role" bson:"role"`
+	Status   UserStatus `json:"status" bson:"status"`
+	// SSOIssuer and SSOSubject identify users who sign in through the
+	// tenant's OIDC issuer.
+	SSOIssuer  string    `json:"sso_issuer,omitempty" bson:"sso_issuer,omitempty"`
+	SSOSubject string    `json:"sso_subject,omitempty" bson:"sso_subject,omitempty"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+type CreateUserRequest struct {
+	Email string   `json:"email"`
+	Name  string   `json:"name"`
+	Role  UserRole `json:"role"`
+}
+
+// UpdateUserRequest changes the fields it sets.
+type UpdateUserRequest struct {
+	Name   *string     `json:"name,omitempty"`
+	Role   *UserRole   `json:"role,omitempty"`
+	Status *UserStatus `json:"status,omitempty"`
 }
 
 type APIKeyStatus string
@@ -51,26 +160,44 @@ const (
 	APIKeyStatusExpired APIKeyStatus = "EXPIRED"
 )
 
+// APIKey belongs to a tenant, or, with OrganizationID and Role set and no
+// TenantID, to an organization. A tenant key with UserID belongs to that
+// user and acts within their role.
 type APIKey struct {
-	ID         string       `json:"id" bson:"id"`
-	TenantID   string       `json:"tenant_id" bson:"tenant_id"`
-	Name       string       `json:"name" bson:"name"`
-	KeyHash    string       `json:"-" bson:"key_hash"`
-	Prefix     string       `json:"prefix" bson:"prefix"`
-	Scopes     []string     `json:"scopes" bson:"scopes"`
-	Status     APIKeyStatus `json:"status" bson:"status"`
-	CreatedAt  time.Time    `json:"created_at" bson:"created_at"`
-	ExpiresAt  *time.Time   `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
-	LastUsedAt *time.Time   `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
-	RevokedAt  *time.Time   `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	ID             string       `json:"id" bson:"id"`
+	TenantID       string       `json:"tenant_id" bson:"tenant_id"`
+	UserID         string       `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	OrganizationID string       `json:"organization_id,omitempty" bson:"organization_id,omitempty"`
+	Role           OrgRole      `json:"role,omitempty" bson:"role,omitempty"`
+	Name           string       `json:"name" bson:"name"`
+	KeyHash        string       `json:"-" bson:"key_hash"`
+	Prefix         string       `json:"prefix" bson:"prefix"`
+	Scopes         []string     `json:"scopes" bson:"scopes"`
+	Status         APIKeyStatus `json:"status" bson:"status"`
+	CreatedAt      time.Time    `json:"created_at" bson:"created_at"`
+	ExpiresAt      *time.Time   `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	LastUsedAt     *time.Time   `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	RevokedAt      *time.Time   `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	// ExpiryNotifiedAt is when the apikey.expiring event was published for
+	// the key.
+	ExpiryNotifiedAt *time.Time `json:"expiry_notified_at,omitempty" bson:"expiry_notified_at,omitempty"`
+	// ReplacedBy is the key a rotation replaced this one with.
+	ReplacedBy string `json:"replaced_by,omitempty" bson:"replaced_by,omitempty"`
+	// AllowedCIDRs and AllowedRoutes restrict the addresses the key may be
+	// used from and the route prefixes, such as /v1/bids, it may call;
+	// empty means unrestricted.
+	AllowedCIDRs  []string `json:"allowed_cidrs,omitempty" bson:"allowed_cidrs,omitempty"`
+	AllowedRoutes []string `json:"allowed_routes,omitempty" bson:"allowed_routes,omitempty"`
 }
 
 type CreateTenantRequest struct {
-	Name         string         `json:"name"`
-	Type         TenantType     `json:"type"`
-	ContactEmail string         `json:"contact_email"`
-	BillingEmail string         `json:"billing_email"`
-	Metadata     map[string]any `json:"metadata"`
+	Name string `json:"name"`
+	// OrganizationID places the tenant in an organization, which bills it.
+	OrganizationID string         `json:"organization_id,omitempty"`
+	Type           TenantType     `json:"type"`
+	ContactEmail   string         `json:"contact_email"`
+	BillingEmail   string         `json:"billing_email"`
+	Metadata       map[string]any `json:"metadata"`
 }
 
 type CreateTenantResponse struct {
@@ -88,10 +215,26 @@ type CreateTenantResponse struct {
 	Quotas Quotas `json:"quotas"`
 }
 
+// UpdateTenantRequest changes the fields it sets. Metadata replaces the
+// tenant's metadata, and Quotas all of its quotas.
+type UpdateTenantRequest struct {
+	Name         *string        `json:"name,omitempty"`
+	ContactEmail *string        `json:"contact_email,omitempty"`
+	BillingEmail *string        `json:"billing_email,omitempty"`
+	Metadata     map[string]any `json:"metadata,omitempty"`
+	Quotas       *Quotas        `json:"quotas,omitempty"`
+}
+
 type CreateAPIKeyRequest struct {
 	Name      string     `json:"name"`
 	Scopes    []string   `json:"scopes"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Role is for organization keys, and defaults to ADMIN.
+	Role OrgRole `json:"role,omitempty"`
+	// AllowedCIDRs and AllowedRoutes restrict where the key may be used
+	// from and what it may call.
+	AllowedCIDRs  []string `json:"allowed_cidrs,omitempty"`
+	AllowedRoutes []string `json:"allowed_routes,omitempty"`
 }
 
 type CreateAPIKeyResponse struct {
@@ -100,18 +243,255 @@ type CreateAPIKeyResponse struct {
 	Key        string     `json:"key"`
 	Prefix     string     `json:"prefix"`
 	Scopes     []string   `json:"scopes"`
+	Role       OrgRole    `json:"role,omitempty"`
 	CreatedAt  time.Time  `json:"created_at"`
 	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+
+	AllowedCIDRs  []string `json:"allowed_cidrs,omitempty"`
+	AllowedRoutes []string `json:"allowed_routes,omitempty"`
+}
+
+// RotateAPIKeyRequest sets how long the old key keeps working and when the
+// new one expires; by default the service's rotation grace and the old
+// key's lifetime.
+type RotateAPIKeyRequest struct {
+	GraceSeconds *int       `json:"grace_seconds,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+type RotateAPIKeyResponse struct {
+	CreateAPIKeyResponse
+	Replaces             string    `json:"replaces"`
+	ReplacedKeyExpiresAt time.Time `json:"replaced_key_expires_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Name         string         `json:"name"`
+	BillingEmail string         `json:"billing_email"`
+	Metadata     map[string]any `json:"metadata"`
+}
+
+type CreateOrganizationResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	BillingEmail string    `json:"billing_email"`
+	CreatedAt    time.Time `json:"created_at"`
+	APIKey       struct {
+		ID     string  `json:"id"`
+		Key    string  `json:"key"`
+		Prefix string  `json:"prefix"`
+		Role   OrgRole `json:"role"`
+	} `json:"api_key"`
+}
+
+// UpdateOrganizationRequest changes the fields it sets. A new billing
+// email applies to every tenant of the organization.
+type UpdateOrganizationRequest struct {
+	Name         *string        `json:"name,omitempty"`
+	BillingEmail *string        `json:"billing_email,omitempty"`
+	Metadata     map[string]any `json:"metadata,omitempty"`
+}
+
+type AddOrganizationTenantRequest struct {
+	TenantID string `json:"tenant_id"`
 }
 
 type ValidateAPIKeyRequest struct {
 	APIKey string `json:"api_key"`
+	// TenantID is the tenant an organization key acts for.
+	TenantID string `json:"tenant_id,omitempty"`
+	// ClientIP and Route, when set, are checked against the key's
+	// restrictions: the caller's address and the path it calls.
+	ClientIP string `json:"client_ip,omitempty"`
+	Route    string `json:"route,omitempty"`
 }
 
 type ValidateAPIKeyResponse struct {
-	TenantID     string       `json:"tenant_id"`
-	TenantStatus TenantStatus `json:"tenant_status"`
-	Scopes       []string     `json:"scopes"`
-	Quotas       Quotas       `json:"quotas"`
+	// KeyID identifies the key, or the key a token was issued for.
+	KeyID          string       `json:"key_id,omitempty"`
+	TenantID       string       `json:"tenant_id"`
+	OrganizationID string       `json:"organization_id,omitempty"`
+	UserID         string       `json:"user_id,omitempty"`
+	UserRole       UserRole     `json:"user_role,omitempty"`
+	TenantStatus   TenantStatus `json:"tenant_status"`
+	Scopes         []string     `json:"scopes"`
+	Grants         []ScopeGrant `json:"grants"`
+	Quotas         Quotas       `json:"quotas"`
+	// AllowedCIDRs and AllowedRoutes are the key's restrictions, which
+	// callers enforce for requests they did not send with the validation.
+	AllowedCIDRs  []string `json:"allowed_cidrs,omitempty"`
+	AllowedRoutes []string `json:"allowed_routes,omitempty"`
+}
+
+// ValidateAPIKeysRequest validates several API keys or tokens at once.
+type ValidateAPIKeysRequest struct {
+	Keys []ValidateAPIKeyRequest `json:"keys"`
+}
+
+// ValidateAPIKeysResponse has a result for each key, in request order.
+type ValidateAPIKeysResponse struct {
+	Results []ValidateAPIKeyResult `json:"results"`
+}
+
+// ValidateAPIKeyResult is the validation of one key of a batch. Status and
+// Error are what validating it alone would have answered, unless it is
+// valid; MaxAgeSeconds is how long the result may be cached.
+type ValidateAPIKeyResult struct {
+	Valid         bool   `json:"valid"`
+	Status        int    `json:"status"`
+	Error         string `json:"error,omitempty"`
+	MaxAgeSeconds int    `json:"max_age_seconds"`
+	*ValidateAPIKeyResponse
+}
+
+// Scope describes a scope of the catalog API keys are granted scopes from.
+type Scope struct {
+	Name        string   `json:"name"`
+	Resource    string   `json:"resource"`
+	Actions     []string `json:"actions"`
+	Description string   `json:"description"`
+}
+
+// ScopeGrant is what a key's scopes allow on one resource. Resource "*"
+// means every resource, and action "*" every action.
+type ScopeGrant struct {
+	Resource string   `json:"resource"`
+	Actions  []string `json:"actions"`
+}
+
+type IssueTokenRequest struct {
+	APIKey string `json:"api_key"`
+	// TenantID is the tenant an organization key acts for, or whose
+	// operator signs in with IDToken.
+	TenantID string `json:"tenant_id,omitempty"`
+	// IDToken, an ID token of the tenant's OIDC issuer, is exchanged
+	// instead of an API key.
+	IDToken string `json:"id_token,omitempty"`
+}
+
+type IssueTokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Usage is what a tenant has used against its quotas: requests on Day, a
+// UTC date, and the tasks and agents it currently has.
+type Usage struct {
+	TenantID    string `json:"tenant_id"`
+	Day         string `json:"day"`
+	Requests    int64  `json:"requests"`
+	ActiveTasks int64  `json:"active_tasks"`
+	Agents      int64  `json:"agents"`
+}
+
+// UsageDelta is added to a tenant's usage. Tasks and Agents go down again
+// as tasks finish and agents are removed.
+type UsageDelta struct {
+	Requests int64 `json:"requests"`
+	Tasks    int64 `json:"tasks"`
+	Agents   int64 `json:"agents"`
+}
+
+// RecordUsageRequest adds to a tenant's usage. With Enforce, nothing is
+// added when any counter would pass its quota.
+type RecordUsageRequest struct {
+	UsageDelta
+	Enforce bool `json:"enforce"`
+}
+
+type UsageResponse struct {
+	Usage
+	Quotas Quotas `json:"quotas"`
+	// Exceeded names the quota an enforced increment would have passed.
+	Exceeded string `json:"exceeded,omitempty"`
+}
+
+type AuditAction string
+
+const (
+	AuditTenantCreated          AuditAction = "tenant.created"
+	AuditTenantSuspended        AuditAction = "tenant.suspended"
+	AuditTenantActivated        AuditAction = "tenant.activated"
+	AuditTenantDeleted          AuditAction = "tenant.deleted"
+	AuditAPIKeyCreated          AuditAction = "apikey.created"
+	AuditAPIKeyRevoked          AuditAction = "apikey.revoked"
+	AuditAPIKeyRotated          AuditAction = "apikey.rotated"
+	AuditAPIKeyValidationFailed AuditAction = "apikey.validation_failed"
+	AuditTenantSSOUpdated       AuditAction = "tenant.sso_updated"
+	AuditUserSSOLogin           AuditAction = "user.sso_login"
+	AuditUserSSOLoginFailed     AuditAction = "user.sso_login_failed"
+)
+
+// AuditEntry records an identity operation on a tenant. Entries are only
+// ever appended; IDs sort in the order entries were recorded.
+type AuditEntry struct {
+	ID       string      `json:"id" bson:"id"`
+	TenantID string      `json:"tenant_id" bson:"tenant_id"`
+	Action   AuditAction `json:"action" bson:"action"`
+	// ActorID is the user who made the request, when the gateway names one.
+	ActorID string `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	// TargetID is the API key the operation concerns.
+	TargetID  string         `json:"target_id,omitempty" bson:"target_id,omitempty"`
+	Details   map[string]any `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt time.Time      `json:"created_at" bson:"created_at"`
+}
+
+// AuditQuery selects a tenant's audit entries, newest first. Empty fields
+// match every entry.
+type AuditQuery struct {
+	TenantID string
+	Action   AuditAction
+	ActorID  string
+	TargetID string
+	Since    time.Time
+	Until    time.Time
+	Cursor   string
+	Limit    int
+}
+
+// ServiceAccount is an internal service of the exchange. It exchanges its
+// secret for short-lived tokens to call the internal endpoints of the
+// services in Audiences.
+type ServiceAccount struct {
+	ID          string `json:"id" bson:"id"`
+	Name        string `json:"name" bson:"name"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	// Audiences are the services, by name, it may get tokens for.
+	Audiences    []string             `json:"audiences" bson:"audiences"`
+	SecretHash   string               `json:"-" bson:"secret_hash"`
+	SecretPrefix string               `json:"secret_prefix" bson:"secret_prefix"`
+	Status       ServiceAccountStatus `json:"status" bson:"status"`
+	CreatedAt    time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at" bson:"updated_at"`
+	LastUsedAt   *time.Time           `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
+
+type ServiceAccountStatus string
+
+const (
+	ServiceAccountStatusActive   ServiceAccountStatus = "ACTIVE"
+	ServiceAccountStatusDisabled ServiceAccountStatus = "DISABLED"
+)
+
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Audiences   []string `json:"audiences"`
+}
+
+// ServiceAccountSecretResponse returns a service account with its secret,
+// which is only shown when it is created or rotated.
+type ServiceAccountSecretResponse struct {
+	ServiceAccount
+	ClientSecret string `json:"client_secret"`
+}
+
+type ServiceTokenRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// Audience is the service the token is for.
+	Audience string `json:"audience"`
 }
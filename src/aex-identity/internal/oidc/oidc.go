@@ -0,0 +1,293 @@
+// Package oidc verifies the ID tokens of external OpenID Connect issuers,
+// which tenants federate their operators' sign-in to. An issuer's keys are
+// found through OpenID Connect discovery, unless configured, and cached
+// until a token names a key they do not include.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalid means an ID token is malformed, not signed by its issuer's
+// keys, for another issuer or client, or expired.
+var ErrInvalid = errors.New("invalid id token")
+
+const (
+	// keysRefreshInterval bounds how often tokens naming an unknown key
+	// make the verifier fetch an issuer's keys again.
+	keysRefreshInterval = time.Minute
+	// clockSkew is how far an issuer's clock may be off.
+	clockSkew = time.Minute
+)
+
+// Provider is an issuer and the client its ID tokens must be issued to.
+type Provider struct {
+	Issuer   string
+	ClientID string
+	// JWKSURL is the issuer's key set; it is discovered when empty.
+	JWKSURL string
+}
+
+// IDToken is the verified content of an ID token.
+type IDToken struct {
+	Subject string
+	Email   string
+	Name    string
+	// Claims are all of the token's claims, for those issuers name as they
+	// like, such as groups.
+	Claims map[string]any
+}
+
+// Verifier verifies ID tokens of any number of issuers.
+type Verifier struct {
+	client *http.Client
+
+	mu       sync.Mutex
+	jwksURLs map[string]string  // issuer -> discovered key set URL
+	keySets  map[string]*keySet // key set URL -> keys
+}
+
+type keySet struct {
+	keys      map[string]crypto.PublicKey // kid -> key
+	fetchedAt time.Time
+}
+
+func NewVerifier(client *http.Client) *Verifier {
+	return &Verifier{
+		client:   client,
+		jwksURLs: make(map[string]string),
+		keySets:  make(map[string]*keySet),
+	}
+}
+
+// Verify checks the signature, issuer, audience and validity period of an
+// RS256 or ES256 ID token of p and returns its content. Errors other than
+// ErrInvalid mean the issuer could not be reached.
+func (v *Verifier) Verify(ctx context.Context, p Provider, raw string) (*IDToken, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	key, err := v.key(ctx, p, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(header.Alg, key, digest[:], sig) {
+		return nil, ErrInvalid
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalid
+	}
+	if iss, _ := claims["iss"].(string); iss != p.Issuer || !hasAudience(claims["aud"], p.ClientID) {
+		return nil, ErrInvalid
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-clockSkew).Unix() >= int64(exp) {
+		return nil, ErrInvalid
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Unix() < int64(nbf) {
+		return nil, ErrInvalid
+	}
+	id := &IDToken{Claims: claims}
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+	id.Name, _ = claims["name"].(string)
+	if id.Subject == "" {
+		return nil, ErrInvalid
+	}
+	return id, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest, sig []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		return alg == "ES256" && len(sig) == 64 &&
+			ecdsa.Verify(k, digest, new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	}
+	return false
+}
+
+// hasAudience reports whether an aud claim, a string or a list of them,
+// names clientID.
+func hasAudience(aud any, clientID string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == clientID
+	case []any:
+		for _, s := range a {
+			if s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key returns the issuer's key named kid, or its only key when the token
+// names none, fetching the key set when the key is unknown.
+func (v *Verifier) key(ctx context.Context, p Provider, kid string) (crypto.PublicKey, error) {
+	jwksURL, err := v.jwksURL(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	set := v.keySets[jwksURL]
+	if set != nil {
+		if k := set.lookup(kid); k != nil {
+			return k, nil
+		}
+		if time.Since(set.fetchedAt) < keysRefreshInterval {
+			return nil, ErrInvalid
+		}
+	}
+	keys, err := v.fetchKeys(ctx, jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	set = &keySet{keys: keys, fetchedAt: time.Now()}
+	v.keySets[jwksURL] = set
+	if k := set.lookup(kid); k != nil {
+		return k, nil
+	}
+	return nil, ErrInvalid
+}
+
+func (s *keySet) lookup(kid string) crypto.PublicKey {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k
+		}
+	}
+	return s.keys[kid]
+}
+
+// jwksURL returns the configured key set URL of p, or the one its discovery
+// document names.
+func (v *Verifier) jwksURL(ctx context.Context, p Provider) (string, error) {
+	if p.JWKSURL != "" {
+		return p.JWKSURL, nil
+	}
+	v.mu.Lock()
+	u, ok := v.jwksURLs[p.Issuer]
+	v.mu.Unlock()
+	if ok {
+		return u, nil
+	}
+
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.get(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return "", err
+	}
+	if doc.Issuer != p.Issuer || doc.JWKSURI == "" {
+		return "", fmt.Errorf("discovery document of %s names issuer %q and key set %q", p.Issuer, doc.Issuer, doc.JWKSURI)
+	}
+	v.mu.Lock()
+	v.jwksURLs[p.Issuer] = doc.JWKSURI
+	v.mu.Unlock()
+	return doc.JWKSURI, nil
+}
+
+// fetchKeys reads the RSA and P-256 signing keys of a key set.
+func (v *Verifier) fetchKeys(ctx context.Context, jwksURL string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := v.get(ctx, jwksURL, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch {
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+				continue
+			}
+			keys[k.Kid] = pub
+		}
+	}
+	return keys, nil
+}
+
+func (v *Verifier) get(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/events"
+)
+
+// identityEventTypes are published to the shared event bus so key owners
+// can be reminded to rotate keys before they stop working, and tenant and
+// key changes are on record.
+var identityEventTypes = []string{
+	events.EventAPIKeyExpiring,
+	events.EventAPIKeyRotated,
+	events.EventAPIKeyRevoked,
+	events.EventTenantSuspended,
+	events.EventTenantActivated,
+}
+
+// lifecycleEventTypes end access the services validating credentials may
+// still have cached, or restore it.
+var lifecycleEventTypes = []string{
+	events.EventTenantSuspended,
+	events.EventTenantActivated,
+	events.EventAPIKeyRevoked,
+}
+
+// publish sends an event in the background; delivery failures are logged by
+// the publisher and never fail the request that caused them.
+func (s *Service) publish(tenantID, eventType string, data map[string]any) {
+	data["tenant_id"] = tenantID
+	go func() {
+		if err := s.events.Publish(context.Background(), eventType, data); err != nil {
+			log.Printf("event publish failed type=%s tenant_id=%s: %v", eventType, tenantID, err)
+		}
+	}()
+}
+
+func (s *Service) publishKeyExpiring(k model.APIKey) {
+	data := map[string]any{
+		"key_id":     k.ID,
+		"prefix":     k.Prefix,
+		"expires_at": k.ExpiresAt,
+	}
+	if k.UserID != "" {
+		data["user_id"] = k.UserID
+	}
+	if k.OrganizationID != "" {
+		data["organization_id"] = k.OrganizationID
+	}
+	s.publish(k.TenantID, events.EventAPIKeyExpiring, data)
+}
+
+func (s *Service) publishKeyRotated(old, replacement model.APIKey) {
+	s.publish(old.TenantID, events.EventAPIKeyRotated, map[string]any{
+		"key_id":             old.ID,
+		"new_key_id":         replacement.ID,
+		"old_key_expires_at": old.ExpiresAt,
+	})
+}
+
+func (s *Service) publishTenantSuspended(t model.Tenant) {
+	data := map[string]any{"suspended_at": t.SuspendedAt}
+	if t.SuspensionReason != nil {
+		data["reason"] = *t.SuspensionReason
+	}
+	s.publish(t.ID, events.EventTenantSuspended, data)
+}
+
+func (s *Service) publishTenantActivated(t model.Tenant) {
+	s.publish(t.ID, events.EventTenantActivated, map[string]any{"activated_at": t.UpdatedAt})
+}
+
+// publishKeyRevoked reports a revoked key; reason says why, unless it was
+// revoked on its own.
+func (s *Service) publishKeyRevoked(k model.APIKey, reason string) {
+	data := map[string]any{
+		"key_id":     k.ID,
+		"prefix":     k.Prefix,
+		"revoked_at": k.RevokedAt,
+	}
+	if k.UserID != "" {
+		data["user_id"] = k.UserID
+	}
+	if k.OrganizationID != "" {
+		data["organization_id"] = k.OrganizationID
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	s.publish(k.TenantID, events.EventAPIKeyRevoked, data)
+}
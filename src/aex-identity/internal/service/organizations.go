@@ -0,0 +1,401 @@
+package service
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+)
+
+// HandleCreateOrganization creates an organization with an ADMIN API key
+// for its tenants.
+func (s *Service) HandleCreateOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req model.CreateOrganizationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	now := time.Now().UTC()
+	o := model.Organization{
+		ID:           generateID("org_"),
+		Name:         strings.TrimSpace(req.Name),
+		BillingEmail: strings.TrimSpace(req.BillingEmail),
+		Metadata:     req.Metadata,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if o.Metadata == nil {
+		o.Metadata = map[string]any{}
+	}
+	if err := s.store.CreateOrganization(ctx, o); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	k, plain := newAPIKey("default", []string{"*"}, nil)
+	k.OrganizationID = o.ID
+	k.Role = model.OrgRoleAdmin
+	if err := s.store.CreateAPIKey(ctx, k); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	var resp model.CreateOrganizationResponse
+	resp.ID = o.ID
+	resp.Name = o.Name
+	resp.BillingEmail = o.BillingEmail
+	resp.CreatedAt = o.CreatedAt
+	resp.APIKey.ID = k.ID
+	resp.APIKey.Key = plain
+	resp.APIKey.Prefix = k.Prefix
+	resp.APIKey.Role = k.Role
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Service) HandleGetOrganization(w http.ResponseWriter, r *http.Request) {
+	if !s.requireOrgAdmin(w, r, r.PathValue("org_id")) {
+		return
+	}
+	o, ok := s.organization(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// HandleUpdateOrganization changes an organization's name, billing email
+// or metadata. A new billing email is copied to all of its tenants.
+func (s *Service) HandleUpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	if !s.requireOrgAdmin(w, r, r.PathValue("org_id")) {
+		return
+	}
+	ctx := r.Context()
+	var req model.UpdateOrganizationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		http.Error(w, "name must not be empty", http.StatusBadRequest)
+		return
+	}
+	o, ok := s.organization(w, r)
+	if !ok {
+		return
+	}
+	if req.Name != nil {
+		o.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.BillingEmail != nil {
+		o.BillingEmail = strings.TrimSpace(*req.BillingEmail)
+	}
+	if req.Metadata != nil {
+		o.Metadata = req.Metadata
+	}
+	o.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateOrganization(ctx, *o); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if req.BillingEmail != nil {
+		tenants, err := s.organizationTenants(r, o.ID)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		for _, t := range tenants {
+			if t.DeletedAt != nil || t.BillingEmail == o.BillingEmail {
+				continue
+			}
+			t.BillingEmail = o.BillingEmail
+			t.UpdatedAt = o.UpdatedAt
+			if err := s.store.UpdateTenant(ctx, t); err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// HandleListOrganizationTenants lists the tenants of an organization.
+func (s *Service) HandleListOrganizationTenants(w http.ResponseWriter, r *http.Request) {
+	if !s.requireOrgAdmin(w, r, r.PathValue("org_id")) {
+		return
+	}
+	o, ok := s.organization(w, r)
+	if !ok {
+		return
+	}
+	tenants, err := s.organizationTenants(r, o.ID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tenants": tenants, "total": len(tenants)})
+}
+
+// HandleAddOrganizationTenant moves a tenant into an organization, which
+// bills it from then on. Its owner must ask, with an organization admin key.
+func (s *Service) HandleAddOrganizationTenant(w http.ResponseWriter, r *http.Request) {
+	if !s.requireOrgAdmin(w, r, r.PathValue("org_id")) {
+		return
+	}
+	ctx := r.Context()
+	var req model.AddOrganizationTenantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	tenantID := strings.TrimSpace(req.TenantID)
+	if !s.trustedCaller(r) && (r.Header.Get(tenantHeader) != tenantID || model.UserRole(r.Header.Get(userRoleHeader)) != model.UserRoleOwner) {
+		http.Error(w, "only the tenant's owner may add it to an organization", http.StatusForbidden)
+		return
+	}
+	o, ok := s.organization(w, r)
+	if !ok {
+		return
+	}
+	t, ok := s.liveTenant(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if t.OrganizationID != "" && t.OrganizationID != o.ID {
+		http.Error(w, "tenant belongs to another organization", http.StatusConflict)
+		return
+	}
+	t.OrganizationID = o.ID
+	t.BillingEmail = o.BillingEmail
+	t.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateTenant(ctx, *t); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("tenant added to organization org_id=%s tenant_id=%s", o.ID, t.ID)
+	writeJSON(w, http.StatusOK, t)
+}
+
+// HandleRemoveOrganizationTenant takes a tenant out of its organization.
+// The tenant keeps the organization's billing email until it changes it.
+func (s *Service) HandleRemoveOrganizationTenant(w http.ResponseWriter, r *http.Request) {
+	if !s.requireOrgAdmin(w, r, r.PathValue("org_id")) {
+		return
+	}
+	ctx := r.Context()
+	o, ok := s.organization(w, r)
+	if !ok {
+		return
+	}
+	t, err := s.store.GetTenant(ctx, r.PathValue("tenant_id"))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if t == nil || t.OrganizationID != o.ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	t.OrganizationID = ""
+	t.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateTenant(ctx, *t); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("tenant removed from organization org_id=%s tenant_id=%s", o.ID, t.ID)
+	writeJSON(w, http.StatusOK, t)
+}
+
+// HandleCreateOrganizationAPIKey creates an API key that acts for any
+// tenant of the organization, within its role.
+func (s *Service) HandleCreateOrganizationAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireOrgAdmin(w, r, r.PathValue("org_id")) {
+		return
+	}
+	ctx := r.Context()
+	var req model.CreateAPIKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	role := model.OrgRole(strings.ToUpper(strings.TrimSpace(string(req.Role))))
+	switch role {
+	case "":
+		role = model.OrgRoleAdmin
+	case model.OrgRoleAdmin, model.OrgRoleViewer, model.OrgRoleBilling:
+	default:
+		http.Error(w, "role must be ADMIN, VIEWER or BILLING", http.StatusBadRequest)
+		return
+	}
+	scopes := normalizeScopes(req.Scopes)
+	if err := validateScopes(scopes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cidrs, routes, err := normalizeRestrictions(req.AllowedCIDRs, req.AllowedRoutes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	o, ok := s.organization(w, r)
+	if !ok {
+		return
+	}
+
+	k, plain := newAPIKey(req.Name, scopes, req.ExpiresAt)
+	k.AllowedCIDRs, k.AllowedRoutes = cidrs, routes
+	k.OrganizationID = o.ID
+	k.Role = role
+	if err := s.store.CreateAPIKey(ctx, k); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, model.CreateAPIKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		Key:       plain,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		Role:      k.Role,
+		CreatedAt: k.CreatedAt,
+		ExpiresAt: k.ExpiresAt,
+
+		AllowedCIDRs:  k.AllowedCIDRs,
+		AllowedRoutes: k.AllowedRoutes,
+	})
+}
+
+func (s *Service) HandleListOrganizationAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !s.requireOrgAdmin(w, r, r.PathValue("org_id")) {
+		return
+	}
+	o, ok := s.organization(w, r)
+	if !ok {
+		return
+	}
+	keys, err := s.store.ListOrganizationAPIKeys(r.Context(), o.ID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (s *Service) HandleRevokeOrganizationAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireOrgAdmin(w, r, r.PathValue("org_id")) {
+		return
+	}
+	ctx := r.Context()
+	orgID := r.PathValue("org_id")
+	keyID := r.PathValue("key_id")
+	k, err := s.store.GetOrganizationAPIKey(ctx, orgID, keyID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if k == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now().UTC()
+	k.Status = model.APIKeyStatusRevoked
+	k.RevokedAt = &now
+	if err := s.store.UpdateAPIKey(ctx, *k); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.publishKeyRevoked(*k, "")
+	writeJSON(w, http.StatusOK, map[string]any{"revoked": true, "id": k.ID})
+}
+
+// requireOrgAdmin answers 403 and reports false unless the request may
+// manage organization orgID: it carries an active ADMIN
+// key of the organization, in X-API-Key or as a Bearer token, or comes from
+// the owner of one of its tenants, or from a trusted caller.
+func (s *Service) requireOrgAdmin(w http.ResponseWriter, r *http.Request, orgID string) bool {
+	if s.trustedCaller(r) {
+		return true
+	}
+	ctx := r.Context()
+	apiKey := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if apiKey == "" {
+		apiKey, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		apiKey = strings.TrimSpace(apiKey)
+	}
+	if apiKey != "" {
+		k, err := s.store.FindAPIKeyByHash(ctx, hashAPIKey(apiKey))
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return false
+		}
+		if k != nil && k.TenantID == "" && k.OrganizationID == orgID && k.Role == model.OrgRoleAdmin &&
+			k.Status == model.APIKeyStatusActive && (k.ExpiresAt == nil || time.Now().UTC().Before(*k.ExpiresAt)) {
+			return true
+		}
+	}
+	if tenantID := r.Header.Get(tenantHeader); tenantID != "" && model.UserRole(r.Header.Get(userRoleHeader)) == model.UserRoleOwner {
+		t, err := s.store.GetTenant(ctx, tenantID)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return false
+		}
+		if t != nil && t.OrganizationID == orgID {
+			return true
+		}
+	}
+	http.Error(w, "requires an organization admin", http.StatusForbidden)
+	return false
+}
+
+// organization looks up the organization named by the request path,
+// answering 400 or 404 otherwise.
+func (s *Service) organization(w http.ResponseWriter, r *http.Request) (*model.Organization, bool) {
+	orgID := r.PathValue("org_id")
+	o, err := s.store.GetOrganization(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if o == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	return o, true
+}
+
+// organizationTenants pages through every tenant of an organization.
+func (s *Service) organizationTenants(r *http.Request, orgID string) ([]model.Tenant, error) {
+	var all []model.Tenant
+	q := model.TenantQuery{OrganizationID: orgID, Limit: maxListLimit}
+	for {
+		tenants, next, err := s.store.QueryTenants(r.Context(), q)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, tenants...)
+		if next == "" {
+			return all, nil
+		}
+		q.Cursor = next
+	}
+}
+
+// roleScopes narrows an organization key's scopes to what its role
+// allows: all of them for ADMIN, their read side for VIEWER, and reading
+// settlement for BILLING.
+func roleScopes(role model.OrgRole, scopes []string) []string {
+	switch role {
+	case model.OrgRoleBilling:
+		return []string{"settlement:read"}
+	case model.OrgRoleViewer:
+		return readScopes(scopes)
+	default:
+		return scopes
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Reasons restrictionViolation gives, which validation answers and audits.
+const (
+	reasonAddressNotAllowed = "address not allowed"
+	reasonRouteNotAllowed   = "route not allowed"
+)
+
+// normalizeRestrictions checks the CIDR ranges and route prefixes an API key
+// is restricted to and returns them in canonical form, without duplicates.
+// A bare address is a range of one address.
+func normalizeRestrictions(cidrs, routes []string) ([]string, []string, error) {
+	var outCIDRs, outRoutes []string
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(c)
+			if addrErr != nil {
+				return nil, nil, fmt.Errorf("allowed_cidrs: %q is not a CIDR range or address", c)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if s := prefix.Masked().String(); !slices.Contains(outCIDRs, s) {
+			outCIDRs = append(outCIDRs, s)
+		}
+	}
+	for _, r := range routes {
+		r = strings.TrimSpace(r)
+		if !strings.HasPrefix(r, "/") || strings.ContainsAny(r, "?#* ") {
+			return nil, nil, fmt.Errorf("allowed_routes: %q is not a path prefix such as /v1/bids", r)
+		}
+		if r != "/" {
+			r = strings.TrimRight(r, "/")
+		}
+		if !slices.Contains(outRoutes, r) {
+			outRoutes = append(outRoutes, r)
+		}
+	}
+	return outCIDRs, outRoutes, nil
+}
+
+// restrictionViolation returns why a key restricted to cidrs and routes may
+// not be used from clientIP for route, or "" when it may. An empty clientIP
+// or route is not checked; callers that do not send them get the
+// restrictions with the validation and enforce them themselves.
+func restrictionViolation(cidrs, routes []string, clientIP, route string) string {
+	if clientIP != "" && len(cidrs) > 0 {
+		addr, err := netip.ParseAddr(clientIP)
+		if err != nil || !slices.ContainsFunc(cidrs, func(c string) bool {
+			p, err := netip.ParsePrefix(c)
+			return err == nil && p.Contains(addr.Unmap())
+		}) {
+			return reasonAddressNotAllowed
+		}
+	}
+	if route != "" && len(routes) > 0 && !slices.ContainsFunc(routes, func(p string) bool {
+		return p == "/" || route == p || strings.HasPrefix(route, p+"/")
+	}) {
+		return reasonRouteNotAllowed
+	}
+	return ""
+}
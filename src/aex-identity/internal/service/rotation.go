@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+)
+
+// HandleRotateAPIKey replaces a tenant API key with a new one with the same
+// name, scopes, user and lifetime. The old key keeps working for
+// grace_seconds, the service's rotation grace by default, so clients can
+// switch over; a grace of 0 revokes it at once.
+func (s *Service) HandleRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	keyID := r.PathValue("key_id")
+	var req model.RotateAPIKeyRequest
+	if err := decodeOptionalJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	grace := s.rotationGrace
+	if req.GraceSeconds != nil {
+		if *req.GraceSeconds < 0 {
+			http.Error(w, "grace_seconds must not be negative", http.StatusBadRequest)
+			return
+		}
+		grace = time.Duration(*req.GraceSeconds) * time.Second
+	}
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := s.liveTenant(w, r, tenantID); !ok {
+		return
+	}
+	k, err := s.store.GetAPIKey(ctx, tenantID, keyID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if k == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !s.authorizeUser(w, r, tenantID, k.UserID, model.UserRoleAdmin) {
+		return
+	}
+	if k.Status != model.APIKeyStatusActive || (k.ExpiresAt != nil && !k.ExpiresAt.After(now)) {
+		http.Error(w, "api key is not active", http.StatusConflict)
+		return
+	}
+	if k.ReplacedBy != "" {
+		http.Error(w, "api key already rotated", http.StatusConflict)
+		return
+	}
+
+	expiresAt := req.ExpiresAt
+	if expiresAt == nil && k.ExpiresAt != nil {
+		e := now.Add(k.ExpiresAt.Sub(k.CreatedAt))
+		expiresAt = &e
+	}
+	nk, plain := newAPIKey(k.Name, k.Scopes, expiresAt)
+	nk.TenantID = k.TenantID
+	nk.UserID = k.UserID
+	nk.AllowedCIDRs, nk.AllowedRoutes = k.AllowedCIDRs, k.AllowedRoutes
+	if err := s.store.CreateAPIKey(ctx, nk); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	oldExpiresAt := now.Add(grace)
+	if k.ExpiresAt != nil && k.ExpiresAt.Before(oldExpiresAt) {
+		oldExpiresAt = *k.ExpiresAt
+	}
+	k.ExpiresAt = &oldExpiresAt
+	k.ReplacedBy = nk.ID
+	if grace == 0 {
+		k.Status = model.APIKeyStatusRevoked
+		k.RevokedAt = &now
+	}
+	if err := s.store.UpdateAPIKey(ctx, *k); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.auditKeyCreated(r, nk)
+	s.audit(r, tenantID, model.AuditAPIKeyRotated, k.ID, map[string]any{
+		"new_key_id":         nk.ID,
+		"old_key_expires_at": oldExpiresAt,
+	})
+	s.publishKeyRotated(*k, nk)
+	if k.Status == model.APIKeyStatusRevoked {
+		s.publishKeyRevoked(*k, "rotated")
+	}
+	log.Printf("api key rotated tenant_id=%s key_id=%s new_key_id=%s old_key_expires_at=%s",
+		tenantID, k.ID, nk.ID, oldExpiresAt.Format(time.RFC3339))
+
+	writeJSON(w, http.StatusCreated, model.RotateAPIKeyResponse{
+		CreateAPIKeyResponse: model.CreateAPIKeyResponse{
+			ID:        nk.ID,
+			Name:      nk.Name,
+			Key:       plain,
+			Prefix:    nk.Prefix,
+			Scopes:    nk.Scopes,
+			CreatedAt: nk.CreatedAt,
+			ExpiresAt: nk.ExpiresAt,
+
+			AllowedCIDRs:  nk.AllowedCIDRs,
+			AllowedRoutes: nk.AllowedRoutes,
+		},
+		Replaces:             k.ID,
+		ReplacedKeyExpiresAt: oldExpiresAt,
+	})
+}
+
+// RunKeyExpiryMonitor checks API keys every interval until ctx is done:
+// keys past their expiry are marked EXPIRED, and an apikey.expiring event
+// is published once for each key that expires within warning and has not
+// been rotated.
+func (s *Service) RunKeyExpiryMonitor(ctx context.Context, interval, warning time.Duration) {
+	if interval <= 0 || warning <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.checkKeyExpiry(ctx, time.Now().UTC(), warning)
+		}
+	}
+}
+
+func (s *Service) checkKeyExpiry(ctx context.Context, now time.Time, warning time.Duration) {
+	keys, err := s.store.ListAPIKeysExpiringBefore(ctx, now.Add(warning))
+	if err != nil {
+		log.Printf("api key expiry check failed: %v", err)
+		return
+	}
+	for _, k := range keys {
+		switch {
+		case !k.ExpiresAt.After(now):
+			k.Status = model.APIKeyStatusExpired
+			if err := s.store.UpdateAPIKey(ctx, k); err != nil {
+				log.Printf("failed to expire api key key_id=%s: %v", k.ID, err)
+			}
+		case k.ExpiryNotifiedAt == nil && k.ReplacedBy == "":
+			k.ExpiryNotifiedAt = &now
+			if err := s.store.UpdateAPIKey(ctx, k); err != nil {
+				log.Printf("failed to mark api key expiry notified key_id=%s: %v", k.ID, err)
+				continue
+			}
+			s.publishKeyExpiring(k)
+		}
+	}
+}
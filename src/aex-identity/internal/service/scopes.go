@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+)
+
+// scopeCatalog lists the scopes API keys can be granted. Besides these, a
+// key may hold "*" or "<resource>:*" for all actions on a resource.
+var scopeCatalog = []model.Scope{
+	{Name: "work:read", Resource: "work", Actions: []string{"read"}, Description: "Read published work"},
+	{Name: "work:write", Resource: "work", Actions: []string{"read", "write"}, Description: "Publish and cancel work"},
+	{Name: "bids:read", Resource: "bids", Actions: []string{"read"}, Description: "Read bids"},
+	{Name: "bids:write", Resource: "bids", Actions: []string{"read", "write"}, Description: "Submit and withdraw bids"},
+	{Name: "contracts:read", Resource: "contracts", Actions: []string{"read"}, Description: "Read contracts"},
+	{Name: "contracts:manage", Resource: "contracts", Actions: []string{"read", "write"}, Description: "Award, amend, complete and cancel contracts"},
+	{Name: "providers:read", Resource: "providers", Actions: []string{"read"}, Description: "Read providers, subscriptions and capabilities"},
+	{Name: "providers:write", Resource: "providers", Actions: []string{"read", "write"}, Description: "Register providers and manage subscriptions"},
+	{Name: "settlement:read", Resource: "settlement", Actions: []string{"read"}, Description: "Read usage, transactions and the balance"},
+	{Name: "settlement:write", Resource: "settlement", Actions: []string{"read", "write"}, Description: "Deposit funds"},
+	{Name: "admin:*", Resource: "*", Actions: []string{"*"}, Description: "Everything, including tenant administration"},
+}
+
+// HandleListScopes serves the scope catalog.
+func (s *Service) HandleListScopes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, scopeCatalog)
+}
+
+func catalogScope(name string) (model.Scope, bool) {
+	for _, sc := range scopeCatalog {
+		if sc.Name == name {
+			return sc, true
+		}
+	}
+	return model.Scope{}, false
+}
+
+// validateScopes checks that every scope is in the catalog, "*", or
+// "<resource>:*" for a resource of the catalog.
+func validateScopes(scopes []string) error {
+	for _, name := range scopes {
+		if _, ok := catalogScope(name); ok || name == "*" {
+			continue
+		}
+		resource, action, ok := strings.Cut(name, ":")
+		if ok && action == "*" && slices.ContainsFunc(scopeCatalog, func(sc model.Scope) bool { return sc.Resource == resource }) {
+			continue
+		}
+		return fmt.Errorf("unknown scope %q", name)
+	}
+	return nil
+}
+
+// readScopes returns the read side of scopes: "<resource>:read" for each
+// resource they cover, and "*:read" for "*" and "admin:*".
+func readScopes(scopes []string) []string {
+	out := make([]string, 0, len(scopes))
+	for _, name := range scopes {
+		resource, _, _ := strings.Cut(name, ":")
+		if name == "*" || name == "admin:*" {
+			resource = "*"
+		}
+		out = appendScope(out, resource+":read")
+	}
+	return out
+}
+
+// appendScope appends name to scopes unless it is there already.
+func appendScope(scopes []string, name string) []string {
+	if slices.Contains(scopes, name) {
+		return scopes
+	}
+	return append(scopes, name)
+}
+
+// scopeGrants resolves scopes into the actions they allow, by resource.
+// Scopes outside the catalog, held by keys created before it, are read as
+// "<resource>:<action>", or as an action on every resource.
+func scopeGrants(scopes []string) []model.ScopeGrant {
+	var grants []model.ScopeGrant
+	add := func(resource string, actions ...string) {
+		i := slices.IndexFunc(grants, func(g model.ScopeGrant) bool { return g.Resource == resource })
+		if i < 0 {
+			grants = append(grants, model.ScopeGrant{Resource: resource})
+			i = len(grants) - 1
+		}
+		for _, a := range actions {
+			if !slices.Contains(grants[i].Actions, a) {
+				grants[i].Actions = append(grants[i].Actions, a)
+			}
+		}
+	}
+	for _, name := range scopes {
+		if sc, ok := catalogScope(name); ok {
+			add(sc.Resource, sc.Actions...)
+			continue
+		}
+		if name == "*" {
+			add("*", "*")
+			continue
+		}
+		if resource, action, ok := strings.Cut(name, ":"); ok {
+			add(resource, action)
+		} else {
+			add("*", name)
+		}
+	}
+	slices.SortFunc(grants, func(a, b model.ScopeGrant) int { return strings.Compare(a.Resource, b.Resource) })
+	return grants
+}
@@ -1,25 +1,107 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/oidc"
 	"github.com/parlakisik/agent-exchange/aex-identity/internal/store"
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/token"
+	"github.com/parlakisik/agent-exchange/internal/events"
+)
+
+// defaultTokenTTL is the lifetime of tokens issued with the generated
+// signing key New starts with.
+const defaultTokenTTL = 15 * time.Minute
+
+// defaultRotationGrace is how long a rotated API key keeps working unless
+// SetRotationGrace or the request says otherwise.
+const defaultRotationGrace = 24 * time.Hour
+
+var (
+	// errUnauthorized means an API key or token is unknown, revoked,
+	// expired or belongs to an inactive tenant, or an organization key was
+	// used for a tenant outside its organization.
+	errUnauthorized = errors.New("unauthorized")
+	// errTenantRequired means an organization key was used without naming
+	// the tenant it acts for.
+	errTenantRequired = errors.New("tenant_id is required for organization keys")
 )
 
 type Service struct {
-	store store.Store
+	store         store.Store
+	tokens        *token.Signer
+	events        *events.Publisher
+	oidc          *oidc.Verifier
+	rotationGrace time.Duration
+	adminToken    string
+
+	validationMaxAge time.Duration
+	validations      *validationLimiter
 }
 
 func New(st store.Store) *Service {
-	return &Service{store: st}
+	key, err := token.LoadKey("")
+	if err != nil {
+		log.Fatalf("generating token signing key: %v", err)
+	}
+	return &Service{
+		store:         st,
+		tokens:        token.NewSigner(key, "aex-identity", defaultTokenTTL),
+		events:        events.NewPublisher("aex-identity"),
+		oidc:          oidc.NewVerifier(&http.Client{Timeout: 5 * time.Second}),
+		rotationGrace: defaultRotationGrace,
+
+		validationMaxAge: defaultValidationMaxAge,
+	}
+}
+
+// SetTokenSigner replaces the signer of the tokens POST /v1/token issues.
+func (s *Service) SetTokenSigner(signer *token.Signer) {
+	s.tokens = signer
+}
+
+// SetEventsURL sends the service's events to url.
+func (s *Service) SetEventsURL(url string) {
+	for _, typ := range identityEventTypes {
+		s.events.AddEndpoint(typ, url)
+	}
+}
+
+// SetLifecycleWebhooks sends tenant suspensions and activations and API key
+// revocations to each of urls too, and signs events with secret.
+func (s *Service) SetLifecycleWebhooks(urls []string, secret string) {
+	if secret != "" {
+		s.events.SetSecret(secret)
+	}
+	for _, url := range urls {
+		for _, typ := range lifecycleEventTypes {
+			s.events.AddEndpoint(typ, url)
+		}
+	}
+}
+
+// SetAdminToken requires token as a Bearer token on the /admin routes,
+// which refuse every request until it is set.
+func (s *Service) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetRotationGrace sets how long a rotated API key keeps working by
+// default.
+func (s *Service) SetRotationGrace(d time.Duration) {
+	s.rotationGrace = d
 }
 
 func (s *Service) HandleCreateTenant(w http.ResponseWriter, r *http.Request) {
@@ -36,6 +118,22 @@ func (s *Service) HandleCreateTenant(w http.ResponseWriter, r *http.Request) {
 	if req.Type == "" {
 		req.Type = model.TenantTypeBoth
 	}
+	var org *model.Organization
+	if orgID := strings.TrimSpace(req.OrganizationID); orgID != "" {
+		if !s.requireOrgAdmin(w, r, orgID) {
+			return
+		}
+		o, err := s.store.GetOrganization(ctx, orgID)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if o == nil {
+			http.Error(w, "organization not found", http.StatusBadRequest)
+			return
+		}
+		org = o
+	}
 	now := time.Now().UTC()
 	tenantID := generateID("tenant_")
 	externalID := generateID("ext_")
@@ -56,6 +154,10 @@ func (s *Service) HandleCreateTenant(w http.ResponseWriter, r *http.Request) {
 	if t.Metadata == nil {
 		t.Metadata = map[string]any{}
 	}
+	if org != nil {
+		t.OrganizationID = org.ID
+		t.BillingEmail = org.BillingEmail
+	}
 	if err := s.store.CreateTenant(ctx, t); err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -76,6 +178,12 @@ func (s *Service) HandleCreateTenant(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, t.ID, model.AuditTenantCreated, "", map[string]any{
+		"name":            t.Name,
+		"type":            t.Type,
+		"organization_id": t.OrganizationID,
+	})
+	s.auditKeyCreated(r, k)
 
 	var resp model.CreateTenantResponse
 	resp.ID = t.ID
@@ -94,9 +202,8 @@ func (s *Service) HandleCreateTenant(w http.ResponseWriter, r *http.Request) {
 
 func (s *Service) HandleGetTenant(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	tenantID := pathParam(r.URL.Path, "/v1/tenants/", "")
-	if tenantID == "" {
-		http.Error(w, "tenant_id is required", http.StatusBadRequest)
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleViewer) {
 		return
 	}
 	t, err := s.store.GetTenant(ctx, tenantID)
@@ -113,18 +220,12 @@ func (s *Service) HandleGetTenant(w http.ResponseWriter, r *http.Request) {
 
 func (s *Service) HandleSuspendTenant(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	tenantID := pathParam(r.URL.Path, "/v1/tenants/", "/suspend")
-	if tenantID == "" {
-		http.Error(w, "tenant_id is required", http.StatusBadRequest)
-		return
-	}
-	t, err := s.store.GetTenant(ctx, tenantID)
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleOwner) {
 		return
 	}
-	if t == nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	t, ok := s.liveTenant(w, r, tenantID)
+	if !ok {
 		return
 	}
 	now := time.Now().UTC()
@@ -135,23 +236,19 @@ func (s *Service) HandleSuspendTenant(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, t.ID, model.AuditTenantSuspended, "", nil)
+	s.publishTenantSuspended(*t)
 	writeJSON(w, http.StatusOK, t)
 }
 
 func (s *Service) HandleActivateTenant(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	tenantID := pathParam(r.URL.Path, "/v1/tenants/", "/activate")
-	if tenantID == "" {
-		http.Error(w, "tenant_id is required", http.StatusBadRequest)
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleOwner) {
 		return
 	}
-	t, err := s.store.GetTenant(ctx, tenantID)
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
-	}
-	if t == nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	t, ok := s.liveTenant(w, r, tenantID)
+	if !ok {
 		return
 	}
 	now := time.Now().UTC()
@@ -163,23 +260,18 @@ func (s *Service) HandleActivateTenant(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, t.ID, model.AuditTenantActivated, "", nil)
+	s.publishTenantActivated(*t)
 	writeJSON(w, http.StatusOK, t)
 }
 
 func (s *Service) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	tenantID := pathParam(r.URL.Path, "/v1/tenants/", "/api-keys")
-	if tenantID == "" {
-		http.Error(w, "tenant_id is required", http.StatusBadRequest)
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleAdmin) {
 		return
 	}
-	t, err := s.store.GetTenant(ctx, tenantID)
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
-	}
-	if t == nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	if _, ok := s.liveTenant(w, r, tenantID); !ok {
 		return
 	}
 
@@ -188,28 +280,24 @@ func (s *Service) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	name := strings.TrimSpace(req.Name)
-	if name == "" {
-		name = "key"
+	scopes := normalizeScopes(req.Scopes)
+	if err := validateScopes(scopes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	now := time.Now().UTC()
-
-	plain, hash, prefix := generateAPIKey("aexk_")
-	k := model.APIKey{
-		ID:        generateID("key_"),
-		TenantID:  tenantID,
-		Name:      name,
-		KeyHash:   hash,
-		Prefix:    prefix,
-		Scopes:    normalizeScopes(req.Scopes),
-		Status:    model.APIKeyStatusActive,
-		CreatedAt: now,
-		ExpiresAt: req.ExpiresAt,
+	cidrs, routes, err := normalizeRestrictions(req.AllowedCIDRs, req.AllowedRoutes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	k, plain := newAPIKey(req.Name, scopes, req.ExpiresAt)
+	k.AllowedCIDRs, k.AllowedRoutes = cidrs, routes
+	k.TenantID = tenantID
 	if err := s.store.CreateAPIKey(ctx, k); err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	s.auditKeyCreated(r, k)
 
 	resp := model.CreateAPIKeyResponse{
 		ID:         k.ID,
@@ -220,15 +308,17 @@ func (s *Service) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:  k.CreatedAt,
 		ExpiresAt:  k.ExpiresAt,
 		LastUsedAt: nil,
+
+		AllowedCIDRs:  k.AllowedCIDRs,
+		AllowedRoutes: k.AllowedRoutes,
 	}
 	writeJSON(w, http.StatusCreated, resp)
 }
 
 func (s *Service) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	tenantID := pathParam(r.URL.Path, "/v1/tenants/", "/api-keys")
-	if tenantID == "" {
-		http.Error(w, "tenant_id is required", http.StatusBadRequest)
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleViewer) {
 		return
 	}
 	keys, err := s.store.ListAPIKeys(ctx, tenantID)
@@ -239,18 +329,12 @@ func (s *Service) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, keys)
 }
 
+// HandleRevokeAPIKey revokes an API key. Users may revoke their own keys;
+// admins any key.
 func (s *Service) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	tenantID := pathParam(r.URL.Path, "/v1/tenants/", "/api-keys/")
-	if tenantID == "" {
-		http.Error(w, "tenant_id is required", http.StatusBadRequest)
-		return
-	}
-	keyID := lastPathSegment(r.URL.Path)
-	if keyID == "" {
-		http.Error(w, "key_id is required", http.StatusBadRequest)
-		return
-	}
+	tenantID := r.PathValue("tenant_id")
+	keyID := r.PathValue("key_id")
 	k, err := s.store.GetAPIKey(ctx, tenantID, keyID)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -260,6 +344,9 @@ func (s *Service) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
+	if !s.authorizeUser(w, r, tenantID, k.UserID, model.UserRoleAdmin) {
+		return
+	}
 	now := time.Now().UTC()
 	k.Status = model.APIKeyStatusRevoked
 	k.RevokedAt = &now
@@ -267,68 +354,165 @@ func (s *Service) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, tenantID, model.AuditAPIKeyRevoked, k.ID, nil)
+	s.publishKeyRevoked(*k, "")
 	writeJSON(w, http.StatusOK, map[string]any{"revoked": true, "id": k.ID})
 }
 
-func (s *Service) HandleValidateAPIKey(w http.ResponseWriter, r *http.Request) {
+// HandleIssueToken exchanges an API key, sent as X-API-Key or in the body,
+// for a signed token carrying its tenant, scopes, their grants and quotas.
+// The token expires after the signer's TTL, or with the key if that is
+// sooner. Organization keys get a token for the tenant_id in the body.
+// An id_token instead signs in an operator of the tenant_id through the
+// tenant's OIDC issuer.
+func (s *Service) HandleIssueToken(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	var req model.ValidateAPIKeyRequest
-	if err := decodeJSON(r, &req); err != nil {
+	var req model.IssueTokenRequest
+	if err := decodeOptionalJSON(r, &req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	apiKey := strings.TrimSpace(req.APIKey)
+	if strings.TrimSpace(req.IDToken) != "" {
+		s.issueSSOToken(w, r, req)
+		return
+	}
+	apiKey := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if apiKey == "" {
+		apiKey = strings.TrimSpace(req.APIKey)
+	}
 	if apiKey == "" {
 		http.Error(w, "api_key is required", http.StatusBadRequest)
 		return
 	}
-	keyHash := hashAPIKey(apiKey)
-	k, err := s.store.FindAPIKeyByHash(ctx, keyHash)
+	k, t, u, err := s.authenticate(ctx, apiKey, strings.TrimSpace(req.TenantID))
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeAuthError(w, err)
 		return
 	}
-	if k == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+	claims := token.Claims{
+		Subject:        t.ID,
+		TenantID:       t.ID,
+		OrganizationID: k.OrganizationID,
+		UserID:         k.UserID,
+		Scopes:         k.Scopes,
+		Grants:         scopeGrants(k.Scopes),
+		Quotas:         t.Quotas,
+		KeyID:          k.ID,
+		AllowedCIDRs:   k.AllowedCIDRs,
+		AllowedRoutes:  k.AllowedRoutes,
+	}
+	if u != nil {
+		claims.UserRole = u.Role
+	}
+	if k.ExpiresAt != nil {
+		claims.ExpiresAt = k.ExpiresAt.Unix()
+	}
+	signed, expiresAt, err := s.tokens.Issue(claims)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	if k.Status != model.APIKeyStatusActive {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
+	writeJSON(w, http.StatusOK, model.IssueTokenResponse{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// HandleJWKS serves the public key tokens are verified with.
+func (s *Service) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, s.tokens.JWKS())
+}
+
+// authenticate looks up an active, unexpired API key of an active tenant
+// and records its use. An organization key needs tenantID, a tenant of its
+// organization, and is returned with the scopes its role leaves it; a
+// tenant key may only name its own tenant. A user's key is returned with
+// the scopes the user's role leaves it, and the user, who must be active.
+func (s *Service) authenticate(ctx context.Context, apiKey, tenantID string) (*model.APIKey, *model.Tenant, *model.TenantUser, error) {
+	k, err := s.store.FindAPIKeyByHash(ctx, hashAPIKey(apiKey))
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	if k.ExpiresAt != nil && time.Now().UTC().After(*k.ExpiresAt) {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
+	// fail refuses the key, recording why in the audit log.
+	fail := func(err error, reason string) (*model.APIKey, *model.Tenant, *model.TenantUser, error) {
+		if errors.Is(err, errUnauthorized) {
+			s.auditValidationFailure(ctx, tenantID, k, reason)
+		}
+		return nil, nil, nil, err
+	}
+	switch {
+	case k == nil:
+		return fail(errUnauthorized, "unknown key")
+	case k.Status != model.APIKeyStatusActive:
+		return fail(errUnauthorized, "key "+strings.ToLower(string(k.Status)))
+	case k.ExpiresAt != nil && time.Now().UTC().After(*k.ExpiresAt):
+		return fail(errUnauthorized, "key expired")
+	}
+	switch {
+	case k.TenantID != "":
+		if tenantID != "" && tenantID != k.TenantID {
+			return fail(errUnauthorized, "key of another tenant")
+		}
+		tenantID = k.TenantID
+	case tenantID == "":
+		return nil, nil, nil, errTenantRequired
 	}
-	t, err := s.store.GetTenant(ctx, k.TenantID)
+	t, err := s.activeTenant(ctx, tenantID)
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
+		return fail(err, "tenant not active")
 	}
-	if t == nil || t.Status != model.TenantStatusActive {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
+	if k.TenantID == "" && t.OrganizationID != k.OrganizationID {
+		return fail(errUnauthorized, "tenant outside the key's organization")
+	}
+	var u *model.TenantUser
+	if k.UserID != "" {
+		if u, err = s.activeUser(ctx, t.ID, k.UserID); err != nil {
+			return fail(err, "user not active")
+		}
 	}
 	now := time.Now().UTC()
 	k.LastUsedAt = &now
 	_ = s.store.UpdateAPIKey(ctx, *k)
+	switch {
+	case k.TenantID == "":
+		k.Scopes = roleScopes(k.Role, k.Scopes)
+	case u != nil:
+		k.Scopes = userRoleScopes(u.Role, k.Scopes)
+	}
+	return k, t, u, nil
+}
 
-	resp := model.ValidateAPIKeyResponse{
-		TenantID:     t.ID,
-		TenantStatus: t.Status,
-		Scopes:       k.Scopes,
-		Quotas:       t.Quotas,
+// activeTenant returns the tenant, or errUnauthorized unless it is active.
+func (s *Service) activeTenant(ctx context.Context, tenantID string) (*model.Tenant, error) {
+	t, err := s.store.GetTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
 	}
-	writeJSON(w, http.StatusOK, resp)
+	if t == nil || t.Status != model.TenantStatusActive {
+		return nil, errUnauthorized
+	}
+	return t, nil
 }
 
-func (s *Service) HandleGetQuotas(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	tenantID := pathParam(r.URL.Path, "/internal/v1/tenants/", "/quotas")
-	if tenantID == "" {
-		http.Error(w, "tenant_id is required", http.StatusBadRequest)
+func writeAuthError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUnauthorized):
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	case errors.Is(err, errTenantRequired):
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func (s *Service) HandleGetQuotas(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
 	t, err := s.store.GetTenant(ctx, tenantID)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -371,6 +555,19 @@ func normalizeScopes(in []string) []string {
 	return out
 }
 
+// decodeOptionalJSON decodes the body into v unless it is empty.
+func decodeOptionalJSON(r *http.Request, v any) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Body.Close() }()
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
 func decodeJSON(r *http.Request, v any) error {
 	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
 	if err != nil {
@@ -386,6 +583,25 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
+// newAPIKey returns a new active key, without an owner, and its secret.
+func newAPIKey(name string, scopes []string, expiresAt *time.Time) (model.APIKey, string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "key"
+	}
+	plain, hash, prefix := generateAPIKey("aexk_")
+	return model.APIKey{
+		ID:        generateID("key_"),
+		Name:      name,
+		KeyHash:   hash,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		Status:    model.APIKeyStatusActive,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}, plain
+}
+
 func generateID(prefix string) string {
 	var b [16]byte
 	_, _ = rand.Read(b[:])
@@ -403,39 +619,3 @@ func hashAPIKey(k string) string {
 	sum := sha256.Sum256([]byte(k))
 	return hex.EncodeToString(sum[:])
 }
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func pathParam(path string, prefix string, suffix string) string {
-	if !strings.HasPrefix(path, prefix) {
-		return ""
-	}
-	rest := strings.TrimPrefix(path, prefix)
-	if suffix != "" {
-		if !strings.HasSuffix(rest, suffix) {
-			return ""
-		}
-		rest = strings.TrimSuffix(rest, suffix)
-	}
-	rest = strings.Trim(rest, "/")
-	if i := strings.IndexByte(rest, '/'); i >= 0 {
-		rest = rest[:i]
-	}
-	return strings.TrimSpace(rest)
-}
-
-func lastPathSegment(path string) string {
-	path = strings.Trim(path, "/")
-	if path == "" {
-		return ""
-	}
-	if i := strings.LastIndexByte(path, '/'); i >= 0 {
-		return path[i+1:]
-	}
-	return path
-}
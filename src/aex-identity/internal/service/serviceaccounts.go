@@ -0,0 +1,225 @@
+package service
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/token"
+)
+
+// serviceTokenUse marks service account tokens, so they are never taken
+// for a tenant's token.
+const serviceTokenUse = "service"
+
+// HandleCreateServiceAccount creates a service account for the audiences
+// it calls and returns its secret, which is not shown again.
+func (s *Service) HandleCreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	var req model.CreateServiceAccountRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	audiences := make([]string, 0, len(req.Audiences))
+	for _, a := range req.Audiences {
+		a = strings.TrimSpace(a)
+		if a != "" && !slices.Contains(audiences, a) {
+			audiences = append(audiences, a)
+		}
+	}
+	if len(audiences) == 0 {
+		http.Error(w, "audiences are required", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	plain, hash, prefix := generateAPIKey("aexs_")
+	a := model.ServiceAccount{
+		ID:           generateID("sa_"),
+		Name:         name,
+		Description:  strings.TrimSpace(req.Description),
+		Audiences:    audiences,
+		SecretHash:   hash,
+		SecretPrefix: prefix,
+		Status:       model.ServiceAccountStatusActive,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.store.CreateServiceAccount(r.Context(), a); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("service account created id=%s name=%s audiences=%s", a.ID, a.Name, strings.Join(a.Audiences, ","))
+	writeJSON(w, http.StatusCreated, model.ServiceAccountSecretResponse{ServiceAccount: a, ClientSecret: plain})
+}
+
+func (s *Service) HandleListServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	accounts, err := s.store.ListServiceAccounts(r.Context())
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"service_accounts": accounts,
+		"total":            len(accounts),
+	})
+}
+
+func (s *Service) HandleGetServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	a, ok := s.serviceAccount(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// HandleRotateServiceAccountSecret replaces a service account's secret.
+// The old secret stops working at once; tokens already issued with it
+// keep working until they expire.
+func (s *Service) HandleRotateServiceAccountSecret(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	a, ok := s.serviceAccount(w, r)
+	if !ok {
+		return
+	}
+	if a.Status != model.ServiceAccountStatusActive {
+		http.Error(w, "service account disabled", http.StatusConflict)
+		return
+	}
+	plain, hash, prefix := generateAPIKey("aexs_")
+	a.SecretHash = hash
+	a.SecretPrefix = prefix
+	a.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateServiceAccount(r.Context(), *a); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("service account secret rotated id=%s", a.ID)
+	writeJSON(w, http.StatusOK, model.ServiceAccountSecretResponse{ServiceAccount: *a, ClientSecret: plain})
+}
+
+// HandleDisableServiceAccount stops a service account from getting tokens.
+// The account is kept, so its ID stays known.
+func (s *Service) HandleDisableServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	a, ok := s.serviceAccount(w, r)
+	if !ok {
+		return
+	}
+	if a.Status != model.ServiceAccountStatusDisabled {
+		a.Status = model.ServiceAccountStatusDisabled
+		a.UpdatedAt = time.Now().UTC()
+		if err := s.store.UpdateServiceAccount(r.Context(), *a); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("service account disabled id=%s", a.ID)
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// HandleIssueServiceToken exchanges a service account's ID and secret for
+// a token for one of its audiences. Services verify it against the JWKS
+// with the internal/serviceauth package.
+func (s *Service) HandleIssueServiceToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req model.ServiceTokenRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	audience := strings.TrimSpace(req.Audience)
+	if strings.TrimSpace(req.ClientID) == "" || req.ClientSecret == "" || audience == "" {
+		http.Error(w, "client_id, client_secret and audience are required", http.StatusBadRequest)
+		return
+	}
+	a, err := s.store.GetServiceAccount(ctx, strings.TrimSpace(req.ClientID))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if a == nil || a.Status != model.ServiceAccountStatusActive ||
+		subtle.ConstantTimeCompare([]byte(hashAPIKey(req.ClientSecret)), []byte(a.SecretHash)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !slices.Contains(a.Audiences, audience) {
+		http.Error(w, "audience not allowed for this service account", http.StatusForbidden)
+		return
+	}
+
+	signed, expiresAt, err := s.tokens.Issue(token.Claims{
+		Subject:  a.ID,
+		Service:  a.Name,
+		Audience: audience,
+		TokenUse: serviceTokenUse,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	a.LastUsedAt = &now
+	if err := s.store.UpdateServiceAccount(ctx, *a); err != nil {
+		log.Printf("failed to record service account use id=%s: %v", a.ID, err)
+	}
+	writeJSON(w, http.StatusOK, model.IssueTokenResponse{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// serviceAccount looks up the service account named by the path, answering
+// 404 when there is none.
+func (s *Service) serviceAccount(w http.ResponseWriter, r *http.Request) (*model.ServiceAccount, bool) {
+	id := r.PathValue("account_id")
+	a, err := s.store.GetServiceAccount(r.Context(), id)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if a == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	return a, true
+}
+
+// requireAdmin answers 401 unless the request carries the admin token as a
+// Bearer token. Without an admin token every request is refused.
+func (s *Service) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		http.Error(w, "admin API is disabled: no admin token is configured", http.StatusForbidden)
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(s.adminToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
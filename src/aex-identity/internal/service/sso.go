@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/oidc"
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/token"
+)
+
+// defaultGroupsClaim is the ID token claim listing a user's groups unless
+// the tenant names another.
+const defaultGroupsClaim = "groups"
+
+// HandleGetSSO serves a tenant's OIDC federation settings.
+func (s *Service) HandleGetSSO(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleAdmin) {
+		return
+	}
+	t, ok := s.liveTenant(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if t.SSO == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, t.SSO)
+}
+
+// HandleConfigureSSO sets the OIDC issuer a tenant's operators sign in
+// with and the roles their groups map to. Only owners may, as the mapping
+// can make anyone the issuer vouches for an owner.
+func (s *Service) HandleConfigureSSO(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleOwner) {
+		return
+	}
+	var cfg model.OIDCConfig
+	if err := decodeJSON(r, &cfg); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	cfg.Issuer = strings.TrimSpace(cfg.Issuer)
+	cfg.ClientID = strings.TrimSpace(cfg.ClientID)
+	cfg.JWKSURL = strings.TrimSpace(cfg.JWKSURL)
+	cfg.GroupsClaim = strings.TrimSpace(cfg.GroupsClaim)
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = defaultGroupsClaim
+	}
+	if !secureURL(cfg.Issuer) || (cfg.JWKSURL != "" && !secureURL(cfg.JWKSURL)) {
+		http.Error(w, "issuer and jwks_url must be https URLs", http.StatusBadRequest)
+		return
+	}
+	if cfg.ClientID == "" {
+		http.Error(w, "client_id is required", http.StatusBadRequest)
+		return
+	}
+	roles := make(map[string]model.UserRole, len(cfg.GroupRoles))
+	for group, role := range cfg.GroupRoles {
+		role = model.UserRole(strings.ToUpper(strings.TrimSpace(string(role))))
+		if _, ok := userRoleRank[role]; !ok || strings.TrimSpace(group) == "" {
+			http.Error(w, "group_roles must map groups to OWNER, ADMIN, DEVELOPER or VIEWER", http.StatusBadRequest)
+			return
+		}
+		roles[strings.TrimSpace(group)] = role
+	}
+	cfg.GroupRoles = roles
+	cfg.DefaultRole = model.UserRole(strings.ToUpper(strings.TrimSpace(string(cfg.DefaultRole))))
+	if _, ok := userRoleRank[cfg.DefaultRole]; !ok && cfg.DefaultRole != "" {
+		http.Error(w, "default_role must be OWNER, ADMIN, DEVELOPER or VIEWER", http.StatusBadRequest)
+		return
+	}
+	if len(cfg.GroupRoles) == 0 && cfg.DefaultRole == "" {
+		http.Error(w, "group_roles or default_role is required", http.StatusBadRequest)
+		return
+	}
+
+	t, ok := s.liveTenant(w, r, tenantID)
+	if !ok {
+		return
+	}
+	cfg.UpdatedAt = time.Now().UTC()
+	t.SSO = &cfg
+	t.UpdatedAt = cfg.UpdatedAt
+	if err := s.store.UpdateTenant(ctx, *t); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, t.ID, model.AuditTenantSSOUpdated, "", map[string]any{"issuer": cfg.Issuer, "client_id": cfg.ClientID})
+	log.Printf("tenant sso configured tenant_id=%s issuer=%s", t.ID, cfg.Issuer)
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// HandleDeleteSSO turns a tenant's OIDC federation off. Tokens already
+// issued through it stay valid until they expire.
+func (s *Service) HandleDeleteSSO(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleOwner) {
+		return
+	}
+	t, ok := s.liveTenant(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if t.SSO == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	t.SSO = nil
+	t.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateTenant(ctx, *t); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, t.ID, model.AuditTenantSSOUpdated, "", map[string]any{"removed": true})
+	writeJSON(w, http.StatusOK, map[string]any{"id": t.ID, "sso": nil})
+}
+
+// issueSSOToken exchanges an ID token of a tenant's OIDC issuer for a
+// token of the tenant user it names, added on first sign-in. The user's
+// role follows their groups at every sign-in; users disabled in the tenant
+// stay refused.
+func (s *Service) issueSSOToken(w http.ResponseWriter, r *http.Request, req model.IssueTokenRequest) {
+	ctx := r.Context()
+	tenantID := strings.TrimSpace(req.TenantID)
+	t, err := s.activeTenant(ctx, tenantID)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	if t.SSO == nil {
+		http.Error(w, "single sign-on is not configured for the tenant", http.StatusBadRequest)
+		return
+	}
+	cfg := t.SSO
+	id, err := s.oidc.Verify(ctx, oidc.Provider{Issuer: cfg.Issuer, ClientID: cfg.ClientID, JWKSURL: cfg.JWKSURL}, strings.TrimSpace(req.IDToken))
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalid) {
+			s.auditSSOFailure(ctx, t.ID, "", "invalid id token")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("oidc verification failed tenant_id=%s issuer=%s: %v", t.ID, cfg.Issuer, err)
+		http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	role := ssoRole(cfg, id.Claims)
+	if role == "" {
+		s.auditSSOFailure(ctx, t.ID, id.Subject, "no role for the user's groups")
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	u, created, err := s.ssoUser(ctx, t.ID, cfg.Issuer, id, role)
+	switch {
+	case errors.Is(err, errUnauthorized):
+		s.auditSSOFailure(ctx, t.ID, id.Subject, "no active user for the id token")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	scopes := userRoleScopes(u.Role, []string{"*"})
+	signed, expiresAt, err := s.tokens.Issue(token.Claims{
+		Subject:  t.ID,
+		TenantID: t.ID,
+		UserID:   u.ID,
+		UserRole: u.Role,
+		Scopes:   scopes,
+		Grants:   scopeGrants(scopes),
+		Quotas:   t.Quotas,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.appendAudit(ctx, model.AuditEntry{
+		TenantID: t.ID,
+		Action:   model.AuditUserSSOLogin,
+		ActorID:  u.ID,
+		Details:  map[string]any{"subject": id.Subject, "role": u.Role, "user_created": created},
+	})
+	writeJSON(w, http.StatusOK, model.IssueTokenResponse{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// ssoUser finds the user an ID token names, by subject or else by email,
+// and gives them role, or adds them. Linking by email needs an email the
+// issuer has not marked unverified.
+func (s *Service) ssoUser(ctx context.Context, tenantID, issuer string, id *oidc.IDToken, role model.UserRole) (*model.TenantUser, bool, error) {
+	users, err := s.store.ListUsers(ctx, tenantID)
+	if err != nil {
+		return nil, false, err
+	}
+	email := strings.ToLower(strings.TrimSpace(id.Email))
+	if verified, ok := id.Claims["email_verified"].(bool); ok && !verified {
+		email = ""
+	}
+	var u *model.TenantUser
+	for i := range users {
+		if users[i].SSOIssuer == issuer && users[i].SSOSubject == id.Subject {
+			u = &users[i]
+			break
+		}
+		if email != "" && users[i].Email == email && users[i].SSOIssuer != issuer {
+			u = &users[i]
+		}
+	}
+
+	now := time.Now().UTC()
+	if u == nil {
+		if !strings.Contains(email, "@") {
+			return nil, false, errUnauthorized
+		}
+		nu := model.TenantUser{
+			ID:         generateID("user_"),
+			TenantID:   tenantID,
+			Email:      email,
+			Name:       strings.TrimSpace(id.Name),
+			Role:       role,
+			Status:     model.UserStatusActive,
+			SSOIssuer:  issuer,
+			SSOSubject: id.Subject,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := s.store.CreateUser(ctx, nu); err != nil {
+			return nil, false, err
+		}
+		log.Printf("user added by sso tenant_id=%s user_id=%s role=%s", tenantID, nu.ID, nu.Role)
+		return &nu, true, nil
+	}
+	if u.Status != model.UserStatusActive {
+		return nil, false, errUnauthorized
+	}
+	if u.Role != role || u.SSOSubject != id.Subject || u.SSOIssuer != issuer {
+		u.Role = role
+		u.SSOIssuer = issuer
+		u.SSOSubject = id.Subject
+		u.UpdatedAt = now
+		if err := s.store.UpdateUser(ctx, *u); err != nil {
+			return nil, false, err
+		}
+	}
+	return u, false, nil
+}
+
+// ssoRole is the highest role the groups of an ID token map to, the
+// default role when none does, or "" when there is none.
+func ssoRole(cfg *model.OIDCConfig, claims map[string]any) model.UserRole {
+	var groups []string
+	switch g := claims[cfg.GroupsClaim].(type) {
+	case string:
+		groups = []string{g}
+	case []any:
+		for _, v := range g {
+			if name, ok := v.(string); ok {
+				groups = append(groups, name)
+			}
+		}
+	}
+	var role model.UserRole
+	for _, g := range groups {
+		if mapped, ok := cfg.GroupRoles[g]; ok && (role == "" || userRoleRank[mapped] > userRoleRank[role]) {
+			role = mapped
+		}
+	}
+	if role == "" {
+		return cfg.DefaultRole
+	}
+	return role
+}
+
+func (s *Service) auditSSOFailure(ctx context.Context, tenantID, subject, reason string) {
+	details := map[string]any{"reason": reason}
+	if subject != "" {
+		details["subject"] = subject
+	}
+	s.appendAudit(ctx, model.AuditEntry{TenantID: tenantID, Action: model.AuditUserSSOLoginFailed, Details: details})
+}
+
+// secureURL reports whether raw is an https URL, or an http one on the
+// loopback interface for issuers run locally.
+func secureURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		ip := net.ParseIP(host)
+		return host == "localhost" || (ip != nil && ip.IsLoopback())
+	}
+	return false
+}
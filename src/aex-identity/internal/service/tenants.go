@@ -0,0 +1,257 @@
+package service
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// HandleListTenants lists tenants by id, filtered by ?status= and ?type=,
+// a page of ?limit= at a time after ?cursor=. Only operators and services
+// list every tenant; a tenant's own keys list just that tenant.
+func (s *Service) HandleListTenants(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	q := model.TenantQuery{
+		Status: model.TenantStatus(strings.ToUpper(strings.TrimSpace(qs.Get("status")))),
+		Type:   model.TenantType(strings.ToUpper(strings.TrimSpace(qs.Get("type")))),
+		Cursor: strings.TrimSpace(qs.Get("cursor")),
+		Limit:  defaultListLimit,
+	}
+	switch q.Status {
+	case "", model.TenantStatusPending, model.TenantStatusActive, model.TenantStatusSuspended, model.TenantStatusTerminated:
+	default:
+		http.Error(w, "status must be PENDING, ACTIVE, SUSPENDED or TERMINATED", http.StatusBadRequest)
+		return
+	}
+	switch q.Type {
+	case "", model.TenantTypeRequestor, model.TenantTypeProvider, model.TenantTypeBoth:
+	default:
+		http.Error(w, "type must be REQUESTOR, PROVIDER or BOTH", http.StatusBadRequest)
+		return
+	}
+	if l := qs.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		q.Limit = min(parsed, maxListLimit)
+	}
+
+	if !s.trustedCaller(r) {
+		s.listCallerTenant(w, r, q)
+		return
+	}
+	tenants, nextCursor, err := s.store.QueryTenants(r.Context(), q)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tenants":     tenants,
+		"total":       len(tenants),
+		"next_cursor": nextCursor,
+	})
+}
+
+// listCallerTenant answers a tenant listing with the caller's own tenant,
+// if it matches q.
+func (s *Service) listCallerTenant(w http.ResponseWriter, r *http.Request, q model.TenantQuery) {
+	callerID := r.Header.Get(tenantHeader)
+	if callerID == "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	t, err := s.store.GetTenant(r.Context(), callerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	tenants := make([]model.Tenant, 0, 1)
+	if t != nil && (q.Status == "" || t.Status == q.Status) && (q.Type == "" || t.Type == q.Type) && t.ID > q.Cursor {
+		tenants = append(tenants, *t)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tenants":     tenants,
+		"total":       len(tenants),
+		"next_cursor": "",
+	})
+}
+
+// HandleUpdateTenant changes a tenant's name, emails, metadata or quotas.
+func (s *Service) HandleUpdateTenant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleAdmin) {
+		return
+	}
+	var req model.UpdateTenantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		http.Error(w, "name must not be empty", http.StatusBadRequest)
+		return
+	}
+	if q := req.Quotas; q != nil && (q.RequestsPerMinute < 0 || q.RequestsPerDay < 0 || q.MaxAgents < 0 ||
+		q.MaxConcurrentTasks < 0 || q.MaxTaskPayloadBytes < 0) {
+		http.Error(w, "quotas must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	t, ok := s.liveTenant(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if req.BillingEmail != nil && t.OrganizationID != "" {
+		http.Error(w, "billing_email is set by the tenant's organization", http.StatusConflict)
+		return
+	}
+	if req.Name != nil {
+		t.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.ContactEmail != nil {
+		t.ContactEmail = strings.TrimSpace(*req.ContactEmail)
+	}
+	if req.BillingEmail != nil {
+		t.BillingEmail = strings.TrimSpace(*req.BillingEmail)
+	}
+	if req.Metadata != nil {
+		t.Metadata = req.Metadata
+	}
+	if req.Quotas != nil {
+		t.Quotas = *req.Quotas
+	}
+	t.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateTenant(ctx, *t); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// HandleDeleteTenant deletes a tenant. By default the tenant is kept as a
+// TERMINATED record with its name, emails and metadata erased, its users
+// are removed and its API keys are revoked, so their IDs stay known. ?purge=true removes the
+// tenant and its keys entirely, including tenants deleted before.
+func (s *Service) HandleDeleteTenant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleOwner) {
+		return
+	}
+	t, err := s.store.GetTenant(ctx, tenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if t == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	keys, err := s.store.ListAPIKeys(ctx, tenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("purge") == "true" {
+		if err := s.store.DeleteTenant(ctx, tenantID); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		s.audit(r, tenantID, model.AuditTenantDeleted, "", map[string]any{"purged": true, "api_keys_deleted": len(keys)})
+		log.Printf("tenant purged tenant_id=%s api_keys_deleted=%d", tenantID, len(keys))
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":               tenantID,
+			"purged":           true,
+			"api_keys_deleted": len(keys),
+		})
+		return
+	}
+
+	if t.DeletedAt != nil {
+		http.Error(w, "tenant already deleted", http.StatusConflict)
+		return
+	}
+	now := time.Now().UTC()
+	t.Name = "deleted tenant"
+	t.ContactEmail = ""
+	t.BillingEmail = ""
+	t.Metadata = map[string]any{}
+	t.Status = model.TenantStatusTerminated
+	t.SuspendedAt = nil
+	t.SuspensionReason = nil
+	t.DeletedAt = &now
+	t.UpdatedAt = now
+	if err := s.store.UpdateTenant(ctx, *t); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// Keys of a TERMINATED tenant no longer authenticate, so a key left
+	// unrevoked is logged rather than failing the request.
+	revoked := 0
+	for _, k := range keys {
+		if k.Status != model.APIKeyStatusActive {
+			continue
+		}
+		k.Status = model.APIKeyStatusRevoked
+		k.RevokedAt = &now
+		if err := s.store.UpdateAPIKey(ctx, k); err != nil {
+			log.Printf("failed to revoke api key of deleted tenant tenant_id=%s key_id=%s: %v", tenantID, k.ID, err)
+			continue
+		}
+		s.publishKeyRevoked(k, "tenant deleted")
+		revoked++
+	}
+	users, err := s.store.ListUsers(ctx, tenantID)
+	if err != nil {
+		log.Printf("failed to list users of deleted tenant tenant_id=%s: %v", tenantID, err)
+	}
+	removed := 0
+	for _, u := range users {
+		if err := s.store.DeleteUser(ctx, tenantID, u.ID); err != nil {
+			log.Printf("failed to remove user of deleted tenant tenant_id=%s user_id=%s: %v", tenantID, u.ID, err)
+			continue
+		}
+		removed++
+	}
+	s.audit(r, tenantID, model.AuditTenantDeleted, "", map[string]any{"api_keys_revoked": revoked, "users_removed": removed})
+	log.Printf("tenant deleted tenant_id=%s api_keys_revoked=%d users_removed=%d", tenantID, revoked, removed)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":               t.ID,
+		"status":           t.Status,
+		"deleted_at":       t.DeletedAt,
+		"api_keys_revoked": revoked,
+	})
+}
+
+// liveTenant looks up a tenant that has not been deleted, answering 404 or
+// 409 otherwise.
+func (s *Service) liveTenant(w http.ResponseWriter, r *http.Request, tenantID string) (*model.Tenant, bool) {
+	t, err := s.store.GetTenant(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if t == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	if t.DeletedAt != nil {
+		http.Error(w, "tenant deleted", http.StatusConflict)
+		return nil, false
+	}
+	return t, true
+}
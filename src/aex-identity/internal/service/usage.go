@@ -0,0 +1,76 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+)
+
+// usageDay is the UTC date request counts are kept for.
+func usageDay() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+// HandleRecordUsage adds to a tenant's usage for the gateway and the work
+// publisher: requests as they are served, and tasks and agents as they
+// start and finish, with negative counts. With "enforce" the increment is
+// refused with 429, and nothing added, when it would pass a quota.
+func (s *Service) HandleRecordUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	var req model.RecordUsageRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.Requests < 0 {
+		http.Error(w, "requests must not be negative", http.StatusBadRequest)
+		return
+	}
+	t, err := s.store.GetTenant(ctx, tenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if t == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	var limits *model.Quotas
+	if req.Enforce {
+		limits = &t.Quotas
+	}
+	u, quota, err := s.store.AddUsage(ctx, tenantID, usageDay(), req.UsageDelta, limits)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if quota != "" {
+		status = http.StatusTooManyRequests
+	}
+	writeJSON(w, status, model.UsageResponse{Usage: *u, Quotas: t.Quotas, Exceeded: quota})
+}
+
+// HandleGetUsage serves a tenant's usage today, with its quotas.
+func (s *Service) HandleGetUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	t, err := s.store.GetTenant(ctx, tenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if t == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	u, err := s.store.GetUsage(ctx, tenantID, usageDay())
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, model.UsageResponse{Usage: *u, Quotas: t.Quotas})
+}
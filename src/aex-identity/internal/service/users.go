@@ -0,0 +1,455 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+)
+
+// Headers the gateway sets on the requests it proxies. The user headers
+// are only set for requests made with a user's API key; other requests,
+// made with a tenant-wide key or by other services, are not restricted by
+// role.
+const (
+	tenantHeader   = "X-Tenant-ID"
+	userIDHeader   = "X-User-ID"
+	userRoleHeader = "X-User-Role"
+)
+
+// userRoleRank orders the user roles by what they may do.
+var userRoleRank = map[model.UserRole]int{
+	model.UserRoleViewer:    1,
+	model.UserRoleDeveloper: 2,
+	model.UserRoleAdmin:     3,
+	model.UserRoleOwner:     4,
+}
+
+// developerScopes are what "*" and "admin:*" leave a DEVELOPER's key. The
+// tenants scope only lets the key reach the endpoints its role allows.
+var developerScopes = []string{
+	"work:write", "bids:write", "contracts:manage", "providers:write", "settlement:read", "tenants:write",
+}
+
+// HandleListUsers lists a tenant's users.
+func (s *Service) HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleViewer) {
+		return
+	}
+	if _, ok := s.liveTenant(w, r, tenantID); !ok {
+		return
+	}
+	users, err := s.store.ListUsers(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"users": users, "total": len(users)})
+}
+
+// HandleCreateUser adds a user to a tenant. Only owners may add owners.
+func (s *Service) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	var req model.CreateUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if !strings.Contains(email, "@") {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+	role := model.UserRole(strings.ToUpper(strings.TrimSpace(string(req.Role))))
+	if role == "" {
+		role = model.UserRoleViewer
+	}
+	if _, ok := userRoleRank[role]; !ok {
+		http.Error(w, "role must be OWNER, ADMIN, DEVELOPER or VIEWER", http.StatusBadRequest)
+		return
+	}
+	if !s.requireRole(w, r, tenantID, higherRole(role, model.UserRoleAdmin)) {
+		return
+	}
+	if _, ok := s.liveTenant(w, r, tenantID); !ok {
+		return
+	}
+	users, err := s.store.ListUsers(ctx, tenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	for _, u := range users {
+		if u.Email == email {
+			http.Error(w, "a user with this email exists", http.StatusConflict)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	u := model.TenantUser{
+		ID:        generateID("user_"),
+		TenantID:  tenantID,
+		Email:     email,
+		Name:      strings.TrimSpace(req.Name),
+		Role:      role,
+		Status:    model.UserStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.store.CreateUser(ctx, u); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("user added tenant_id=%s user_id=%s role=%s", tenantID, u.ID, u.Role)
+	writeJSON(w, http.StatusCreated, u)
+}
+
+func (s *Service) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleViewer) {
+		return
+	}
+	u, ok := s.tenantUser(w, r, tenantID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// HandleUpdateUser changes a user's name, role or status. Only owners may
+// change owners or make users owners, and a tenant keeps at least one
+// active owner once it has one.
+func (s *Service) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	var req model.UpdateUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.Role != nil {
+		*req.Role = model.UserRole(strings.ToUpper(strings.TrimSpace(string(*req.Role))))
+		if _, ok := userRoleRank[*req.Role]; !ok {
+			http.Error(w, "role must be OWNER, ADMIN, DEVELOPER or VIEWER", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Status != nil {
+		*req.Status = model.UserStatus(strings.ToUpper(strings.TrimSpace(string(*req.Status))))
+		if *req.Status != model.UserStatusActive && *req.Status != model.UserStatusDisabled {
+			http.Error(w, "status must be ACTIVE or DISABLED", http.StatusBadRequest)
+			return
+		}
+	}
+	if !s.requireRole(w, r, tenantID, model.UserRoleAdmin) {
+		return
+	}
+	u, ok := s.tenantUser(w, r, tenantID)
+	if !ok {
+		return
+	}
+	needed := u.Role
+	if req.Role != nil {
+		needed = higherRole(needed, *req.Role)
+	}
+	if !s.requireRole(w, r, tenantID, higherRole(needed, model.UserRoleAdmin)) {
+		return
+	}
+	demoted := req.Role != nil && *req.Role != model.UserRoleOwner
+	disabled := req.Status != nil && *req.Status != model.UserStatusActive
+	if (demoted || disabled) && !s.keepsOwner(w, r, *u) {
+		return
+	}
+
+	if req.Name != nil {
+		u.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Role != nil {
+		u.Role = *req.Role
+	}
+	if req.Status != nil {
+		u.Status = *req.Status
+	}
+	u.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateUser(ctx, *u); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// HandleDeleteUser removes a user from a tenant and revokes their API keys.
+func (s *Service) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleAdmin) {
+		return
+	}
+	u, ok := s.tenantUser(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if !s.requireRole(w, r, tenantID, higherRole(u.Role, model.UserRoleAdmin)) || !s.keepsOwner(w, r, *u) {
+		return
+	}
+	revoked, err := s.revokeUserKeys(r, tenantID, u.ID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.DeleteUser(ctx, tenantID, u.ID); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("user removed tenant_id=%s user_id=%s api_keys_revoked=%d", tenantID, u.ID, revoked)
+	writeJSON(w, http.StatusOK, map[string]any{"id": u.ID, "deleted": true, "api_keys_revoked": revoked})
+}
+
+// HandleCreateUserAPIKey creates an API key of a user, which acts within
+// the user's role. Users may create their own keys; admins anyone's.
+func (s *Service) HandleCreateUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := r.PathValue("tenant_id")
+	userID := r.PathValue("user_id")
+	if !s.authorizeUser(w, r, tenantID, userID, model.UserRoleAdmin) {
+		return
+	}
+	var req model.CreateAPIKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	scopes := normalizeScopes(req.Scopes)
+	if err := validateScopes(scopes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cidrs, routes, err := normalizeRestrictions(req.AllowedCIDRs, req.AllowedRoutes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.liveTenant(w, r, tenantID); !ok {
+		return
+	}
+	u, err := s.store.GetUser(ctx, tenantID, userID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if u == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if u.Status != model.UserStatusActive {
+		http.Error(w, "user disabled", http.StatusConflict)
+		return
+	}
+
+	k, plain := newAPIKey(req.Name, scopes, req.ExpiresAt)
+	k.AllowedCIDRs, k.AllowedRoutes = cidrs, routes
+	k.TenantID = tenantID
+	k.UserID = u.ID
+	if err := s.store.CreateAPIKey(ctx, k); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.auditKeyCreated(r, k)
+	writeJSON(w, http.StatusCreated, model.CreateAPIKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		Key:       plain,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt,
+		ExpiresAt: k.ExpiresAt,
+
+		AllowedCIDRs:  k.AllowedCIDRs,
+		AllowedRoutes: k.AllowedRoutes,
+	})
+}
+
+// HandleListUserAPIKeys lists the API keys of a user.
+func (s *Service) HandleListUserAPIKeys(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.PathValue("tenant_id")
+	userID := r.PathValue("user_id")
+	if !s.requireRole(w, r, tenantID, model.UserRoleViewer) {
+		return
+	}
+	keys, err := s.store.ListAPIKeys(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	out := make([]model.APIKey, 0)
+	for _, k := range keys {
+		if k.UserID == userID {
+			out = append(out, k)
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// tenantUser looks up the user named by the request path, answering 404
+// otherwise.
+func (s *Service) tenantUser(w http.ResponseWriter, r *http.Request, tenantID string) (*model.TenantUser, bool) {
+	u, err := s.store.GetUser(r.Context(), tenantID, r.PathValue("user_id"))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if u == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	return u, true
+}
+
+// keepsOwner answers 409 and reports false if u is the tenant's last
+// active owner.
+func (s *Service) keepsOwner(w http.ResponseWriter, r *http.Request, u model.TenantUser) bool {
+	if u.Role != model.UserRoleOwner || u.Status != model.UserStatusActive {
+		return true
+	}
+	users, err := s.store.ListUsers(r.Context(), u.TenantID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return false
+	}
+	for _, other := range users {
+		if other.ID != u.ID && other.Role == model.UserRoleOwner && other.Status == model.UserStatusActive {
+			return true
+		}
+	}
+	http.Error(w, "a tenant needs an active owner", http.StatusConflict)
+	return false
+}
+
+// revokeUserKeys revokes the active API keys of a user.
+func (s *Service) revokeUserKeys(r *http.Request, tenantID, userID string) (int, error) {
+	ctx := r.Context()
+	keys, err := s.store.ListAPIKeys(ctx, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	revoked := 0
+	for _, k := range keys {
+		if k.UserID != userID || k.Status != model.APIKeyStatusActive {
+			continue
+		}
+		k.Status = model.APIKeyStatusRevoked
+		k.RevokedAt = &now
+		if err := s.store.UpdateAPIKey(ctx, k); err != nil {
+			return revoked, err
+		}
+		s.audit(r, tenantID, model.AuditAPIKeyRevoked, k.ID, map[string]any{"user_id": userID, "reason": "user removed"})
+		s.publishKeyRevoked(k, "user removed")
+		revoked++
+	}
+	return revoked, nil
+}
+
+// activeUser returns the user, or errUnauthorized unless it is active.
+func (s *Service) activeUser(ctx context.Context, tenantID, userID string) (*model.TenantUser, error) {
+	u, err := s.store.GetUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.Status != model.UserStatusActive {
+		return nil, errUnauthorized
+	}
+	return u, nil
+}
+
+// higherRole returns whichever of a and b may do more.
+func higherRole(a, b model.UserRole) model.UserRole {
+	if userRoleRank[a] >= userRoleRank[b] {
+		return a
+	}
+	return b
+}
+
+// requireRole answers 403 and reports false unless the request acts for
+// tenantID, if it names a tenant, and its user, if it has one, has at least
+// role min. Trusted callers may act for any tenant.
+func (s *Service) requireRole(w http.ResponseWriter, r *http.Request, tenantID string, min model.UserRole) bool {
+	return s.authorizeUser(w, r, tenantID, "", min)
+}
+
+// authorizeUser is requireRole that also admits the user named self,
+// whatever their role.
+func (s *Service) authorizeUser(w http.ResponseWriter, r *http.Request, tenantID, self string, min model.UserRole) bool {
+	if s.trustedCaller(r) {
+		return true
+	}
+	caller := r.Header.Get(tenantHeader)
+	if caller != "" && caller != tenantID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	role := model.UserRole(r.Header.Get(userRoleHeader))
+	if role == "" {
+		return true
+	}
+	if caller == "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	if userRoleRank[role] >= userRoleRank[min] || (self != "" && r.Header.Get(userIDHeader) == self) {
+		return true
+	}
+	http.Error(w, "requires the "+string(min)+" role", http.StatusForbidden)
+	return false
+}
+
+// trustedCaller reports whether the request carries the admin token or a
+// service account token for the identity service, whose callers act for
+// any tenant.
+func (s *Service) trustedCaller(r *http.Request) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	got = strings.TrimSpace(got)
+	if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(got), []byte(s.adminToken)) == 1 {
+		return true
+	}
+	claims, err := s.tokens.Verify(got)
+	return err == nil && claims.TokenUse == serviceTokenUse && claims.Audience == "aex-identity"
+}
+
+// userRoleScopes narrows the scopes of a user's key to what the user's
+// role allows: all of them for owners and admins, the read side for
+// viewers, and for developers no deposits and nothing "*" grants beyond
+// developerScopes.
+func userRoleScopes(role model.UserRole, scopes []string) []string {
+	switch role {
+	case model.UserRoleOwner, model.UserRoleAdmin:
+		return scopes
+	case model.UserRoleDeveloper:
+		var out []string
+		for _, name := range scopes {
+			switch name {
+			case "*", "admin:*":
+				for _, sc := range developerScopes {
+					out = appendScope(out, sc)
+				}
+			case "settlement:write", "settlement:*":
+				out = appendScope(out, "settlement:read")
+			default:
+				out = appendScope(out, name)
+			}
+		}
+		return out
+	default:
+		return readScopes(scopes)
+	}
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+)
+
+// defaultValidationMaxAge is how long callers may cache a validation unless
+// SetValidationMaxAge says otherwise.
+const defaultValidationMaxAge = time.Minute
+
+// maxBatchValidations bounds the keys of one batch validation.
+const maxBatchValidations = 100
+
+var (
+	errAPIKeyRequired  = errors.New("api_key is required")
+	errInvalidClientIP = errors.New("client_ip must be an IP address")
+)
+
+// restrictionError refuses a key used from an address or for a route its
+// restrictions do not allow.
+type restrictionError struct {
+	reason string
+}
+
+func (e restrictionError) Error() string {
+	return "api key " + e.reason
+}
+
+// SetValidationMaxAge sets how long callers may cache validations, which
+// the validate endpoints say with Cache-Control.
+func (s *Service) SetValidationMaxAge(d time.Duration) {
+	s.validationMaxAge = d
+}
+
+// SetValidationRateLimit limits each caller to perSecond key validations,
+// with bursts of up to burst; a batch counts each of its keys. Zero
+// perSecond removes the limit.
+func (s *Service) SetValidationRateLimit(perSecond, burst int) {
+	if perSecond <= 0 {
+		s.validations = nil
+		return
+	}
+	s.validations = newValidationLimiter(float64(perSecond), float64(max(burst, perSecond, maxBatchValidations)))
+}
+
+// HandleValidateAPIKey answers whether an API key, or a token issued by
+// POST /v1/token, is valid, with its tenant, scopes, what they grant, and
+// quotas. Organization keys are validated for the tenant_id they act for.
+// Valid answers carry an ETag and say how long they may be cached; a
+// request whose If-None-Match names the ETag is answered 304.
+func (s *Service) HandleValidateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.allowValidations(w, r, 1) {
+		return
+	}
+	var req model.ValidateAPIKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	resp, maxAge, err := s.validate(r.Context(), req)
+	if err != nil {
+		status, message := validationFailure(err)
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, message, status)
+		return
+	}
+	writeCacheable(w, r, resp, maxAge)
+}
+
+// HandleValidateAPIKeys validates up to maxBatchValidations keys or tokens
+// at once, answering a result for each in request order. The response may
+// be cached as long as its shortest-lived result.
+func (s *Service) HandleValidateAPIKeys(w http.ResponseWriter, r *http.Request) {
+	var req model.ValidateAPIKeysRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Keys) == 0 || len(req.Keys) > maxBatchValidations {
+		http.Error(w, "keys must hold 1 to "+strconv.Itoa(maxBatchValidations)+" keys", http.StatusBadRequest)
+		return
+	}
+	if !s.allowValidations(w, r, len(req.Keys)) {
+		return
+	}
+
+	resp := model.ValidateAPIKeysResponse{Results: make([]model.ValidateAPIKeyResult, len(req.Keys))}
+	maxAge := s.validationMaxAge
+	for i, k := range req.Keys {
+		v, keyMaxAge, err := s.validate(r.Context(), k)
+		if err != nil {
+			status, message := validationFailure(err)
+			if status == http.StatusInternalServerError {
+				http.Error(w, message, status)
+				return
+			}
+			resp.Results[i] = model.ValidateAPIKeyResult{Status: status, Error: message}
+			maxAge = 0
+			continue
+		}
+		resp.Results[i] = model.ValidateAPIKeyResult{
+			Valid:                  true,
+			Status:                 http.StatusOK,
+			MaxAgeSeconds:          int(keyMaxAge.Seconds()),
+			ValidateAPIKeyResponse: v,
+		}
+		maxAge = min(maxAge, keyMaxAge)
+	}
+	writeCacheable(w, r, resp, maxAge)
+}
+
+// validate checks a key or token the way HandleValidateAPIKey does and
+// returns its validation, and how long that may be cached: the
+// validation max age, but no longer than the key or token lives.
+func (s *Service) validate(ctx context.Context, req model.ValidateAPIKeyRequest) (*model.ValidateAPIKeyResponse, time.Duration, error) {
+	apiKey := strings.TrimSpace(req.APIKey)
+	if apiKey == "" {
+		return nil, 0, errAPIKeyRequired
+	}
+	clientIP, route := strings.TrimSpace(req.ClientIP), strings.TrimSpace(req.Route)
+	if _, err := netip.ParseAddr(clientIP); clientIP != "" && err != nil {
+		return nil, 0, errInvalidClientIP
+	}
+	maxAge := s.validationMaxAge
+
+	if claims, err := s.tokens.Verify(apiKey); err == nil && claims.TokenUse == "" {
+		t, err := s.activeTenant(ctx, claims.TenantID)
+		if err != nil {
+			if errors.Is(err, errUnauthorized) {
+				s.auditTokenFailure(ctx, claims, "tenant not active")
+			}
+			return nil, 0, err
+		}
+		if claims.UserID != "" {
+			if _, err := s.activeUser(ctx, t.ID, claims.UserID); err != nil {
+				if errors.Is(err, errUnauthorized) {
+					s.auditTokenFailure(ctx, claims, "user not active")
+				}
+				return nil, 0, err
+			}
+		}
+		if reason := restrictionViolation(claims.AllowedCIDRs, claims.AllowedRoutes, clientIP, route); reason != "" {
+			s.auditTokenFailure(ctx, claims, reason)
+			return nil, 0, restrictionError{reason}
+		}
+		return &model.ValidateAPIKeyResponse{
+			KeyID:          claims.KeyID,
+			TenantID:       t.ID,
+			OrganizationID: claims.OrganizationID,
+			UserID:         claims.UserID,
+			UserRole:       claims.UserRole,
+			TenantStatus:   t.Status,
+			Scopes:         claims.Scopes,
+			Grants:         scopeGrants(claims.Scopes),
+			Quotas:         t.Quotas,
+			AllowedCIDRs:   claims.AllowedCIDRs,
+			AllowedRoutes:  claims.AllowedRoutes,
+		}, min(maxAge, time.Until(time.Unix(claims.ExpiresAt, 0))), nil
+	}
+
+	k, t, u, err := s.authenticate(ctx, apiKey, strings.TrimSpace(req.TenantID))
+	if err != nil {
+		return nil, 0, err
+	}
+	if reason := restrictionViolation(k.AllowedCIDRs, k.AllowedRoutes, clientIP, route); reason != "" {
+		s.auditValidationFailure(ctx, t.ID, k, reason)
+		return nil, 0, restrictionError{reason}
+	}
+	resp := &model.ValidateAPIKeyResponse{
+		KeyID:          k.ID,
+		TenantID:       t.ID,
+		OrganizationID: k.OrganizationID,
+		UserID:         k.UserID,
+		TenantStatus:   t.Status,
+		Scopes:         k.Scopes,
+		Grants:         scopeGrants(k.Scopes),
+		Quotas:         t.Quotas,
+		AllowedCIDRs:   k.AllowedCIDRs,
+		AllowedRoutes:  k.AllowedRoutes,
+	}
+	if u != nil {
+		resp.UserRole = u.Role
+	}
+	if k.ExpiresAt != nil {
+		maxAge = min(maxAge, time.Until(*k.ExpiresAt))
+	}
+	return resp, maxAge, nil
+}
+
+// validationFailure is the status and message a validation error is
+// answered with.
+func validationFailure(err error) (int, string) {
+	var restricted restrictionError
+	switch {
+	case errors.Is(err, errUnauthorized):
+		return http.StatusUnauthorized, "unauthorized"
+	case errors.Is(err, errAPIKeyRequired), errors.Is(err, errInvalidClientIP), errors.Is(err, errTenantRequired):
+		return http.StatusBadRequest, err.Error()
+	case errors.As(err, &restricted):
+		return http.StatusForbidden, restricted.Error()
+	}
+	return http.StatusInternalServerError, "internal error"
+}
+
+// writeCacheable answers v with an ETag of its body, which callers may
+// cache for maxAge, or 304 when If-None-Match names the ETag.
+func writeCacheable(w http.ResponseWriter, r *http.Request, v any, maxAge time.Duration) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(max(maxAge, 0).Seconds())))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header names etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// allowValidations takes n validations from the caller's rate limit,
+// answering 429 when it is used up.
+func (s *Service) allowValidations(w http.ResponseWriter, r *http.Request, n int) bool {
+	if s.validations == nil {
+		return true
+	}
+	caller, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		caller = r.RemoteAddr
+	}
+	if wait := s.validations.take(caller, float64(n)); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "too many validations", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// maxLimitedCallers bounds the callers validationLimiter tracks; callers
+// whose bucket has refilled are dropped first.
+const maxLimitedCallers = 10000
+
+// validationLimiter is a token bucket per caller.
+type validationLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*validationBucket
+}
+
+type validationBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newValidationLimiter(rate, burst float64) *validationLimiter {
+	return &validationLimiter{rate: rate, burst: burst, buckets: make(map[string]*validationBucket)}
+}
+
+// take removes n tokens from caller's bucket and returns 0, or, when it
+// holds too few, leaves it alone and returns how long until it would hold
+// enough.
+func (l *validationLimiter) take(caller string, n float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	b, ok := l.buckets[caller]
+	if !ok {
+		if len(l.buckets) >= maxLimitedCallers {
+			for c, old := range l.buckets {
+				if old.tokens+now.Sub(old.last).Seconds()*l.rate >= l.burst {
+					delete(l.buckets, c)
+				}
+			}
+		}
+		b = &validationBucket{tokens: l.burst, last: now}
+		l.buckets[caller] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < n {
+		return time.Duration((n - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens -= n
+	return 0
+}
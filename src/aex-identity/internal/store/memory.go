@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
 )
@@ -10,15 +12,27 @@ import (
 type MemoryStore struct {
 	mu      sync.RWMutex
 	tenants map[string]model.Tenant
-	apiKeys map[string]map[string]model.APIKey // tenantID -> keyID -> key
-	byHash  map[string]model.APIKey            // keyHash -> key
+	orgs    map[string]model.Organization
+	users   map[string]map[string]model.TenantUser // tenantID -> userID -> user
+	apiKeys map[string]map[string]model.APIKey     // tenantID -> keyID -> key
+	orgKeys map[string]map[string]model.APIKey     // orgID -> keyID -> key
+	byHash  map[string]model.APIKey                // keyHash -> key
+	usage   map[string]model.Usage                 // tenantID -> usage on its last day
+	audit   map[string][]model.AuditEntry          // tenantID -> entries, oldest first
+	svcAccs map[string]model.ServiceAccount
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		tenants: map[string]model.Tenant{},
+		orgs:    map[string]model.Organization{},
+		users:   map[string]map[string]model.TenantUser{},
 		apiKeys: map[string]map[string]model.APIKey{},
+		orgKeys: map[string]map[string]model.APIKey{},
 		byHash:  map[string]model.APIKey{},
+		usage:   map[string]model.Usage{},
+		audit:   map[string][]model.AuditEntry{},
+		svcAccs: map[string]model.ServiceAccount{},
 	}
 }
 
@@ -46,14 +60,128 @@ func (s *MemoryStore) UpdateTenant(ctx context.Context, t model.Tenant) error {
 	return s.CreateTenant(ctx, t)
 }
 
+func (s *MemoryStore) QueryTenants(ctx context.Context, q model.TenantQuery) ([]model.Tenant, string, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	matched := make([]model.Tenant, 0)
+	for _, t := range s.tenants {
+		if q.Cursor != "" && t.ID <= q.Cursor {
+			continue
+		}
+		if q.Status != "" && t.Status != q.Status {
+			continue
+		}
+		if q.Type != "" && t.Type != q.Type {
+			continue
+		}
+		if q.OrganizationID != "" && t.OrganizationID != q.OrganizationID {
+			continue
+		}
+		matched = append(matched, t)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	if q.Limit > 0 && len(matched) > q.Limit {
+		return matched[:q.Limit], matched[q.Limit-1].ID, nil
+	}
+	return matched, "", nil
+}
+
+func (s *MemoryStore) DeleteTenant(ctx context.Context, tenantID string) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.apiKeys[tenantID] {
+		delete(s.byHash, k.KeyHash)
+	}
+	delete(s.apiKeys, tenantID)
+	delete(s.users, tenantID)
+	delete(s.usage, tenantID)
+	delete(s.tenants, tenantID)
+	return nil
+}
+
+func (s *MemoryStore) CreateOrganization(ctx context.Context, o model.Organization) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orgs[o.ID] = o
+	return nil
+}
+
+func (s *MemoryStore) GetOrganization(ctx context.Context, orgID string) (*model.Organization, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.orgs[orgID]
+	if !ok {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+func (s *MemoryStore) UpdateOrganization(ctx context.Context, o model.Organization) error {
+	return s.CreateOrganization(ctx, o)
+}
+
+func (s *MemoryStore) CreateUser(ctx context.Context, u model.TenantUser) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.TenantID]; !ok {
+		s.users[u.TenantID] = map[string]model.TenantUser{}
+	}
+	s.users[u.TenantID][u.ID] = u
+	return nil
+}
+
+func (s *MemoryStore) GetUser(ctx context.Context, tenantID string, userID string) (*model.TenantUser, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[tenantID][userID]
+	if !ok {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+func (s *MemoryStore) ListUsers(ctx context.Context, tenantID string) ([]model.TenantUser, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.TenantUser, 0, len(s.users[tenantID]))
+	for _, u := range s.users[tenantID] {
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *MemoryStore) UpdateUser(ctx context.Context, u model.TenantUser) error {
+	return s.CreateUser(ctx, u)
+}
+
+func (s *MemoryStore) DeleteUser(ctx context.Context, tenantID string, userID string) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users[tenantID], userID)
+	return nil
+}
+
 func (s *MemoryStore) CreateAPIKey(ctx context.Context, k model.APIKey) error {
 	_ = ctx
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.apiKeys[k.TenantID]; !ok {
-		s.apiKeys[k.TenantID] = map[string]model.APIKey{}
+	owners, owner := s.apiKeys, k.TenantID
+	if owner == "" {
+		owners, owner = s.orgKeys, k.OrganizationID
 	}
-	s.apiKeys[k.TenantID][k.ID] = k
+	if _, ok := owners[owner]; !ok {
+		owners[owner] = map[string]model.APIKey{}
+	}
+	owners[owner][k.ID] = k
 	s.byHash[k.KeyHash] = k
 	return nil
 }
@@ -98,3 +226,155 @@ func (s *MemoryStore) FindAPIKeyByHash(ctx context.Context, keyHash string) (*mo
 	out := k
 	return &out, nil
 }
+
+func (s *MemoryStore) ListAPIKeysExpiringBefore(ctx context.Context, before time.Time) ([]model.APIKey, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.APIKey, 0)
+	for _, k := range s.byHash {
+		if k.Status == model.APIKeyStatusActive && k.ExpiresAt != nil && k.ExpiresAt.Before(before) {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) AddUsage(ctx context.Context, tenantID, day string, delta model.UsageDelta, limits *model.Quotas) (*model.Usage, string, error) {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.usageOn(tenantID, day)
+	if limits != nil {
+		if quota := exceeded(u, delta, *limits); quota != "" {
+			return &u, quota, nil
+		}
+	}
+	u.Requests += delta.Requests
+	u.ActiveTasks += delta.Tasks
+	u.Agents += delta.Agents
+	s.usage[tenantID] = u
+	return &u, "", nil
+}
+
+func (s *MemoryStore) GetUsage(ctx context.Context, tenantID, day string) (*model.Usage, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u := s.usageOn(tenantID, day)
+	return &u, nil
+}
+
+// usageOn returns the tenant's usage, with the request count of an earlier
+// day reset.
+func (s *MemoryStore) usageOn(tenantID, day string) model.Usage {
+	u, ok := s.usage[tenantID]
+	if !ok {
+		u = model.Usage{TenantID: tenantID}
+	}
+	if u.Day != day {
+		u.Day = day
+		u.Requests = 0
+	}
+	return u
+}
+
+func (s *MemoryStore) ListOrganizationAPIKeys(ctx context.Context, orgID string) ([]model.APIKey, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m := s.orgKeys[orgID]
+	out := make([]model.APIKey, 0, len(m))
+	for _, k := range m {
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) GetOrganizationAPIKey(ctx context.Context, orgID string, keyID string) (*model.APIKey, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.orgKeys[orgID][keyID]
+	if !ok {
+		return nil, nil
+	}
+	return &k, nil
+}
+
+func (s *MemoryStore) AppendAuditEntry(ctx context.Context, e model.AuditEntry) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit[e.TenantID] = append(s.audit[e.TenantID], e)
+	return nil
+}
+
+func (s *MemoryStore) QueryAuditLog(ctx context.Context, q model.AuditQuery) ([]model.AuditEntry, string, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	matched := make([]model.AuditEntry, 0)
+	for _, e := range s.audit[q.TenantID] {
+		if q.Cursor != "" && e.ID >= q.Cursor {
+			continue
+		}
+		if q.Action != "" && e.Action != q.Action {
+			continue
+		}
+		if q.ActorID != "" && e.ActorID != q.ActorID {
+			continue
+		}
+		if q.TargetID != "" && e.TargetID != q.TargetID {
+			continue
+		}
+		if !q.Since.IsZero() && e.CreatedAt.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !e.CreatedAt.Before(q.Until) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+	if q.Limit > 0 && len(matched) > q.Limit {
+		return matched[:q.Limit], matched[q.Limit-1].ID, nil
+	}
+	return matched, "", nil
+}
+
+func (s *MemoryStore) CreateServiceAccount(ctx context.Context, a model.ServiceAccount) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.svcAccs[a.ID] = a
+	return nil
+}
+
+func (s *MemoryStore) GetServiceAccount(ctx context.Context, id string) (*model.ServiceAccount, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.svcAccs[id]
+	if !ok {
+		return nil, nil
+	}
+	a.Audiences = append([]string(nil), a.Audiences...)
+	return &a, nil
+}
+
+func (s *MemoryStore) ListServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.ServiceAccount, 0, len(s.svcAccs))
+	for _, a := range s.svcAccs {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *MemoryStore) UpdateServiceAccount(ctx context.Context, a model.ServiceAccount) error {
+	return s.CreateServiceAccount(ctx, a)
+}
@@ -10,31 +10,93 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// usageRetention is how long daily request counts are kept.
+const usageRetention = 35 * 24 * time.Hour
+
 type MongoStore struct {
 	tenants *mongo.Collection
+	orgs    *mongo.Collection
+	users   *mongo.Collection
 	keys    *mongo.Collection
+	usage   *mongo.Collection
+	audit   *mongo.Collection
+	svcAccs *mongo.Collection
+}
+
+// Collections names the collections a MongoStore keeps its records in.
+type Collections struct {
+	Tenants       string
+	Organizations string
+	APIKeys       string
+	Users         string
+	Usage         string
+	AuditLog      string
+	// ServiceAccounts holds the credentials of the exchange's services.
+	ServiceAccounts string
 }
 
-func NewMongoStore(client *mongo.Client, dbName, tenantsColl, keysColl string) *MongoStore {
+func NewMongoStore(client *mongo.Client, dbName string, colls Collections) *MongoStore {
 	db := client.Database(dbName)
 	return &MongoStore{
-		tenants: db.Collection(tenantsColl),
-		keys:    db.Collection(keysColl),
+		tenants: db.Collection(colls.Tenants),
+		orgs:    db.Collection(colls.Organizations),
+		users:   db.Collection(colls.Users),
+		keys:    db.Collection(colls.APIKeys),
+		usage:   db.Collection(colls.Usage),
+		audit:   db.Collection(colls.AuditLog),
+		svcAccs: db.Collection(colls.ServiceAccounts),
 	}
 }
 
 func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
-	_, err := s.tenants.Indexes().CreateOne(ctx, mongo.IndexModel{
+	_, err := s.tenants.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "organization_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.orgs.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys:    bson.D{{Key: "id", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	})
 	if err != nil {
 		return err
 	}
+	_, err = s.users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return err
+	}
 	_, err = s.keys.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
 		{Keys: bson.D{{Key: "tenant_id", Value: 1}}},
+		{Keys: bson.D{{Key: "organization_id", Value: 1}}},
 		{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.usage.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.audit.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "id", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.svcAccs.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
 	})
 	return err
 }
@@ -70,6 +132,155 @@ func (s *MongoStore) UpdateTenant(ctx context.Context, t model.Tenant) error {
 	return err
 }
 
+func (s *MongoStore) QueryTenants(ctx context.Context, q model.TenantQuery) ([]model.Tenant, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	filter := bson.M{}
+	if q.Cursor != "" {
+		filter["id"] = bson.M{"$gt": q.Cursor}
+	}
+	if q.Status != "" {
+		filter["status"] = q.Status
+	}
+	if q.Type != "" {
+		filter["type"] = q.Type
+	}
+	if q.OrganizationID != "" {
+		filter["organization_id"] = q.OrganizationID
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: 1}})
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit) + 1)
+	}
+	cur, err := s.tenants.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+	out := make([]model.Tenant, 0)
+	for cur.Next(ctx) {
+		var t model.Tenant
+		if err := cur.Decode(&t); err != nil {
+			return nil, "", err
+		}
+		out = append(out, t)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, "", err
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+		return out, out[len(out)-1].ID, nil
+	}
+	return out, "", nil
+}
+
+// DeleteTenant removes the keys, users and usage first, so a failure never
+// leaves records of a tenant that no longer exists.
+func (s *MongoStore) DeleteTenant(ctx context.Context, tenantID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, err := s.keys.DeleteMany(ctx, bson.M{"tenant_id": tenantID}); err != nil {
+		return err
+	}
+	if _, err := s.users.DeleteMany(ctx, bson.M{"tenant_id": tenantID}); err != nil {
+		return err
+	}
+	if _, err := s.usage.DeleteMany(ctx, bson.M{"tenant_id": tenantID}); err != nil {
+		return err
+	}
+	_, err := s.tenants.DeleteOne(ctx, bson.M{"id": tenantID})
+	return err
+}
+
+func (s *MongoStore) CreateOrganization(ctx context.Context, o model.Organization) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.orgs.InsertOne(ctx, o)
+	return err
+}
+
+func (s *MongoStore) GetOrganization(ctx context.Context, orgID string) (*model.Organization, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.orgs.FindOne(ctx, bson.M{"id": orgID})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var o model.Organization
+	if err := res.Decode(&o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (s *MongoStore) UpdateOrganization(ctx context.Context, o model.Organization) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.orgs.ReplaceOne(ctx, bson.M{"id": o.ID}, o, options.Replace().SetUpsert(false))
+	return err
+}
+
+func (s *MongoStore) CreateUser(ctx context.Context, u model.TenantUser) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.users.InsertOne(ctx, u)
+	return err
+}
+
+func (s *MongoStore) GetUser(ctx context.Context, tenantID string, userID string) (*model.TenantUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.users.FindOne(ctx, bson.M{"tenant_id": tenantID, "id": userID})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var u model.TenantUser
+	if err := res.Decode(&u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *MongoStore) ListUsers(ctx context.Context, tenantID string) ([]model.TenantUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cur, err := s.users.Find(ctx, bson.M{"tenant_id": tenantID}, options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+	out := make([]model.TenantUser, 0)
+	for cur.Next(ctx) {
+		var u model.TenantUser
+		if err := cur.Decode(&u); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, cur.Err()
+}
+
+func (s *MongoStore) UpdateUser(ctx context.Context, u model.TenantUser) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.users.ReplaceOne(ctx, bson.M{"tenant_id": u.TenantID, "id": u.ID}, u, options.Replace().SetUpsert(false))
+	return err
+}
+
+func (s *MongoStore) DeleteUser(ctx context.Context, tenantID string, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.users.DeleteOne(ctx, bson.M{"tenant_id": tenantID, "id": userID})
+	return err
+}
+
 func (s *MongoStore) CreateAPIKey(ctx context.Context, k model.APIKey) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
@@ -78,9 +289,17 @@ func (s *MongoStore) CreateAPIKey(ctx context.Context, k model.APIKey) error {
 }
 
 func (s *MongoStore) ListAPIKeys(ctx context.Context, tenantID string) ([]model.APIKey, error) {
+	return s.findAPIKeys(ctx, bson.M{"tenant_id": tenantID})
+}
+
+func (s *MongoStore) ListOrganizationAPIKeys(ctx context.Context, orgID string) ([]model.APIKey, error) {
+	return s.findAPIKeys(ctx, bson.M{"organization_id": orgID, "tenant_id": ""})
+}
+
+func (s *MongoStore) findAPIKeys(ctx context.Context, filter bson.M) ([]model.APIKey, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	cur, err := s.keys.Find(ctx, bson.M{"tenant_id": tenantID})
+	cur, err := s.keys.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -101,9 +320,17 @@ func (s *MongoStore) ListAPIKeys(ctx context.Context, tenantID string) ([]model.
 }
 
 func (s *MongoStore) GetAPIKey(ctx context.Context, tenantID string, keyID string) (*model.APIKey, error) {
+	return s.findAPIKey(ctx, bson.M{"tenant_id": tenantID, "id": keyID})
+}
+
+func (s *MongoStore) GetOrganizationAPIKey(ctx context.Context, orgID string, keyID string) (*model.APIKey, error) {
+	return s.findAPIKey(ctx, bson.M{"organization_id": orgID, "tenant_id": "", "id": keyID})
+}
+
+func (s *MongoStore) findAPIKey(ctx context.Context, filter bson.M) (*model.APIKey, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	res := s.keys.FindOne(ctx, bson.M{"tenant_id": tenantID, "id": keyID})
+	res := s.keys.FindOne(ctx, filter)
 	if res.Err() == mongo.ErrNoDocuments {
 		return nil, nil
 	}
@@ -140,3 +367,228 @@ func (s *MongoStore) FindAPIKeyByHash(ctx context.Context, keyHash string) (*mod
 	}
 	return &k, nil
 }
+
+func (s *MongoStore) ListAPIKeysExpiringBefore(ctx context.Context, before time.Time) ([]model.APIKey, error) {
+	return s.findAPIKeys(ctx, bson.M{
+		"status":     model.APIKeyStatusActive,
+		"expires_at": bson.M{"$lt": before},
+	})
+}
+
+// Usage is kept in two kinds of documents: the request count of each day,
+// with _id "<tenant>/<day>", which expire after usageRetention, and the
+// task and agent counts, with _id "<tenant>".
+type usageCounter struct {
+	field string
+	id    string
+	delta int64
+	limit int
+	quota string
+}
+
+// AddUsage increments each counter with a conditional upsert, so counters
+// stay within their quotas under concurrent increments. Should a later
+// counter be over its quota, the earlier increments are undone.
+func (s *MongoStore) AddUsage(ctx context.Context, tenantID, day string, delta model.UsageDelta, limits *model.Quotas) (*model.Usage, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var q model.Quotas
+	if limits != nil {
+		q = *limits
+	}
+	counters := []usageCounter{
+		{"active_tasks", tenantID, delta.Tasks, q.MaxConcurrentTasks, QuotaMaxConcurrentTasks},
+		{"agents", tenantID, delta.Agents, q.MaxAgents, QuotaMaxAgents},
+		{"requests", tenantID + "/" + day, delta.Requests, q.RequestsPerDay, QuotaRequestsPerDay},
+	}
+	expiresAt, _ := time.Parse("2006-01-02", day)
+	expiresAt = expiresAt.Add(usageRetention)
+
+	var applied []usageCounter
+	for _, c := range counters {
+		if c.delta == 0 {
+			continue
+		}
+		onInsert := bson.M{"tenant_id": tenantID}
+		if c.field == "requests" {
+			onInsert["day"] = day
+			onInsert["expires_at"] = expiresAt
+		}
+		ok, err := s.incUsage(ctx, c, onInsert)
+		if err != nil || !ok {
+			for _, a := range applied {
+				a.delta, a.limit = -a.delta, 0
+				if _, undoErr := s.incUsage(ctx, a, nil); undoErr != nil && err == nil {
+					err = undoErr
+				}
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			u, err := s.getUsage(ctx, tenantID, day)
+			return u, c.quota, err
+		}
+		applied = append(applied, c)
+	}
+	u, err := s.getUsage(ctx, tenantID, day)
+	return u, "", err
+}
+
+// incUsage adds c.delta to c's counter unless that would pass its limit.
+// A counter over its limit fails the filter, so the upsert tries to insert
+// a second document with the same _id and is refused.
+func (s *MongoStore) incUsage(ctx context.Context, c usageCounter, onInsert bson.M) (bool, error) {
+	filter := bson.M{"_id": c.id}
+	if c.limit > 0 && c.delta > 0 {
+		if c.delta > int64(c.limit) {
+			return false, nil
+		}
+		filter[c.field] = bson.M{"$lte": int64(c.limit) - c.delta}
+	}
+	update := bson.M{"$inc": bson.M{c.field: c.delta}}
+	if len(onInsert) > 0 {
+		update["$setOnInsert"] = onInsert
+	}
+	_, err := s.usage.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *MongoStore) GetUsage(ctx context.Context, tenantID, day string) (*model.Usage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return s.getUsage(ctx, tenantID, day)
+}
+
+func (s *MongoStore) getUsage(ctx context.Context, tenantID, day string) (*model.Usage, error) {
+	u := model.Usage{TenantID: tenantID, Day: day}
+	var counts struct {
+		Requests    int64 `bson:"requests"`
+		ActiveTasks int64 `bson:"active_tasks"`
+		Agents      int64 `bson:"agents"`
+	}
+	err := s.usage.FindOne(ctx, bson.M{"_id": tenantID}).Decode(&counts)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	u.ActiveTasks, u.Agents = counts.ActiveTasks, counts.Agents
+	counts.Requests = 0
+	err = s.usage.FindOne(ctx, bson.M{"_id": tenantID + "/" + day}).Decode(&counts)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	u.Requests = counts.Requests
+	return &u, nil
+}
+
+func (s *MongoStore) AppendAuditEntry(ctx context.Context, e model.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.audit.InsertOne(ctx, e)
+	return err
+}
+
+func (s *MongoStore) QueryAuditLog(ctx context.Context, q model.AuditQuery) ([]model.AuditEntry, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	filter := bson.M{"tenant_id": q.TenantID}
+	if q.Cursor != "" {
+		filter["id"] = bson.M{"$lt": q.Cursor}
+	}
+	if q.Action != "" {
+		filter["action"] = q.Action
+	}
+	if q.ActorID != "" {
+		filter["actor_id"] = q.ActorID
+	}
+	if q.TargetID != "" {
+		filter["target_id"] = q.TargetID
+	}
+	created := bson.M{}
+	if !q.Since.IsZero() {
+		created["$gte"] = q.Since
+	}
+	if !q.Until.IsZero() {
+		created["$lt"] = q.Until
+	}
+	if len(created) > 0 {
+		filter["created_at"] = created
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: -1}})
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit) + 1)
+	}
+	cur, err := s.audit.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+	out := make([]model.AuditEntry, 0)
+	for cur.Next(ctx) {
+		var e model.AuditEntry
+		if err := cur.Decode(&e); err != nil {
+			return nil, "", err
+		}
+		out = append(out, e)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, "", err
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+		return out, out[len(out)-1].ID, nil
+	}
+	return out, "", nil
+}
+
+func (s *MongoStore) CreateServiceAccount(ctx context.Context, a model.ServiceAccount) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.svcAccs.InsertOne(ctx, a)
+	return err
+}
+
+func (s *MongoStore) GetServiceAccount(ctx context.Context, id string) (*model.ServiceAccount, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.svcAccs.FindOne(ctx, bson.M{"id": id})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var a model.ServiceAccount
+	if err := res.Decode(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *MongoStore) ListServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cur, err := s.svcAccs.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+	out := make([]model.ServiceAccount, 0)
+	for cur.Next(ctx) {
+		var a model.ServiceAccount
+		if err := cur.Decode(&a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, cur.Err()
+}
+
+func (s *MongoStore) UpdateServiceAccount(ctx context.Context, a model.ServiceAccount) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.svcAccs.ReplaceOne(ctx, bson.M{"id": a.ID}, a, options.Replace().SetUpsert(false))
+	return err
+}
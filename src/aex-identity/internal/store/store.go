@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
 )
@@ -10,11 +11,78 @@ type Store interface {
 	CreateTenant(ctx context.Context, t model.Tenant) error
 	GetTenant(ctx context.Context, tenantID string) (*model.Tenant, error)
 	UpdateTenant(ctx context.Context, t model.Tenant) error
+	// QueryTenants returns up to q.Limit tenants matching q, ordered by id,
+	// and the cursor for the next page or "" at the end.
+	QueryTenants(ctx context.Context, q model.TenantQuery) ([]model.Tenant, string, error)
+	// DeleteTenant removes the tenant, all of its users and API keys and its
+	// usage.
+	DeleteTenant(ctx context.Context, tenantID string) error
 
+	CreateOrganization(ctx context.Context, o model.Organization) error
+	GetOrganization(ctx context.Context, orgID string) (*model.Organization, error)
+	UpdateOrganization(ctx context.Context, o model.Organization) error
+
+	CreateUser(ctx context.Context, u model.TenantUser) error
+	GetUser(ctx context.Context, tenantID string, userID string) (*model.TenantUser, error)
+	// ListUsers returns the tenant's users ordered by id.
+	ListUsers(ctx context.Context, tenantID string) ([]model.TenantUser, error)
+	UpdateUser(ctx context.Context, u model.TenantUser) error
+	DeleteUser(ctx context.Context, tenantID string, userID string) error
+
+	// CreateAPIKey and UpdateAPIKey store tenant and organization keys.
 	CreateAPIKey(ctx context.Context, k model.APIKey) error
 	ListAPIKeys(ctx context.Context, tenantID string) ([]model.APIKey, error)
 	GetAPIKey(ctx context.Context, tenantID string, keyID string) (*model.APIKey, error)
 	UpdateAPIKey(ctx context.Context, k model.APIKey) error
+	ListOrganizationAPIKeys(ctx context.Context, orgID string) ([]model.APIKey, error)
+	GetOrganizationAPIKey(ctx context.Context, orgID string, keyID string) (*model.APIKey, error)
 
 	FindAPIKeyByHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	// ListAPIKeysExpiringBefore returns the active tenant and organization
+	// keys that expire before the given time, including those expired.
+	ListAPIKeysExpiringBefore(ctx context.Context, before time.Time) ([]model.APIKey, error)
+
+	// AddUsage adds delta to the tenant's request count for day and its
+	// task and agent counts, and returns the new usage. With limits, a
+	// delta that would take a counter past its non-zero quota is not added
+	// and the quota's name is returned.
+	AddUsage(ctx context.Context, tenantID, day string, delta model.UsageDelta, limits *model.Quotas) (*model.Usage, string, error)
+	GetUsage(ctx context.Context, tenantID, day string) (*model.Usage, error)
+
+	// AppendAuditEntry adds to a tenant's audit log, which is kept when
+	// the tenant is deleted or purged.
+	AppendAuditEntry(ctx context.Context, e model.AuditEntry) error
+	// QueryAuditLog returns up to q.Limit entries matching q, newest first,
+	// and the cursor for the next page or "" at the end.
+	QueryAuditLog(ctx context.Context, q model.AuditQuery) ([]model.AuditEntry, string, error)
+
+	CreateServiceAccount(ctx context.Context, a model.ServiceAccount) error
+	GetServiceAccount(ctx context.Context, id string) (*model.ServiceAccount, error)
+	// ListServiceAccounts returns all service accounts ordered by id.
+	ListServiceAccounts(ctx context.Context) ([]model.ServiceAccount, error)
+	UpdateServiceAccount(ctx context.Context, a model.ServiceAccount) error
+}
+
+// Names of the quotas AddUsage enforces.
+const (
+	QuotaRequestsPerDay     = "requests_per_day"
+	QuotaMaxConcurrentTasks = "max_concurrent_tasks"
+	QuotaMaxAgents          = "max_agents"
+)
+
+// exceeded returns the quota adding delta to u would pass, or "".
+func exceeded(u model.Usage, delta model.UsageDelta, limits model.Quotas) string {
+	switch {
+	case passes(u.Requests, delta.Requests, limits.RequestsPerDay):
+		return QuotaRequestsPerDay
+	case passes(u.ActiveTasks, delta.Tasks, limits.MaxConcurrentTasks):
+		return QuotaMaxConcurrentTasks
+	case passes(u.Agents, delta.Agents, limits.MaxAgents):
+		return QuotaMaxAgents
+	}
+	return ""
+}
+
+func passes(used, delta int64, limit int) bool {
+	return limit > 0 && delta > 0 && used+delta > int64(limit)
 }
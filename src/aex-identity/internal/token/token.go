@@ -0,0 +1,237 @@
+// Package token issues and verifies the short-lived JWTs the identity
+// service exchanges for API keys. Tokens are signed with ES256 and the
+// public key is published as a JWKS, so other services can verify them
+// without calling the identity service.
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/model"
+)
+
+var (
+	ErrInvalid = errors.New("invalid token")
+	ErrExpired = errors.New("token expired")
+)
+
+// Claims are the claims of an identity token. Subject is the tenant ID and
+// KeyID the API key the token was exchanged for, or, for service account
+// tokens, Subject is the account's ID.
+type Claims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub"`
+	TenantID string `json:"tenant_id"`
+	// OrganizationID is set for tokens of organization keys.
+	OrganizationID string `json:"organization_id,omitempty"`
+	// UserID and UserRole are set for tokens of a user's API keys.
+	UserID   string             `json:"user_id,omitempty"`
+	UserRole model.UserRole     `json:"user_role,omitempty"`
+	Scopes   []string           `json:"scopes"`
+	Grants   []model.ScopeGrant `json:"grants,omitempty"`
+	Quotas   model.Quotas       `json:"quotas"`
+	KeyID    string             `json:"key_id,omitempty"`
+	// AllowedCIDRs and AllowedRoutes are the restrictions of the key.
+	AllowedCIDRs  []string `json:"allowed_cidrs,omitempty"`
+	AllowedRoutes []string `json:"allowed_routes,omitempty"`
+	// Service, Audience and TokenUse are set for service account tokens:
+	// the calling service, the service the token is for and "service".
+	Service   string `json:"service,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	TokenUse  string `json:"token_use,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// JWK is a public key in the JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Signer signs and verifies tokens with one P-256 key.
+type Signer struct {
+	key    *ecdsa.PrivateKey
+	kid    string
+	issuer string
+	ttl    time.Duration
+}
+
+func NewSigner(key *ecdsa.PrivateKey, issuer string, ttl time.Duration) *Signer {
+	s := &Signer{key: key, issuer: issuer, ttl: ttl}
+	s.kid = thumbprint(s.jwk())
+	return s
+}
+
+// LoadKey reads a PEM-encoded P-256 private key, in SEC 1 or PKCS #8 form.
+// An empty path generates a key, whose tokens stop verifying when the
+// service restarts.
+func LoadKey(path string) (*ecdsa.PrivateKey, error) {
+	if path == "" {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return checkCurve(path, key)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ECDSA key", path)
+	}
+	return checkCurve(path, key)
+}
+
+func checkCurve(path string, key *ecdsa.PrivateKey) (*ecdsa.PrivateKey, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%s: ES256 needs a P-256 key", path)
+	}
+	return key, nil
+}
+
+// TTL is how long issued tokens are valid.
+func (s *Signer) TTL() time.Duration { return s.ttl }
+
+// Issue signs c, filling in its issuer, issue time and ID, and returns the
+// token and when it expires: after the signer's TTL, or at c.ExpiresAt if
+// that is sooner.
+func (s *Signer) Issue(c Claims) (string, time.Time, error) {
+	now := time.Now().UTC()
+	c.Issuer = s.issuer
+	c.IssuedAt = now.Unix()
+	if exp := now.Add(s.ttl).Unix(); c.ExpiresAt == 0 || c.ExpiresAt > exp {
+		c.ExpiresAt = exp
+	}
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	c.ID = hex.EncodeToString(id[:])
+
+	h, err := json.Marshal(header{Alg: "ES256", Typ: "JWT", Kid: s.kid})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signingInput := encode(h) + "." + encode(p)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	raw := make([]byte, 64)
+	r.FillBytes(raw[:32])
+	sig.FillBytes(raw[32:])
+	return signingInput + "." + encode(raw), time.Unix(c.ExpiresAt, 0).UTC(), nil
+}
+
+// Verify checks the signature, issuer and expiry of a token and returns
+// its claims.
+func (s *Signer) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil || h.Alg != "ES256" || h.Kid != s.kid {
+		return nil, ErrInvalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(raw) != 64 {
+		return nil, ErrInvalid
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, sig := new(big.Int).SetBytes(raw[:32]), new(big.Int).SetBytes(raw[32:])
+	if !ecdsa.Verify(&s.key.PublicKey, digest[:], r, sig) {
+		return nil, ErrInvalid
+	}
+	var c Claims
+	if err := decodeJSON(parts[1], &c); err != nil || c.Issuer != s.issuer {
+		return nil, ErrInvalid
+	}
+	if time.Now().Unix() >= c.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return &c, nil
+}
+
+// JWKS returns the key set verifiers fetch.
+func (s *Signer) JWKS() JWKS {
+	return JWKS{Keys: []JWK{s.jwk()}}
+}
+
+func (s *Signer) jwk() JWK {
+	// The uncompressed point is 0x04 || X || Y.
+	point, _ := s.key.PublicKey.ECDH()
+	b := point.Bytes()
+	return JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   encode(b[1:33]),
+		Y:   encode(b[33:]),
+		Kid: s.kid,
+		Use: "sig",
+		Alg: "ES256",
+	}
+}
+
+// thumbprint is the RFC 7638 thumbprint of k, used as its key ID.
+func thumbprint(k JWK) string {
+	canonical := fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Crv, k.Kty, k.X, k.Y)
+	sum := sha256.Sum256([]byte(canonical))
+	return encode(sum[:])
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJSON(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/parlakisik/agent-exchange/aex-identity/internal/httpapi"
 	"github.com/parlakisik/agent-exchange/aex-identity/internal/service"
 	"github.com/parlakisik/agent-exchange/aex-identity/internal/store"
+	"github.com/parlakisik/agent-exchange/aex-identity/internal/token"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -30,7 +31,15 @@ func main() {
 			log.Fatal(err)
 		}
 		mongoClient = c
-		ms := store.NewMongoStore(c, cfg.MongoDatabase, cfg.MongoCollectionTenants, cfg.MongoCollectionAPIKeys)
+		ms := store.NewMongoStore(c, cfg.MongoDatabase, store.Collections{
+			Tenants:         cfg.MongoCollectionTenants,
+			Organizations:   cfg.MongoCollectionOrgs,
+			APIKeys:         cfg.MongoCollectionAPIKeys,
+			Users:           cfg.MongoCollectionUsers,
+			Usage:           cfg.MongoCollectionUsage,
+			AuditLog:        cfg.MongoCollectionAudit,
+			ServiceAccounts: cfg.MongoCollectionSvcAccs,
+		})
 		if err := ms.EnsureIndexes(ctx); err != nil {
 			log.Printf("mongo index creation failed: %v", err)
 		}
@@ -42,6 +51,30 @@ func main() {
 	}
 
 	svc := service.New(st)
+	signingKey, err := token.LoadKey(cfg.JWTSigningKeyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.JWTSigningKeyFile == "" {
+		log.Printf("token signing key generated (set JWT_SIGNING_KEY_FILE to keep tokens valid across restarts)")
+	}
+	svc.SetTokenSigner(token.NewSigner(signingKey, cfg.JWTIssuer, cfg.JWTTTL))
+	svc.SetRotationGrace(cfg.APIKeyRotationGrace)
+	svc.SetAdminToken(cfg.AdminToken)
+	if cfg.AdminToken == "" {
+		log.Printf("admin routes are disabled (set ADMIN_TOKEN to manage service accounts)")
+	}
+	svc.SetValidationMaxAge(cfg.ValidationMaxAge)
+	svc.SetValidationRateLimit(cfg.ValidationRatePerSecond, cfg.ValidationBurst)
+	if cfg.EventsURL != "" {
+		svc.SetEventsURL(cfg.EventsURL)
+	}
+	if len(cfg.LifecycleWebhookURLs) > 0 {
+		if cfg.WebhookSecret == "" {
+			log.Printf("lifecycle webhooks are unsigned (set WEBHOOK_SECRET so receivers can verify them)")
+		}
+		svc.SetLifecycleWebhooks(cfg.LifecycleWebhookURLs, cfg.WebhookSecret)
+	}
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      httpapi.NewRouter(svc),
@@ -50,6 +83,10 @@ func main() {
 		IdleTimeout:  cfg.IdleTimeout,
 	}
 
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	go svc.RunKeyExpiryMonitor(monitorCtx, cfg.APIKeyExpiryCheckInterval, cfg.APIKeyExpiryWarning)
+
 	go func() {
 		log.Printf("listening on :%s", cfg.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -60,6 +97,7 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
+	stopMonitor()
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -2,14 +2,34 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	prhttp "github.com/parlakisik/agent-exchange/aex-provider-registry/internal/httpapi"
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
 	prsvc "github.com/parlakisik/agent-exchange/aex-provider-registry/internal/service"
 	prstore "github.com/parlakisik/agent-exchange/aex-provider-registry/internal/store"
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/webhook"
 )
 
 func TestRegisterSubscribeAndInternalLookup(t *testing.T) {
@@ -64,6 +84,30 @@ func TestRegisterSubscribeAndInternalLookup(t *testing.T) {
 	if resp2.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp2.StatusCode)
 	}
+	var subOut struct {
+		SubscriptionID string `json:"subscription_id"`
+		WebhookSecret  string `json:"webhook_secret"`
+	}
+	_ = json.NewDecoder(resp2.Body).Decode(&subOut)
+	if subOut.WebhookSecret != "whsec_test" {
+		t.Fatalf("expected the webhook secret in the create response, got %q", subOut.WebhookSecret)
+	}
+
+	// The secret is returned once; reading the subscription never shows it.
+	getReq, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/subscriptions/"+subOut.SubscriptionID, nil)
+	getReq.Header.Set("Authorization", "Bearer "+regOut.APIKey)
+	getResp, err := http.DefaultClient.Do(getReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(getResp.Body)
+	_ = getResp.Body.Close()
+	if getResp.StatusCode != 200 {
+		t.Fatalf("get subscription: expected 200, got %d", getResp.StatusCode)
+	}
+	if bytes.Contains(got, []byte("webhook_secret")) || bytes.Contains(got, []byte("whsec_test")) {
+		t.Fatalf("subscription leaked its webhook secret: %s", got)
+	}
 
 	// Internal lookup should return the provider for travel.booking
 	resp3, err := http.Get(ts.URL + "/internal/v1/providers/subscribed?category=travel.booking")
@@ -84,3 +128,1922 @@ func TestRegisterSubscribeAndInternalLookup(t *testing.T) {
 		t.Fatalf("expected provider %s, got %+v", regOut.ProviderID, out.Providers)
 	}
 }
+
+func TestEndpointVerificationChallenge(t *testing.T) {
+	var secretHash, providerID, apiKey string
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ch struct {
+			Type  string `json:"type"`
+			Nonce string `json:"nonce"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&ch)
+		if ch.Type != prsvc.ChallengeType {
+			http.Error(w, "unexpected", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"nonce":     ch.Nonce,
+			"signature": prsvc.ChallengeSignature(secretHash, ch.Nonce),
+		})
+	}))
+	t.Cleanup(agent.Close)
+
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AllowHTTP: true})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	register := func(endpoint string) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"name": "Verified Provider", "endpoint": endpoint})
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != 200 {
+			t.Fatalf("register: expected 200, got %d", resp.StatusCode)
+		}
+		var out struct {
+			ProviderID string `json:"provider_id"`
+			APIKey     string `json:"api_key"`
+			APISecret  string `json:"api_secret"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		if secretHash == "" {
+			sum := sha256.Sum256([]byte(out.APISecret))
+			secretHash = hex.EncodeToString(sum[:])
+			providerID, apiKey = out.ProviderID, out.APIKey
+		}
+	}
+	verified := func() (bool, string) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/providers/" + providerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			EndpointVerified     bool `json:"endpoint_verified"`
+			EndpointVerification struct {
+				Status string `json:"status"`
+			} `json:"endpoint_verification"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.EndpointVerified, out.EndpointVerification.Status
+	}
+	verify := func(key string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/providers/"+providerID+"/verify-endpoint", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	register(agent.URL + "/a2a")
+	if ok, status := verified(); ok || status != "PENDING" {
+		t.Fatalf("expected unverified PENDING after registration, got %v %s", ok, status)
+	}
+
+	if code := verify("aex_pk_live_wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong key, got %d", code)
+	}
+	if code := verify(apiKey); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if ok, status := verified(); !ok || status != "VERIFIED" {
+		t.Fatalf("expected VERIFIED, got %v %s", ok, status)
+	}
+
+	// Changing the endpoint drops the verified flag until re-verified.
+	register(agent.URL + "/v2/a2a")
+	if ok, status := verified(); ok || status != "PENDING" {
+		t.Fatalf("expected reset after endpoint change, got %v %s", ok, status)
+	}
+
+	// A wrong signature fails verification.
+	secretHash = "not-the-secret"
+	if code := verify(apiKey); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if ok, status := verified(); ok || status != "FAILED" {
+		t.Fatalf("expected FAILED, got %v %s", ok, status)
+	}
+}
+
+func TestHeartbeatAndLivenessChecker(t *testing.T) {
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{
+		HeartbeatStaleAfter:   time.Minute,
+		HeartbeatOfflineAfter: 5 * time.Minute,
+	})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{"name": "Live Provider", "endpoint": "https://live.example.com/a2a"})
+	resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reg struct {
+		ProviderID string `json:"provider_id"`
+		APIKey     string `json:"api_key"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reg)
+	_ = resp.Body.Close()
+
+	sb, _ := json.Marshal(map[string]any{"provider_id": reg.ProviderID, "categories": []string{"nlp.*"}})
+	resp, err = http.Post(ts.URL+"/v1/subscriptions", "application/json", bytes.NewReader(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	heartbeat := func(key string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/providers/"+reg.ProviderID+"/heartbeat", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	liveness := func() string {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/providers/" + reg.ProviderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Liveness string `json:"liveness"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.Liveness
+	}
+	subscribed := func() int {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/internal/v1/providers/subscribed?category=nlp.summarize")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Providers []map[string]any `json:"providers"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return len(out.Providers)
+	}
+
+	if code := heartbeat("aex_pk_live_wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if code := heartbeat(reg.APIKey); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := liveness(); got != "ONLINE" {
+		t.Fatalf("expected ONLINE, got %q", got)
+	}
+
+	ctx := context.Background()
+	if n := svc.CheckLiveness(ctx, time.Now().UTC().Add(2*time.Minute)); n != 1 {
+		t.Fatalf("expected 1 change, got %d", n)
+	}
+	if got := liveness(); got != "STALE" {
+		t.Fatalf("expected STALE, got %q", got)
+	}
+	if n := subscribed(); n != 1 {
+		t.Fatalf("stale providers should still be notified, got %d", n)
+	}
+
+	svc.CheckLiveness(ctx, time.Now().UTC().Add(10*time.Minute))
+	if got := liveness(); got != "OFFLINE" {
+		t.Fatalf("expected OFFLINE, got %q", got)
+	}
+	if n := subscribed(); n != 0 {
+		t.Fatalf("offline providers should be skipped, got %d", n)
+	}
+
+	if code := heartbeat(reg.APIKey); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := liveness(); got != "ONLINE" {
+		t.Fatalf("expected ONLINE after heartbeat, got %q", got)
+	}
+}
+
+func TestAPIKeyRotation(t *testing.T) {
+	svc := prsvc.New(prstore.NewMemoryStore())
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{"name": "Rotating Provider", "endpoint": "https://rotate.example.com/a2a"})
+	resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reg struct {
+		ProviderID string `json:"provider_id"`
+		APIKey     string `json:"api_key"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reg)
+	_ = resp.Body.Close()
+
+	do := func(method, path, key string, body any) *http.Response {
+		t.Helper()
+		var rd *bytes.Reader
+		if body != nil {
+			bb, _ := json.Marshal(body)
+			rd = bytes.NewReader(bb)
+		} else {
+			rd = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, rd)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	valid := func(key string) bool {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/internal/v1/providers/validate-key?api_key=" + key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Valid bool `json:"valid"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.Valid
+	}
+
+	base := "/v1/providers/" + reg.ProviderID + "/api-keys"
+	resp = do(http.MethodPost, base, reg.APIKey, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	var created struct {
+		KeyID  string `json:"key_id"`
+		APIKey string `json:"api_key"`
+		Keys   []struct {
+			KeyID string `json:"key_id"`
+		} `json:"keys"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	_ = resp.Body.Close()
+	if created.APIKey == "" || len(created.Keys) != 2 {
+		t.Fatalf("expected a new key alongside the original, got %+v", created)
+	}
+
+	// Both keys are valid during the overlap.
+	if !valid(reg.APIKey) || !valid(created.APIKey) {
+		t.Fatalf("expected both keys to be valid")
+	}
+
+	oldKeyID := created.Keys[0].KeyID
+	resp = do(http.MethodDelete, base+"/"+oldKeyID, created.APIKey, nil)
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 on revoke, got %d", resp.StatusCode)
+	}
+	if valid(reg.APIKey) {
+		t.Fatalf("revoked key should no longer validate")
+	}
+	if !valid(created.APIKey) {
+		t.Fatalf("new key should still validate")
+	}
+
+	resp = do(http.MethodDelete, base+"/"+created.KeyID, created.APIKey, nil)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 revoking last key, got %d", resp.StatusCode)
+	}
+
+	// expire_existing_in: 0s retires the current key as soon as the new one exists.
+	resp = do(http.MethodPost, base, created.APIKey, map[string]any{"expire_existing_in": "0s"})
+	var third struct {
+		APIKey string `json:"api_key"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&third)
+	_ = resp.Body.Close()
+	if valid(created.APIKey) || !valid(third.APIKey) {
+		t.Fatalf("expected only the newest key to be valid")
+	}
+}
+
+func TestListProvidersFiltersAndPaginates(t *testing.T) {
+	svc := prsvc.New(prstore.NewMemoryStore())
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	for _, p := range []struct {
+		name string
+		caps []string
+	}{
+		{"Summarizer One", []string{"nlp.summarize"}},
+		{"Summarizer Two", []string{"nlp.summarize", "nlp.translate"}},
+		{"Flight Booker", []string{"travel.booking"}},
+	} {
+		b, _ := json.Marshal(map[string]any{"name": p.name, "endpoint": "https://p.example.com/a2a", "capabilities": p.caps})
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	type page struct {
+		Providers []struct {
+			Name string `json:"name"`
+		} `json:"providers"`
+		NextCursor string `json:"next_cursor"`
+	}
+	list := func(query string) page {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/providers?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != 200 {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var out page
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	first := list("capability=nlp.summarize&limit=1")
+	if len(first.Providers) != 1 || first.NextCursor == "" {
+		t.Fatalf("expected one result and a cursor, got %+v", first)
+	}
+	second := list("capability=nlp.summarize&limit=1&cursor=" + first.NextCursor)
+	if len(second.Providers) != 1 || second.NextCursor != "" {
+		t.Fatalf("expected last page, got %+v", second)
+	}
+	if first.Providers[0].Name == second.Providers[0].Name {
+		t.Fatalf("pages should not overlap")
+	}
+
+	if got := list("q=flight"); len(got.Providers) != 1 || got.Providers[0].Name != "Flight Booker" {
+		t.Fatalf("expected text match on Flight Booker, got %+v", got)
+	}
+	if got := list("status=SUSPENDED"); len(got.Providers) != 0 {
+		t.Fatalf("expected no suspended providers, got %+v", got)
+	}
+	if got := list("tier=UNVERIFIED"); len(got.Providers) != 3 {
+		t.Fatalf("expected 3 unverified providers, got %d", len(got.Providers))
+	}
+}
+
+func TestSuspendReactivateDeactivate(t *testing.T) {
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AdminToken: "admin_secret"})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{"name": "Noisy Provider", "endpoint": "https://noisy.example.com/a2a"})
+	resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reg struct {
+		ProviderID string `json:"provider_id"`
+		APIKey     string `json:"api_key"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reg)
+	_ = resp.Body.Close()
+
+	admin := func(action, token, reason string) int {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"reason": reason})
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/admin/v1/providers/"+reg.ProviderID+"/"+action, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	valid := func() bool {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/internal/v1/providers/validate-key?api_key=" + reg.APIKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Valid bool `json:"valid"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.Valid
+	}
+
+	if code := admin("suspend", "wrong", "spam"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without admin token, got %d", code)
+	}
+	if code := admin("suspend", "admin_secret", ""); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without reason, got %d", code)
+	}
+	if code := admin("suspend", "admin_secret", "bid spam"); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if valid() {
+		t.Fatalf("suspended provider key should not validate")
+	}
+	if code := admin("suspend", "admin_secret", "again"); code != http.StatusConflict {
+		t.Fatalf("expected 409 suspending twice, got %d", code)
+	}
+	if code := admin("reactivate", "admin_secret", "appeal accepted"); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if !valid() {
+		t.Fatalf("reactivated provider key should validate")
+	}
+	if code := admin("deactivate", "admin_secret", "requested by owner"); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/providers/" + reg.ProviderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var out struct {
+		Status       string `json:"status"`
+		StatusReason string `json:"status_reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if out.Status != "INACTIVE" || out.StatusReason != "requested by owner" {
+		t.Fatalf("unexpected status %+v", out)
+	}
+
+	// Without a configured admin token the admin routes are disabled.
+	disabled := httptest.NewServer(prhttp.NewRouter(prsvc.New(prstore.NewMemoryStore())))
+	t.Cleanup(disabled.Close)
+	req, _ := http.NewRequest(http.MethodPost, disabled.URL+"/admin/v1/providers/"+reg.ProviderID+"/suspend", strings.NewReader(`{"reason":"spam"}`))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a configured admin token, got %d", resp.StatusCode)
+	}
+}
+
+func TestSubscriptionManagement(t *testing.T) {
+	svc := prsvc.New(prstore.NewMemoryStore())
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{
+		"name":        "Sub Provider",
+		"endpoint":    "https://sub.example.com/a2a",
+		"bid_webhook": "https://sub.example.com/aex/work",
+	})
+	resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reg struct {
+		ProviderID string `json:"provider_id"`
+		APIKey     string `json:"api_key"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reg)
+	_ = resp.Body.Close()
+
+	sb, _ := json.Marshal(map[string]any{
+		"provider_id": reg.ProviderID,
+		"categories":  []string{"nlp.*"},
+		"delivery":    map[string]any{"method": "poll"},
+	})
+	resp, err = http.Post(ts.URL+"/v1/subscriptions", "application/json", bytes.NewReader(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sub struct {
+		SubscriptionID string `json:"subscription_id"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&sub)
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 || sub.SubscriptionID == "" {
+		t.Fatalf("create subscription: status %d", resp.StatusCode)
+	}
+
+	do := func(method, path string, body any) int {
+		t.Helper()
+		var rd *bytes.Reader
+		if body != nil {
+			bb, _ := json.Marshal(body)
+			rd = bytes.NewReader(bb)
+		} else {
+			rd = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, rd)
+		req.Header.Set("Authorization", "Bearer "+reg.APIKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	type hit struct {
+		DeliveryMethod string `json:"delivery_method"`
+		WebhookURL     string `json:"webhook_url"`
+	}
+	subscribed := func(category string) []hit {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/internal/v1/providers/subscribed?category=" + category)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Providers []hit `json:"providers"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.Providers
+	}
+
+	// Prefix wildcards match at any depth, and polling never falls back to
+	// the provider's bid webhook.
+	hits := subscribed("nlp.text.summarize")
+	if len(hits) != 1 || hits[0].DeliveryMethod != "polling" || hits[0].WebhookURL != "" {
+		t.Fatalf("expected one polling hit, got %+v", hits)
+	}
+	if len(subscribed("nlpx.summarize")) != 0 {
+		t.Fatalf("nlp.* should not match nlpx.summarize")
+	}
+
+	path := "/v1/subscriptions/" + sub.SubscriptionID
+	if code := do(http.MethodPost, path+"/pause", nil); code != 200 {
+		t.Fatalf("pause: expected 200, got %d", code)
+	}
+	if len(subscribed("nlp.summarize")) != 0 {
+		t.Fatalf("paused subscription should not match")
+	}
+	if code := do(http.MethodPost, path+"/resume", nil); code != 200 {
+		t.Fatalf("resume: expected 200, got %d", code)
+	}
+
+	update := map[string]any{
+		"categories": []string{"travel.*"},
+		"delivery":   map[string]any{"method": "webhook"},
+	}
+	if code := do(http.MethodPatch, path, update); code != 200 {
+		t.Fatalf("update: expected 200, got %d", code)
+	}
+	if len(subscribed("nlp.summarize")) != 0 {
+		t.Fatalf("updated categories should no longer match nlp")
+	}
+	hits = subscribed("travel.booking")
+	if len(hits) != 1 || hits[0].WebhookURL != "https://sub.example.com/aex/work" {
+		t.Fatalf("expected webhook hit with provider default URL, got %+v", hits)
+	}
+
+	if code := do(http.MethodPatch, path, map[string]any{"delivery": map[string]any{"method": "carrier-pigeon"}}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown delivery method, got %d", code)
+	}
+	if code := do(http.MethodDelete, path, nil); code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", code)
+	}
+	if code := do(http.MethodGet, path, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", code)
+	}
+}
+
+func TestListSubscriptionsFiltersAndPaginates(t *testing.T) {
+	svc := prsvc.New(prstore.NewMemoryStore())
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{
+		"name":        "List Provider",
+		"endpoint":    "https://list.example.com/a2a",
+		"bid_webhook": "https://list.example.com/aex/work",
+	})
+	resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reg struct {
+		ProviderID string `json:"provider_id"`
+		APIKey     string `json:"api_key"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reg)
+	_ = resp.Body.Close()
+
+	subIDs := make([]string, 0, 3)
+	for _, cats := range [][]string{{"nlp.*"}, {"nlp.summarize"}, {"travel.*"}} {
+		sb, _ := json.Marshal(map[string]any{
+			"provider_id": reg.ProviderID,
+			"categories":  cats,
+			"delivery":    map[string]any{"method": "poll"},
+		})
+		resp, err := http.Post(ts.URL+"/v1/subscriptions", "application/json", bytes.NewReader(sb))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sub struct {
+			SubscriptionID string `json:"subscription_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&sub)
+		_ = resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("create subscription: status %d", resp.StatusCode)
+		}
+		subIDs = append(subIDs, sub.SubscriptionID)
+	}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/subscriptions/"+subIDs[2]+"/pause", nil)
+	req.Header.Set("Authorization", "Bearer "+reg.APIKey)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	type page struct {
+		Subscriptions []struct {
+			SubscriptionID string `json:"subscription_id"`
+		} `json:"subscriptions"`
+		NextCursor string `json:"next_cursor"`
+	}
+	list := func(query string) (page, int) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/subscriptions?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out page
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out, resp.StatusCode
+	}
+
+	// Category filters match wildcard and literal subscriptions alike.
+	got, _ := list("category=nlp.summarize")
+	if len(got.Subscriptions) != 2 {
+		t.Fatalf("expected 2 nlp.summarize subscriptions, got %+v", got.Subscriptions)
+	}
+	got, _ = list("category=nlp.translate")
+	if len(got.Subscriptions) != 1 {
+		t.Fatalf("expected only the wildcard subscription, got %+v", got.Subscriptions)
+	}
+	got, _ = list("status=paused")
+	if len(got.Subscriptions) != 1 || got.Subscriptions[0].SubscriptionID != subIDs[2] {
+		t.Fatalf("expected the paused subscription, got %+v", got.Subscriptions)
+	}
+	if _, code := list("status=bogus"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown status, got %d", code)
+	}
+
+	seen := map[string]bool{}
+	cursor := ""
+	for range 4 {
+		got, _ = list("provider_id=" + reg.ProviderID + "&limit=2&cursor=" + cursor)
+		for _, s := range got.Subscriptions {
+			seen[s.SubscriptionID] = true
+		}
+		if cursor = got.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if len(seen) != 3 || cursor != "" {
+		t.Fatalf("paging should visit all 3 subscriptions once, got %v", seen)
+	}
+}
+
+func TestWorkAvailableWebhooks(t *testing.T) {
+	received := make(chan []byte, 4)
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("whsec_test"))
+		mac.Write(body)
+		if r.Header.Get(webhook.SignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		received <- body
+	}))
+	t.Cleanup(agent.Close)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{
+		AllowHTTP:          true,
+		WebhookMaxAttempts: 2,
+		WebhookBackoff:     10 * time.Millisecond,
+	})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	subscribe := func(name, webhookURL string) (string, string) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"name": name, "endpoint": agent.URL + "/a2a"})
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reg struct {
+			ProviderID string `json:"provider_id"`
+			APIKey     string `json:"api_key"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&reg)
+		_ = resp.Body.Close()
+
+		sb, _ := json.Marshal(map[string]any{
+			"provider_id": reg.ProviderID,
+			"categories":  []string{"nlp.*"},
+			"delivery":    map[string]any{"method": "webhook", "webhook_url": webhookURL, "webhook_secret": "whsec_test"},
+		})
+		resp, err = http.Post(ts.URL+"/v1/subscriptions", "application/json", bytes.NewReader(sb))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sub struct {
+			SubscriptionID string `json:"subscription_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&sub)
+		_ = resp.Body.Close()
+		return sub.SubscriptionID, reg.APIKey
+	}
+	okSub, okKey := subscribe("Webhook Provider", agent.URL+"/aex/work")
+	downSub, downKey := subscribe("Down Provider", down.URL+"/aex/work")
+
+	wb, _ := json.Marshal(map[string]any{"work_id": "work_1", "category": "nlp.summarize"})
+	resp, err := http.Post(ts.URL+"/internal/v1/work/available", "application/json", bytes.NewReader(wb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Notified []string `json:"notified_subscriptions"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	_ = resp.Body.Close()
+	if len(out.Notified) != 2 {
+		t.Fatalf("expected 2 notified subscriptions, got %v", out.Notified)
+	}
+
+	select {
+	case body := <-received:
+		var ev struct {
+			Type string         `json:"type"`
+			Data map[string]any `json:"data"`
+		}
+		_ = json.Unmarshal(body, &ev)
+		if ev.Type != "work.available" || ev.Data["work_id"] != "work_1" {
+			t.Fatalf("unexpected event %s", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	deliveries := func(subID, key string) []webhook.Delivery {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/subscriptions/"+subID+"/deliveries", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Deliveries []webhook.Delivery `json:"deliveries"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.Deliveries
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		okD, downD := deliveries(okSub, okKey), deliveries(downSub, downKey)
+		if len(okD) == 1 && okD[0].Status == webhook.DeliveryDelivered &&
+			len(downD) == 1 && downD[0].Status == webhook.DeliveryFailed && downD[0].Attempts == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected delivery state ok=%+v down=%+v", okD, downD)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRegistrationFetchesAgentCard(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != prsvc.AgentCardPath {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"name":    "Card Agent",
+			"url":     "http://" + r.Host,
+			"version": "1.0.0",
+			"skills": []map[string]any{
+				{"id": "translate", "name": "Translate", "tags": []string{"nlp.translation"}},
+			},
+		})
+	}))
+	t.Cleanup(agent.Close)
+
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AllowHTTP: true, FetchAgentCards: true})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	register := func(name, endpoint string, caps []string) (int, string) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"name": name, "endpoint": endpoint, "capabilities": caps})
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			ProviderID string `json:"provider_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.ProviderID
+	}
+
+	// Capabilities the card does not advertise are rejected.
+	if code, _ := register("Overclaiming Agent", agent.URL+"/a2a", []string{"translate", "travel.booking"}); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unadvertised capability, got %d", code)
+	}
+	// So is an endpoint without an agent card.
+	missing := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(missing.Close)
+	if code, _ := register("Cardless Agent", missing.URL+"/a2a", []string{"translate"}); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 without agent card, got %d", code)
+	}
+
+	code, providerID := register("Card Agent", agent.URL+"/a2a", []string{"translate", "NLP.Translation"})
+	if code != 200 || providerID == "" {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/providers/" + providerID + "/agent-card")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var out struct {
+		SourceURL string `json:"source_url"`
+		AgentCard struct {
+			Name   string `json:"name"`
+			Skills []struct {
+				ID string `json:"id"`
+			} `json:"skills"`
+		} `json:"agent_card"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if out.SourceURL != agent.URL+prsvc.AgentCardPath || out.AgentCard.Name != "Card Agent" || len(out.AgentCard.Skills) != 1 {
+		t.Fatalf("unexpected agent card response: %+v", out)
+	}
+
+	// The fetched skills are searchable.
+	resp2, err := http.Get(ts.URL + "/v1/providers/search?skill_tags=nlp.translation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp2.Body.Close() }()
+	var found struct {
+		Total int `json:"total"`
+	}
+	_ = json.NewDecoder(resp2.Body).Decode(&found)
+	if found.Total != 1 {
+		t.Fatalf("expected fetched skills to be indexed, got %d results", found.Total)
+	}
+
+	resp3, err := http.Get(ts.URL + "/v1/providers/prov_unknown/agent-card")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp3.Body.Close()
+	if resp3.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown provider, got %d", resp3.StatusCode)
+	}
+}
+
+func TestProviderProfileValidation(t *testing.T) {
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{
+		ProfileValidator: func(p model.ProviderProfile) error {
+			if p.MaxConcurrency > 100 {
+				return errors.New("max_concurrency above platform limit")
+			}
+			return nil
+		},
+	})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	register := func(profile map[string]any) (int, string) {
+		t.Helper()
+		req := map[string]any{"name": "Profiled Provider", "endpoint": "https://agent.example.com/a2a"}
+		for k, v := range profile {
+			req[k] = v
+		}
+		b, _ := json.Marshal(req)
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			ProviderID string `json:"provider_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.ProviderID
+	}
+
+	for name, bad := range map[string]map[string]any{
+		"pricing model":   {"pricing": map[string]any{"model": "barter", "amount": 1}},
+		"negative amount": {"pricing": map[string]any{"model": "per_task", "amount": -1}},
+		"currency":        {"pricing": map[string]any{"model": "per_task", "currency": "dollars", "amount": 1}},
+		"region":          {"regions": []string{"us east"}},
+		"concurrency":     {"max_concurrency": -1},
+		"hook":            {"max_concurrency": 500},
+	} {
+		if code, _ := register(bad); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", name, code)
+		}
+	}
+
+	code, providerID := register(map[string]any{
+		"pricing":         map[string]any{"model": "PER_TASK", "amount": 2.5},
+		"regions":         []string{"US-East1", "global", "us-east1"},
+		"max_concurrency": 8,
+		"runtime":         map[string]any{"models": []string{"claude"}, "tools": []string{"web_search"}},
+	})
+	if code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/providers/" + providerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var out struct {
+		Pricing        model.Pricing     `json:"pricing"`
+		Regions        []string          `json:"regions"`
+		MaxConcurrency int               `json:"max_concurrency"`
+		Runtime        model.RuntimeInfo `json:"runtime"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if out.Pricing.Model != model.PricingPerTask || out.Pricing.Currency != "USD" || out.Pricing.Amount != 2.5 {
+		t.Fatalf("unexpected pricing: %+v", out.Pricing)
+	}
+	if len(out.Regions) != 2 || out.Regions[0] != "us-east1" || out.MaxConcurrency != 8 || len(out.Runtime.Tools) != 1 {
+		t.Fatalf("unexpected profile: %+v", out)
+	}
+
+	resp2, err := http.Get(ts.URL + "/v1/providers/schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp2.Body.Close() }()
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.NewDecoder(resp2.Body).Decode(&schema); err != nil || schema.Properties["pricing"] == nil {
+		t.Fatalf("expected profile schema, got %v", err)
+	}
+}
+
+func TestProviderLifecycleEvents(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received = map[string]map[string]any{}
+	)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env struct {
+			EventType string         `json:"event_type"`
+			Source    string         `json:"source"`
+			Data      map[string]any `json:"data"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&env)
+		if env.Source != "aex-provider-registry" {
+			t.Errorf("unexpected source %q", env.Source)
+		}
+		mu.Lock()
+		received[env.EventType] = env.Data
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(sink.Close)
+
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{EventsURL: sink.URL, AdminToken: "admin_secret"})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path, key string, body any) {
+		t.Helper()
+		bb, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(bb))
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			t.Fatalf("%s %s: got %d", method, path, resp.StatusCode)
+		}
+	}
+
+	reg := map[string]any{"name": "Evented Provider", "endpoint": "https://events.example.com/a2a"}
+	b, _ := json.Marshal(reg)
+	resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		ProviderID string `json:"provider_id"`
+		APIKey     string `json:"api_key"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	_ = resp.Body.Close()
+
+	reg["endpoint"] = "https://events.example.com/v2/a2a"
+	do(http.MethodPost, "/v1/providers", "", reg)
+	do(http.MethodPost, "/v1/providers/"+out.ProviderID+"/api-keys", out.APIKey, map[string]any{})
+	do(http.MethodPost, "/admin/v1/providers/"+out.ProviderID+"/suspend", "admin_secret", map[string]any{"reason": "abuse"})
+	do(http.MethodPost, "/admin/v1/providers/"+out.ProviderID+"/reactivate", "admin_secret", map[string]any{"reason": "resolved"})
+
+	seen := func(typ string) map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return received[typ]
+	}
+	types := []string{"provider.registered", "provider.updated", "provider.key_rotated", "provider.suspended", "provider.status_changed"}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		all := true
+		for _, typ := range types {
+			if seen(typ) == nil {
+				all = false
+			}
+		}
+		if all {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("missing events, got %v", received)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	for _, typ := range types {
+		if seen(typ)["provider_id"] != out.ProviderID {
+			t.Fatalf("%s: unexpected provider_id %v", typ, seen(typ)["provider_id"])
+		}
+	}
+	if seen("provider.updated")["endpoint_changed"] != true {
+		t.Fatalf("expected endpoint_changed on update, got %v", seen("provider.updated"))
+	}
+	if seen("provider.suspended")["reason"] != "abuse" || seen("provider.key_rotated")["action"] != "created" {
+		t.Fatalf("unexpected event data suspended=%v key_rotated=%v", seen("provider.suspended"), seen("provider.key_rotated"))
+	}
+}
+
+func TestProviderSelfService(t *testing.T) {
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AdminToken: "admin_secret"})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{
+		"name":         "Self Service Provider",
+		"endpoint":     "https://self.example.com/a2a",
+		"capabilities": []string{"nlp.summarize"},
+	})
+	resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reg struct {
+		ProviderID string `json:"provider_id"`
+		APIKey     string `json:"api_key"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reg)
+	_ = resp.Body.Close()
+
+	type me struct {
+		ProviderID           string         `json:"provider_id"`
+		Name                 string         `json:"name"`
+		Endpoint             string         `json:"endpoint"`
+		Capabilities         []string       `json:"capabilities"`
+		Metadata             map[string]any `json:"metadata"`
+		MaxConcurrency       int            `json:"max_concurrency"`
+		EndpointVerification struct {
+			Endpoint string `json:"endpoint"`
+		} `json:"endpoint_verification"`
+	}
+	do := func(method, key string, body any) (int, me) {
+		t.Helper()
+		var rd io.Reader = http.NoBody
+		if body != nil {
+			bb, _ := json.Marshal(body)
+			rd = bytes.NewReader(bb)
+		}
+		req, _ := http.NewRequest(method, ts.URL+"/v1/providers/me", rd)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out me
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if code, _ := do(http.MethodGet, "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without key, got %d", code)
+	}
+	if code, _ := do(http.MethodGet, "aex_pk_live_wrong", nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong key, got %d", code)
+	}
+	code, got := do(http.MethodGet, reg.APIKey, nil)
+	if code != 200 || got.ProviderID != reg.ProviderID || got.Name != "Self Service Provider" {
+		t.Fatalf("unexpected GET /me: %d %+v", code, got)
+	}
+
+	if code, _ := do(http.MethodPatch, reg.APIKey, map[string]any{"endpoint": "not a url"}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid endpoint, got %d", code)
+	}
+	code, got = do(http.MethodPatch, reg.APIKey, map[string]any{
+		"endpoint":        "https://self.example.com/v2/a2a",
+		"capabilities":    []string{"nlp.summarize", "nlp.translate"},
+		"metadata":        map[string]any{"region": "eu"},
+		"max_concurrency": 4,
+	})
+	if code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got.Endpoint != "https://self.example.com/v2/a2a" || len(got.Capabilities) != 2 || got.Metadata["region"] != "eu" || got.MaxConcurrency != 4 {
+		t.Fatalf("unexpected PATCH /me result: %+v", got)
+	}
+	if got.EndpointVerification.Endpoint != got.Endpoint {
+		t.Fatalf("expected endpoint verification reset for new endpoint, got %+v", got.EndpointVerification)
+	}
+
+	// Omitted fields are left alone.
+	_, got = do(http.MethodPatch, reg.APIKey, map[string]any{"description": "updated"})
+	if got.Endpoint != "https://self.example.com/v2/a2a" || len(got.Capabilities) != 2 {
+		t.Fatalf("expected unchanged endpoint and capabilities, got %+v", got)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/admin/v1/providers/"+reg.ProviderID+"/suspend", bytes.NewReader([]byte(`{"reason":"abuse"}`)))
+	req.Header.Set("Authorization", "Bearer admin_secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if code, _ := do(http.MethodPatch, reg.APIKey, map[string]any{"description": "again"}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for suspended provider, got %d", code)
+	}
+}
+
+func TestProviderLimits(t *testing.T) {
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{
+		AdminToken:    "admin-secret",
+		DefaultLimits: model.ProviderLimits{MaxBidsPerMinute: 60, MaxConcurrentContracts: 10},
+	})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{"name": "Noisy Provider", "endpoint": "https://noisy.example.com/a2a"})
+	resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reg struct {
+		ProviderID string `json:"provider_id"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reg)
+	_ = resp.Body.Close()
+
+	type limits struct {
+		MaxBidsPerMinute       int    `json:"max_bids_per_minute"`
+		MaxConcurrentContracts int    `json:"max_concurrent_contracts"`
+		Source                 string `json:"source"`
+	}
+	get := func() limits {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/internal/v1/providers/" + reg.ProviderID + "/limits")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != 200 {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var out limits
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+	admin := func(method, token string, body any) int {
+		t.Helper()
+		bb, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+"/admin/v1/providers/"+reg.ProviderID+"/limits", bytes.NewReader(bb))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := get(); got.MaxBidsPerMinute != 60 || got.MaxConcurrentContracts != 10 || got.Source != "default" {
+		t.Fatalf("unexpected default limits: %+v", got)
+	}
+	if code := admin(http.MethodPut, "wrong", map[string]any{"max_bids_per_minute": 5}); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if code := admin(http.MethodPut, "admin-secret", map[string]any{"max_bids_per_minute": -1}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative limit, got %d", code)
+	}
+	if code := admin(http.MethodPut, "admin-secret", map[string]any{"max_bids_per_minute": 5, "max_concurrent_contracts": 1}); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := get(); got.MaxBidsPerMinute != 5 || got.MaxConcurrentContracts != 1 || got.Source != "provider" {
+		t.Fatalf("unexpected override: %+v", got)
+	}
+	if code := admin(http.MethodDelete, "admin-secret", nil); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := get(); got.Source != "default" {
+		t.Fatalf("expected defaults after clearing, got %+v", got)
+	}
+
+	resp, err = http.Get(ts.URL + "/internal/v1/providers/prov_unknown/limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+type fakeTXTResolver map[string][]string
+
+func (f fakeTXTResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if txts, ok := f[name]; ok {
+		return txts, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestDomainOwnershipVerification(t *testing.T) {
+	var wellKnownToken string
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != prsvc.DomainWellKnownPath || wellKnownToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, wellKnownToken+"\n")
+	}))
+	t.Cleanup(agent.Close)
+
+	resolver := fakeTXTResolver{}
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AllowHTTP: true, VerifyDomains: true, Resolver: resolver})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	type registration struct {
+		ProviderID         string                               `json:"provider_id"`
+		APIKey             string                               `json:"api_key"`
+		Status             string                               `json:"status"`
+		DomainVerification model.DomainVerificationInstructions `json:"domain_verification"`
+	}
+	register := func(name, endpoint string) registration {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"name": name, "endpoint": endpoint})
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out registration
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+	type verification struct {
+		Status             string                   `json:"status"`
+		DomainVerification model.DomainVerification `json:"domain_verification"`
+	}
+	verify := func(reg registration) verification {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/providers/"+reg.ProviderID+"/verify-domain", nil)
+		req.Header.Set("Authorization", "Bearer "+reg.APIKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != 200 {
+			t.Fatalf("verify-domain: expected 200, got %d", resp.StatusCode)
+		}
+		var out verification
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	reg := register("Well Known Provider", agent.URL+"/a2a")
+	if reg.Status != "PENDING_VERIFICATION" || reg.DomainVerification.Token == "" || reg.DomainVerification.WellKnownURL != agent.URL+prsvc.DomainWellKnownPath {
+		t.Fatalf("expected pending registration with instructions, got %+v", reg)
+	}
+
+	got := verify(reg)
+	if got.Status != "PENDING_VERIFICATION" || got.DomainVerification.Status != "FAILED" || len(got.DomainVerification.Artifacts) != 2 {
+		t.Fatalf("expected failed check with two artifacts, got %+v", got)
+	}
+
+	wellKnownToken = reg.DomainVerification.Token
+	got = verify(reg)
+	if got.Status != "ACTIVE" || got.DomainVerification.Status != "VERIFIED" || got.DomainVerification.Method != model.DomainMethodWellKnown {
+		t.Fatalf("expected ACTIVE after well-known verification, got %+v", got)
+	}
+	last := got.DomainVerification.Artifacts[len(got.DomainVerification.Artifacts)-1]
+	if !last.Verified || last.Location != reg.DomainVerification.WellKnownURL || len(last.Observed) != 1 || last.Observed[0] != wellKnownToken {
+		t.Fatalf("unexpected audit artifact: %+v", last)
+	}
+
+	// Moving the endpoint to another host requires verifying again.
+	reg2 := register("Well Known Provider", "https://moved.example.com/a2a")
+	if reg2.Status != "PENDING_VERIFICATION" || reg2.DomainVerification.Domain != "moved.example.com" {
+		t.Fatalf("expected re-verification after host change, got %+v", reg2)
+	}
+	resolver[reg2.DomainVerification.DNSRecordName] = []string{"unrelated", reg2.DomainVerification.DNSRecordValue}
+	got = verify(registration{ProviderID: reg.ProviderID, APIKey: reg.APIKey})
+	if got.Status != "ACTIVE" || got.DomainVerification.Method != model.DomainMethodDNS {
+		t.Fatalf("expected ACTIVE after DNS verification, got %+v", got)
+	}
+}
+
+func TestCapabilityTaxonomy(t *testing.T) {
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AllowHTTP: true, StrictTaxonomy: true})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Get(ts.URL + "/v1/capabilities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tax struct {
+		Version string   `json:"version"`
+		Strict  bool     `json:"strict"`
+		IDs     []string `json:"ids"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&tax)
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 || !tax.Strict || tax.Version == "" || !slices.Contains(tax.IDs, "nlp.summarize") {
+		t.Fatalf("unexpected taxonomy: status=%d %+v", resp.StatusCode, tax)
+	}
+
+	post := func(path string, body any) *http.Response {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		resp, err := http.Post(ts.URL+path, "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp = post("/v1/providers", map[string]any{"name": "Astrologer", "endpoint": "http://astro.local/a2a", "capabilities": []string{"nlp.summarize", "astrology"}})
+	_ = resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Fatalf("unknown capability: expected 400, got %d", resp.StatusCode)
+	}
+
+	resp = post("/v1/providers", map[string]any{"name": "Summarizer", "endpoint": "http://sum.local/a2a", "capabilities": []string{"nlp.summarize"}})
+	var reg struct {
+		ProviderID string `json:"provider_id"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reg)
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("register: expected 200, got %d", resp.StatusCode)
+	}
+
+	resp = post("/v1/subscriptions", map[string]any{"provider_id": reg.ProviderID, "categories": []string{"astrology.*"}})
+	_ = resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Fatalf("unknown category: expected 400, got %d", resp.StatusCode)
+	}
+	resp = post("/v1/subscriptions", map[string]any{"provider_id": reg.ProviderID, "categories": []string{"nlp.*", "legal.contract_review"}})
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("subscribe: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestProviderStats(t *testing.T) {
+	trust := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"trust_score":          0.82,
+			"trust_tier":           "TRUSTED",
+			"total_contracts":      10,
+			"successful_contracts": 9,
+			"failed_contracts":     1,
+		})
+	}))
+	t.Cleanup(trust.Close)
+	var settlementQuery string
+	settlement := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settlementQuery = r.URL.Query().Get("provider_id")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"total_contracts":       10,
+			"success_rate":          0.9,
+			"total_awarded":         "125.00",
+			"average_awarded_price": "12.50",
+			"currency":              "USD",
+		})
+	}))
+	t.Cleanup(settlement.Close)
+
+	register := func(ts *httptest.Server, name string) string {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"name": name, "endpoint": "http://stats.local/a2a"})
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			ProviderID string `json:"provider_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.ProviderID
+	}
+	type stats struct {
+		TrustScore          float64           `json:"trust_score"`
+		TrustTier           string            `json:"trust_tier"`
+		Contracts           map[string]int    `json:"contracts"`
+		SuccessRate         *float64          `json:"success_rate"`
+		AverageAwardedPrice string            `json:"average_awarded_price"`
+		Sources             map[string]string `json:"sources"`
+	}
+	getStats := func(ts *httptest.Server, id string) (int, stats) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/providers/" + id + "/stats")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out stats
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AllowHTTP: true, TrustBrokerURL: trust.URL, SettlementURL: settlement.URL})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	id := register(ts, "Stats Provider")
+	code, got := getStats(ts, id)
+	if code != 200 {
+		t.Fatalf("stats: expected 200, got %d", code)
+	}
+	if got.TrustScore != 0.82 || got.TrustTier != "TRUSTED" || got.Contracts["total"] != 10 || got.SuccessRate == nil || *got.SuccessRate != 0.9 {
+		t.Fatalf("unexpected trust stats: %+v", got)
+	}
+	if got.AverageAwardedPrice != "12.50" || settlementQuery != id {
+		t.Fatalf("unexpected settlement stats: %+v (queried %q)", got, settlementQuery)
+	}
+	if got.Sources["trust_broker"] != "ok" || got.Sources["settlement"] != "ok" {
+		t.Fatalf("unexpected sources: %v", got.Sources)
+	}
+
+	if code, _ := getStats(ts, "prov_missing"); code != 404 {
+		t.Fatalf("missing provider: expected 404, got %d", code)
+	}
+
+	bare := httptest.NewServer(prhttp.NewRouter(prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AllowHTTP: true})))
+	t.Cleanup(bare.Close)
+	code, got = getStats(bare, register(bare, "Bare Provider"))
+	if code != 200 || got.Sources["trust_broker"] != "not_configured" || got.SuccessRate != nil {
+		t.Fatalf("unconfigured upstreams: status=%d %+v", code, got)
+	}
+}
+
+func TestProviderDeregistration(t *testing.T) {
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AdminToken: "admin_secret"})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	type registration struct {
+		ProviderID string `json:"provider_id"`
+		APIKey     string `json:"api_key"`
+	}
+	register := func(query, token string) (int, registration) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"name": "Leaving Provider", "endpoint": "https://leaving.example.com/a2a"})
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/providers"+query, bytes.NewReader(b))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out registration
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	do := func(method, path, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	_, reg := register("", "")
+	b, _ := json.Marshal(map[string]any{"provider_id": reg.ProviderID, "categories": []string{"legal.*"}})
+	resp, err := http.Post(ts.URL+"/v1/subscriptions", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	resp = do(http.MethodDelete, "/v1/providers/"+reg.ProviderID, "wrong")
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("deregister with wrong key: expected 401, got %d", resp.StatusCode)
+	}
+	resp = do(http.MethodDelete, "/v1/providers/"+reg.ProviderID, reg.APIKey)
+	var deleted struct {
+		Status               string `json:"status"`
+		SubscriptionsDeleted int    `json:"subscriptions_deleted"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&deleted)
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 || deleted.Status != "DELETED" || deleted.SubscriptionsDeleted != 1 {
+		t.Fatalf("deregister: status=%d %+v", resp.StatusCode, deleted)
+	}
+
+	// The tombstone keeps the record but its key no longer works.
+	resp = do(http.MethodGet, "/v1/providers/"+reg.ProviderID, "")
+	var tomb struct {
+		Status    string     `json:"status"`
+		DeletedAt *time.Time `json:"deleted_at"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&tomb)
+	_ = resp.Body.Close()
+	if tomb.Status != "DELETED" || tomb.DeletedAt == nil {
+		t.Fatalf("expected tombstone, got %+v", tomb)
+	}
+	resp = do(http.MethodGet, "/v1/providers/me", reg.APIKey)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("deleted provider key: expected 401, got %d", resp.StatusCode)
+	}
+	resp = do(http.MethodGet, "/v1/subscriptions?provider_id="+reg.ProviderID, "")
+	var subs struct {
+		Subscriptions []json.RawMessage `json:"subscriptions"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&subs)
+	_ = resp.Body.Close()
+	if len(subs.Subscriptions) != 0 {
+		t.Fatalf("expected subscriptions to be deleted, got %d", len(subs.Subscriptions))
+	}
+
+	if code, _ := register("", ""); code != http.StatusConflict {
+		t.Fatalf("re-registration of deleted endpoint: expected 409, got %d", code)
+	}
+	if code, _ := register("?admin_override=true", "wrong"); code != http.StatusConflict {
+		t.Fatalf("override without admin token: expected 409, got %d", code)
+	}
+	code, again := register("?admin_override=true", "admin_secret")
+	if code != 200 || again.ProviderID == reg.ProviderID || again.APIKey == reg.APIKey {
+		t.Fatalf("admin override: status=%d %+v", code, again)
+	}
+
+	resp = do(http.MethodDelete, "/admin/v1/providers/"+again.ProviderID, "admin_secret")
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("admin delete: expected 200, got %d", resp.StatusCode)
+	}
+	resp = do(http.MethodDelete, "/admin/v1/providers/"+again.ProviderID, "admin_secret")
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("second delete: expected 409, got %d", resp.StatusCode)
+	}
+}
+
+func TestDeletionAdminActionsRequireConfiguredToken(t *testing.T) {
+	ts := httptest.NewServer(prhttp.NewRouter(prsvc.New(prstore.NewMemoryStore())))
+	t.Cleanup(ts.Close)
+
+	register := func(query string) (int, string, string) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"name": "Leaving Provider", "endpoint": "https://leaving.example.com/a2a"})
+		resp, err := http.Post(ts.URL+"/v1/providers"+query, "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			ProviderID string `json:"provider_id"`
+			APIKey     string `json:"api_key"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.ProviderID, out.APIKey
+	}
+
+	_, providerID, apiKey := register("")
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/providers/"+providerID, nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("deregister: expected 200, got %d", resp.StatusCode)
+	}
+
+	// Without an admin token nobody is an admin, for the override or
+	// for deleting other providers.
+	if code, _, _ := register("?admin_override=true"); code != http.StatusConflict {
+		t.Fatalf("override without a configured admin token: expected 409, got %d", code)
+	}
+	req, _ = http.NewRequest(http.MethodDelete, ts.URL+"/admin/v1/providers/"+providerID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("admin delete without a configured admin token: expected 503, got %d", resp.StatusCode)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "agent.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &certKey.PublicKey, certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	sum := sha256.Sum256(der)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC", "crv": "P-256", "kid": "k1", "alg": "ES256",
+			"x": b64(signingKey.X.FillBytes(make([]byte, 32))),
+			"y": b64(signingKey.Y.FillBytes(make([]byte, 32))),
+		}}})
+	}))
+	t.Cleanup(jwks.Close)
+	sign := func(claims map[string]any) string {
+		t.Helper()
+		h, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "k1", "typ": "JWT"})
+		c, _ := json.Marshal(claims)
+		signed := b64(h) + "." + b64(c)
+		digest := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, signingKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + b64(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...))
+	}
+
+	svc := prsvc.NewWithOptions(prstore.NewMemoryStore(), prsvc.Options{AllowHTTP: true})
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	register := func(name, cert string) (int, string) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"name": name, "endpoint": "https://agent.example.com/a2a", "client_certificate": cert, "jwks_url": jwks.URL})
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			ProviderID string `json:"provider_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.ProviderID
+	}
+	type validation struct {
+		Valid      bool   `json:"valid"`
+		ProviderID string `json:"provider_id"`
+		Error      string `json:"error"`
+	}
+	decode := func(resp *http.Response) validation {
+		t.Helper()
+		defer func() { _ = resp.Body.Close() }()
+		var out validation
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	if code, _ := register("Bad Cert", "not a certificate"); code != http.StatusBadRequest {
+		t.Fatalf("invalid certificate: expected 400, got %d", code)
+	}
+	code, id := register("Cert Provider", certPEM)
+	if code != 200 {
+		t.Fatalf("register: expected 200, got %d", code)
+	}
+	if code, _ := register("Cert Thief", certPEM); code != http.StatusConflict {
+		t.Fatalf("duplicate certificate: expected 409, got %d", code)
+	}
+
+	resp, err := http.Get(ts.URL + "/internal/v1/providers/validate-cert?fingerprint=" + strings.ToUpper(fingerprint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decode(resp); !got.Valid || got.ProviderID != id {
+		t.Fatalf("validate-cert: %+v", got)
+	}
+	resp, err = http.Get(ts.URL + "/internal/v1/providers/validate-cert?fingerprint=00ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decode(resp); got.Valid {
+		t.Fatalf("unknown fingerprint should be invalid: %+v", got)
+	}
+
+	validateJWT := func(token, aud string) validation {
+		t.Helper()
+		b, _ := json.Marshal(map[string]string{"token": token, "audience": aud})
+		resp, err := http.Post(ts.URL+"/internal/v1/providers/validate-jwt", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decode(resp)
+	}
+	exp := time.Now().Add(5 * time.Minute).Unix()
+	if got := validateJWT(sign(map[string]any{"iss": id, "aud": "aex-bid-gateway", "exp": exp}), "aex-bid-gateway"); !got.Valid || got.ProviderID != id {
+		t.Fatalf("valid jwt rejected: %+v", got)
+	}
+	if got := validateJWT(sign(map[string]any{"iss": id, "aud": "other", "exp": exp}), "aex-bid-gateway"); got.Valid {
+		t.Fatal("jwt with wrong audience accepted")
+	}
+	if got := validateJWT(sign(map[string]any{"iss": id, "exp": time.Now().Add(-time.Hour).Unix()}), ""); got.Valid || got.Error != "token expired" {
+		t.Fatalf("expired jwt: %+v", got)
+	}
+	forged := sign(map[string]any{"iss": id, "exp": exp})
+	forged = forged[:len(forged)-4] + "AAAA"
+	if got := validateJWT(forged, ""); got.Valid {
+		t.Fatal("jwt with forged signature accepted")
+	}
+}
+
+func TestProviderSLACommitments(t *testing.T) {
+	svc := prsvc.New(prstore.NewMemoryStore())
+	ts := httptest.NewServer(prhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	register := func(body map[string]any) (*http.Response, string, string) {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		resp, err := http.Post(ts.URL+"/v1/providers", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var reg struct {
+			ProviderID string `json:"provider_id"`
+			APIKey     string `json:"api_key"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&reg)
+		return resp, reg.ProviderID, reg.APIKey
+	}
+
+	resp, _, _ := register(map[string]any{
+		"name":            "Bad SLA Provider",
+		"endpoint":        "https://bad-sla.example.com/a2a",
+		"capabilities":    []string{"nlp"},
+		"sla_commitments": []map[string]any{{"capability": "travel", "max_latency_ms": 1000, "availability": 0.99}},
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for undeclared capability, got %d", resp.StatusCode)
+	}
+	resp, _, _ = register(map[string]any{
+		"name":            "Bad SLA Provider",
+		"endpoint":        "https://bad-sla.example.com/a2a",
+		"sla_commitments": []map[string]any{{"capability": "*", "max_latency_ms": 1000, "availability": 1.5}},
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for availability above 1, got %d", resp.StatusCode)
+	}
+
+	resp, providerID, apiKey := register(map[string]any{
+		"name":         "SLA Provider",
+		"endpoint":     "https://sla.example.com/a2a",
+		"capabilities": []string{"nlp", "nlp.summarize"},
+		"sla_commitments": []map[string]any{
+			{"capability": "*", "max_latency_ms": 5000, "availability": 0.95},
+			{"capability": "nlp", "max_latency_ms": 2000, "availability": 0.99},
+			{"capability": "nlp.summarize", "max_latency_ms": 800, "availability": 0.999},
+		},
+	})
+	if resp.StatusCode != 200 {
+		t.Fatalf("register: expected 200, got %d", resp.StatusCode)
+	}
+
+	type sla struct {
+		Capability   string  `json:"capability"`
+		MaxLatencyMs int64   `json:"max_latency_ms"`
+		Availability float64 `json:"availability"`
+	}
+	lookup := func(category string) (sla, int) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/internal/v1/providers/" + providerID + "/sla?category=" + category)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out sla
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out, resp.StatusCode
+	}
+
+	for category, want := range map[string]string{
+		"nlp.summarize":      "nlp.summarize",
+		"nlp.summarize.long": "nlp.summarize",
+		"nlp.translate":      "nlp",
+		"travel.booking":     "*",
+		"":                   "*",
+	} {
+		got, code := lookup(category)
+		if code != 200 || got.Capability != want {
+			t.Fatalf("category %q: expected %q, got %+v (status %d)", category, want, got, code)
+		}
+	}
+	if got, _ := lookup("nlp.summarize"); got.MaxLatencyMs != 800 || got.Availability != 0.999 {
+		t.Fatalf("unexpected commitment: %+v", got)
+	}
+
+	patch := func(body any) int {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPatch, ts.URL+"/v1/providers/me", bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Dropping a capability that still has a commitment is rejected.
+	if code := patch(map[string]any{"capabilities": []string{"nlp"}}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for orphaned commitment, got %d", code)
+	}
+	if code := patch(map[string]any{"sla_commitments": []map[string]any{
+		{"capability": "nlp", "max_latency_ms": 1500, "availability": 0.99},
+	}}); code != 200 {
+		t.Fatalf("update commitments: expected 200, got %d", code)
+	}
+	if got, _ := lookup("nlp.summarize"); got.Capability != "nlp" || got.MaxLatencyMs != 1500 {
+		t.Fatalf("expected updated nlp commitment, got %+v", got)
+	}
+	if _, code := lookup("travel.booking"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a covering commitment, got %d", code)
+	}
+}
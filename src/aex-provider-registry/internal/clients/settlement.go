@@ -0,0 +1,45 @@
+package clients
+
+import (
+	"context"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/internal/httpclient"
+)
+
+// SettlementStats is the settlement service's summary of a provider's
+// settled contracts. Amounts are decimal strings.
+type SettlementStats struct {
+	ProviderID          string  `json:"provider_id"`
+	TotalContracts      int     `json:"total_contracts"`
+	CompletedContracts  int     `json:"completed_contracts"`
+	FailedContracts     int     `json:"failed_contracts"`
+	SuccessRate         float64 `json:"success_rate"`
+	TotalAwarded        string  `json:"total_awarded"`
+	AverageAwardedPrice string  `json:"average_awarded_price"`
+	Currency            string  `json:"currency"`
+}
+
+type SettlementClient struct {
+	baseURL string
+	client  *httpclient.Client
+}
+
+func NewSettlementClient(baseURL string) *SettlementClient {
+	return &SettlementClient{
+		baseURL: baseURL,
+		client:  httpclient.NewClient("settlement", 10*time.Second),
+	}
+}
+
+// GetProviderStats returns contract counts and awarded prices for a
+// provider.
+func (c *SettlementClient) GetProviderStats(ctx context.Context, providerID string) (SettlementStats, error) {
+	var stats SettlementStats
+	err := httpclient.NewRequest("GET", c.baseURL).
+		Path("/internal/settlement/providers/stats").
+		Query("provider_id", providerID).
+		Context(ctx).
+		ExecuteJSON(c.client, &stats)
+	return stats, err
+}
@@ -0,0 +1,74 @@
+package clients
+
+import (
+	"context"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/internal/httpclient"
+)
+
+// VerificationUpdate is the payload accepted by the trust broker's
+// verification endpoint. Nil fields are left unchanged; Source and Method
+// record where the result came from.
+type VerificationUpdate struct {
+	EndpointVerified *bool  `json:"endpoint_verified,omitempty"`
+	IdentityVerified *bool  `json:"identity_verified,omitempty"`
+	Source           string `json:"source,omitempty"`
+	Method           string `json:"method,omitempty"`
+}
+
+// VerificationSource identifies the registry as the origin of
+// verification results sent to the trust broker.
+const VerificationSource = "aex-provider-registry"
+
+type TrustBrokerClient struct {
+	baseURL string
+	auth    httpclient.AuthProvider
+	client  *httpclient.Client
+}
+
+// NewTrustBrokerClient talks to baseURL, sending verification results
+// authenticated with auth when it is not nil.
+func NewTrustBrokerClient(baseURL string, auth httpclient.AuthProvider) *TrustBrokerClient {
+	return &TrustBrokerClient{
+		baseURL: baseURL,
+		auth:    auth,
+		client:  httpclient.NewClient("trust-broker", 10*time.Second),
+	}
+}
+
+// SetVerification updates the provider's verification flags so the trust
+// score modifiers are recalculated.
+func (c *TrustBrokerClient) SetVerification(ctx context.Context, providerID string, update VerificationUpdate) error {
+	var response struct {
+		ProviderID string `json:"provider_id"`
+	}
+	return httpclient.NewRequest("PUT", c.baseURL).
+		Path("/internal/v1/providers/"+providerID+"/verification").
+		Auth(c.auth).
+		JSON(update).
+		Context(ctx).
+		ExecuteJSON(c.client, &response)
+}
+
+// TrustRecord is the subset of the trust broker's provider record the
+// registry reports in provider statistics.
+type TrustRecord struct {
+	ProviderID          string  `json:"provider_id"`
+	TrustScore          float64 `json:"trust_score"`
+	TrustTier           string  `json:"trust_tier"`
+	TotalContracts      int     `json:"total_contracts"`
+	SuccessfulContracts int     `json:"successful_contracts"`
+	FailedContracts     int     `json:"failed_contracts"`
+	DisputedContracts   int     `json:"disputed_contracts"`
+}
+
+// GetTrust returns the provider's current trust score and contract counts.
+func (c *TrustBrokerClient) GetTrust(ctx context.Context, providerID string) (TrustRecord, error) {
+	var rec TrustRecord
+	err := httpclient.NewRequest("GET", c.baseURL).
+		Path("/v1/providers/"+providerID+"/trust").
+		Context(ctx).
+		ExecuteJSON(c.client, &rec)
+	return rec, err
+}
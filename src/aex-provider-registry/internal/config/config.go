@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,6 +21,47 @@ type Config struct {
 
 	// AllowHTTP allows HTTP URLs in development mode
 	AllowHTTP bool
+
+	// VerifyEndpoints challenges provider endpoints on registration and on
+	// endpoint change.
+	VerifyEndpoints bool
+	TrustBrokerURL  string
+	SettlementURL   string
+
+	// TrustBrokerToken authorizes verification updates sent to the trust
+	// broker (its INTERNAL_TOKEN).
+	TrustBrokerToken string
+
+	// VerifyDomains holds new providers in PENDING_VERIFICATION until they
+	// prove domain ownership. On by default outside development.
+	VerifyDomains bool
+
+	// FetchAgentCards validates registrations against the provider's A2A
+	// agent card.
+	FetchAgentCards bool
+
+	HeartbeatStaleAfter   time.Duration
+	HeartbeatOfflineAfter time.Duration
+	LivenessCheckInterval time.Duration
+
+	// AdminToken protects the /admin routes; without it they are disabled.
+	AdminToken string
+
+	// Default per-provider limits; zero means unlimited.
+	DefaultMaxBidsPerMinute       int
+	DefaultMaxConcurrentContracts int
+
+	// EventsURL receives provider lifecycle events (optional).
+	EventsURL string
+
+	// StrictTaxonomy rejects capabilities and subscription categories
+	// outside the capability taxonomy; TaxonomyFile replaces the built-in
+	// taxonomy.
+	StrictTaxonomy bool
+	TaxonomyFile   string
+
+	WebhookMaxAttempts int
+	WebhookBackoff     time.Duration
 }
 
 func Load() Config {
@@ -27,15 +69,32 @@ func Load() Config {
 	allowHTTP := env == "development" || env == "dev" || env == "local"
 
 	return Config{
-		Port:                     getenv("PORT", "8080"),
-		MongoURI:                 strings.TrimSpace(os.Getenv("MONGO_URI")),
-		MongoDatabase:            getenv("MONGO_DB", "aex"),
-		MongoCollectionProviders: getenv("MONGO_COLLECTION_PROVIDERS", "providers"),
-		MongoCollectionSubs:      getenv("MONGO_COLLECTION_SUBSCRIPTIONS", "subscriptions"),
-		ReadTimeout:              10 * time.Second,
-		WriteTimeout:             20 * time.Second,
-		IdleTimeout:              60 * time.Second,
-		AllowHTTP:                allowHTTP,
+		Port:                          getenv("PORT", "8080"),
+		MongoURI:                      strings.TrimSpace(os.Getenv("MONGO_URI")),
+		MongoDatabase:                 getenv("MONGO_DB", "aex"),
+		MongoCollectionProviders:      getenv("MONGO_COLLECTION_PROVIDERS", "providers"),
+		MongoCollectionSubs:           getenv("MONGO_COLLECTION_SUBSCRIPTIONS", "subscriptions"),
+		ReadTimeout:                   10 * time.Second,
+		WriteTimeout:                  20 * time.Second,
+		IdleTimeout:                   60 * time.Second,
+		AllowHTTP:                     allowHTTP,
+		VerifyEndpoints:               getenvBool("VERIFY_ENDPOINTS", false),
+		VerifyDomains:                 getenvBool("VERIFY_DOMAINS", !allowHTTP),
+		TrustBrokerURL:                strings.TrimSpace(os.Getenv("TRUST_BROKER_URL")),
+		TrustBrokerToken:              strings.TrimSpace(os.Getenv("TRUST_BROKER_TOKEN")),
+		SettlementURL:                 strings.TrimSpace(os.Getenv("SETTLEMENT_URL")),
+		FetchAgentCards:               getenvBool("FETCH_AGENT_CARDS", false),
+		HeartbeatStaleAfter:           getenvDuration("HEARTBEAT_STALE_AFTER", 2*time.Minute),
+		HeartbeatOfflineAfter:         getenvDuration("HEARTBEAT_OFFLINE_AFTER", 10*time.Minute),
+		LivenessCheckInterval:         getenvDuration("LIVENESS_CHECK_INTERVAL", 30*time.Second),
+		AdminToken:                    strings.TrimSpace(os.Getenv("ADMIN_TOKEN")),
+		DefaultMaxBidsPerMinute:       getenvInt("DEFAULT_MAX_BIDS_PER_MINUTE", 0),
+		DefaultMaxConcurrentContracts: getenvInt("DEFAULT_MAX_CONCURRENT_CONTRACTS", 0),
+		EventsURL:                     strings.TrimSpace(os.Getenv("EVENTS_URL")),
+		StrictTaxonomy:                getenvBool("STRICT_TAXONOMY", false),
+		TaxonomyFile:                  strings.TrimSpace(os.Getenv("TAXONOMY_FILE")),
+		WebhookMaxAttempts:            getenvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookBackoff:                getenvDuration("WEBHOOK_BACKOFF", time.Second),
 	}
 }
 
@@ -45,3 +104,35 @@ func getenv(k, def string) string {
 	}
 	return def
 }
+
+func getenvBool(k string, def bool) bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(k)))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func getenvDuration(k string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+func getenvInt(k string, def int) int {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
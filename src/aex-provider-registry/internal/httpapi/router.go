@@ -13,11 +13,24 @@ func NewRouter(svc *service.Service) http.Handler {
 	mux.HandleFunc("POST /v1/providers", svc.HandleRegisterProvider)
 	mux.HandleFunc("GET /v1/providers", svc.HandleListAllProviders)
 	mux.HandleFunc("GET /v1/providers/search", svc.HandleSearchProviders)
+	mux.HandleFunc("GET /v1/providers/schema", svc.HandleProfileSchema)
+	mux.HandleFunc("GET /v1/capabilities", svc.HandleListCapabilities)
+	mux.HandleFunc("GET /v1/providers/me", svc.HandleGetMe)
+	mux.HandleFunc("PATCH /v1/providers/me", svc.HandleUpdateMe)
 
 	// Provider details (must come after /search to avoid conflicts)
 	mux.HandleFunc("GET /v1/providers/{provider_id}/a2a", svc.HandleGetProviderWithA2A)
+	mux.HandleFunc("GET /v1/providers/{provider_id}/agent-card", svc.HandleGetAgentCard)
 	mux.HandleFunc("POST /v1/providers/{provider_id}/agent-card", svc.HandleRegisterAgentCard)
+	mux.HandleFunc("POST /v1/providers/{provider_id}/verify-endpoint", svc.HandleVerifyEndpoint)
+	mux.HandleFunc("POST /v1/providers/{provider_id}/verify-domain", svc.HandleVerifyDomain)
+	mux.HandleFunc("POST /v1/providers/{provider_id}/heartbeat", svc.HandleHeartbeat)
+	mux.HandleFunc("GET /v1/providers/{provider_id}/stats", svc.HandleProviderStats)
+	mux.HandleFunc("GET /v1/providers/{provider_id}/api-keys", svc.HandleListAPIKeys)
+	mux.HandleFunc("POST /v1/providers/{provider_id}/api-keys", svc.HandleCreateAPIKey)
+	mux.HandleFunc("DELETE /v1/providers/{provider_id}/api-keys/{key_id}", svc.HandleRevokeAPIKey)
 	mux.HandleFunc("GET /v1/providers/{provider_id}", svc.HandleGetProvider)
+	mux.HandleFunc("DELETE /v1/providers/{provider_id}", svc.HandleDeregisterProvider)
 
 	// Legacy single provider endpoint (fallback)
 	mux.HandleFunc("GET /v1/providers/", svc.HandleGetProvider)
@@ -25,10 +38,30 @@ func NewRouter(svc *service.Service) http.Handler {
 	// Subscriptions
 	mux.HandleFunc("POST /v1/subscriptions", svc.HandleCreateSubscription)
 	mux.HandleFunc("GET /v1/subscriptions", svc.HandleListSubscriptions)
+	mux.HandleFunc("GET /v1/subscriptions/{subscription_id}", svc.HandleGetSubscription)
+	mux.HandleFunc("PATCH /v1/subscriptions/{subscription_id}", svc.HandleUpdateSubscription)
+	mux.HandleFunc("DELETE /v1/subscriptions/{subscription_id}", svc.HandleDeleteSubscription)
+	mux.HandleFunc("POST /v1/subscriptions/{subscription_id}/pause", svc.HandlePauseSubscription)
+	mux.HandleFunc("POST /v1/subscriptions/{subscription_id}/resume", svc.HandleResumeSubscription)
+	mux.HandleFunc("GET /v1/subscriptions/{subscription_id}/deliveries", svc.HandleSubscriptionDeliveries)
 
 	// Internal APIs
 	mux.HandleFunc("GET /internal/v1/providers/subscribed", svc.HandleInternalSubscribed)
 	mux.HandleFunc("GET /internal/v1/providers/validate-key", svc.HandleValidateAPIKey)
+	mux.HandleFunc("GET /internal/v1/providers/validate-cert", svc.HandleValidateCert)
+	mux.HandleFunc("POST /internal/v1/providers/validate-jwt", svc.HandleValidateJWT)
+	mux.HandleFunc("GET /internal/v1/providers/{provider_id}/credentials", svc.HandleInternalCredentials)
+	mux.HandleFunc("POST /internal/v1/work/available", svc.HandleWorkAvailable)
+	mux.HandleFunc("GET /internal/v1/providers/{provider_id}/limits", svc.HandleInternalLimits)
+	mux.HandleFunc("GET /internal/v1/providers/{provider_id}/sla", svc.HandleInternalSLA)
+
+	// Admin APIs
+	mux.HandleFunc("POST /admin/v1/providers/{provider_id}/suspend", svc.HandleSuspendProvider)
+	mux.HandleFunc("POST /admin/v1/providers/{provider_id}/reactivate", svc.HandleReactivateProvider)
+	mux.HandleFunc("POST /admin/v1/providers/{provider_id}/deactivate", svc.HandleDeactivateProvider)
+	mux.HandleFunc("DELETE /admin/v1/providers/{provider_id}", svc.HandleAdminDeleteProvider)
+	mux.HandleFunc("PUT /admin/v1/providers/{provider_id}/limits", svc.HandleSetLimits)
+	mux.HandleFunc("DELETE /admin/v1/providers/{provider_id}/limits", svc.HandleClearLimits)
 
 	// Health check
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
@@ -9,6 +9,8 @@ const (
 	ProviderStatusActive              ProviderStatus = "ACTIVE"
 	ProviderStatusSuspended           ProviderStatus = "SUSPENDED"
 	ProviderStatusInactive            ProviderStatus = "INACTIVE"
+	// ProviderStatusDeleted marks a tombstone left by deregistration.
+	ProviderStatusDeleted ProviderStatus = "DELETED"
 )
 
 type TrustTier string
@@ -20,6 +22,35 @@ const (
 	TrustTierPreferred  TrustTier = "PREFERRED"
 )
 
+// StatusChange records an administrative status transition.
+type StatusChange struct {
+	From      ProviderStatus `json:"from" bson:"from"`
+	To        ProviderStatus `json:"to" bson:"to"`
+	Reason    string         `json:"reason" bson:"reason"`
+	ChangedAt time.Time      `json:"changed_at" bson:"changed_at"`
+}
+
+// ProviderLimits throttles a provider across the exchange. Zero means
+// unlimited.
+type ProviderLimits struct {
+	MaxBidsPerMinute       int `json:"max_bids_per_minute" bson:"max_bids_per_minute"`
+	MaxConcurrentContracts int `json:"max_concurrent_contracts" bson:"max_concurrent_contracts"`
+}
+
+type StatusChangeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Liveness is derived from provider heartbeats. It is empty until the first
+// heartbeat, so providers that never opted in are not treated as dead.
+type Liveness string
+
+const (
+	LivenessOnline  Liveness = "ONLINE"
+	LivenessStale   Liveness = "STALE"
+	LivenessOffline Liveness = "OFFLINE"
+)
+
 type Provider struct {
 	ProviderID   string         `json:"provider_id" bson:"provider_id"`
 	Name         string         `json:"name" bson:"name"`
@@ -30,17 +61,185 @@ type Provider struct {
 	ContactEmail string         `json:"contact_email" bson:"contact_email"`
 	Metadata     map[string]any `json:"metadata" bson:"metadata"`
 
-	APIKeyHash    string `json:"-" bson:"api_key_hash"`
-	APISecretHash string `json:"-" bson:"api_secret_hash"`
+	ProviderProfile `bson:",inline"`
+
+	APIKeyHash    string   `json:"-" bson:"api_key_hash"`
+	APISecretHash string   `json:"-" bson:"api_secret_hash"`
+	APIKeys       []APIKey `json:"-" bson:"api_keys,omitempty"`
 
 	Status     ProviderStatus `json:"status" bson:"status"`
 	TrustScore float64        `json:"trust_score" bson:"trust_score"`
 	TrustTier  TrustTier      `json:"trust_tier" bson:"trust_tier"`
 
+	StatusReason    string         `json:"status_reason,omitempty" bson:"status_reason,omitempty"`
+	StatusChangedAt *time.Time     `json:"status_changed_at,omitempty" bson:"status_changed_at,omitempty"`
+	StatusHistory   []StatusChange `json:"status_history,omitempty" bson:"status_history,omitempty"`
+	DeletedAt       *time.Time     `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+
+	// Limits overrides the exchange-wide default limits for this provider.
+	Limits *ProviderLimits `json:"limits,omitempty" bson:"limits,omitempty"`
+
+	Liveness        Liveness   `json:"liveness,omitempty" bson:"liveness,omitempty"`
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at,omitempty" bson:"last_heartbeat_at,omitempty"`
+
+	EndpointVerified     bool                  `json:"endpoint_verified" bson:"endpoint_verified"`
+	EndpointVerification *EndpointVerification `json:"endpoint_verification,omitempty" bson:"endpoint_verification,omitempty"`
+	DomainVerification   *DomainVerification   `json:"domain_verification,omitempty" bson:"domain_verification,omitempty"`
+
+	// AgentCardURL and AgentCardFetchedAt are set when the agent card was
+	// fetched from the provider's endpoint at registration.
+	AgentCardURL       string     `json:"agent_card_url,omitempty" bson:"agent_card_url,omitempty"`
+	AgentCardFetchedAt *time.Time `json:"agent_card_fetched_at,omitempty" bson:"agent_card_fetched_at,omitempty"`
+
+	// ClientCertificate and JWKSURL let internal services authenticate
+	// provider calls by mTLS or signed JWT instead of an API key.
+	ClientCertificate *ClientCertificate `json:"client_certificate,omitempty" bson:"client_certificate,omitempty"`
+	JWKSURL           string             `json:"jwks_url,omitempty" bson:"jwks_url,omitempty"`
+
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
 }
 
+type VerificationStatus string
+
+const (
+	VerificationPending  VerificationStatus = "PENDING"
+	VerificationVerified VerificationStatus = "VERIFIED"
+	VerificationFailed   VerificationStatus = "FAILED"
+)
+
+// EndpointVerification records the latest challenge-response check against
+// the provider's declared endpoint. Endpoint is the URL that was checked, so a
+// result never carries over to a different endpoint.
+type EndpointVerification struct {
+	Status     VerificationStatus `json:"status" bson:"status"`
+	Endpoint   string             `json:"endpoint" bson:"endpoint"`
+	Attempts   int                `json:"attempts" bson:"attempts"`
+	LastError  string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	CheckedAt  *time.Time         `json:"checked_at,omitempty" bson:"checked_at,omitempty"`
+	VerifiedAt *time.Time         `json:"verified_at,omitempty" bson:"verified_at,omitempty"`
+}
+
+const (
+	DomainMethodDNS       = "dns_txt"
+	DomainMethodWellKnown = "well_known"
+)
+
+// DomainVerification proves the provider controls the host of its endpoint.
+// The provider publishes Token either as a DNS TXT record or in a
+// .well-known file; Artifacts keeps every check for audit.
+type DomainVerification struct {
+	Domain     string                       `json:"domain" bson:"domain"`
+	Token      string                       `json:"token" bson:"token"`
+	Status     VerificationStatus           `json:"status" bson:"status"`
+	Method     string                       `json:"method,omitempty" bson:"method,omitempty"`
+	LastError  string                       `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	CheckedAt  *time.Time                   `json:"checked_at,omitempty" bson:"checked_at,omitempty"`
+	VerifiedAt *time.Time                   `json:"verified_at,omitempty" bson:"verified_at,omitempty"`
+	Artifacts  []DomainVerificationArtifact `json:"artifacts,omitempty" bson:"artifacts,omitempty"`
+}
+
+// DomainVerificationArtifact records what one check looked at and found.
+type DomainVerificationArtifact struct {
+	Method    string    `json:"method" bson:"method"`
+	Location  string    `json:"location" bson:"location"`
+	Observed  []string  `json:"observed,omitempty" bson:"observed,omitempty"`
+	Verified  bool      `json:"verified" bson:"verified"`
+	Error     string    `json:"error,omitempty" bson:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at" bson:"checked_at"`
+}
+
+// DomainVerificationInstructions tells a newly registered provider how to
+// prove domain ownership.
+type DomainVerificationInstructions struct {
+	Domain         string `json:"domain"`
+	Token          string `json:"token"`
+	DNSRecordName  string `json:"dns_record_name"`
+	DNSRecordValue string `json:"dns_record_value"`
+	WellKnownURL   string `json:"well_known_url"`
+}
+
+// EndpointChallenge is POSTed to the provider endpoint. The provider must
+// answer with an EndpointChallengeResponse echoing Nonce and signing it.
+type EndpointChallenge struct {
+	Type       string    `json:"type"`
+	ProviderID string    `json:"provider_id"`
+	Nonce      string    `json:"nonce"`
+	IssuedAt   time.Time `json:"issued_at"`
+}
+
+// EndpointChallengeResponse carries the echoed nonce and the hex
+// HMAC-SHA256 of the nonce keyed with the hex SHA-256 of the API secret.
+type EndpointChallengeResponse struct {
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// APIKey is one of a provider's credentials. Providers registered before key
+// rotation only have APIKeyHash; the first rotation records that key here as
+// well so it can be expired or revoked like any other.
+type APIKey struct {
+	KeyID     string     `json:"key_id" bson:"key_id"`
+	Hash      string     `json:"-" bson:"hash"`
+	Prefix    string     `json:"prefix" bson:"prefix,omitempty"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key can be used at now.
+func (k APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// ClientCertificate is a provider's registered mTLS client certificate.
+// FingerprintSHA256 is the lowercase hex SHA-256 of the DER encoding.
+type ClientCertificate struct {
+	PEM               string    `json:"pem" bson:"pem"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256" bson:"fingerprint_sha256"`
+	Subject           string    `json:"subject" bson:"subject"`
+	NotBefore         time.Time `json:"not_before" bson:"not_before"`
+	NotAfter          time.Time `json:"not_after" bson:"not_after"`
+}
+
+// ValidateJWTRequest asks the registry to verify a provider-signed JWT.
+// Audience, when set, must be listed in the token's aud claim.
+type ValidateJWTRequest struct {
+	Token    string `json:"token"`
+	Audience string `json:"audience,omitempty"`
+}
+
+// KeyActive reports whether keyHash is a usable API key for the provider.
+func (p *Provider) KeyActive(keyHash string, now time.Time) bool {
+	if keyHash == "" {
+		return false
+	}
+	for _, k := range p.APIKeys {
+		if k.Hash == keyHash {
+			return k.Active(now)
+		}
+	}
+	return p.APIKeyHash == keyHash
+}
+
+// CreateAPIKeyRequest issues an additional key. ExpireExistingIn, when set,
+// schedules every other active key to expire after that duration (e.g.
+// "24h") so rotation completes on its own.
+type CreateAPIKeyRequest struct {
+	ExpireExistingIn string `json:"expire_existing_in,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	ProviderID string    `json:"provider_id"`
+	KeyID      string    `json:"key_id"`
+	APIKey     string    `json:"api_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	Keys       []APIKey  `json:"keys"`
+}
+
 type ProviderRegistrationRequest struct {
 	Name         string         `json:"name"`
 	Description  string         `json:"description"`
@@ -49,6 +248,55 @@ type ProviderRegistrationRequest struct {
 	Capabilities []string       `json:"capabilities"`
 	ContactEmail string         `json:"contact_email"`
 	Metadata     map[string]any `json:"metadata"`
+
+	// ClientCertificate is a PEM-encoded client certificate and JWKSURL
+	// serves the keys the provider signs JWTs with. Both are optional.
+	ClientCertificate string `json:"client_certificate,omitempty"`
+	JWKSURL           string `json:"jwks_url,omitempty"`
+
+	ProviderProfile
+}
+
+const (
+	PricingPerTask  = "per_task"
+	PricingPerToken = "per_token"
+	PricingPerHour  = "per_hour"
+)
+
+// ProviderProfile holds the validated, structured description of what a
+// provider offers. Metadata stays free-form for backwards compatibility;
+// consumers should rely on these fields instead.
+type ProviderProfile struct {
+	Pricing        *Pricing     `json:"pricing,omitempty" bson:"pricing,omitempty"`
+	Regions        []string     `json:"regions,omitempty" bson:"regions,omitempty"`
+	MaxConcurrency int          `json:"max_concurrency,omitempty" bson:"max_concurrency,omitempty"`
+	Runtime        *RuntimeInfo `json:"runtime,omitempty" bson:"runtime,omitempty"`
+
+	SLACommitments []SLACommitment `json:"sla_commitments,omitempty" bson:"sla_commitments,omitempty"`
+}
+
+// SLACommitment is the service level a provider commits to by default for
+// one of its capabilities, or for all of them when Capability is "*". Bids
+// that omit an SLA block inherit it.
+type SLACommitment struct {
+	Capability   string  `json:"capability" bson:"capability"`
+	MaxLatencyMs int64   `json:"max_latency_ms" bson:"max_latency_ms"`
+	Availability float64 `json:"availability" bson:"availability"`
+}
+
+// Pricing is the provider's list price. Amount is in Currency per Model unit
+// (task, 1k tokens or hour).
+type Pricing struct {
+	Model    string  `json:"model" bson:"model"`
+	Currency string  `json:"currency" bson:"currency"`
+	Amount   float64 `json:"amount" bson:"amount"`
+	Minimum  float64 `json:"minimum,omitempty" bson:"minimum,omitempty"`
+}
+
+// RuntimeInfo describes the models and tools the agent runs with.
+type RuntimeInfo struct {
+	Models []string `json:"models,omitempty" bson:"models,omitempty"`
+	Tools  []string `json:"tools,omitempty" bson:"tools,omitempty"`
 }
 
 type ProviderRegistrationResponse struct {
@@ -58,6 +306,8 @@ type ProviderRegistrationResponse struct {
 	Status     ProviderStatus `json:"status"`
 	TrustTier  TrustTier      `json:"trust_tier"`
 	CreatedAt  time.Time      `json:"created_at"`
+
+	DomainVerification *DomainVerificationInstructions `json:"domain_verification,omitempty"`
 }
 
 type SubscriptionFilter struct {
@@ -66,9 +316,30 @@ type SubscriptionFilter struct {
 	Regions      []string `json:"regions,omitempty"`
 }
 
+const (
+	DeliveryWebhook = "webhook"
+	DeliveryPolling = "polling"
+)
+
+const (
+	SubscriptionActive = "ACTIVE"
+	SubscriptionPaused = "PAUSED"
+)
+
+// DeliveryConfig is how a subscription is delivered. The webhook secret is
+// stored but never rendered; it is returned once, when the subscription is
+// created.
 type DeliveryConfig struct {
 	Method        string `json:"method"` // webhook|polling
 	WebhookURL    string `json:"webhook_url,omitempty"`
+	WebhookSecret string `json:"-"`
+}
+
+// DeliveryRequest is the delivery preference a provider sends when it
+// creates or updates a subscription.
+type DeliveryRequest struct {
+	Method        string `json:"method"`
+	WebhookURL    string `json:"webhook_url,omitempty"`
 	WebhookSecret string `json:"webhook_secret,omitempty"`
 }
 
@@ -80,13 +351,55 @@ type Subscription struct {
 	Delivery       DeliveryConfig     `json:"delivery" bson:"delivery"`
 	Status         string             `json:"status" bson:"status"`
 	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt      *time.Time         `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+
+	// GlobCategories is maintained by the store: it marks subscriptions
+	// whose patterns cannot be matched by an index lookup.
+	GlobCategories bool `json:"-" bson:"glob_categories"`
+}
+
+// SubscriptionQuery filters and pages subscriptions. Category selects the
+// subscriptions whose patterns cover that work category.
+type SubscriptionQuery struct {
+	ProviderID string
+	Category   string
+	Status     string
+	Cursor     string
+	Limit      int
+}
+
+// ProviderUpdateRequest is the self-service PATCH body. Only fields that
+// are present are changed; the name identifies the provider and is fixed.
+type ProviderUpdateRequest struct {
+	Description    *string        `json:"description,omitempty"`
+	Endpoint       *string        `json:"endpoint,omitempty"`
+	BidWebhook     *string        `json:"bid_webhook,omitempty"`
+	Capabilities   []string       `json:"capabilities,omitempty"`
+	ContactEmail   *string        `json:"contact_email,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+	Pricing        *Pricing       `json:"pricing,omitempty"`
+	Regions        []string       `json:"regions,omitempty"`
+	MaxConcurrency *int           `json:"max_concurrency,omitempty"`
+	Runtime        *RuntimeInfo   `json:"runtime,omitempty"`
+	// SLACommitments replaces the list; an empty list removes them.
+	SLACommitments *[]SLACommitment `json:"sla_commitments,omitempty"`
+	// An empty ClientCertificate or JWKSURL removes it.
+	ClientCertificate *string `json:"client_certificate,omitempty"`
+	JWKSURL           *string `json:"jwks_url,omitempty"`
+}
+
+// SubscriptionUpdateRequest replaces the fields that are present.
+type SubscriptionUpdateRequest struct {
+	Categories []string            `json:"categories,omitempty"`
+	Filters    *SubscriptionFilter `json:"filters,omitempty"`
+	Delivery   *DeliveryRequest    `json:"delivery,omitempty"`
 }
 
 type SubscriptionRequest struct {
 	ProviderID string             `json:"provider_id"`
 	Categories []string           `json:"categories"`
 	Filters    SubscriptionFilter `json:"filters"`
-	Delivery   DeliveryConfig     `json:"delivery"`
+	Delivery   DeliveryRequest    `json:"delivery"`
 }
 
 type SubscriptionResponse struct {
@@ -97,6 +410,30 @@ type SubscriptionResponse struct {
 	CreatedAt      time.Time `json:"created_at"`
 }
 
+// CreateSubscriptionResponse is the only response that carries the
+// subscription's webhook secret.
+type CreateSubscriptionResponse struct {
+	SubscriptionResponse
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+// WorkAvailableRequest is sent by the work publisher when new work opens
+// for bidding.
+type WorkAvailableRequest struct {
+	WorkID          string         `json:"work_id"`
+	Category        string         `json:"category"`
+	Description     string         `json:"description,omitempty"`
+	Budget          map[string]any `json:"budget,omitempty"`
+	BidWindowEndsAt *time.Time     `json:"bid_window_ends_at,omitempty"`
+}
+
+type WorkAvailableResponse struct {
+	WorkID   string   `json:"work_id"`
+	Category string   `json:"category"`
+	Notified []string `json:"notified_subscriptions"`
+	Polling  int      `json:"polling_subscriptions"`
+}
+
 // A2A Agent Card models
 
 type AgentCard struct {
@@ -172,6 +509,17 @@ type ProviderWithA2A struct {
 	A2AEndpoint string     `json:"a2a_endpoint,omitempty" bson:"a2a_endpoint,omitempty"`
 }
 
+// ProviderQuery filters the provider listing. Results are ordered by
+// provider_id; Cursor is the last provider_id of the previous page.
+type ProviderQuery struct {
+	Capability string
+	Status     ProviderStatus
+	Tier       TrustTier
+	Text       string
+	Cursor     string
+	Limit      int
+}
+
 // SearchProvidersRequest for skill-based search
 type SearchProvidersRequest struct {
 	SkillTags []string `json:"skill_tags,omitempty"`
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// AgentCardPath is the A2A well-known location of an agent card, relative to
+// the origin of the provider endpoint.
+const AgentCardPath = "/.well-known/agent-card.json"
+
+// HandleGetAgentCard returns the agent card stored for a provider, whether it
+// was fetched at registration or pushed via POST .../agent-card.
+func (s *Service) HandleGetAgentCard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+
+	p, err := s.store.GetProviderWithA2A(ctx, providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+	if p.AgentCard == nil {
+		http.Error(w, "agent card not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":  p.ProviderID,
+		"a2a_endpoint": p.A2AEndpoint,
+		"source_url":   p.AgentCardURL,
+		"fetched_at":   p.AgentCardFetchedAt,
+		"agent_card":   p.AgentCard,
+	})
+}
+
+// fetchAgentCard downloads the agent card published at the origin of
+// endpoint and checks it covers every capability in the registration.
+func (s *Service) fetchAgentCard(ctx context.Context, endpoint string, capabilities []string) (model.AgentCard, string, error) {
+	var card model.AgentCard
+	cardURL, err := agentCardURL(endpoint)
+	if err != nil {
+		return card, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cardURL, nil)
+	if err != nil {
+		return card, cardURL, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.verifier.Do(req)
+	if err != nil {
+		return card, cardURL, fmt.Errorf("agent card unreachable: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return card, cardURL, fmt.Errorf("agent card returned %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&card); err != nil {
+		return card, cardURL, errors.New("invalid agent card")
+	}
+	if card.Name == "" || card.URL == "" || len(card.Skills) == 0 {
+		return card, cardURL, errors.New("agent card must have name, url, and at least one skill")
+	}
+	if missing := missingCapabilities(card, capabilities); len(missing) > 0 {
+		return card, cardURL, fmt.Errorf("capabilities not advertised by agent card: %s", strings.Join(missing, ", "))
+	}
+	return card, cardURL, nil
+}
+
+// saveAgentCard stores card and replaces the provider's skill index.
+func (s *Service) saveAgentCard(ctx context.Context, providerID string, card model.AgentCard) (string, int, error) {
+	a2aEndpoint := deriveA2AEndpoint(card.URL)
+	if err := s.store.SaveAgentCard(ctx, providerID, card, a2aEndpoint); err != nil {
+		return "", 0, err
+	}
+
+	skills := make([]model.SkillIndex, 0, len(card.Skills))
+	for _, skill := range card.Skills {
+		skills = append(skills, model.SkillIndex{
+			SkillID:     skill.ID,
+			SkillName:   skill.Name,
+			Description: skill.Description,
+			Tags:        skill.Tags,
+			ProviderID:  providerID,
+			AgentName:   card.Name,
+			AgentURL:    card.URL,
+			A2AEndpoint: a2aEndpoint,
+		})
+	}
+	if err := s.store.IndexSkills(ctx, providerID, skills); err != nil {
+		return a2aEndpoint, 0, err
+	}
+	return a2aEndpoint, len(skills), nil
+}
+
+func agentCardURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", errors.New("invalid endpoint")
+	}
+	return u.Scheme + "://" + u.Host + AgentCardPath, nil
+}
+
+// missingCapabilities returns the capabilities that match neither a skill id
+// nor a skill tag of card. Matching is case-insensitive.
+func missingCapabilities(card model.AgentCard, capabilities []string) []string {
+	advertised := map[string]bool{}
+	for _, skill := range card.Skills {
+		advertised[strings.ToLower(skill.ID)] = true
+		for _, tag := range skill.Tags {
+			advertised[strings.ToLower(tag)] = true
+		}
+	}
+	var missing []string
+	for _, c := range capabilities {
+		if !advertised[strings.ToLower(strings.TrimSpace(c))] {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
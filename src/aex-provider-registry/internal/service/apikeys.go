@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// legacyKeyID names the key issued at registration by providers created
+// before keys were tracked individually.
+const legacyKeyID = "key_primary"
+
+// HandleListAPIKeys returns the provider's keys without their hashes.
+func (s *Service) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+	ensureAPIKeys(p)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id": p.ProviderID,
+		"keys":        p.APIKeys,
+	})
+}
+
+// HandleCreateAPIKey issues an additional API key. Existing keys stay valid
+// until they expire or are revoked, so clients can switch over gradually.
+func (s *Service) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.CreateAPIKeyRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+	}
+	var expireIn time.Duration
+	if req.ExpireExistingIn != "" {
+		expireIn, err = time.ParseDuration(req.ExpireExistingIn)
+		if err != nil || expireIn < 0 {
+			http.Error(w, "expire_existing_in must be a non-negative duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	ensureAPIKeys(p)
+	if req.ExpireExistingIn != "" {
+		expiresAt := now.Add(expireIn)
+		for i := range p.APIKeys {
+			k := &p.APIKeys[i]
+			if !k.Active(now) {
+				continue
+			}
+			if k.ExpiresAt == nil || k.ExpiresAt.After(expiresAt) {
+				t := expiresAt
+				k.ExpiresAt = &t
+			}
+		}
+	}
+
+	apiKey := generateToken("aex_pk_live_")
+	key := newAPIKey(apiKey, now)
+	p.APIKeys = append(p.APIKeys, key)
+	p.UpdatedAt = now
+
+	if err := s.store.UpdateProvider(ctx, *p); err != nil {
+		http.Error(w, "failed to create api key", http.StatusInternalServerError)
+		return
+	}
+	s.publishKeyRotated(p.ProviderID, key.KeyID, "created", now)
+
+	writeJSON(w, http.StatusCreated, model.CreateAPIKeyResponse{
+		ProviderID: p.ProviderID,
+		KeyID:      key.KeyID,
+		APIKey:     apiKey,
+		CreatedAt:  now,
+		Keys:       p.APIKeys,
+	})
+}
+
+// HandleRevokeAPIKey revokes a key immediately. The last active key cannot
+// be revoked, which would lock the provider out.
+func (s *Service) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+	keyID := strings.TrimSpace(r.PathValue("key_id"))
+
+	now := time.Now().UTC()
+	ensureAPIKeys(p)
+	idx := -1
+	active := 0
+	for i, k := range p.APIKeys {
+		if k.KeyID == keyID {
+			idx = i
+		}
+		if k.Active(now) {
+			active++
+		}
+	}
+	if idx < 0 {
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	}
+	key := &p.APIKeys[idx]
+	if key.RevokedAt != nil {
+		http.Error(w, "api key already revoked", http.StatusConflict)
+		return
+	}
+	if key.Active(now) && active == 1 {
+		http.Error(w, "cannot revoke the last active api key", http.StatusConflict)
+		return
+	}
+	key.RevokedAt = &now
+	p.UpdatedAt = now
+
+	if err := s.store.UpdateProvider(ctx, *p); err != nil {
+		http.Error(w, "failed to revoke api key", http.StatusInternalServerError)
+		return
+	}
+	s.publishKeyRotated(p.ProviderID, key.KeyID, "revoked", now)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id": p.ProviderID,
+		"key_id":      key.KeyID,
+		"revoked_at":  now,
+	})
+}
+
+func newAPIKey(apiKey string, now time.Time) model.APIKey {
+	prefix := apiKey
+	if len(prefix) > 16 {
+		prefix = prefix[:16]
+	}
+	return model.APIKey{
+		KeyID:     generateToken("key_"),
+		Hash:      sha256Hex(apiKey),
+		Prefix:    prefix,
+		CreatedAt: now,
+	}
+}
+
+// ensureAPIKeys records the registration key of providers created before
+// keys were tracked individually.
+func ensureAPIKeys(p *model.Provider) {
+	if len(p.APIKeys) > 0 || p.APIKeyHash == "" {
+		return
+	}
+	p.APIKeys = []model.APIKey{{
+		KeyID:     legacyKeyID,
+		Hash:      p.APIKeyHash,
+		CreatedAt: p.CreatedAt,
+	}}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// HandleInternalCredentials returns the client certificate and JWKS URL a
+// provider registered, for services that verify provider calls themselves.
+func (s *Service) HandleInternalCredentials(w http.ResponseWriter, r *http.Request) {
+	p, err := s.store.GetProvider(r.Context(), strings.TrimSpace(r.PathValue("provider_id")))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":        p.ProviderID,
+		"status":             p.Status,
+		"client_certificate": p.ClientCertificate,
+		"jwks_url":           p.JWKSURL,
+	})
+}
+
+// HandleValidateCert resolves the provider owning a client certificate,
+// identified by the SHA-256 fingerprint of its DER encoding as presented in
+// an mTLS handshake. The response mirrors validate-key.
+func (s *Service) HandleValidateCert(w http.ResponseWriter, r *http.Request) {
+	fingerprint := normalizeFingerprint(r.URL.Query().Get("fingerprint"))
+	if fingerprint == "" {
+		http.Error(w, "fingerprint is required", http.StatusBadRequest)
+		return
+	}
+	p, err := s.store.GetProviderByCertFingerprint(r.Context(), fingerprint)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	if p == nil || now.Before(p.ClientCertificate.NotBefore) || now.After(p.ClientCertificate.NotAfter) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"valid":       false,
+			"provider_id": "",
+			"status":      "",
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"valid":       p.Status == model.ProviderStatusActive,
+		"provider_id": p.ProviderID,
+		"status":      p.Status,
+	})
+}
+
+// HandleValidateJWT verifies a JWT signed by a provider with a key from its
+// registered JWKS. The token's iss claim names the provider.
+func (s *Service) HandleValidateJWT(w http.ResponseWriter, r *http.Request) {
+	var req model.ValidateJWTRequest
+	if err := decodeJSON(r, &req); err != nil || strings.TrimSpace(req.Token) == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	invalid := func(reason string) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"valid":       false,
+			"provider_id": "",
+			"status":      "",
+			"error":       reason,
+		})
+	}
+
+	token, err := parseJWT(strings.TrimSpace(req.Token))
+	if err != nil {
+		invalid(err.Error())
+		return
+	}
+	p, err := s.store.GetProvider(r.Context(), token.claims.Issuer)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil || p.JWKSURL == "" {
+		invalid("issuer has no registered jwks_url")
+		return
+	}
+	if err := s.jwks.verify(r.Context(), p.JWKSURL, token, req.Audience, time.Now()); err != nil {
+		invalid(err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"valid":       p.Status == model.ProviderStatusActive,
+		"provider_id": p.ProviderID,
+		"status":      p.Status,
+	})
+}
+
+// setClientCertificate parses and stores a PEM certificate; an empty string
+// removes the registered certificate.
+func setClientCertificate(p *model.Provider, certPEM string, now time.Time) error {
+	if strings.TrimSpace(certPEM) == "" {
+		p.ClientCertificate = nil
+		return nil
+	}
+	cert, err := parseClientCertificate(certPEM, now)
+	if err != nil {
+		return err
+	}
+	p.ClientCertificate = cert
+	return nil
+}
+
+func parseClientCertificate(certPEM string, now time.Time) (*model.ClientCertificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("client_certificate must be a PEM encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.New("client_certificate is not a valid certificate: " + err.Error())
+	}
+	if now.After(cert.NotAfter) {
+		return nil, errors.New("client_certificate has expired")
+	}
+	sum := sha256.Sum256(cert.Raw)
+	return &model.ClientCertificate{
+		PEM:               string(pem.EncodeToMemory(block)),
+		FingerprintSHA256: hex.EncodeToString(sum[:]),
+		Subject:           cert.Subject.String(),
+		NotBefore:         cert.NotBefore.UTC(),
+		NotAfter:          cert.NotAfter.UTC(),
+	}, nil
+}
+
+func (s *Service) validateJWKSURL(u string) error {
+	if u == "" {
+		return nil
+	}
+	if err := s.validateURL(u); err != nil {
+		return errors.New("jwks_url must be a valid URL: " + err.Error())
+	}
+	return nil
+}
+
+// normalizeFingerprint accepts the colon separated upper case form tools
+// like openssl print.
+func normalizeFingerprint(f string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(f), ":", ""))
+}
+
+// credentialsChanged reports whether a registration request carries client
+// credentials that differ from the provider's. Omitted credentials are
+// left unchanged.
+func credentialsChanged(p model.Provider, cert *model.ClientCertificate, jwksURL string) bool {
+	if cert != nil && (p.ClientCertificate == nil || p.ClientCertificate.FingerprintSHA256 != cert.FingerprintSHA256) {
+		return true
+	}
+	return jwksURL != "" && jwksURL != p.JWKSURL
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// HandleDeregisterProvider lets a provider delete itself with one of its API
+// keys.
+func (s *Service) HandleDeregisterProvider(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+	s.deleteProvider(w, r, p, "deregistered by provider")
+}
+
+// HandleAdminDeleteProvider deletes any provider.
+func (s *Service) HandleAdminDeleteProvider(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	p, err := s.store.GetProvider(r.Context(), strings.TrimSpace(r.PathValue("provider_id")))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+	s.deleteProvider(w, r, p, "deleted by admin")
+}
+
+// deleteProvider turns the provider into a tombstone: the record is kept
+// with status DELETED so its API keys stay known but revoked and its
+// endpoint cannot be registered again without an admin override. The
+// provider's subscriptions are removed.
+func (s *Service) deleteProvider(w http.ResponseWriter, r *http.Request, p *model.Provider, defaultReason string) {
+	ctx := r.Context()
+	var req model.StatusChangeRequest
+	if err := decodeOptionalJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = defaultReason
+	}
+	if p.Status == model.ProviderStatusDeleted {
+		http.Error(w, "provider already deleted", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+	from := p.Status
+	ensureAPIKeys(p)
+	var revoked []string
+	for i := range p.APIKeys {
+		if p.APIKeys[i].RevokedAt == nil {
+			p.APIKeys[i].RevokedAt = &now
+			revoked = append(revoked, p.APIKeys[i].KeyID)
+		}
+	}
+	p.StatusHistory = append(p.StatusHistory, model.StatusChange{
+		From:      p.Status,
+		To:        model.ProviderStatusDeleted,
+		Reason:    reason,
+		ChangedAt: now,
+	})
+	p.Status = model.ProviderStatusDeleted
+	p.StatusReason = reason
+	p.StatusChangedAt = &now
+	p.DeletedAt = &now
+	p.UpdatedAt = now
+	// Client credentials are dropped rather than kept like API keys: only
+	// the holder of the private key can use them, so they may be registered
+	// again by a new provider.
+	p.ClientCertificate = nil
+	p.JWKSURL = ""
+
+	if err := s.store.UpdateProvider(ctx, *p); err != nil {
+		http.Error(w, "failed to delete provider", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscriptions of non-ACTIVE providers are already skipped by the
+	// fan-out, so a failed cleanup is logged rather than returned.
+	deleted := 0
+	subs, _, err := s.store.QuerySubscriptions(ctx, model.SubscriptionQuery{ProviderID: p.ProviderID})
+	if err != nil {
+		log.Printf("provider deleted but subscriptions not listed provider_id=%s: %v", p.ProviderID, err)
+	}
+	for _, sub := range subs {
+		if err := s.store.DeleteSubscription(ctx, sub.SubscriptionID); err != nil {
+			log.Printf("failed to delete subscription subscription_id=%s: %v", sub.SubscriptionID, err)
+			continue
+		}
+		deleted++
+	}
+
+	log.Printf("provider deleted provider_id=%s reason=%q subscriptions_deleted=%d", p.ProviderID, reason, deleted)
+	for _, keyID := range revoked {
+		s.publishKeyRotated(p.ProviderID, keyID, "revoked", now)
+	}
+	s.publishStatusChanged(*p, from, reason, now)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":           p.ProviderID,
+		"status":                p.Status,
+		"deleted_at":            p.DeletedAt,
+		"subscriptions_deleted": deleted,
+	})
+}
+
+// registrationBlocked reports whether endpoint belongs to a deleted
+// provider. Re-registration is then only allowed with ?admin_override=true
+// and the configured admin token.
+func (s *Service) registrationBlocked(r *http.Request, endpoint string) (*model.Provider, error) {
+	tomb, err := s.store.GetDeletedProviderByEndpoint(r.Context(), endpoint)
+	if err != nil || tomb == nil {
+		return nil, err
+	}
+	if r.URL.Query().Get("admin_override") == "true" && s.isAdmin(r) {
+		log.Printf("re-registration of deleted provider endpoint allowed by admin override provider_id=%s", tomb.ProviderID)
+		return nil, nil
+	}
+	return tomb, nil
+}
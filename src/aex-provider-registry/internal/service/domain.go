@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/clients"
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+const (
+	// DomainRecordPrefix is prepended to the endpoint host to form the name
+	// of the TXT record carrying the verification token.
+	DomainRecordPrefix = "_aex-verification."
+	// DomainTokenPrefix prefixes the token in the TXT record value.
+	DomainTokenPrefix = "aex-verification="
+	// DomainWellKnownPath is the alternative to DNS: a text file at the
+	// origin of the endpoint containing the token.
+	DomainWellKnownPath = "/.well-known/aex-verification.txt"
+
+	maxDomainArtifacts = 20
+)
+
+// TXTResolver looks up DNS TXT records. *net.Resolver satisfies it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// HandleVerifyDomain checks the provider's DNS TXT record and .well-known
+// file for its verification token. A provider waiting in
+// PENDING_VERIFICATION is activated once the domain is verified.
+func (s *Service) HandleVerifyDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+	if p.DomainVerification == nil {
+		resetDomainVerification(p)
+	}
+
+	now := time.Now().UTC()
+	dv := p.DomainVerification
+	artifacts := s.checkDomain(ctx, *p, now)
+	dv.CheckedAt = &now
+	dv.Artifacts = append(dv.Artifacts, artifacts...)
+	if n := len(dv.Artifacts); n > maxDomainArtifacts {
+		dv.Artifacts = dv.Artifacts[n-maxDomainArtifacts:]
+	}
+
+	verified := false
+	var errs []string
+	for _, a := range artifacts {
+		if a.Verified {
+			verified = true
+			dv.Method = a.Method
+			break
+		}
+		errs = append(errs, a.Method+": "+a.Error)
+	}
+	if verified {
+		dv.Status = model.VerificationVerified
+		dv.LastError = ""
+		dv.VerifiedAt = &now
+		if p.Status == model.ProviderStatusPendingVerification {
+			p.StatusHistory = append(p.StatusHistory, model.StatusChange{
+				From:      p.Status,
+				To:        model.ProviderStatusActive,
+				Reason:    "domain verified",
+				ChangedAt: now,
+			})
+			p.Status = model.ProviderStatusActive
+			p.StatusReason = "domain verified"
+			p.StatusChangedAt = &now
+			s.publishStatusChanged(*p, model.ProviderStatusPendingVerification, "domain verified", now)
+		}
+	} else {
+		dv.Status = model.VerificationFailed
+		dv.LastError = strings.Join(errs, "; ")
+	}
+	p.UpdatedAt = now
+
+	if err := s.store.UpdateProvider(ctx, *p); err != nil {
+		http.Error(w, "failed to update provider", http.StatusInternalServerError)
+		return
+	}
+	if verified {
+		log.Printf("domain verified provider_id=%s domain=%s method=%s", p.ProviderID, dv.Domain, dv.Method)
+		go s.syncIdentityVerified(p.ProviderID, dv.Method)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":         p.ProviderID,
+		"status":              p.Status,
+		"domain_verification": dv,
+	})
+}
+
+// checkDomain tries the DNS record first and falls back to the .well-known
+// file, returning one artifact per method tried.
+func (s *Service) checkDomain(ctx context.Context, p model.Provider, now time.Time) []model.DomainVerificationArtifact {
+	dv := p.DomainVerification
+	record := DomainRecordPrefix + dv.Domain
+	dns := model.DomainVerificationArtifact{Method: model.DomainMethodDNS, Location: record, CheckedAt: now}
+	txts, err := s.resolver.LookupTXT(ctx, record)
+	if err != nil {
+		dns.Error = err.Error()
+	} else {
+		dns.Observed = txts
+		for _, txt := range txts {
+			if strings.TrimSpace(txt) == DomainTokenPrefix+dv.Token {
+				dns.Verified = true
+			}
+		}
+		if !dns.Verified {
+			dns.Error = "token not found"
+		}
+	}
+	if dns.Verified {
+		return []model.DomainVerificationArtifact{dns}
+	}
+
+	wk := model.DomainVerificationArtifact{Method: model.DomainMethodWellKnown, CheckedAt: now}
+	wellKnownURL, err := domainWellKnownURL(p.Endpoint)
+	if err != nil {
+		wk.Error = err.Error()
+		return []model.DomainVerificationArtifact{dns, wk}
+	}
+	wk.Location = wellKnownURL
+	body, err := s.fetchWellKnown(ctx, wellKnownURL)
+	if err != nil {
+		wk.Error = err.Error()
+	} else {
+		wk.Observed = []string{body}
+		if body == dv.Token {
+			wk.Verified = true
+		} else {
+			wk.Error = "token mismatch"
+		}
+	}
+	return []model.DomainVerificationArtifact{dns, wk}
+}
+
+func (s *Service) fetchWellKnown(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.verifier.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unreachable: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("returned %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (s *Service) syncIdentityVerified(providerID, method string) {
+	if s.trustBroker == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	verified := true
+	update := clients.VerificationUpdate{IdentityVerified: &verified, Source: clients.VerificationSource, Method: "domain_" + method}
+	if err := s.trustBroker.SetVerification(ctx, providerID, update); err != nil {
+		log.Printf("trust broker identity sync failed provider_id=%s: %v", providerID, err)
+	}
+}
+
+// resetDomainVerification issues a fresh token for the endpoint's host.
+func resetDomainVerification(p *model.Provider) {
+	p.DomainVerification = &model.DomainVerification{
+		Domain: endpointDomain(p.Endpoint),
+		Token:  generateToken("aexdv_"),
+		Status: model.VerificationPending,
+	}
+}
+
+// domainChanged reports whether the endpoint moved to a different host than
+// the one the domain verification was issued for, or, for providers
+// registered before domains were verified, than prevEndpoint.
+func domainChanged(p model.Provider, prevEndpoint string) bool {
+	if p.DomainVerification != nil {
+		return p.DomainVerification.Domain != endpointDomain(p.Endpoint)
+	}
+	return endpointDomain(prevEndpoint) != endpointDomain(p.Endpoint)
+}
+
+// requireDomainVerification resets the domain verification after the
+// endpoint moved to another host and holds an ACTIVE provider in
+// PENDING_VERIFICATION until the new domain is verified.
+func requireDomainVerification(p *model.Provider, now time.Time) {
+	resetDomainVerification(p)
+	if p.Status != model.ProviderStatusActive {
+		return
+	}
+	p.StatusHistory = append(p.StatusHistory, model.StatusChange{
+		From:      p.Status,
+		To:        model.ProviderStatusPendingVerification,
+		Reason:    "endpoint domain changed",
+		ChangedAt: now,
+	})
+	p.Status = model.ProviderStatusPendingVerification
+	p.StatusReason = "endpoint domain changed"
+	p.StatusChangedAt = &now
+}
+
+func domainInstructions(p model.Provider) *model.DomainVerificationInstructions {
+	dv := p.DomainVerification
+	if dv == nil {
+		return nil
+	}
+	wellKnownURL, _ := domainWellKnownURL(p.Endpoint)
+	return &model.DomainVerificationInstructions{
+		Domain:         dv.Domain,
+		Token:          dv.Token,
+		DNSRecordName:  DomainRecordPrefix + dv.Domain,
+		DNSRecordValue: DomainTokenPrefix + dv.Token,
+		WellKnownURL:   wellKnownURL,
+	}
+}
+
+func endpointDomain(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func domainWellKnownURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", errors.New("invalid endpoint")
+	}
+	return u.Scheme + "://" + u.Host + DomainWellKnownPath, nil
+}
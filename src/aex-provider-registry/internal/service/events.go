@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/events"
+)
+
+// providerEventTypes are published to the shared event bus so the bid
+// gateway can invalidate cached key validations and the trust broker can
+// set up records without waiting for the first contract.
+var providerEventTypes = []string{
+	events.EventProviderRegistered,
+	events.EventProviderUpdated,
+	events.EventProviderSuspended,
+	events.EventProviderStatusChanged,
+	events.EventProviderKeyRotated,
+}
+
+// publish sends an event in the background; delivery failures are logged by
+// the publisher and never fail the request that caused them.
+func (s *Service) publish(providerID, eventType string, data map[string]any) {
+	data["provider_id"] = providerID
+	go func() {
+		if err := s.events.Publish(context.Background(), eventType, data); err != nil {
+			log.Printf("event publish failed type=%s provider_id=%s: %v", eventType, providerID, err)
+		}
+	}()
+}
+
+func (s *Service) publishRegistered(p model.Provider) {
+	s.publish(p.ProviderID, events.EventProviderRegistered, map[string]any{
+		"name":          p.Name,
+		"endpoint":      p.Endpoint,
+		"capabilities":  p.Capabilities,
+		"status":        p.Status,
+		"trust_tier":    p.TrustTier,
+		"trust_score":   p.TrustScore,
+		"registered_at": p.CreatedAt,
+	})
+}
+
+func (s *Service) publishUpdated(p model.Provider, endpointChanged bool) {
+	s.publish(p.ProviderID, events.EventProviderUpdated, map[string]any{
+		"endpoint":         p.Endpoint,
+		"capabilities":     p.Capabilities,
+		"endpoint_changed": endpointChanged,
+		"updated_at":       p.UpdatedAt,
+	})
+}
+
+// publishStatusChanged emits provider.suspended for suspensions and
+// provider.status_changed for every other transition.
+func (s *Service) publishStatusChanged(p model.Provider, from model.ProviderStatus, reason string, at time.Time) {
+	if p.Status == model.ProviderStatusSuspended {
+		s.publish(p.ProviderID, events.EventProviderSuspended, map[string]any{
+			"previous_status": from,
+			"reason":          reason,
+			"suspended_at":    at,
+		})
+		return
+	}
+	s.publish(p.ProviderID, events.EventProviderStatusChanged, map[string]any{
+		"previous_status": from,
+		"new_status":      p.Status,
+		"reason":          reason,
+		"changed_at":      at,
+	})
+}
+
+func (s *Service) publishKeyRotated(providerID, keyID, action string, at time.Time) {
+	s.publish(providerID, events.EventProviderKeyRotated, map[string]any{
+		"key_id":     keyID,
+		"action":     action,
+		"rotated_at": at,
+	})
+}
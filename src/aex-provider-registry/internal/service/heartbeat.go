@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// HandleHeartbeat records that the provider is alive. The caller must
+// authenticate with the provider's API key.
+func (s *Service) HandleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := s.store.SetLiveness(ctx, p.ProviderID, model.LivenessOnline, &now); err != nil {
+		http.Error(w, "failed to record heartbeat", http.StatusInternalServerError)
+		return
+	}
+	if p.Liveness != "" && p.Liveness != model.LivenessOnline {
+		log.Printf("provider back online provider_id=%s previous=%s", p.ProviderID, p.Liveness)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":       p.ProviderID,
+		"liveness":          model.LivenessOnline,
+		"last_heartbeat_at": now,
+		"stale_after":       now.Add(s.staleAfter),
+	})
+}
+
+// RunLivenessChecker periodically downgrades providers whose heartbeats
+// stopped. It returns when ctx is cancelled.
+func (s *Service) RunLivenessChecker(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.CheckLiveness(ctx, time.Now().UTC())
+		}
+	}
+}
+
+// CheckLiveness marks providers STALE or OFFLINE based on the time since
+// their last heartbeat and returns how many changed. Providers that never
+// sent a heartbeat are left alone.
+func (s *Service) CheckLiveness(ctx context.Context, now time.Time) int {
+	providers, err := s.store.ListAllProviders(ctx)
+	if err != nil {
+		log.Printf("liveness check failed: %v", err)
+		return 0
+	}
+	changed := 0
+	for _, p := range providers {
+		if p.LastHeartbeatAt == nil {
+			continue
+		}
+		next := livenessAt(*p.LastHeartbeatAt, now, s.staleAfter, s.offlineAfter)
+		if next == p.Liveness {
+			continue
+		}
+		if err := s.store.SetLiveness(ctx, p.ProviderID, next, nil); err != nil {
+			log.Printf("liveness update failed provider_id=%s: %v", p.ProviderID, err)
+			continue
+		}
+		log.Printf("provider liveness changed provider_id=%s from=%s to=%s", p.ProviderID, p.Liveness, next)
+		changed++
+	}
+	return changed
+}
+
+func livenessAt(lastHeartbeat, now time.Time, staleAfter, offlineAfter time.Duration) model.Liveness {
+	since := now.Sub(lastHeartbeat)
+	switch {
+	case since >= offlineAfter:
+		return model.LivenessOffline
+	case since >= staleAfter:
+		return model.LivenessStale
+	default:
+		return model.LivenessOnline
+	}
+}
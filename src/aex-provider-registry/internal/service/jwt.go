@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwksCacheTTL = 5 * time.Minute
+	// jwksRefreshAfter bounds how often an unknown kid forces a refetch,
+	// so a stream of bogus tokens cannot hammer the provider's JWKS.
+	jwksRefreshAfter = time.Minute
+	jwtLeeway        = 30 * time.Second
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience accepts both the string and the array form of the aud claim.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+type jwtClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+}
+
+type parsedJWT struct {
+	header    jwtHeader
+	claims    jwtClaims
+	signed    []byte
+	signature []byte
+}
+
+func parseJWT(token string) (*parsedJWT, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var t parsedJWT
+	if err := decodeSegment(parts[0], &t.header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	if err := decodeSegment(parts[1], &t.claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	if t.claims.Issuer == "" {
+		return nil, errors.New("token has no iss claim")
+	}
+	t.signed = []byte(parts[0] + "." + parts[1])
+	t.signature = sig
+	return &t, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksEntry struct {
+	keys      []jwk
+	fetchedAt time.Time
+}
+
+// jwksCache fetches and caches provider JWKS documents by URL.
+type jwksCache struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	entries map[string]jwksEntry
+}
+
+func newJWKSCache(client *http.Client) *jwksCache {
+	return &jwksCache{client: client, entries: map[string]jwksEntry{}}
+}
+
+// verify checks the token signature against the JWKS at url, and its
+// expiry, not-before and, when aud is set, audience claims.
+func (c *jwksCache) verify(ctx context.Context, url string, t *parsedJWT, aud string, now time.Time) error {
+	key, err := c.key(ctx, url, t.header.Kid, now)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(t, key); err != nil {
+		return err
+	}
+	if t.claims.ExpiresAt == 0 {
+		return errors.New("token has no exp claim")
+	}
+	if now.After(time.Unix(t.claims.ExpiresAt, 0).Add(jwtLeeway)) {
+		return errors.New("token expired")
+	}
+	if t.claims.NotBefore != 0 && now.Add(jwtLeeway).Before(time.Unix(t.claims.NotBefore, 0)) {
+		return errors.New("token not yet valid")
+	}
+	if aud != "" && !slices.Contains(t.claims.Audience, aud) {
+		return errors.New("token audience mismatch")
+	}
+	return nil
+}
+
+func (c *jwksCache) key(ctx context.Context, url, kid string, now time.Time) (jwk, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[url]
+	c.mu.Unlock()
+
+	if !ok || now.Sub(entry.fetchedAt) > jwksCacheTTL {
+		if err := c.refresh(ctx, url, now, &entry); err != nil {
+			return jwk{}, err
+		}
+	}
+	if k, ok := findKey(entry.keys, kid); ok {
+		return k, nil
+	}
+	if now.Sub(entry.fetchedAt) > jwksRefreshAfter {
+		if err := c.refresh(ctx, url, now, &entry); err != nil {
+			return jwk{}, err
+		}
+		if k, ok := findKey(entry.keys, kid); ok {
+			return k, nil
+		}
+	}
+	return jwk{}, fmt.Errorf("no key %q in jwks", kid)
+}
+
+func (c *jwksCache) refresh(ctx context.Context, url string, now time.Time, entry *jwksEntry) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("jwks unreachable: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks returned %d", resp.StatusCode)
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return errors.New("jwks is not valid JSON")
+	}
+	*entry = jwksEntry{keys: doc.Keys, fetchedAt: now}
+	c.mu.Lock()
+	c.entries[url] = *entry
+	c.mu.Unlock()
+	return nil
+}
+
+// findKey returns the key with the given kid, or the only key when the
+// token names none.
+func findKey(keys []jwk, kid string) (jwk, bool) {
+	if kid == "" && len(keys) == 1 {
+		return keys[0], true
+	}
+	for _, k := range keys {
+		if k.Kid == kid && kid != "" {
+			return k, true
+		}
+	}
+	return jwk{}, false
+}
+
+func verifySignature(t *parsedJWT, k jwk) error {
+	if k.Alg != "" && k.Alg != t.header.Alg {
+		return errors.New("token alg does not match key")
+	}
+	switch t.header.Alg {
+	case "RS256", "RS512":
+		pub, err := rsaKey(k)
+		if err != nil {
+			return err
+		}
+		h, digest := hashFor(t.header.Alg, t.signed)
+		if rsa.VerifyPKCS1v15(pub, h, digest, t.signature) != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case "ES256", "ES384":
+		pub, err := ecKey(k)
+		if err != nil {
+			return err
+		}
+		_, digest := hashFor(t.header.Alg, t.signed)
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported alg %q", t.header.Alg)
+	}
+}
+
+func hashFor(alg string, data []byte) (crypto.Hash, []byte) {
+	switch alg {
+	case "RS512":
+		sum := sha512.Sum512(data)
+		return crypto.SHA512, sum[:]
+	case "ES384":
+		sum := sha512.Sum384(data)
+		return crypto.SHA384, sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return crypto.SHA256, sum[:]
+	}
+}
+
+func rsaKey(k jwk) (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, errors.New("key is not RSA")
+	}
+	n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+	e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+	if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 {
+		return nil, errors.New("malformed RSA key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func ecKey(k jwk) (*ecdsa.PublicKey, error) {
+	if k.Kty != "EC" {
+		return nil, errors.New("key is not EC")
+	}
+	var curve elliptic.Curve
+	var check ecdh.Curve
+	switch k.Crv {
+	case "P-256":
+		curve, check = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, check = elliptic.P384(), ecdh.P384()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+	y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+	size := (curve.Params().BitSize + 7) / 8
+	if err1 != nil || err2 != nil || len(x) > size || len(y) > size {
+		return nil, errors.New("malformed EC key")
+	}
+	// crypto/ecdh rejects points that are not on the curve.
+	point := make([]byte, 1+2*size)
+	point[0] = 4
+	copy(point[1+size-len(x):1+size], x)
+	copy(point[1+2*size-len(y):], y)
+	if _, err := check.NewPublicKey(point); err != nil {
+		return nil, errors.New("malformed EC key")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
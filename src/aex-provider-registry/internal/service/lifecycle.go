@@ -0,0 +1,131 @@
+package service
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// statusTransitions lists, per admin action, the target status and the
+// statuses it may be applied to.
+var statusTransitions = map[string]struct {
+	to   model.ProviderStatus
+	from []model.ProviderStatus
+}{
+	"suspend": {
+		to:   model.ProviderStatusSuspended,
+		from: []model.ProviderStatus{model.ProviderStatusActive, model.ProviderStatusPendingVerification},
+	},
+	"reactivate": {
+		to:   model.ProviderStatusActive,
+		from: []model.ProviderStatus{model.ProviderStatusSuspended, model.ProviderStatusInactive},
+	},
+	"deactivate": {
+		to:   model.ProviderStatusInactive,
+		from: []model.ProviderStatus{model.ProviderStatusActive, model.ProviderStatusPendingVerification, model.ProviderStatusSuspended},
+	},
+}
+
+// HandleSuspendProvider, HandleReactivateProvider and HandleDeactivateProvider
+// are admin-only status changes. Non-ACTIVE providers fail API key
+// validation and are left out of the subscription fan-out.
+func (s *Service) HandleSuspendProvider(w http.ResponseWriter, r *http.Request) {
+	s.changeStatus(w, r, "suspend")
+}
+
+func (s *Service) HandleReactivateProvider(w http.ResponseWriter, r *http.Request) {
+	s.changeStatus(w, r, "reactivate")
+}
+
+func (s *Service) HandleDeactivateProvider(w http.ResponseWriter, r *http.Request) {
+	s.changeStatus(w, r, "deactivate")
+}
+
+func (s *Service) changeStatus(w http.ResponseWriter, r *http.Request, action string) {
+	ctx := r.Context()
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	t := statusTransitions[action]
+
+	var req model.StatusChangeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	p, err := s.store.GetProvider(ctx, providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+	if !slices.Contains(t.from, p.Status) {
+		http.Error(w, "cannot "+action+" provider in status "+string(p.Status), http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+	from := p.Status
+	p.StatusHistory = append(p.StatusHistory, model.StatusChange{
+		From:      p.Status,
+		To:        t.to,
+		Reason:    req.Reason,
+		ChangedAt: now,
+	})
+	p.Status = t.to
+	p.StatusReason = req.Reason
+	p.StatusChangedAt = &now
+	p.UpdatedAt = now
+
+	if err := s.store.UpdateProvider(ctx, *p); err != nil {
+		http.Error(w, "failed to update provider", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("provider status changed provider_id=%s status=%s reason=%q", p.ProviderID, p.Status, req.Reason)
+	s.publishStatusChanged(*p, from, req.Reason, now)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":       p.ProviderID,
+		"status":            p.Status,
+		"status_reason":     p.StatusReason,
+		"status_changed_at": p.StatusChangedAt,
+		"status_history":    p.StatusHistory,
+	})
+}
+
+// authorizeAdmin requires the admin token as a Bearer token. Without a
+// configured admin token the admin routes are disabled.
+func (s *Service) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		http.Error(w, "admin API is disabled: no admin token is configured", http.StatusServiceUnavailable)
+		return false
+	}
+	if !s.isAdmin(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// isAdmin reports whether r carries the admin token; never without one.
+func (s *Service) isAdmin(r *http.Request) bool {
+	if s.adminToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(s.adminToken)) == 1
+}
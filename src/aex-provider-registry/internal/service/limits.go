@@ -0,0 +1,85 @@
+package service
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// HandleSetLimits stores a per-provider override of the default limits.
+func (s *Service) HandleSetLimits(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	var req model.ProviderLimits
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.MaxBidsPerMinute < 0 || req.MaxConcurrentContracts < 0 {
+		http.Error(w, "limits must not be negative", http.StatusBadRequest)
+		return
+	}
+	s.updateLimits(w, r, &req)
+}
+
+// HandleClearLimits drops the override so the provider uses the defaults
+// again.
+func (s *Service) HandleClearLimits(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	s.updateLimits(w, r, nil)
+}
+
+func (s *Service) updateLimits(w http.ResponseWriter, r *http.Request, limits *model.ProviderLimits) {
+	ctx := r.Context()
+	p, err := s.store.GetProvider(ctx, strings.TrimSpace(r.PathValue("provider_id")))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+	p.Limits = limits
+	p.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdateProvider(ctx, *p); err != nil {
+		http.Error(w, "failed to update provider", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("provider limits changed provider_id=%s limits=%+v", p.ProviderID, limits)
+	writeJSON(w, http.StatusOK, s.effectiveLimits(*p))
+}
+
+// HandleInternalLimits returns the limits the bid gateway and contract
+// engine should enforce for a provider.
+func (s *Service) HandleInternalLimits(w http.ResponseWriter, r *http.Request) {
+	p, err := s.store.GetProvider(r.Context(), strings.TrimSpace(r.PathValue("provider_id")))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.effectiveLimits(*p))
+}
+
+func (s *Service) effectiveLimits(p model.Provider) map[string]any {
+	limits, source := s.defaultLimits, "default"
+	if p.Limits != nil {
+		limits, source = *p.Limits, "provider"
+	}
+	return map[string]any{
+		"provider_id":              p.ProviderID,
+		"max_bids_per_minute":      limits.MaxBidsPerMinute,
+		"max_concurrent_contracts": limits.MaxConcurrentContracts,
+		"source":                   source,
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// HandleGetMe returns the full record of the provider owning the API key.
+func (s *Service) HandleGetMe(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.authorizeMe(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// HandleUpdateMe lets a provider change its own endpoint, capabilities,
+// metadata, profile and client credentials. Suspended and deactivated providers are read-only.
+func (s *Service) HandleUpdateMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, ok := s.authorizeMe(w, r)
+	if !ok {
+		return
+	}
+	if p.Status == model.ProviderStatusSuspended || p.Status == model.ProviderStatusInactive {
+		http.Error(w, "provider is "+string(p.Status), http.StatusForbidden)
+		return
+	}
+
+	var req model.ProviderUpdateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	prevEndpoint := p.Endpoint
+	prevCapabilities := p.Capabilities
+	if req.Description != nil {
+		p.Description = *req.Description
+	}
+	if req.Endpoint != nil {
+		if err := s.validateURL(*req.Endpoint); err != nil {
+			http.Error(w, "endpoint must be a valid URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.Endpoint = *req.Endpoint
+	}
+	if req.BidWebhook != nil {
+		if *req.BidWebhook != "" {
+			if err := s.validateURL(*req.BidWebhook); err != nil {
+				http.Error(w, "bid_webhook must be a valid URL: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		p.BidWebhook = *req.BidWebhook
+	}
+	if req.Capabilities != nil {
+		if err := s.checkCapabilities(req.Capabilities); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.Capabilities = req.Capabilities
+	}
+	if req.ContactEmail != nil {
+		p.ContactEmail = *req.ContactEmail
+	}
+	if req.Metadata != nil {
+		p.Metadata = req.Metadata
+	}
+	if req.Pricing != nil {
+		p.Pricing = req.Pricing
+	}
+	if req.Regions != nil {
+		p.Regions = req.Regions
+	}
+	if req.MaxConcurrency != nil {
+		p.MaxConcurrency = *req.MaxConcurrency
+	}
+	if req.Runtime != nil {
+		p.Runtime = req.Runtime
+	}
+	if req.SLACommitments != nil {
+		p.SLACommitments = *req.SLACommitments
+	}
+	if err := s.validateProfile(&p.ProviderProfile); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkSLACommitments(p.Capabilities, p.SLACommitments); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	if req.JWKSURL != nil {
+		if err := s.validateJWKSURL(*req.JWKSURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.JWKSURL = *req.JWKSURL
+	}
+	if req.ClientCertificate != nil {
+		if err := setClientCertificate(p, *req.ClientCertificate, now); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p.ClientCertificate != nil {
+			owner, err := s.store.GetProviderByCertFingerprint(ctx, p.ClientCertificate.FingerprintSHA256)
+			if err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if owner != nil && owner.ProviderID != p.ProviderID {
+				http.Error(w, "client_certificate is registered to another provider", http.StatusConflict)
+				return
+			}
+		}
+	}
+	endpointChanged := p.Endpoint != prevEndpoint
+	var card *model.AgentCard
+	if s.fetchAgentCards && (endpointChanged || !slices.Equal(p.Capabilities, prevCapabilities)) {
+		fetched, cardURL, err := s.fetchAgentCard(ctx, p.Endpoint, p.Capabilities)
+		if err != nil {
+			http.Error(w, "agent card validation failed: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		card = &fetched
+		p.AgentCardURL = cardURL
+		p.AgentCardFetchedAt = &now
+	}
+	if endpointChanged {
+		resetEndpointVerification(p)
+	}
+	if s.verifyDomains && domainChanged(*p, prevEndpoint) {
+		requireDomainVerification(p, now)
+	}
+	p.UpdatedAt = now
+
+	if err := s.store.UpdateProvider(ctx, *p); err != nil {
+		http.Error(w, "failed to update provider", http.StatusInternalServerError)
+		return
+	}
+	if card != nil {
+		if _, _, err := s.saveAgentCard(ctx, p.ProviderID, *card); err != nil {
+			http.Error(w, "failed to save agent card", http.StatusInternalServerError)
+			return
+		}
+	}
+	if endpointChanged {
+		s.endpointChanged(*p)
+	}
+	s.publishUpdated(*p, endpointChanged)
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+// authorizeMe resolves the provider from the Bearer API key alone, for
+// routes that do not name the provider in the path.
+func (s *Service) authorizeMe(w http.ResponseWriter, r *http.Request) (*model.Provider, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	hash := sha256Hex(token)
+	p, err := s.store.GetProviderByAPIKeyHash(r.Context(), hash)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if p == nil || !p.KeyActive(hash, time.Now().UTC()) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return p, true
+}
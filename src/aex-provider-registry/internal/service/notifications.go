@@ -0,0 +1,90 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/webhook"
+)
+
+// HandleWorkAvailable fans a newly published work item out to every
+// webhook subscription matching its category. Deliveries are queued and
+// retried in the background; polling subscriptions are only counted.
+func (s *Service) HandleWorkAvailable(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req model.WorkAvailableRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.WorkID = strings.TrimSpace(req.WorkID)
+	req.Category = strings.TrimSpace(req.Category)
+	if req.WorkID == "" || req.Category == "" {
+		http.Error(w, "work_id and category are required", http.StatusBadRequest)
+		return
+	}
+
+	matched, err := s.matchSubscribers(ctx, req.Category)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	data := map[string]any{
+		"work_id":  req.WorkID,
+		"category": req.Category,
+	}
+	if req.Description != "" {
+		data["description"] = req.Description
+	}
+	if req.Budget != nil {
+		data["budget"] = req.Budget
+	}
+	if req.BidWindowEndsAt != nil {
+		data["bid_window_ends_at"] = req.BidWindowEndsAt
+	}
+
+	resp := model.WorkAvailableResponse{WorkID: req.WorkID, Category: req.Category, Notified: []string{}}
+	for _, m := range matched {
+		if m.webhookURL == "" {
+			resp.Polling++
+			continue
+		}
+		s.notifier.Notify(m.webhookURL, webhookSecret(m), webhook.Event{
+			EventID:        generateToken("evt_"),
+			Type:           webhook.EventWorkAvailable,
+			SubscriptionID: m.sub.SubscriptionID,
+			ProviderID:     m.provider.ProviderID,
+			Timestamp:      now,
+			Data:           data,
+		})
+		resp.Notified = append(resp.Notified, m.sub.SubscriptionID)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleSubscriptionDeliveries returns the recent webhook deliveries for a
+// subscription.
+func (s *Service) HandleSubscriptionDeliveries(w http.ResponseWriter, r *http.Request) {
+	sub, ok := s.authorizeSubscription(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"subscription_id": sub.SubscriptionID,
+		"deliveries":      s.notifier.Deliveries(sub.SubscriptionID),
+	})
+}
+
+// webhookSecret is the subscription's own secret when it has one. Otherwise
+// notifications are signed with the hex SHA-256 of the provider's API
+// secret, the same key used for endpoint challenges.
+func webhookSecret(m subscriber) string {
+	if m.sub.Delivery.WebhookSecret != "" {
+		return m.sub.Delivery.WebhookSecret
+	}
+	return m.provider.APISecretHash
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// ProfileValidator is an additional check run on the structured provider
+// profile after the built-in rules, e.g. a JSON-schema validator owned by
+// another team. A returned error rejects the registration with 400.
+type ProfileValidator func(model.ProviderProfile) error
+
+const maxProfileListLen = 50
+
+var (
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	regionPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// ProfileSchema is the JSON schema of the structured provider fields, served
+// at GET /v1/providers/schema so consumers can validate cached records.
+var ProfileSchema = json.RawMessage(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://aex.dev/schemas/provider-profile.json",
+  "type": "object",
+  "properties": {
+    "pricing": {
+      "type": "object",
+      "required": ["model", "currency", "amount"],
+      "properties": {
+        "model": {"enum": ["per_task", "per_token", "per_hour"]},
+        "currency": {"type": "string", "pattern": "^[A-Z]{3}$"},
+        "amount": {"type": "number", "minimum": 0},
+        "minimum": {"type": "number", "minimum": 0}
+      },
+      "additionalProperties": false
+    },
+    "regions": {
+      "type": "array",
+      "maxItems": 50,
+      "uniqueItems": true,
+      "items": {"type": "string", "pattern": "^[a-z0-9][a-z0-9-]*$"}
+    },
+    "max_concurrency": {"type": "integer", "minimum": 0},
+    "runtime": {
+      "type": "object",
+      "properties": {
+        "models": {"type": "array", "maxItems": 50, "items": {"type": "string", "minLength": 1}},
+        "tools": {"type": "array", "maxItems": 50, "items": {"type": "string", "minLength": 1}}
+      },
+      "additionalProperties": false
+    },
+    "sla_commitments": {
+      "type": "array",
+      "maxItems": 50,
+      "items": {
+        "type": "object",
+        "required": ["capability", "max_latency_ms", "availability"],
+        "properties": {
+          "capability": {"type": "string", "minLength": 1},
+          "max_latency_ms": {"type": "integer", "exclusiveMinimum": 0},
+          "availability": {"type": "number", "exclusiveMinimum": 0, "maximum": 1}
+        },
+        "additionalProperties": false
+      }
+    }
+  }
+}`)
+
+// HandleProfileSchema serves ProfileSchema.
+func (s *Service) HandleProfileSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(ProfileSchema)
+}
+
+// validateProfile normalizes p in place and checks it against the rules in
+// ProfileSchema, then runs the configured hook.
+func (s *Service) validateProfile(p *model.ProviderProfile) error {
+	if pr := p.Pricing; pr != nil {
+		pr.Model = strings.ToLower(strings.TrimSpace(pr.Model))
+		switch pr.Model {
+		case model.PricingPerTask, model.PricingPerToken, model.PricingPerHour:
+		default:
+			return errors.New("pricing.model must be per_task, per_token or per_hour")
+		}
+		pr.Currency = strings.ToUpper(strings.TrimSpace(pr.Currency))
+		if pr.Currency == "" {
+			pr.Currency = "USD"
+		}
+		if !currencyPattern.MatchString(pr.Currency) {
+			return errors.New("pricing.currency must be an ISO 4217 code")
+		}
+		if pr.Amount < 0 || pr.Minimum < 0 {
+			return errors.New("pricing amounts must not be negative")
+		}
+	}
+
+	if len(p.Regions) > maxProfileListLen {
+		return fmt.Errorf("at most %d regions are allowed", maxProfileListLen)
+	}
+	seen := map[string]bool{}
+	regions := make([]string, 0, len(p.Regions))
+	for _, r := range p.Regions {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if !regionPattern.MatchString(r) {
+			return fmt.Errorf("invalid region %q", r)
+		}
+		if !seen[r] {
+			seen[r] = true
+			regions = append(regions, r)
+		}
+	}
+	if len(p.Regions) > 0 {
+		p.Regions = regions
+	}
+
+	if p.MaxConcurrency < 0 {
+		return errors.New("max_concurrency must not be negative")
+	}
+
+	if rt := p.Runtime; rt != nil {
+		for field, list := range map[string][]string{"runtime.models": rt.Models, "runtime.tools": rt.Tools} {
+			if len(list) > maxProfileListLen {
+				return fmt.Errorf("at most %d %s are allowed", maxProfileListLen, field)
+			}
+			for _, v := range list {
+				if strings.TrimSpace(v) == "" {
+					return fmt.Errorf("%s must not contain empty values", field)
+				}
+			}
+		}
+	}
+
+	if len(p.SLACommitments) > maxProfileListLen {
+		return fmt.Errorf("at most %d sla_commitments are allowed", maxProfileListLen)
+	}
+	seen = map[string]bool{}
+	for i := range p.SLACommitments {
+		c := &p.SLACommitments[i]
+		c.Capability = strings.TrimSpace(c.Capability)
+		if c.Capability == "" {
+			return errors.New("sla_commitments capability is required")
+		}
+		if seen[c.Capability] {
+			return fmt.Errorf("duplicate sla_commitments entry for %q", c.Capability)
+		}
+		seen[c.Capability] = true
+		if c.MaxLatencyMs <= 0 {
+			return errors.New("sla_commitments max_latency_ms must be positive")
+		}
+		if c.Availability <= 0 || c.Availability > 1 {
+			return errors.New("sla_commitments availability must be in (0, 1]")
+		}
+	}
+
+	if s.profileValidator != nil {
+		return s.profileValidator(*p)
+	}
+	return nil
+}
@@ -7,27 +7,156 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
-	"path"
 	"strings"
 	"time"
 
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/clients"
 	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/store"
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/webhook"
+	"github.com/parlakisik/agent-exchange/internal/events"
+	"github.com/parlakisik/agent-exchange/internal/httpclient"
+	"github.com/parlakisik/agent-exchange/internal/taxonomy"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
 )
 
 type Service struct {
 	store     store.Store
 	allowHTTP bool
+
+	verifyEndpoints bool
+	verifyDomains   bool
+	fetchAgentCards bool
+	resolver        TXTResolver
+	verifier        *http.Client
+	jwks            *jwksCache
+	trustBroker     *clients.TrustBrokerClient
+	settlement      *clients.SettlementClient
+
+	staleAfter   time.Duration
+	offlineAfter time.Duration
+
+	adminToken    string
+	defaultLimits model.ProviderLimits
+
+	profileValidator ProfileValidator
+
+	taxonomy       *taxonomy.Taxonomy
+	strictTaxonomy bool
+
+	notifier *webhook.Notifier
+	events   *events.Publisher
+}
+
+// Options configures optional behaviour of the service.
+type Options struct {
+	// AllowHTTP allows plain HTTP URLs (development mode).
+	AllowHTTP bool
+	// VerifyEndpoints runs the endpoint challenge automatically on
+	// registration and whenever the endpoint changes.
+	VerifyEndpoints bool
+	// VerifyDomains registers new providers as PENDING_VERIFICATION until
+	// they prove control of their endpoint's domain (production mode).
+	VerifyDomains bool
+	// Resolver looks up domain verification TXT records (default
+	// net.DefaultResolver).
+	Resolver TXTResolver
+	// FetchAgentCards fetches the provider's A2A agent card on registration
+	// and rejects registrations whose capabilities it does not advertise.
+	FetchAgentCards bool
+	// TrustBrokerURL, when set, receives endpoint verification results and
+	// supplies trust scores for provider statistics.
+	TrustBrokerURL string
+	// TrustBrokerToken is sent with verification results.
+	TrustBrokerToken string
+	// SettlementURL, when set, supplies contract counts and awarded prices
+	// for provider statistics.
+	SettlementURL string
+	// HeartbeatStaleAfter and HeartbeatOfflineAfter are the heartbeat gaps
+	// after which a provider is marked STALE and OFFLINE (default 2m/10m).
+	HeartbeatStaleAfter   time.Duration
+	HeartbeatOfflineAfter time.Duration
+	// AdminToken is required as a Bearer token on /admin routes, which
+	// are disabled without one.
+	AdminToken string
+	// DefaultLimits applies to providers without a limits override.
+	DefaultLimits model.ProviderLimits
+	// WebhookMaxAttempts and WebhookBackoff control work.available delivery
+	// retries (default 5 attempts, 1s doubling).
+	WebhookMaxAttempts int
+	WebhookBackoff     time.Duration
+	// EventsURL receives provider lifecycle events on the shared event bus.
+	EventsURL string
+	// ProfileValidator, when set, runs after the built-in provider profile
+	// checks on registration.
+	ProfileValidator ProfileValidator
+	// Taxonomy is the capability taxonomy served at /v1/capabilities
+	// (default taxonomy.Default()).
+	Taxonomy *taxonomy.Taxonomy
+	// StrictTaxonomy rejects provider capabilities and subscription
+	// categories that are not in the taxonomy.
+	StrictTaxonomy bool
 }
 
 func New(st store.Store) *Service {
-	return &Service{store: st, allowHTTP: false}
+	return NewWithOptions(st, Options{})
 }
 
-func NewWithOptions(st store.Store, allowHTTP bool) *Service {
-	return &Service{store: st, allowHTTP: allowHTTP}
+func NewWithOptions(st store.Store, opts Options) *Service {
+	if opts.HeartbeatStaleAfter <= 0 {
+		opts.HeartbeatStaleAfter = 2 * time.Minute
+	}
+	if opts.HeartbeatOfflineAfter <= opts.HeartbeatStaleAfter {
+		opts.HeartbeatOfflineAfter = 5 * opts.HeartbeatStaleAfter
+	}
+	s := &Service{
+		store:            st,
+		allowHTTP:        opts.AllowHTTP,
+		verifyEndpoints:  opts.VerifyEndpoints,
+		verifyDomains:    opts.VerifyDomains,
+		fetchAgentCards:  opts.FetchAgentCards,
+		resolver:         opts.Resolver,
+		verifier:         &http.Client{Timeout: 10 * time.Second},
+		staleAfter:       opts.HeartbeatStaleAfter,
+		offlineAfter:     opts.HeartbeatOfflineAfter,
+		adminToken:       opts.AdminToken,
+		defaultLimits:    opts.DefaultLimits,
+		profileValidator: opts.ProfileValidator,
+		taxonomy:         opts.Taxonomy,
+		strictTaxonomy:   opts.StrictTaxonomy,
+		notifier:         webhook.NewNotifier(opts.WebhookMaxAttempts, opts.WebhookBackoff),
+	}
+	s.jwks = newJWKSCache(s.verifier)
+	if s.resolver == nil {
+		s.resolver = net.DefaultResolver
+	}
+	if s.taxonomy == nil {
+		s.taxonomy = taxonomy.Default()
+	}
+	if opts.TrustBrokerURL != "" {
+		var auth httpclient.AuthProvider
+		if opts.TrustBrokerToken != "" {
+			auth = &httpclient.BearerTokenAuth{Token: opts.TrustBrokerToken}
+		}
+		s.trustBroker = clients.NewTrustBrokerClient(opts.TrustBrokerURL, auth)
+	}
+	if opts.SettlementURL != "" {
+		s.settlement = clients.NewSettlementClient(opts.SettlementURL)
+	}
+	s.events = events.NewPublisher("aex-provider-registry")
+	if opts.EventsURL != "" {
+		for _, typ := range providerEventTypes {
+			s.events.RegisterEndpoint(typ, opts.EventsURL)
+		}
+	}
+	return s
 }
 
 func (s *Service) HandleRegisterProvider(w http.ResponseWriter, r *http.Request) {
@@ -52,6 +181,41 @@ func (s *Service) HandleRegisterProvider(w http.ResponseWriter, r *http.Request)
 			return
 		}
 	}
+	if err := s.validateProfile(&req.ProviderProfile); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkCapabilities(req.Capabilities); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkSLACommitments(req.Capabilities, req.SLACommitments); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.validateJWKSURL(req.JWKSURL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var clientCert *model.ClientCertificate
+	if strings.TrimSpace(req.ClientCertificate) != "" {
+		cert, err := parseClientCertificate(req.ClientCertificate, time.Now().UTC())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		clientCert = cert
+	}
+
+	tomb, err := s.registrationBlocked(r, req.Endpoint)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if tomb != nil {
+		http.Error(w, "endpoint belongs to deleted provider "+tomb.ProviderID+"; re-registration requires admin override", http.StatusConflict)
+		return
+	}
 
 	// Check if provider with same name already exists - upsert behavior
 	existing, err := s.store.GetProviderByName(ctx, req.Name)
@@ -62,20 +226,72 @@ func (s *Service) HandleRegisterProvider(w http.ResponseWriter, r *http.Request)
 
 	now := time.Now().UTC()
 
+	var card *model.AgentCard
+	var cardURL string
+	if s.fetchAgentCards {
+		fetched, u, err := s.fetchAgentCard(ctx, req.Endpoint, req.Capabilities)
+		if err != nil {
+			http.Error(w, "agent card validation failed: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		card, cardURL = &fetched, u
+	}
+
+	if existing != nil && credentialsChanged(*existing, clientCert, req.JWKSURL) {
+		// Re-registration is unauthenticated, so it must not be able to
+		// swap the credentials other services trust.
+		http.Error(w, "client credentials can only be changed with PATCH /v1/providers/me", http.StatusConflict)
+		return
+	}
+	if existing == nil && clientCert != nil {
+		owner, err := s.store.GetProviderByCertFingerprint(ctx, clientCert.FingerprintSHA256)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if owner != nil {
+			http.Error(w, "client_certificate is registered to another provider", http.StatusConflict)
+			return
+		}
+	}
+
 	if existing != nil {
 		// Update existing provider's info (keeps same provider_id and API keys)
+		prevEndpoint := existing.Endpoint
+		endpointChanged := existing.Endpoint != req.Endpoint
 		existing.Description = req.Description
 		existing.Endpoint = req.Endpoint
 		existing.BidWebhook = req.BidWebhook
 		existing.Capabilities = req.Capabilities
 		existing.ContactEmail = req.ContactEmail
 		existing.Metadata = req.Metadata
+		existing.ProviderProfile = req.ProviderProfile
 		existing.UpdatedAt = now
+		if endpointChanged {
+			resetEndpointVerification(existing)
+		}
+		if s.verifyDomains && domainChanged(*existing, prevEndpoint) {
+			requireDomainVerification(existing, now)
+		}
+		if card != nil {
+			existing.AgentCardURL = cardURL
+			existing.AgentCardFetchedAt = &now
+		}
 
 		if err := s.store.UpdateProvider(ctx, *existing); err != nil {
 			http.Error(w, "failed to update provider", http.StatusInternalServerError)
 			return
 		}
+		if card != nil {
+			if _, _, err := s.saveAgentCard(ctx, existing.ProviderID, *card); err != nil {
+				http.Error(w, "failed to save agent card", http.StatusInternalServerError)
+				return
+			}
+		}
+		if endpointChanged {
+			s.endpointChanged(*existing)
+		}
+		s.publishUpdated(*existing, endpointChanged)
 
 		// Return existing provider info (API keys masked since we only store hashes)
 		resp := model.ProviderRegistrationResponse{
@@ -86,6 +302,9 @@ func (s *Service) HandleRegisterProvider(w http.ResponseWriter, r *http.Request)
 			TrustTier:  existing.TrustTier,
 			CreatedAt:  existing.CreatedAt,
 		}
+		if dv := existing.DomainVerification; dv != nil && dv.Status != model.VerificationVerified {
+			resp.DomainVerification = domainInstructions(*existing)
+		}
 		writeJSON(w, http.StatusOK, resp)
 		return
 	}
@@ -118,27 +337,51 @@ func (s *Service) HandleRegisterProvider(w http.ResponseWriter, r *http.Request)
 	}
 
 	p := model.Provider{
-		ProviderID:    generateToken("prov_"),
-		Name:          req.Name,
-		Description:   req.Description,
-		Endpoint:      req.Endpoint,
-		BidWebhook:    req.BidWebhook,
-		Capabilities:  req.Capabilities,
-		ContactEmail:  req.ContactEmail,
-		Metadata:      req.Metadata,
-		APIKeyHash:    keyHash,
-		APISecretHash: secretHash,
-		Status:        model.ProviderStatusActive, // Option A: keep it usable immediately for local dev
-		TrustScore:    trustScore,
-		TrustTier:     trustTier,
-		CreatedAt:     now,
-		UpdatedAt:     now,
+		ProviderID:        generateToken("prov_"),
+		Name:              req.Name,
+		Description:       req.Description,
+		Endpoint:          req.Endpoint,
+		BidWebhook:        req.BidWebhook,
+		Capabilities:      req.Capabilities,
+		ContactEmail:      req.ContactEmail,
+		Metadata:          req.Metadata,
+		ProviderProfile:   req.ProviderProfile,
+		ClientCertificate: clientCert,
+		JWKSURL:           req.JWKSURL,
+		APIKeyHash:        keyHash,
+		APISecretHash:     secretHash,
+		APIKeys:           []model.APIKey{newAPIKey(apiKey, now)},
+		Status:            model.ProviderStatusActive, // Option A: keep it usable immediately for local dev
+		TrustScore:        trustScore,
+		TrustTier:         trustTier,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	resetEndpointVerification(&p)
+	if s.verifyDomains {
+		resetDomainVerification(&p)
+		p.Status = model.ProviderStatusPendingVerification
+	}
+	if card != nil {
+		p.AgentCardURL = cardURL
+		p.AgentCardFetchedAt = &now
 	}
 
 	if err := s.store.CreateProvider(ctx, p); err != nil {
 		http.Error(w, "failed to create provider", http.StatusInternalServerError)
 		return
 	}
+	if card != nil {
+		if _, _, err := s.saveAgentCard(ctx, p.ProviderID, *card); err != nil {
+			http.Error(w, "failed to save agent card", http.StatusInternalServerError)
+			return
+		}
+	}
+	if s.verifyEndpoints {
+		go s.verifyAsync(p.ProviderID)
+	}
+	s.publishRegistered(p)
 
 	resp := model.ProviderRegistrationResponse{
 		ProviderID: p.ProviderID,
@@ -148,6 +391,9 @@ func (s *Service) HandleRegisterProvider(w http.ResponseWriter, r *http.Request)
 		TrustTier:  p.TrustTier,
 		CreatedAt:  p.CreatedAt,
 	}
+	if s.verifyDomains {
+		resp.DomainVerification = domainInstructions(p)
+	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -164,8 +410,12 @@ func (s *Service) HandleCreateSubscription(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "provider_id is required", http.StatusBadRequest)
 		return
 	}
-	if len(req.Categories) == 0 {
-		http.Error(w, "categories is required", http.StatusBadRequest)
+	if err := validateCategories(req.Categories); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkCategoryPatterns(req.Categories); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	p, err := s.store.GetProvider(ctx, req.ProviderID)
@@ -177,12 +427,15 @@ func (s *Service) HandleCreateSubscription(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "provider not found", http.StatusNotFound)
 		return
 	}
+	if p.Status == model.ProviderStatusDeleted {
+		http.Error(w, "provider deleted", http.StatusGone)
+		return
+	}
 
-	if req.Delivery.Method == "webhook" && req.Delivery.WebhookURL != "" {
-		if err := s.validateURL(req.Delivery.WebhookURL); err != nil {
-			http.Error(w, "delivery.webhook_url must be a valid URL: "+err.Error(), http.StatusBadRequest)
-			return
-		}
+	delivery, err := s.normalizeDelivery(req.Delivery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	now := time.Now().UTC()
@@ -191,8 +444,8 @@ func (s *Service) HandleCreateSubscription(w http.ResponseWriter, r *http.Reques
 		ProviderID:     req.ProviderID,
 		Categories:     req.Categories,
 		Filters:        req.Filters,
-		Delivery:       req.Delivery,
-		Status:         "ACTIVE",
+		Delivery:       delivery,
+		Status:         model.SubscriptionActive,
 		CreatedAt:      now,
 	}
 	if err := s.store.CreateSubscription(ctx, sub); err != nil {
@@ -200,12 +453,15 @@ func (s *Service) HandleCreateSubscription(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	resp := model.SubscriptionResponse{
-		SubscriptionID: sub.SubscriptionID,
-		ProviderID:     sub.ProviderID,
-		Categories:     sub.Categories,
-		Status:         sub.Status,
-		CreatedAt:      sub.CreatedAt,
+	resp := model.CreateSubscriptionResponse{
+		SubscriptionResponse: model.SubscriptionResponse{
+			SubscriptionID: sub.SubscriptionID,
+			ProviderID:     sub.ProviderID,
+			Categories:     sub.Categories,
+			Status:         sub.Status,
+			CreatedAt:      sub.CreatedAt,
+		},
+		WebhookSecret: sub.Delivery.WebhookSecret,
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -232,40 +488,66 @@ func (s *Service) HandleGetProvider(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"provider_id":  p.ProviderID,
-		"name":         p.Name,
-		"endpoint":     p.Endpoint,
-		"status":       p.Status,
-		"trust_score":  p.TrustScore,
-		"trust_tier":   p.TrustTier,
-		"capabilities": p.Capabilities,
-		"created_at":   p.CreatedAt,
-		"updated_at":   p.UpdatedAt,
+		"provider_id":           p.ProviderID,
+		"name":                  p.Name,
+		"endpoint":              p.Endpoint,
+		"status":                p.Status,
+		"trust_score":           p.TrustScore,
+		"trust_tier":            p.TrustTier,
+		"capabilities":          p.Capabilities,
+		"pricing":               p.Pricing,
+		"regions":               p.Regions,
+		"max_concurrency":       p.MaxConcurrency,
+		"runtime":               p.Runtime,
+		"created_at":            p.CreatedAt,
+		"updated_at":            p.UpdatedAt,
+		"endpoint_verified":     p.EndpointVerified,
+		"endpoint_verification": p.EndpointVerification,
+		"liveness":              p.Liveness,
+		"last_heartbeat_at":     p.LastHeartbeatAt,
+		"status_reason":         p.StatusReason,
+		"status_changed_at":     p.StatusChangedAt,
+		"deleted_at":            p.DeletedAt,
 	})
 }
 
 func (s *Service) HandleListSubscriptions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	providerID := strings.TrimSpace(r.URL.Query().Get("provider_id"))
+	qs := r.URL.Query()
 
-	subs, err := s.store.ListSubscriptions(ctx)
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	q := model.SubscriptionQuery{
+		ProviderID: strings.TrimSpace(qs.Get("provider_id")),
+		Category:   strings.TrimSpace(qs.Get("category")),
+		Status:     strings.ToUpper(strings.TrimSpace(qs.Get("status"))),
+		Cursor:     strings.TrimSpace(qs.Get("cursor")),
+		Limit:      defaultListLimit,
+	}
+	switch q.Status {
+	case "", model.SubscriptionActive, model.SubscriptionPaused:
+	default:
+		http.Error(w, "status must be ACTIVE or PAUSED", http.StatusBadRequest)
 		return
 	}
-
-	result := make([]model.Subscription, 0)
-	for _, sub := range subs {
-		if providerID != "" && sub.ProviderID != providerID {
-			continue
+	if l := qs.Get("limit"); l != "" {
+		parsed, err := parseInt(l)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
 		}
-		result = append(result, sub)
+		q.Limit = min(parsed, maxListLimit)
+	}
+
+	subs, nextCursor, err := s.store.QuerySubscriptions(ctx, q)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"subscriptions": result,
-		"total":         len(result),
+		"subscriptions": subs,
+		"total":         len(subs),
+		"next_cursor":   nextCursor,
 	})
 }
 
@@ -277,71 +559,24 @@ func (s *Service) HandleInternalSubscribed(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	subs, err := s.store.ListSubscriptions(ctx)
+	matched, err := s.matchSubscribers(ctx, category)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	providerIDs := make([]string, 0)
-	type subHit struct {
-		providerID string
-		webhookURL string
-	}
-	hits := make([]subHit, 0)
-
-	for _, sub := range subs {
-		if sub.Status != "ACTIVE" {
-			continue
-		}
-		matched := false
-		for _, pat := range sub.Categories {
-			ok, err := path.Match(pat, category)
-			if err == nil && ok {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			continue
-		}
-
-		webhookURL := ""
-		if sub.Delivery.Method == "webhook" && sub.Delivery.WebhookURL != "" {
-			webhookURL = sub.Delivery.WebhookURL
-		}
-		providerIDs = append(providerIDs, sub.ProviderID)
-		hits = append(hits, subHit{providerID: sub.ProviderID, webhookURL: webhookURL})
-	}
-
-	providers, err := s.store.ListProviders(ctx, providerIDs)
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
-	}
-
-	byID := map[string]model.Provider{}
-	for _, p := range providers {
-		byID[p.ProviderID] = p
-	}
-
-	outProviders := make([]map[string]any, 0)
-	for _, h := range hits {
-		p, ok := byID[h.providerID]
-		if !ok {
-			continue
-		}
-		if p.Status != model.ProviderStatusActive {
-			continue
-		}
-		webhookURL := h.webhookURL
-		if webhookURL == "" {
-			webhookURL = p.BidWebhook
+	outProviders := make([]map[string]any, 0, len(matched))
+	for _, m := range matched {
+		method := model.DeliveryWebhook
+		if m.webhookURL == "" {
+			method = model.DeliveryPolling
 		}
 		outProviders = append(outProviders, map[string]any{
-			"provider_id": h.providerID,
-			"webhook_url": webhookURL,
-			"trust_score": p.TrustScore,
+			"provider_id":     m.provider.ProviderID,
+			"delivery_method": method,
+			"webhook_url":     m.webhookURL,
+			"trust_score":     m.provider.TrustScore,
+			"liveness":        m.provider.Liveness,
 		})
 	}
 
@@ -376,6 +611,48 @@ func (s *Service) validateURL(raw string) error {
 	return nil
 }
 
+// authorizeProvider loads the provider named by the {provider_id} path value
+// and checks the request carries its API key as a Bearer token. On failure
+// the error response has already been written.
+func (s *Service) authorizeProvider(w http.ResponseWriter, r *http.Request) (*model.Provider, bool) {
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	p, err := s.store.GetProvider(r.Context(), providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return nil, false
+	}
+	if token := bearerToken(r); token == "" || !p.KeyActive(sha256Hex(token), time.Now().UTC()) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return p, true
+}
+
+func bearerToken(r *http.Request) string {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+}
+
+// decodeOptionalJSON is decodeJSON for endpoints whose body may be empty.
+func decodeOptionalJSON(r *http.Request, v any) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Body.Close() }()
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
 func decodeJSON(r *http.Request, v any) error {
 	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
 	if err != nil {
@@ -428,6 +705,15 @@ func (s *Service) HandleValidateAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !provider.KeyActive(keyHash, time.Now().UTC()) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"valid":       false,
+			"provider_id": "",
+			"status":      "",
+		})
+		return
+	}
+
 	writeJSON(w, http.StatusOK, map[string]any{
 		"valid":       provider.Status == model.ProviderStatusActive,
 		"provider_id": provider.ProviderID,
@@ -513,39 +799,17 @@ func (s *Service) HandleRegisterAgentCard(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Derive A2A endpoint
-	a2aEndpoint := deriveA2AEndpoint(card.URL)
-
-	// Save agent card
-	if err := s.store.SaveAgentCard(ctx, providerID, card, a2aEndpoint); err != nil {
+	// Save agent card and index skills
+	a2aEndpoint, indexed, err := s.saveAgentCard(ctx, providerID, card)
+	if err != nil {
 		http.Error(w, "failed to save agent card", http.StatusInternalServerError)
 		return
 	}
 
-	// Index skills
-	skills := make([]model.SkillIndex, 0, len(card.Skills))
-	for _, skill := range card.Skills {
-		skills = append(skills, model.SkillIndex{
-			SkillID:     skill.ID,
-			SkillName:   skill.Name,
-			Description: skill.Description,
-			Tags:        skill.Tags,
-			ProviderID:  providerID,
-			AgentName:   card.Name,
-			AgentURL:    card.URL,
-			A2AEndpoint: a2aEndpoint,
-		})
-	}
-
-	if err := s.store.IndexSkills(ctx, providerID, skills); err != nil {
-		http.Error(w, "failed to index skills", http.StatusInternalServerError)
-		return
-	}
-
 	writeJSON(w, http.StatusOK, map[string]any{
 		"provider_id":    providerID,
 		"a2a_endpoint":   a2aEndpoint,
-		"skills_indexed": len(skills),
+		"skills_indexed": indexed,
 		"message":        "agent card registered successfully",
 	})
 }
@@ -577,11 +841,34 @@ func (s *Service) HandleGetProviderWithA2A(w http.ResponseWriter, r *http.Reques
 	writeJSON(w, http.StatusOK, provider)
 }
 
-// HandleListAllProviders lists all registered providers
+// HandleListAllProviders lists providers, filtered by capability, status,
+// trust tier and free text, one page at a time. Only ACTIVE providers are
+// listed unless status is given.
 func (s *Service) HandleListAllProviders(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	qs := r.URL.Query()
+
+	q := model.ProviderQuery{
+		Capability: strings.TrimSpace(qs.Get("capability")),
+		Status:     model.ProviderStatus(strings.ToUpper(strings.TrimSpace(qs.Get("status")))),
+		Tier:       model.TrustTier(strings.ToUpper(strings.TrimSpace(qs.Get("tier")))),
+		Text:       strings.TrimSpace(qs.Get("q")),
+		Cursor:     strings.TrimSpace(qs.Get("cursor")),
+		Limit:      defaultListLimit,
+	}
+	if q.Status == "" {
+		q.Status = model.ProviderStatusActive
+	}
+	if l := qs.Get("limit"); l != "" {
+		parsed, err := parseInt(l)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		q.Limit = min(parsed, maxListLimit)
+	}
 
-	providers, err := s.store.ListAllProviders(ctx)
+	providers, nextCursor, err := s.store.QueryProviders(ctx, q)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -590,24 +877,29 @@ func (s *Service) HandleListAllProviders(w http.ResponseWriter, r *http.Request)
 	// Return simplified list
 	result := make([]map[string]any, 0, len(providers))
 	for _, p := range providers {
-		if p.Status != model.ProviderStatusActive {
-			continue
-		}
 		result = append(result, map[string]any{
 			"provider_id":  p.ProviderID,
 			"name":         p.Name,
 			"description":  p.Description,
 			"endpoint":     p.Endpoint,
+			"status":       p.Status,
 			"trust_score":  p.TrustScore,
 			"trust_tier":   p.TrustTier,
 			"capabilities": p.Capabilities,
+			"pricing":      p.Pricing,
+			"regions":      p.Regions,
+			"liveness":     p.Liveness,
 		})
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"providers": result,
 		"total":     len(result),
-	})
+	}
+	if nextCursor != "" {
+		resp["next_cursor"] = nextCursor
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func deriveA2AEndpoint(agentURL string) string {
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// slaAnyCapability keys the commitment that applies to every capability
+// without an entry of its own.
+const slaAnyCapability = "*"
+
+// checkSLACommitments rejects commitments for capabilities the provider does
+// not declare, so a capability change cannot leave stale defaults behind.
+func checkSLACommitments(capabilities []string, commitments []model.SLACommitment) error {
+	var unknown []string
+	for _, c := range commitments {
+		if c.Capability != slaAnyCapability && !slices.Contains(capabilities, c.Capability) {
+			unknown = append(unknown, c.Capability)
+		}
+	}
+	if len(unknown) > 0 {
+		return errors.New("sla_commitments reference undeclared capabilities: " + strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// resolveSLA returns the most specific commitment covering category: an
+// exact capability match, then the nearest ancestor capability ("nlp" covers
+// "nlp.summarize"), then the "*" entry. An empty category only matches "*".
+func resolveSLA(commitments []model.SLACommitment, category string) *model.SLACommitment {
+	var best *model.SLACommitment
+	for i := range commitments {
+		c := &commitments[i]
+		if c.Capability == slaAnyCapability {
+			if best == nil {
+				best = c
+			}
+			continue
+		}
+		if category == "" || (category != c.Capability && !strings.HasPrefix(category, c.Capability+".")) {
+			continue
+		}
+		if best == nil || best.Capability == slaAnyCapability || len(c.Capability) > len(best.Capability) {
+			best = c
+		}
+	}
+	return best
+}
+
+// HandleInternalSLA serves the commitment the bid gateway applies to bids
+// without an SLA block and the evaluator checks bid SLAs against. It is 404
+// when the provider has no commitment covering ?category=.
+func (s *Service) HandleInternalSLA(w http.ResponseWriter, r *http.Request) {
+	p, err := s.store.GetProvider(r.Context(), strings.TrimSpace(r.PathValue("provider_id")))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+	category := strings.TrimSpace(r.URL.Query().Get("category"))
+	c := resolveSLA(p.SLACommitments, category)
+	if c == nil {
+		http.Error(w, "no sla commitment", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":    p.ProviderID,
+		"category":       category,
+		"capability":     c.Capability,
+		"max_latency_ms": c.MaxLatencyMs,
+		"availability":   c.Availability,
+	})
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/clients"
+)
+
+// Source states reported in the stats response for each upstream service.
+const (
+	statsSourceOK            = "ok"
+	statsSourceUnavailable   = "unavailable"
+	statsSourceNotConfigured = "not_configured"
+)
+
+// HandleProviderStats combines the provider's trust score and tier from the
+// trust broker with contract counts and awarded prices from settlement, so
+// consumers can vet a provider with a single call. Upstream failures are
+// reported in "sources" rather than failing the request.
+func (s *Service) HandleProviderStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, err := s.store.GetProvider(ctx, strings.TrimSpace(r.PathValue("provider_id")))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var (
+		wg           sync.WaitGroup
+		trust        clients.TrustRecord
+		settled      clients.SettlementStats
+		trustSource  = statsSourceNotConfigured
+		settleSource = statsSourceNotConfigured
+	)
+	if s.trustBroker != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if trust, err = s.trustBroker.GetTrust(ctx, p.ProviderID); err != nil {
+				log.Printf("provider stats: trust broker lookup failed provider_id=%s: %v", p.ProviderID, err)
+				trustSource = statsSourceUnavailable
+				return
+			}
+			trustSource = statsSourceOK
+		}()
+	}
+	if s.settlement != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if settled, err = s.settlement.GetProviderStats(ctx, p.ProviderID); err != nil {
+				log.Printf("provider stats: settlement lookup failed provider_id=%s: %v", p.ProviderID, err)
+				settleSource = statsSourceUnavailable
+				return
+			}
+			settleSource = statsSourceOK
+		}()
+	}
+	wg.Wait()
+
+	out := map[string]any{
+		"provider_id": p.ProviderID,
+		"name":        p.Name,
+		"status":      p.Status,
+		"trust_score": p.TrustScore,
+		"trust_tier":  p.TrustTier,
+		"sources": map[string]string{
+			"trust_broker": trustSource,
+			"settlement":   settleSource,
+		},
+	}
+	if trustSource == statsSourceOK {
+		out["trust_score"] = trust.TrustScore
+		out["trust_tier"] = trust.TrustTier
+		out["contracts"] = map[string]int{
+			"total":      trust.TotalContracts,
+			"successful": trust.SuccessfulContracts,
+			"failed":     trust.FailedContracts,
+			"disputed":   trust.DisputedContracts,
+		}
+		if trust.TotalContracts > 0 {
+			out["success_rate"] = float64(trust.SuccessfulContracts) / float64(trust.TotalContracts)
+		}
+	}
+	if settleSource == statsSourceOK {
+		out["settled_contracts"] = settled.TotalContracts
+		out["total_awarded"] = settled.TotalAwarded
+		out["average_awarded_price"] = settled.AverageAwardedPrice
+		out["currency"] = settled.Currency
+		if _, ok := out["success_rate"]; !ok && settled.TotalContracts > 0 {
+			out["success_rate"] = settled.SuccessRate
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// HandleGetSubscription returns a single subscription to its provider.
+func (s *Service) HandleGetSubscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := s.authorizeSubscription(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// HandleUpdateSubscription replaces the categories, filters and/or delivery
+// settings present in the request.
+func (s *Service) HandleUpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sub, ok := s.authorizeSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.SubscriptionUpdateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.Categories != nil {
+		if err := validateCategories(req.Categories); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkCategoryPatterns(req.Categories); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sub.Categories = req.Categories
+	}
+	if req.Filters != nil {
+		sub.Filters = *req.Filters
+	}
+	if req.Delivery != nil {
+		delivery, err := s.normalizeDelivery(*req.Delivery)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sub.Delivery = delivery
+	}
+
+	now := time.Now().UTC()
+	sub.UpdatedAt = &now
+	if err := s.store.UpdateSubscription(ctx, *sub); err != nil {
+		http.Error(w, "failed to update subscription", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// HandlePauseSubscription stops matching work for the subscription until it
+// is resumed.
+func (s *Service) HandlePauseSubscription(w http.ResponseWriter, r *http.Request) {
+	s.setSubscriptionStatus(w, r, model.SubscriptionPaused)
+}
+
+func (s *Service) HandleResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	s.setSubscriptionStatus(w, r, model.SubscriptionActive)
+}
+
+func (s *Service) HandleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sub, ok := s.authorizeSubscription(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteSubscription(ctx, sub.SubscriptionID); err != nil {
+		http.Error(w, "failed to delete subscription", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) setSubscriptionStatus(w http.ResponseWriter, r *http.Request, status string) {
+	ctx := r.Context()
+	sub, ok := s.authorizeSubscription(w, r)
+	if !ok {
+		return
+	}
+	if sub.Status != status {
+		now := time.Now().UTC()
+		sub.Status = status
+		sub.UpdatedAt = &now
+		if err := s.store.UpdateSubscription(ctx, *sub); err != nil {
+			http.Error(w, "failed to update subscription", http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, model.SubscriptionResponse{
+		SubscriptionID: sub.SubscriptionID,
+		ProviderID:     sub.ProviderID,
+		Categories:     sub.Categories,
+		Status:         sub.Status,
+		CreatedAt:      sub.CreatedAt,
+	})
+}
+
+// authorizeSubscription loads the subscription named by the
+// {subscription_id} path value and checks the request carries an active API
+// key of the provider that owns it.
+func (s *Service) authorizeSubscription(w http.ResponseWriter, r *http.Request) (*model.Subscription, bool) {
+	ctx := r.Context()
+	sub, err := s.store.GetSubscription(ctx, strings.TrimSpace(r.PathValue("subscription_id")))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if sub == nil {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return nil, false
+	}
+	p, err := s.store.GetProvider(ctx, sub.ProviderID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	token := bearerToken(r)
+	if p == nil || token == "" || !p.KeyActive(sha256Hex(token), time.Now().UTC()) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return sub, true
+}
+
+// subscriber is an active subscription of an eligible provider matched to a
+// category. webhookURL is empty for polling delivery.
+type subscriber struct {
+	sub        model.Subscription
+	provider   model.Provider
+	webhookURL string
+}
+
+// matchSubscribers returns the active subscriptions covering category whose
+// provider is ACTIVE and not OFFLINE.
+func (s *Service) matchSubscribers(ctx context.Context, category string) ([]subscriber, error) {
+	hits, _, err := s.store.QuerySubscriptions(ctx, model.SubscriptionQuery{
+		Category: category,
+		Status:   model.SubscriptionActive,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	providerIDs := make([]string, 0, len(hits))
+	for _, sub := range hits {
+		providerIDs = append(providerIDs, sub.ProviderID)
+	}
+
+	providers, err := s.store.ListProviders(ctx, providerIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]model.Provider{}
+	for _, p := range providers {
+		byID[p.ProviderID] = p
+	}
+
+	out := make([]subscriber, 0, len(hits))
+	for _, sub := range hits {
+		p, ok := byID[sub.ProviderID]
+		if !ok || p.Status != model.ProviderStatusActive || p.Liveness == model.LivenessOffline {
+			continue
+		}
+		// Polling subscriptions never get a webhook, not even the provider
+		// default.
+		webhookURL := ""
+		if sub.Delivery.Method != model.DeliveryPolling {
+			webhookURL = sub.Delivery.WebhookURL
+			if webhookURL == "" {
+				webhookURL = p.BidWebhook
+			}
+		}
+		out = append(out, subscriber{sub: sub, provider: p, webhookURL: webhookURL})
+	}
+	return out, nil
+}
+
+// normalizeDelivery validates the delivery preference. "poll" is accepted
+// as an alias for polling; an empty method keeps the legacy behaviour of
+// falling back to the provider's bid_webhook.
+func (s *Service) normalizeDelivery(req model.DeliveryRequest) (model.DeliveryConfig, error) {
+	d := model.DeliveryConfig{
+		Method:        strings.ToLower(strings.TrimSpace(req.Method)),
+		WebhookURL:    strings.TrimSpace(req.WebhookURL),
+		WebhookSecret: req.WebhookSecret,
+	}
+	switch d.Method {
+	case "poll":
+		d.Method = model.DeliveryPolling
+	case "", model.DeliveryWebhook, model.DeliveryPolling:
+	default:
+		return model.DeliveryConfig{}, errors.New("delivery.method must be webhook or polling")
+	}
+	if d.Method == model.DeliveryPolling {
+		return model.DeliveryConfig{Method: d.Method}, nil
+	}
+	if d.WebhookURL != "" {
+		if err := s.validateURL(d.WebhookURL); err != nil {
+			return model.DeliveryConfig{}, errors.New("delivery.webhook_url must be a valid URL: " + err.Error())
+		}
+	}
+	return d, nil
+}
+
+func validateCategories(categories []string) error {
+	if len(categories) == 0 {
+		return errors.New("categories is required")
+	}
+	for _, c := range categories {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			return errors.New("categories must not be empty")
+		}
+		if _, err := path.Match(c, ""); err != nil {
+			return errors.New("invalid category pattern: " + c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// HandleListCapabilities serves the capability taxonomy shared with the
+// work publisher. Provider capabilities and subscription categories should
+// use its ids so that they intersect with published work categories.
+func (s *Service) HandleListCapabilities(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"version":    s.taxonomy.Version,
+		"strict":     s.strictTaxonomy,
+		"categories": s.taxonomy.Categories,
+		"ids":        s.taxonomy.IDs(),
+	})
+}
+
+// checkCapabilities rejects capabilities outside the taxonomy when strict
+// taxonomy validation is enabled.
+func (s *Service) checkCapabilities(capabilities []string) error {
+	if !s.strictTaxonomy {
+		return nil
+	}
+	if unknown := s.taxonomy.Unknown(capabilities); len(unknown) > 0 {
+		return errors.New("unknown capabilities: " + strings.Join(unknown, ", ") + " (see GET /v1/capabilities)")
+	}
+	return nil
+}
+
+// checkCategoryPatterns rejects subscription patterns that match no
+// taxonomy category when strict taxonomy validation is enabled.
+func (s *Service) checkCategoryPatterns(patterns []string) error {
+	if !s.strictTaxonomy {
+		return nil
+	}
+	var unknown []string
+	for _, p := range patterns {
+		if !s.taxonomy.Covers(p) {
+			unknown = append(unknown, p)
+		}
+	}
+	if len(unknown) > 0 {
+		return errors.New("categories match no capability: " + strings.Join(unknown, ", ") + " (see GET /v1/capabilities)")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/clients"
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+)
+
+// ChallengeType identifies endpoint verification requests so providers can
+// route them apart from regular A2A traffic.
+const ChallengeType = "aex.endpoint_verification"
+
+// HandleVerifyEndpoint runs the challenge-response check synchronously.
+// The caller must authenticate with the provider's API key.
+func (s *Service) HandleVerifyEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := s.verifyEndpoint(ctx, *p)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":           updated.ProviderID,
+		"endpoint":              updated.Endpoint,
+		"endpoint_verified":     updated.EndpointVerified,
+		"endpoint_verification": updated.EndpointVerification,
+	})
+}
+
+// endpointChanged is called after a provider's endpoint was replaced. The
+// stored verification has already been reset; the trust broker is told and a
+// new check is scheduled when automatic verification is enabled.
+func (s *Service) endpointChanged(p model.Provider) {
+	if s.verifyEndpoints {
+		go s.verifyAsync(p.ProviderID)
+		return
+	}
+	go s.syncTrustBroker(p.ProviderID, false)
+}
+
+func (s *Service) verifyAsync(providerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	p, err := s.store.GetProvider(ctx, providerID)
+	if err != nil || p == nil {
+		return
+	}
+	if _, err := s.verifyEndpoint(ctx, *p); err != nil {
+		log.Printf("endpoint verification failed provider_id=%s: %v", providerID, err)
+	}
+}
+
+// verifyEndpoint challenges p.Endpoint and stores the outcome. The returned
+// error is only set when the result could not be persisted; a provider that
+// fails the challenge is recorded as FAILED.
+func (s *Service) verifyEndpoint(ctx context.Context, p model.Provider) (model.Provider, error) {
+	endpoint := p.Endpoint
+	challengeErr := s.challenge(ctx, p)
+
+	// Re-read so a concurrent registration update is not overwritten, and
+	// discard the result if the endpoint moved while we were checking it.
+	cur, err := s.store.GetProvider(ctx, p.ProviderID)
+	if err != nil {
+		return p, err
+	}
+	if cur == nil {
+		return p, errors.New("provider not found")
+	}
+	if cur.Endpoint != endpoint {
+		return *cur, nil
+	}
+
+	now := time.Now().UTC()
+	v := cur.EndpointVerification
+	if v == nil || v.Endpoint != endpoint {
+		v = &model.EndpointVerification{Endpoint: endpoint}
+	}
+	v.Attempts++
+	v.CheckedAt = &now
+	if challengeErr == nil {
+		v.Status = model.VerificationVerified
+		v.LastError = ""
+		v.VerifiedAt = &now
+	} else {
+		v.Status = model.VerificationFailed
+		v.LastError = challengeErr.Error()
+		v.VerifiedAt = nil
+	}
+	cur.EndpointVerification = v
+	cur.EndpointVerified = challengeErr == nil
+	cur.UpdatedAt = now
+
+	if err := s.store.UpdateProvider(ctx, *cur); err != nil {
+		return *cur, err
+	}
+	go s.syncTrustBroker(cur.ProviderID, cur.EndpointVerified)
+	return *cur, nil
+}
+
+// challenge POSTs a fresh nonce to the provider endpoint and checks that the
+// response echoes it with a valid signature.
+func (s *Service) challenge(ctx context.Context, p model.Provider) error {
+	if err := s.validateURL(p.Endpoint); err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	nonce := generateToken("nonce_")
+	body, err := json.Marshal(model.EndpointChallenge{
+		Type:       ChallengeType,
+		ProviderID: p.ProviderID,
+		Nonce:      nonce,
+		IssuedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.verifier.Do(req)
+	if err != nil {
+		return fmt.Errorf("endpoint unreachable: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+
+	var echo model.EndpointChallengeResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&echo); err != nil {
+		return errors.New("invalid challenge response")
+	}
+	if echo.Nonce != nonce {
+		return errors.New("nonce mismatch")
+	}
+	expected := ChallengeSignature(p.APISecretHash, nonce)
+	if !hmac.Equal([]byte(strings.ToLower(echo.Signature)), []byte(expected)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// ChallengeSignature returns the expected signature for nonce. The key is
+// the hex SHA-256 of the provider's API secret, which is all the registry
+// keeps; providers derive it from the secret they were issued.
+func ChallengeSignature(secretHash, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secretHash))
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) syncTrustBroker(providerID string, verified bool) {
+	if s.trustBroker == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	update := clients.VerificationUpdate{EndpointVerified: &verified, Source: clients.VerificationSource, Method: "endpoint_challenge"}
+	if err := s.trustBroker.SetVerification(ctx, providerID, update); err != nil {
+		log.Printf("trust broker verification sync failed provider_id=%s: %v", providerID, err)
+	}
+}
+
+func resetEndpointVerification(p *model.Provider) {
+	p.EndpointVerified = false
+	p.EndpointVerification = &model.EndpointVerification{
+		Status:   model.VerificationPending,
+		Endpoint: p.Endpoint,
+	}
+}
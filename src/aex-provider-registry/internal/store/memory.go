@@ -2,6 +2,9 @@ package store
 
 import (
 	"context"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -56,6 +59,12 @@ func (s *MemoryStore) GetProviderByAPIKeyHash(ctx context.Context, apiKeyHash st
 			out := p
 			return &out, nil
 		}
+		for _, k := range p.APIKeys {
+			if k.Hash == apiKeyHash {
+				out := p
+				return &out, nil
+			}
+		}
 	}
 	return nil, nil
 }
@@ -65,7 +74,33 @@ func (s *MemoryStore) GetProviderByName(ctx context.Context, name string) (*mode
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	for _, p := range s.providers {
-		if p.Name == name {
+		if p.Name == name && p.Status != model.ProviderStatusDeleted {
+			out := p
+			return &out, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) GetProviderByCertFingerprint(ctx context.Context, fingerprint string) (*model.Provider, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.providers {
+		if p.ClientCertificate != nil && p.ClientCertificate.FingerprintSHA256 == fingerprint {
+			out := p
+			return &out, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) GetDeletedProviderByEndpoint(ctx context.Context, endpoint string) (*model.Provider, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.providers {
+		if p.Endpoint == endpoint && p.Status == model.ProviderStatusDeleted {
 			out := p
 			return &out, nil
 		}
@@ -94,15 +129,61 @@ func (s *MemoryStore) CreateSubscription(ctx context.Context, sub model.Subscrip
 	return nil
 }
 
-func (s *MemoryStore) ListSubscriptions(ctx context.Context) ([]model.Subscription, error) {
+func (s *MemoryStore) GetSubscription(ctx context.Context, subscriptionID string) (*model.Subscription, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub, ok := s.subscriptions[subscriptionID]
+	if !ok {
+		return nil, nil
+	}
+	return &sub, nil
+}
+
+func (s *MemoryStore) UpdateSubscription(ctx context.Context, sub model.Subscription) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscriptions[sub.SubscriptionID]; !ok {
+		return nil
+	}
+	s.subscriptions[sub.SubscriptionID] = sub
+	return nil
+}
+
+func (s *MemoryStore) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscriptions, subscriptionID)
+	return nil
+}
+
+func (s *MemoryStore) QuerySubscriptions(ctx context.Context, q model.SubscriptionQuery) ([]model.Subscription, string, error) {
 	_ = ctx
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	out := make([]model.Subscription, 0, len(s.subscriptions))
+	matched := make([]model.Subscription, 0)
 	for _, sub := range s.subscriptions {
-		out = append(out, sub)
+		if q.Cursor != "" && sub.SubscriptionID <= q.Cursor {
+			continue
+		}
+		if q.ProviderID != "" && sub.ProviderID != q.ProviderID {
+			continue
+		}
+		if q.Status != "" && sub.Status != q.Status {
+			continue
+		}
+		if q.Category != "" && !coversCategory(sub.Categories, q.Category) {
+			continue
+		}
+		matched = append(matched, sub)
 	}
-	return out, nil
+	sort.Slice(matched, func(i, j int) bool { return matched[i].SubscriptionID < matched[j].SubscriptionID })
+	if q.Limit > 0 && len(matched) > q.Limit {
+		return matched[:q.Limit], matched[q.Limit-1].SubscriptionID, nil
+	}
+	return matched, "", nil
 }
 
 func (s *MemoryStore) ListAllProviders(ctx context.Context) ([]model.Provider, error) {
@@ -116,6 +197,37 @@ func (s *MemoryStore) ListAllProviders(ctx context.Context) ([]model.Provider, e
 	return out, nil
 }
 
+func (s *MemoryStore) QueryProviders(ctx context.Context, q model.ProviderQuery) ([]model.Provider, string, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	text := strings.ToLower(strings.TrimSpace(q.Text))
+	matched := make([]model.Provider, 0)
+	for _, p := range s.providers {
+		if q.Cursor != "" && p.ProviderID <= q.Cursor {
+			continue
+		}
+		if q.Status != "" && p.Status != q.Status {
+			continue
+		}
+		if q.Tier != "" && p.TrustTier != q.Tier {
+			continue
+		}
+		if q.Capability != "" && !slices.Contains(p.Capabilities, q.Capability) {
+			continue
+		}
+		if text != "" && !strings.Contains(strings.ToLower(p.Name+" "+p.Description), text) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ProviderID < matched[j].ProviderID })
+	if q.Limit > 0 && len(matched) > q.Limit {
+		return matched[:q.Limit], matched[q.Limit-1].ProviderID, nil
+	}
+	return matched, "", nil
+}
+
 func (s *MemoryStore) UpdateProvider(ctx context.Context, p model.Provider) error {
 	_ = ctx
 	s.mu.Lock()
@@ -127,6 +239,23 @@ func (s *MemoryStore) UpdateProvider(ctx context.Context, p model.Provider) erro
 	return nil
 }
 
+func (s *MemoryStore) SetLiveness(ctx context.Context, providerID string, liveness model.Liveness, lastHeartbeat *time.Time) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.providers[providerID]
+	if !ok {
+		return nil
+	}
+	p.Liveness = liveness
+	if lastHeartbeat != nil {
+		t := *lastHeartbeat
+		p.LastHeartbeatAt = &t
+	}
+	s.providers[providerID] = p
+	return nil
+}
+
 func (s *MemoryStore) SaveAgentCard(ctx context.Context, providerID string, card model.AgentCard, a2aEndpoint string) error {
 	_ = ctx
 	s.mu.Lock()
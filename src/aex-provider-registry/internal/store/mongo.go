@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/taxonomy"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -42,6 +43,32 @@ func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	_, err = s.providers.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "api_keys.hash", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.providers.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "client_certificate.fingerprint_sha256", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+	// Provider listing filters: capabilities is a multikey array index and
+	// name/description back the q= text search.
+	_, err = s.providers.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "capabilities", Value: 1}, {Key: "provider_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "trust_tier", Value: 1}, {Key: "provider_id", Value: 1}}},
+		{Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}}},
+		// Tombstone lookup on re-registration.
+		{Keys: bson.D{{Key: "endpoint", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
 	_, err = s.subs.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys:    bson.D{{Key: "subscription_id", Value: 1}},
 		Options: options.Index().SetUnique(true),
@@ -49,6 +76,16 @@ func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	// Subscription listing filters and the category fan-out lookup.
+	_, err = s.subs.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "provider_id", Value: 1}, {Key: "subscription_id", Value: 1}}},
+		{Keys: bson.D{{Key: "categories", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "glob_categories", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "subscription_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
 	// A2A indexes
 	_, err = s.agentCards.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys:    bson.D{{Key: "provider_id", Value: 1}},
@@ -96,7 +133,10 @@ func (s *MongoStore) GetProvider(ctx context.Context, providerID string) (*model
 func (s *MongoStore) GetProviderByAPIKeyHash(ctx context.Context, apiKeyHash string) (*model.Provider, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	res := s.providers.FindOne(ctx, bson.M{"api_key_hash": apiKeyHash})
+	res := s.providers.FindOne(ctx, bson.M{"$or": bson.A{
+		bson.M{"api_key_hash": apiKeyHash},
+		bson.M{"api_keys.hash": apiKeyHash},
+	}})
 	if res.Err() == mongo.ErrNoDocuments {
 		return nil, nil
 	}
@@ -113,7 +153,41 @@ func (s *MongoStore) GetProviderByAPIKeyHash(ctx context.Context, apiKeyHash str
 func (s *MongoStore) GetProviderByName(ctx context.Context, name string) (*model.Provider, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	res := s.providers.FindOne(ctx, bson.M{"name": name})
+	res := s.providers.FindOne(ctx, bson.M{"name": name, "status": bson.M{"$ne": model.ProviderStatusDeleted}})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var p model.Provider
+	if err := res.Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *MongoStore) GetProviderByCertFingerprint(ctx context.Context, fingerprint string) (*model.Provider, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.providers.FindOne(ctx, bson.M{"client_certificate.fingerprint_sha256": fingerprint})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var p model.Provider
+	if err := res.Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *MongoStore) GetDeletedProviderByEndpoint(ctx context.Context, endpoint string) (*model.Provider, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.providers.FindOne(ctx, bson.M{"endpoint": endpoint, "status": model.ProviderStatusDeleted})
 	if res.Err() == mongo.ErrNoDocuments {
 		return nil, nil
 	}
@@ -152,30 +226,96 @@ func (s *MongoStore) ListProviders(ctx context.Context, providerIDs []string) ([
 func (s *MongoStore) CreateSubscription(ctx context.Context, sub model.Subscription) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	sub.GlobCategories = hasGlobCategories(sub.Categories)
 	_, err := s.subs.InsertOne(ctx, sub)
 	return err
 }
 
-func (s *MongoStore) ListSubscriptions(ctx context.Context) ([]model.Subscription, error) {
+func (s *MongoStore) GetSubscription(ctx context.Context, subscriptionID string) (*model.Subscription, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	cur, err := s.subs.Find(ctx, bson.M{})
-	if err != nil {
+	res := s.subs.FindOne(ctx, bson.M{"subscription_id": subscriptionID})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var sub model.Subscription
+	if err := res.Decode(&sub); err != nil {
 		return nil, err
 	}
+	return &sub, nil
+}
+
+func (s *MongoStore) UpdateSubscription(ctx context.Context, sub model.Subscription) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	sub.GlobCategories = hasGlobCategories(sub.Categories)
+	_, err := s.subs.UpdateOne(ctx,
+		bson.M{"subscription_id": sub.SubscriptionID},
+		bson.M{"$set": sub},
+	)
+	return err
+}
+
+func (s *MongoStore) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.subs.DeleteOne(ctx, bson.M{"subscription_id": subscriptionID})
+	return err
+}
+
+// QuerySubscriptions narrows a category query with the categories index to
+// subscriptions holding one of the category's literal patterns, plus those
+// flagged as using globs (or written before the flag existed), and checks
+// the remaining candidates with taxonomy.Match.
+func (s *MongoStore) QuerySubscriptions(ctx context.Context, q model.SubscriptionQuery) ([]model.Subscription, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	filter := bson.M{}
+	if q.Cursor != "" {
+		filter["subscription_id"] = bson.M{"$gt": q.Cursor}
+	}
+	if q.ProviderID != "" {
+		filter["provider_id"] = q.ProviderID
+	}
+	if q.Status != "" {
+		filter["status"] = q.Status
+	}
+	if q.Category != "" {
+		filter["$or"] = bson.A{
+			bson.M{"categories": bson.M{"$in": taxonomy.LiteralPatterns(q.Category)}},
+			bson.M{"glob_categories": bson.M{"$ne": false}},
+		}
+	}
+	cur, err := s.subs.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "subscription_id", Value: 1}}))
+	if err != nil {
+		return nil, "", err
+	}
 	defer func() { _ = cur.Close(ctx) }()
-	var out []model.Subscription
+	out := make([]model.Subscription, 0)
 	for cur.Next(ctx) {
 		var sub model.Subscription
 		if err := cur.Decode(&sub); err != nil {
-			return nil, err
+			return nil, "", err
+		}
+		if q.Category != "" && !coversCategory(sub.Categories, q.Category) {
+			continue
 		}
 		out = append(out, sub)
+		if q.Limit > 0 && len(out) > q.Limit {
+			break
+		}
 	}
 	if err := cur.Err(); err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return out, nil
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+		return out, out[len(out)-1].SubscriptionID, nil
+	}
+	return out, "", nil
 }
 
 func (s *MongoStore) ListAllProviders(ctx context.Context) ([]model.Provider, error) {
@@ -197,6 +337,52 @@ func (s *MongoStore) ListAllProviders(ctx context.Context) ([]model.Provider, er
 	return out, cur.Err()
 }
 
+func (s *MongoStore) QueryProviders(ctx context.Context, q model.ProviderQuery) ([]model.Provider, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	filter := bson.M{}
+	if q.Cursor != "" {
+		filter["provider_id"] = bson.M{"$gt": q.Cursor}
+	}
+	if q.Status != "" {
+		filter["status"] = q.Status
+	}
+	if q.Tier != "" {
+		filter["trust_tier"] = q.Tier
+	}
+	if q.Capability != "" {
+		filter["capabilities"] = q.Capability
+	}
+	if q.Text != "" {
+		filter["$text"] = bson.M{"$search": q.Text}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "provider_id", Value: 1}})
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit) + 1)
+	}
+	cur, err := s.providers.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+	out := make([]model.Provider, 0)
+	for cur.Next(ctx) {
+		var p model.Provider
+		if err := cur.Decode(&p); err != nil {
+			return nil, "", err
+		}
+		out = append(out, p)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, "", err
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+		return out, out[len(out)-1].ProviderID, nil
+	}
+	return out, "", nil
+}
+
 func (s *MongoStore) UpdateProvider(ctx context.Context, p model.Provider) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
@@ -207,6 +393,17 @@ func (s *MongoStore) UpdateProvider(ctx context.Context, p model.Provider) error
 	return err
 }
 
+func (s *MongoStore) SetLiveness(ctx context.Context, providerID string, liveness model.Liveness, lastHeartbeat *time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	set := bson.M{"liveness": liveness}
+	if lastHeartbeat != nil {
+		set["last_heartbeat_at"] = *lastHeartbeat
+	}
+	_, err := s.providers.UpdateOne(ctx, bson.M{"provider_id": providerID}, bson.M{"$set": set})
+	return err
+}
+
 // agentCardDoc wraps AgentCard with provider info for storage
 type agentCardDoc struct {
 	ProviderID  string          `bson:"provider_id"`
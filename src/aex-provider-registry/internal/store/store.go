@@ -2,21 +2,40 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/taxonomy"
 )
 
 type Store interface {
 	CreateProvider(ctx context.Context, p model.Provider) error
 	GetProvider(ctx context.Context, providerID string) (*model.Provider, error)
+	// GetProviderByName ignores deleted providers.
 	GetProviderByName(ctx context.Context, name string) (*model.Provider, error)
+	// GetDeletedProviderByEndpoint returns the tombstone of a deleted
+	// provider registered with endpoint, if any.
+	GetDeletedProviderByEndpoint(ctx context.Context, endpoint string) (*model.Provider, error)
 	GetProviderByAPIKeyHash(ctx context.Context, apiKeyHash string) (*model.Provider, error)
+	GetProviderByCertFingerprint(ctx context.Context, fingerprint string) (*model.Provider, error)
 	ListProviders(ctx context.Context, providerIDs []string) ([]model.Provider, error)
 	ListAllProviders(ctx context.Context) ([]model.Provider, error)
+	// QueryProviders returns up to q.Limit providers matching q and the
+	// cursor for the next page, or "" when there are no more results.
+	QueryProviders(ctx context.Context, q model.ProviderQuery) ([]model.Provider, string, error)
 	UpdateProvider(ctx context.Context, p model.Provider) error
+	// SetLiveness updates the liveness status, and the heartbeat time when
+	// lastHeartbeat is non-nil, without touching the rest of the record.
+	SetLiveness(ctx context.Context, providerID string, liveness model.Liveness, lastHeartbeat *time.Time) error
 
 	CreateSubscription(ctx context.Context, s model.Subscription) error
-	ListSubscriptions(ctx context.Context) ([]model.Subscription, error)
+	// QuerySubscriptions returns up to q.Limit subscriptions matching q,
+	// ordered by id, and the cursor for the next page or "" at the end.
+	// A zero limit returns every match.
+	QuerySubscriptions(ctx context.Context, q model.SubscriptionQuery) ([]model.Subscription, string, error)
+	GetSubscription(ctx context.Context, subscriptionID string) (*model.Subscription, error)
+	UpdateSubscription(ctx context.Context, s model.Subscription) error
+	DeleteSubscription(ctx context.Context, subscriptionID string) error
 
 	// A2A support
 	SaveAgentCard(ctx context.Context, providerID string, card model.AgentCard, a2aEndpoint string) error
@@ -24,3 +43,21 @@ type Store interface {
 	IndexSkills(ctx context.Context, providerID string, skills []model.SkillIndex) error
 	SearchBySkillTags(ctx context.Context, tags []string, minTrust float64, limit int) ([]model.ProviderSearchResult, error)
 }
+
+func coversCategory(patterns []string, category string) bool {
+	for _, p := range patterns {
+		if taxonomy.Match(p, category) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGlobCategories(patterns []string) bool {
+	for _, p := range patterns {
+		if taxonomy.IsGlob(p) {
+			return true
+		}
+	}
+	return false
+}
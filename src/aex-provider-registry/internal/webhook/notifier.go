@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, prefixed
+// with "sha256=", so providers can verify notifications came from us.
+const SignatureHeader = "X-AEX-Signature"
+
+// EventWorkAvailable announces new work in a subscribed category.
+const EventWorkAvailable = "work.available"
+
+// maxDeliveriesPerSubscription bounds the in-memory delivery log.
+const maxDeliveriesPerSubscription = 100
+
+type Event struct {
+	EventID        string         `json:"event_id"`
+	Type           string         `json:"type"`
+	SubscriptionID string         `json:"subscription_id"`
+	ProviderID     string         `json:"provider_id"`
+	Timestamp      time.Time      `json:"timestamp"`
+	Data           map[string]any `json:"data,omitempty"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+type Delivery struct {
+	EventID       string         `json:"event_id"`
+	Type          string         `json:"type"`
+	URL           string         `json:"url"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      int            `json:"attempts"`
+	LastError     string         `json:"last_error,omitempty"`
+	LastAttemptAt *time.Time     `json:"last_attempt_at,omitempty"`
+	DeliveredAt   *time.Time     `json:"delivered_at,omitempty"`
+}
+
+// Notifier delivers events asynchronously with exponential backoff and keeps
+// an in-memory record of recent deliveries per subscription.
+type Notifier struct {
+	http        *http.Client
+	maxAttempts int
+	backoff     time.Duration
+
+	mu         sync.RWMutex
+	deliveries map[string][]*Delivery
+}
+
+func NewNotifier(maxAttempts int, backoff time.Duration) *Notifier {
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	return &Notifier{
+		http:        &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		deliveries:  map[string][]*Delivery{},
+	}
+}
+
+// Notify queues ev for delivery to url, signed with secret, and returns
+// immediately.
+func (n *Notifier) Notify(url, secret string, ev Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("webhook marshal failed subscription_id=%s: %v", ev.SubscriptionID, err)
+		return
+	}
+	d := &Delivery{EventID: ev.EventID, Type: ev.Type, URL: url, Status: DeliveryPending}
+	n.mu.Lock()
+	list := append(n.deliveries[ev.SubscriptionID], d)
+	if len(list) > maxDeliveriesPerSubscription {
+		list = list[len(list)-maxDeliveriesPerSubscription:]
+	}
+	n.deliveries[ev.SubscriptionID] = list
+	n.mu.Unlock()
+
+	go n.deliver(d, []byte(secret), body)
+}
+
+// Deliveries returns a snapshot of the delivery records for a subscription,
+// oldest first.
+func (n *Notifier) Deliveries(subscriptionID string) []Delivery {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	out := make([]Delivery, 0, len(n.deliveries[subscriptionID]))
+	for _, d := range n.deliveries[subscriptionID] {
+		out = append(out, *d)
+	}
+	return out
+}
+
+func (n *Notifier) deliver(d *Delivery, secret []byte, body []byte) {
+	wait := n.backoff
+	for attempt := 1; attempt <= n.maxAttempts; attempt++ {
+		err := n.post(d.URL, secret, body)
+		now := time.Now().UTC()
+
+		n.mu.Lock()
+		d.Attempts = attempt
+		d.LastAttemptAt = &now
+		if err == nil {
+			d.Status = DeliveryDelivered
+			d.DeliveredAt = &now
+			d.LastError = ""
+		} else {
+			d.LastError = err.Error()
+			if attempt == n.maxAttempts {
+				d.Status = DeliveryFailed
+			}
+		}
+		n.mu.Unlock()
+
+		if err == nil {
+			return
+		}
+		if attempt < n.maxAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	log.Printf("webhook delivery failed event_id=%s url=%s attempts=%d", d.EventID, d.URL, n.maxAttempts)
+}
+
+func (n *Notifier) post(url string, secret []byte, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
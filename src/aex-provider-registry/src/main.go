@@ -11,8 +11,10 @@ import (
 
 	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/config"
 	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/httpapi"
+	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/service"
 	"github.com/parlakisik/agent-exchange/aex-provider-registry/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/taxonomy"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -42,10 +44,42 @@ func main() {
 		log.Printf("mongo disabled (set MONGO_URI to enable)")
 	}
 
-	svc := service.NewWithOptions(st, cfg.AllowHTTP)
+	tax := taxonomy.Default()
+	if cfg.TaxonomyFile != "" {
+		t, err := taxonomy.Load(cfg.TaxonomyFile)
+		if err != nil {
+			log.Fatalf("load taxonomy: %v", err)
+		}
+		tax = t
+	}
+
+	svc := service.NewWithOptions(st, service.Options{
+		AllowHTTP:             cfg.AllowHTTP,
+		VerifyEndpoints:       cfg.VerifyEndpoints,
+		VerifyDomains:         cfg.VerifyDomains,
+		FetchAgentCards:       cfg.FetchAgentCards,
+		TrustBrokerURL:        cfg.TrustBrokerURL,
+		TrustBrokerToken:      cfg.TrustBrokerToken,
+		SettlementURL:         cfg.SettlementURL,
+		HeartbeatStaleAfter:   cfg.HeartbeatStaleAfter,
+		HeartbeatOfflineAfter: cfg.HeartbeatOfflineAfter,
+		AdminToken:            cfg.AdminToken,
+		DefaultLimits: model.ProviderLimits{
+			MaxBidsPerMinute:       cfg.DefaultMaxBidsPerMinute,
+			MaxConcurrentContracts: cfg.DefaultMaxConcurrentContracts,
+		},
+		EventsURL:          cfg.EventsURL,
+		Taxonomy:           tax,
+		StrictTaxonomy:     cfg.StrictTaxonomy,
+		WebhookMaxAttempts: cfg.WebhookMaxAttempts,
+		WebhookBackoff:     cfg.WebhookBackoff,
+	})
 	if cfg.AllowHTTP {
 		log.Printf("WARNING: HTTP URLs allowed (development mode)")
 	}
+	if cfg.AdminToken == "" {
+		log.Printf("WARNING: admin routes are disabled (set ADMIN_TOKEN)")
+	}
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      httpapi.NewRouter(svc),
@@ -54,6 +88,10 @@ func main() {
 		IdleTimeout:  cfg.IdleTimeout,
 	}
 
+	checkerCtx, stopChecker := context.WithCancel(context.Background())
+	defer stopChecker()
+	go svc.RunLivenessChecker(checkerCtx, cfg.LivenessCheckInterval)
+
 	go func() {
 		log.Printf("listening on :%s", cfg.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -5,6 +5,7 @@ go 1.24.0
 require (
 	github.com/parlakisik/agent-exchange/internal/ap2 v0.0.0
 	github.com/parlakisik/agent-exchange/internal/events v0.0.0
+	github.com/parlakisik/agent-exchange/internal/serviceauth v0.0.0
 	github.com/shopspring/decimal v1.3.1
 	go.mongodb.org/mongo-driver v1.13.1
 )
@@ -13,6 +14,8 @@ replace github.com/parlakisik/agent-exchange/internal/ap2 => ../internal/ap2
 
 replace github.com/parlakisik/agent-exchange/internal/events => ../internal/events
 
+replace github.com/parlakisik/agent-exchange/internal/serviceauth => ../internal/serviceauth
+
 require (
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/klauspost/compress v1.13.6 // indirect
@@ -10,6 +10,10 @@ type Config struct {
 	StoreType   string
 	MongoURI    string
 	MongoDB     string
+
+	// IdentityURL, when set, makes settling a contract require a service
+	// account token issued by the identity service.
+	IdentityURL string
 }
 
 func Load() (*Config, error) {
@@ -19,6 +23,7 @@ func Load() (*Config, error) {
 		StoreType:   getEnv("STORE_TYPE", "mongo"),
 		MongoURI:    getEnv("MONGO_URI", "mongodb://localhost:27017"),
 		MongoDB:     getEnv("MONGO_DB", "aex"),
+		IdentityURL: getEnv("IDENTITY_URL", ""),
 	}
 
 	return cfg, nil
@@ -2,6 +2,7 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -91,6 +92,25 @@ func (h *Handlers) GetTransactions(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, transactions)
 }
 
+// GetProviderStats returns contract counts and awarded prices for a provider
+// GET /internal/settlement/providers/stats?provider_id={id}
+func (h *Handlers) GetProviderStats(w http.ResponseWriter, r *http.Request) {
+	providerID := r.URL.Query().Get("provider_id")
+	if providerID == "" {
+		http.Error(w, "provider_id is required", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.svc.GetProviderStats(r.Context(), providerID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get provider stats failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
+
 // ProcessDeposit handles a deposit request
 // POST /v1/deposits
 func (h *Handlers) ProcessDeposit(w http.ResponseWriter, r *http.Request) {
@@ -138,6 +158,10 @@ func (h *Handlers) ProcessContractCompletion(w http.ResponseWriter, r *http.Requ
 			http.Error(w, "execution already recorded", http.StatusConflict)
 			return
 		}
+		if errors.Is(err, service.ErrInvalidPayoutSplit) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
@@ -4,9 +4,12 @@ import (
 	"net/http"
 
 	"github.com/parlakisik/agent-exchange/aex-settlement/internal/service"
+	"github.com/parlakisik/agent-exchange/internal/serviceauth"
 )
 
-func NewRouter(svc *service.Service) http.Handler {
+// NewRouter serves the settlement API. With serviceTokens, settling a
+// contract requires a service account token for aex-settlement.
+func NewRouter(svc *service.Service, serviceTokens *serviceauth.Verifier) http.Handler {
 	h := NewHandlers(svc)
 	mux := http.NewServeMux()
 
@@ -17,7 +20,12 @@ func NewRouter(svc *service.Service) http.Handler {
 	mux.HandleFunc("/v1/deposits", h.ProcessDeposit)
 
 	// Internal API
-	mux.HandleFunc("/internal/settlement/complete", h.ProcessContractCompletion)
+	var complete http.Handler = http.HandlerFunc(h.ProcessContractCompletion)
+	if serviceTokens != nil {
+		complete = serviceTokens.Middleware(complete)
+	}
+	mux.Handle("/internal/settlement/complete", complete)
+	mux.HandleFunc("/internal/settlement/providers/stats", h.GetProviderStats)
 
 	// Health
 	mux.HandleFunc("/health", h.Health)
@@ -24,6 +24,10 @@ type Execution struct {
 	Metadata       map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
 	CreatedAt      time.Time              `json:"created_at" bson:"created_at"`
 
+	// PayoutSplits divides ProviderPayout between the providers of a
+	// multi-party contract. Empty means ProviderID receives the full payout.
+	PayoutSplits []PayoutSplit `json:"payout_splits,omitempty" bson:"payout_splits,omitempty"`
+
 	// AP2 Payment fields
 	AP2Enabled           bool   `json:"ap2_enabled,omitempty" bson:"ap2_enabled,omitempty"`
 	PaymentMandateID     string `json:"payment_mandate_id,omitempty" bson:"payment_mandate_id,omitempty"`
@@ -83,6 +87,18 @@ type UsageResponse struct {
 	Count      int         `json:"count"`
 }
 
+// ProviderStatsResponse summarizes the settled executions of a provider
+type ProviderStatsResponse struct {
+	ProviderID          string  `json:"provider_id"`
+	TotalContracts      int     `json:"total_contracts"`
+	CompletedContracts  int     `json:"completed_contracts"`
+	FailedContracts     int     `json:"failed_contracts"`
+	SuccessRate         float64 `json:"success_rate"`
+	TotalAwarded        string  `json:"total_awarded"`
+	AverageAwardedPrice string  `json:"average_awarded_price"`
+	Currency            string  `json:"currency"`
+}
+
 // BalanceResponse represents balance information
 type BalanceResponse struct {
 	TenantID string `json:"tenant_id"`
@@ -103,6 +119,13 @@ type CostBreakdown struct {
 	ProviderPayout string `json:"provider_payout"`
 }
 
+// PayoutSplit is one provider's share of a multi-party contract payout
+type PayoutSplit struct {
+	ProviderID   string  `json:"provider_id" bson:"provider_id"`
+	SharePercent float64 `json:"share_percent" bson:"share_percent"`
+	Amount       string  `json:"amount,omitempty" bson:"amount,omitempty"` // Decimal as string; computed by settlement
+}
+
 // ContractCompletedEvent represents the event received when a contract is completed
 type ContractCompletedEvent struct {
 	ContractID  string                 `json:"contract_id"`
@@ -125,6 +148,9 @@ type ContractCompletedEvent struct {
 
 	// Work category for payment provider selection
 	WorkCategory string `json:"work_category,omitempty"` // "contracts", "compliance", "general"
+
+	// Payout split for multi-party contracts; shares must add up to 100
+	PayoutSplits []PayoutSplit `json:"payout_splits,omitempty"`
 }
 
 // AP2PaymentResult contains the result of AP2 payment processing
@@ -20,11 +20,12 @@ import (
 )
 
 var (
-	ErrExecutionExists   = errors.New("execution already recorded")
-	ErrInsufficientFunds = errors.New("insufficient funds")
-	ErrInvalidAmount     = errors.New("invalid amount")
-	ErrAP2PaymentFailed  = errors.New("AP2 payment failed")
-	PlatformFeeRate      = decimal.RequireFromString("0.15") // 15% platform fee
+	ErrExecutionExists    = errors.New("execution already recorded")
+	ErrInsufficientFunds  = errors.New("insufficient funds")
+	ErrInvalidAmount      = errors.New("invalid amount")
+	ErrAP2PaymentFailed   = errors.New("AP2 payment failed")
+	ErrInvalidPayoutSplit = errors.New("invalid payout split")
+	PlatformFeeRate       = decimal.RequireFromString("0.15") // 15% platform fee
 )
 
 type Service struct {
@@ -77,6 +78,12 @@ func (s *Service) ProcessContractCompletion(ctx context.Context, event model.Con
 
 	breakdown := s.calculateCost(agreedPrice)
 
+	providerPayout, _ := decimal.NewFromString(breakdown.ProviderPayout)
+	splits, err := splitPayout(providerPayout, event.PayoutSplits)
+	if err != nil {
+		return err
+	}
+
 	// Calculate duration
 	durationMs := event.CompletedAt.Sub(event.StartedAt).Milliseconds()
 
@@ -112,6 +119,7 @@ func (s *Service) ProcessContractCompletion(ctx context.Context, event model.Con
 		Metadata:       event.Metadata,
 		CreatedAt:      time.Now().UTC(),
 		WorkCategory:   workCategory,
+		PayoutSplits:   splits,
 	}
 
 	// Get bids from payment providers and select best one
@@ -325,14 +333,28 @@ func (s *Service) settleExecution(ctx context.Context, execution model.Execution
 		return fmt.Errorf("append consumer ledger entry: %w", err)
 	}
 
-	// Credit provider
-	providerBalance, err := s.store.GetBalance(ctx, execution.ProviderID)
+	// Credit provider(s)
+	if len(execution.PayoutSplits) == 0 {
+		return s.creditProvider(ctx, execution, execution.ProviderID, providerPayout, now)
+	}
+	for _, split := range execution.PayoutSplits {
+		amount, _ := decimal.NewFromString(split.Amount)
+		if err := s.creditProvider(ctx, execution, split.ProviderID, amount, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// creditProvider adds a payout to a provider's balance and ledger
+func (s *Service) creditProvider(ctx context.Context, execution model.Execution, providerID string, amount decimal.Decimal, now time.Time) error {
+	providerBalance, err := s.store.GetBalance(ctx, providerID)
 	if err != nil {
 		return fmt.Errorf("get provider balance: %w", err)
 	}
 
-	currentBalance, _ = decimal.NewFromString(providerBalance.Balance)
-	newProviderBalance := currentBalance.Add(providerPayout)
+	currentBalance, _ := decimal.NewFromString(providerBalance.Balance)
+	newProviderBalance := currentBalance.Add(amount)
 
 	providerBalance.Balance = newProviderBalance.String()
 	providerBalance.LastUpdated = now
@@ -343,9 +365,9 @@ func (s *Service) settleExecution(ctx context.Context, execution model.Execution
 	// Create provider ledger entry (CREDIT)
 	providerEntry := model.LedgerEntry{
 		ID:            generateID("ledger"),
-		TenantID:      execution.ProviderID,
+		TenantID:      providerID,
 		EntryType:     "CREDIT",
-		Amount:        providerPayout.String(),
+		Amount:        amount.String(),
 		BalanceAfter:  newProviderBalance.String(),
 		ReferenceType: "execution",
 		ReferenceID:   execution.ID,
@@ -359,6 +381,36 @@ func (s *Service) settleExecution(ctx context.Context, execution model.Execution
 	return nil
 }
 
+// splitPayout divides the provider payout according to the requested shares.
+// The last split absorbs rounding so the amounts always add up to payout.
+func splitPayout(payout decimal.Decimal, requested []model.PayoutSplit) ([]model.PayoutSplit, error) {
+	if len(requested) == 0 {
+		return nil, nil
+	}
+	total := decimal.Zero
+	for _, sp := range requested {
+		if sp.ProviderID == "" || sp.SharePercent <= 0 {
+			return nil, fmt.Errorf("%w: payout split needs provider_id and a positive share", ErrInvalidPayoutSplit)
+		}
+		total = total.Add(decimal.NewFromFloat(sp.SharePercent))
+	}
+	if !total.Round(4).Equal(decimal.NewFromInt(100)) {
+		return nil, fmt.Errorf("%w: shares add up to %s, not 100", ErrInvalidPayoutSplit, total.String())
+	}
+
+	out := make([]model.PayoutSplit, len(requested))
+	remaining := payout
+	for i, sp := range requested {
+		amount := remaining
+		if i < len(requested)-1 {
+			amount = payout.Mul(decimal.NewFromFloat(sp.SharePercent)).Div(decimal.NewFromInt(100)).Round(6)
+			remaining = remaining.Sub(amount)
+		}
+		out[i] = model.PayoutSplit{ProviderID: sp.ProviderID, SharePercent: sp.SharePercent, Amount: amount.String()}
+	}
+	return out, nil
+}
+
 // calculateCost calculates platform fee and provider payout
 func (s *Service) calculateCost(agreedPrice decimal.Decimal) model.CostBreakdown {
 	platformFee := agreedPrice.Mul(PlatformFeeRate).Round(6)
@@ -394,6 +446,48 @@ func (s *Service) GetUsage(ctx context.Context, tenantID string, limit int) (mod
 	}, nil
 }
 
+// GetProviderStats aggregates the executions settled for a provider.
+// Cancellation fees are not contract outcomes and are left out.
+func (s *Service) GetProviderStats(ctx context.Context, providerID string) (model.ProviderStatsResponse, error) {
+	all, err := s.store.ListExecutionsByProvider(ctx, providerID, 0)
+	if err != nil {
+		return model.ProviderStatsResponse{}, err
+	}
+	var executions []model.Execution
+	for _, exec := range all {
+		if !isCancellationFee(exec) {
+			executions = append(executions, exec)
+		}
+	}
+
+	stats := model.ProviderStatsResponse{ProviderID: providerID, Currency: "USD"}
+	total := decimal.Zero
+	for _, exec := range executions {
+		price, _ := decimal.NewFromString(exec.AgreedPrice)
+		total = total.Add(price)
+		if exec.Success {
+			stats.CompletedContracts++
+		} else {
+			stats.FailedContracts++
+		}
+	}
+	stats.TotalContracts = len(executions)
+	stats.TotalAwarded = total.StringFixed(2)
+	stats.AverageAwardedPrice = "0.00"
+	if n := len(executions); n > 0 {
+		stats.SuccessRate = float64(stats.CompletedContracts) / float64(n)
+		stats.AverageAwardedPrice = total.Div(decimal.NewFromInt(int64(n))).StringFixed(2)
+	}
+	return stats, nil
+}
+
+// isCancellationFee reports whether exec settled the fee of a cancelled
+// contract rather than the contract itself.
+func isCancellationFee(exec model.Execution) bool {
+	cancelled, _ := exec.Metadata["cancellation"].(bool)
+	return cancelled
+}
+
 // GetBalance retrieves balance for a tenant
 func (s *Service) GetBalance(ctx context.Context, tenantID string) (model.BalanceResponse, error) {
 	balance, err := s.store.GetBalance(ctx, tenantID)
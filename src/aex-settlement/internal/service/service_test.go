@@ -1,8 +1,11 @@
 package service
 
 import (
+	"context"
 	"testing"
 
+	"github.com/parlakisik/agent-exchange/aex-settlement/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-settlement/internal/store"
 	"github.com/shopspring/decimal"
 )
 
@@ -126,3 +129,72 @@ func TestCostBreakdownConsistency(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitPayout(t *testing.T) {
+	payout := decimal.RequireFromString("10")
+
+	splits, err := splitPayout(payout, []model.PayoutSplit{
+		{ProviderID: "prov_a", SharePercent: 33.33},
+		{ProviderID: "prov_b", SharePercent: 33.33},
+		{ProviderID: "prov_c", SharePercent: 33.34},
+	})
+	if err != nil {
+		t.Fatalf("splitPayout() error = %v", err)
+	}
+	sum := decimal.Zero
+	for _, sp := range splits {
+		amount, _ := decimal.NewFromString(sp.Amount)
+		sum = sum.Add(amount)
+	}
+	if !sum.Equal(payout) {
+		t.Errorf("split amounts sum to %s, want %s", sum, payout)
+	}
+	if splits[0].Amount != "3.333" {
+		t.Errorf("first split amount = %s, want 3.333", splits[0].Amount)
+	}
+
+	if _, err := splitPayout(payout, []model.PayoutSplit{{ProviderID: "prov_a", SharePercent: 60}}); err == nil {
+		t.Error("splitPayout() should reject shares that do not add up to 100")
+	}
+
+	if splits, err := splitPayout(payout, nil); err != nil || splits != nil {
+		t.Errorf("splitPayout(nil) = %v, %v; want nil, nil", splits, err)
+	}
+}
+
+func TestGetProviderStats(t *testing.T) {
+	st := store.NewMemoryStore()
+	svc := New(st)
+	ctx := context.Background()
+	for _, exec := range []model.Execution{
+		{ID: "exec_1", ContractID: "c1", ProviderID: "prov_1", Success: true, AgreedPrice: "10.00"},
+		{ID: "exec_2", ContractID: "c2", ProviderID: "prov_1", Success: true, AgreedPrice: "20.00"},
+		{ID: "exec_3", ContractID: "c3", ProviderID: "prov_1", Success: false, AgreedPrice: "6.50"},
+		{ID: "exec_4", ContractID: "c4", ProviderID: "prov_2", Success: true, AgreedPrice: "99.00"},
+		// A cancellation fee is paid for c5 but it is not a contract outcome.
+		{ID: "exec_5", ContractID: "c5", ProviderID: "prov_1", Success: false, AgreedPrice: "2.00", Metadata: map[string]interface{}{"cancellation": true}},
+	} {
+		if err := st.SaveExecution(ctx, exec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := svc.GetProviderStats(ctx, "prov_1")
+	if err != nil {
+		t.Fatalf("GetProviderStats() error = %v", err)
+	}
+	if stats.TotalContracts != 3 || stats.CompletedContracts != 2 || stats.FailedContracts != 1 {
+		t.Errorf("counts = %d/%d/%d, want 3/2/1", stats.TotalContracts, stats.CompletedContracts, stats.FailedContracts)
+	}
+	if stats.TotalAwarded != "36.50" || stats.AverageAwardedPrice != "12.17" {
+		t.Errorf("total/average = %s/%s, want 36.50/12.17", stats.TotalAwarded, stats.AverageAwardedPrice)
+	}
+
+	empty, err := svc.GetProviderStats(ctx, "prov_none")
+	if err != nil {
+		t.Fatalf("GetProviderStats() error = %v", err)
+	}
+	if empty.TotalContracts != 0 || empty.SuccessRate != 0 || empty.AverageAwardedPrice != "0.00" {
+		t.Errorf("empty stats = %+v", empty)
+	}
+}
@@ -70,6 +70,21 @@ func (s *MemoryStore) ListExecutionsByContract(ctx context.Context, contractID s
 	return model.Execution{}, fmt.Errorf("execution not found for contract: %s", contractID)
 }
 
+func (s *MemoryStore) ListExecutionsByProvider(ctx context.Context, providerID string, limit int) ([]model.Execution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []model.Execution
+	for _, exec := range s.executions {
+		if exec.ProviderID == providerID {
+			result = append(result, exec)
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
 func (s *MemoryStore) AppendLedgerEntry(ctx context.Context, entry model.LedgerEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
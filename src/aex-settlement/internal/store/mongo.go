@@ -126,6 +126,29 @@ func (s *MongoSettlementStore) ListExecutionsByContract(ctx context.Context, con
 	return exec, nil
 }
 
+func (s *MongoSettlementStore) ListExecutionsByProvider(ctx context.Context, providerID string, limit int) ([]model.Execution, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cur, err := s.executions.Find(ctx, bson.M{"provider_id": providerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+
+	var executions []model.Execution
+	if err := cur.All(ctx, &executions); err != nil {
+		return nil, err
+	}
+
+	return executions, nil
+}
+
 // Ledger
 
 func (s *MongoSettlementStore) AppendLedgerEntry(ctx context.Context, entry model.LedgerEntry) error {
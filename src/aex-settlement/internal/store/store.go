@@ -13,6 +13,7 @@ type SettlementStore interface {
 	GetExecution(ctx context.Context, executionID string) (model.Execution, error)
 	ListExecutionsByTenant(ctx context.Context, tenantID string, limit int) ([]model.Execution, error)
 	ListExecutionsByContract(ctx context.Context, contractID string) (model.Execution, error)
+	ListExecutionsByProvider(ctx context.Context, providerID string, limit int) ([]model.Execution, error)
 
 	// Ledger
 	AppendLedgerEntry(ctx context.Context, entry model.LedgerEntry) error
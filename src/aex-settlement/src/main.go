@@ -13,6 +13,7 @@ import (
 	"github.com/parlakisik/agent-exchange/aex-settlement/internal/httpapi"
 	"github.com/parlakisik/agent-exchange/aex-settlement/internal/service"
 	"github.com/parlakisik/agent-exchange/aex-settlement/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/serviceauth"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -87,8 +88,16 @@ func main() {
 	// Initialize service
 	svc := service.New(settlementStore)
 
+	// Internal settlement requires service account tokens once identity
+	// is configured
+	var serviceTokens *serviceauth.Verifier
+	if cfg.IdentityURL != "" {
+		serviceTokens = serviceauth.NewVerifier(cfg.IdentityURL, "aex-settlement")
+		slog.Info("service account tokens required", "identity_url", cfg.IdentityURL)
+	}
+
 	// Setup HTTP router
-	router := httpapi.NewRouter(svc)
+	router := httpapi.NewRouter(svc, serviceTokens)
 
 	// Create HTTP server
 	srv := &http.Server{
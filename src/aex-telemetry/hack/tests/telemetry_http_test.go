@@ -1,17 +1,35 @@
 package tests
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/archive"
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/httpapi"
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/service"
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/events"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 func setupTestServer() *httptest.Server {
@@ -286,3 +304,1648 @@ func TestGetStats(t *testing.T) {
 		t.Fatalf("expected log_count=1, got %v", stats["log_count"])
 	}
 }
+
+func TestRetentionDeletesExpiredSignals(t *testing.T) {
+	st := store.NewMemoryStore(1000, 1000)
+	svc := service.New(st)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, age := range []time.Duration{48 * time.Hour, time.Hour} {
+		if err := st.AddLog(ctx, model.LogEntry{Timestamp: now.Add(-age), Level: "info", Message: "m"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.AddMetric(ctx, model.MetricEntry{Timestamp: now.Add(-age), Name: "requests", Value: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.AddSpan(ctx, model.TraceSpan{TraceID: "t1", SpanID: age.String(), StartTime: now.Add(-age)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A cancelled context runs a single retention pass.
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	svc.RunRetention(done, map[model.Signal]time.Duration{
+		model.SignalLogs:  24 * time.Hour,
+		model.SignalSpans: 24 * time.Hour,
+	}, time.Minute)
+
+	stats, err := st.GetStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["log_count"] != 1 || stats["span_count"] != 1 {
+		t.Fatalf("expected expired logs and spans to be deleted, got %v", stats)
+	}
+	if stats["metric_count"] != 2 {
+		t.Fatalf("expected metrics without retention to be kept, got %v", stats)
+	}
+	logs, _ := st.QueryLogs(ctx, model.LogQuery{})
+	if len(logs) != 1 || now.Sub(logs[0].Timestamp) > 2*time.Hour {
+		t.Fatalf("expected the recent log to remain, got %+v", logs)
+	}
+}
+
+func TestOTLPTracesJSON(t *testing.T) {
+	ts := setupTestServer()
+	defer ts.Close()
+
+	body := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"aex-gateway"}}]},
+		"scopeSpans":[{"spans":[{"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174",
+		"name":"POST /v1/work","kind":2,"startTimeUnixNano":"1700000000000000000","endTimeUnixNano":"1700000000250000000",
+		"attributes":[{"key":"http.status_code","value":{"intValue":"201"}}],"status":{"code":1}}]}]}]}`
+	resp, err := http.Post(ts.URL+"/v1/otlp/traces", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected 200 JSON, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/traces/5b8efff798038103d269b633813fc60c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var result struct {
+		Spans []model.TraceSpan `json:"spans"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	if len(result.Spans) != 1 {
+		t.Fatalf("expected 1 span, got %+v", result.Spans)
+	}
+	s := result.Spans[0]
+	if s.SpanID != "eee19b7ec3c1b174" || s.Service != "aex-gateway" || s.Operation != "POST /v1/work" ||
+		s.DurationMs != 250 || s.Status != "OK" || s.Attributes["http.status_code"] != "201" || s.Attributes["span.kind"] != "server" {
+		t.Fatalf("unexpected span translation: %+v", s)
+	}
+}
+
+func TestOTLPMetricsProtobufGzip(t *testing.T) {
+	ts := setupTestServer()
+	defer ts.Close()
+
+	now := uint64(time.Now().UnixNano())
+	attrs := []*commonpb.KeyValue{{Key: "route", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "/v1/work"}}}}
+	req := &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			{Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "aex-gateway"}}},
+		}},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{
+			{Name: "http.requests", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				IsMonotonic:            true,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricspb.NumberDataPoint{{
+					TimeUnixNano: now, Attributes: attrs, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 42},
+				}},
+			}}},
+			{Name: "http.duration", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricspb.HistogramDataPoint{{
+					TimeUnixNano: now, Count: 3, Sum: proto.Float64(0.9), ExplicitBounds: []float64{0.1, 0.5}, BucketCounts: []uint64{1, 1, 1},
+				}},
+			}}},
+			{Name: "queue.delta", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				IsMonotonic:            true,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				DataPoints:             []*metricspb.NumberDataPoint{{TimeUnixNano: now}},
+			}}},
+		}}},
+	}}}
+	raw, _ := proto.Marshal(req)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(raw)
+	_ = zw.Close()
+
+	httpReq, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/otlp/metrics", &gz)
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, respBody)
+	}
+	var out colmetricspb.ExportMetricsServiceResponse
+	if err := proto.Unmarshal(respBody, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.GetPartialSuccess().GetRejectedDataPoints() != 1 {
+		t.Fatalf("expected the delta sum to be rejected, got %+v", out.GetPartialSuccess())
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/metrics?service=aex-gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var result struct {
+		Metrics []model.MetricEntry `json:"metrics"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	byName := map[string]model.MetricEntry{}
+	for _, m := range result.Metrics {
+		byName[m.Name] = m
+	}
+	if c := byName["http.requests"]; c.Type != model.MetricTypeCounter || c.Value != 42 || c.Labels["route"] != "/v1/work" {
+		t.Fatalf("unexpected counter translation: %+v", c)
+	}
+	if h := byName["http.duration"]; h.Type != model.MetricTypeHistogram || h.Histogram == nil || h.Histogram.Count != 3 || len(h.Histogram.BucketCounts) != 3 {
+		t.Fatalf("unexpected histogram translation: %+v", h)
+	}
+}
+
+func TestOTLPLogsJSON(t *testing.T) {
+	ts := setupTestServer()
+	defer ts.Close()
+
+	body := `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"aex-settlement"}}]},
+		"scopeLogs":[{"logRecords":[{"timeUnixNano":"1700000000000000000","severityNumber":17,
+		"body":{"stringValue":"payment failed"},"traceId":"5b8efff798038103d269b633813fc60c",
+		"attributes":[{"key":"contract_id","value":{"stringValue":"c_1"}}]}]}]}]}`
+	resp, err := http.Post(ts.URL+"/v1/otlp/logs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/logs?service=aex-settlement&level=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var result struct {
+		Logs []model.LogEntry `json:"logs"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	if len(result.Logs) != 1 || result.Logs[0].Message != "payment failed" ||
+		result.Logs[0].TraceID != "5b8efff798038103d269b633813fc60c" || result.Logs[0].Fields["contract_id"] != "c_1" {
+		t.Fatalf("unexpected log translation: %+v", result.Logs)
+	}
+
+	resp, err = http.Post(ts.URL+"/v1/otlp/logs", "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for unsupported content type, got %d", resp.StatusCode)
+	}
+}
+
+func TestPrometheusExposition(t *testing.T) {
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	metrics := []model.MetricEntry{
+		{Name: "http.requests", Type: model.MetricTypeCounter, Value: 10, Service: "aex-gateway", Labels: map[string]string{"status-code": "200"}},
+		{Name: "http.requests", Type: model.MetricTypeCounter, Value: 12, Service: "aex-gateway", Labels: map[string]string{"status-code": "200"}},
+		{Name: "queue_depth", Type: model.MetricTypeGauge, Value: 3, Service: "aex-settlement", Labels: map[string]string{"service": "ledger"}},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.03, Service: "aex-gateway"},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.2, Service: "aex-gateway"},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 20, Service: "aex-gateway"},
+		{Name: "queue_depth", Type: model.MetricTypeCounter, Value: 99, Service: "aex-settlement"},
+	}
+	body, _ := json.Marshal(metrics)
+	resp, err := http.Post(ts.URL+"/v1/metrics", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	scrape := func() string {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+			t.Fatalf("expected text exposition, got %s", resp.Header.Get("Content-Type"))
+		}
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+	out := scrape()
+	for _, want := range []string{
+		"# TYPE http_requests counter",
+		`http_requests{service="aex-gateway",status_code="200"} 12`,
+		"# TYPE queue_depth gauge",
+		`queue_depth{exported_service="ledger",service="aex-settlement"} 3`,
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{service="aex-gateway",le="0.05"} 1`,
+		`latency_seconds_bucket{service="aex-gateway",le="0.25"} 2`,
+		`latency_seconds_bucket{service="aex-gateway",le="10"} 2`,
+		`latency_seconds_bucket{service="aex-gateway",le="+Inf"} 3`,
+		`latency_seconds_count{service="aex-gateway"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("exposition missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, " 99") {
+		t.Fatalf("expected a point with a conflicting type to be dropped:\n%s", out)
+	}
+
+	svc.SetMetricsStaleness(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if out := scrape(); strings.Contains(out, "http_requests") {
+		t.Fatalf("expected stale series to be dropped:\n%s", out)
+	}
+}
+
+func TestAggregateMetrics(t *testing.T) {
+	ts := setupTestServer()
+	defer ts.Close()
+
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(secs int) time.Time { return t0.Add(time.Duration(secs) * time.Second) }
+	metrics := []model.MetricEntry{
+		{Name: "jobs_total", Type: model.MetricTypeCounter, Value: 0, Service: "worker", Timestamp: at(0)},
+		{Name: "jobs_total", Type: model.MetricTypeCounter, Value: 30, Service: "worker", Timestamp: at(30)},
+		{Name: "jobs_total", Type: model.MetricTypeCounter, Value: 60, Service: "worker", Timestamp: at(60)},
+		// The counter restarted from zero.
+		{Name: "jobs_total", Type: model.MetricTypeCounter, Value: 6, Service: "worker", Timestamp: at(90)},
+		{Name: "queue_depth", Type: model.MetricTypeGauge, Value: 2, Service: "a", Timestamp: at(10)},
+		{Name: "queue_depth", Type: model.MetricTypeGauge, Value: 4, Service: "a", Timestamp: at(20)},
+		{Name: "queue_depth", Type: model.MetricTypeGauge, Value: 10, Service: "b", Timestamp: at(20)},
+		{Name: "queue_depth", Type: model.MetricTypeGauge, Value: 99, Service: "a", Timestamp: at(500)},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.03, Service: "api", Timestamp: at(1)},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.04, Service: "api", Timestamp: at(2)},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.2, Service: "api", Timestamp: at(3)},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.2, Service: "api", Timestamp: at(4)},
+	}
+	body, _ := json.Marshal(metrics)
+	resp, err := http.Post(ts.URL+"/v1/metrics", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	type result struct {
+		Series []struct {
+			Labels map[string]string `json:"labels"`
+			Points []struct {
+				Timestamp time.Time `json:"timestamp"`
+				Value     float64   `json:"value"`
+			} `json:"points"`
+		} `json:"series"`
+	}
+	aggregate := func(query string) result {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/metrics/aggregate?from=" + t0.Format(time.RFC3339) +
+			"&to=" + at(120).Format(time.RFC3339) + "&" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", query, resp.StatusCode)
+		}
+		var res result
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	near := func(a, b float64) bool { return a-b < 1e-9 && b-a < 1e-9 }
+
+	rate := aggregate("name=jobs_total&agg=rate&step=1m")
+	if len(rate.Series) != 1 || len(rate.Series[0].Points) != 2 {
+		t.Fatalf("expected one series with two steps, got %+v", rate.Series)
+	}
+	if p := rate.Series[0].Points; !near(p[0].Value, 0.5) || !near(p[1].Value, 36.0/60) || !p[1].Timestamp.Equal(at(60)) {
+		t.Fatalf("unexpected rate points %+v", p)
+	}
+
+	avg := aggregate("name=queue_depth&agg=avg&by=service")
+	if len(avg.Series) != 2 || avg.Series[0].Labels["service"] != "a" || !near(avg.Series[0].Points[0].Value, 3) ||
+		!near(avg.Series[1].Points[0].Value, 10) {
+		t.Fatalf("expected per-service averages within the range, got %+v", avg.Series)
+	}
+
+	p50 := aggregate("name=latency_seconds&agg=p50")
+	p99 := aggregate("name=latency_seconds&agg=p99")
+	if v := p50.Series[0].Points[0].Value; !near(v, 0.05) {
+		t.Fatalf("expected p50 of 0.05, got %v", v)
+	}
+	if v := p99.Series[0].Points[0].Value; !near(v, 0.1+0.15*(3.96-2)/2) {
+		t.Fatalf("unexpected p99 %v", v)
+	}
+
+	for _, query := range []string{
+		"/v1/metrics/aggregate?name=jobs_total&agg=median",
+		"/v1/metrics/aggregate?agg=sum",
+		"/v1/metrics/aggregate?name=jobs_total&agg=sum&step=1ns",
+		"/v1/metrics?from=yesterday",
+		"/v1/logs?from=" + at(60).Format(time.RFC3339) + "&to=" + t0.Format(time.RFC3339),
+	} {
+		resp, err := http.Get(ts.URL + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, resp.StatusCode)
+		}
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/metrics?name=queue_depth&from=" + strconv.FormatInt(at(15).Unix(), 10) +
+		"&to=" + at(120).Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var raw struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.Count != 2 {
+		t.Fatalf("expected 2 points between from and to, got %d", raw.Count)
+	}
+}
+
+func TestSearchLogs(t *testing.T) {
+	ts := httptest.NewServer(httpapi.NewRouter(service.New(store.NewMemoryStore(4, 1000))))
+	defer ts.Close()
+
+	logs := []model.LogEntry{
+		{Level: "info", Service: "aex-gateway", Message: "Evicted request log"},
+		{Level: "error", Service: "aex-gateway", Message: "Upstream request timed out", Fields: map[string]any{"status": 504, "route": "/v1/work"}},
+		{Level: "warn", Service: "aex-gateway", Message: "Slow upstream request", Fields: map[string]any{"status": 200, "route": "/v1/work"}},
+		{Level: "error", Service: "aex-settlement", Message: "Ledger request timed out", Fields: map[string]any{"status": 504}},
+		{Level: "info", Service: "aex-settlement", Message: "Ledger entry written"},
+	}
+	body, _ := json.Marshal(logs)
+	resp, err := http.Post(ts.URL+"/v1/logs", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	type result struct {
+		Logs   []model.LogEntry `json:"logs"`
+		Count  int              `json:"count"`
+		Facets model.LogFacets  `json:"facets"`
+	}
+	search := func(query string) result {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/logs?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var res result
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := search("q=Request+TIMED")
+	if res.Count != 2 || res.Logs[0].Service != "aex-settlement" {
+		t.Fatalf("expected both timeouts, newest first, got %+v", res.Logs)
+	}
+	if res.Facets.Level["error"] != 2 || res.Facets.Service["aex-gateway"] != 1 || res.Facets.Service["aex-settlement"] != 1 {
+		t.Fatalf("unexpected facets %+v", res.Facets)
+	}
+
+	if res := search("q=request&field.status=504&field.route=/v1/work"); res.Count != 1 || res.Logs[0].Message != "Upstream request timed out" {
+		t.Fatalf("expected the gateway timeout only, got %+v", res.Logs)
+	}
+
+	// The oldest log was evicted and must no longer be found through the index.
+	if res := search("q=evicted"); res.Count != 0 {
+		t.Fatalf("expected evicted log to be gone, got %+v", res.Logs)
+	}
+
+	res = search("limit=1&service=aex-gateway")
+	if res.Count != 1 || res.Facets.Service["aex-gateway"] != 2 || res.Facets.Level["warn"] != 1 {
+		t.Fatalf("expected facets to count past the limit, got %d logs and %+v", res.Count, res.Facets)
+	}
+}
+
+func TestServiceMap(t *testing.T) {
+	ts := setupTestServer()
+	defer ts.Close()
+
+	now := time.Now()
+	var spans []model.TraceSpan
+	for i := 1; i <= 20; i++ {
+		traceID := "trace-" + strconv.Itoa(i)
+		status := "OK"
+		if i <= 2 {
+			status = "ERROR"
+		}
+		spans = append(spans,
+			model.TraceSpan{TraceID: traceID, SpanID: "a", Service: "work-publisher", Operation: "PublishWork", StartTime: now.Add(-time.Minute), DurationMs: 50, Status: "OK"},
+			model.TraceSpan{TraceID: traceID, SpanID: "b", ParentSpanID: "a", Service: "bid-gateway", Operation: "SubmitBid", StartTime: now.Add(-time.Minute), DurationMs: int64(i), Status: status},
+			model.TraceSpan{TraceID: traceID, SpanID: "c", ParentSpanID: "b", Service: "bid-gateway", Operation: "Validate", StartTime: now.Add(-time.Minute), DurationMs: 1, Status: "OK"},
+			model.TraceSpan{TraceID: traceID, SpanID: "d", ParentSpanID: "c", Service: "contract-engine", Operation: "Award", StartTime: now.Add(-time.Minute), DurationMs: 5, Status: "OK"},
+		)
+	}
+	// Outside the window.
+	spans = append(spans,
+		model.TraceSpan{TraceID: "old", SpanID: "a", Service: "work-publisher", StartTime: now.Add(-2 * time.Hour)},
+		model.TraceSpan{TraceID: "old", SpanID: "b", ParentSpanID: "a", Service: "settlement", StartTime: now.Add(-2 * time.Hour)},
+	)
+	body, _ := json.Marshal(spans)
+	resp, err := http.Post(ts.URL+"/v1/spans", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = http.Get(ts.URL + "/v1/service-map?window=1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var result struct {
+		Nodes []service.ServiceNode `json:"nodes"`
+		Edges []service.ServiceEdge `json:"edges"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Nodes) != 3 || result.Nodes[0].Service != "bid-gateway" || result.Nodes[0].SpanCount != 40 || result.Nodes[0].ErrorRate != 0.05 {
+		t.Fatalf("unexpected nodes %+v", result.Nodes)
+	}
+	want := []service.ServiceEdge{
+		{Source: "bid-gateway", Target: "contract-engine", CallCount: 20, P95LatencyMs: 5},
+		{Source: "work-publisher", Target: "bid-gateway", CallCount: 20, ErrorRate: 0.1, P95LatencyMs: 19},
+	}
+	if len(result.Edges) != len(want) {
+		t.Fatalf("unexpected edges %+v", result.Edges)
+	}
+	for i := range want {
+		if result.Edges[i] != want[i] {
+			t.Fatalf("edge %d: expected %+v, got %+v", i, want[i], result.Edges[i])
+		}
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/service-map?window=forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid window, got %d", resp.StatusCode)
+	}
+}
+
+func TestAlertRules(t *testing.T) {
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	svc.SetAlertWebhookSecret("s3cret")
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	hooks := make(chan model.AlertEvent, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get(service.AlertSignatureHeader), "sha256=") {
+			t.Errorf("expected a signed webhook")
+		}
+		var ev model.AlertEvent
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		hooks <- ev
+	}))
+	defer hook.Close()
+
+	create := func(rule model.AlertRule) (*http.Response, model.AlertRule) {
+		t.Helper()
+		body, _ := json.Marshal(rule)
+		resp, err := http.Post(ts.URL+"/v1/alerts/rules", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out model.AlertRule
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+	resp, errorRule := create(model.AlertRule{
+		Name: "settlement errors", Type: model.AlertRuleMetricThreshold,
+		Metric: "settlement_errors", Service: "aex-settlement", Aggregation: "sum",
+		Threshold: 5, Window: "5m", For: "1m", WebhookURL: hook.URL,
+	})
+	if resp.StatusCode != http.StatusCreated || errorRule.State != model.AlertInactive || errorRule.Comparator != "gt" {
+		t.Fatalf("expected created inactive rule, got %d %+v", resp.StatusCode, errorRule)
+	}
+	_, timeoutRule := create(model.AlertRule{Name: "timeouts", Type: model.AlertRuleLogPattern, Pattern: "timed? out", Window: "5m"})
+	if resp, _ := create(model.AlertRule{Name: "bad", Type: model.AlertRuleLogPattern, Pattern: "(", Window: "5m"}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid pattern, got %d", resp.StatusCode)
+	}
+
+	post := func(path string, v any) {
+		t.Helper()
+		body, _ := json.Marshal(v)
+		resp, err := http.Post(ts.URL+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	state := func(id string) model.AlertState {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/alerts/rules/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var rule model.AlertRule
+		_ = json.NewDecoder(resp.Body).Decode(&rule)
+		return rule.State
+	}
+	expectHook := func(want model.AlertState) {
+		t.Helper()
+		select {
+		case ev := <-hooks:
+			if ev.RuleID != errorRule.ID || ev.State != want {
+				t.Fatalf("expected %s webhook, got %+v", want, ev)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected %s webhook", want)
+		}
+	}
+
+	now := time.Now().UTC()
+	post("/v1/metrics", []model.MetricEntry{
+		{Name: "settlement_errors", Type: model.MetricTypeGauge, Value: 4, Service: "aex-settlement", Timestamp: now},
+		{Name: "settlement_errors", Type: model.MetricTypeGauge, Value: 3, Service: "aex-settlement", Timestamp: now},
+		{Name: "settlement_errors", Type: model.MetricTypeGauge, Value: 50, Service: "aex-gateway", Timestamp: now},
+	})
+	svc.EvaluateAlerts(context.Background(), now.Add(time.Second))
+	if s := state(errorRule.ID); s != model.AlertPending {
+		t.Fatalf("expected PENDING while the for duration runs, got %s", s)
+	}
+	expectHook(model.AlertPending)
+	if s := state(timeoutRule.ID); s != model.AlertInactive {
+		t.Fatalf("expected the log rule to stay INACTIVE, got %s", s)
+	}
+
+	post("/v1/logs", []model.LogEntry{{Level: "error", Service: "aex-settlement", Message: "ledger call timed out", Timestamp: now}})
+	svc.EvaluateAlerts(context.Background(), now.Add(2*time.Minute))
+	if s := state(errorRule.ID); s != model.AlertFiring {
+		t.Fatalf("expected FIRING after the for duration, got %s", s)
+	}
+	expectHook(model.AlertFiring)
+	if s := state(timeoutRule.ID); s != model.AlertFiring {
+		t.Fatalf("expected the log rule to fire at once, got %s", s)
+	}
+
+	svc.EvaluateAlerts(context.Background(), now.Add(10*time.Minute))
+	if s := state(errorRule.ID); s != model.AlertResolved {
+		t.Fatalf("expected RESOLVED once the window has no data, got %s", s)
+	}
+	expectHook(model.AlertResolved)
+
+	resp, err := http.Get(ts.URL + "/v1/alerts/events?rule_id=" + errorRule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events struct {
+		Events []model.AlertEvent `json:"events"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&events)
+	_ = resp.Body.Close()
+	if len(events.Events) != 3 || events.Events[0].State != model.AlertResolved || events.Events[2].From != model.AlertInactive {
+		t.Fatalf("unexpected events %+v", events.Events)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/alerts/rules/"+errorRule.ID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	resp, err = http.Get(ts.URL + "/v1/alerts/rules/" + errorRule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestStreamLogs(t *testing.T) {
+	ts := setupTestServer()
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/v1/logs/stream?service=aex-settlement&level=error", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s", ct)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() || lines.Text() != ": connected" {
+		t.Fatalf("expected connected comment, got %q", lines.Text())
+	}
+
+	logs := []model.LogEntry{
+		{Level: "error", Service: "aex-gateway", Message: "other service"},
+		{Level: "info", Service: "aex-settlement", Message: "other level"},
+		{Level: "error", Service: "aex-settlement", Message: "ledger write failed"},
+	}
+	body, _ := json.Marshal(logs)
+	post, err := http.Post(ts.URL+"/v1/logs", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = post.Body.Close()
+
+	var event, data string
+	for lines.Scan() {
+		line := lines.Text()
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			event = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+			break
+		}
+	}
+	if event != "log" {
+		t.Fatalf("expected a log event, got %q", event)
+	}
+	var entry model.LogEntry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "ledger write failed" || entry.ID == "" || entry.Timestamp.IsZero() {
+		t.Fatalf("expected only the matching entry, got %+v", entry)
+	}
+}
+
+func TestMetricRollups(t *testing.T) {
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	svc.EnableRollups(map[model.Resolution]time.Duration{
+		model.ResolutionRaw: 3 * time.Hour,
+		model.Resolution1m:  3 * time.Hour,
+	})
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	now := time.Now().UTC()
+	base := now.Truncate(time.Hour).Add(-2 * time.Hour)
+	at := func(secs int) time.Time { return base.Add(time.Duration(secs) * time.Second) }
+	body, _ := json.Marshal([]model.MetricEntry{
+		{Name: "cpu", Type: model.MetricTypeGauge, Value: 1, Service: "worker", Timestamp: at(0)},
+		{Name: "cpu", Type: model.MetricTypeGauge, Value: 3, Service: "worker", Timestamp: at(30)},
+		{Name: "cpu", Type: model.MetricTypeGauge, Value: 5, Service: "worker", Timestamp: at(90)},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.03, Service: "api", Timestamp: at(10)},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.04, Service: "api", Timestamp: at(70)},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.2, Service: "api", Timestamp: at(80)},
+		{Name: "latency_seconds", Type: model.MetricTypeHistogram, Value: 0.2, Service: "api", Timestamp: at(200)},
+	})
+	resp, err := http.Post(ts.URL+"/v1/metrics", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if err := svc.RollupMetrics(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+
+	type listing struct {
+		Metrics    []model.MetricEntry `json:"metrics"`
+		Resolution model.Resolution    `json:"resolution"`
+	}
+	get := func(path string, out any) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	from := url.QueryEscape(base.Format(time.RFC3339))
+
+	var minute listing
+	get("/v1/metrics?name=cpu&resolution=1m&from="+from, &minute)
+	if len(minute.Metrics) != 2 {
+		t.Fatalf("expected two 1m rollups, got %+v", minute.Metrics)
+	}
+	first := minute.Metrics[1]
+	if !first.Timestamp.Equal(base) || first.Value != 3 || first.Rollup.Count != 2 || first.Rollup.Sum != 4 ||
+		first.Rollup.Min != 1 || first.Rollup.Max != 3 {
+		t.Fatalf("unexpected first 1m rollup %+v %+v", first, first.Rollup)
+	}
+
+	var fiveMinutes listing
+	get("/v1/metrics?name=cpu&resolution=5m&from="+from, &fiveMinutes)
+	if len(fiveMinutes.Metrics) != 1 || fiveMinutes.Metrics[0].Rollup.Count != 3 || fiveMinutes.Metrics[0].Value != 5 {
+		t.Fatalf("expected one 5m rollup of all points, got %+v", fiveMinutes.Metrics)
+	}
+
+	type aggregate struct {
+		Resolution model.Resolution `json:"resolution"`
+		Series     []struct {
+			Points []struct {
+				Value float64 `json:"value"`
+			} `json:"points"`
+		} `json:"series"`
+	}
+	var avg aggregate
+	get("/v1/metrics/aggregate?name=cpu&agg=avg&step=5m&from="+from+"&to="+url.QueryEscape(at(300).Format(time.RFC3339)), &avg)
+	if avg.Resolution != model.Resolution5m || len(avg.Series) != 1 || avg.Series[0].Points[0].Value != 3 {
+		t.Fatalf("expected the 5m tier to be picked and averaged, got %+v", avg)
+	}
+	var p50 aggregate
+	get("/v1/metrics/aggregate?name=latency_seconds&agg=p50&resolution=1m&from="+from+"&to="+url.QueryEscape(at(300).Format(time.RFC3339)), &p50)
+	if len(p50.Series) != 1 || p50.Series[0].Points[0].Value != 0.05 {
+		t.Fatalf("expected p50 from the rolled up buckets, got %+v", p50)
+	}
+
+	// Hours later raw points and 1m rollups have expired, the 5m rollups
+	// they were summarized into remain.
+	if err := svc.RollupMetrics(context.Background(), now.Add(4*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var raw listing
+	get("/v1/metrics?name=cpu&from="+from, &raw)
+	get("/v1/metrics?name=cpu&resolution=1m&from="+from, &minute)
+	get("/v1/metrics?name=cpu&resolution=5m&from="+from, &fiveMinutes)
+	if len(raw.Metrics) != 0 || len(minute.Metrics) != 0 || len(fiveMinutes.Metrics) != 1 {
+		t.Fatalf("expected only the 5m rollup to remain, got raw=%d 1m=%d 5m=%d", len(raw.Metrics), len(minute.Metrics), len(fiveMinutes.Metrics))
+	}
+}
+
+func TestSearchTraces(t *testing.T) {
+	ts := setupTestServer()
+	defer ts.Close()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	span := func(traceID, spanID, parent, svc, op string, offsetMs, durationMs int64, status string) model.TraceSpan {
+		start := now.Add(time.Duration(offsetMs) * time.Millisecond)
+		return model.TraceSpan{
+			TraceID: traceID, SpanID: spanID, ParentSpanID: parent, Service: svc, Operation: op,
+			StartTime: start, EndTime: start.Add(time.Duration(durationMs) * time.Millisecond),
+			DurationMs: durationMs, Status: status,
+		}
+	}
+	spans := []model.TraceSpan{
+		span("slow", "a", "", "aex-gateway", "POST /v1/work", -5000, 900, "OK"),
+		span("slow", "b", "a", "aex-work-publisher", "PublishWork", -4990, 850, "ERROR"),
+		span("fast", "a", "", "aex-gateway", "POST /v1/work", -3000, 40, "OK"),
+		span("fast", "b", "a", "aex-work-publisher", "PublishWork", -2990, 20, "OK"),
+		span("other", "a", "", "aex-gateway", "GET /health", -1000, 1, "OK"),
+		span("old", "a", "", "aex-work-publisher", "PublishWork", -int64(2*time.Hour/time.Millisecond), 999, "OK"),
+	}
+	body, _ := json.Marshal(spans)
+	resp, err := http.Post(ts.URL+"/v1/spans", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	search := func(query string) []model.TraceSummary {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/traces?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", query, resp.StatusCode)
+		}
+		var result struct {
+			Traces []model.TraceSummary `json:"traces"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result.Traces
+	}
+	from := url.QueryEscape(now.Add(-time.Hour).Format(time.RFC3339))
+
+	traces := search("service=aex-work-publisher&operation=PublishWork&from=" + from)
+	if len(traces) != 2 || traces[0].TraceID != "fast" || traces[1].TraceID != "slow" {
+		t.Fatalf("expected both recent publish traces, latest first, got %+v", traces)
+	}
+	slow := traces[1]
+	if slow.RootService != "aex-gateway" || slow.RootOperation != "POST /v1/work" || slow.DurationMs != 900 ||
+		slow.SpanCount != 2 || slow.ErrorCount != 1 || len(slow.Services) != 2 {
+		t.Fatalf("unexpected summary %+v", slow)
+	}
+
+	if traces := search("service=aex-work-publisher&min_duration_ms=500&from=" + from); len(traces) != 1 || traces[0].TraceID != "slow" {
+		t.Fatalf("expected only the slow trace, got %+v", traces)
+	}
+	if traces := search("status=error"); len(traces) != 1 || traces[0].TraceID != "slow" {
+		t.Fatalf("expected only the failed trace, got %+v", traces)
+	}
+	if traces := search("service=aex-gateway&limit=1"); len(traces) != 1 || traces[0].TraceID != "other" {
+		t.Fatalf("expected the latest gateway trace, got %+v", traces)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/traces?min_duration_ms=slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid duration, got %d", resp.StatusCode)
+	}
+}
+
+func TestExportToObjectStore(t *testing.T) {
+	dir := t.TempDir()
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	from := time.Now().UTC().Add(-3 * time.Hour).Truncate(time.Hour)
+	post := func(path string, v any) *http.Response {
+		t.Helper()
+		body, _ := json.Marshal(v)
+		resp, err := http.Post(ts.URL+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	var logs []model.LogEntry
+	for i := 0; i < 5; i++ {
+		logs = append(logs, model.LogEntry{Level: "info", Service: "aex-gateway", Message: "entry " + strconv.Itoa(i), Timestamp: from.Add(time.Duration(i) * 40 * time.Minute)})
+	}
+	logs = append(logs, model.LogEntry{Level: "info", Service: "aex-gateway", Message: "after the range", Timestamp: from.Add(4 * time.Hour)})
+	_ = post("/v1/logs", logs).Body.Close()
+	_ = post("/v1/spans", []model.TraceSpan{{TraceID: "t1", SpanID: "s1", Service: "aex-gateway", StartTime: from.Add(time.Minute)}}).Body.Close()
+
+	req := model.ExportRequest{Signals: []model.Signal{model.SignalLogs, model.SignalSpans}, From: from, To: from.Add(3 * time.Hour)}
+	resp := post("/v1/export", req)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without export storage, got %d", resp.StatusCode)
+	}
+
+	svc.SetExportStore(archive.NewFileStore(dir), "archive")
+	resp = post("/v1/export", model.ExportRequest{From: from, To: from.Add(time.Hour), Format: "parquet"})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for parquet, got %d", resp.StatusCode)
+	}
+
+	resp = post("/v1/export", req)
+	var job model.ExportJob
+	_ = json.NewDecoder(resp.Body).Decode(&job)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || job.ID == "" {
+		t.Fatalf("expected an accepted job, got %d %+v", resp.StatusCode, job)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != model.ExportCompleted {
+		if job.Status == model.ExportFailed || time.Now().After(deadline) {
+			t.Fatalf("export did not complete: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		resp, err := http.Get(ts.URL + "/v1/export/" + job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&job)
+		_ = resp.Body.Close()
+	}
+
+	if len(job.Objects) != 2 || job.Objects[0].Records != 5 || job.Objects[1].Records != 1 {
+		t.Fatalf("unexpected objects %+v", job.Objects)
+	}
+	f, err := os.Open(strings.TrimPrefix(job.Objects[0].Location, "file://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bufio.NewScanner(zr)
+	var messages []string
+	for lines.Scan() {
+		var entry model.LogEntry
+		if err := json.Unmarshal(lines.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, entry.Message)
+	}
+	if strings.Join(messages, ",") != "entry 0,entry 1,entry 2,entry 3,entry 4" {
+		t.Fatalf("expected the logs in the range oldest first, got %v", messages)
+	}
+}
+
+func TestS3StoreSignsUploads(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s3 := archive.NewS3Store(srv.URL, "eu-west-1", "telemetry", "AKIDEXAMPLE", "secret", "")
+	payload := []byte("{\"a\":1}\n")
+	if err := s3.Put(context.Background(), "logs/a b.ndjson", bytes.NewReader(payload), int64(len(payload)), "application/x-ndjson"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != http.MethodPut || got.URL.EscapedPath() != "/telemetry/logs/a%20b.ndjson" || string(body) != string(payload) {
+		t.Fatalf("unexpected upload %s %s %q", got.Method, got.URL.EscapedPath(), body)
+	}
+	sum := sha256.Sum256(payload)
+	if got.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected payload hash %s", got.Header.Get("X-Amz-Content-Sha256"))
+	}
+	auth := got.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Fatalf("unexpected authorization %q", auth)
+	}
+	if s3.Location("logs/x") != "s3://telemetry/logs/x" {
+		t.Fatalf("unexpected location %s", s3.Location("logs/x"))
+	}
+}
+
+func TestIngestDeadLetters(t *testing.T) {
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	post := func(path, body string) (int, model.IngestResult) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var result model.IngestResult
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, result := post("/v1/logs", `[
+		{"level": "info", "service": "aex-gateway", "message": "ok"},
+		{"level": "info", "service": "aex-gateway", "timestamp": "yesterday", "message": "bad time"},
+		{"level": "info", "service": "aex-gateway"},
+		{"level": "warn", "service": "aex-gateway", "message": "also ok"}
+	]`)
+	if status != http.StatusAccepted || result.Accepted != 2 || result.Rejected != 2 || result.BatchID == "" {
+		t.Fatalf("unexpected log batch result %d %+v", status, result)
+	}
+	if result.Rejections[0].Index != 1 || result.Rejections[1].Index != 2 || result.Rejections[1].Reason != "message is required" {
+		t.Fatalf("unexpected rejections %+v", result.Rejections)
+	}
+	logBatch := result.BatchID
+
+	status, result = post("/v1/logs", `{"level": "info", "message": "single entry"}`)
+	if status != http.StatusAccepted || result.Accepted != 1 || result.Rejected != 0 {
+		t.Fatalf("unexpected single entry result %d %+v", status, result)
+	}
+
+	status, result = post("/v1/metrics", `[
+		{"name": "cpu", "type": "gauge", "value": 1, "service": "worker"},
+		{"name": "cpu", "type": "summary", "value": 1, "service": "worker"},
+		{"name": "latency", "type": "histogram", "service": "worker", "histogram": {"count": 1, "sum": 2, "explicit_bounds": [1, 5], "bucket_counts": [0, 1]}},
+		{"name": "cpu", "value": "high"}
+	]`)
+	if status != http.StatusAccepted || result.Accepted != 1 || result.Rejected != 3 {
+		t.Fatalf("unexpected metric batch result %d %+v", status, result)
+	}
+
+	status, result = post("/v1/spans", `[{"trace_id": "t1", "span_id": "a", "service": "aex-gateway"}, {"trace_id": "t1", "service": "aex-gateway"}]`)
+	if status != http.StatusAccepted || result.Accepted != 1 || result.Rejected != 1 {
+		t.Fatalf("unexpected span batch result %d %+v", status, result)
+	}
+
+	if status, _ := post("/v1/spans", `[{"trace_id": "t1"`); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a truncated body, got %d", status)
+	}
+
+	list := func(query string) []model.DeadLetter {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/dead-letters" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			DeadLetters []model.DeadLetter `json:"dead_letters"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.DeadLetters
+	}
+	if all := list(""); len(all) != 7 || all[0].Index != -1 || all[0].Signal != model.SignalSpans {
+		t.Fatalf("expected 7 dead letters, the unparsable body first, got %+v", all)
+	}
+	logs := list("?signal=logs&batch_id=" + logBatch)
+	if len(logs) != 2 || logs[1].Index != 1 || !strings.Contains(logs[1].Reason, `parsing time "yesterday"`) || !strings.Contains(logs[1].Payload, "bad time") {
+		t.Fatalf("unexpected log dead letters %+v", logs)
+	}
+	if metrics := list("?signal=metrics&limit=1"); len(metrics) != 1 || metrics[0].Reason != "cannot unmarshal string into Go struct field MetricEntry.value of type float64" {
+		t.Fatalf("unexpected metric dead letters %+v", metrics)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/logs?service=aex-gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored struct {
+		Count int `json:"count"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&stored)
+	_ = resp.Body.Close()
+	if stored.Count != 2 {
+		t.Fatalf("expected the 2 valid logs stored, got %d", stored.Count)
+	}
+}
+
+func TestSLOs(t *testing.T) {
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	now := time.Now().UTC()
+	var metrics []model.MetricEntry
+	for i := 0; i < 100; i++ {
+		latency := 120.0
+		if i%40 == 0 {
+			latency = 900
+		}
+		metrics = append(metrics, model.MetricEntry{Name: "award_latency_ms", Type: model.MetricTypeHistogram, Value: latency, Service: "aex-contract-engine", Timestamp: now.Add(-time.Duration(100-i) * time.Second)})
+	}
+	for _, p := range []struct {
+		status string
+		at     time.Duration
+		value  float64
+	}{{"success", -50 * time.Minute, 100}, {"failure", -50 * time.Minute, 0}, {"success", -10 * time.Minute, 1090}, {"failure", -10 * time.Minute, 10}} {
+		metrics = append(metrics, model.MetricEntry{Name: "settlements_total", Type: model.MetricTypeCounter, Value: p.value, Service: "aex-settlement", Labels: map[string]string{"status": p.status}, Timestamp: now.Add(p.at)})
+	}
+	body, _ := json.Marshal(metrics)
+	resp, err := http.Post(ts.URL+"/v1/metrics", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	create := func(slo model.SLO) (int, model.SLO) {
+		t.Helper()
+		body, _ := json.Marshal(slo)
+		resp, err := http.Post(ts.URL+"/v1/slos", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out model.SLO
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	get := func(path string, v any) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+		}
+		_ = json.NewDecoder(resp.Body).Decode(v)
+	}
+
+	if status, _ := create(model.SLO{Name: "bad", Service: "aex-settlement", Type: model.SLORatio, Metric: "settlements_total", Objective: 0.99}); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a ratio SLO without good events, got %d", status)
+	}
+	if status, _ := create(model.SLO{Name: "bad", Service: "aex-settlement", Type: model.SLOLatency, Metric: "m", Objective: 99}); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an objective above 1, got %d", status)
+	}
+
+	status, latency := create(model.SLO{Name: "award latency p95 < 500ms", Service: "aex-contract-engine", Type: model.SLOLatency, Metric: "award_latency_ms", Threshold: 500, Objective: 0.95})
+	if status != http.StatusCreated || latency.Window != "30d" {
+		t.Fatalf("unexpected latency SLO %d %+v", status, latency)
+	}
+	_, success := create(model.SLO{Name: "settlement success > 99.5%", Service: "aex-settlement", Type: model.SLORatio, Metric: "settlements_total", GoodLabels: map[string]string{"status": "success"}, Objective: 0.995, Window: "24h"})
+
+	var st model.SLOStatus
+	get("/v1/slos/"+latency.ID+"/status", &st)
+	if st.TotalEvents != 100 || st.GoodEvents != 97 || st.Status != model.SLOHealthy || math.Abs(st.BudgetRemaining-0.4) > 1e-9 {
+		t.Fatalf("unexpected latency status %+v", st)
+	}
+	get("/v1/slos/"+success.ID+"/status", &st)
+	if st.TotalEvents != 1000 || st.GoodEvents != 990 || st.Status != model.SLOExhausted || math.Abs(st.BudgetConsumed-2) > 1e-9 {
+		t.Fatalf("unexpected ratio status %+v", st)
+	}
+
+	var burn struct {
+		BurnRates []model.BurnRate `json:"burn_rates"`
+	}
+	get("/v1/slos/"+success.ID+"/burn-rate?windows=5m,1h", &burn)
+	if len(burn.BurnRates) != 2 || burn.BurnRates[0].TotalEvents != 0 || burn.BurnRates[0].BurnRate != 0 || math.Abs(burn.BurnRates[1].BurnRate-2) > 1e-9 {
+		t.Fatalf("unexpected burn rates %+v", burn.BurnRates)
+	}
+
+	var overview struct {
+		Services []service.ServiceSLOStatus `json:"services"`
+	}
+	get("/v1/slos/status", &overview)
+	if len(overview.Services) != 2 || overview.Services[0].Service != "aex-contract-engine" || overview.Services[0].Status != model.SLOHealthy ||
+		overview.Services[1].Status != model.SLOExhausted {
+		t.Fatalf("unexpected service status %+v", overview.Services)
+	}
+	get("/v1/slos/status?service=aex-settlement", &overview)
+	if len(overview.Services) != 1 || len(overview.Services[0].SLOs) != 1 {
+		t.Fatalf("unexpected filtered status %+v", overview.Services)
+	}
+}
+
+func TestLabelCardinalityLimits(t *testing.T) {
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	svc.SetLabelValueLimits(3, map[string]int{"bids_total": 2, "unbounded_total": 0})
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	var metrics []model.MetricEntry
+	for i := 0; i < 5; i++ {
+		id := "work-" + strconv.Itoa(i)
+		metrics = append(metrics,
+			model.MetricEntry{Name: "bids_total", Type: model.MetricTypeCounter, Value: 1, Service: "aex-bid-gateway", Labels: map[string]string{"work_id": id, "region": "eu"}},
+			model.MetricEntry{Name: "awards_total", Type: model.MetricTypeCounter, Value: 1, Service: "aex-contract-engine", Labels: map[string]string{"work_id": id}},
+			model.MetricEntry{Name: "unbounded_total", Type: model.MetricTypeCounter, Value: 1, Service: "aex-contract-engine", Labels: map[string]string{"work_id": id}},
+		)
+	}
+	// A value admitted before the limit was reached stays admitted.
+	metrics = append(metrics, model.MetricEntry{Name: "bids_total", Type: model.MetricTypeCounter, Value: 2, Service: "aex-bid-gateway", Labels: map[string]string{"work_id": "work-0", "region": "eu"}})
+	body, _ := json.Marshal(metrics)
+	resp, err := http.Post(ts.URL+"/v1/metrics", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = http.Get(ts.URL + "/v1/metrics?name=bids_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored struct {
+		Metrics []model.MetricEntry `json:"metrics"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&stored)
+	_ = resp.Body.Close()
+	values := map[string]int{}
+	for _, m := range stored.Metrics {
+		values[m.Labels["work_id"]]++
+		if m.Labels["region"] != "eu" {
+			t.Fatalf("label under the limit was changed: %+v", m.Labels)
+		}
+	}
+	if len(stored.Metrics) != 6 || values["work-0"] != 2 || values["work-1"] != 1 || values[service.OverflowLabelValue] != 3 {
+		t.Fatalf("unexpected bucketing %v", values)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/metrics/cardinality?limit=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		DefaultLimit int                        `json:"default_limit"`
+		Labels       []service.LabelCardinality `json:"labels"`
+		Total        int                        `json:"total"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&report)
+	_ = resp.Body.Close()
+	if report.DefaultLimit != 3 || report.Total != 3 || len(report.Labels) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	worst := report.Labels[0]
+	if worst.Metric != "bids_total" || worst.Label != "work_id" || worst.Limit != 2 || worst.DistinctValues != 2 ||
+		worst.OverflowedPoints != 3 || worst.LastOverflowed != "work-4" || worst.LastOverflowAt == nil {
+		t.Fatalf("unexpected worst offender %+v", worst)
+	}
+	if next := report.Labels[1]; next.Metric != "awards_total" || next.OverflowedPoints != 2 || next.DistinctValues != 3 {
+		t.Fatalf("unexpected second offender %+v", next)
+	}
+}
+
+func TestMarketplaceEvents(t *testing.T) {
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	postEnvelope := func(env events.Envelope) (int, map[string]any) {
+		t.Helper()
+		body, _ := json.Marshal(env)
+		resp, err := http.Post(ts.URL+"/internal/v1/events", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	if status, _ := postEnvelope(events.Envelope{EventID: "evt_1", EventType: events.EventWorkSubmitted}); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with the consumer disabled, got %d", status)
+	}
+
+	svc.EnableEventConsumer(service.DefaultEventTypes)
+	ctx := context.Background()
+	work := events.NewPublisher("aex-work-publisher")
+	work.RegisterEndpoint(events.EventWorkSubmitted, ts.URL+"/internal/v1/events")
+	contracts := events.NewPublisher("aex-contract-engine")
+	for _, typ := range []string{events.EventContractAwarded, events.EventContractCompleted} {
+		contracts.RegisterEndpoint(typ, ts.URL+"/internal/v1/events")
+	}
+	_ = work.Publish(ctx, events.EventWorkSubmitted, map[string]any{"work_id": "work_1", "consumer_id": "tenant_a", "tenant_id": "tenant_a"})
+	_ = contracts.Publish(ctx, events.EventContractAwarded, map[string]any{"contract_id": "contract_1", "work_id": "work_1"})
+	_ = contracts.Publish(ctx, events.EventContractCompleted, map[string]any{"contract_id": "contract_1", "work_id": "work_1", "provider_id": "prov_1", "consumer_id": "tenant_a"})
+
+	status, out := postEnvelope(events.Envelope{EventID: "evt_trust", EventType: events.EventTrustTierChanged, Source: "aex-trust-broker",
+		Timestamp: time.Now().UTC().Add(time.Second), Data: map[string]any{"provider_id": "prov_1", "new_tier": "TRUSTED"}})
+	if status != http.StatusAccepted || out["stored"] != true {
+		t.Fatalf("unexpected response %d %v", status, out)
+	}
+	if status, out = postEnvelope(events.Envelope{EventID: "evt_trust", EventType: events.EventTrustTierChanged}); status != http.StatusAccepted || out["stored"] != false {
+		t.Fatalf("expected a redelivery to be acknowledged but not stored, got %d %v", status, out)
+	}
+	if status, _ = postEnvelope(events.Envelope{EventType: events.EventWorkSubmitted}); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 without an event id, got %d", status)
+	}
+
+	query := func(params string) []model.MarketEvent {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/events" + params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out struct {
+			Events []model.MarketEvent `json:"events"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out.Events
+	}
+	all := query("")
+	if len(all) != 3 || all[0].Type != events.EventTrustTierChanged {
+		t.Fatalf("expected the 3 subscribed events, newest first, got %+v", all)
+	}
+	if trail := query("?work_id=work_1"); len(trail) != 2 || trail[0].Type != events.EventContractCompleted || trail[1].TenantID != "tenant_a" || trail[1].Source != "aex-work-publisher" {
+		t.Fatalf("unexpected work trail %+v", trail)
+	}
+	if byProvider := query("?provider_id=prov_1&type=contract.completed,trust.tier_changed"); len(byProvider) != 2 {
+		t.Fatalf("unexpected provider events %+v", byProvider)
+	}
+	if limited := query("?limit=1&type=work.submitted"); len(limited) != 1 || limited[0].ConsumerID != "tenant_a" || limited[0].Data["work_id"] != "work_1" {
+		t.Fatalf("unexpected work events %+v", limited)
+	}
+}
+
+func TestTenantScoping(t *testing.T) {
+	svc := service.New(store.NewMemoryStore(1000, 1000))
+	svc.EnableTenantScoping([]string{"tenant_ops"}, "gw_secret")
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	now := time.Now().UTC()
+	post := func(path string, v any) {
+		t.Helper()
+		body, _ := json.Marshal(v)
+		resp, err := http.Post(ts.URL+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	post("/v1/logs", []model.LogEntry{
+		{Level: "info", Service: "aex-work-publisher", Message: "work submitted", Fields: map[string]any{"tenant_id": "tenant_a"}},
+		{Level: "info", Service: "aex-work-publisher", Message: "work submitted", Fields: map[string]any{"tenant_id": "tenant_b"}},
+		{Level: "info", Service: "aex-work-publisher", Message: "untagged"},
+	})
+	post("/v1/metrics", []model.MetricEntry{
+		{Name: "work_submitted_total", Type: model.MetricTypeCounter, Value: 3, Service: "aex-work-publisher", Labels: map[string]string{"tenant_id": "tenant_a"}, Timestamp: now},
+		{Name: "work_submitted_total", Type: model.MetricTypeCounter, Value: 5, Service: "aex-work-publisher", Labels: map[string]string{"tenant_id": "tenant_b"}, Timestamp: now},
+	})
+	post("/v1/spans", []model.TraceSpan{
+		{TraceID: "ta", SpanID: "1", Service: "aex-gateway", StartTime: now, Attributes: map[string]string{"tenant_id": "tenant_a"}},
+		{TraceID: "ta", SpanID: "2", ParentSpanID: "1", Service: "aex-work-publisher", StartTime: now, Attributes: map[string]string{"tenant_id": "tenant_a"}},
+		{TraceID: "tb", SpanID: "1", Service: "aex-gateway", StartTime: now, Attributes: map[string]string{"tenant_id": "tenant_b"}},
+	})
+
+	getAs := func(path, gatewayToken, tenant string, v any) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if gatewayToken != "" {
+			req.Header.Set(service.GatewayTokenHeader, gatewayToken)
+		}
+		if tenant != "" {
+			req.Header.Set(service.TenantHeader, tenant)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if v != nil {
+			_ = json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode
+	}
+	get := func(path, tenant string, v any) int {
+		t.Helper()
+		return getAs(path, "gw_secret", tenant, v)
+	}
+
+	if status := get("/v1/logs", "", nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a tenant, got %d", status)
+	}
+	// The tenant header is only trusted from the gateway.
+	if status := getAs("/v1/logs", "", "tenant_ops", nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the gateway token, got %d", status)
+	}
+	if status := getAs("/v1/stats", "forged", "tenant_ops", nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a wrong gateway token, got %d", status)
+	}
+	var logs struct {
+		Logs []model.LogEntry `json:"logs"`
+	}
+	get("/v1/logs?field.tenant_id=tenant_b", "tenant_a", &logs)
+	if len(logs.Logs) != 1 || logs.Logs[0].Fields["tenant_id"] != "tenant_a" {
+		t.Fatalf("expected only tenant_a's log, got %+v", logs.Logs)
+	}
+	get("/v1/logs", "tenant_ops", &logs)
+	if len(logs.Logs) != 3 {
+		t.Fatalf("expected the operator to see all 3 logs, got %d", len(logs.Logs))
+	}
+
+	var metrics struct {
+		Metrics []model.MetricEntry `json:"metrics"`
+	}
+	get("/v1/metrics?name=work_submitted_total", "tenant_b", &metrics)
+	if len(metrics.Metrics) != 1 || metrics.Metrics[0].Value != 5 {
+		t.Fatalf("expected only tenant_b's point, got %+v", metrics.Metrics)
+	}
+	var agg struct {
+		Series []service.AggregateSeries `json:"series"`
+	}
+	get("/v1/metrics/aggregate?name=work_submitted_total&agg=sum&resolution=raw", "tenant_a", &agg)
+	if len(agg.Series) != 1 || agg.Series[0].Points[0].Value != 3 {
+		t.Fatalf("expected tenant_a's sum of 3, got %+v", agg.Series)
+	}
+
+	var traces struct {
+		Traces []model.TraceSummary `json:"traces"`
+	}
+	get("/v1/traces", "tenant_a", &traces)
+	if len(traces.Traces) != 1 || traces.Traces[0].TraceID != "ta" {
+		t.Fatalf("expected only trace ta, got %+v", traces.Traces)
+	}
+	var trace struct {
+		Spans []model.TraceSpan `json:"spans"`
+	}
+	get("/v1/traces/tb", "tenant_a", &trace)
+	if len(trace.Spans) != 0 {
+		t.Fatalf("expected no spans of another tenant's trace, got %+v", trace.Spans)
+	}
+	var serviceMap struct {
+		Nodes []service.ServiceNode `json:"nodes"`
+	}
+	get("/v1/service-map", "tenant_b", &serviceMap)
+	if len(serviceMap.Nodes) != 1 || serviceMap.Nodes[0].SpanCount != 1 {
+		t.Fatalf("expected tenant_b's single span in the map, got %+v", serviceMap.Nodes)
+	}
+
+	if status := get("/v1/stats", "tenant_a", nil); status != http.StatusForbidden {
+		t.Fatalf("expected 403 for a tenant on an operator endpoint, got %d", status)
+	}
+	if status := get("/v1/alerts/rules", "tenant_ops", nil); status != http.StatusOK {
+		t.Fatalf("expected the operator on an operator endpoint, got %d", status)
+	}
+}
+
+func TestProbesAndExchangeStatus(t *testing.T) {
+	memStore := store.NewMemoryStore(1000, 1000)
+	svc := service.New(memStore)
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+
+	failing := false
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/providers" && failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer registry.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	put := func(service string, target model.ProbeTarget) int {
+		t.Helper()
+		body, _ := json.Marshal(target)
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/v1/probes/"+service, bytes.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := put("aex-bad", model.ProbeTarget{BaseURL: "not a url"}); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid base_url, got %d", status)
+	}
+	if status := put("aex-provider-registry", model.ProbeTarget{BaseURL: registry.URL, Paths: []string{"/v1/providers"}}); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if status := put("aex-settlement", model.ProbeTarget{BaseURL: down.URL}); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	type status struct {
+		Status   string                `json:"status"`
+		Services []model.ServiceStatus `json:"services"`
+	}
+	getStatus := func() status {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var s status
+		_ = json.NewDecoder(resp.Body).Decode(&s)
+		return s
+	}
+	if s := getStatus(); s.Status != "operational" || len(s.Services) != 2 || s.Services[0].Status != model.ProbeUnknown {
+		t.Fatalf("expected unprobed services, got %+v", s)
+	}
+
+	ctx := context.Background()
+	svc.ProbeServices(ctx)
+	failing = true
+	svc.ProbeServices(ctx)
+
+	s := getStatus()
+	if s.Status != "partial_outage" || len(s.Services) != 2 {
+		t.Fatalf("expected a partial outage, got %+v", s)
+	}
+	reg, settle := s.Services[0], s.Services[1]
+	if reg.Status != model.ProbeDegraded || len(reg.Checks) != 2 || reg.Availability != 0.75 {
+		t.Fatalf("expected the registry degraded at 75%% availability, got %+v", reg)
+	}
+	if settle.Status != model.ProbeDown || settle.Availability != 0 || settle.Checks[0].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected settlement down, got %+v", settle)
+	}
+
+	points, err := memStore.QueryMetrics(ctx, model.MetricQuery{Name: service.ProbeUpMetric, Service: "aex-provider-registry"})
+	if err != nil || len(points) != 4 {
+		t.Fatalf("expected 4 probe_up points, got %d (%v)", len(points), err)
+	}
+	latency, _ := memStore.QueryMetrics(ctx, model.MetricQuery{Name: service.ProbeLatencyMetric})
+	if len(latency) != 6 {
+		t.Fatalf("expected 6 latency points, got %d", len(latency))
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/probes/aex-provider-registry", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	if s := getStatus(); s.Status != "major_outage" || len(s.Services) != 1 {
+		t.Fatalf("expected a major outage, got %+v", s)
+	}
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	st := store.NewMemoryStore(1000, 1000)
+	svc := service.New(st)
+	ts := httptest.NewServer(httpapi.NewRouter(svc))
+	defer ts.Close()
+	ctx := context.Background()
+	now := time.Now()
+
+	put := func(policy model.RetentionPolicy) (int, model.RetentionPolicy) {
+		t.Helper()
+		body, _ := json.Marshal(policy)
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/v1/retention", bytes.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out model.RetentionPolicy
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	for _, bad := range []model.RetentionRule{
+		{Signal: "events", Retention: "1d"},
+		{Signal: model.SignalSpans, Level: "DEBUG", Retention: "1d"},
+		{Signal: model.SignalLogs, Retention: "soon"},
+	} {
+		if status, _ := put(model.RetentionPolicy{Rules: []model.RetentionRule{bad}}); status != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %+v, got %d", bad, status)
+		}
+	}
+	status, policy := put(model.RetentionPolicy{Rules: []model.RetentionRule{
+		{Signal: model.SignalLogs, Level: "DEBUG", Retention: "24h"},
+		{Signal: model.SignalLogs, Service: "aex-settlement", Retention: "30d"},
+		{Signal: model.SignalLogs, Service: "aex-settlement", Level: "debug", Retention: "12h"},
+		{Signal: model.SignalSpans, Retention: "7d"},
+		{Signal: model.SignalMetrics, Service: "aex-gateway", Retention: "0"},
+	}})
+	if status != http.StatusOK || len(policy.Rules) != 5 || policy.UpdatedAt == nil {
+		t.Fatalf("expected the rules to be set, got %d %+v", status, policy)
+	}
+
+	for _, age := range []time.Duration{6 * time.Hour, 18 * time.Hour, 2 * 24 * time.Hour, 10 * 24 * time.Hour} {
+		for _, service := range []string{"aex-gateway", "aex-settlement"} {
+			for _, level := range []string{"debug", "info"} {
+				_ = st.AddLog(ctx, model.LogEntry{Timestamp: now.Add(-age), Service: service, Level: level, Message: "m"})
+			}
+			_ = st.AddMetric(ctx, model.MetricEntry{Timestamp: now.Add(-age), Name: "requests", Service: service, Value: 1})
+			_ = st.AddSpan(ctx, model.TraceSpan{TraceID: service, SpanID: age.String(), Service: service, StartTime: now.Add(-age)})
+		}
+	}
+
+	// A cancelled context runs a single retention pass.
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	svc.RunRetention(done, map[model.Signal]time.Duration{
+		model.SignalLogs:    3 * 24 * time.Hour,
+		model.SignalMetrics: 24 * time.Hour,
+	}, time.Minute)
+
+	count := func(logs []model.LogEntry, service, level string) int {
+		n := 0
+		for _, l := range logs {
+			if l.Service == service && l.Level == level {
+				n++
+			}
+		}
+		return n
+	}
+	logs, _ := st.QueryLogs(ctx, model.LogQuery{Limit: 100})
+	for _, c := range []struct {
+		service, level string
+		want           int
+	}{
+		{"aex-gateway", "debug", 2},    // 24h by level
+		{"aex-gateway", "info", 3},     // 3d configured
+		{"aex-settlement", "debug", 1}, // 12h by service and level
+		{"aex-settlement", "info", 4},  // 30d by service
+	} {
+		if got := count(logs, c.service, c.level); got != c.want {
+			t.Fatalf("expected %d %s %s logs, got %d", c.want, c.service, c.level, got)
+		}
+	}
+	metrics, _ := st.QueryMetrics(ctx, model.MetricQuery{Name: "requests", Limit: 100})
+	if len(metrics) != 6 {
+		t.Fatalf("expected aex-gateway's 4 metrics kept forever and 2 recent others, got %d", len(metrics))
+	}
+	spans, _ := st.QuerySpans(ctx, model.SpanQuery{Limit: 100})
+	if len(spans) != 6 {
+		t.Fatalf("expected spans of the last 7d, got %d", len(spans))
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_ = json.NewDecoder(resp.Body).Decode(&policy)
+	if policy.Defaults[model.SignalLogs] != "3d" || policy.Defaults[model.SignalMetrics] != "1d" || len(policy.Rules) != 5 {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+}
@@ -0,0 +1,53 @@
+// Package archive writes exported telemetry to object storage.
+package archive
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ObjectStore stores objects under a key.
+type ObjectStore interface {
+	// Put uploads size bytes read from body as key, replacing any object
+	// already there. body may be rewound to compute checksums.
+	Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error
+	// Location describes where key is stored, such as s3://bucket/key.
+	Location(key string) string
+}
+
+// FileStore keeps objects as files under a directory, for local runs.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) Location(key string) string {
+	return "file://" + filepath.Join(s.dir, filepath.FromSlash(key))
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCSStore uploads objects to a Cloud Storage bucket through the JSON API,
+// authenticated as the instance's service account via the metadata server.
+type GCSStore struct {
+	endpoint string
+	tokenURL string
+	bucket   string
+	http     *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewGCSStore returns a store for bucket. An empty endpoint uses Google's;
+// a different one (such as an emulator) is called without a token.
+func NewGCSStore(endpoint, bucket string) *GCSStore {
+	s := &GCSStore{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		bucket:   bucket,
+		http:     &http.Client{Timeout: 5 * time.Minute},
+	}
+	if s.endpoint == "" {
+		s.endpoint = "https://storage.googleapis.com"
+		s.tokenURL = metadataTokenURL
+	}
+	return s
+}
+
+func (s *GCSStore) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error {
+	u := s.endpoint + "/upload/storage/v1/b/" + url.PathEscape(s.bucket) + "/o?uploadType=media&name=" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, io.NopCloser(body))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	if s.tokenURL != "" {
+		token, err := s.accessToken(ctx)
+		if err != nil {
+			return fmt.Errorf("gcs token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("gcs upload %s returned %d: %s", key, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (s *GCSStore) Location(key string) string {
+	return "gs://" + s.bucket + "/" + key
+}
+
+// accessToken returns a cached metadata server token, refreshing it a
+// minute before it expires.
+func (s *GCSStore) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %d", resp.StatusCode)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	s.token = tok.AccessToken
+	s.expires = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -11,15 +13,131 @@ type Config struct {
 	LogLevel       string
 	MaxLogEntries  int
 	MaxMetricItems int
+
+	// StoreType is "memory" (default) or "mongo".
+	StoreType              string
+	MongoURI               string
+	MongoDatabase          string
+	MongoCollectionLogs    string
+	MongoCollectionMetrics string
+	MongoCollectionSpans   string
+	MongoCollectionEvents  string
+
+	// LogRetention, MetricRetention and SpanRetention are how long each
+	// signal is kept; 0 keeps it (the memory store still evicts the oldest
+	// items at capacity). With rollups, raw metric points are only expired
+	// once rolled up.
+	LogRetention      time.Duration
+	MetricRetention   time.Duration
+	SpanRetention     time.Duration
+	RetentionInterval time.Duration
+
+	// MetricsStaleness is how long an ingested series stays on /metrics
+	// after it was last reported (METRICS_STALENESS).
+	MetricsStaleness time.Duration
+
+	// AlertEvalInterval is how often alert rules are evaluated; 0 disables
+	// evaluation. AlertWebhookSecret signs alert webhooks (HMAC-SHA256).
+	AlertEvalInterval  time.Duration
+	AlertWebhookSecret string
+
+	// RollupInterval is how often metrics are rolled up into 1m, 5m and 1h
+	// tiers; 0 disables rollups. Each tier is kept for its retention.
+	RollupInterval    time.Duration
+	Rollup1mRetention time.Duration
+	Rollup5mRetention time.Duration
+	Rollup1hRetention time.Duration
+
+	// LabelValueLimit is how many distinct values a label of one metric may
+	// take before further values are bucketed as "__overflow__"; 0 leaves
+	// labels unbounded. LabelValueLimits overrides it per metric, from
+	// METRIC_LABEL_VALUE_LIMITS as "name=limit,name=limit".
+	LabelValueLimit  int
+	LabelValueLimits map[string]int
+
+	// TenantScoping restricts the query APIs to the telemetry tagged with
+	// the tenant_id of the X-Tenant-ID the gateway sets, trusted only on
+	// requests carrying the gateway's GatewayToken (its UPSTREAM_TOKEN);
+	// OperatorTenants see everything and alone reach the administrative
+	// endpoints.
+	TenantScoping   bool
+	GatewayToken    string
+	OperatorTenants []string
+
+	// ProbeInterval is how often the prober checks ProbeTargets, given as
+	// PROBE_TARGETS="service=base URL,...", on /health and on the extra
+	// read paths of PROBE_PATHS="service=/path|/path,..."; 0 disables it.
+	ProbeInterval time.Duration
+	ProbeTargets  map[string]string
+	ProbePaths    map[string]string
+
+	// EventsConsumer turns on POST /internal/v1/events, which stores the
+	// EventTypes ("*" for all) published on the shared event bus for
+	// GET /v1/events. Publishers point their EVENTS_URL at it.
+	EventsConsumer bool
+	EventTypes     []string
+
+	// ExportStore is where POST /v1/export writes: "" (exports disabled),
+	// "file" (under ExportDir), "s3" or "gcs" (into ExportBucket). Objects
+	// are keyed under ExportPrefix. S3 credentials come from the standard
+	// AWS_* variables; GCS uses the instance's service account.
+	ExportStore        string
+	ExportBucket       string
+	ExportPrefix       string
+	ExportDir          string
+	ExportS3Region     string
+	ExportS3Endpoint   string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+	ExportGCSEndpoint  string
 }
 
 func Load() *Config {
 	return &Config{
-		Port:           getEnv("PORT", "8089"),
-		Environment:    getEnv("ENVIRONMENT", "development"),
-		LogLevel:       getEnv("LOG_LEVEL", "info"),
-		MaxLogEntries:  getEnvInt("MAX_LOG_ENTRIES", 10000),
-		MaxMetricItems: getEnvInt("MAX_METRIC_ITEMS", 10000),
+		Port:                   getEnv("PORT", "8089"),
+		Environment:            getEnv("ENVIRONMENT", "development"),
+		LogLevel:               getEnv("LOG_LEVEL", "info"),
+		MaxLogEntries:          getEnvInt("MAX_LOG_ENTRIES", 10000),
+		MaxMetricItems:         getEnvInt("MAX_METRIC_ITEMS", 10000),
+		StoreType:              getEnv("STORE_TYPE", "memory"),
+		MongoURI:               getEnv("MONGO_URI", "mongodb://localhost:27017"),
+		MongoDatabase:          getEnv("MONGO_DB", "aex"),
+		MongoCollectionLogs:    getEnv("MONGO_COLLECTION_LOGS", "telemetry_logs"),
+		MongoCollectionMetrics: getEnv("MONGO_COLLECTION_METRICS", "telemetry_metrics"),
+		MongoCollectionSpans:   getEnv("MONGO_COLLECTION_SPANS", "telemetry_spans"),
+		MongoCollectionEvents:  getEnv("MONGO_COLLECTION_EVENTS", "telemetry_events"),
+		LogRetention:           getEnvDuration("LOG_RETENTION", 7*24*time.Hour),
+		MetricRetention:        getEnvDuration("METRIC_RETENTION", 48*time.Hour),
+		SpanRetention:          getEnvDuration("SPAN_RETENTION", 3*24*time.Hour),
+		RetentionInterval:      getEnvDuration("RETENTION_INTERVAL", 10*time.Minute),
+		MetricsStaleness:       getEnvDuration("METRICS_STALENESS", 5*time.Minute),
+		AlertEvalInterval:      getEnvDuration("ALERT_EVAL_INTERVAL", 30*time.Second),
+		AlertWebhookSecret:     getEnv("ALERT_WEBHOOK_SECRET", ""),
+		RollupInterval:         getEnvDuration("ROLLUP_INTERVAL", time.Minute),
+		Rollup1mRetention:      getEnvDuration("ROLLUP_1M_RETENTION", 7*24*time.Hour),
+		Rollup5mRetention:      getEnvDuration("ROLLUP_5M_RETENTION", 30*24*time.Hour),
+		Rollup1hRetention:      getEnvDuration("ROLLUP_1H_RETENTION", 365*24*time.Hour),
+		LabelValueLimit:        getEnvInt("METRIC_LABEL_VALUE_LIMIT", 1000),
+		LabelValueLimits:       getEnvIntMap("METRIC_LABEL_VALUE_LIMITS"),
+		TenantScoping:          getEnv("TENANT_SCOPING_ENABLED", "false") == "true",
+		GatewayToken:           getEnv("GATEWAY_TOKEN", ""),
+		OperatorTenants:        getEnvList("OPERATOR_TENANT_IDS"),
+		ProbeInterval:          getEnvDuration("PROBE_INTERVAL", 30*time.Second),
+		ProbeTargets:           getEnvMap("PROBE_TARGETS"),
+		ProbePaths:             getEnvMap("PROBE_PATHS"),
+		EventsConsumer:         getEnv("EVENTS_CONSUMER_ENABLED", "false") == "true",
+		EventTypes:             getEnvList("EVENT_TYPES"),
+		ExportStore:            getEnv("EXPORT_STORE", ""),
+		ExportBucket:           getEnv("EXPORT_BUCKET", ""),
+		ExportPrefix:           getEnv("EXPORT_PREFIX", "aex-telemetry"),
+		ExportDir:              getEnv("EXPORT_DIR", "./exports"),
+		ExportS3Region:         getEnv("AWS_REGION", "us-east-1"),
+		ExportS3Endpoint:       getEnv("EXPORT_S3_ENDPOINT", ""),
+		AWSAccessKeyID:         getEnv("AWS_ACCESS_KEY_ID", ""),
+		AWSSecretAccessKey:     getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		AWSSessionToken:        getEnv("AWS_SESSION_TOKEN", ""),
+		ExportGCSEndpoint:      getEnv("EXPORT_GCS_ENDPOINT", ""),
 	}
 }
 
@@ -38,3 +156,47 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvList splits a comma-separated value; nil when unset.
+func getEnvList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// getEnvMap parses "key=value,key=value", skipping malformed pairs.
+func getEnvMap(key string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || k == "" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// getEnvIntMap parses "key=n,key=n", skipping malformed pairs.
+func getEnvIntMap(key string) map[string]int {
+	out := map[string]int{}
+	for k, v := range getEnvMap(key) {
+		if i, err := strconv.Atoi(v); err == nil {
+			out[k] = i
+		}
+	}
+	return out
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
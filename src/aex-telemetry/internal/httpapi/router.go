@@ -7,23 +7,79 @@ import (
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/service"
 )
 
+// NewRouter routes the API. With tenant scoping on, query endpoints only
+// return the caller's telemetry and the administrative ones are for
+// operators.
 func NewRouter(svc *service.Service) http.Handler {
 	mux := http.NewServeMux()
 
 	// Log endpoints
 	mux.HandleFunc("POST /v1/logs", svc.HandleIngestLogs)
 	mux.HandleFunc("GET /v1/logs", svc.HandleQueryLogs)
+	mux.HandleFunc("GET /v1/logs/stream", svc.HandleStreamLogs)
 
 	// Metrics endpoints
 	mux.HandleFunc("POST /v1/metrics", svc.HandleIngestMetrics)
 	mux.HandleFunc("GET /v1/metrics", svc.HandleQueryMetrics)
+	mux.HandleFunc("GET /v1/metrics/aggregate", svc.HandleAggregateMetrics)
+	mux.HandleFunc("GET /v1/metrics/cardinality", svc.OperatorOnly(svc.HandleLabelCardinality))
 
 	// Trace endpoints
 	mux.HandleFunc("POST /v1/spans", svc.HandleIngestSpans)
+	mux.HandleFunc("GET /v1/traces", svc.HandleSearchTraces)
 	mux.HandleFunc("GET /v1/traces/{trace_id}", svc.HandleGetTrace)
+	mux.HandleFunc("GET /v1/service-map", svc.HandleServiceMap)
+
+	// OTLP/HTTP endpoints
+	mux.HandleFunc("POST /v1/otlp/traces", svc.HandleOTLPTraces)
+	mux.HandleFunc("POST /v1/otlp/metrics", svc.HandleOTLPMetrics)
+	mux.HandleFunc("POST /v1/otlp/logs", svc.HandleOTLPLogs)
+
+	// Marketplace events from the shared event bus
+	mux.HandleFunc("POST /internal/v1/events", svc.HandleReceiveEvent)
+	mux.HandleFunc("GET /v1/events", svc.HandleQueryEvents)
+
+	// Items rejected at ingestion
+	mux.HandleFunc("GET /v1/dead-letters", svc.OperatorOnly(svc.HandleListDeadLetters))
+
+	// Alerting endpoints
+	mux.HandleFunc("POST /v1/alerts/rules", svc.OperatorOnly(svc.HandleCreateAlertRule))
+	mux.HandleFunc("GET /v1/alerts/rules", svc.OperatorOnly(svc.HandleListAlertRules))
+	mux.HandleFunc("GET /v1/alerts/rules/{id}", svc.OperatorOnly(svc.HandleGetAlertRule))
+	mux.HandleFunc("PUT /v1/alerts/rules/{id}", svc.OperatorOnly(svc.HandleUpdateAlertRule))
+	mux.HandleFunc("DELETE /v1/alerts/rules/{id}", svc.OperatorOnly(svc.HandleDeleteAlertRule))
+	mux.HandleFunc("GET /v1/alerts/events", svc.OperatorOnly(svc.HandleListAlertEvents))
+
+	// SLO endpoints
+	mux.HandleFunc("POST /v1/slos", svc.OperatorOnly(svc.HandleCreateSLO))
+	mux.HandleFunc("GET /v1/slos", svc.OperatorOnly(svc.HandleListSLOs))
+	mux.HandleFunc("GET /v1/slos/status", svc.OperatorOnly(svc.HandleListSLOStatus))
+	mux.HandleFunc("GET /v1/slos/{id}", svc.OperatorOnly(svc.HandleGetSLO))
+	mux.HandleFunc("PUT /v1/slos/{id}", svc.OperatorOnly(svc.HandleUpdateSLO))
+	mux.HandleFunc("DELETE /v1/slos/{id}", svc.OperatorOnly(svc.HandleDeleteSLO))
+	mux.HandleFunc("GET /v1/slos/{id}/status", svc.OperatorOnly(svc.HandleGetSLOStatus))
+	mux.HandleFunc("GET /v1/slos/{id}/burn-rate", svc.OperatorOnly(svc.HandleGetSLOBurnRate))
+
+	// Retention policy
+	mux.HandleFunc("GET /v1/retention", svc.OperatorOnly(svc.HandleGetRetention))
+	mux.HandleFunc("PUT /v1/retention", svc.OperatorOnly(svc.HandlePutRetention))
+
+	// Synthetic probes and the exchange status they feed
+	mux.HandleFunc("PUT /v1/probes/{service}", svc.OperatorOnly(svc.HandlePutProbeTarget))
+	mux.HandleFunc("GET /v1/probes", svc.OperatorOnly(svc.HandleListProbeTargets))
+	mux.HandleFunc("DELETE /v1/probes/{service}", svc.OperatorOnly(svc.HandleDeleteProbeTarget))
+	mux.HandleFunc("GET /v1/status", svc.HandleExchangeStatus)
+
+	// Export endpoints
+	mux.HandleFunc("POST /v1/export", svc.OperatorOnly(svc.HandleCreateExport))
+	mux.HandleFunc("GET /v1/export", svc.OperatorOnly(svc.HandleListExports))
+	mux.HandleFunc("GET /v1/export/{id}", svc.OperatorOnly(svc.HandleGetExport))
 
 	// Stats endpoint
-	mux.HandleFunc("GET /v1/stats", svc.HandleGetStats)
+	mux.HandleFunc("GET /v1/stats", svc.OperatorOnly(svc.HandleGetStats))
+
+	// Prometheus exposition of ingested metrics
+	mux.HandleFunc("GET /metrics", svc.OperatorOnly(svc.HandlePrometheusMetrics))
 
 	// Health endpoints
 	mux.HandleFunc("GET /health", healthHandler)
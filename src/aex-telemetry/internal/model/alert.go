@@ -0,0 +1,79 @@
+package model
+
+import "time"
+
+type AlertRuleType string
+
+const (
+	// AlertRuleMetricThreshold compares an aggregation of a metric over the
+	// window with the threshold.
+	AlertRuleMetricThreshold AlertRuleType = "metric_threshold"
+	// AlertRuleLogPattern compares the number of logs matching a pattern
+	// over the window with the threshold.
+	AlertRuleLogPattern AlertRuleType = "log_pattern"
+)
+
+type AlertState string
+
+const (
+	AlertInactive AlertState = "INACTIVE"
+	// AlertPending means the condition holds but not yet for the rule's For.
+	AlertPending  AlertState = "PENDING"
+	AlertFiring   AlertState = "FIRING"
+	AlertResolved AlertState = "RESOLVED"
+)
+
+// AlertRule is a condition evaluated periodically over recent telemetry.
+type AlertRule struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Type        AlertRuleType `json:"type"`
+	Description string        `json:"description,omitempty"`
+
+	// Service restricts the rule to one reporting service.
+	Service string `json:"service,omitempty"`
+
+	// Metric, Labels and Aggregation select the metric_threshold value.
+	// Aggregation is one of the /v1/metrics/aggregate aggregations.
+	Metric      string            `json:"metric,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Aggregation string            `json:"aggregation,omitempty"`
+
+	// Pattern is the regular expression log_pattern matches against log
+	// messages, optionally only at Level.
+	Pattern string `json:"pattern,omitempty"`
+	Level   string `json:"level,omitempty"`
+
+	// Comparator is gt, gte, lt or lte; the condition holds when
+	// value Comparator Threshold.
+	Comparator string  `json:"comparator"`
+	Threshold  float64 `json:"threshold"`
+	// Window is the lookback evaluated, such as "5m".
+	Window string `json:"window"`
+	// For is how long the condition must hold before the alert fires;
+	// empty fires on the first evaluation that holds.
+	For string `json:"for,omitempty"`
+
+	// WebhookURL receives the rule's state transitions.
+	WebhookURL string `json:"webhook_url,omitempty"`
+
+	State           AlertState `json:"state"`
+	Value           float64    `json:"value"`
+	ActiveSince     *time.Time `json:"active_since,omitempty"`
+	LastEvaluatedAt *time.Time `json:"last_evaluated_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// AlertEvent records a rule's state transition. It is also the webhook
+// payload.
+type AlertEvent struct {
+	EventID   string     `json:"event_id"`
+	RuleID    string     `json:"rule_id"`
+	RuleName  string     `json:"rule_name"`
+	From      AlertState `json:"from"`
+	State     AlertState `json:"state"`
+	Value     float64    `json:"value"`
+	Threshold float64    `json:"threshold"`
+	Timestamp time.Time  `json:"timestamp"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// DeadLetter is an ingested item rejected as malformed, kept with the reason
+// so its sender can find and fix it.
+type DeadLetter struct {
+	ID      string `json:"id"`
+	BatchID string `json:"batch_id"`
+	Signal  Signal `json:"signal"`
+	// Index is the item's position in its batch, or -1 when the body as a
+	// whole could not be parsed.
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+	// Payload is the item as received, cut at a few kilobytes; Truncated
+	// tells when it was.
+	Payload    string    `json:"payload"`
+	Truncated  bool      `json:"truncated,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Rejection reports a rejected item of an ingested batch.
+type Rejection struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// IngestResult is the response to an ingested batch.
+type IngestResult struct {
+	BatchID    string      `json:"batch_id"`
+	Accepted   int         `json:"accepted"`
+	Rejected   int         `json:"rejected"`
+	Rejections []Rejection `json:"rejections,omitempty"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// MarketEvent is a business event received from the shared event bus, such
+// as work.submitted or contract.completed. The ids of the entities it
+// concerns are lifted out of Data so the audit trail can be searched by
+// them.
+type MarketEvent struct {
+	// ID is the bus event_id; an event delivered twice is stored once.
+	ID         string    `json:"id" bson:"_id"`
+	Type       string    `json:"type" bson:"type"`
+	Source     string    `json:"source" bson:"source"`
+	TenantID   string    `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Timestamp  time.Time `json:"timestamp" bson:"timestamp"`
+	ReceivedAt time.Time `json:"received_at" bson:"received_at"`
+
+	WorkID     string `json:"work_id,omitempty" bson:"work_id,omitempty"`
+	ContractID string `json:"contract_id,omitempty" bson:"contract_id,omitempty"`
+	ProviderID string `json:"provider_id,omitempty" bson:"provider_id,omitempty"`
+	ConsumerID string `json:"consumer_id,omitempty" bson:"consumer_id,omitempty"`
+
+	Data map[string]any `json:"data,omitempty" bson:"data,omitempty"`
+}
+
+// EventQuery represents parameters for querying marketplace events. Types
+// matches any of the listed event types.
+type EventQuery struct {
+	Types      []string  `json:"types,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	TenantID   string    `json:"tenant_id,omitempty"`
+	WorkID     string    `json:"work_id,omitempty"`
+	ContractID string    `json:"contract_id,omitempty"`
+	ProviderID string    `json:"provider_id,omitempty"`
+	ConsumerID string    `json:"consumer_id,omitempty"`
+	StartTime  time.Time `json:"start_time,omitempty"`
+	EndTime    time.Time `json:"end_time,omitempty"`
+	Limit      int       `json:"limit,omitempty"`
+}
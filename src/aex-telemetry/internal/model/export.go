@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+type ExportStatus string
+
+const (
+	ExportPending   ExportStatus = "pending"
+	ExportRunning   ExportStatus = "running"
+	ExportCompleted ExportStatus = "completed"
+	ExportFailed    ExportStatus = "failed"
+)
+
+// ExportRequest asks for the signals' data timestamped in [From, To) to be
+// written to object storage.
+type ExportRequest struct {
+	Signals []Signal  `json:"signals,omitempty"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	// Format is ndjson, the only one supported so far.
+	Format string `json:"format,omitempty"`
+	// Compression is gzip (default) or none.
+	Compression string `json:"compression,omitempty"`
+}
+
+// ExportJob tracks an export to object storage.
+type ExportJob struct {
+	ID          string         `json:"id"`
+	Status      ExportStatus   `json:"status"`
+	Request     ExportRequest  `json:"request"`
+	Objects     []ExportObject `json:"objects"`
+	Error       string         `json:"error,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// ExportObject is one object written by an export, holding one signal.
+type ExportObject struct {
+	Signal   Signal `json:"signal"`
+	Location string `json:"location"`
+	Records  int    `json:"records"`
+	Bytes    int64  `json:"bytes"`
+}
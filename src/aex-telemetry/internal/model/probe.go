@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+type ProbeState string
+
+const (
+	ProbeUp       ProbeState = "up"
+	ProbeDegraded ProbeState = "degraded"
+	ProbeDown     ProbeState = "down"
+	// ProbeUnknown means the service has not been probed yet.
+	ProbeUnknown ProbeState = "unknown"
+)
+
+// ProbeTarget is a service the prober checks: GET BaseURL+path for each of
+// Paths, which always include /health.
+type ProbeTarget struct {
+	Service string   `json:"service"`
+	BaseURL string   `json:"base_url"`
+	Paths   []string `json:"paths"`
+}
+
+// ProbeResult is the outcome of one probe of one path.
+type ProbeResult struct {
+	Service    string    `json:"service"`
+	Path       string    `json:"path"`
+	Up         bool      `json:"up"`
+	StatusCode int       `json:"status_code,omitempty"`
+	LatencyMs  float64   `json:"latency_ms"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// ServiceStatus summarizes the latest probes of a service. Availability is
+// the fraction of its recent probes that succeeded.
+type ServiceStatus struct {
+	Service      string        `json:"service"`
+	Status       ProbeState    `json:"status"`
+	Availability float64       `json:"availability"`
+	Checks       []ProbeResult `json:"checks"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// RetentionRule sets how long a signal's data is kept, for one service
+// and, for logs, one level when those are set. Retention is a duration
+// such as "24h" or "30d"; "0" keeps the data forever.
+//
+// The most specific rule covering an item applies: service and level, then
+// service, then level, then the signal-wide rule or, without one, the
+// configured retention of the signal.
+type RetentionRule struct {
+	Signal    Signal `json:"signal"`
+	Service   string `json:"service,omitempty"`
+	Level     string `json:"level,omitempty"`
+	Retention string `json:"retention"`
+}
+
+// RetentionPolicy is the retention set through PUT /v1/retention on top of
+// the configured retention of each signal.
+type RetentionPolicy struct {
+	Defaults  map[Signal]string `json:"defaults"`
+	Rules     []RetentionRule   `json:"rules"`
+	UpdatedAt *time.Time        `json:"updated_at,omitempty"`
+}
+
+// RetentionSelector matches a signal's items of Service at Level (logs
+// only; compared case-insensitively). Empty fields match anything.
+type RetentionSelector struct {
+	Service string
+	Level   string
+}
+
+// RetentionScope selects the items a retention rule expires: those its
+// selector matches except the ones more specific rules cover.
+type RetentionScope struct {
+	RetentionSelector
+	Except []RetentionSelector
+}
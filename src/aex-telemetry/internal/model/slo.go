@@ -0,0 +1,91 @@
+package model
+
+import "time"
+
+type SLOType string
+
+const (
+	// SLOLatency counts the observations of a histogram metric at or below
+	// the threshold as good.
+	SLOLatency SLOType = "latency"
+	// SLORatio compares the increase of a counter of good events with that
+	// of a counter of all events.
+	SLORatio SLOType = "ratio"
+)
+
+type SLOHealth string
+
+const (
+	SLOHealthy   SLOHealth = "healthy"
+	SLOAtRisk    SLOHealth = "at_risk"
+	SLOExhausted SLOHealth = "exhausted"
+	// SLONoData means no events were seen in the window.
+	SLONoData SLOHealth = "no_data"
+)
+
+// SLO is a service level objective: the fraction of good events a service
+// must reach over a rolling window.
+type SLO struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Service     string  `json:"service"`
+	Type        SLOType `json:"type"`
+	Description string  `json:"description,omitempty"`
+
+	// Metric and Labels select the events: the histogram for latency, the
+	// counter of all events for ratio.
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Threshold is the latency, in the metric's unit, a good event stays
+	// within.
+	Threshold float64 `json:"threshold,omitempty"`
+	// GoodMetric and GoodLabels select the ratio's good events: GoodMetric
+	// (Metric by default) with Labels and GoodLabels.
+	GoodMetric string            `json:"good_metric,omitempty"`
+	GoodLabels map[string]string `json:"good_labels,omitempty"`
+
+	// Objective is the target fraction of good events, such as 0.99.
+	Objective float64 `json:"objective"`
+	// Window is the rolling compliance window, such as "30d" or "24h".
+	Window string `json:"window"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SLOStatus is an SLO's compliance and error budget over its window.
+type SLOStatus struct {
+	SLOID     string    `json:"slo_id"`
+	Name      string    `json:"name"`
+	Service   string    `json:"service"`
+	Window    string    `json:"window"`
+	Objective float64   `json:"objective"`
+	Status    SLOHealth `json:"status"`
+
+	GoodEvents  float64 `json:"good_events"`
+	TotalEvents float64 `json:"total_events"`
+	// SLI is the fraction of good events; 1 without events.
+	SLI float64 `json:"sli"`
+	// ErrorBudget is the fraction of events allowed to be bad,
+	// 1 - Objective. BudgetConsumed is the share of it spent, which exceeds
+	// 1 once the objective is missed; BudgetRemaining is 1 - BudgetConsumed.
+	ErrorBudget     float64 `json:"error_budget"`
+	BudgetConsumed  float64 `json:"budget_consumed"`
+	BudgetRemaining float64 `json:"budget_remaining"`
+
+	// Truncated is set when the window held more points than were read.
+	Truncated   bool      `json:"truncated,omitempty"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// BurnRate is how fast an SLO spends its error budget over a lookback
+// window: the bad-event fraction divided by the error budget. At 1 the
+// budget lasts exactly the SLO window.
+type BurnRate struct {
+	Window      string  `json:"window"`
+	BurnRate    float64 `json:"burn_rate"`
+	ErrorRate   float64 `json:"error_rate"`
+	GoodEvents  float64 `json:"good_events"`
+	TotalEvents float64 `json:"total_events"`
+	Truncated   bool    `json:"truncated,omitempty"`
+}
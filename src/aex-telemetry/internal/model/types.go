@@ -4,25 +4,80 @@ import "time"
 
 // LogEntry represents a log entry from a service
 type LogEntry struct {
-	ID        string         `json:"id"`
-	Timestamp time.Time      `json:"timestamp"`
-	Level     string         `json:"level"`
-	Service   string         `json:"service"`
-	Message   string         `json:"message"`
-	Fields    map[string]any `json:"fields,omitempty"`
-	TraceID   string         `json:"trace_id,omitempty"`
-	SpanID    string         `json:"span_id,omitempty"`
+	ID        string         `json:"id" bson:"_id"`
+	Timestamp time.Time      `json:"timestamp" bson:"timestamp"`
+	Level     string         `json:"level" bson:"level"`
+	Service   string         `json:"service" bson:"service"`
+	Message   string         `json:"message" bson:"message"`
+	Fields    map[string]any `json:"fields,omitempty" bson:"fields,omitempty"`
+	TraceID   string         `json:"trace_id,omitempty" bson:"trace_id,omitempty"`
+	SpanID    string         `json:"span_id,omitempty" bson:"span_id,omitempty"`
 }
 
 // MetricEntry represents a metric data point
 type MetricEntry struct {
-	ID        string            `json:"id"`
-	Timestamp time.Time         `json:"timestamp"`
-	Name      string            `json:"name"`
-	Type      MetricType        `json:"type"`
-	Value     float64           `json:"value"`
-	Service   string            `json:"service"`
-	Labels    map[string]string `json:"labels,omitempty"`
+	ID        string            `json:"id" bson:"_id"`
+	Timestamp time.Time         `json:"timestamp" bson:"timestamp"`
+	Name      string            `json:"name" bson:"name"`
+	Type      MetricType        `json:"type" bson:"type"`
+	Value     float64           `json:"value" bson:"value"`
+	Service   string            `json:"service" bson:"service"`
+	Labels    map[string]string `json:"labels,omitempty" bson:"labels,omitempty"`
+	// Histogram is set on histogram points reported already aggregated,
+	// as OTLP histograms are; Value then holds their sum.
+	Histogram *HistogramData `json:"histogram,omitempty" bson:"histogram,omitempty"`
+	// Rollup is set on points downsampled from finer ones. Timestamp is
+	// then the start of the rollup interval, Value and Histogram are the
+	// latest reported ones in the interval.
+	Rollup *RollupData `json:"rollup,omitempty" bson:"rollup,omitempty"`
+}
+
+// RollupData summarizes the raw points of one series over an interval.
+type RollupData struct {
+	Resolution Resolution `json:"resolution" bson:"resolution"`
+	Count      uint64     `json:"count" bson:"count"`
+	Sum        float64    `json:"sum" bson:"sum"`
+	Min        float64    `json:"min" bson:"min"`
+	Max        float64    `json:"max" bson:"max"`
+	// Observations is the distribution of histogram points reported as
+	// single observations, bucketed as on /metrics.
+	Observations *HistogramData `json:"observations,omitempty" bson:"observations,omitempty"`
+}
+
+// Resolution is the interval of a metric rollup tier.
+type Resolution string
+
+const (
+	ResolutionRaw Resolution = "raw"
+	Resolution1m  Resolution = "1m"
+	Resolution5m  Resolution = "5m"
+	Resolution1h  Resolution = "1h"
+)
+
+// RollupResolutions are the rollup tiers, each built from the previous one
+// (the first from raw points).
+var RollupResolutions = []Resolution{Resolution1m, Resolution5m, Resolution1h}
+
+// Duration is the resolution's interval; 0 for raw points.
+func (r Resolution) Duration() time.Duration {
+	switch r {
+	case Resolution1m:
+		return time.Minute
+	case Resolution5m:
+		return 5 * time.Minute
+	case Resolution1h:
+		return time.Hour
+	}
+	return 0
+}
+
+// HistogramData is a cumulative distribution. BucketCounts has one more
+// entry than ExplicitBounds, for observations above the last bound.
+type HistogramData struct {
+	Count          uint64    `json:"count" bson:"count"`
+	Sum            float64   `json:"sum" bson:"sum"`
+	ExplicitBounds []float64 `json:"explicit_bounds" bson:"explicit_bounds"`
+	BucketCounts   []uint64  `json:"bucket_counts" bson:"bucket_counts"`
 }
 
 type MetricType string
@@ -35,16 +90,16 @@ const (
 
 // TraceSpan represents a span in a distributed trace
 type TraceSpan struct {
-	TraceID      string            `json:"trace_id"`
-	SpanID       string            `json:"span_id"`
-	ParentSpanID string            `json:"parent_span_id,omitempty"`
-	Service      string            `json:"service"`
-	Operation    string            `json:"operation"`
-	StartTime    time.Time         `json:"start_time"`
-	EndTime      time.Time         `json:"end_time"`
-	DurationMs   int64             `json:"duration_ms"`
-	Status       string            `json:"status"`
-	Attributes   map[string]string `json:"attributes,omitempty"`
+	TraceID      string            `json:"trace_id" bson:"trace_id"`
+	SpanID       string            `json:"span_id" bson:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty" bson:"parent_span_id,omitempty"`
+	Service      string            `json:"service" bson:"service"`
+	Operation    string            `json:"operation" bson:"operation"`
+	StartTime    time.Time         `json:"start_time" bson:"start_time"`
+	EndTime      time.Time         `json:"end_time" bson:"end_time"`
+	DurationMs   int64             `json:"duration_ms" bson:"duration_ms"`
+	Status       string            `json:"status" bson:"status"`
+	Attributes   map[string]string `json:"attributes,omitempty" bson:"attributes,omitempty"`
 }
 
 // LogQuery represents parameters for querying logs
@@ -54,14 +109,61 @@ type LogQuery struct {
 	StartTime time.Time `json:"start_time,omitempty"`
 	EndTime   time.Time `json:"end_time,omitempty"`
 	Search    string    `json:"search,omitempty"`
-	Limit     int       `json:"limit,omitempty"`
+	// Text holds full-text search terms, all of which must appear as words
+	// of the message.
+	Text string `json:"text,omitempty"`
+	// Fields filters on LogEntry.Fields values, compared as text.
+	Fields map[string]string `json:"fields,omitempty"`
+	Limit  int               `json:"limit,omitempty"`
+}
+
+// LogFacets counts the logs matching a query by level and by service.
+type LogFacets struct {
+	Level   map[string]int `json:"level"`
+	Service map[string]int `json:"service"`
 }
 
-// MetricQuery represents parameters for querying metrics
+// MetricQuery represents parameters for querying metrics. Labels must all
+// match the point's labels.
 type MetricQuery struct {
-	Name      string    `json:"name,omitempty"`
-	Service   string    `json:"service,omitempty"`
-	StartTime time.Time `json:"start_time,omitempty"`
-	EndTime   time.Time `json:"end_time,omitempty"`
-	Limit     int       `json:"limit,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Service   string            `json:"service,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	StartTime time.Time         `json:"start_time,omitempty"`
+	EndTime   time.Time         `json:"end_time,omitempty"`
+	Limit     int               `json:"limit,omitempty"`
+}
+
+// SpanQuery represents parameters for querying spans across traces. Status
+// matches case-insensitively; Attributes must all match the span's.
+type SpanQuery struct {
+	Service       string            `json:"service,omitempty"`
+	Operation     string            `json:"operation,omitempty"`
+	Status        string            `json:"status,omitempty"`
+	MinDurationMs int64             `json:"min_duration_ms,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	StartTime     time.Time         `json:"start_time,omitempty"`
+	EndTime       time.Time         `json:"end_time,omitempty"`
+	Limit         int               `json:"limit,omitempty"`
 }
+
+// TraceSummary describes a trace found by a search.
+type TraceSummary struct {
+	TraceID       string    `json:"trace_id"`
+	RootService   string    `json:"root_service,omitempty"`
+	RootOperation string    `json:"root_operation,omitempty"`
+	StartTime     time.Time `json:"start_time"`
+	DurationMs    int64     `json:"duration_ms"`
+	SpanCount     int       `json:"span_count"`
+	ErrorCount    int       `json:"error_count"`
+	Services      []string  `json:"services"`
+}
+
+// Signal is a kind of telemetry data, each stored and retained separately.
+type Signal string
+
+const (
+	SignalLogs    Signal = "logs"
+	SignalMetrics Signal = "metrics"
+	SignalSpans   Signal = "spans"
+)
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	// defaultAggregateRange is the range aggregated when from is omitted.
+	defaultAggregateRange = time.Hour
+	// maxAggregatePoints caps the data points read for one aggregation.
+	maxAggregatePoints = 100000
+	// maxAggregateSteps caps the points returned per series.
+	maxAggregateSteps = 11000
+)
+
+// quantiles are the percentile aggregations, computed over histogram buckets.
+var quantiles = map[string]float64{"p50": 0.5, "p95": 0.95, "p99": 0.99}
+
+// AggregatePoint is the aggregated value of the step starting at Timestamp.
+type AggregatePoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// AggregateSeries is one group of the by labels.
+type AggregateSeries struct {
+	Labels map[string]string `json:"labels"`
+	Points []AggregatePoint  `json:"points"`
+}
+
+// aggStep accumulates the data points of one group that fall in one step.
+type aggStep struct {
+	n        int
+	sum      float64
+	min, max float64
+	// increase is the counter increase seen in the step, for rate.
+	increase float64
+	// bounds and buckets are the merged distribution, for percentiles.
+	bounds  []float64
+	buckets []uint64
+}
+
+func (s *aggStep) add(v float64) {
+	if s.n == 0 || v < s.min {
+		s.min = v
+	}
+	if s.n == 0 || v > s.max {
+		s.max = v
+	}
+	s.n++
+	s.sum += v
+}
+
+// addRollup adds the points summarized by a rollup.
+func (s *aggStep) addRollup(r *model.RollupData) {
+	if r.Count == 0 {
+		return
+	}
+	if s.n == 0 || r.Min < s.min {
+		s.min = r.Min
+	}
+	if s.n == 0 || r.Max > s.max {
+		s.max = r.Max
+	}
+	s.n += int(r.Count)
+	s.sum += r.Sum
+}
+
+// observations is how many single observations p stands for.
+func observations(p model.MetricEntry) uint64 {
+	if p.Rollup != nil {
+		return p.Rollup.Count
+	}
+	return 1
+}
+
+// addBuckets merges a distribution into the step. Distributions with other
+// bounds than the first one merged cannot be combined and are skipped.
+func (s *aggStep) addBuckets(bounds []float64, counts []uint64) {
+	if s.buckets == nil {
+		s.bounds = bounds
+		s.buckets = make([]uint64, len(counts))
+	} else if !slices.Equal(s.bounds, bounds) || len(counts) != len(s.buckets) {
+		return
+	}
+	for i, c := range counts {
+		s.buckets[i] += c
+	}
+	s.n++
+}
+
+type aggGroup struct {
+	labels map[string]string
+	steps  map[int]*aggStep
+}
+
+// HandleAggregateMetrics handles GET /v1/metrics/aggregate
+//
+// It aggregates the points of the named metric over [from, to] in steps of
+// step (the whole range by default), grouped by the comma-separated by
+// labels ("service" groups by reporting service). Points are read from the
+// resolution tier given, or by default from the one suited to from and
+// step when rollups are enabled. agg is one of:
+//
+//   - sum, avg, min, max, count: over the point values in each step.
+//   - rate: per-second increase of a counter (or of a histogram's
+//     observation count), handling counter resets.
+//   - p50, p95, p99: estimated from the histogram buckets observed in each
+//     step, interpolating within a bucket as Prometheus does.
+//
+// Increases are taken between consecutive points of a series inside the
+// range, so the first point of each series only serves as a baseline.
+func (svc *Service) HandleAggregateMetrics(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := svc.callerTenant(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	name := q.Get("name")
+	if name == "" {
+		respondError(w, http.StatusBadRequest, "name required")
+		return
+	}
+	agg := q.Get("agg")
+	if !validAggregation(agg) {
+		respondError(w, http.StatusBadRequest, "agg must be one of sum, avg, min, max, count, rate, p50, p95, p99")
+		return
+	}
+	from, to, err := parseTimeRange(q)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultAggregateRange)
+	}
+	if !from.Before(to) {
+		respondError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	step := to.Sub(from)
+	if s := q.Get("step"); s != "" {
+		if step, err = time.ParseDuration(s); err != nil || step <= 0 {
+			respondError(w, http.StatusBadRequest, "invalid step")
+			return
+		}
+	}
+	nSteps := int((to.Sub(from) + step - 1) / step)
+	if nSteps > maxAggregateSteps {
+		respondError(w, http.StatusBadRequest, "step too small for the range")
+		return
+	}
+	var by []string
+	if s := q.Get("by"); s != "" {
+		by = strings.Split(s, ",")
+	}
+	res, ok := svc.parseResolution(q.Get("resolution"), "auto", from, step)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "resolution must be one of raw, 1m, 5m, 1h, auto")
+		return
+	}
+
+	points, err := svc.queryResolution(r.Context(), res, model.MetricQuery{
+		Name:      name,
+		Service:   q.Get("service"),
+		Labels:    tenantFilter(tenant),
+		StartTime: from,
+		EndTime:   to,
+		Limit:     maxAggregatePoints,
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	series := aggregatePoints(points, agg, from, step, nSteps, by)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"name":       name,
+		"agg":        agg,
+		"from":       from,
+		"to":         to,
+		"step":       step.String(),
+		"resolution": res,
+		"series":     series,
+		"truncated":  len(points) >= maxAggregatePoints,
+	})
+}
+
+// aggregatePoints computes agg over points for nSteps steps of step from
+// from, grouped by the by labels. Series and points without data are left
+// out.
+func aggregatePoints(points []model.MetricEntry, agg string, from time.Time, step time.Duration, nSteps int, by []string) []AggregateSeries {
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+
+	groups := map[string]*aggGroup{}
+	prev := map[string]model.MetricEntry{}
+	for _, p := range points {
+		labels := groupLabels(p, by)
+		gk := seriesKey("", labels)
+		g := groups[gk]
+		if g == nil {
+			g = &aggGroup{labels: labels, steps: map[int]*aggStep{}}
+			groups[gk] = g
+		}
+		i := min(int(p.Timestamp.Sub(from)/step), nSteps-1)
+		s := g.steps[i]
+		if s == nil {
+			s = &aggStep{}
+			g.steps[i] = s
+		}
+
+		switch {
+		case agg == "rate":
+			if p.Type == model.MetricTypeHistogram && p.Histogram == nil {
+				// Each single observation increases the count by one.
+				s.increase += float64(observations(p))
+				s.n++
+				continue
+			}
+			if p.Type != model.MetricTypeCounter && p.Type != model.MetricTypeHistogram {
+				continue
+			}
+			sk := seriesKey(p.Service, p.Labels)
+			last, ok := prev[sk]
+			prev[sk] = p
+			if ok {
+				s.increase += counterIncrease(last, p)
+				s.n++
+			}
+		case quantiles[agg] > 0:
+			if p.Type != model.MetricTypeHistogram {
+				continue
+			}
+			if p.Rollup != nil && p.Rollup.Observations != nil {
+				s.addBuckets(p.Rollup.Observations.ExplicitBounds, p.Rollup.Observations.BucketCounts)
+				continue
+			}
+			if p.Histogram == nil {
+				counts := make([]uint64, len(defaultBuckets)+1)
+				counts[sort.SearchFloat64s(defaultBuckets, p.Value)] = 1
+				s.addBuckets(defaultBuckets, counts)
+				continue
+			}
+			sk := seriesKey(p.Service, p.Labels)
+			last, ok := prev[sk]
+			prev[sk] = p
+			if ok {
+				s.addBuckets(p.Histogram.ExplicitBounds, bucketIncrease(last.Histogram, p.Histogram))
+			}
+		case p.Rollup != nil:
+			s.addRollup(p.Rollup)
+		default:
+			s.add(p.Value)
+		}
+	}
+
+	series := make([]AggregateSeries, 0, len(groups))
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g := groups[k]
+		idx := make([]int, 0, len(g.steps))
+		for i := range g.steps {
+			idx = append(idx, i)
+		}
+		sort.Ints(idx)
+		out := AggregateSeries{Labels: g.labels, Points: []AggregatePoint{}}
+		for _, i := range idx {
+			v, ok := g.steps[i].value(agg, step)
+			if !ok {
+				continue
+			}
+			out.Points = append(out.Points, AggregatePoint{Timestamp: from.Add(time.Duration(i) * step), Value: v})
+		}
+		if len(out.Points) > 0 {
+			series = append(series, out)
+		}
+	}
+	return series
+}
+
+func validAggregation(agg string) bool {
+	switch agg {
+	case "sum", "avg", "min", "max", "count", "rate", "p50", "p95", "p99":
+		return true
+	}
+	return false
+}
+
+// value is the step's aggregate; ok is false when the step had no usable
+// points for agg.
+func (s *aggStep) value(agg string, step time.Duration) (float64, bool) {
+	if s.n == 0 {
+		return 0, false
+	}
+	switch agg {
+	case "sum":
+		return s.sum, true
+	case "avg":
+		return s.sum / float64(s.n), true
+	case "min":
+		return s.min, true
+	case "max":
+		return s.max, true
+	case "count":
+		return float64(s.n), true
+	case "rate":
+		return s.increase / step.Seconds(), true
+	}
+	v := histogramQuantile(quantiles[agg], s.bounds, s.buckets)
+	return v, !math.IsNaN(v)
+}
+
+// groupLabels picks the by labels of p; "service" is the reporting service.
+func groupLabels(p model.MetricEntry, by []string) map[string]string {
+	labels := make(map[string]string, len(by))
+	for _, k := range by {
+		if k == "service" {
+			labels[k] = p.Service
+			continue
+		}
+		labels[k] = p.Labels[k]
+	}
+	return labels
+}
+
+// counterIncrease is how much a cumulative series grew from last to cur. A
+// drop means the counter was reset, so all of cur counts as increase.
+func counterIncrease(last, cur model.MetricEntry) float64 {
+	lv, cv := last.Value, cur.Value
+	if cur.Histogram != nil {
+		if last.Histogram == nil {
+			return 0
+		}
+		lv, cv = float64(last.Histogram.Count), float64(cur.Histogram.Count)
+	}
+	if cv < lv {
+		return cv
+	}
+	return cv - lv
+}
+
+// bucketIncrease is the per-bucket growth of a cumulative histogram from
+// last to cur, or cur itself when it was reset or rebucketed.
+func bucketIncrease(last, cur *model.HistogramData) []uint64 {
+	out := make([]uint64, len(cur.BucketCounts))
+	copy(out, cur.BucketCounts)
+	if last == nil || cur.Count < last.Count || !slices.Equal(last.ExplicitBounds, cur.ExplicitBounds) ||
+		len(last.BucketCounts) != len(cur.BucketCounts) {
+		return out
+	}
+	for i, c := range last.BucketCounts {
+		if out[i] < c {
+			copy(out, cur.BucketCounts)
+			return out
+		}
+		out[i] -= c
+	}
+	return out
+}
+
+// histogramQuantile estimates the q-quantile of a distribution by linear
+// interpolation within the bucket holding it. Observations in the overflow
+// bucket are reported at the highest bound. NaN means no observations.
+func histogramQuantile(q float64, bounds []float64, counts []uint64) float64 {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+	rank := q * float64(total)
+	var cum uint64
+	for i, c := range counts {
+		if c == 0 || float64(cum+c) < rank {
+			cum += c
+			continue
+		}
+		if i >= len(bounds) {
+			if len(bounds) == 0 {
+				return math.NaN()
+			}
+			return bounds[len(bounds)-1]
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = bounds[i-1]
+		} else if bounds[0] <= 0 {
+			return bounds[0]
+		}
+		return lower + (bounds[i]-lower)*(rank-float64(cum))/float64(c)
+	}
+	return math.NaN()
+}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	// maxAlertEvents is how many state transitions are kept for
+	// GET /v1/alerts/events.
+	maxAlertEvents = 1000
+	// maxAlertLogs caps the logs scanned for one log_pattern evaluation.
+	maxAlertLogs = 10000
+	// webhookAttempts is how many times a notification is posted before
+	// giving up, doubling webhookBackoff in between.
+	webhookAttempts = 3
+	webhookBackoff  = time.Second
+)
+
+// AlertSignatureHeader carries the hex HMAC-SHA256 of a webhook body,
+// prefixed with "sha256=", when a webhook secret is configured.
+const AlertSignatureHeader = "X-AEX-Signature"
+
+var errAlertRuleNotFound = errors.New("alert rule not found")
+
+// alertManager holds the alert rules and their recent state transitions.
+type alertManager struct {
+	mu     sync.Mutex
+	rules  map[string]*model.AlertRule
+	events []model.AlertEvent
+
+	secret []byte
+	http   *http.Client
+}
+
+func newAlertManager() *alertManager {
+	return &alertManager{
+		rules: map[string]*model.AlertRule{},
+		http:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SetAlertWebhookSecret sets the key alert webhooks are signed with.
+func (svc *Service) SetAlertWebhookSecret(secret string) {
+	svc.alerts.mu.Lock()
+	svc.alerts.secret = []byte(secret)
+	svc.alerts.mu.Unlock()
+}
+
+// HandleCreateAlertRule handles POST /v1/alerts/rules
+func (svc *Service) HandleCreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	var rule model.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validateAlertRule(&rule); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	rule.ID = newID()
+	rule.State = model.AlertInactive
+	rule.Value = 0
+	rule.ActiveSince = nil
+	rule.LastEvaluatedAt = nil
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	svc.alerts.mu.Lock()
+	svc.alerts.rules[rule.ID] = &rule
+	svc.alerts.mu.Unlock()
+
+	respondJSON(w, http.StatusCreated, rule)
+}
+
+// HandleListAlertRules handles GET /v1/alerts/rules
+func (svc *Service) HandleListAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules := svc.alerts.snapshot()
+	respondJSON(w, http.StatusOK, map[string]any{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// HandleGetAlertRule handles GET /v1/alerts/rules/{id}
+func (svc *Service) HandleGetAlertRule(w http.ResponseWriter, r *http.Request) {
+	svc.alerts.mu.Lock()
+	rule, ok := svc.alerts.rules[r.PathValue("id")]
+	var out model.AlertRule
+	if ok {
+		out = *rule
+	}
+	svc.alerts.mu.Unlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errAlertRuleNotFound.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+// HandleUpdateAlertRule handles PUT /v1/alerts/rules/{id}
+//
+// The rule's definition is replaced; its state carries over and the next
+// evaluation moves it according to the new definition.
+func (svc *Service) HandleUpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	var rule model.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validateAlertRule(&rule); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	svc.alerts.mu.Lock()
+	existing, ok := svc.alerts.rules[r.PathValue("id")]
+	if ok {
+		rule.ID = existing.ID
+		rule.State = existing.State
+		rule.Value = existing.Value
+		rule.ActiveSince = existing.ActiveSince
+		rule.LastEvaluatedAt = existing.LastEvaluatedAt
+		rule.CreatedAt = existing.CreatedAt
+		rule.UpdatedAt = time.Now().UTC()
+		*existing = rule
+	}
+	svc.alerts.mu.Unlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errAlertRuleNotFound.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, rule)
+}
+
+// HandleDeleteAlertRule handles DELETE /v1/alerts/rules/{id}
+func (svc *Service) HandleDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	svc.alerts.mu.Lock()
+	_, ok := svc.alerts.rules[id]
+	delete(svc.alerts.rules, id)
+	svc.alerts.mu.Unlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errAlertRuleNotFound.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListAlertEvents handles GET /v1/alerts/events
+func (svc *Service) HandleListAlertEvents(w http.ResponseWriter, r *http.Request) {
+	ruleID := r.URL.Query().Get("rule_id")
+	svc.alerts.mu.Lock()
+	events := make([]model.AlertEvent, 0, len(svc.alerts.events))
+	for i := len(svc.alerts.events) - 1; i >= 0; i-- {
+		if ev := svc.alerts.events[i]; ruleID == "" || ev.RuleID == ruleID {
+			events = append(events, ev)
+		}
+	}
+	svc.alerts.mu.Unlock()
+	respondJSON(w, http.StatusOK, map[string]any{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+// RunAlerting evaluates the alert rules every interval until ctx is done.
+func (svc *Service) RunAlerting(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			svc.EvaluateAlerts(ctx, time.Now().UTC())
+		}
+	}
+}
+
+// EvaluateAlerts evaluates every rule as of now, moving it between states
+// and notifying its webhook of each transition.
+func (svc *Service) EvaluateAlerts(ctx context.Context, now time.Time) {
+	for _, rule := range svc.alerts.snapshot() {
+		value, ok, err := svc.evaluateRule(ctx, rule, now)
+		if err != nil {
+			log.Printf("alert %s: evaluation failed: %v", rule.ID, err)
+			continue
+		}
+		holds := ok && compare(value, rule.Comparator, rule.Threshold)
+		svc.alerts.transition(rule, value, holds, now)
+	}
+}
+
+// evaluateRule returns the rule's current value; ok is false when a metric
+// had no points in the window.
+func (svc *Service) evaluateRule(ctx context.Context, rule model.AlertRule, now time.Time) (float64, bool, error) {
+	window, _ := time.ParseDuration(rule.Window)
+	from := now.Add(-window)
+
+	if rule.Type == model.AlertRuleLogPattern {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return 0, false, err
+		}
+		logs, err := svc.store.QueryLogs(ctx, model.LogQuery{
+			Service:   rule.Service,
+			Level:     rule.Level,
+			StartTime: from,
+			EndTime:   now,
+			Limit:     maxAlertLogs,
+		})
+		if err != nil {
+			return 0, false, err
+		}
+		n := 0
+		for _, l := range logs {
+			if pattern.MatchString(l.Message) {
+				n++
+			}
+		}
+		return float64(n), true, nil
+	}
+
+	points, err := svc.store.QueryMetrics(ctx, model.MetricQuery{
+		Name:      rule.Metric,
+		Service:   rule.Service,
+		StartTime: from,
+		EndTime:   now,
+		Limit:     maxAggregatePoints,
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	matched := points[:0]
+	for _, p := range points {
+		if hasLabels(p.Labels, rule.Labels) {
+			matched = append(matched, p)
+		}
+	}
+	series := aggregatePoints(matched, rule.Aggregation, from, window, 1, nil)
+	if len(series) == 0 {
+		return 0, false, nil
+	}
+	return series[0].Points[0].Value, true, nil
+}
+
+// snapshot copies the rules, oldest first.
+func (m *alertManager) snapshot() []model.AlertRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rules := make([]model.AlertRule, 0, len(m.rules))
+	for _, r := range m.rules {
+		rules = append(rules, *r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// transition applies an evaluation of evaluated to the stored rule, unless
+// the rule was deleted or redefined meanwhile.
+func (m *alertManager) transition(evaluated model.AlertRule, value float64, holds bool, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rule, ok := m.rules[evaluated.ID]
+	if !ok || !rule.UpdatedAt.Equal(evaluated.UpdatedAt) {
+		return
+	}
+	rule.Value = value
+	rule.LastEvaluatedAt = &now
+
+	from := rule.State
+	forDur, _ := time.ParseDuration(rule.For)
+	switch {
+	case holds && (from == model.AlertInactive || from == model.AlertResolved):
+		rule.ActiveSince = &now
+		rule.State = model.AlertPending
+		if forDur <= 0 {
+			rule.State = model.AlertFiring
+		}
+	case holds && from == model.AlertPending:
+		if now.Sub(*rule.ActiveSince) >= forDur {
+			rule.State = model.AlertFiring
+		}
+	case !holds && from == model.AlertPending:
+		rule.State = model.AlertInactive
+		rule.ActiveSince = nil
+	case !holds && from == model.AlertFiring:
+		rule.State = model.AlertResolved
+		rule.ActiveSince = nil
+	}
+	if rule.State == from {
+		return
+	}
+
+	ev := model.AlertEvent{
+		EventID:   newID(),
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		From:      from,
+		State:     rule.State,
+		Value:     value,
+		Threshold: rule.Threshold,
+		Timestamp: now,
+	}
+	m.events = append(m.events, ev)
+	if len(m.events) > maxAlertEvents {
+		m.events = m.events[len(m.events)-maxAlertEvents:]
+	}
+	log.Printf("alert %s (%s): %s -> %s value=%g threshold=%g", rule.ID, rule.Name, from, rule.State, value, rule.Threshold)
+	if rule.WebhookURL != "" {
+		go m.notify(rule.WebhookURL, ev, m.secret)
+	}
+}
+
+// notify posts ev to url, retrying with backoff.
+func (m *alertManager) notify(url string, ev model.AlertEvent, secret []byte) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("alert webhook marshal failed event_id=%s: %v", ev.EventID, err)
+		return
+	}
+	wait := webhookBackoff
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if err = m.post(url, body, secret); err == nil {
+			return
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	log.Printf("alert webhook delivery failed event_id=%s url=%s: %v", ev.EventID, url, err)
+}
+
+func (m *alertManager) post(url string, body, secret []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		req.Header.Set(AlertSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// validateAlertRule checks rule and fills in the default aggregation (avg)
+// and comparator (gt).
+func validateAlertRule(rule *model.AlertRule) error {
+	if rule.Name == "" {
+		return errors.New("name required")
+	}
+	switch rule.Type {
+	case model.AlertRuleMetricThreshold:
+		if rule.Metric == "" {
+			return errors.New("metric required")
+		}
+		if rule.Aggregation == "" {
+			rule.Aggregation = "avg"
+		}
+		if !validAggregation(rule.Aggregation) {
+			return errors.New("aggregation must be one of sum, avg, min, max, count, rate, p50, p95, p99")
+		}
+	case model.AlertRuleLogPattern:
+		if rule.Pattern == "" {
+			return errors.New("pattern required")
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return errors.New("invalid pattern: " + err.Error())
+		}
+	default:
+		return errors.New("type must be metric_threshold or log_pattern")
+	}
+	if rule.Comparator == "" {
+		rule.Comparator = "gt"
+	}
+	switch rule.Comparator {
+	case "gt", "gte", "lt", "lte":
+	default:
+		return errors.New("comparator must be one of gt, gte, lt, lte")
+	}
+	if d, err := time.ParseDuration(rule.Window); err != nil || d <= 0 {
+		return errors.New("window must be a positive duration")
+	}
+	if rule.For != "" {
+		if d, err := time.ParseDuration(rule.For); err != nil || d < 0 {
+			return errors.New("for must be a non-negative duration")
+		}
+	}
+	if rule.WebhookURL != "" {
+		u, err := url.Parse(rule.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("webhook_url must be an absolute http(s) URL")
+		}
+	}
+	return nil
+}
+
+func compare(value float64, comparator string, threshold float64) bool {
+	switch comparator {
+	case "gte":
+		return value >= threshold
+	case "lt":
+		return value < threshold
+	case "lte":
+		return value <= threshold
+	}
+	return value > threshold
+}
+
+// hasLabels reports whether labels include every want label.
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
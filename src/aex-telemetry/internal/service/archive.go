@@ -0,0 +1,299 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/archive"
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	// exportWindow is the span of data read per store query; windows
+	// holding more than exportPageLimit records are split further.
+	exportWindow    = time.Hour
+	exportPageLimit = 50000
+	exportTimeout   = 2 * time.Hour
+)
+
+// exportManager runs export jobs and keeps their status.
+type exportManager struct {
+	mu     sync.Mutex
+	jobs   map[string]*model.ExportJob
+	store  archive.ObjectStore
+	prefix string
+}
+
+func newExportManager() *exportManager {
+	return &exportManager{jobs: map[string]*model.ExportJob{}}
+}
+
+// SetExportStore sets where exports are written, under prefix. Without one,
+// exports are refused.
+func (svc *Service) SetExportStore(store archive.ObjectStore, prefix string) {
+	svc.exports.mu.Lock()
+	svc.exports.store = store
+	svc.exports.prefix = prefix
+	svc.exports.mu.Unlock()
+}
+
+// HandleCreateExport handles POST /v1/export
+func (svc *Service) HandleCreateExport(w http.ResponseWriter, r *http.Request) {
+	var req model.ExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validateExport(&req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m := svc.exports
+	m.mu.Lock()
+	store, prefix := m.store, m.prefix
+	if store == nil {
+		m.mu.Unlock()
+		respondError(w, http.StatusServiceUnavailable, "export storage not configured")
+		return
+	}
+	job := &model.ExportJob{
+		ID:        newID(),
+		Status:    model.ExportPending,
+		Request:   req,
+		Objects:   []model.ExportObject{},
+		CreatedAt: time.Now().UTC(),
+	}
+	m.jobs[job.ID] = job
+	out := *job
+	m.mu.Unlock()
+
+	go svc.runExport(job, store, prefix)
+	respondJSON(w, http.StatusAccepted, out)
+}
+
+// HandleGetExport handles GET /v1/export/{id}
+func (svc *Service) HandleGetExport(w http.ResponseWriter, r *http.Request) {
+	m := svc.exports
+	m.mu.Lock()
+	job, ok := m.jobs[r.PathValue("id")]
+	var out model.ExportJob
+	if ok {
+		out = copyJob(job)
+	}
+	m.mu.Unlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, "export job not found")
+		return
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+// HandleListExports handles GET /v1/export
+func (svc *Service) HandleListExports(w http.ResponseWriter, r *http.Request) {
+	m := svc.exports
+	m.mu.Lock()
+	jobs := make([]model.ExportJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, copyJob(job))
+	}
+	m.mu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	respondJSON(w, http.StatusOK, map[string]any{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
+
+func copyJob(job *model.ExportJob) model.ExportJob {
+	out := *job
+	out.Objects = append([]model.ExportObject{}, job.Objects...)
+	return out
+}
+
+func validateExport(req *model.ExportRequest) error {
+	if req.From.IsZero() || req.To.IsZero() || !req.From.Before(req.To) {
+		return errors.New("from and to are required and from must be before to")
+	}
+	if len(req.Signals) == 0 {
+		req.Signals = []model.Signal{model.SignalLogs, model.SignalMetrics, model.SignalSpans}
+	}
+	for _, s := range req.Signals {
+		if s != model.SignalLogs && s != model.SignalMetrics && s != model.SignalSpans {
+			return fmt.Errorf("unknown signal %q", s)
+		}
+	}
+	switch req.Format {
+	case "", "ndjson":
+		req.Format = "ndjson"
+	case "parquet":
+		return errors.New("parquet export is not supported yet; use ndjson")
+	default:
+		return errors.New("format must be ndjson")
+	}
+	switch req.Compression {
+	case "", "gzip":
+		req.Compression = "gzip"
+	case "none":
+	default:
+		return errors.New("compression must be gzip or none")
+	}
+	return nil
+}
+
+// runExport writes one object per requested signal and records the outcome
+// on job.
+func (svc *Service) runExport(job *model.ExportJob, store archive.ObjectStore, prefix string) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	m := svc.exports
+	started := time.Now().UTC()
+	m.mu.Lock()
+	job.Status = model.ExportRunning
+	job.StartedAt = &started
+	req := job.Request
+	m.mu.Unlock()
+
+	var err error
+	for _, signal := range req.Signals {
+		var obj model.ExportObject
+		if obj, err = svc.exportSignal(ctx, job.ID, signal, req, store, prefix); err != nil {
+			err = fmt.Errorf("%s: %w", signal, err)
+			break
+		}
+		m.mu.Lock()
+		job.Objects = append(job.Objects, obj)
+		m.mu.Unlock()
+	}
+
+	done := time.Now().UTC()
+	m.mu.Lock()
+	job.CompletedAt = &done
+	job.Status = model.ExportCompleted
+	if err != nil {
+		job.Status = model.ExportFailed
+		job.Error = err.Error()
+	}
+	m.mu.Unlock()
+	if err != nil {
+		log.Printf("export %s failed: %v", job.ID, err)
+		return
+	}
+	log.Printf("export %s completed objects=%d", job.ID, len(req.Signals))
+}
+
+// exportSignal writes the signal's records in the request's range, oldest
+// first, to a temporary file and uploads it.
+func (svc *Service) exportSignal(ctx context.Context, jobID string, signal model.Signal, req model.ExportRequest, store archive.ObjectStore, prefix string) (model.ExportObject, error) {
+	f, err := os.CreateTemp("", "aex-export-*")
+	if err != nil {
+		return model.ExportObject{}, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	var out io.Writer = f
+	var zw *gzip.Writer
+	if req.Compression == "gzip" {
+		zw = gzip.NewWriter(f)
+		out = zw
+	}
+	enc := json.NewEncoder(out)
+	write := func(v any) error { return enc.Encode(v) }
+
+	var records int
+	switch signal {
+	case model.SignalLogs:
+		records, err = exportRange(req.From, req.To, func(from, to time.Time) ([]model.LogEntry, error) {
+			return svc.store.QueryLogs(ctx, model.LogQuery{StartTime: from, EndTime: to, Limit: exportPageLimit})
+		}, func(e model.LogEntry) time.Time { return e.Timestamp }, write)
+	case model.SignalMetrics:
+		records, err = exportRange(req.From, req.To, func(from, to time.Time) ([]model.MetricEntry, error) {
+			return svc.store.QueryMetrics(ctx, model.MetricQuery{StartTime: from, EndTime: to, Limit: exportPageLimit})
+		}, func(e model.MetricEntry) time.Time { return e.Timestamp }, write)
+	case model.SignalSpans:
+		records, err = exportRange(req.From, req.To, func(from, to time.Time) ([]model.TraceSpan, error) {
+			return svc.store.QuerySpans(ctx, model.SpanQuery{StartTime: from, EndTime: to, Limit: exportPageLimit})
+		}, func(s model.TraceSpan) time.Time { return s.StartTime }, write)
+	}
+	if err != nil {
+		return model.ExportObject{}, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return model.ExportObject{}, err
+		}
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return model.ExportObject{}, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return model.ExportObject{}, err
+	}
+
+	name := fmt.Sprintf("%s-%s-%s.ndjson", req.From.UTC().Format("20060102T150405Z"), req.To.UTC().Format("20060102T150405Z"), jobID)
+	if zw != nil {
+		name += ".gz"
+	}
+	key := path.Join(prefix, string(signal), name)
+	if err := store.Put(ctx, key, f, size, "application/x-ndjson"); err != nil {
+		return model.ExportObject{}, err
+	}
+	return model.ExportObject{Signal: signal, Location: store.Location(key), Records: records, Bytes: size}, nil
+}
+
+// exportRange writes the records timestamped in [from, to) in time order.
+// fetch returns the records in an inclusive range, newest first, and is
+// called on exportWindow windows, split while a window is full.
+func exportRange[T any](from, to time.Time, fetch func(from, to time.Time) ([]T, error), ts func(T) time.Time, write func(any) error) (int, error) {
+	total := 0
+	var window func(a, b time.Time) error
+	window = func(a, b time.Time) error {
+		items, err := fetch(a, b.Add(-time.Nanosecond))
+		if err != nil {
+			return err
+		}
+		if len(items) >= exportPageLimit {
+			if b.Sub(a) <= time.Second {
+				return fmt.Errorf("more than %d records within %s", exportPageLimit, a.Format(time.RFC3339Nano))
+			}
+			mid := a.Add(b.Sub(a) / 2)
+			if err := window(a, mid); err != nil {
+				return err
+			}
+			return window(mid, b)
+		}
+		sort.SliceStable(items, func(i, j int) bool { return ts(items[i]).Before(ts(items[j])) })
+		for _, it := range items {
+			if err := write(it); err != nil {
+				return err
+			}
+		}
+		total += len(items)
+		return nil
+	}
+	for a := from; a.Before(to); a = a.Add(exportWindow) {
+		b := a.Add(exportWindow)
+		if b.After(to) {
+			b = to
+		}
+		if err := window(a, b); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	// defaultLabelValueLimit is how many distinct values a label of one
+	// metric may take before further values are bucketed.
+	defaultLabelValueLimit        = 1000
+	defaultCardinalityReportLimit = 20
+)
+
+// OverflowLabelValue replaces the values of a label past its metric's
+// limit, so the points are kept under a single series.
+const OverflowLabelValue = "__overflow__"
+
+type labelRef struct{ metric, label string }
+
+// labelValues tracks the distinct values admitted for one label of one
+// metric, and the points bucketed once it was full.
+type labelValues struct {
+	values         map[string]struct{}
+	overflowed     uint64
+	lastOverflowAt time.Time
+	// lastOverflowed is a value that was bucketed, to help find the source.
+	lastOverflowed string
+}
+
+// cardinalityGuard bounds the distinct values of each metric label. It only
+// knows the values seen since the process started.
+type cardinalityGuard struct {
+	mu           sync.Mutex
+	defaultLimit int
+	limits       map[string]int
+	labels       map[labelRef]*labelValues
+}
+
+func newCardinalityGuard() *cardinalityGuard {
+	return &cardinalityGuard{
+		defaultLimit: defaultLabelValueLimit,
+		limits:       map[string]int{},
+		labels:       map[labelRef]*labelValues{},
+	}
+}
+
+// LabelCardinality reports the values seen for one label of a metric.
+type LabelCardinality struct {
+	Metric           string     `json:"metric"`
+	Label            string     `json:"label"`
+	DistinctValues   int        `json:"distinct_values"`
+	Limit            int        `json:"limit"`
+	OverflowedPoints uint64     `json:"overflowed_points"`
+	LastOverflowAt   *time.Time `json:"last_overflow_at,omitempty"`
+	LastOverflowed   string     `json:"last_overflowed_value,omitempty"`
+}
+
+// SetLabelValueLimits sets how many distinct values each label of a metric
+// may take: perMetric by metric name, defaultLimit for the others. A limit
+// of 0 or less leaves the labels unbounded.
+func (svc *Service) SetLabelValueLimits(defaultLimit int, perMetric map[string]int) {
+	g := svc.cardinality
+	g.mu.Lock()
+	g.defaultLimit = defaultLimit
+	g.limits = maps.Clone(perMetric)
+	if g.limits == nil {
+		g.limits = map[string]int{}
+	}
+	g.mu.Unlock()
+}
+
+// addMetric bounds entry's label values, stores it and exposes it on
+// /metrics.
+func (svc *Service) addMetric(ctx context.Context, entry model.MetricEntry, now time.Time) error {
+	svc.cardinality.apply(&entry, now)
+	if err := svc.store.AddMetric(ctx, entry); err != nil {
+		return err
+	}
+	svc.prom.observe(entry, now)
+	return nil
+}
+
+// apply replaces the label values of entry that are new past their
+// metric's limit with OverflowLabelValue.
+func (g *cardinalityGuard) apply(entry *model.MetricEntry, now time.Time) {
+	if len(entry.Labels) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	limit, ok := g.limits[entry.Name]
+	if !ok {
+		limit = g.defaultLimit
+	}
+	if limit <= 0 {
+		return
+	}
+	copied := false
+	for k, v := range entry.Labels {
+		ref := labelRef{entry.Name, k}
+		lv := g.labels[ref]
+		if lv == nil {
+			lv = &labelValues{values: map[string]struct{}{}}
+			g.labels[ref] = lv
+		}
+		if _, seen := lv.values[v]; seen {
+			continue
+		}
+		if len(lv.values) < limit {
+			lv.values[v] = struct{}{}
+			continue
+		}
+		lv.overflowed++
+		lv.lastOverflowAt = now
+		lv.lastOverflowed = v
+		if !copied {
+			entry.Labels = maps.Clone(entry.Labels)
+			copied = true
+		}
+		entry.Labels[k] = OverflowLabelValue
+	}
+}
+
+// HandleLabelCardinality handles GET /v1/metrics/cardinality
+//
+// It lists the metric labels with the most values, those that overflowed
+// their limit first, optionally only for one metric.
+func (svc *Service) HandleLabelCardinality(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	limit := defaultCardinalityReportLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			respondError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	g := svc.cardinality
+	g.mu.Lock()
+	report := make([]LabelCardinality, 0, len(g.labels))
+	for ref, lv := range g.labels {
+		if metric != "" && ref.metric != metric {
+			continue
+		}
+		lc := LabelCardinality{
+			Metric:           ref.metric,
+			Label:            ref.label,
+			DistinctValues:   len(lv.values),
+			Limit:            g.defaultLimit,
+			OverflowedPoints: lv.overflowed,
+			LastOverflowed:   lv.lastOverflowed,
+		}
+		if l, ok := g.limits[ref.metric]; ok {
+			lc.Limit = l
+		}
+		if !lv.lastOverflowAt.IsZero() {
+			at := lv.lastOverflowAt
+			lc.LastOverflowAt = &at
+		}
+		report = append(report, lc)
+	}
+	defaultLimit := g.defaultLimit
+	g.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.OverflowedPoints != b.OverflowedPoints {
+			return a.OverflowedPoints > b.OverflowedPoints
+		}
+		if a.DistinctValues != b.DistinctValues {
+			return a.DistinctValues > b.DistinctValues
+		}
+		if a.Metric != b.Metric {
+			return a.Metric < b.Metric
+		}
+		return a.Label < b.Label
+	})
+	total := len(report)
+	report = report[:min(limit, total)]
+	respondJSON(w, http.StatusOK, map[string]any{
+		"default_limit": defaultLimit,
+		"labels":        report,
+		"count":         len(report),
+		"total":         total,
+	})
+}
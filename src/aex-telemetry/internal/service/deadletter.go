@@ -0,0 +1,212 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	// maxDeadLetters is how many rejected items are kept for
+	// GET /v1/dead-letters.
+	maxDeadLetters = 1000
+	// maxDeadLetterPayload caps the bytes of an item kept with its reason.
+	maxDeadLetterPayload   = 4096
+	defaultDeadLetterLimit = 100
+)
+
+// deadLetterBuffer holds the most recently rejected items.
+type deadLetterBuffer struct {
+	mu    sync.Mutex
+	items []model.DeadLetter
+}
+
+func newDeadLetterBuffer() *deadLetterBuffer {
+	return &deadLetterBuffer{}
+}
+
+func (b *deadLetterBuffer) add(dl model.DeadLetter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items = append(b.items, dl)
+	if len(b.items) > maxDeadLetters {
+		b.items = b.items[len(b.items)-maxDeadLetters:]
+	}
+}
+
+// ingestBatch stores the valid items of a JSON array of T and puts the
+// others in the dead-letter buffer, responding with the counts of both. A
+// single object is taken as a batch of one. Only a body that is not JSON
+// is rejected as a whole.
+func ingestBatch[T any](svc *Service, w http.ResponseWriter, r *http.Request, signal model.Signal,
+	validate func(*T) error, add func(context.Context, T) error) {
+	result := model.IngestResult{BatchID: newID()}
+	now := time.Now().UTC()
+	reject := func(index int, reason string, payload []byte) {
+		if index >= 0 {
+			result.Rejections = append(result.Rejections, model.Rejection{Index: index, Reason: reason})
+		}
+		dl := model.DeadLetter{
+			ID:         newID(),
+			BatchID:    result.BatchID,
+			Signal:     signal,
+			Index:      index,
+			Reason:     reason,
+			ReceivedAt: now,
+		}
+		if len(payload) > maxDeadLetterPayload {
+			payload, dl.Truncated = payload[:maxDeadLetterPayload], true
+		}
+		dl.Payload = strings.ToValidUTF8(string(payload), string(utf8.RuneError))
+		svc.deadLetters.add(dl)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	items, err := splitBatch(body)
+	if err != nil {
+		reject(-1, "invalid request body: "+jsonReason(err), body)
+		log.Printf("ingest %s batch=%s: rejected invalid body: %v", signal, result.BatchID, err)
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	for i, raw := range items {
+		var item T
+		err := json.Unmarshal(raw, &item)
+		if err == nil {
+			err = validate(&item)
+		}
+		if err != nil {
+			reject(i, jsonReason(err), raw)
+			continue
+		}
+		if err := add(r.Context(), item); err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to store "+strings.TrimSuffix(string(signal), "s"))
+			return
+		}
+		result.Accepted++
+	}
+	result.Rejected = len(result.Rejections)
+	if result.Rejected > 0 {
+		log.Printf("ingest %s batch=%s: accepted=%d rejected=%d first reason: %s",
+			signal, result.BatchID, result.Accepted, result.Rejected, result.Rejections[0].Reason)
+	}
+	respondJSON(w, http.StatusAccepted, result)
+}
+
+// splitBatch splits a JSON array into its items, or wraps a single object.
+func splitBatch(body []byte) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if !json.Valid(trimmed) {
+			var v any
+			return nil, json.Unmarshal(trimmed, &v)
+		}
+		return []json.RawMessage{trimmed}, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(trimmed, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func jsonReason(err error) string {
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
+
+func validateLog(entry *model.LogEntry) error {
+	if entry.Message == "" {
+		return errors.New("message is required")
+	}
+	return nil
+}
+
+func validateMetric(entry *model.MetricEntry) error {
+	if entry.Name == "" {
+		return errors.New("name is required")
+	}
+	switch entry.Type {
+	case "", model.MetricTypeCounter, model.MetricTypeGauge, model.MetricTypeHistogram:
+	default:
+		return errors.New("type must be counter, gauge or histogram")
+	}
+	if entry.Rollup != nil {
+		return errors.New("rollup is computed by the service and cannot be ingested")
+	}
+	if h := entry.Histogram; h != nil {
+		if len(h.BucketCounts) != len(h.ExplicitBounds)+1 {
+			return errors.New("histogram must have one more bucket count than explicit bounds")
+		}
+		if !slices.IsSorted(h.ExplicitBounds) {
+			return errors.New("histogram explicit bounds must be sorted")
+		}
+	}
+	return nil
+}
+
+func validateSpan(span *model.TraceSpan) error {
+	if span.TraceID == "" || span.SpanID == "" {
+		return errors.New("trace_id and span_id are required")
+	}
+	if !span.EndTime.IsZero() && span.EndTime.Before(span.StartTime) {
+		return errors.New("end_time is before start_time")
+	}
+	if span.DurationMs < 0 {
+		return errors.New("duration_ms must not be negative")
+	}
+	return nil
+}
+
+// HandleListDeadLetters handles GET /v1/dead-letters
+//
+// It lists the most recently rejected items, newest first, optionally only
+// those of one signal or batch.
+func (svc *Service) HandleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	signal := model.Signal(q.Get("signal"))
+	if signal != "" && signal != model.SignalLogs && signal != model.SignalMetrics && signal != model.SignalSpans {
+		respondError(w, http.StatusBadRequest, "signal must be one of logs, metrics, spans")
+		return
+	}
+	batchID := q.Get("batch_id")
+	limit := defaultDeadLetterLimit
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			respondError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxDeadLetters)
+	}
+
+	svc.deadLetters.mu.Lock()
+	items := []model.DeadLetter{}
+	for i := len(svc.deadLetters.items) - 1; i >= 0 && len(items) < limit; i-- {
+		dl := svc.deadLetters.items[i]
+		if (signal == "" || dl.Signal == signal) && (batchID == "" || dl.BatchID == batchID) {
+			items = append(items, dl)
+		}
+	}
+	svc.deadLetters.mu.Unlock()
+	respondJSON(w, http.StatusOK, map[string]any{
+		"dead_letters": items,
+		"count":        len(items),
+	})
+}
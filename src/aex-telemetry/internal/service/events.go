@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/events"
+)
+
+// maxEventQueryLimit caps the events returned by one query.
+const maxEventQueryLimit = 1000
+
+// DefaultEventTypes are the marketplace events the consumer stores unless
+// configured otherwise.
+var DefaultEventTypes = []string{
+	events.EventWorkSubmitted,
+	events.EventContractCompleted,
+	events.EventSettlementCompleted,
+	events.EventTrustTierChanged,
+}
+
+// eventConsumer is the subscription of the event bus consumer. It is off
+// until enabled, as publishers must be pointed at it.
+type eventConsumer struct {
+	mu      sync.RWMutex
+	enabled bool
+	// types are the stored event types; nil stores every type.
+	types []string
+}
+
+// EnableEventConsumer makes POST /internal/v1/events store the events of
+// the given types; "*" stores every type.
+func (svc *Service) EnableEventConsumer(types []string) {
+	svc.consumer.mu.Lock()
+	defer svc.consumer.mu.Unlock()
+	svc.consumer.enabled = true
+	svc.consumer.types = types
+	if slices.Contains(types, "*") {
+		svc.consumer.types = nil
+	}
+}
+
+// HandleReceiveEvent handles POST /internal/v1/events
+//
+// It receives event envelopes from the shared event bus. Subscribed types
+// are stored as marketplace events, each event id once; others are
+// acknowledged and ignored.
+func (svc *Service) HandleReceiveEvent(w http.ResponseWriter, r *http.Request) {
+	svc.consumer.mu.RLock()
+	enabled, types := svc.consumer.enabled, svc.consumer.types
+	svc.consumer.mu.RUnlock()
+	if !enabled {
+		respondError(w, http.StatusServiceUnavailable, "event consumer is not enabled")
+		return
+	}
+
+	var env events.Envelope
+	if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if env.EventID == "" || env.EventType == "" {
+		respondError(w, http.StatusBadRequest, "event_id and event_type are required")
+		return
+	}
+	if types != nil && !slices.Contains(types, env.EventType) {
+		respondJSON(w, http.StatusOK, map[string]any{"event_id": env.EventID, "ignored": true})
+		return
+	}
+
+	now := time.Now().UTC()
+	event := model.MarketEvent{
+		ID:         env.EventID,
+		Type:       env.EventType,
+		Source:     env.Source,
+		TenantID:   env.TenantID,
+		Timestamp:  env.Timestamp,
+		ReceivedAt: now,
+		WorkID:     stringField(env.Data, "work_id"),
+		ContractID: stringField(env.Data, "contract_id"),
+		ProviderID: stringField(env.Data, "provider_id"),
+		ConsumerID: stringField(env.Data, "consumer_id"),
+		Data:       env.Data,
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = now
+	}
+	added, err := svc.store.AddEvent(r.Context(), event)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to store event")
+		return
+	}
+	respondJSON(w, http.StatusAccepted, map[string]any{"event_id": env.EventID, "stored": added})
+}
+
+// HandleQueryEvents handles GET /v1/events
+//
+// type is a comma-separated list of event types; the other filters match
+// the event's source, tenant and the work, contract, provider and consumer
+// it concerns.
+func (svc *Service) HandleQueryEvents(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := svc.callerTenant(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	query := model.EventQuery{
+		Source:     q.Get("source"),
+		TenantID:   q.Get("tenant_id"),
+		WorkID:     q.Get("work_id"),
+		ContractID: q.Get("contract_id"),
+		ProviderID: q.Get("provider_id"),
+		ConsumerID: q.Get("consumer_id"),
+	}
+	if tenant != "" {
+		query.TenantID = tenant
+	}
+	if s := q.Get("type"); s != "" {
+		query.Types = strings.Split(s, ",")
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			respondError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		query.Limit = min(n, maxEventQueryLimit)
+	}
+	from, to, err := parseTimeRange(q)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.StartTime, query.EndTime = from, to
+
+	evs, err := svc.store.QueryEvents(r.Context(), query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	if evs == nil {
+		evs = []model.MarketEvent{}
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"events": evs,
+		"count":  len(evs),
+	})
+}
+
+func stringField(data map[string]any, key string) string {
+	s, _ := data[key].(string)
+	return s
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	// tailBuffer is how many entries a slow subscriber may lag behind
+	// before further entries are dropped for it.
+	tailBuffer = 256
+	// tailKeepalive is how often an idle stream gets a comment line, so
+	// proxies do not close it.
+	tailKeepalive = 15 * time.Second
+)
+
+type tailSubscriber struct {
+	service string
+	level   string
+	tenant  string
+	ch      chan model.LogEntry
+
+	// dropped counts entries lost since the last one delivered.
+	mu      sync.Mutex
+	dropped int
+}
+
+// logTail fans ingested logs out to the live streams.
+type logTail struct {
+	mu   sync.RWMutex
+	subs map[*tailSubscriber]struct{}
+}
+
+func newLogTail() *logTail {
+	return &logTail{subs: map[*tailSubscriber]struct{}{}}
+}
+
+func (t *logTail) subscribe(service, level, tenant string) *tailSubscriber {
+	sub := &tailSubscriber{service: service, level: level, tenant: tenant, ch: make(chan model.LogEntry, tailBuffer)}
+	t.mu.Lock()
+	t.subs[sub] = struct{}{}
+	t.mu.Unlock()
+	return sub
+}
+
+func (t *logTail) unsubscribe(sub *tailSubscriber) {
+	t.mu.Lock()
+	delete(t.subs, sub)
+	t.mu.Unlock()
+}
+
+// publish hands entry to the matching subscribers without blocking
+// ingestion on any of them.
+func (t *logTail) publish(entry model.LogEntry) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for sub := range t.subs {
+		if (sub.service != "" && sub.service != entry.Service) || (sub.level != "" && sub.level != entry.Level) ||
+			!logOfTenant(entry, sub.tenant) {
+			continue
+		}
+		select {
+		case sub.ch <- entry:
+		default:
+			sub.mu.Lock()
+			sub.dropped++
+			sub.mu.Unlock()
+		}
+	}
+}
+
+// addLog stores entry and publishes it to the live streams.
+func (svc *Service) addLog(ctx context.Context, entry model.LogEntry) error {
+	if entry.ID == "" {
+		entry.ID = newID()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if err := svc.store.AddLog(ctx, entry); err != nil {
+		return err
+	}
+	svc.tail.publish(entry)
+	return nil
+}
+
+// HandleStreamLogs handles GET /v1/logs/stream
+//
+// It streams the logs ingested from now on that match the service and
+// level filters as server-sent events, one "log" event per entry. When the
+// client falls behind, a "dropped" event reports how many entries it
+// missed.
+func (svc *Service) HandleStreamLogs(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := svc.callerTenant(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	// The stream outlives the server's write timeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	sub := svc.tail.subscribe(r.URL.Query().Get("service"), r.URL.Query().Get("level"), tenant)
+	defer svc.tail.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(tailKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case entry := <-sub.ch:
+			sub.mu.Lock()
+			dropped := sub.dropped
+			sub.dropped = 0
+			sub.mu.Unlock()
+			if dropped > 0 {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", dropped)
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: log\ndata: %s\n\n", entry.ID, data)
+		}
+		flusher.Flush()
+	}
+}
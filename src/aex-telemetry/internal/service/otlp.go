@@ -0,0 +1,459 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxOTLPBodyBytes caps the decompressed size of an OTLP export request.
+const maxOTLPBodyBytes = 16 << 20
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// unknownService is the OpenTelemetry default for resources without a
+// service.name attribute.
+const unknownService = "unknown_service"
+
+var errUnsupportedMediaType = errors.New("content type must be application/x-protobuf or application/json")
+
+// HandleOTLPTraces handles POST /v1/otlp/traces
+func (svc *Service) HandleOTLPTraces(w http.ResponseWriter, r *http.Request) {
+	var req coltracepb.ExportTraceServiceRequest
+	ct, ok := decodeOTLP(w, r, &req)
+	if !ok {
+		return
+	}
+	for _, span := range spansFromOTLP(&req) {
+		if err := svc.store.AddSpan(r.Context(), span); err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to store span")
+			return
+		}
+	}
+	writeOTLP(w, ct, &coltracepb.ExportTraceServiceResponse{})
+}
+
+// HandleOTLPMetrics handles POST /v1/otlp/metrics
+func (svc *Service) HandleOTLPMetrics(w http.ResponseWriter, r *http.Request) {
+	var req colmetricspb.ExportMetricsServiceRequest
+	ct, ok := decodeOTLP(w, r, &req)
+	if !ok {
+		return
+	}
+	entries, rejected, reason := metricsFromOTLP(&req)
+	now := time.Now()
+	for _, entry := range entries {
+		if err := svc.addMetric(r.Context(), entry, now); err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to store metric")
+			return
+		}
+	}
+	resp := &colmetricspb.ExportMetricsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{RejectedDataPoints: rejected, ErrorMessage: reason}
+	}
+	writeOTLP(w, ct, resp)
+}
+
+// HandleOTLPLogs handles POST /v1/otlp/logs
+func (svc *Service) HandleOTLPLogs(w http.ResponseWriter, r *http.Request) {
+	var req collogspb.ExportLogsServiceRequest
+	ct, ok := decodeOTLP(w, r, &req)
+	if !ok {
+		return
+	}
+	for _, entry := range logsFromOTLP(&req) {
+		if err := svc.addLog(r.Context(), entry); err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to store log")
+			return
+		}
+	}
+	writeOTLP(w, ct, &collogspb.ExportLogsServiceResponse{})
+}
+
+// decodeOTLP reads an OTLP/HTTP request body, gzip-compressed or not, into
+// msg and returns its content type. It responds itself when it fails.
+func decodeOTLP(w http.ResponseWriter, r *http.Request, msg proto.Message) (string, bool) {
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (ct != contentTypeProtobuf && ct != contentTypeJSON) {
+		respondError(w, http.StatusUnsupportedMediaType, errUnsupportedMediaType.Error())
+		return "", false
+	}
+	var body io.Reader = r.Body
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid gzip body")
+			return "", false
+		}
+		defer func() { _ = zr.Close() }()
+		body = zr
+	default:
+		respondError(w, http.StatusUnsupportedMediaType, "content encoding must be gzip or identity")
+		return "", false
+	}
+	raw, err := io.ReadAll(io.LimitReader(body, maxOTLPBodyBytes+1))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to read request body")
+		return "", false
+	}
+	if len(raw) > maxOTLPBodyBytes {
+		respondError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return "", false
+	}
+
+	if ct == contentTypeProtobuf {
+		err = proto.Unmarshal(raw, msg)
+	} else {
+		raw, err = hexIDsToBase64(raw)
+		if err == nil {
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(raw, msg)
+		}
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid OTLP request: "+err.Error())
+		return "", false
+	}
+	return ct, true
+}
+
+// writeOTLP responds with msg encoded as the request was.
+func writeOTLP(w http.ResponseWriter, ct string, msg proto.Message) {
+	var (
+		b   []byte
+		err error
+	)
+	if ct == contentTypeProtobuf {
+		b, err = proto.Marshal(msg)
+	} else {
+		b, err = protojson.Marshal(msg)
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+	w.Header().Set("Content-Type", ct)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
+
+// otlpIDFields are the OTLP JSON fields that carry hex-encoded ids.
+var otlpIDFields = map[string]bool{
+	"traceId": true, "spanId": true, "parentSpanId": true,
+	"trace_id": true, "span_id": true, "parent_span_id": true,
+}
+
+// hexIDsToBase64 rewrites the trace and span ids of an OTLP JSON body from
+// the hex encoding OTLP uses to the base64 protojson expects for bytes.
+func hexIDsToBase64(raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	var walk func(v any) error
+	walk = func(v any) error {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				if s, ok := child.(string); ok && otlpIDFields[k] {
+					id, err := hex.DecodeString(s)
+					if err != nil {
+						return fmt.Errorf("%s is not hex: %q", k, s)
+					}
+					t[k] = base64.StdEncoding.EncodeToString(id)
+					continue
+				}
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for _, child := range t {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func spansFromOTLP(req *coltracepb.ExportTraceServiceRequest) []model.TraceSpan {
+	var out []model.TraceSpan
+	for _, rs := range req.GetResourceSpans() {
+		service := serviceName(rs.GetResource())
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				start, end := unixNano(s.GetStartTimeUnixNano()), unixNano(s.GetEndTimeUnixNano())
+				attrs := stringAttributes(s.GetAttributes())
+				if kind := s.GetKind(); kind != tracepb.Span_SPAN_KIND_UNSPECIFIED {
+					if attrs == nil {
+						attrs = map[string]string{}
+					}
+					attrs["span.kind"] = strings.ToLower(strings.TrimPrefix(kind.String(), "SPAN_KIND_"))
+				}
+				out = append(out, model.TraceSpan{
+					TraceID:      hex.EncodeToString(s.GetTraceId()),
+					SpanID:       hex.EncodeToString(s.GetSpanId()),
+					ParentSpanID: hex.EncodeToString(s.GetParentSpanId()),
+					Service:      service,
+					Operation:    s.GetName(),
+					StartTime:    start,
+					EndTime:      end,
+					DurationMs:   end.Sub(start).Milliseconds(),
+					Status:       strings.TrimPrefix(s.GetStatus().GetCode().String(), "STATUS_CODE_"),
+					Attributes:   attrs,
+				})
+			}
+		}
+	}
+	return out
+}
+
+// metricsFromOTLP translates gauges, sums and explicit-bucket histograms
+// with cumulative temporality. Other points are counted as rejected, with
+// the reason for the first.
+func metricsFromOTLP(req *colmetricspb.ExportMetricsServiceRequest) ([]model.MetricEntry, int64, string) {
+	var (
+		out      []model.MetricEntry
+		rejected int64
+		reason   string
+	)
+	reject := func(n int, why string) {
+		rejected += int64(n)
+		if reason == "" {
+			reason = why
+		}
+	}
+	for _, rm := range req.GetResourceMetrics() {
+		service := serviceName(rm.GetResource())
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				switch data := m.GetData().(type) {
+				case *metricspb.Metric_Gauge:
+					for _, dp := range data.Gauge.GetDataPoints() {
+						out = append(out, numberEntry(m.GetName(), model.MetricTypeGauge, service, dp))
+					}
+				case *metricspb.Metric_Sum:
+					points := data.Sum.GetDataPoints()
+					typ := model.MetricTypeGauge
+					if data.Sum.GetIsMonotonic() {
+						if data.Sum.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+							reject(len(points), m.GetName()+": only cumulative sums are supported")
+							continue
+						}
+						typ = model.MetricTypeCounter
+					}
+					for _, dp := range points {
+						out = append(out, numberEntry(m.GetName(), typ, service, dp))
+					}
+				case *metricspb.Metric_Histogram:
+					points := data.Histogram.GetDataPoints()
+					if data.Histogram.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+						reject(len(points), m.GetName()+": only cumulative histograms are supported")
+						continue
+					}
+					for _, dp := range points {
+						out = append(out, model.MetricEntry{
+							Timestamp: unixNano(dp.GetTimeUnixNano()),
+							Name:      m.GetName(),
+							Type:      model.MetricTypeHistogram,
+							Value:     dp.GetSum(),
+							Service:   service,
+							Labels:    stringAttributes(dp.GetAttributes()),
+							Histogram: &model.HistogramData{
+								Count:          dp.GetCount(),
+								Sum:            dp.GetSum(),
+								ExplicitBounds: dp.GetExplicitBounds(),
+								BucketCounts:   dp.GetBucketCounts(),
+							},
+						})
+					}
+				case *metricspb.Metric_ExponentialHistogram:
+					reject(len(data.ExponentialHistogram.GetDataPoints()), m.GetName()+": exponential histograms are not supported")
+				case *metricspb.Metric_Summary:
+					reject(len(data.Summary.GetDataPoints()), m.GetName()+": summaries are not supported")
+				}
+			}
+		}
+	}
+	return out, rejected, reason
+}
+
+func numberEntry(name string, typ model.MetricType, service string, dp *metricspb.NumberDataPoint) model.MetricEntry {
+	value := dp.GetAsDouble()
+	if v, ok := dp.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		value = float64(v.AsInt)
+	}
+	return model.MetricEntry{
+		Timestamp: unixNano(dp.GetTimeUnixNano()),
+		Name:      name,
+		Type:      typ,
+		Value:     value,
+		Service:   service,
+		Labels:    stringAttributes(dp.GetAttributes()),
+	}
+}
+
+func logsFromOTLP(req *collogspb.ExportLogsServiceRequest) []model.LogEntry {
+	var out []model.LogEntry
+	for _, rl := range req.GetResourceLogs() {
+		service := serviceName(rl.GetResource())
+		for _, sl := range rl.GetScopeLogs() {
+			for _, lr := range sl.GetLogRecords() {
+				ts := lr.GetTimeUnixNano()
+				if ts == 0 {
+					ts = lr.GetObservedTimeUnixNano()
+				}
+				var fields map[string]any
+				if attrs := lr.GetAttributes(); len(attrs) > 0 {
+					fields = make(map[string]any, len(attrs))
+					for _, kv := range attrs {
+						fields[kv.GetKey()] = anyValue(kv.GetValue())
+					}
+				}
+				out = append(out, model.LogEntry{
+					Timestamp: unixNano(ts),
+					Level:     logLevel(lr),
+					Service:   service,
+					Message:   anyValueString(lr.GetBody()),
+					Fields:    fields,
+					TraceID:   hex.EncodeToString(lr.GetTraceId()),
+					SpanID:    hex.EncodeToString(lr.GetSpanId()),
+				})
+			}
+		}
+	}
+	return out
+}
+
+// logLevel is the record's severity text, lower-cased, or else the level
+// its severity number falls in.
+func logLevel(lr *logspb.LogRecord) string {
+	if t := strings.TrimSpace(lr.GetSeverityText()); t != "" {
+		return strings.ToLower(t)
+	}
+	switch n := lr.GetSeverityNumber(); {
+	case n == logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED:
+		return "info"
+	case n <= logspb.SeverityNumber_SEVERITY_NUMBER_TRACE4:
+		return "trace"
+	case n <= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG4:
+		return "debug"
+	case n <= logspb.SeverityNumber_SEVERITY_NUMBER_INFO4:
+		return "info"
+	case n <= logspb.SeverityNumber_SEVERITY_NUMBER_WARN4:
+		return "warn"
+	case n <= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR4:
+		return "error"
+	default:
+		return "fatal"
+	}
+}
+
+func serviceName(res *resourcepb.Resource) string {
+	for _, kv := range res.GetAttributes() {
+		if kv.GetKey() == "service.name" {
+			if s := anyValueString(kv.GetValue()); s != "" {
+				return s
+			}
+		}
+	}
+	return unknownService
+}
+
+func stringAttributes(attrs []*commonpb.KeyValue) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		out[kv.GetKey()] = anyValueString(kv.GetValue())
+	}
+	return out
+}
+
+// anyValue converts an OTLP value to its Go equivalent.
+func anyValue(v *commonpb.AnyValue) any {
+	switch t := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return t.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return t.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return t.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return t.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(t.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		out := make([]any, 0, len(t.ArrayValue.GetValues()))
+		for _, e := range t.ArrayValue.GetValues() {
+			out = append(out, anyValue(e))
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		out := make(map[string]any, len(t.KvlistValue.GetValues()))
+		for _, kv := range t.KvlistValue.GetValues() {
+			out[kv.GetKey()] = anyValue(kv.GetValue())
+		}
+		return out
+	}
+	return nil
+}
+
+// anyValueString renders an OTLP value as a string; arrays and maps as JSON.
+func anyValueString(v *commonpb.AnyValue) string {
+	switch x := anyValue(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []any, map[string]any:
+		b, _ := json.Marshal(x)
+		return string(b)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// unixNano converts OTLP nanoseconds since the epoch; zero stays zero so
+// the store stamps the entry.
+func unixNano(ns uint64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ns)).UTC()
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	// probeTimeout bounds one probe request.
+	probeTimeout = 5 * time.Second
+	// probeHistory is how many recent results per path the availability
+	// is computed over.
+	probeHistory = 60
+	healthPath   = "/health"
+
+	// ProbeUpMetric is the gauge recorded for every probe: 1 when the path
+	// answered 2xx or 3xx, else 0. ProbeLatencyMetric is the histogram of
+	// probe latencies in milliseconds. Both are labeled with the path.
+	ProbeUpMetric      = "probe_up"
+	ProbeLatencyMetric = "probe_latency_ms"
+)
+
+// Overall states of GET /v1/status.
+const (
+	exchangeOperational   = "operational"
+	exchangePartialOutage = "partial_outage"
+	exchangeMajorOutage   = "major_outage"
+)
+
+var errProbeTargetNotFound = errors.New("probe target not found")
+
+type probeKey struct{ service, path string }
+
+// prober holds the probe targets and their recent results.
+type prober struct {
+	mu      sync.Mutex
+	targets map[string]model.ProbeTarget
+	history map[probeKey][]model.ProbeResult
+	http    *http.Client
+}
+
+func newProber() *prober {
+	return &prober{
+		targets: map[string]model.ProbeTarget{},
+		history: map[probeKey][]model.ProbeResult{},
+		http: &http.Client{
+			Timeout: probeTimeout,
+			// A redirect is an answer; following it probes another service.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// AddProbeTarget registers target, replacing the service's previous one.
+func (svc *Service) AddProbeTarget(target model.ProbeTarget) error {
+	if err := validateProbeTarget(&target); err != nil {
+		return err
+	}
+	p := svc.probes
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.targets[target.Service] = target
+	for k := range p.history {
+		if k.service == target.Service && !slices.Contains(target.Paths, k.path) {
+			delete(p.history, k)
+		}
+	}
+	return nil
+}
+
+// RunProbes probes every target each interval until ctx is done.
+func (svc *Service) RunProbes(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		svc.ProbeServices(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeServices probes every path of every target concurrently and records
+// the results as metrics.
+func (svc *Service) ProbeServices(ctx context.Context) {
+	p := svc.probes
+	p.mu.Lock()
+	var keys []probeKey
+	var urls []string
+	for _, t := range p.targets {
+		for _, path := range t.Paths {
+			keys = append(keys, probeKey{t.Service, path})
+			urls = append(urls, strings.TrimRight(t.BaseURL, "/")+path)
+		}
+	}
+	p.mu.Unlock()
+
+	results := make([]model.ProbeResult, len(keys))
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = p.probe(ctx, keys[i], urls[i])
+		}(i)
+	}
+	wg.Wait()
+
+	now := time.Now().UTC()
+	p.mu.Lock()
+	for _, res := range results {
+		k := probeKey{res.Service, res.Path}
+		t, ok := p.targets[k.service]
+		if !ok || !slices.Contains(t.Paths, k.path) {
+			continue // removed while probing
+		}
+		h := append(p.history[k], res)
+		if len(h) > probeHistory {
+			h = h[len(h)-probeHistory:]
+		}
+		p.history[k] = h
+	}
+	p.mu.Unlock()
+
+	for _, res := range results {
+		if !res.Up {
+			log.Printf("probe %s %s down: status=%d error=%s", res.Service, res.Path, res.StatusCode, res.Error)
+		}
+		up := 0.0
+		if res.Up {
+			up = 1
+		}
+		labels := map[string]string{"path": res.Path}
+		for _, m := range []model.MetricEntry{
+			{Name: ProbeUpMetric, Type: model.MetricTypeGauge, Value: up, Service: res.Service, Labels: labels, Timestamp: res.CheckedAt},
+			{Name: ProbeLatencyMetric, Type: model.MetricTypeHistogram, Value: res.LatencyMs, Service: res.Service, Labels: labels, Timestamp: res.CheckedAt},
+		} {
+			if err := svc.addMetric(ctx, m, now); err != nil {
+				log.Printf("probe: failed to record %s for %s: %v", m.Name, res.Service, err)
+			}
+		}
+	}
+}
+
+func (p *prober) probe(ctx context.Context, k probeKey, target string) model.ProbeResult {
+	res := model.ProbeResult{Service: k.service, Path: k.path, CheckedAt: time.Now().UTC()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	req.Header.Set("User-Agent", "aex-telemetry-prober")
+	start := time.Now()
+	resp, err := p.http.Do(req)
+	res.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	_ = resp.Body.Close()
+	res.StatusCode = resp.StatusCode
+	res.Up = resp.StatusCode < 400
+	return res
+}
+
+// HandlePutProbeTarget handles PUT /v1/probes/{service}
+func (svc *Service) HandlePutProbeTarget(w http.ResponseWriter, r *http.Request) {
+	var target model.ProbeTarget
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	target.Service = r.PathValue("service")
+	if err := svc.AddProbeTarget(target); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	svc.probes.mu.Lock()
+	target = svc.probes.targets[target.Service]
+	svc.probes.mu.Unlock()
+	respondJSON(w, http.StatusOK, target)
+}
+
+// HandleListProbeTargets handles GET /v1/probes
+func (svc *Service) HandleListProbeTargets(w http.ResponseWriter, r *http.Request) {
+	svc.probes.mu.Lock()
+	targets := make([]model.ProbeTarget, 0, len(svc.probes.targets))
+	for _, t := range svc.probes.targets {
+		targets = append(targets, t)
+	}
+	svc.probes.mu.Unlock()
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Service < targets[j].Service })
+	respondJSON(w, http.StatusOK, map[string]any{
+		"targets": targets,
+		"count":   len(targets),
+	})
+}
+
+// HandleDeleteProbeTarget handles DELETE /v1/probes/{service}
+func (svc *Service) HandleDeleteProbeTarget(w http.ResponseWriter, r *http.Request) {
+	service := r.PathValue("service")
+	p := svc.probes
+	p.mu.Lock()
+	_, ok := p.targets[service]
+	delete(p.targets, service)
+	for k := range p.history {
+		if k.service == service {
+			delete(p.history, k)
+		}
+	}
+	p.mu.Unlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errProbeTargetNotFound.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleExchangeStatus handles GET /v1/status
+//
+// It reports every probed service from its latest probes: up when all its
+// paths answered, down when /health did not, degraded otherwise. The
+// exchange is operational when every service is up, in a major outage when
+// none is, and in a partial outage in between.
+func (svc *Service) HandleExchangeStatus(w http.ResponseWriter, r *http.Request) {
+	p := svc.probes
+	p.mu.Lock()
+	services := make([]model.ServiceStatus, 0, len(p.targets))
+	for _, t := range p.targets {
+		s := model.ServiceStatus{Service: t.Service, Status: model.ProbeUnknown, Checks: []model.ProbeResult{}}
+		var up, total, failing int
+		healthDown := false
+		for _, path := range t.Paths {
+			h := p.history[probeKey{t.Service, path}]
+			if len(h) == 0 {
+				continue
+			}
+			last := h[len(h)-1]
+			s.Checks = append(s.Checks, last)
+			if !last.Up {
+				failing++
+				healthDown = healthDown || path == healthPath
+			}
+			for _, res := range h {
+				total++
+				if res.Up {
+					up++
+				}
+			}
+		}
+		if total > 0 {
+			s.Availability = float64(up) / float64(total)
+			switch {
+			case healthDown || failing == len(s.Checks):
+				s.Status = model.ProbeDown
+			case failing > 0:
+				s.Status = model.ProbeDegraded
+			default:
+				s.Status = model.ProbeUp
+			}
+		}
+		services = append(services, s)
+	}
+	p.mu.Unlock()
+	sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+
+	overall := exchangeOperational
+	var probed, down int
+	for _, s := range services {
+		if s.Status == model.ProbeUnknown {
+			continue
+		}
+		probed++
+		if s.Status == model.ProbeDown {
+			down++
+		}
+		if s.Status != model.ProbeUp {
+			overall = exchangePartialOutage
+		}
+	}
+	if probed > 0 && down == probed {
+		overall = exchangeMajorOutage
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"status":     overall,
+		"services":   services,
+		"updated_at": time.Now().UTC(),
+	})
+}
+
+// validateProbeTarget checks target and puts /health first among its
+// paths.
+func validateProbeTarget(target *model.ProbeTarget) error {
+	if target.Service == "" {
+		return errors.New("service required")
+	}
+	u, err := url.Parse(target.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("base_url must be an absolute http(s) URL")
+	}
+	paths := []string{healthPath}
+	for _, path := range target.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return errors.New("paths must start with /")
+		}
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	target.Paths = paths
+	return nil
+}
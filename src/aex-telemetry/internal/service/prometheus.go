@@ -0,0 +1,282 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+// defaultStaleness is how long a series is exposed after it was last reported.
+const defaultStaleness = 5 * time.Minute
+
+// defaultBuckets are the upper bounds used for histogram metrics ingested as
+// single observations, as in the Prometheus client libraries.
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type promSeries struct {
+	name   string
+	labels map[string]string
+	typ    model.MetricType
+	value  float64
+	// at is the timestamp of value; older points do not replace it.
+	at   time.Time
+	hist *model.HistogramData
+	// raw is set when hist accumulates single observations.
+	raw bool
+	// lastSeen is when the series was last reported, for staleness.
+	lastSeen time.Time
+}
+
+// promRegistry keeps the latest state of every ingested series for the
+// Prometheus exposition. Counters and gauges expose their latest value.
+// Histograms reported aggregated (OTLP) expose their latest buckets, and
+// histograms reported as single observations accumulate into
+// defaultBuckets. A series not reported within the staleness window is
+// dropped, so a scraper sees it disappear instead of a frozen value.
+type promRegistry struct {
+	mu        sync.Mutex
+	staleness time.Duration
+	series    map[string]*promSeries
+	// types fixes each metric name to the type it was first seen with, as
+	// a Prometheus metric family has a single type.
+	types map[string]model.MetricType
+}
+
+func newPromRegistry() *promRegistry {
+	return &promRegistry{
+		staleness: defaultStaleness,
+		series:    map[string]*promSeries{},
+		types:     map[string]model.MetricType{},
+	}
+}
+
+// SetMetricsStaleness sets how long an ingested series stays on /metrics
+// after it was last reported (default 5m).
+func (svc *Service) SetMetricsStaleness(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	svc.prom.mu.Lock()
+	svc.prom.staleness = d
+	svc.prom.mu.Unlock()
+}
+
+// observe records an ingested data point received at now.
+func (p *promRegistry) observe(e model.MetricEntry, now time.Time) {
+	name := promName(e.Name)
+	labels := promLabels(e.Labels, e.Service)
+	key := seriesKey(name, labels)
+	at := e.Timestamp
+	if at.IsZero() {
+		at = now
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if typ, ok := p.types[name]; ok && typ != e.Type {
+		return
+	}
+	p.types[name] = e.Type
+	s := p.series[key]
+	if s == nil {
+		s = &promSeries{name: name, labels: labels, typ: e.Type}
+		p.series[key] = s
+	}
+	s.lastSeen = now
+
+	if e.Type == model.MetricTypeHistogram && e.Histogram == nil {
+		if !s.raw {
+			s.raw = true
+			s.hist = &model.HistogramData{
+				ExplicitBounds: defaultBuckets,
+				BucketCounts:   make([]uint64, len(defaultBuckets)+1),
+			}
+		}
+		i := sort.SearchFloat64s(defaultBuckets, e.Value)
+		s.hist.BucketCounts[i]++
+		s.hist.Count++
+		s.hist.Sum += e.Value
+		return
+	}
+	if at.Before(s.at) {
+		return
+	}
+	s.at = at
+	s.value = e.Value
+	s.hist = e.Histogram
+	s.raw = false
+}
+
+// write renders the series reported within the staleness window in the
+// Prometheus text format and forgets the others.
+func (p *promRegistry) write(w io.Writer, now time.Time) error {
+	p.mu.Lock()
+	families := map[string][]*promSeries{}
+	for key, s := range p.series {
+		if now.Sub(s.lastSeen) > p.staleness {
+			delete(p.series, key)
+			continue
+		}
+		families[s.name] = append(families[s.name], s)
+	}
+	for name := range p.types {
+		if len(families[name]) == 0 {
+			delete(p.types, name)
+		}
+	}
+	p.mu.Unlock()
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		series := families[name]
+		sort.Slice(series, func(i, j int) bool {
+			return seriesKey("", series[i].labels) < seriesKey("", series[j].labels)
+		})
+		typ := string(series[0].typ)
+		if typ != string(model.MetricTypeCounter) && typ != string(model.MetricTypeGauge) && typ != string(model.MetricTypeHistogram) {
+			typ = "untyped"
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+		for _, s := range series {
+			if s.typ == model.MetricTypeHistogram && s.hist != nil {
+				writeHistogram(bw, s)
+				continue
+			}
+			fmt.Fprintf(bw, "%s%s %s\n", name, formatLabels(s.labels, "", ""), formatFloat(s.value))
+		}
+	}
+	return bw.Flush()
+}
+
+func writeHistogram(w io.Writer, s *promSeries) {
+	var cumulative uint64
+	for i, count := range s.hist.BucketCounts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(s.hist.ExplicitBounds) {
+			le = formatFloat(s.hist.ExplicitBounds[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", s.name, formatLabels(s.labels, "le", le), cumulative)
+	}
+	if len(s.hist.BucketCounts) == 0 {
+		fmt.Fprintf(w, "%s_bucket%s %d\n", s.name, formatLabels(s.labels, "le", "+Inf"), s.hist.Count)
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", s.name, formatLabels(s.labels, "", ""), formatFloat(s.hist.Sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", s.name, formatLabels(s.labels, "", ""), s.hist.Count)
+}
+
+// HandlePrometheusMetrics handles GET /metrics
+func (svc *Service) HandlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = svc.prom.write(w, time.Now())
+}
+
+// promName maps a metric name to a valid Prometheus name, replacing
+// invalid characters (such as the dots of OpenTelemetry names) with "_".
+func promName(name string) string {
+	return sanitize(name, true)
+}
+
+// promLabels maps label names to valid Prometheus names and adds the
+// reporting service as the "service" label. A label that already had that
+// name becomes "exported_service".
+func promLabels(labels map[string]string, service string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		k = sanitize(k, false)
+		if k == "service" {
+			k = "exported_service"
+		}
+		if strings.HasPrefix(k, "__") {
+			k = "_" + strings.TrimLeft(k, "_")
+		}
+		out[k] = v
+	}
+	if service != "" {
+		out["service"] = service
+	}
+	return out
+}
+
+func sanitize(s string, colons bool) string {
+	if s == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for i, r := range s {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(colons && r == ':') || (i > 0 && r >= '0' && r <= '9')
+		if valid {
+			b.WriteRune(r)
+			continue
+		}
+		if i == 0 && r >= '0' && r <= '9' {
+			b.WriteByte('_')
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteByte('_')
+	}
+	return b.String()
+}
+
+func seriesKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
+
+// formatLabels renders labels, plus extraKey if set, as {k="v",...}.
+func formatLabels(labels map[string]string, extraKey, extraValue string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		parts = append(parts, k+`="`+escapeLabelValue(labels[k])+`"`)
+	}
+	if extraKey != "" {
+		parts = append(parts, extraKey+`="`+extraValue+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
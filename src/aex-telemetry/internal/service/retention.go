@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+var retentionSignals = []model.Signal{model.SignalLogs, model.SignalMetrics, model.SignalSpans}
+
+type retentionRule struct {
+	model.RetentionRule
+	keep time.Duration
+}
+
+// retentionPolicy holds the configured retention of each signal and the
+// rules set through the API.
+type retentionPolicy struct {
+	mu        sync.Mutex
+	defaults  map[model.Signal]time.Duration
+	rules     []retentionRule
+	updatedAt time.Time
+}
+
+func newRetentionPolicy() *retentionPolicy {
+	return &retentionPolicy{defaults: map[model.Signal]time.Duration{}}
+}
+
+// retentionTarget is the data one rule, or the signal's configured
+// retention, expires.
+type retentionTarget struct {
+	scope model.RetentionScope
+	keep  time.Duration
+}
+
+// RunRetention deletes data older than its retention every interval, which
+// must be positive, until ctx is done. retention is the configured
+// retention of each signal, which the rules set through PUT /v1/retention
+// refine; data without a positive retention is kept.
+func (svc *Service) RunRetention(ctx context.Context, retention map[model.Signal]time.Duration, interval time.Duration) {
+	svc.retention.mu.Lock()
+	svc.retention.defaults = retention
+	svc.retention.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		svc.applyRetention(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (svc *Service) applyRetention(ctx context.Context, now time.Time) {
+	for _, signal := range retentionSignals {
+		for _, t := range svc.retention.targets(signal) {
+			if t.keep <= 0 {
+				continue
+			}
+			cutoff := now.Add(-t.keep)
+			if signal == model.SignalMetrics {
+				// Raw points are only expired once rolled up.
+				if covered, ok := svc.rollupCoverage(); ok {
+					if covered.IsZero() {
+						continue
+					}
+					if covered.Before(cutoff) {
+						cutoff = covered
+					}
+				}
+			}
+			n, err := svc.store.DeleteOlderThan(ctx, signal, t.scope, cutoff)
+			if err != nil {
+				log.Printf("retention: delete failed signal=%s%s: %v", signal, describeSelector(t.scope.RetentionSelector), err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("retention: deleted signal=%s%s count=%d older_than=%s", signal, describeSelector(t.scope.RetentionSelector), n, t.keep)
+			}
+		}
+	}
+}
+
+// targets lists what each rule of signal expires, the signal-wide one
+// last. Each leaves out the data of the more specific rules.
+func (p *retentionPolicy) targets(signal model.Signal) []retentionTarget {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var rules []retentionRule
+	signalWide := retentionRule{keep: p.defaults[signal]}
+	for _, r := range p.rules {
+		if r.Signal != signal {
+			continue
+		}
+		if r.Service == "" && r.Level == "" {
+			signalWide = r
+			continue
+		}
+		rules = append(rules, r)
+	}
+	rules = append(rules, signalWide)
+
+	out := make([]retentionTarget, 0, len(rules))
+	for _, r := range rules {
+		sel := selectorOf(r.RetentionRule)
+		t := retentionTarget{scope: model.RetentionScope{RetentionSelector: sel}, keep: r.keep}
+		for _, other := range rules {
+			o := selectorOf(other.RetentionRule)
+			if specificity(o) > specificity(sel) && overlaps(o, sel) {
+				t.scope.Except = append(t.scope.Except, o)
+			}
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// signalWideScope is the data of signal no rule but the signal-wide one
+// covers; overridden reports whether there is such a rule.
+func (p *retentionPolicy) signalWideScope(signal model.Signal) (scope model.RetentionScope, overridden bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range p.rules {
+		if r.Signal != signal {
+			continue
+		}
+		if r.Service == "" && r.Level == "" {
+			overridden = true
+			continue
+		}
+		scope.Except = append(scope.Except, selectorOf(r.RetentionRule))
+	}
+	return scope, overridden
+}
+
+func selectorOf(r model.RetentionRule) model.RetentionSelector {
+	return model.RetentionSelector{Service: r.Service, Level: r.Level}
+}
+
+// specificity ranks selectors: service and level, service, level, none.
+func specificity(sel model.RetentionSelector) int {
+	n := 0
+	if sel.Service != "" {
+		n += 2
+	}
+	if sel.Level != "" {
+		n++
+	}
+	return n
+}
+
+// overlaps reports whether some item matches both a and b.
+func overlaps(a, b model.RetentionSelector) bool {
+	return (a.Service == "" || b.Service == "" || a.Service == b.Service) &&
+		(a.Level == "" || b.Level == "" || strings.EqualFold(a.Level, b.Level))
+}
+
+func describeSelector(sel model.RetentionSelector) string {
+	var s string
+	if sel.Service != "" {
+		s += " service=" + sel.Service
+	}
+	if sel.Level != "" {
+		s += " level=" + sel.Level
+	}
+	return s
+}
+
+// HandleGetRetention handles GET /v1/retention
+func (svc *Service) HandleGetRetention(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, svc.retentionSnapshot())
+}
+
+// HandlePutRetention handles PUT /v1/retention
+//
+// It replaces the retention rules. They apply from the next retention
+// pass.
+func (svc *Service) HandlePutRetention(w http.ResponseWriter, r *http.Request) {
+	var policy model.RetentionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	rules, err := validateRetentionRules(policy.Rules)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	svc.retention.mu.Lock()
+	svc.retention.rules = rules
+	svc.retention.updatedAt = time.Now().UTC()
+	svc.retention.mu.Unlock()
+	respondJSON(w, http.StatusOK, svc.retentionSnapshot())
+}
+
+func (svc *Service) retentionSnapshot() model.RetentionPolicy {
+	p := svc.retention
+	p.mu.Lock()
+	defaults := map[model.Signal]time.Duration{}
+	for signal, keep := range p.defaults {
+		defaults[signal] = keep
+	}
+	policy := model.RetentionPolicy{Defaults: map[model.Signal]string{}, Rules: make([]model.RetentionRule, 0, len(p.rules))}
+	for _, r := range p.rules {
+		policy.Rules = append(policy.Rules, r.RetentionRule)
+	}
+	if !p.updatedAt.IsZero() {
+		updated := p.updatedAt
+		policy.UpdatedAt = &updated
+	}
+	p.mu.Unlock()
+
+	// With rollups, the rollup worker expires raw metric points.
+	svc.rollups.mu.Lock()
+	if svc.rollups.enabled {
+		defaults[model.SignalMetrics] = svc.rollups.retention[model.ResolutionRaw]
+	}
+	svc.rollups.mu.Unlock()
+	for signal, keep := range defaults {
+		if keep > 0 {
+			policy.Defaults[signal] = formatRetention(keep)
+		}
+	}
+	return policy
+}
+
+// validateRetentionRules parses the retention of rules and rejects rules
+// of unknown signals, log levels on other signals and duplicates.
+func validateRetentionRules(rules []model.RetentionRule) ([]retentionRule, error) {
+	out := make([]retentionRule, 0, len(rules))
+	seen := map[string]bool{}
+	for _, r := range rules {
+		switch r.Signal {
+		case model.SignalLogs, model.SignalMetrics, model.SignalSpans:
+		default:
+			return nil, fmt.Errorf("unknown signal %q", r.Signal)
+		}
+		if r.Level != "" && r.Signal != model.SignalLogs {
+			return nil, errors.New("level only applies to logs")
+		}
+		keep, err := parseWindow(r.Retention)
+		if err != nil || keep < 0 {
+			return nil, fmt.Errorf("invalid retention %q", r.Retention)
+		}
+		key := string(r.Signal) + "\x00" + r.Service + "\x00" + strings.ToLower(r.Level)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate rule for signal=%s%s", r.Signal, describeSelector(selectorOf(r)))
+		}
+		seen[key] = true
+		out = append(out, retentionRule{RetentionRule: r, keep: keep})
+	}
+	return out, nil
+}
+
+func formatRetention(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	// rollupDelay leaves late points time to arrive before the interval
+	// they fall in is rolled up.
+	rollupDelay = 30 * time.Second
+	// rollupChunk is how many intervals are rolled up per source query.
+	rollupChunk = 60
+	// maxRollupSourcePoints caps the points read for one chunk.
+	maxRollupSourcePoints = 1000000
+	// defaultRollupLookback is how far back the first rollup starts when
+	// raw points are kept forever.
+	defaultRollupLookback = 24 * time.Hour
+)
+
+// rollupTiers are all metric tiers, from raw points to the coarsest rollup.
+var rollupTiers = append([]model.Resolution{model.ResolutionRaw}, model.RollupResolutions...)
+
+// rollupState tracks the rollup tiers. watermark is the end of the rolled up
+// span of each tier: every interval before it has been computed.
+type rollupState struct {
+	mu        sync.Mutex
+	enabled   bool
+	retention map[model.Resolution]time.Duration
+	watermark map[model.Resolution]time.Time
+}
+
+func newRollupState() *rollupState {
+	return &rollupState{watermark: map[model.Resolution]time.Time{}}
+}
+
+// EnableRollups turns on metric downsampling with the given retention for
+// raw points and each rollup tier; a tier without a positive retention is
+// kept forever. Queries then read from the tier suited to their range.
+func (svc *Service) EnableRollups(retention map[model.Resolution]time.Duration) {
+	svc.rollups.mu.Lock()
+	svc.rollups.enabled = true
+	svc.rollups.retention = retention
+	svc.rollups.mu.Unlock()
+}
+
+// RunRollups rolls metrics up every interval until ctx is done.
+func (svc *Service) RunRollups(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := svc.RollupMetrics(ctx, time.Now().UTC()); err != nil {
+			log.Printf("rollup: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RollupMetrics computes the rollup intervals completed by now, tier by
+// tier, then expires raw points and rollups past their retention. Data is
+// only expired once the next tier covers it.
+func (svc *Service) RollupMetrics(ctx context.Context, now time.Time) error {
+	rs := svc.rollups
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !rs.enabled {
+		return nil
+	}
+
+	for i, res := range model.RollupResolutions {
+		if err := svc.rollupTier(ctx, rollupTiers[i], res, now); err != nil {
+			return err
+		}
+	}
+
+	for i, tier := range rollupTiers {
+		keep := rs.retention[tier]
+		if keep <= 0 {
+			continue
+		}
+		cutoff := now.Add(-keep)
+		if i+1 < len(rollupTiers) {
+			covered := rs.watermark[rollupTiers[i+1]]
+			if covered.IsZero() {
+				continue
+			}
+			if covered.Before(cutoff) {
+				cutoff = covered
+			}
+		}
+		var n int64
+		var err error
+		if tier == model.ResolutionRaw {
+			// Raw points covered by retention rules are expired by the
+			// retention worker instead.
+			scope, overridden := svc.retention.signalWideScope(model.SignalMetrics)
+			if overridden {
+				continue
+			}
+			n, err = svc.store.DeleteOlderThan(ctx, model.SignalMetrics, scope, cutoff)
+		} else {
+			n, err = svc.store.DeleteRollupsOlderThan(ctx, tier, cutoff)
+		}
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("rollup: expired resolution=%s count=%d before=%s", tier, n, cutoff.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// rollupCoverage is the time up to which raw metric points are rolled up,
+// so may be expired; ok is false when rollups are disabled.
+func (svc *Service) rollupCoverage() (covered time.Time, ok bool) {
+	rs := svc.rollups
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !rs.enabled {
+		return time.Time{}, false
+	}
+	return rs.watermark[model.RollupResolutions[0]], true
+}
+
+// rollupTier computes the intervals of res from the source tier up to now,
+// or up to what the source tier covers. The caller holds rs.mu.
+func (svc *Service) rollupTier(ctx context.Context, source, res model.Resolution, now time.Time) error {
+	rs := svc.rollups
+	d := res.Duration()
+	start := rs.watermark[res]
+	if start.IsZero() {
+		latest, err := svc.store.LatestRollup(ctx, res)
+		if err != nil {
+			return err
+		}
+		if latest.IsZero() {
+			// Every tier first backfills as far back as raw points go.
+			lookback := rs.retention[model.ResolutionRaw]
+			if lookback <= 0 {
+				lookback = defaultRollupLookback
+			}
+			start = now.Add(-lookback).Truncate(d)
+		} else {
+			start = latest.Add(d)
+		}
+	}
+	end := now.Add(-rollupDelay).Truncate(d)
+	if source != model.ResolutionRaw && rs.watermark[source].Before(end) {
+		end = rs.watermark[source].Truncate(d)
+	}
+
+	for start.Before(end) {
+		chunkEnd := start.Add(rollupChunk * d)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		query := model.MetricQuery{StartTime: start, EndTime: chunkEnd.Add(-time.Nanosecond), Limit: maxRollupSourcePoints}
+		var points []model.MetricEntry
+		var err error
+		if source == model.ResolutionRaw {
+			points, err = svc.store.QueryMetrics(ctx, query)
+		} else {
+			points, err = svc.store.QueryRollups(ctx, source, query)
+		}
+		if err != nil {
+			return err
+		}
+		if len(points) >= maxRollupSourcePoints {
+			log.Printf("rollup: resolution=%s from=%s read the maximum of %d points; later points are left out",
+				res, start.Format(time.RFC3339), maxRollupSourcePoints)
+		}
+		if err := svc.store.UpsertRollups(ctx, res, buildRollups(points, res)); err != nil {
+			return err
+		}
+		start = chunkEnd
+		rs.watermark[res] = start
+	}
+	if rs.watermark[res].IsZero() {
+		rs.watermark[res] = start
+	}
+	return nil
+}
+
+// buildRollups merges points, raw or rollups of a finer tier, into one
+// rollup point per series and interval of res.
+func buildRollups(points []model.MetricEntry, res model.Resolution) []model.MetricEntry {
+	d := res.Duration()
+	rollups := map[string]*model.MetricEntry{}
+	latest := map[string]time.Time{}
+	for _, p := range points {
+		bucket := p.Timestamp.Truncate(d)
+		key := rollupKey(res, p, bucket)
+		r := rollups[key]
+		if r == nil {
+			r = &model.MetricEntry{
+				ID:        key,
+				Timestamp: bucket,
+				Name:      p.Name,
+				Type:      p.Type,
+				Service:   p.Service,
+				Labels:    p.Labels,
+				Rollup:    &model.RollupData{Resolution: res},
+			}
+			rollups[key] = r
+		}
+		mergeRollup(r.Rollup, p)
+		if t, seen := latest[key]; !seen || !p.Timestamp.Before(t) {
+			latest[key] = p.Timestamp
+			r.Value = p.Value
+			r.Histogram = p.Histogram
+		}
+	}
+
+	out := make([]model.MetricEntry, 0, len(rollups))
+	for _, r := range rollups {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func mergeRollup(r *model.RollupData, p model.MetricEntry) {
+	count, sum, lo, hi := uint64(1), p.Value, p.Value, p.Value
+	var obs *model.HistogramData
+	switch {
+	case p.Rollup != nil:
+		count, sum, lo, hi = p.Rollup.Count, p.Rollup.Sum, p.Rollup.Min, p.Rollup.Max
+		obs = p.Rollup.Observations
+	case p.Type == model.MetricTypeHistogram && p.Histogram == nil:
+		obs = &model.HistogramData{
+			Count:          1,
+			Sum:            p.Value,
+			ExplicitBounds: defaultBuckets,
+			BucketCounts:   make([]uint64, len(defaultBuckets)+1),
+		}
+		obs.BucketCounts[sort.SearchFloat64s(defaultBuckets, p.Value)] = 1
+	}
+	if r.Count == 0 || lo < r.Min {
+		r.Min = lo
+	}
+	if r.Count == 0 || hi > r.Max {
+		r.Max = hi
+	}
+	r.Count += count
+	r.Sum += sum
+
+	if obs == nil {
+		return
+	}
+	if r.Observations == nil {
+		r.Observations = &model.HistogramData{
+			ExplicitBounds: obs.ExplicitBounds,
+			BucketCounts:   make([]uint64, len(obs.BucketCounts)),
+		}
+	}
+	if !slices.Equal(r.Observations.ExplicitBounds, obs.ExplicitBounds) || len(r.Observations.BucketCounts) != len(obs.BucketCounts) {
+		return
+	}
+	r.Observations.Count += obs.Count
+	r.Observations.Sum += obs.Sum
+	for i, c := range obs.BucketCounts {
+		r.Observations.BucketCounts[i] += c
+	}
+}
+
+// rollupKey identifies the rollup of p's series for the interval starting
+// at bucket, so recomputing an interval replaces it.
+func rollupKey(res model.Resolution, p model.MetricEntry, bucket time.Time) string {
+	h := sha256.New()
+	h.Write([]byte(string(res) + "\x00" + p.Name + "\x00" + p.Service + "\x00" + string(p.Type) + "\x00"))
+	h.Write([]byte(seriesKey(strconv.FormatInt(bucket.Unix(), 10), p.Labels)))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// resolutionFor picks the tier to answer a query starting at from in steps
+// of step: the coarsest tier no coarser than step among those still
+// holding data at from, or the finest of those if all are coarser. An open
+// start counts as now. Without rollups it is always raw.
+func (svc *Service) resolutionFor(from time.Time, step time.Duration, now time.Time) model.Resolution {
+	rs := svc.rollups
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !rs.enabled {
+		return model.ResolutionRaw
+	}
+	if from.IsZero() {
+		from = now
+	}
+	best := model.Resolution("")
+	for _, tier := range rollupTiers {
+		keep := rs.retention[tier]
+		if keep > 0 && from.Before(now.Add(-keep)) {
+			continue
+		}
+		if best == "" || tier.Duration() <= step {
+			best = tier
+		}
+	}
+	if best == "" {
+		return rollupTiers[len(rollupTiers)-1]
+	}
+	return best
+}
+
+// queryResolution reads query from res's tier.
+func (svc *Service) queryResolution(ctx context.Context, res model.Resolution, query model.MetricQuery) ([]model.MetricEntry, error) {
+	if res == model.ResolutionRaw {
+		return svc.store.QueryMetrics(ctx, query)
+	}
+	return svc.store.QueryRollups(ctx, res, query)
+}
+
+// parseResolution reads the resolution parameter: raw, a rollup tier or
+// auto, which picks one with resolutionFor. def applies when it is empty.
+func (svc *Service) parseResolution(v string, def string, from time.Time, step time.Duration) (model.Resolution, bool) {
+	if v == "" {
+		v = def
+	}
+	if v == "auto" {
+		return svc.resolutionFor(from, step, time.Now().UTC()), true
+	}
+	res := model.Resolution(v)
+	return res, slices.Contains(rollupTiers, res)
+}
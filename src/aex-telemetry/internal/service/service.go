@@ -1,9 +1,14 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
@@ -11,46 +16,83 @@ import (
 )
 
 type Service struct {
-	store *store.MemoryStore
+	store       store.Store
+	prom        *promRegistry
+	alerts      *alertManager
+	tail        *logTail
+	rollups     *rollupState
+	exports     *exportManager
+	deadLetters *deadLetterBuffer
+	slos        *sloManager
+	cardinality *cardinalityGuard
+	consumer    *eventConsumer
+	tenancy     *tenancy
+	probes      *prober
+	retention   *retentionPolicy
 }
 
-func New(s *store.MemoryStore) *Service {
-	return &Service{store: s}
+func New(s store.Store) *Service {
+	return &Service{
+		store:       s,
+		prom:        newPromRegistry(),
+		alerts:      newAlertManager(),
+		tail:        newLogTail(),
+		rollups:     newRollupState(),
+		exports:     newExportManager(),
+		deadLetters: newDeadLetterBuffer(),
+		slos:        newSLOManager(),
+		cardinality: newCardinalityGuard(),
+		consumer:    &eventConsumer{},
+		tenancy:     &tenancy{},
+		probes:      newProber(),
+		retention:   newRetentionPolicy(),
+	}
 }
 
 // HandleIngestLogs handles POST /v1/logs
+//
+// Malformed entries are rejected to the dead-letter buffer while the rest
+// of the batch is stored.
 func (svc *Service) HandleIngestLogs(w http.ResponseWriter, r *http.Request) {
-	var entries []model.LogEntry
-	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
-		// Try single entry
-		var entry model.LogEntry
-		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
-			respondError(w, http.StatusBadRequest, "invalid request body")
-			return
-		}
-		entries = []model.LogEntry{entry}
-	}
-
-	for _, entry := range entries {
-		if err := svc.store.AddLog(entry); err != nil {
-			respondError(w, http.StatusInternalServerError, "failed to store log")
-			return
-		}
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"accepted": len(entries),
-	})
+	ingestBatch(svc, w, r, model.SignalLogs, validateLog, svc.addLog)
 }
 
 // HandleQueryLogs handles GET /v1/logs
+//
+// q is a full-text search over messages and each field.<key>=value
+// parameter filters on a structured field. The response includes facet
+// counts by level and service over all matching logs.
 func (svc *Service) HandleQueryLogs(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := svc.callerTenant(w, r)
+	if !ok {
+		return
+	}
 	query := model.LogQuery{
 		Service: r.URL.Query().Get("service"),
 		Level:   r.URL.Query().Get("level"),
 		Search:  r.URL.Query().Get("search"),
+		Text:    r.URL.Query().Get("q"),
+	}
+
+	for key, values := range r.URL.Query() {
+		field, ok := strings.CutPrefix(key, "field.")
+		if !ok {
+			continue
+		}
+		if field == "" {
+			respondError(w, http.StatusBadRequest, "field filter requires a key")
+			return
+		}
+		if query.Fields == nil {
+			query.Fields = map[string]string{}
+		}
+		query.Fields[field] = values[0]
+	}
+	if tenant != "" {
+		if query.Fields == nil {
+			query.Fields = map[string]string{}
+		}
+		query.Fields[TenantKey] = tenant
 	}
 
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -59,19 +101,19 @@ func (svc *Service) HandleQueryLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if startStr := r.URL.Query().Get("start_time"); startStr != "" {
-		if t, err := time.Parse(time.RFC3339, startStr); err == nil {
-			query.StartTime = t
-		}
+	from, to, err := parseTimeRange(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	query.StartTime, query.EndTime = from, to
 
-	if endStr := r.URL.Query().Get("end_time"); endStr != "" {
-		if t, err := time.Parse(time.RFC3339, endStr); err == nil {
-			query.EndTime = t
-		}
+	logs, err := svc.store.QueryLogs(r.Context(), query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "query failed")
+		return
 	}
-
-	logs, err := svc.store.QueryLogs(query)
+	facets, err := svc.store.LogFacets(r.Context(), query)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "query failed")
 		return
@@ -79,38 +121,36 @@ func (svc *Service) HandleQueryLogs(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
-		"logs":  logs,
-		"count": len(logs),
+		"logs":   logs,
+		"count":  len(logs),
+		"facets": facets,
 	})
 }
 
 // HandleIngestMetrics handles POST /v1/metrics
+//
+// Malformed points are rejected to the dead-letter buffer while the rest
+// of the batch is stored.
 func (svc *Service) HandleIngestMetrics(w http.ResponseWriter, r *http.Request) {
-	var entries []model.MetricEntry
-	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request body")
-		return
-	}
-
-	for _, entry := range entries {
-		if err := svc.store.AddMetric(entry); err != nil {
-			respondError(w, http.StatusInternalServerError, "failed to store metric")
-			return
-		}
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"accepted": len(entries),
+	now := time.Now()
+	ingestBatch(svc, w, r, model.SignalMetrics, validateMetric, func(ctx context.Context, entry model.MetricEntry) error {
+		return svc.addMetric(ctx, entry, now)
 	})
 }
 
 // HandleQueryMetrics handles GET /v1/metrics
+//
+// resolution selects raw points (the default), a rollup tier, or auto for
+// the finest tier still holding data at from.
 func (svc *Service) HandleQueryMetrics(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := svc.callerTenant(w, r)
+	if !ok {
+		return
+	}
 	query := model.MetricQuery{
 		Name:    r.URL.Query().Get("name"),
 		Service: r.URL.Query().Get("service"),
+		Labels:  tenantFilter(tenant),
 	}
 
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -119,19 +159,19 @@ func (svc *Service) HandleQueryMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if startStr := r.URL.Query().Get("start_time"); startStr != "" {
-		if t, err := time.Parse(time.RFC3339, startStr); err == nil {
-			query.StartTime = t
-		}
+	from, to, err := parseTimeRange(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	query.StartTime, query.EndTime = from, to
 
-	if endStr := r.URL.Query().Get("end_time"); endStr != "" {
-		if t, err := time.Parse(time.RFC3339, endStr); err == nil {
-			query.EndTime = t
-		}
+	res, ok := svc.parseResolution(r.URL.Query().Get("resolution"), string(model.ResolutionRaw), from, 0)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "resolution must be one of raw, 1m, 5m, 1h, auto")
+		return
 	}
-
-	metrics, err := svc.store.QueryMetrics(query)
+	metrics, err := svc.queryResolution(r.Context(), res, query)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "query failed")
 		return
@@ -139,35 +179,26 @@ func (svc *Service) HandleQueryMetrics(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
-		"metrics": metrics,
-		"count":   len(metrics),
+		"metrics":    metrics,
+		"count":      len(metrics),
+		"resolution": res,
 	})
 }
 
 // HandleIngestSpans handles POST /v1/spans
+//
+// Malformed spans are rejected to the dead-letter buffer while the rest of
+// the batch is stored.
 func (svc *Service) HandleIngestSpans(w http.ResponseWriter, r *http.Request) {
-	var spans []model.TraceSpan
-	if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request body")
-		return
-	}
-
-	for _, span := range spans {
-		if err := svc.store.AddSpan(span); err != nil {
-			respondError(w, http.StatusInternalServerError, "failed to store span")
-			return
-		}
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"accepted": len(spans),
-	})
+	ingestBatch(svc, w, r, model.SignalSpans, validateSpan, svc.store.AddSpan)
 }
 
 // HandleGetTrace handles GET /v1/traces/{trace_id}
 func (svc *Service) HandleGetTrace(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := svc.callerTenant(w, r)
+	if !ok {
+		return
+	}
 	// Extract trace_id from path
 	traceID := r.PathValue("trace_id")
 	if traceID == "" {
@@ -175,11 +206,12 @@ func (svc *Service) HandleGetTrace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	spans, err := svc.store.GetTraceSpans(traceID)
+	spans, err := svc.store.GetTraceSpans(r.Context(), traceID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "query failed")
 		return
 	}
+	spans = spansOfTenant(spans, tenant)
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
@@ -191,12 +223,57 @@ func (svc *Service) HandleGetTrace(w http.ResponseWriter, r *http.Request) {
 
 // HandleGetStats handles GET /v1/stats
 func (svc *Service) HandleGetStats(w http.ResponseWriter, r *http.Request) {
-	stats := svc.store.GetStats()
+	stats, err := svc.store.GetStats(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(stats)
 }
 
+// parseTimeRange reads the from and to query parameters (start_time and
+// end_time are accepted as older names). Either may be omitted; a zero time
+// leaves that side of the range open.
+func parseTimeRange(q url.Values) (from, to time.Time, err error) {
+	if from, err = parseTimeParam(q, "from", "start_time"); err != nil {
+		return
+	}
+	if to, err = parseTimeParam(q, "to", "end_time"); err != nil {
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		err = errors.New("from must not be after to")
+	}
+	return
+}
+
+// parseTimeParam parses the first of names that is set, as RFC 3339 or
+// Unix seconds.
+func parseTimeParam(q url.Values, names ...string) (time.Time, error) {
+	for _, name := range names {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC(), nil
+		}
+		return time.Time{}, fmt.Errorf("invalid %s: expected RFC 3339 time or Unix seconds", name)
+	}
+	return time.Time{}, nil
+}
+
+func respondJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
 func respondError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	defaultServiceMapWindow = time.Hour
+	maxServiceMapWindow     = 7 * 24 * time.Hour
+	// maxServiceMapSpans caps the spans read to build one map.
+	maxServiceMapSpans = 100000
+)
+
+// ServiceNode is a service that reported spans in the window.
+type ServiceNode struct {
+	Service   string  `json:"service"`
+	SpanCount int     `json:"span_count"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// ServiceEdge aggregates the calls from Source to Target: spans of Target
+// whose parent span belongs to Source.
+type ServiceEdge struct {
+	Source       string  `json:"source"`
+	Target       string  `json:"target"`
+	CallCount    int     `json:"call_count"`
+	ErrorRate    float64 `json:"error_rate"`
+	P95LatencyMs int64   `json:"p95_latency_ms"`
+}
+
+type edgeKey struct{ source, target string }
+
+type edgeStats struct {
+	errors    int
+	durations []int64
+}
+
+// HandleServiceMap handles GET /v1/service-map
+//
+// It builds the service dependency graph from the spans started within
+// window (1h by default). A call is only seen when its parent span is
+// also in the window.
+func (svc *Service) HandleServiceMap(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := svc.callerTenant(w, r)
+	if !ok {
+		return
+	}
+	window := defaultServiceMapWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 || d > maxServiceMapWindow {
+			respondError(w, http.StatusBadRequest, "window must be a positive duration of at most 168h")
+			return
+		}
+		window = d
+	}
+	to := time.Now().UTC()
+	from := to.Add(-window)
+
+	spans, err := svc.store.QuerySpans(r.Context(), model.SpanQuery{
+		Attributes: tenantFilter(tenant),
+		StartTime:  from,
+		EndTime:    to,
+		Limit:      maxServiceMapSpans,
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+
+	type spanRef struct{ traceID, spanID string }
+	owner := make(map[spanRef]string, len(spans))
+	nodes := map[string]*ServiceNode{}
+	for _, s := range spans {
+		owner[spanRef{s.TraceID, s.SpanID}] = s.Service
+		n := nodes[s.Service]
+		if n == nil {
+			n = &ServiceNode{Service: s.Service}
+			nodes[s.Service] = n
+		}
+		n.SpanCount++
+		if isErrorStatus(s.Status) {
+			n.ErrorRate++
+		}
+	}
+
+	edges := map[edgeKey]*edgeStats{}
+	for _, s := range spans {
+		if s.ParentSpanID == "" {
+			continue
+		}
+		parent, ok := owner[spanRef{s.TraceID, s.ParentSpanID}]
+		if !ok || parent == s.Service {
+			continue
+		}
+		k := edgeKey{parent, s.Service}
+		e := edges[k]
+		if e == nil {
+			e = &edgeStats{}
+			edges[k] = e
+		}
+		e.durations = append(e.durations, s.DurationMs)
+		if isErrorStatus(s.Status) {
+			e.errors++
+		}
+	}
+
+	outNodes := make([]ServiceNode, 0, len(nodes))
+	for _, n := range nodes {
+		n.ErrorRate /= float64(n.SpanCount)
+		outNodes = append(outNodes, *n)
+	}
+	sort.Slice(outNodes, func(i, j int) bool { return outNodes[i].Service < outNodes[j].Service })
+
+	outEdges := make([]ServiceEdge, 0, len(edges))
+	for k, e := range edges {
+		outEdges = append(outEdges, ServiceEdge{
+			Source:       k.source,
+			Target:       k.target,
+			CallCount:    len(e.durations),
+			ErrorRate:    float64(e.errors) / float64(len(e.durations)),
+			P95LatencyMs: percentile(e.durations, 0.95),
+		})
+	}
+	sort.Slice(outEdges, func(i, j int) bool {
+		if outEdges[i].Source != outEdges[j].Source {
+			return outEdges[i].Source < outEdges[j].Source
+		}
+		return outEdges[i].Target < outEdges[j].Target
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"window":    window.String(),
+		"from":      from,
+		"to":        to,
+		"nodes":     outNodes,
+		"edges":     outEdges,
+		"truncated": len(spans) >= maxServiceMapSpans,
+	})
+}
+
+func isErrorStatus(status string) bool {
+	return strings.EqualFold(status, "error")
+}
+
+// percentile is the nearest-rank q-percentile of values, which it sorts.
+func percentile(values []int64, q float64) int64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := int(math.Ceil(q * float64(len(values))))
+	return values[max(rank-1, 0)]
+}
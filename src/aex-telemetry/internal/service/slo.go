@@ -0,0 +1,485 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	defaultSLOWindow = "30d"
+	maxSLOWindow     = 90 * 24 * time.Hour
+	// maxSLOPoints caps the points read for one metric of one evaluation.
+	maxSLOPoints = 100000
+	// sloResolutionSteps is how many rollup intervals an evaluated window
+	// spans at least, so recent points not rolled up yet weigh little.
+	sloResolutionSteps = 12
+	// sloAtRiskBudget is the remaining error budget below which an SLO is
+	// at risk.
+	sloAtRiskBudget = 0.25
+)
+
+// defaultBurnRateWindows are the lookbacks of the usual multiwindow
+// burn-rate alerts.
+var defaultBurnRateWindows = []string{"5m", "30m", "1h", "6h", "24h", "3d"}
+
+// sloHealthRank orders health from best to worst, for a service's overall
+// status.
+var sloHealthRank = map[model.SLOHealth]int{
+	model.SLONoData:    0,
+	model.SLOHealthy:   1,
+	model.SLOAtRisk:    2,
+	model.SLOExhausted: 3,
+}
+
+var errSLONotFound = errors.New("slo not found")
+
+// sloManager holds the SLO definitions. Their status is computed from the
+// stored metrics when requested.
+type sloManager struct {
+	mu   sync.Mutex
+	slos map[string]*model.SLO
+}
+
+func newSLOManager() *sloManager {
+	return &sloManager{slos: map[string]*model.SLO{}}
+}
+
+// ServiceSLOStatus is the error-budget status of a service's SLOs; Status
+// is the worst of them.
+type ServiceSLOStatus struct {
+	Service string            `json:"service"`
+	Status  model.SLOHealth   `json:"status"`
+	SLOs    []model.SLOStatus `json:"slos"`
+}
+
+// HandleCreateSLO handles POST /v1/slos
+func (svc *Service) HandleCreateSLO(w http.ResponseWriter, r *http.Request) {
+	var slo model.SLO
+	if err := json.NewDecoder(r.Body).Decode(&slo); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validateSLO(&slo); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	slo.ID = newID()
+	slo.CreatedAt = now
+	slo.UpdatedAt = now
+
+	svc.slos.mu.Lock()
+	svc.slos.slos[slo.ID] = &slo
+	svc.slos.mu.Unlock()
+
+	respondJSON(w, http.StatusCreated, slo)
+}
+
+// HandleListSLOs handles GET /v1/slos
+func (svc *Service) HandleListSLOs(w http.ResponseWriter, r *http.Request) {
+	slos := svc.slos.snapshot(r.URL.Query().Get("service"))
+	respondJSON(w, http.StatusOK, map[string]any{
+		"slos":  slos,
+		"count": len(slos),
+	})
+}
+
+// HandleGetSLO handles GET /v1/slos/{id}
+func (svc *Service) HandleGetSLO(w http.ResponseWriter, r *http.Request) {
+	slo, ok := svc.slos.get(r.PathValue("id"))
+	if !ok {
+		respondError(w, http.StatusNotFound, errSLONotFound.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, slo)
+}
+
+// HandleUpdateSLO handles PUT /v1/slos/{id}
+func (svc *Service) HandleUpdateSLO(w http.ResponseWriter, r *http.Request) {
+	var slo model.SLO
+	if err := json.NewDecoder(r.Body).Decode(&slo); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validateSLO(&slo); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	svc.slos.mu.Lock()
+	existing, ok := svc.slos.slos[r.PathValue("id")]
+	if ok {
+		slo.ID = existing.ID
+		slo.CreatedAt = existing.CreatedAt
+		slo.UpdatedAt = time.Now().UTC()
+		*existing = slo
+	}
+	svc.slos.mu.Unlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errSLONotFound.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, slo)
+}
+
+// HandleDeleteSLO handles DELETE /v1/slos/{id}
+func (svc *Service) HandleDeleteSLO(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	svc.slos.mu.Lock()
+	_, ok := svc.slos.slos[id]
+	delete(svc.slos.slos, id)
+	svc.slos.mu.Unlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errSLONotFound.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetSLOStatus handles GET /v1/slos/{id}/status
+//
+// It reports the SLO's compliance and error budget over its window.
+func (svc *Service) HandleGetSLOStatus(w http.ResponseWriter, r *http.Request) {
+	slo, ok := svc.slos.get(r.PathValue("id"))
+	if !ok {
+		respondError(w, http.StatusNotFound, errSLONotFound.Error())
+		return
+	}
+	status, err := svc.sloStatus(r.Context(), slo, time.Now().UTC())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	respondJSON(w, http.StatusOK, status)
+}
+
+// HandleListSLOStatus handles GET /v1/slos/status
+//
+// It reports the error-budget status of every SLO grouped by service,
+// optionally only for one service.
+func (svc *Service) HandleListSLOStatus(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	byService := map[string]*ServiceSLOStatus{}
+	for _, slo := range svc.slos.snapshot(r.URL.Query().Get("service")) {
+		status, err := svc.sloStatus(r.Context(), slo, now)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		s := byService[slo.Service]
+		if s == nil {
+			s = &ServiceSLOStatus{Service: slo.Service, Status: status.Status}
+			byService[slo.Service] = s
+		}
+		if sloHealthRank[status.Status] > sloHealthRank[s.Status] {
+			s.Status = status.Status
+		}
+		s.SLOs = append(s.SLOs, status)
+	}
+
+	services := make([]ServiceSLOStatus, 0, len(byService))
+	for _, s := range byService {
+		services = append(services, *s)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+	respondJSON(w, http.StatusOK, map[string]any{
+		"services":     services,
+		"evaluated_at": now,
+	})
+}
+
+// HandleGetSLOBurnRate handles GET /v1/slos/{id}/burn-rate
+//
+// It reports the SLO's burn rate over each of the comma-separated windows
+// (5m, 30m, 1h, 6h, 24h and 3d by default).
+func (svc *Service) HandleGetSLOBurnRate(w http.ResponseWriter, r *http.Request) {
+	slo, ok := svc.slos.get(r.PathValue("id"))
+	if !ok {
+		respondError(w, http.StatusNotFound, errSLONotFound.Error())
+		return
+	}
+	windows := defaultBurnRateWindows
+	if s := r.URL.Query().Get("windows"); s != "" {
+		windows = strings.Split(s, ",")
+	}
+	lookbacks := make([]time.Duration, len(windows))
+	for i, s := range windows {
+		d, err := parseWindow(s)
+		if err != nil || d <= 0 || d > maxSLOWindow {
+			respondError(w, http.StatusBadRequest, "windows must be positive durations of at most 90d")
+			return
+		}
+		lookbacks[i] = d
+	}
+
+	now := time.Now().UTC()
+	budget := 1 - slo.Objective
+	rates := make([]model.BurnRate, 0, len(windows))
+	for i, d := range lookbacks {
+		good, total, truncated, err := svc.sloEvents(r.Context(), slo, now.Add(-d), now)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		rate := model.BurnRate{Window: windows[i], GoodEvents: good, TotalEvents: total, Truncated: truncated}
+		if total > 0 {
+			rate.ErrorRate = (total - good) / total
+			rate.BurnRate = rate.ErrorRate / budget
+		}
+		rates = append(rates, rate)
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"slo_id":       slo.ID,
+		"objective":    slo.Objective,
+		"error_budget": budget,
+		"burn_rates":   rates,
+		"evaluated_at": now,
+	})
+}
+
+// sloStatus evaluates slo over its window ending at now.
+func (svc *Service) sloStatus(ctx context.Context, slo model.SLO, now time.Time) (model.SLOStatus, error) {
+	window, _ := parseWindow(slo.Window)
+	good, total, truncated, err := svc.sloEvents(ctx, slo, now.Add(-window), now)
+	if err != nil {
+		return model.SLOStatus{}, err
+	}
+	status := model.SLOStatus{
+		SLOID:       slo.ID,
+		Name:        slo.Name,
+		Service:     slo.Service,
+		Window:      slo.Window,
+		Objective:   slo.Objective,
+		GoodEvents:  good,
+		TotalEvents: total,
+		SLI:         1,
+		ErrorBudget: 1 - slo.Objective,
+		Truncated:   truncated,
+		EvaluatedAt: now,
+	}
+	if total > 0 {
+		status.SLI = good / total
+	}
+	status.BudgetConsumed = (1 - status.SLI) / status.ErrorBudget
+	status.BudgetRemaining = 1 - status.BudgetConsumed
+	switch {
+	case total == 0:
+		status.Status = model.SLONoData
+	case status.BudgetRemaining <= 0:
+		status.Status = model.SLOExhausted
+	case status.BudgetRemaining < sloAtRiskBudget:
+		status.Status = model.SLOAtRisk
+	default:
+		status.Status = model.SLOHealthy
+	}
+	return status, nil
+}
+
+// sloEvents counts the good and all events of slo in [from, to]. Points are
+// read from the rollup tier suited to the range when rollups are enabled.
+func (svc *Service) sloEvents(ctx context.Context, slo model.SLO, from, to time.Time) (good, total float64, truncated bool, err error) {
+	res := svc.resolutionFor(from, to.Sub(from)/sloResolutionSteps, to)
+	read := func(name string, labels ...map[string]string) ([]model.MetricEntry, error) {
+		points, err := svc.queryResolution(ctx, res, model.MetricQuery{
+			Name:      name,
+			Service:   slo.Service,
+			StartTime: from,
+			EndTime:   to,
+			Limit:     maxSLOPoints,
+		})
+		if err != nil {
+			return nil, err
+		}
+		truncated = truncated || len(points) >= maxSLOPoints
+		matched := points[:0]
+	next:
+		for _, p := range points {
+			for _, want := range labels {
+				if !hasLabels(p.Labels, want) {
+					continue next
+				}
+			}
+			matched = append(matched, p)
+		}
+		sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.Before(matched[j].Timestamp) })
+		return matched, nil
+	}
+
+	points, err := read(slo.Metric, slo.Labels)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if slo.Type == model.SLOLatency {
+		good, total = latencyEvents(points, slo.Threshold)
+		return good, total, truncated, nil
+	}
+
+	total = counterTotal(points)
+	goodMetric := slo.GoodMetric
+	if goodMetric == "" {
+		goodMetric = slo.Metric
+	}
+	if points, err = read(goodMetric, slo.Labels, slo.GoodLabels); err != nil {
+		return 0, 0, false, err
+	}
+	// Counters scraped at different times may briefly disagree.
+	good = min(counterTotal(points), total)
+	return good, total, truncated, nil
+}
+
+// counterTotal is the summed increase of the counter series in points,
+// which are sorted by time.
+func counterTotal(points []model.MetricEntry) float64 {
+	var total float64
+	prev := map[string]model.MetricEntry{}
+	for _, p := range points {
+		sk := seriesKey(p.Service, p.Labels)
+		if last, ok := prev[sk]; ok {
+			total += counterIncrease(last, p)
+		}
+		prev[sk] = p
+	}
+	return total
+}
+
+// latencyEvents counts the observations of the histogram points, which are
+// sorted by time, and those of them at or below threshold.
+func latencyEvents(points []model.MetricEntry, threshold float64) (good, total float64) {
+	prev := map[string]model.MetricEntry{}
+	for _, p := range points {
+		if p.Type != model.MetricTypeHistogram {
+			continue
+		}
+		switch {
+		case p.Rollup != nil && p.Rollup.Observations != nil:
+			g, t := bucketsWithin(p.Rollup.Observations.ExplicitBounds, p.Rollup.Observations.BucketCounts, threshold)
+			good, total = good+g, total+t
+		case p.Histogram != nil:
+			sk := seriesKey(p.Service, p.Labels)
+			last, ok := prev[sk]
+			prev[sk] = p
+			if ok {
+				g, t := bucketsWithin(p.Histogram.ExplicitBounds, bucketIncrease(last.Histogram, p.Histogram), threshold)
+				good, total = good+g, total+t
+			}
+		case p.Rollup == nil:
+			total++
+			if p.Value <= threshold {
+				good++
+			}
+		}
+	}
+	return good, total
+}
+
+// bucketsWithin counts the observations of a distribution and estimates
+// how many are at or below threshold, interpolating within the bucket
+// holding it. The overflow bucket is never within a finite threshold.
+func bucketsWithin(bounds []float64, counts []uint64, threshold float64) (good, total float64) {
+	for i, c := range counts {
+		n := float64(c)
+		total += n
+		upper := math.Inf(1)
+		if i < len(bounds) {
+			upper = bounds[i]
+		}
+		lower := math.Min(0, upper)
+		if i > 0 {
+			lower = bounds[i-1]
+		}
+		switch {
+		case upper <= threshold:
+			good += n
+		case lower < threshold && !math.IsInf(upper, 1):
+			good += n * (threshold - lower) / (upper - lower)
+		}
+	}
+	return good, total
+}
+
+func (m *sloManager) get(id string) (model.SLO, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	slo, ok := m.slos[id]
+	if !ok {
+		return model.SLO{}, false
+	}
+	return *slo, true
+}
+
+// snapshot copies the SLOs of service, or all of them, oldest first.
+func (m *sloManager) snapshot(service string) []model.SLO {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	slos := make([]model.SLO, 0, len(m.slos))
+	for _, s := range m.slos {
+		if service == "" || s.Service == service {
+			slos = append(slos, *s)
+		}
+	}
+	sort.Slice(slos, func(i, j int) bool {
+		if !slos[i].CreatedAt.Equal(slos[j].CreatedAt) {
+			return slos[i].CreatedAt.Before(slos[j].CreatedAt)
+		}
+		return slos[i].ID < slos[j].ID
+	})
+	return slos
+}
+
+// validateSLO checks slo and fills in the default window (30d).
+func validateSLO(slo *model.SLO) error {
+	if slo.Name == "" {
+		return errors.New("name required")
+	}
+	if slo.Service == "" {
+		return errors.New("service required")
+	}
+	if slo.Metric == "" {
+		return errors.New("metric required")
+	}
+	switch slo.Type {
+	case model.SLOLatency:
+		if slo.GoodMetric != "" || len(slo.GoodLabels) > 0 {
+			return errors.New("good_metric and good_labels only apply to ratio SLOs")
+		}
+	case model.SLORatio:
+		if slo.GoodMetric == "" && len(slo.GoodLabels) == 0 {
+			return errors.New("ratio SLOs require good_metric or good_labels")
+		}
+	default:
+		return errors.New("type must be latency or ratio")
+	}
+	if !(slo.Objective > 0 && slo.Objective < 1) {
+		return errors.New("objective must be between 0 and 1, exclusive")
+	}
+	if slo.Window == "" {
+		slo.Window = defaultSLOWindow
+	}
+	if d, err := parseWindow(slo.Window); err != nil || d <= 0 || d > maxSLOWindow {
+		return errors.New("window must be a positive duration of at most 90d")
+	}
+	return nil
+}
+
+// parseWindow parses a duration, also accepting a whole number of days
+// such as "30d".
+func parseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.New("invalid window " + s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
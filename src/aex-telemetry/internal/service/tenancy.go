@@ -0,0 +1,121 @@
+package service
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+// TenantHeader carries the calling tenant. The gateway sets it on every
+// request it proxies, replacing any value sent by the client.
+const TenantHeader = "X-Tenant-ID"
+
+// GatewayTokenHeader carries the gateway's upstream token. TenantHeader is
+// only trusted on requests bearing it, that is, proxied by the gateway.
+const GatewayTokenHeader = "X-Gateway-Token"
+
+// TenantKey is the log field, metric label and span attribute holding the
+// tenant telemetry belongs to.
+const TenantKey = "tenant_id"
+
+// tenancy is the tenant scoping of the query APIs; off until enabled.
+type tenancy struct {
+	mu           sync.RWMutex
+	enabled      bool
+	gatewayToken string
+	operators    map[string]bool
+}
+
+// EnableTenantScoping restricts the query APIs to the telemetry tagged with
+// the caller's tenant, as named by the gateway on requests carrying its
+// gatewayToken. The operator tenants see all telemetry and are the only
+// ones allowed on the endpoints wrapped with OperatorOnly.
+func (svc *Service) EnableTenantScoping(operatorTenants []string, gatewayToken string) {
+	t := svc.tenancy
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enabled = true
+	t.gatewayToken = gatewayToken
+	t.operators = map[string]bool{}
+	for _, id := range operatorTenants {
+		t.operators[id] = true
+	}
+}
+
+// OperatorOnly restricts h to operator tenants while tenant scoping is on.
+func (svc *Service) OperatorOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := svc.callerTenant(w, r)
+		if !ok {
+			return
+		}
+		if tenant != "" {
+			respondError(w, http.StatusForbidden, "operator role required")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// callerTenant is the tenant whose telemetry r may read, or "" for all of
+// it. ok is false when the request did not come through the gateway or
+// carries no tenant, which it answers.
+func (svc *Service) callerTenant(w http.ResponseWriter, r *http.Request) (tenant string, ok bool) {
+	t := svc.tenancy
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.enabled {
+		return "", true
+	}
+	token := r.Header.Get(GatewayTokenHeader)
+	if t.gatewayToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(t.gatewayToken)) != 1 {
+		respondError(w, http.StatusUnauthorized, "requests must come through the gateway")
+		return "", false
+	}
+	tenant = strings.TrimSpace(r.Header.Get(TenantHeader))
+	if tenant == "" {
+		respondError(w, http.StatusUnauthorized, TenantHeader+" header required")
+		return "", false
+	}
+	if t.operators[tenant] {
+		return "", true
+	}
+	return tenant, true
+}
+
+// tenantFilter is the label or attribute filter restricting a query to
+// tenant; nil for all tenants.
+func tenantFilter(tenant string) map[string]string {
+	if tenant == "" {
+		return nil
+	}
+	return map[string]string{TenantKey: tenant}
+}
+
+// logOfTenant reports whether entry is tagged with tenant, or tenant is
+// "" for all.
+func logOfTenant(entry model.LogEntry, tenant string) bool {
+	if tenant == "" {
+		return true
+	}
+	v, ok := entry.Fields[TenantKey]
+	return ok && fmt.Sprint(v) == tenant
+}
+
+// spansOfTenant keeps the spans tagged with tenant, all of them for "".
+func spansOfTenant(spans []model.TraceSpan, tenant string) []model.TraceSpan {
+	if tenant == "" {
+		return spans
+	}
+	kept := spans[:0]
+	for _, s := range spans {
+		if s.Attributes[TenantKey] == tenant {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+const (
+	defaultTraceSearchLimit = 20
+	maxTraceSearchLimit     = 100
+	// maxTraceSearchSpans caps the matching spans read to find traces.
+	maxTraceSearchSpans = 10000
+)
+
+// HandleSearchTraces handles GET /v1/traces
+//
+// It finds the traces with a span matching all of service, operation,
+// status and min_duration_ms that started within [from, to], latest first,
+// and returns a summary of each.
+func (svc *Service) HandleSearchTraces(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := svc.callerTenant(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	query := model.SpanQuery{
+		Service:    q.Get("service"),
+		Operation:  q.Get("operation"),
+		Status:     q.Get("status"),
+		Attributes: tenantFilter(tenant),
+		Limit:      maxTraceSearchSpans,
+	}
+	if s := q.Get("min_duration_ms"); s != "" {
+		d, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || d < 0 {
+			respondError(w, http.StatusBadRequest, "min_duration_ms must be a non-negative integer")
+			return
+		}
+		query.MinDurationMs = d
+	}
+	limit := defaultTraceSearchLimit
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxTraceSearchLimit {
+			respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+	from, to, err := parseTimeRange(q)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.StartTime, query.EndTime = from, to
+
+	spans, err := svc.store.QuerySpans(r.Context(), query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	var traceIDs []string
+	seen := map[string]bool{}
+	for _, s := range spans {
+		if !seen[s.TraceID] && len(traceIDs) < limit {
+			seen[s.TraceID] = true
+			traceIDs = append(traceIDs, s.TraceID)
+		}
+	}
+
+	traces := make([]model.TraceSummary, 0, len(traceIDs))
+	for _, id := range traceIDs {
+		trace, err := svc.store.GetTraceSpans(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		if trace = spansOfTenant(trace, tenant); len(trace) > 0 {
+			traces = append(traces, summarizeTrace(id, trace))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"traces": traces,
+		"count":  len(traces),
+	})
+}
+
+// summarizeTrace describes a trace from its spans. The root is the span
+// without a parent, or the earliest one when the root was not received.
+func summarizeTrace(traceID string, spans []model.TraceSpan) model.TraceSummary {
+	sum := model.TraceSummary{TraceID: traceID, SpanCount: len(spans), Services: []string{}}
+	root := spans[0]
+	start, end := spans[0].StartTime, spans[0].StartTime
+	services := map[string]bool{}
+	for _, s := range spans {
+		if isRoot, rootIsRoot := s.ParentSpanID == "", root.ParentSpanID == ""; isRoot != rootIsRoot {
+			if isRoot {
+				root = s
+			}
+		} else if s.StartTime.Before(root.StartTime) {
+			root = s
+		}
+		if s.StartTime.Before(start) {
+			start = s.StartTime
+		}
+		spanEnd := s.EndTime
+		if spanEnd.IsZero() {
+			spanEnd = s.StartTime.Add(time.Duration(s.DurationMs) * time.Millisecond)
+		}
+		if spanEnd.After(end) {
+			end = spanEnd
+		}
+		if isErrorStatus(s.Status) {
+			sum.ErrorCount++
+		}
+		if !services[s.Service] {
+			services[s.Service] = true
+			sum.Services = append(sum.Services, s.Service)
+		}
+	}
+	sort.Strings(sum.Services)
+	sum.RootService, sum.RootOperation = root.Service, root.Operation
+	sum.StartTime = start
+	sum.DurationMs = end.Sub(start).Milliseconds()
+	return sum
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+// tokenize splits text into the lowercase words used for full-text search.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// invertedIndex maps each message word to the sequence numbers of the logs
+// containing it.
+type invertedIndex map[string]map[uint64]struct{}
+
+func (idx invertedIndex) add(seq uint64, message string) {
+	for _, tok := range tokenize(message) {
+		postings := idx[tok]
+		if postings == nil {
+			postings = map[uint64]struct{}{}
+			idx[tok] = postings
+		}
+		postings[seq] = struct{}{}
+	}
+}
+
+func (idx invertedIndex) remove(seq uint64, message string) {
+	for _, tok := range tokenize(message) {
+		if postings := idx[tok]; postings != nil {
+			delete(postings, seq)
+			if len(postings) == 0 {
+				delete(idx, tok)
+			}
+		}
+	}
+}
+
+// lookup returns the sequence numbers of the logs containing every term,
+// newest first.
+func (idx invertedIndex) lookup(terms []string) []uint64 {
+	if len(terms) == 0 {
+		return nil
+	}
+	sets := make([]map[uint64]struct{}, 0, len(terms))
+	for _, term := range terms {
+		postings := idx[term]
+		if len(postings) == 0 {
+			return nil
+		}
+		sets = append(sets, postings)
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	var out []uint64
+next:
+	for seq := range sets[0] {
+		for _, set := range sets[1:] {
+			if _, ok := set[seq]; !ok {
+				continue next
+			}
+		}
+		out = append(out, seq)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] > out[j] })
+	return out
+}
+
+// matchesLog applies the query's non-text filters to entry.
+func matchesLog(entry model.LogEntry, query model.LogQuery) bool {
+	if query.Service != "" && entry.Service != query.Service {
+		return false
+	}
+	if query.Level != "" && entry.Level != query.Level {
+		return false
+	}
+	if !query.StartTime.IsZero() && entry.Timestamp.Before(query.StartTime) {
+		return false
+	}
+	if !query.EndTime.IsZero() && entry.Timestamp.After(query.EndTime) {
+		return false
+	}
+	if query.Search != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(query.Search)) {
+		return false
+	}
+	for k, want := range query.Fields {
+		v, ok := entry.Fields[k]
+		if !ok || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	return true
+}
@@ -1,8 +1,12 @@
 package store
 
 import (
+	"cmp"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -12,24 +16,39 @@ import (
 
 type MemoryStore struct {
 	mu             sync.RWMutex
-	logs           []model.LogEntry
+	logs           []memLog
+	logIndex       invertedIndex
+	nextLogSeq     uint64
 	metrics        []model.MetricEntry
 	spans          []model.TraceSpan
+	rollups        map[model.Resolution]map[string]model.MetricEntry
+	events         []model.MarketEvent
+	eventIDs       map[string]struct{}
 	maxLogEntries  int
 	maxMetricItems int
 }
 
 func NewMemoryStore(maxLogEntries, maxMetricItems int) *MemoryStore {
 	return &MemoryStore{
-		logs:           make([]model.LogEntry, 0),
+		logs:           make([]memLog, 0),
+		logIndex:       invertedIndex{},
 		metrics:        make([]model.MetricEntry, 0),
 		spans:          make([]model.TraceSpan, 0),
+		rollups:        map[model.Resolution]map[string]model.MetricEntry{},
+		eventIDs:       map[string]struct{}{},
 		maxLogEntries:  maxLogEntries,
 		maxMetricItems: maxMetricItems,
 	}
 }
 
-func (s *MemoryStore) AddLog(entry model.LogEntry) error {
+// memLog is a stored log with its insertion sequence number, which orders
+// s.logs and identifies the log in the index.
+type memLog struct {
+	seq   uint64
+	entry model.LogEntry
+}
+
+func (s *MemoryStore) AddLog(ctx context.Context, entry model.LogEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -42,14 +61,17 @@ func (s *MemoryStore) AddLog(entry model.LogEntry) error {
 
 	// Evict oldest if at capacity
 	if len(s.logs) >= s.maxLogEntries {
+		s.logIndex.remove(s.logs[0].seq, s.logs[0].entry.Message)
 		s.logs = s.logs[1:]
 	}
 
-	s.logs = append(s.logs, entry)
+	s.nextLogSeq++
+	s.logs = append(s.logs, memLog{seq: s.nextLogSeq, entry: entry})
+	s.logIndex.add(s.nextLogSeq, entry.Message)
 	return nil
 }
 
-func (s *MemoryStore) QueryLogs(query model.LogQuery) ([]model.LogEntry, error) {
+func (s *MemoryStore) QueryLogs(ctx context.Context, query model.LogQuery) ([]model.LogEntry, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -59,34 +81,50 @@ func (s *MemoryStore) QueryLogs(query model.LogQuery) ([]model.LogEntry, error)
 		limit = 100
 	}
 
-	// Iterate in reverse (newest first)
-	for i := len(s.logs) - 1; i >= 0 && len(results) < limit; i-- {
-		entry := s.logs[i]
+	s.eachLog(query, func(entry model.LogEntry) bool {
+		results = append(results, entry)
+		return len(results) < limit
+	})
+	return results, nil
+}
 
-		// Apply filters
-		if query.Service != "" && entry.Service != query.Service {
-			continue
-		}
-		if query.Level != "" && entry.Level != query.Level {
-			continue
-		}
-		if !query.StartTime.IsZero() && entry.Timestamp.Before(query.StartTime) {
-			continue
+func (s *MemoryStore) LogFacets(ctx context.Context, query model.LogQuery) (model.LogFacets, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	facets := model.LogFacets{Level: map[string]int{}, Service: map[string]int{}}
+	s.eachLog(query, func(entry model.LogEntry) bool {
+		facets.Level[entry.Level]++
+		facets.Service[entry.Service]++
+		return true
+	})
+	return facets, nil
+}
+
+// eachLog calls fn with the logs matching query, newest first, until fn
+// returns false. Text terms are resolved through the index.
+func (s *MemoryStore) eachLog(query model.LogQuery, fn func(model.LogEntry) bool) {
+	terms := tokenize(query.Text)
+	if len(terms) == 0 {
+		for i := len(s.logs) - 1; i >= 0; i-- {
+			if matchesLog(s.logs[i].entry, query) && !fn(s.logs[i].entry) {
+				return
+			}
 		}
-		if !query.EndTime.IsZero() && entry.Timestamp.After(query.EndTime) {
+		return
+	}
+	for _, seq := range s.logIndex.lookup(terms) {
+		i, found := sort.Find(len(s.logs), func(i int) int { return cmp.Compare(seq, s.logs[i].seq) })
+		if !found {
 			continue
 		}
-		if query.Search != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(query.Search)) {
-			continue
+		if matchesLog(s.logs[i].entry, query) && !fn(s.logs[i].entry) {
+			return
 		}
-
-		results = append(results, entry)
 	}
-
-	return results, nil
 }
 
-func (s *MemoryStore) AddMetric(entry model.MetricEntry) error {
+func (s *MemoryStore) AddMetric(ctx context.Context, entry model.MetricEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -106,7 +144,7 @@ func (s *MemoryStore) AddMetric(entry model.MetricEntry) error {
 	return nil
 }
 
-func (s *MemoryStore) QueryMetrics(query model.MetricQuery) ([]model.MetricEntry, error) {
+func (s *MemoryStore) QueryMetrics(ctx context.Context, query model.MetricQuery) ([]model.MetricEntry, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -117,28 +155,103 @@ func (s *MemoryStore) QueryMetrics(query model.MetricQuery) ([]model.MetricEntry
 	}
 
 	for i := len(s.metrics) - 1; i >= 0 && len(results) < limit; i-- {
-		entry := s.metrics[i]
-
-		if query.Name != "" && entry.Name != query.Name {
-			continue
-		}
-		if query.Service != "" && entry.Service != query.Service {
-			continue
+		if entry := s.metrics[i]; matchesMetric(entry, query) {
+			results = append(results, entry)
 		}
-		if !query.StartTime.IsZero() && entry.Timestamp.Before(query.StartTime) {
-			continue
+	}
+
+	return results, nil
+}
+
+func matchesMetric(entry model.MetricEntry, query model.MetricQuery) bool {
+	if query.Name != "" && entry.Name != query.Name {
+		return false
+	}
+	if query.Service != "" && entry.Service != query.Service {
+		return false
+	}
+	if !hasAll(entry.Labels, query.Labels) {
+		return false
+	}
+	if !query.StartTime.IsZero() && entry.Timestamp.Before(query.StartTime) {
+		return false
+	}
+	if !query.EndTime.IsZero() && entry.Timestamp.After(query.EndTime) {
+		return false
+	}
+	return true
+}
+
+func (s *MemoryStore) UpsertRollups(ctx context.Context, resolution model.Resolution, entries []model.MetricEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tier := s.rollups[resolution]
+	if tier == nil {
+		tier = map[string]model.MetricEntry{}
+		s.rollups[resolution] = tier
+	}
+	for _, e := range entries {
+		tier[e.ID] = e
+	}
+	return nil
+}
+
+func (s *MemoryStore) QueryRollups(ctx context.Context, resolution model.Resolution, query model.MetricQuery) ([]model.MetricEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []model.MetricEntry
+	for _, e := range s.rollups[resolution] {
+		if matchesMetric(e, query) {
+			results = append(results, e)
 		}
-		if !query.EndTime.IsZero() && entry.Timestamp.After(query.EndTime) {
-			continue
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Timestamp.Equal(results[j].Timestamp) {
+			return results[i].Timestamp.After(results[j].Timestamp)
 		}
+		return results[i].ID < results[j].ID
+	})
 
-		results = append(results, entry)
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if len(results) > limit {
+		results = results[:limit]
 	}
-
 	return results, nil
 }
 
-func (s *MemoryStore) AddSpan(span model.TraceSpan) error {
+func (s *MemoryStore) LatestRollup(ctx context.Context, resolution model.Resolution) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest time.Time
+	for _, e := range s.rollups[resolution] {
+		if e.Timestamp.After(latest) {
+			latest = e.Timestamp
+		}
+	}
+	return latest, nil
+}
+
+func (s *MemoryStore) DeleteRollupsOlderThan(ctx context.Context, resolution model.Resolution, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	for id, e := range s.rollups[resolution] {
+		if e.Timestamp.Before(cutoff) {
+			delete(s.rollups[resolution], id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *MemoryStore) AddSpan(ctx context.Context, span model.TraceSpan) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -150,7 +263,7 @@ func (s *MemoryStore) AddSpan(span model.TraceSpan) error {
 	return nil
 }
 
-func (s *MemoryStore) GetTraceSpans(traceID string) ([]model.TraceSpan, error) {
+func (s *MemoryStore) GetTraceSpans(ctx context.Context, traceID string) ([]model.TraceSpan, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -163,7 +276,160 @@ func (s *MemoryStore) GetTraceSpans(traceID string) ([]model.TraceSpan, error) {
 	return results, nil
 }
 
-func (s *MemoryStore) GetStats() map[string]any {
+func (s *MemoryStore) QuerySpans(ctx context.Context, query model.SpanQuery) ([]model.TraceSpan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []model.TraceSpan
+	for _, span := range s.spans {
+		if query.Service != "" && span.Service != query.Service {
+			continue
+		}
+		if query.Operation != "" && span.Operation != query.Operation {
+			continue
+		}
+		if query.Status != "" && !strings.EqualFold(span.Status, query.Status) {
+			continue
+		}
+		if span.DurationMs < query.MinDurationMs {
+			continue
+		}
+		if !hasAll(span.Attributes, query.Attributes) {
+			continue
+		}
+		if !query.StartTime.IsZero() && span.StartTime.Before(query.StartTime) {
+			continue
+		}
+		if !query.EndTime.IsZero() && span.StartTime.After(query.EndTime) {
+			continue
+		}
+		results = append(results, span)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].StartTime.After(results[j].StartTime) })
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// AddEvent keeps up to maxLogEntries events, evicting the oldest.
+func (s *MemoryStore) AddEvent(ctx context.Context, event model.MarketEvent) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.eventIDs[event.ID]; ok {
+		return false, nil
+	}
+	if len(s.events) >= s.maxLogEntries {
+		delete(s.eventIDs, s.events[0].ID)
+		s.events = s.events[1:]
+	}
+	s.events = append(s.events, event)
+	s.eventIDs[event.ID] = struct{}{}
+	return true, nil
+}
+
+func (s *MemoryStore) QueryEvents(ctx context.Context, query model.EventQuery) ([]model.MarketEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []model.MarketEvent
+	for _, e := range s.events {
+		if len(query.Types) > 0 && !slices.Contains(query.Types, e.Type) {
+			continue
+		}
+		if (query.Source != "" && e.Source != query.Source) ||
+			(query.TenantID != "" && e.TenantID != query.TenantID) ||
+			(query.WorkID != "" && e.WorkID != query.WorkID) ||
+			(query.ContractID != "" && e.ContractID != query.ContractID) ||
+			(query.ProviderID != "" && e.ProviderID != query.ProviderID) ||
+			(query.ConsumerID != "" && e.ConsumerID != query.ConsumerID) {
+			continue
+		}
+		if !query.StartTime.IsZero() && e.Timestamp.Before(query.StartTime) {
+			continue
+		}
+		if !query.EndTime.IsZero() && e.Timestamp.After(query.EndTime) {
+			continue
+		}
+		results = append(results, e)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Timestamp.After(results[j].Timestamp) })
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (s *MemoryStore) DeleteOlderThan(ctx context.Context, signal model.Signal, scope model.RetentionScope, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int
+	switch signal {
+	case model.SignalLogs:
+		expired := func(l memLog) bool {
+			return l.entry.Timestamp.Before(cutoff) && inScope(scope, l.entry.Service, l.entry.Level)
+		}
+		for _, l := range s.logs {
+			if expired(l) {
+				s.logIndex.remove(l.seq, l.entry.Message)
+			}
+		}
+		s.logs, removed = keepUnexpired(s.logs, expired)
+	case model.SignalMetrics:
+		s.metrics, removed = keepUnexpired(s.metrics, func(e model.MetricEntry) bool {
+			return e.Timestamp.Before(cutoff) && inScope(scope, e.Service, "")
+		})
+	case model.SignalSpans:
+		s.spans, removed = keepUnexpired(s.spans, func(e model.TraceSpan) bool {
+			return e.StartTime.Before(cutoff) && inScope(scope, e.Service, "")
+		})
+	}
+	return int64(removed), nil
+}
+
+// inScope reports whether an item of service at level is in scope.
+func inScope(scope model.RetentionScope, service, level string) bool {
+	matches := func(sel model.RetentionSelector) bool {
+		return (sel.Service == "" || sel.Service == service) && (sel.Level == "" || strings.EqualFold(sel.Level, level))
+	}
+	if !matches(scope.RetentionSelector) {
+		return false
+	}
+	for _, sel := range scope.Except {
+		if matches(sel) {
+			return false
+		}
+	}
+	return true
+}
+
+// keepUnexpired drops the expired items, keeping the order of the rest, and
+// returns how many it dropped.
+func keepUnexpired[T any](items []T, expired func(T) bool) ([]T, int) {
+	kept := items[:0]
+	for _, it := range items {
+		if !expired(it) {
+			kept = append(kept, it)
+		}
+	}
+	removed := len(items) - len(kept)
+	clear(items[len(kept):])
+	return kept, removed
+}
+
+func (s *MemoryStore) GetStats(ctx context.Context) (map[string]any, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -171,9 +437,20 @@ func (s *MemoryStore) GetStats() map[string]any {
 		"log_count":    len(s.logs),
 		"metric_count": len(s.metrics),
 		"span_count":   len(s.spans),
+		"event_count":  len(s.events),
 		"max_logs":     s.maxLogEntries,
 		"max_metrics":  s.maxMetricItems,
+	}, nil
+}
+
+// hasAll reports whether m holds every pair of want.
+func hasAll(m, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := m[k]; !ok || got != v {
+			return false
+		}
 	}
+	return true
 }
 
 func generateID() string {
@@ -0,0 +1,458 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoStore struct {
+	logs    *mongo.Collection
+	metrics *mongo.Collection
+	spans   *mongo.Collection
+	events  *mongo.Collection
+	// rollups holds one collection per rollup resolution, named after the
+	// metrics collection with the resolution as suffix.
+	rollups map[model.Resolution]*mongo.Collection
+}
+
+func NewMongoStore(client *mongo.Client, dbName, logsColl, metricsColl, spansColl, eventsColl string) *MongoStore {
+	db := client.Database(dbName)
+	rollups := map[model.Resolution]*mongo.Collection{}
+	for _, res := range model.RollupResolutions {
+		rollups[res] = db.Collection(metricsColl + "_" + string(res))
+	}
+	return &MongoStore{
+		logs:    db.Collection(logsColl),
+		metrics: db.Collection(metricsColl),
+		spans:   db.Collection(spansColl),
+		events:  db.Collection(eventsColl),
+		rollups: rollups,
+	}
+}
+
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	if _, err := s.logs.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "service", Value: 1}, {Key: "timestamp", Value: -1}}},
+		// No language, so words match exactly as in the memory store.
+		{Keys: bson.D{{Key: "message", Value: "text"}}, Options: options.Index().SetDefaultLanguage("none")},
+	}); err != nil {
+		return err
+	}
+	if _, err := s.metrics.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "timestamp", Value: -1}}},
+	}); err != nil {
+		return err
+	}
+	for _, coll := range s.rollups {
+		if _, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+			{Keys: bson.D{{Key: "name", Value: 1}, {Key: "timestamp", Value: -1}}},
+		}); err != nil {
+			return err
+		}
+	}
+	if _, err := s.spans.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "trace_id", Value: 1}, {Key: "start_time", Value: 1}}},
+		{Keys: bson.D{{Key: "start_time", Value: 1}}},
+		{Keys: bson.D{{Key: "service", Value: 1}, {Key: "start_time", Value: -1}}},
+	}); err != nil {
+		return err
+	}
+	_, err := s.events.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "work_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "contract_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "provider_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "consumer_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+	})
+	return err
+}
+
+func (s *MongoStore) AddLog(ctx context.Context, entry model.LogEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if entry.ID == "" {
+		entry.ID = generateID()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	_, err := s.logs.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoStore) QueryLogs(ctx context.Context, query model.LogQuery) ([]model.LogEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out := make([]model.LogEntry, 0)
+	err := find(ctx, s.logs, logFilter(query), "timestamp", queryLimit(query.Limit), &out)
+	return out, err
+}
+
+func (s *MongoStore) LogFacets(ctx context.Context, query model.LogQuery) (model.LogFacets, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	countBy := func(field string) bson.A {
+		return bson.A{bson.M{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}}
+	}
+	cur, err := s.logs.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: logFilter(query)}},
+		{{Key: "$facet", Value: bson.M{"level": countBy("level"), "service": countBy("service")}}},
+	})
+	if err != nil {
+		return model.LogFacets{}, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+	type bucket struct {
+		Value string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	var res []struct {
+		Level   []bucket `bson:"level"`
+		Service []bucket `bson:"service"`
+	}
+	if err := cur.All(ctx, &res); err != nil {
+		return model.LogFacets{}, err
+	}
+	facets := model.LogFacets{Level: map[string]int{}, Service: map[string]int{}}
+	for _, r := range res {
+		for _, b := range r.Level {
+			facets.Level[b.Value] = b.Count
+		}
+		for _, b := range r.Service {
+			facets.Service[b.Value] = b.Count
+		}
+	}
+	return facets, nil
+}
+
+func logFilter(query model.LogQuery) bson.M {
+	filter := bson.M{}
+	if query.Service != "" {
+		filter["service"] = query.Service
+	}
+	if query.Level != "" {
+		filter["level"] = query.Level
+	}
+	if ts := timeRange(query.StartTime, query.EndTime); ts != nil {
+		filter["timestamp"] = ts
+	}
+	if query.Search != "" {
+		filter["message"] = bson.M{"$regex": regexp.QuoteMeta(query.Search), "$options": "i"}
+	}
+	if terms := tokenize(query.Text); len(terms) > 0 {
+		// Quoting each term makes all of them required.
+		filter["$text"] = bson.M{"$search": `"` + strings.Join(terms, `" "`) + `"`}
+	}
+	for k, v := range query.Fields {
+		filter["fields."+k] = bson.M{"$in": fieldValues(v)}
+	}
+	return filter
+}
+
+// fieldValues are the stored values a field filter given as text matches:
+// the text itself and, where it parses as one, the number or boolean.
+func fieldValues(v string) bson.A {
+	values := bson.A{v}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		values = append(values, f)
+	}
+	if v == "true" || v == "false" {
+		values = append(values, v == "true")
+	}
+	return values
+}
+
+func (s *MongoStore) AddMetric(ctx context.Context, entry model.MetricEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if entry.ID == "" {
+		entry.ID = generateID()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	_, err := s.metrics.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoStore) QueryMetrics(ctx context.Context, query model.MetricQuery) ([]model.MetricEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out := make([]model.MetricEntry, 0)
+	err := find(ctx, s.metrics, metricFilter(query), "timestamp", queryLimit(query.Limit), &out)
+	return out, err
+}
+
+func metricFilter(query model.MetricQuery) bson.M {
+	filter := bson.M{}
+	if query.Name != "" {
+		filter["name"] = query.Name
+	}
+	if query.Service != "" {
+		filter["service"] = query.Service
+	}
+	for k, v := range query.Labels {
+		filter["labels."+k] = v
+	}
+	if ts := timeRange(query.StartTime, query.EndTime); ts != nil {
+		filter["timestamp"] = ts
+	}
+	return filter
+}
+
+func (s *MongoStore) UpsertRollups(ctx context.Context, resolution model.Resolution, entries []model.MetricEntry) error {
+	coll, err := s.rollupCollection(resolution)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	writes := make([]mongo.WriteModel, 0, len(entries))
+	for _, e := range entries {
+		writes = append(writes, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": e.ID}).SetReplacement(e).SetUpsert(true))
+	}
+	_, err = coll.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (s *MongoStore) QueryRollups(ctx context.Context, resolution model.Resolution, query model.MetricQuery) ([]model.MetricEntry, error) {
+	coll, err := s.rollupCollection(resolution)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out := make([]model.MetricEntry, 0)
+	err = find(ctx, coll, metricFilter(query), "timestamp", queryLimit(query.Limit), &out)
+	return out, err
+}
+
+func (s *MongoStore) LatestRollup(ctx context.Context, resolution model.Resolution) (time.Time, error) {
+	coll, err := s.rollupCollection(resolution)
+	if err != nil {
+		return time.Time{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var latest model.MetricEntry
+	err = coll.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}})).Decode(&latest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	return latest.Timestamp, err
+}
+
+func (s *MongoStore) DeleteRollupsOlderThan(ctx context.Context, resolution model.Resolution, cutoff time.Time) (int64, error) {
+	coll, err := s.rollupCollection(resolution)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	res, err := coll.DeleteMany(ctx, bson.M{"timestamp": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (s *MongoStore) rollupCollection(resolution model.Resolution) (*mongo.Collection, error) {
+	coll, ok := s.rollups[resolution]
+	if !ok {
+		return nil, fmt.Errorf("unknown rollup resolution %q", resolution)
+	}
+	return coll, nil
+}
+
+func (s *MongoStore) AddSpan(ctx context.Context, span model.TraceSpan) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.spans.InsertOne(ctx, span)
+	return err
+}
+
+func (s *MongoStore) GetTraceSpans(ctx context.Context, traceID string) ([]model.TraceSpan, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	cur, err := s.spans.Find(ctx, bson.M{"trace_id": traceID}, options.Find().SetSort(bson.D{{Key: "start_time", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+	var out []model.TraceSpan
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *MongoStore) QuerySpans(ctx context.Context, query model.SpanQuery) ([]model.TraceSpan, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	filter := bson.M{}
+	if query.Service != "" {
+		filter["service"] = query.Service
+	}
+	if query.Operation != "" {
+		filter["operation"] = query.Operation
+	}
+	if query.Status != "" {
+		filter["status"] = bson.M{"$regex": "^" + regexp.QuoteMeta(query.Status) + "$", "$options": "i"}
+	}
+	if query.MinDurationMs > 0 {
+		filter["duration_ms"] = bson.M{"$gte": query.MinDurationMs}
+	}
+	for k, v := range query.Attributes {
+		filter["attributes."+k] = v
+	}
+	if ts := timeRange(query.StartTime, query.EndTime); ts != nil {
+		filter["start_time"] = ts
+	}
+	out := make([]model.TraceSpan, 0)
+	err := find(ctx, s.spans, filter, "start_time", queryLimit(query.Limit), &out)
+	return out, err
+}
+
+func (s *MongoStore) AddEvent(ctx context.Context, event model.MarketEvent) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := s.events.InsertOne(ctx, event); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *MongoStore) QueryEvents(ctx context.Context, query model.EventQuery) ([]model.MarketEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	filter := bson.M{}
+	if len(query.Types) > 0 {
+		filter["type"] = bson.M{"$in": query.Types}
+	}
+	for field, v := range map[string]string{
+		"source":      query.Source,
+		"tenant_id":   query.TenantID,
+		"work_id":     query.WorkID,
+		"contract_id": query.ContractID,
+		"provider_id": query.ProviderID,
+		"consumer_id": query.ConsumerID,
+	} {
+		if v != "" {
+			filter[field] = v
+		}
+	}
+	if ts := timeRange(query.StartTime, query.EndTime); ts != nil {
+		filter["timestamp"] = ts
+	}
+	out := make([]model.MarketEvent, 0)
+	err := find(ctx, s.events, filter, "timestamp", queryLimit(query.Limit), &out)
+	return out, err
+}
+
+func (s *MongoStore) DeleteOlderThan(ctx context.Context, signal model.Signal, scope model.RetentionScope, cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	coll, field := s.logs, "timestamp"
+	switch signal {
+	case model.SignalMetrics:
+		coll = s.metrics
+	case model.SignalSpans:
+		coll, field = s.spans, "start_time"
+	}
+	filter := selectorFilter(scope.RetentionSelector)
+	filter[field] = bson.M{"$lt": cutoff}
+	if len(scope.Except) > 0 {
+		except := make(bson.A, 0, len(scope.Except))
+		for _, sel := range scope.Except {
+			except = append(except, selectorFilter(sel))
+		}
+		filter["$nor"] = except
+	}
+	res, err := coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (s *MongoStore) GetStats(ctx context.Context) (map[string]any, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	stats := map[string]any{}
+	for key, coll := range map[string]*mongo.Collection{
+		"log_count":    s.logs,
+		"metric_count": s.metrics,
+		"span_count":   s.spans,
+		"event_count":  s.events,
+	} {
+		n, err := coll.EstimatedDocumentCount(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stats[key] = n
+	}
+	return stats, nil
+}
+
+// find decodes up to limit documents matching filter, newest by sortField
+// first, into out.
+func find(ctx context.Context, coll *mongo.Collection, filter bson.M, sortField string, limit int, out any) error {
+	opts := options.Find().SetSort(bson.D{{Key: sortField, Value: -1}}).SetLimit(int64(limit))
+	cur, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+	return cur.All(ctx, out)
+}
+
+// timeRange is the filter for [start, end]; nil when both are zero.
+func timeRange(start, end time.Time) bson.M {
+	r := bson.M{}
+	if !start.IsZero() {
+		r["$gte"] = start
+	}
+	if !end.IsZero() {
+		r["$lte"] = end
+	}
+	if len(r) == 0 {
+		return nil
+	}
+	return r
+}
+
+func queryLimit(limit int) int {
+	if limit <= 0 {
+		return 100
+	}
+	return limit
+}
+
+// selectorFilter matches the documents sel selects.
+func selectorFilter(sel model.RetentionSelector) bson.M {
+	filter := bson.M{}
+	if sel.Service != "" {
+		filter["service"] = sel.Service
+	}
+	if sel.Level != "" {
+		filter["level"] = bson.M{"$regex": "^" + regexp.QuoteMeta(sel.Level) + "$", "$options": "i"}
+	}
+	return filter
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
+)
+
+// Store persists ingested logs, metrics and spans, and marketplace events.
+type Store interface {
+	AddLog(ctx context.Context, entry model.LogEntry) error
+	// QueryLogs returns the logs matching query, newest first.
+	QueryLogs(ctx context.Context, query model.LogQuery) ([]model.LogEntry, error)
+	// LogFacets counts all the logs matching query, regardless of its
+	// limit, by level and service.
+	LogFacets(ctx context.Context, query model.LogQuery) (model.LogFacets, error)
+
+	AddMetric(ctx context.Context, entry model.MetricEntry) error
+	// QueryMetrics returns the data points matching query, newest first.
+	QueryMetrics(ctx context.Context, query model.MetricQuery) ([]model.MetricEntry, error)
+
+	// UpsertRollups stores rollup points of resolution, replacing those
+	// with the same ID.
+	UpsertRollups(ctx context.Context, resolution model.Resolution, entries []model.MetricEntry) error
+	// QueryRollups returns the rollup points of resolution matching query,
+	// newest first.
+	QueryRollups(ctx context.Context, resolution model.Resolution, query model.MetricQuery) ([]model.MetricEntry, error)
+	// LatestRollup is the start of the newest rollup interval stored for
+	// resolution; zero when there is none.
+	LatestRollup(ctx context.Context, resolution model.Resolution) (time.Time, error)
+	// DeleteRollupsOlderThan removes the rollup points of resolution
+	// starting before cutoff and returns how many were removed.
+	DeleteRollupsOlderThan(ctx context.Context, resolution model.Resolution, cutoff time.Time) (int64, error)
+
+	AddSpan(ctx context.Context, span model.TraceSpan) error
+	GetTraceSpans(ctx context.Context, traceID string) ([]model.TraceSpan, error)
+	// QuerySpans returns the spans matching query by start time, latest
+	// first.
+	QuerySpans(ctx context.Context, query model.SpanQuery) ([]model.TraceSpan, error)
+
+	// AddEvent stores a marketplace event unless one with its ID is
+	// stored already; added reports which.
+	AddEvent(ctx context.Context, event model.MarketEvent) (added bool, err error)
+	// QueryEvents returns the events matching query, newest first.
+	QueryEvents(ctx context.Context, query model.EventQuery) ([]model.MarketEvent, error)
+
+	// DeleteOlderThan removes the signal's data in scope timestamped before
+	// cutoff (spans by start time) and returns how many items were removed.
+	DeleteOlderThan(ctx context.Context, signal model.Signal, scope model.RetentionScope, cutoff time.Time) (int64, error)
+
+	GetStats(ctx context.Context) (map[string]any, error)
+}
@@ -6,23 +6,121 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/archive"
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/config"
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/httpapi"
+	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/service"
 	"github.com/parlakisik/agent-exchange/aex-telemetry/internal/store"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func main() {
 	cfg := config.Load()
 
 	// Initialize store
-	memStore := store.NewMemoryStore(cfg.MaxLogEntries, cfg.MaxMetricItems)
+	var st store.Store
+	var mongoClient *mongo.Client
+	switch cfg.StoreType {
+	case "mongo":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		c, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
+		if err != nil {
+			log.Fatal(err)
+		}
+		mongoClient = c
+		ms := store.NewMongoStore(c, cfg.MongoDatabase, cfg.MongoCollectionLogs, cfg.MongoCollectionMetrics, cfg.MongoCollectionSpans, cfg.MongoCollectionEvents)
+		if err := ms.EnsureIndexes(ctx); err != nil {
+			log.Printf("mongo index creation failed: %v", err)
+		}
+		cancel()
+		st = ms
+		log.Printf("mongo store enabled uri=%s db=%s", cfg.MongoURI, cfg.MongoDatabase)
+	case "memory":
+		st = store.NewMemoryStore(cfg.MaxLogEntries, cfg.MaxMetricItems)
+	default:
+		log.Fatalf("unknown STORE_TYPE %q (use memory or mongo)", cfg.StoreType)
+	}
 
 	// Initialize service
-	svc := service.New(memStore)
+	svc := service.New(st)
+	svc.SetMetricsStaleness(cfg.MetricsStaleness)
+	svc.SetAlertWebhookSecret(cfg.AlertWebhookSecret)
+	svc.SetLabelValueLimits(cfg.LabelValueLimit, cfg.LabelValueLimits)
+	if cfg.TenantScoping {
+		if cfg.GatewayToken == "" {
+			log.Fatal("GATEWAY_TOKEN is required with TENANT_SCOPING_ENABLED")
+		}
+		svc.EnableTenantScoping(cfg.OperatorTenants, cfg.GatewayToken)
+		log.Printf("tenant scoping enabled operators=%v", cfg.OperatorTenants)
+	}
+	if cfg.EventsConsumer {
+		types := cfg.EventTypes
+		if len(types) == 0 {
+			types = service.DefaultEventTypes
+		}
+		svc.EnableEventConsumer(types)
+		log.Printf("event consumer enabled types=%v", types)
+	}
+
+	if (cfg.ExportStore == "s3" || cfg.ExportStore == "gcs") && cfg.ExportBucket == "" {
+		log.Fatalf("EXPORT_BUCKET is required for EXPORT_STORE=%s", cfg.ExportStore)
+	}
+	switch cfg.ExportStore {
+	case "":
+	case "file":
+		svc.SetExportStore(archive.NewFileStore(cfg.ExportDir), cfg.ExportPrefix)
+	case "s3":
+		svc.SetExportStore(archive.NewS3Store(cfg.ExportS3Endpoint, cfg.ExportS3Region, cfg.ExportBucket,
+			cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey, cfg.AWSSessionToken), cfg.ExportPrefix)
+	case "gcs":
+		svc.SetExportStore(archive.NewGCSStore(cfg.ExportGCSEndpoint, cfg.ExportBucket), cfg.ExportPrefix)
+	default:
+		log.Fatalf("unknown EXPORT_STORE %q (use file, s3 or gcs)", cfg.ExportStore)
+	}
+
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	retention := map[model.Signal]time.Duration{
+		model.SignalLogs:    cfg.LogRetention,
+		model.SignalMetrics: cfg.MetricRetention,
+		model.SignalSpans:   cfg.SpanRetention,
+	}
+	if cfg.RollupInterval > 0 {
+		// Raw metric points are expired by the rollup worker instead, once
+		// rolled up.
+		delete(retention, model.SignalMetrics)
+		svc.EnableRollups(map[model.Resolution]time.Duration{
+			model.ResolutionRaw: cfg.MetricRetention,
+			model.Resolution1m:  cfg.Rollup1mRetention,
+			model.Resolution5m:  cfg.Rollup5mRetention,
+			model.Resolution1h:  cfg.Rollup1hRetention,
+		})
+		go svc.RunRollups(bgCtx, cfg.RollupInterval)
+	}
+	if cfg.RetentionInterval > 0 {
+		go svc.RunRetention(bgCtx, retention, cfg.RetentionInterval)
+	}
+	if cfg.AlertEvalInterval > 0 {
+		go svc.RunAlerting(bgCtx, cfg.AlertEvalInterval)
+	}
+	for name, baseURL := range cfg.ProbeTargets {
+		var paths []string
+		if p := cfg.ProbePaths[name]; p != "" {
+			paths = strings.Split(p, "|")
+		}
+		if err := svc.AddProbeTarget(model.ProbeTarget{Service: name, BaseURL: baseURL, Paths: paths}); err != nil {
+			log.Fatalf("PROBE_TARGETS %s: %v", name, err)
+		}
+	}
+	if cfg.ProbeInterval > 0 {
+		go svc.RunProbes(bgCtx, cfg.ProbeInterval)
+	}
 
 	// Initialize HTTP server
 	srv := &http.Server{
@@ -48,4 +146,7 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(shutdownCtx)
+	if mongoClient != nil {
+		_ = mongoClient.Disconnect(shutdownCtx)
+	}
 }
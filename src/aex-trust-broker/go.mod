@@ -2,7 +2,15 @@ module github.com/parlakisik/agent-exchange/aex-trust-broker
 
 go 1.24.0
 
-require go.mongodb.org/mongo-driver v1.14.0
+require (
+	github.com/parlakisik/agent-exchange/internal/events v0.0.0
+	github.com/parlakisik/agent-exchange/internal/serviceauth v0.0.0
+	go.mongodb.org/mongo-driver v1.14.0
+)
+
+replace github.com/parlakisik/agent-exchange/internal/events => ../internal/events
+
+replace github.com/parlakisik/agent-exchange/internal/serviceauth => ../internal/serviceauth
 
 require (
 	github.com/golang/snappy v0.0.1 // indirect
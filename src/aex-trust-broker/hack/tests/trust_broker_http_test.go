@@ -2,19 +2,45 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	tbhttp "github.com/parlakisik/agent-exchange/aex-trust-broker/internal/httpapi"
+	tbmodel "github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
 	tbsvc "github.com/parlakisik/agent-exchange/aex-trust-broker/internal/service"
 	tbst "github.com/parlakisik/agent-exchange/aex-trust-broker/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/events"
 )
 
+// internalToken authorizes the tests' calls to the internal routes.
+const internalToken = "internal"
+
+// postInternal posts a JSON body with the internal token.
+func postInternal(url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+internalToken)
+	return http.DefaultClient.Do(req)
+}
+
 func TestRecordOutcomeAndGetTrustAndBatch(t *testing.T) {
-	svc := tbsvc.New(tbst.NewMemoryStore())
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
 	ts := httptest.NewServer(tbhttp.NewRouter(svc))
 	t.Cleanup(ts.Close)
 
@@ -29,7 +55,7 @@ func TestRecordOutcomeAndGetTrustAndBatch(t *testing.T) {
 		"completed_at": time.Now().UTC().Format(time.RFC3339Nano),
 	}
 	b, _ := json.Marshal(outcome)
-	resp, err := http.Post(ts.URL+"/internal/v1/outcomes", "application/json", bytes.NewReader(b))
+	resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -59,4 +85,1614 @@ func TestRecordOutcomeAndGetTrustAndBatch(t *testing.T) {
 	if resp3.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp3.StatusCode)
 	}
+	var batch struct {
+		Scores map[string]float64 `json:"scores"`
+		Tiers  map[string]string  `json:"tiers"`
+	}
+	_ = json.NewDecoder(resp3.Body).Decode(&batch)
+	if len(batch.Scores) != 2 || batch.Scores["prov_b"] != 0.3 || batch.Tiers["prov_b"] != "UNVERIFIED" {
+		t.Fatalf("unexpected batch response: %+v", batch)
+	}
+	if batch.Scores["prov_a"] <= 0.3 || batch.Tiers["prov_a"] == "" {
+		t.Fatalf("expected prov_a to be scored from its outcome: %+v", batch)
+	}
+
+	ids := make([]string, 501)
+	for i := range ids {
+		ids[i] = "prov_x"
+	}
+	b3, _ := json.Marshal(map[string]any{"provider_ids": ids})
+	resp4, err := http.Post(ts.URL+"/internal/v1/trust/batch", "application/json", bytes.NewReader(b3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp4.Body.Close()
+	if resp4.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for oversized batch, got %d", resp4.StatusCode)
+	}
+}
+
+func TestSetVerificationAppliesModifier(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{"endpoint_verified": true})
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/internal/v1/providers/prov_v/verification", bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+internalToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	resp2, err := http.Get(ts.URL + "/v1/providers/prov_v/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp2.Body.Close() }()
+	var rec struct {
+		TrustScore       float64 `json:"trust_score"`
+		EndpointVerified bool    `json:"endpoint_verified"`
+	}
+	_ = json.NewDecoder(resp2.Body).Decode(&rec)
+	if !rec.EndpointVerified {
+		t.Fatalf("expected endpoint_verified to be set")
+	}
+	if rec.TrustScore < 0.349 || rec.TrustScore > 0.351 {
+		t.Fatalf("expected trust score 0.35, got %v", rec.TrustScore)
+	}
+
+	req3, _ := http.NewRequest(http.MethodPut, ts.URL+"/internal/v1/providers/prov_v/verification", bytes.NewReader([]byte(`{}`)))
+	req3.Header.Set("Authorization", "Bearer "+internalToken)
+	resp3, err := http.DefaultClient.Do(req3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp3.Body.Close()
+	if resp3.StatusCode != 400 {
+		t.Fatalf("expected 400 for empty update, got %d", resp3.StatusCode)
+	}
+}
+
+func TestTrustScoreDecaysWithInactivity(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{DecayHalfLife: 30 * 24 * time.Hour, InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	record := func(providerID string, completedAt time.Time) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{
+			"contract_id":  "contract_" + completedAt.Format("150405.000"),
+			"provider_id":  providerID,
+			"outcome":      "SUCCESS",
+			"completed_at": completedAt.Format(time.RFC3339Nano),
+		})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("record outcome: expected 200, got %d", resp.StatusCode)
+		}
+	}
+	type trust struct {
+		TrustScore     float64 `json:"trust_score"`
+		ScoreBreakdown struct {
+			OutcomeScore        float64 `json:"outcome_score"`
+			DecayFactor         float64 `json:"decay_factor"`
+			DecayedOutcomeScore float64 `json:"decayed_outcome_score"`
+			InactiveDays        float64 `json:"inactive_days"`
+		} `json:"score_breakdown"`
+	}
+	get := func(providerID string) trust {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/providers/" + providerID + "/trust")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out trust
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	now := time.Now().UTC()
+	record("prov_active", now)
+	record("prov_idle", now.Add(-60*24*time.Hour))
+
+	active := get("prov_active")
+	if active.ScoreBreakdown.DecayFactor < 0.999 || active.TrustScore < 0.99 {
+		t.Fatalf("recently active provider should not decay: %+v", active)
+	}
+
+	// Two half-lives without a contract leave a quarter of the distance
+	// between the perfect outcome score and the neutral 0.3.
+	idle := get("prov_idle")
+	if idle.ScoreBreakdown.OutcomeScore != 1.0 || idle.ScoreBreakdown.InactiveDays < 59.9 {
+		t.Fatalf("unexpected breakdown: %+v", idle.ScoreBreakdown)
+	}
+	if f := idle.ScoreBreakdown.DecayFactor; f < 0.249 || f > 0.251 {
+		t.Fatalf("expected decay factor 0.25, got %v", f)
+	}
+	if idle.TrustScore < 0.474 || idle.TrustScore > 0.476 {
+		t.Fatalf("expected decayed score 0.475, got %v", idle.TrustScore)
+	}
+
+	resp, err := http.Post(ts.URL+"/internal/v1/trust/batch", "application/json", bytes.NewReader([]byte(`{"provider_ids":["prov_idle"]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var batch struct {
+		Scores map[string]float64 `json:"scores"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&batch)
+	if math.Abs(batch.Scores["prov_idle"]-idle.TrustScore) > 1e-6 {
+		t.Fatalf("batch score %v should match decayed score %v", batch.Scores["prov_idle"], idle.TrustScore)
+	}
+}
+
+func TestTrustHistory(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	for i, outcome := range []string{"SUCCESS", "FAILURE_PROVIDER"} {
+		b, _ := json.Marshal(map[string]any{
+			"contract_id": "contract_" + outcome,
+			"provider_id": "prov_h",
+			"outcome":     outcome,
+		})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("outcome %d: expected 200, got %d", i, resp.StatusCode)
+		}
+	}
+	b, _ := json.Marshal(map[string]any{"identity_verified": true})
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/internal/v1/providers/prov_h/verification", bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+internalToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	type history struct {
+		Snapshots []struct {
+			TrustScore    float64 `json:"trust_score"`
+			PreviousScore float64 `json:"previous_score"`
+			Trigger       string  `json:"trigger"`
+			Outcome       *struct {
+				ContractID string `json:"contract_id"`
+			} `json:"outcome"`
+		} `json:"snapshots"`
+	}
+	get := func(query string) (history, int) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/providers/prov_h/trust/history" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out history
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out, resp.StatusCode
+	}
+
+	h, code := get("")
+	if code != 200 || len(h.Snapshots) != 3 {
+		t.Fatalf("expected 3 snapshots, got %d (status %d)", len(h.Snapshots), code)
+	}
+	first, second, third := h.Snapshots[0], h.Snapshots[1], h.Snapshots[2]
+	if first.Trigger != "outcome" || first.Outcome == nil || first.Outcome.ContractID != "contract_SUCCESS" {
+		t.Fatalf("unexpected first snapshot: %+v", first)
+	}
+	if second.PreviousScore != first.TrustScore || second.TrustScore >= first.TrustScore {
+		t.Fatalf("failure should lower the score from %v, got %+v", first.TrustScore, second)
+	}
+	if third.Trigger != "verification" || third.Outcome != nil {
+		t.Fatalf("unexpected verification snapshot: %+v", third)
+	}
+
+	future := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	if h, _ := get("?from=" + future); len(h.Snapshots) != 0 {
+		t.Fatalf("expected no snapshots after %s, got %d", future, len(h.Snapshots))
+	}
+	if _, code := get("?from=yesterday"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad from, got %d", code)
+	}
+	if _, code := get("?from=" + future + "&to=2020-01-01T00:00:00Z"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for from after to, got %d", code)
+	}
+
+	// The trust record route still serves the current score.
+	resp, err = http.Get(ts.URL + "/v1/providers/prov_h/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 from trust route, got %d", resp.StatusCode)
+	}
+}
+
+func TestTrustChangeWebhook(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner := map[string]string{"key_w": "prov_w", "key_other": "prov_other"}[r.URL.Query().Get("api_key")]
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"provider_id": owner, "valid": owner != ""})
+	}))
+	t.Cleanup(registry.Close)
+
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+	received := make(chan delivery, 8)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- delivery{body: body, signature: r.Header.Get(tbsvc.SignatureHeader)}
+	}))
+	t.Cleanup(receiver.Close)
+
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{
+		ProviderRegistryURL: registry.URL,
+		WebhookBackoff:      10 * time.Millisecond,
+		InternalToken:       internalToken,
+	})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	put := func(apiKey string) int {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"url": receiver.URL, "secret": "s3cret"})
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/v1/providers/prov_w/trust/webhook", bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := put("key_other"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for another provider's key, got %d", code)
+	}
+	if code := put("key_w"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/providers/prov_w/trust/webhook", nil)
+	req.Header.Set("Authorization", "Bearer key_w")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&got)
+	_ = resp.Body.Close()
+	if got["url"] != receiver.URL {
+		t.Fatalf("unexpected webhook: %+v", got)
+	}
+	if _, ok := got["secret"]; ok {
+		t.Fatal("secret must not be returned")
+	}
+
+	b, _ := json.Marshal(map[string]any{"contract_id": "contract_w", "provider_id": "prov_w", "outcome": "SUCCESS"})
+	resp, err = postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	select {
+	case d := <-received:
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(d.body)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); d.signature != want {
+			t.Fatalf("bad signature %q, want %q", d.signature, want)
+		}
+		var ev struct {
+			EventType string         `json:"event_type"`
+			Data      map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(d.body, &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.EventType != "trust.score_updated" || ev.Data["contract_id"] != "contract_w" || ev.Data["reason"] != "outcome" {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestScoringPolicyAdmin(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{AdminToken: "admin", InternalToken: internalToken})
+	if p, err := svc.InitPolicy(context.Background()); err != nil || p.Version != 1 {
+		t.Fatalf("init policy: version %d, err %v", p.Version, err)
+	}
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	putPolicy := func(token string, policy map[string]any) int {
+		t.Helper()
+		b, _ := json.Marshal(policy)
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/admin/v1/scoring-policy", bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	lenient := map[string]any{
+		"tiers": []map[string]any{
+			{"tier": "VERIFIED", "min_score": 0.5, "min_contracts": 1},
+			{"tier": "TRUSTED", "min_score": 0.7, "min_contracts": 25},
+		},
+		"recency_weights": []map[string]any{{"outcomes": 10, "weight": 1}},
+		"older_weight":    0.1,
+	}
+	if code := putPolicy("wrong", lenient); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the admin token, got %d", code)
+	}
+	inverted := map[string]any{
+		"tiers": []map[string]any{
+			{"tier": "VERIFIED", "min_score": 0.8, "min_contracts": 1},
+			{"tier": "TRUSTED", "min_score": 0.7, "min_contracts": 25},
+		},
+		"older_weight": 1,
+	}
+	if code := putPolicy("admin", inverted); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for TRUSTED below VERIFIED, got %d", code)
+	}
+	if code := putPolicy("admin", lenient); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	b, _ := json.Marshal(map[string]any{"contract_id": "contract_p", "provider_id": "prov_p", "outcome": "SUCCESS"})
+	resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = http.Get(ts.URL + "/v1/providers/prov_p/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec struct {
+		TrustTier     string `json:"trust_tier"`
+		PolicyVersion int    `json:"policy_version"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&rec)
+	_ = resp.Body.Close()
+	if rec.TrustTier != "VERIFIED" || rec.PolicyVersion != 2 {
+		t.Fatalf("expected VERIFIED under policy 2, got %+v", rec)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/admin/v1/scoring-policy/versions", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions struct {
+		Policies []struct {
+			Version int `json:"version"`
+		} `json:"policies"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&versions)
+	_ = resp.Body.Close()
+	if len(versions.Policies) != 2 || versions.Policies[0].Version != 2 {
+		t.Fatalf("expected versions [2 1], got %+v", versions.Policies)
+	}
+
+	// Without a configured admin token the admin routes are disabled, even
+	// for a caller sending no token.
+	disabled := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	if _, err := disabled.InitPolicy(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ots := httptest.NewServer(tbhttp.NewRouter(disabled))
+	t.Cleanup(ots.Close)
+	for _, path := range []string{"/admin/v1/scoring-policy", "/admin/v1/providers/prov_p/adjustments", "/internal/v1/trust/export"} {
+		req, _ := http.NewRequest(http.MethodGet, ots.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("%s without a configured admin token: expected 503, got %d", path, resp.StatusCode)
+		}
+	}
+	b, _ = json.Marshal(lenient)
+	req, _ = http.NewRequest(http.MethodPut, ots.URL+"/admin/v1/scoring-policy", bytes.NewReader(b))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("policy change without a configured admin token: expected 503, got %d", resp.StatusCode)
+	}
+}
+
+func TestStoredPolicyIsBackfilledWithDefaults(t *testing.T) {
+	st := tbst.NewMemoryStore()
+	// A policy stored before SLA adherence was scored and probation existed.
+	if err := st.SavePolicy(context.Background(), tbmodel.ScoringPolicy{
+		Version:        4,
+		Tiers:          []tbmodel.TierThreshold{{Tier: tbmodel.TrustTierVerified, MinScore: 0.5, MinContracts: 5}},
+		RecencyWeights: []tbmodel.RecencyWeight{{Outcomes: 10, Weight: 1.0}},
+		OlderWeight:    0.1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	svc := tbsvc.New(st)
+	p, err := svc.InitPolicy(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := tbsvc.DefaultScoringPolicy()
+	if p.Version != 4 || p.OlderWeight != 0.1 {
+		t.Fatalf("expected stored policy version 4 to be kept: %+v", p)
+	}
+	if p.SLAWeight != want.SLAWeight {
+		t.Fatalf("expected sla_weight backfilled to %v, got %v", want.SLAWeight, p.SLAWeight)
+	}
+	if p.ProbationFailures != want.ProbationFailures || p.ProbationSuccesses != want.ProbationSuccesses || p.ProbationDays != want.ProbationDays {
+		t.Fatalf("expected probation settings backfilled to %d/%d/%d, got %d/%d/%d",
+			want.ProbationFailures, want.ProbationSuccesses, want.ProbationDays,
+			p.ProbationFailures, p.ProbationSuccesses, p.ProbationDays)
+	}
+}
+
+func TestManualScoreAdjustments(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{AdminToken: "admin", InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{"contract_id": "contract_a", "provider_id": "prov_a", "outcome": "SUCCESS"})
+	resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	type result struct {
+		Adjustment struct {
+			ID string `json:"id"`
+		} `json:"adjustment"`
+		PreviousScore float64 `json:"previous_score"`
+		NewScore      float64 `json:"new_score"`
+	}
+	post := func(path string, body map[string]any) (result, int) {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+path, bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer admin")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out result
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out, resp.StatusCode
+	}
+	expires := time.Now().UTC().Add(30 * 24 * time.Hour)
+	penalty := map[string]any{
+		"delta":       -0.2,
+		"reason_code": "FRAUD_PENALTY",
+		"reason":      "duplicate accounts bidding on the same work",
+		"expires_at":  expires,
+	}
+	// The audited actor is the admin principal, whatever the body claims.
+	penalty["actor"] = "ops@example.com"
+	applied, code := post("/admin/v1/providers/prov_a/adjustments", penalty)
+	if code != http.StatusCreated || math.Abs(applied.PreviousScore-applied.NewScore-0.2) > 1e-6 {
+		t.Fatalf("expected a 0.2 drop, got %+v (status %d)", applied, code)
+	}
+	penalty["delta"] = -0.1
+	if _, code := post("/admin/v1/providers/prov_a/adjustments", penalty); code != http.StatusConflict {
+		t.Fatalf("expected 409 past the adjustment bound, got %d", code)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/providers/prov_a/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec struct {
+		ScoreBreakdown struct {
+			ManualAdjustment float64 `json:"manual_adjustment"`
+			Adjustments      []struct {
+				ReasonCode string `json:"reason_code"`
+			} `json:"adjustments"`
+		} `json:"score_breakdown"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&rec)
+	_ = resp.Body.Close()
+	if rec.ScoreBreakdown.ManualAdjustment != -0.2 || len(rec.ScoreBreakdown.Adjustments) != 1 || rec.ScoreBreakdown.Adjustments[0].ReasonCode != "FRAUD_PENALTY" {
+		t.Fatalf("adjustment missing from breakdown: %+v", rec.ScoreBreakdown)
+	}
+
+	revoked, code := post("/admin/v1/providers/prov_a/adjustments/"+applied.Adjustment.ID+"/revoke",
+		map[string]any{"reason": "accounts belong to separate teams"})
+	if code != http.StatusOK || math.Abs(revoked.NewScore-applied.PreviousScore) > 1e-6 {
+		t.Fatalf("expected the score restored to %v, got %+v (status %d)", applied.PreviousScore, revoked, code)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/admin/v1/providers/prov_a/adjustments", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var audit struct {
+		Adjustments []struct {
+			Actor     string `json:"actor"`
+			RevokedBy string `json:"revoked_by"`
+		} `json:"adjustments"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&audit)
+	_ = resp.Body.Close()
+	if len(audit.Adjustments) != 1 || audit.Adjustments[0].Actor != "admin" || audit.Adjustments[0].RevokedBy != "admin" {
+		t.Fatalf("unexpected audit log: %+v", audit.Adjustments)
+	}
+}
+
+func TestSLAAdherenceLowersLateProviders(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	report := func(providerID string, i int, latencyMs int) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{
+			"contract_id":    providerID + "_" + string(rune('a'+i)),
+			"provider_id":    providerID,
+			"outcome":        "SUCCESS",
+			"latency_ms":     latencyMs,
+			"sla_latency_ms": 1000,
+		})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		report("prov_punctual", i, 400)
+		report("prov_late", i, 2500)
+	}
+
+	type trust struct {
+		TrustScore   float64  `json:"trust_score"`
+		BaseScore    float64  `json:"base_score"`
+		SLAAdherence *float64 `json:"sla_adherence"`
+		SLABreaches  int      `json:"sla_breaches"`
+	}
+	get := func(providerID string) trust {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/providers/" + providerID + "/trust")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out trust
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+	punctual, late := get("prov_punctual"), get("prov_late")
+	if punctual.BaseScore != late.BaseScore {
+		t.Fatalf("outcome scores should match: %v vs %v", punctual.BaseScore, late.BaseScore)
+	}
+	if late.SLAAdherence == nil || *late.SLAAdherence != 0 || late.SLABreaches != 4 {
+		t.Fatalf("expected 4 breaches and 0 adherence, got %+v", late)
+	}
+	if late.TrustScore >= punctual.TrustScore {
+		t.Fatalf("late provider should score below punctual one: %v >= %v", late.TrustScore, punctual.TrustScore)
+	}
+}
+
+func TestContractEventIngestion(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	type ack struct {
+		Outcome   string `json:"outcome"`
+		Recorded  bool   `json:"recorded"`
+		Duplicate bool   `json:"duplicate"`
+		Ignored   bool   `json:"ignored"`
+	}
+	send := func(eventType string, data map[string]any) ack {
+		t.Helper()
+		data["provider_id"] = "prov_e"
+		b, _ := json.Marshal(map[string]any{
+			"event_id":   "evt_" + eventType,
+			"event_type": eventType,
+			"timestamp":  time.Now().UTC(),
+			"source":     "aex-contract-engine",
+			"data":       data,
+		})
+		resp, err := postInternal(ts.URL+"/internal/v1/events", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != 200 {
+			t.Fatalf("%s: expected 200, got %d", eventType, resp.StatusCode)
+		}
+		var out ack
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	if a := send("contract.completed", map[string]any{"contract_id": "c1", "success": true, "duration_ms": 900}); !a.Recorded || a.Outcome != "SUCCESS" {
+		t.Fatalf("unexpected completed ack: %+v", a)
+	}
+	if a := send("contract.completed", map[string]any{"contract_id": "c1", "success": true}); a.Recorded || !a.Duplicate {
+		t.Fatalf("expected duplicate completion to be skipped: %+v", a)
+	}
+	if a := send("contract.failed", map[string]any{"contract_id": "c2", "failure_reason": "provider_no_show", "reported_by": "system"}); a.Outcome != "FAILURE_PROVIDER" {
+		t.Fatalf("unexpected failed ack: %+v", a)
+	}
+	if a := send("contract.disputed", map[string]any{"contract_id": "c3", "reason": "wrong output"}); a.Outcome != "DISPUTE_OPEN" {
+		t.Fatalf("unexpected disputed ack: %+v", a)
+	}
+	if a := send("contract.started", map[string]any{"contract_id": "c4"}); !a.Ignored {
+		t.Fatalf("expected contract.started to be ignored: %+v", a)
+	}
+
+	// The direct outcome API dedupes against ingested events too.
+	b, _ := json.Marshal(map[string]any{"contract_id": "c2", "provider_id": "prov_e", "outcome": "FAILURE_PROVIDER"})
+	resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = http.Get(ts.URL + "/v1/providers/prov_e/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec struct {
+		TotalContracts    int `json:"total_contracts"`
+		FailedContracts   int `json:"failed_contracts"`
+		DisputedContracts int `json:"disputed_contracts"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&rec)
+	_ = resp.Body.Close()
+	if rec.TotalContracts != 3 || rec.FailedContracts != 1 || rec.DisputedContracts != 1 {
+		t.Fatalf("unexpected contract counts: %+v", rec)
+	}
+}
+
+// racingOutcomeStore holds the first two outcome lookups until both have
+// been made, so two reports of one contract both find no outcome.
+type racingOutcomeStore struct {
+	*tbst.MemoryStore
+	lookups atomic.Int32
+	both    chan struct{}
+}
+
+func (s *racingOutcomeStore) GetOutcome(ctx context.Context, providerID, contractID string) (*tbmodel.ContractOutcome, error) {
+	out, err := s.MemoryStore.GetOutcome(ctx, providerID, contractID)
+	switch s.lookups.Add(1) {
+	case 1:
+		<-s.both
+	case 2:
+		close(s.both)
+	}
+	return out, err
+}
+
+func TestConcurrentOutcomeReportsRecordOnce(t *testing.T) {
+	st := &racingOutcomeStore{MemoryStore: tbst.NewMemoryStore(), both: make(chan struct{})}
+	svc := tbsvc.NewWithOptions(st, tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	// The contract engine reports a no-show both directly and as an event.
+	direct, _ := json.Marshal(map[string]any{"contract_id": "c1", "provider_id": "prov_r", "outcome": "FAILURE_PROVIDER"})
+	event, _ := json.Marshal(map[string]any{
+		"event_id":   "evt_1",
+		"event_type": "contract.failed",
+		"timestamp":  time.Now().UTC(),
+		"source":     "aex-contract-engine",
+		"data":       map[string]any{"contract_id": "c1", "provider_id": "prov_r", "failure_reason": "provider_no_show", "reported_by": "system"},
+	})
+	var wg sync.WaitGroup
+	var recorded atomic.Int32
+	for _, report := range []struct {
+		path string
+		body []byte
+	}{{"/internal/v1/outcomes", direct}, {"/internal/v1/events", event}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := postInternal(ts.URL+report.path, bytes.NewReader(report.body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() { _ = resp.Body.Close() }()
+			var ack struct {
+				Recorded bool `json:"recorded"`
+			}
+			_ = json.NewDecoder(resp.Body).Decode(&ack)
+			if ack.Recorded {
+				recorded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := recorded.Load(); n != 1 {
+		t.Fatalf("expected one report recorded, got %d", n)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/providers/prov_r/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var rec struct {
+		TotalContracts  int `json:"total_contracts"`
+		FailedContracts int `json:"failed_contracts"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&rec)
+	if rec.TotalContracts != 1 || rec.FailedContracts != 1 {
+		t.Fatalf("expected the failure counted once: %+v", rec)
+	}
+}
+
+func TestSupersedeOutcomeOnDisputeResolution(t *testing.T) {
+	contracts := map[string]map[string]any{
+		"c_ok":       {"contract_id": "c_ok", "provider_id": "prov_d", "consumer_id": "cons_1", "status": "COMPLETED"},
+		"c_disputed": {"contract_id": "c_disputed", "provider_id": "prov_d", "consumer_id": "cons_1", "status": "FAILED"},
+	}
+	ce := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := contracts[strings.TrimPrefix(r.URL.Path, "/v1/contracts/")]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(c)
+	}))
+	t.Cleanup(ce.Close)
+
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken, ContractEngineURL: ce.URL})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	for contractID, outcome := range map[string]string{"c_ok": "SUCCESS", "c_disputed": "DISPUTE_OPEN"} {
+		b, _ := json.Marshal(map[string]any{"contract_id": contractID, "provider_id": "prov_d", "outcome": outcome})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	type result struct {
+		Changed           bool    `json:"changed"`
+		SupersededOutcome string  `json:"superseded_outcome"`
+		PreviousScore     float64 `json:"previous_score"`
+		NewScore          float64 `json:"new_score"`
+	}
+	supersede := func(providerID, outcome string) (int, result) {
+		t.Helper()
+		b, _ := json.Marshal(map[string]any{"provider_id": providerID, "outcome": outcome})
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/internal/v1/outcomes/c_disputed", bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer "+internalToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out result
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	put := func(outcome string) result {
+		t.Helper()
+		code, out := supersede("prov_d", outcome)
+		if code != 200 {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		return out
+	}
+
+	// The replacement is checked like any new outcome.
+	if code, _ := supersede("prov_d", "GREAT"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown outcome, got %d", code)
+	}
+	if code, _ := supersede("prov_d", "SUCCESS"); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for success on an uncompleted contract, got %d", code)
+	}
+	if code, _ := supersede("prov_other", "DISPUTE_WON"); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for another provider's contract, got %d", code)
+	}
+
+	lost := put("DISPUTE_LOST")
+	if !lost.Changed || lost.SupersededOutcome != "DISPUTE_OPEN" || lost.NewScore >= lost.PreviousScore {
+		t.Fatalf("expected the lost dispute to lower the score: %+v", lost)
+	}
+	if again := put("DISPUTE_LOST"); again.Changed {
+		t.Fatalf("repeating the same outcome should not change anything: %+v", again)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/providers/prov_d/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec struct {
+		TotalContracts int `json:"total_contracts"`
+		DisputesLost   int `json:"disputes_lost"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&rec)
+	_ = resp.Body.Close()
+	if rec.TotalContracts != 2 || rec.DisputesLost != 1 {
+		t.Fatalf("superseded outcome should not count: %+v", rec)
+	}
+}
+
+func TestVerificationProvenanceAndExpiry(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{VerificationTTL: 24 * time.Hour, InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	b, _ := json.Marshal(map[string]any{
+		"identity_verified": true,
+		"endpoint_verified": true,
+		"source":            "aex-provider-registry",
+		"method":            "domain_dns_txt",
+		"expires_at":        time.Now().UTC().Add(150 * time.Millisecond),
+	})
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/internal/v1/providers/prov_v/verification", bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+internalToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	resp, err = http.Get(ts.URL + "/v1/providers/prov_v/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec struct {
+		IdentityVerified     bool `json:"identity_verified"`
+		IdentityVerification struct {
+			Source string `json:"source"`
+			Method string `json:"method"`
+		} `json:"identity_verification"`
+		ScoreBreakdown struct {
+			VerificationBonus float64 `json:"verification_bonus"`
+		} `json:"score_breakdown"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&rec)
+	_ = resp.Body.Close()
+	if rec.IdentityVerified || rec.ScoreBreakdown.VerificationBonus != 0 {
+		t.Fatalf("expired verification should not count: %+v", rec)
+	}
+	if rec.IdentityVerification.Source != "aex-provider-registry" || rec.IdentityVerification.Method != "domain_dns_txt" {
+		t.Fatalf("provenance missing: %+v", rec.IdentityVerification)
+	}
+
+	resp, err = http.Get(ts.URL + "/internal/v1/verifications/due?within=1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var due struct {
+		Due []struct {
+			ProviderID string `json:"provider_id"`
+			Kind       string `json:"kind"`
+			Expired    bool   `json:"expired"`
+		} `json:"due"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&due)
+	_ = resp.Body.Close()
+	if len(due.Due) != 2 || !due.Due[0].Expired || due.Due[0].ProviderID != "prov_v" {
+		t.Fatalf("expected both verifications due and expired, got %+v", due.Due)
+	}
+}
+
+func TestTrustExplanation(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Get(ts.URL + "/v1/providers/prov_x/trust/explanation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown provider, got %d", resp.StatusCode)
+	}
+
+	for i, outcome := range []string{"SUCCESS", "SUCCESS", "FAILURE_PROVIDER"} {
+		b, _ := json.Marshal(map[string]any{"contract_id": "cx_" + string(rune('a'+i)), "provider_id": "prov_x", "outcome": outcome})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	b, _ := json.Marshal(map[string]any{"identity_verified": true})
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/internal/v1/providers/prov_x/verification", bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+internalToken)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = http.Get(ts.URL + "/v1/providers/prov_x/trust/explanation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ex struct {
+		TrustTier     string             `json:"trust_tier"`
+		PolicyVersion int                `json:"policy_version"`
+		BaseScore     float64            `json:"base_score"`
+		Modifiers     map[string]float64 `json:"modifiers"`
+		OutcomeCounts map[string]int     `json:"outcome_counts"`
+		Tiers         []struct {
+			Tier         string `json:"tier"`
+			Met          bool   `json:"met"`
+			ContractsGap int    `json:"contracts_gap"`
+		} `json:"tiers"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&ex)
+	_ = resp.Body.Close()
+	if ex.PolicyVersion != 1 || ex.Modifiers["identity"] != 0.05 || ex.Modifiers["endpoint"] != 0 {
+		t.Fatalf("unexpected explanation: %+v", ex)
+	}
+	if ex.OutcomeCounts["SUCCESS"] != 2 || ex.OutcomeCounts["FAILURE_PROVIDER"] != 1 {
+		t.Fatalf("unexpected outcome counts: %+v", ex.OutcomeCounts)
+	}
+	verified := ex.Tiers[len(ex.Tiers)-1]
+	if verified.Tier != "VERIFIED" || verified.Met || verified.ContractsGap != 2 {
+		t.Fatalf("expected VERIFIED to be 2 contracts away, got %+v", verified)
+	}
+}
+
+func TestOutcomeIntegrityChecks(t *testing.T) {
+	contracts := map[string]map[string]any{
+		"c_done": {"contract_id": "c_done", "provider_id": "prov_s", "consumer_id": "cons_1", "status": "COMPLETED", "agreed_price": 10.0},
+		"c_exec": {"contract_id": "c_exec", "provider_id": "prov_s", "consumer_id": "cons_1", "status": "EXECUTING"},
+	}
+	for i := 0; i < 25; i++ {
+		id := fmt.Sprintf("c_streak_%d", i)
+		contracts[id] = map[string]any{"contract_id": id, "provider_id": "prov_s", "consumer_id": "cons_1", "status": "COMPLETED"}
+	}
+	ce := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := contracts[strings.TrimPrefix(r.URL.Path, "/v1/contracts/")]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(c)
+	}))
+	t.Cleanup(ce.Close)
+
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{
+		InternalToken:     internalToken,
+		ContractEngineURL: ce.URL,
+		SuccessRateLimit:  22,
+	})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	post := func(token string, body map[string]any) int {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	outcome := func(contractID, providerID, result string) map[string]any {
+		return map[string]any{"contract_id": contractID, "provider_id": providerID, "outcome": result}
+	}
+
+	if code := post("", outcome("c_done", "prov_s", "SUCCESS")); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", code)
+	}
+	if code := post("internal", outcome("c_unknown", "prov_s", "SUCCESS")); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unknown contract, got %d", code)
+	}
+	if code := post("internal", outcome("c_done", "prov_other", "SUCCESS")); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for another provider's contract, got %d", code)
+	}
+	if code := post("internal", outcome("c_exec", "prov_s", "SUCCESS")); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for success on an unfinished contract, got %d", code)
+	}
+	if code := post("internal", outcome("c_done", "prov_s", "SUCCESS")); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	// Twenty straight successes for one consumer are flagged, and the
+	// hourly success limit is enforced.
+	limited := 0
+	for i := 0; i < 25; i++ {
+		switch code := post("internal", outcome(fmt.Sprintf("c_streak_%d", i), "prov_s", "SUCCESS")); code {
+		case http.StatusOK:
+		case http.StatusTooManyRequests:
+			limited++
+		default:
+			t.Fatalf("streak outcome %d: unexpected status %d", i, code)
+		}
+	}
+	if limited != 4 {
+		t.Fatalf("expected 4 rate-limited successes, got %d", limited)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/providers/prov_s/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var rec struct {
+		TotalContracts int      `json:"total_contracts"`
+		Flags          []string `json:"flags"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&rec)
+	if rec.TotalContracts != 22 {
+		t.Fatalf("expected 22 recorded outcomes, got %d", rec.TotalContracts)
+	}
+	if len(rec.Flags) != 1 || rec.Flags[0] != "SUCCESS_STREAK_SINGLE_CONSUMER" {
+		t.Fatalf("expected success streak flag, got %v", rec.Flags)
+	}
+}
+
+func TestInternalRoutesRequireAuthorization(t *testing.T) {
+	event, _ := json.Marshal(map[string]any{
+		"event_id":   "evt_1",
+		"event_type": "contract.failed",
+		"timestamp":  time.Now().UTC(),
+		"source":     "aex-contract-engine",
+		"data":       map[string]any{"contract_id": "c1", "provider_id": "prov_i", "failure_reason": "provider_error"},
+	})
+	send := func(ts *httptest.Server, method, path string, body []byte, header, value string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(body))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Without an internal token or service tokens nothing is accepted.
+	open := httptest.NewServer(tbhttp.NewRouter(tbsvc.New(tbst.NewMemoryStore())))
+	t.Cleanup(open.Close)
+	outcome := []byte(`{"contract_id":"c1","provider_id":"prov_i","outcome":"SUCCESS"}`)
+	verification := []byte(`{"endpoint_verified":true}`)
+	for _, c := range []struct {
+		method, path string
+		body         []byte
+	}{
+		{http.MethodPost, "/internal/v1/outcomes", outcome},
+		{http.MethodPost, "/internal/v1/events", event},
+		{http.MethodPut, "/internal/v1/providers/prov_i/verification", verification},
+	} {
+		if code := send(open, c.method, c.path, c.body, "", ""); code != http.StatusUnauthorized {
+			t.Fatalf("%s %s: expected 401 with no internal auth configured, got %d", c.method, c.path, code)
+		}
+	}
+
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken, EventsSecret: "bus-secret"})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+	if code := send(ts, http.MethodPut, "/internal/v1/providers/prov_i/verification", verification, "", ""); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unauthenticated verification, got %d", code)
+	}
+	if code := send(ts, http.MethodPost, "/internal/v1/events", event, events.SignatureHeader, events.Sign([]byte("other"), event)); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an event signed with another secret, got %d", code)
+	}
+	if code := send(ts, http.MethodPost, "/internal/v1/events", event, events.SignatureHeader, events.Sign([]byte("bus-secret"), event)); code != http.StatusOK {
+		t.Fatalf("expected 200 for an event signed by the bus, got %d", code)
+	}
+	if code := send(ts, http.MethodPost, "/internal/v1/events", event, "Authorization", "Bearer "+internalToken); code != http.StatusOK {
+		t.Fatalf("expected 200 for an event with the internal token, got %d", code)
+	}
+}
+
+func TestConsumerReputation(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	post := func(path string, body map[string]any) map[string]any {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		resp, err := postInternal(ts.URL+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, resp.StatusCode)
+		}
+		var out map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+	for i := 0; i < 6; i++ {
+		post("/internal/v1/outcomes", map[string]any{
+			"contract_id": fmt.Sprintf("cr_%d", i), "provider_id": "prov_r", "consumer_id": "cons_r", "outcome": "SUCCESS",
+		})
+	}
+	post("/internal/v1/outcomes", map[string]any{
+		"contract_id": "cr_disputed", "provider_id": "prov_r", "consumer_id": "cons_r", "outcome": "DISPUTE_WON",
+	})
+	cancelled := map[string]any{
+		"event_id":   "evt_cancel",
+		"event_type": "contract.cancelled",
+		"timestamp":  time.Now().UTC(),
+		"data":       map[string]any{"contract_id": "cr_cancel", "provider_id": "prov_r", "consumer_id": "cons_r", "reason": "changed mind"},
+	}
+	if out := post("/internal/v1/events", cancelled); out["recorded"] != true {
+		t.Fatalf("expected cancellation to be recorded: %v", out)
+	}
+	if out := post("/internal/v1/events", cancelled); out["duplicate"] != true {
+		t.Fatalf("expected repeated cancellation to be a duplicate: %v", out)
+	}
+	post("/internal/v1/consumers/cons_r/incidents", map[string]any{"contract_id": "cr_0", "kind": "PAYMENT_FAILURE", "reason": "insufficient funds"})
+
+	get := func(consumerID string) map[string]any {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/consumers/" + consumerID + "/trust")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var out map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+	ct := get("cons_r")
+	if ct["total_contracts"] != 8.0 || ct["cancelled_contracts"] != 1.0 || ct["payment_failures"] != 1.0 || ct["spurious_disputes"] != 1.0 {
+		t.Fatalf("unexpected consumer counts: %v", ct)
+	}
+	if score, _ := ct["reputation_score"].(float64); math.Abs(score-0.8875) > 1e-9 {
+		t.Fatalf("expected reputation 0.8875, got %v", ct["reputation_score"])
+	}
+	if _, ok := get("cons_new")["reputation_score"]; ok {
+		t.Fatal("expected no reputation_score for a consumer without contracts")
+	}
+}
+
+func TestTrustLeaderboardAndStats(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("capability") != "nlp" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"providers": []any{}})
+			return
+		}
+		// Two pages, to exercise the cursor.
+		if r.URL.Query().Get("cursor") == "" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"providers": []any{map[string]any{"provider_id": "lb_a"}}, "next_cursor": "p2"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"providers": []any{map[string]any{"provider_id": "lb_b"}}})
+	}))
+	t.Cleanup(registry.Close)
+
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{ProviderRegistryURL: registry.URL, InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	record := func(providerID, outcome string, n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			b, _ := json.Marshal(map[string]any{"contract_id": fmt.Sprintf("%s_%s_%d", providerID, outcome, i), "provider_id": providerID, "outcome": outcome})
+			resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+		}
+	}
+	record("lb_a", "SUCCESS", 10)
+	record("lb_b", "SUCCESS", 2)
+	record("lb_b", "FAILURE_PROVIDER", 6)
+	record("lb_c", "SUCCESS", 6)
+	resp, err := http.Get(ts.URL + "/v1/providers/lb_new/trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	type board struct {
+		Providers []struct {
+			Rank       int     `json:"rank"`
+			ProviderID string  `json:"provider_id"`
+			TrustTier  string  `json:"trust_tier"`
+			Success    float64 `json:"success_rate"`
+		} `json:"providers"`
+		Total int `json:"total"`
+	}
+	get := func(query string, want int) board {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/trust/leaderboard" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != want {
+			t.Fatalf("%s: expected %d, got %d", query, want, resp.StatusCode)
+		}
+		var b board
+		_ = json.NewDecoder(resp.Body).Decode(&b)
+		return b
+	}
+
+	all := get("", http.StatusOK)
+	if all.Total != 3 || all.Providers[0].ProviderID != "lb_a" || all.Providers[2].ProviderID != "lb_b" {
+		t.Fatalf("unexpected leaderboard: %+v", all)
+	}
+	if all.Providers[2].Rank != 3 || all.Providers[2].Success != 0.25 {
+		t.Fatalf("unexpected last entry: %+v", all.Providers[2])
+	}
+	if b := get("?limit=1", http.StatusOK); len(b.Providers) != 1 || b.Total != 3 {
+		t.Fatalf("expected one of three entries: %+v", b)
+	}
+	if b := get("?category=nlp", http.StatusOK); b.Total != 2 || b.Providers[0].ProviderID != "lb_a" || b.Providers[1].ProviderID != "lb_b" {
+		t.Fatalf("unexpected category leaderboard: %+v", b)
+	}
+	if b := get("?tier=verified", http.StatusOK); b.Total != 2 {
+		t.Fatalf("expected the two VERIFIED providers: %+v", b)
+	}
+	get("?limit=0", http.StatusBadRequest)
+
+	resp, err = http.Get(ts.URL + "/v1/trust/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var stats struct {
+		Providers              int            `json:"providers"`
+		ProvidersWithContracts int            `json:"providers_with_contracts"`
+		TotalContracts         int            `json:"total_contracts"`
+		Tiers                  map[string]int `json:"tiers"`
+		AverageScore           float64        `json:"average_score"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&stats)
+	if stats.Providers != 4 || stats.ProvidersWithContracts != 3 || stats.TotalContracts != 24 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.Tiers["VERIFIED"] != 2 || stats.Tiers["UNVERIFIED"] != 2 || stats.AverageScore <= 0 {
+		t.Fatalf("unexpected tier distribution: %+v", stats)
+	}
+}
+
+func TestRecomputeAppliesCurrentPolicy(t *testing.T) {
+	st := tbst.NewMemoryStore()
+	svc := tbsvc.NewWithOptions(st, tbsvc.Options{AdminToken: "admin", InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	do := func(method, path string, body any) *http.Response {
+		t.Helper()
+		var rd io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			rd = bytes.NewReader(b)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, rd)
+		req.Header.Set("Authorization", "Bearer admin")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	// Ten recent successes and ten older failures.
+	base := time.Now().UTC().Add(-24 * time.Hour)
+	for i := 0; i < 20; i++ {
+		outcome := "SUCCESS"
+		if i < 10 {
+			outcome = "FAILURE_PROVIDER"
+		}
+		b, _ := json.Marshal(map[string]any{
+			"contract_id": fmt.Sprintf("rc_%d", i), "provider_id": "prov_rc", "outcome": outcome,
+			"completed_at": base.Add(time.Duration(i) * time.Minute),
+		})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	// An outcome backfilled straight into the store has no trust record yet.
+	if err := st.SaveOutcome(context.Background(), tbmodel.ContractOutcome{
+		ID: "out_backfill", ContractID: "rc_backfill", ProviderID: "prov_backfill", Outcome: tbmodel.OutcomeSuccess, CompletedAt: base,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the ten most recent outcomes count under the new policy.
+	resp := do(http.MethodPut, "/admin/v1/scoring-policy", map[string]any{
+		"tiers":           []map[string]any{{"tier": "VERIFIED", "min_score": 0.5, "min_contracts": 5}},
+		"recency_weights": []map[string]any{{"outcomes": 10, "weight": 1.0}},
+		"older_weight":    0,
+	})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected policy update to succeed, got %d", resp.StatusCode)
+	}
+
+	resp = do(http.MethodPost, "/internal/v1/recompute?provider_id=prov_unknown", nil)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown provider, got %d", resp.StatusCode)
+	}
+
+	resp = do(http.MethodPost, "/internal/v1/recompute", nil)
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var out struct {
+		PolicyVersion int `json:"policy_version"`
+		Recomputed    int `json:"recomputed"`
+		Changed       []struct {
+			ProviderID    string  `json:"provider_id"`
+			PreviousScore float64 `json:"previous_score"`
+			NewScore      float64 `json:"new_score"`
+		} `json:"changed"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if out.PolicyVersion != 2 || out.Recomputed != 2 || len(out.Changed) != 2 {
+		t.Fatalf("unexpected recompute result: %+v", out)
+	}
+	for _, c := range out.Changed {
+		if c.ProviderID == "prov_rc" && c.NewScore <= c.PreviousScore {
+			t.Fatalf("expected dropping old failures to raise the score: %+v", c)
+		}
+	}
+
+	rec, err := st.GetTrustRecord(context.Background(), "prov_backfill")
+	if err != nil || rec == nil || rec.TotalContracts != 1 {
+		t.Fatalf("expected backfilled provider to get a trust record: %+v %v", rec, err)
+	}
+}
+
+func TestProbationAfterConsecutiveFailures(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	n := 0
+	record := func(outcome string) {
+		t.Helper()
+		n++
+		b, _ := json.Marshal(map[string]any{
+			"contract_id": fmt.Sprintf("pb_%d", n), "provider_id": "prov_pb", "outcome": outcome,
+			"completed_at": time.Now().UTC().Add(time.Duration(n) * time.Second),
+		})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	type trust struct {
+		OnProbation bool `json:"on_probation"`
+		Probation   *struct {
+			Failures  int        `json:"failures"`
+			EndedAt   *time.Time `json:"ended_at"`
+			EndReason string     `json:"end_reason"`
+		} `json:"probation"`
+	}
+	get := func() trust {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/v1/providers/prov_pb/trust")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var tr trust
+		_ = json.NewDecoder(resp.Body).Decode(&tr)
+		return tr
+	}
+
+	for i := 0; i < 4; i++ {
+		record("SUCCESS")
+	}
+	record("FAILURE_PROVIDER")
+	record("DISPUTE_LOST")
+	if tr := get(); tr.OnProbation || tr.Probation != nil {
+		t.Fatalf("expected no probation after two failures: %+v", tr)
+	}
+	record("FAILURE_PROVIDER")
+	if tr := get(); !tr.OnProbation || tr.Probation == nil || tr.Probation.Failures != 3 {
+		t.Fatalf("expected probation after three consecutive failures: %+v", tr)
+	}
+
+	b, _ := json.Marshal(map[string]any{"provider_ids": []string{"prov_pb"}})
+	resp, err := http.Post(ts.URL+"/internal/v1/trust/batch", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch struct {
+		Probation map[string]bool `json:"probation"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&batch)
+	_ = resp.Body.Close()
+	if !batch.Probation["prov_pb"] {
+		t.Fatalf("expected batch response to list the provider on probation: %+v", batch)
+	}
+
+	for i := 0; i < 4; i++ {
+		record("SUCCESS")
+	}
+	if tr := get(); !tr.OnProbation {
+		t.Fatal("expected probation to hold until five consecutive successes")
+	}
+	record("SUCCESS")
+	tr := get()
+	if tr.OnProbation || tr.Probation == nil || tr.Probation.EndedAt == nil || tr.Probation.EndReason != "SUCCESSES" {
+		t.Fatalf("expected probation to end after five successes: %+v", tr)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	svc := tbsvc.NewWithOptions(tbst.NewMemoryStore(), tbsvc.Options{InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	for i, outcome := range []string{"SUCCESS", "SUCCESS", "FAILURE_PROVIDER"} {
+		b, _ := json.Marshal(map[string]any{
+			"contract_id": fmt.Sprintf("m_%d", i), "provider_id": fmt.Sprintf("prov_m%d", i%2), "outcome": outcome,
+		})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected text exposition, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		"# TYPE aex_trust_outcomes_ingested_total counter",
+		`aex_trust_outcomes_ingested_total{outcome="SUCCESS"} 2`,
+		`aex_trust_outcomes_ingested_total{outcome="FAILURE_PROVIDER"} 1`,
+		`aex_trust_providers{tier="UNVERIFIED"} 2`,
+		`aex_trust_providers{tier="PREFERRED"} 0`,
+		"# TYPE aex_trust_score_computation_seconds histogram",
+		`aex_trust_score_computation_seconds_bucket{trigger="outcome",le="+Inf"} 3`,
+		`aex_trust_score_computation_seconds_count{trigger="outcome"} 3`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestExportStreamsPagesWithCursor(t *testing.T) {
+	st := tbst.NewMemoryStore()
+	svc := tbsvc.NewWithOptions(st, tbsvc.Options{AdminToken: "admin", InternalToken: internalToken})
+	ts := httptest.NewServer(tbhttp.NewRouter(svc))
+	t.Cleanup(ts.Close)
+
+	for i, provider := range []string{"prov_ex_a", "prov_ex_a", "prov_ex_b"} {
+		b, _ := json.Marshal(map[string]any{
+			"contract_id": fmt.Sprintf("ex_%d", i), "provider_id": provider, "outcome": "SUCCESS",
+		})
+		resp, err := postInternal(ts.URL+"/internal/v1/outcomes", bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if err := st.SaveOutcome(context.Background(), tbmodel.ContractOutcome{
+		ID: "out_ex_c", ContractID: "ex_c", ProviderID: "prov_ex_c", Outcome: tbmodel.OutcomeSuccess, CompletedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	export := func(query string) (int, []tbmodel.ExportLine) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/internal/v1/trust/export"+query, nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var lines []tbmodel.ExportLine
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		dec := json.NewDecoder(resp.Body)
+		for dec.More() {
+			var l tbmodel.ExportLine
+			if err := dec.Decode(&l); err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, l)
+		}
+		return resp.StatusCode, lines
+	}
+
+	resp, err := http.Get(ts.URL + "/internal/v1/trust/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected export to require the admin token, got %d", resp.StatusCode)
+	}
+
+	code, page := export("?limit=2")
+	if code != http.StatusOK || len(page) != 6 {
+		t.Fatalf("expected 2 records, 3 outcomes and page_end, got %d %+v", code, page)
+	}
+	if page[0].Type != tbmodel.ExportTrustRecord || page[0].Record == nil || page[0].Record.ProviderID != "prov_ex_a" ||
+		page[1].Type != tbmodel.ExportOutcome || page[3].ProviderID != "prov_ex_b" {
+		t.Fatalf("unexpected first page order: %+v", page)
+	}
+	end := page[len(page)-1]
+	if end.Type != tbmodel.ExportPageEnd || end.NextCursor != "prov_ex_b" || end.Providers != 2 {
+		t.Fatalf("expected page_end with cursor prov_ex_b, got %+v", end)
+	}
+
+	code, page = export("?limit=2&cursor=" + end.NextCursor)
+	if code != http.StatusOK || len(page) != 2 || page[0].Type != tbmodel.ExportOutcome || page[0].ProviderID != "prov_ex_c" {
+		t.Fatalf("expected the backfilled provider's outcome on the last page, got %+v", page)
+	}
+	if page[1].Type != tbmodel.ExportPageEnd || page[1].NextCursor != "" {
+		t.Fatalf("expected last page_end without cursor, got %+v", page[1])
+	}
+
+	if code, _ := export("?limit=0"); code != http.StatusBadRequest {
+		t.Fatalf("expected invalid limit to be rejected, got %d", code)
+	}
 }
@@ -0,0 +1,60 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Contract holds the contract engine fields the trust broker checks
+// reported outcomes against.
+type Contract struct {
+	ContractID  string  `json:"contract_id"`
+	ConsumerID  string  `json:"consumer_id"`
+	ProviderID  string  `json:"provider_id"`
+	AgreedPrice float64 `json:"agreed_price"`
+	Status      string  `json:"status"`
+}
+
+// ContractEngineClient looks up contracts in the contract engine.
+type ContractEngineClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func NewContractEngineClient(baseURL string) *ContractEngineClient {
+	return &ContractEngineClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetContract returns the contract, including archived ones, or nil when the
+// contract engine does not know it.
+func (c *ContractEngineClient) GetContract(ctx context.Context, contractID string) (*Contract, error) {
+	u := c.baseURL + "/v1/contracts/" + url.PathEscape(contractID) + "?include_archived=true"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("contract-engine returned %d", resp.StatusCode)
+	}
+	var out Contract
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
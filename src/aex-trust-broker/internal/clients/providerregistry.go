@@ -0,0 +1,103 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProviderRegistryClient authenticates providers against the provider
+// registry and lists providers by capability.
+type ProviderRegistryClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func NewProviderRegistryClient(baseURL string) *ProviderRegistryClient {
+	return &ProviderRegistryClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// ValidateAPIKey returns the provider owning apiKey.
+func (c *ProviderRegistryClient) ValidateAPIKey(ctx context.Context, apiKey string) (string, error) {
+	u := c.baseURL + "/internal/v1/providers/validate-key?api_key=" + url.QueryEscape(apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("provider-registry returned %d", resp.StatusCode)
+	}
+	var out struct {
+		ProviderID string `json:"provider_id"`
+		Valid      bool   `json:"valid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	if !out.Valid || out.ProviderID == "" {
+		return "", fmt.Errorf("invalid API key")
+	}
+	return out.ProviderID, nil
+}
+
+// ListProviderIDs returns the active providers declaring capability.
+func (c *ProviderRegistryClient) ListProviderIDs(ctx context.Context, capability string) ([]string, error) {
+	var ids []string
+	cursor := ""
+	for {
+		u := c.baseURL + "/v1/providers?limit=200&capability=" + url.QueryEscape(capability)
+		if cursor != "" {
+			u += "&cursor=" + url.QueryEscape(cursor)
+		}
+		page, next, err := c.listPage(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, page...)
+		if next == "" {
+			return ids, nil
+		}
+		cursor = next
+	}
+}
+
+func (c *ProviderRegistryClient) listPage(ctx context.Context, u string) ([]string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("provider-registry returned %d", resp.StatusCode)
+	}
+	var out struct {
+		Providers []struct {
+			ProviderID string `json:"provider_id"`
+		} `json:"providers"`
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, "", err
+	}
+	ids := make([]string, 0, len(out.Providers))
+	for _, p := range out.Providers {
+		ids = append(ids, p.ProviderID)
+	}
+	return ids, out.NextCursor, nil
+}
@@ -2,17 +2,55 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Config struct {
 	Port string
+	// LogLevel is debug, info, warn or error (LOG_LEVEL).
+	LogLevel string
 
 	MongoURI                string
 	MongoDatabase           string
 	MongoCollectionTrust    string
 	MongoCollectionOutcomes string
+	MongoCollectionHistory  string
+	MongoCollectionWebhooks string
+	MongoCollectionPolicies string
+	MongoCollectionAdjust   string
+	MongoCollectionIncident string
+
+	// DecayHalfLife is the inactivity half-life of outcome-based trust
+	// (TRUST_DECAY_HALF_LIFE, e.g. "2160h"); "0" disables decay.
+	DecayHalfLife time.Duration
+	// VerificationTTL is how long a passed identity or endpoint
+	// verification counts (TRUST_VERIFICATION_TTL); "0" never expires.
+	VerificationTTL time.Duration
+
+	EventsURL           string // optional; receives trust events
+	ProviderRegistryURL string // optional; enables provider trust webhooks
+
+	// PolicyFile is a JSON scoring policy (TRUST_POLICY_FILE) used until a
+	// policy is stored; afterwards change it through the admin API.
+	PolicyFile string
+	// AdminToken protects the /admin routes, which are disabled without it.
+	AdminToken string
+	// InternalToken authorizes the outcome reporting and verification
+	// routes, which refuse every request without it or IdentityURL.
+	InternalToken string
+	// EventsSecret (EVENTS_SECRET) verifies events delivered by the event
+	// bus, which signs them with the same secret.
+	EventsSecret string
+	// IdentityURL, when set, lets those routes accept service account
+	// tokens issued by the identity service.
+	IdentityURL string
+	// ContractEngineURL, when set, checks outcomes against their contracts.
+	ContractEngineURL string
+	// SuccessRateLimit caps successful outcomes per provider per hour
+	// (TRUST_SUCCESS_RATE_LIMIT); 0 disables it.
+	SuccessRateLimit int
 
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
@@ -22,10 +60,27 @@ type Config struct {
 func Load() Config {
 	return Config{
 		Port:                    getenv("PORT", "8080"),
+		LogLevel:                getenv("LOG_LEVEL", "info"),
 		MongoURI:                strings.TrimSpace(os.Getenv("MONGO_URI")),
 		MongoDatabase:           getenv("MONGO_DB", "aex"),
 		MongoCollectionTrust:    getenv("MONGO_COLLECTION_TRUST", "trust_records"),
 		MongoCollectionOutcomes: getenv("MONGO_COLLECTION_OUTCOMES", "contract_outcomes"),
+		MongoCollectionHistory:  getenv("MONGO_COLLECTION_TRUST_HISTORY", "trust_history"),
+		MongoCollectionWebhooks: getenv("MONGO_COLLECTION_TRUST_WEBHOOKS", "trust_webhooks"),
+		MongoCollectionPolicies: getenv("MONGO_COLLECTION_TRUST_POLICIES", "trust_policies"),
+		MongoCollectionAdjust:   getenv("MONGO_COLLECTION_TRUST_ADJUSTMENTS", "trust_adjustments"),
+		MongoCollectionIncident: getenv("MONGO_COLLECTION_CONSUMER_INCIDENTS", "consumer_incidents"),
+		DecayHalfLife:           getenvDuration("TRUST_DECAY_HALF_LIFE", 90*24*time.Hour),
+		VerificationTTL:         getenvDuration("TRUST_VERIFICATION_TTL", 90*24*time.Hour),
+		EventsURL:               strings.TrimSpace(os.Getenv("EVENTS_URL")),
+		ProviderRegistryURL:     strings.TrimRight(strings.TrimSpace(os.Getenv("PROVIDER_REGISTRY_URL")), "/"),
+		PolicyFile:              strings.TrimSpace(os.Getenv("TRUST_POLICY_FILE")),
+		AdminToken:              strings.TrimSpace(os.Getenv("ADMIN_TOKEN")),
+		InternalToken:           strings.TrimSpace(os.Getenv("INTERNAL_TOKEN")),
+		EventsSecret:            strings.TrimSpace(os.Getenv("EVENTS_SECRET")),
+		IdentityURL:             strings.TrimRight(strings.TrimSpace(os.Getenv("IDENTITY_URL")), "/"),
+		ContractEngineURL:       strings.TrimRight(strings.TrimSpace(os.Getenv("CONTRACT_ENGINE_URL")), "/"),
+		SuccessRateLimit:        getenvInt("TRUST_SUCCESS_RATE_LIMIT", 0),
 		ReadTimeout:             10 * time.Second,
 		WriteTimeout:            20 * time.Second,
 		IdleTimeout:             60 * time.Second,
@@ -38,3 +93,27 @@ func getenv(k, def string) string {
 	}
 	return def
 }
+
+func getenvInt(k string, def int) int {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func getenvDuration(k string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def
+	}
+	return d
+}
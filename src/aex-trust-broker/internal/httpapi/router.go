@@ -9,8 +9,30 @@ import (
 func NewRouter(svc *service.Service) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /v1/providers/", svc.HandleGetTrust) // /v1/providers/{id}/trust
+	mux.HandleFunc("GET /v1/providers/{provider_id}/trust/history", svc.HandleTrustHistory)
+	mux.HandleFunc("GET /v1/providers/{provider_id}/trust/explanation", svc.HandleTrustExplanation)
+	mux.HandleFunc("PUT /v1/providers/{provider_id}/trust/webhook", svc.HandlePutWebhook)
+	mux.HandleFunc("GET /v1/providers/{provider_id}/trust/webhook", svc.HandleGetWebhook)
+	mux.HandleFunc("DELETE /v1/providers/{provider_id}/trust/webhook", svc.HandleDeleteWebhook)
+	mux.HandleFunc("GET /v1/consumers/{consumer_id}/trust", svc.HandleGetConsumerTrust)
+	mux.HandleFunc("GET /v1/trust/leaderboard", svc.HandleLeaderboard)
+	mux.HandleFunc("GET /v1/trust/stats", svc.HandleTrustStats)
 	mux.HandleFunc("POST /internal/v1/trust/batch", svc.HandleBatchTrust)
 	mux.HandleFunc("POST /internal/v1/outcomes", svc.HandleRecordOutcome)
+	mux.HandleFunc("PUT /internal/v1/outcomes/{contract_id}", svc.HandleSupersedeOutcome)
+	mux.HandleFunc("POST /internal/v1/events", svc.HandleContractEvent)
+	mux.HandleFunc("POST /internal/v1/consumers/{consumer_id}/incidents", svc.HandleRecordIncident)
+	mux.HandleFunc("PUT /internal/v1/providers/{provider_id}/verification", svc.HandleSetVerification)
+	mux.HandleFunc("GET /internal/v1/verifications/due", svc.HandleVerificationsDue)
+	mux.HandleFunc("POST /internal/v1/recompute", svc.HandleRecompute)
+	mux.HandleFunc("GET /internal/v1/trust/export", svc.HandleExport)
+	mux.HandleFunc("GET /admin/v1/scoring-policy", svc.HandleGetPolicy)
+	mux.HandleFunc("PUT /admin/v1/scoring-policy", svc.HandlePutPolicy)
+	mux.HandleFunc("GET /admin/v1/scoring-policy/versions", svc.HandleListPolicyVersions)
+	mux.HandleFunc("POST /admin/v1/providers/{provider_id}/adjustments", svc.HandleCreateAdjustment)
+	mux.HandleFunc("GET /admin/v1/providers/{provider_id}/adjustments", svc.HandleListAdjustments)
+	mux.HandleFunc("POST /admin/v1/providers/{provider_id}/adjustments/{adjustment_id}/revoke", svc.HandleRevokeAdjustment)
+	mux.HandleFunc("GET /metrics", svc.HandleMetrics)
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
 	return mux
 }
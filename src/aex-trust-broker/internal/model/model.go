@@ -20,11 +20,18 @@ const (
 	OutcomeFailureProvider OutcomeType = "FAILURE_PROVIDER"
 	OutcomeFailureExternal OutcomeType = "FAILURE_EXTERNAL"
 	OutcomeFailureConsumer OutcomeType = "FAILURE_CONSUMER"
-	OutcomeDisputeWon      OutcomeType = "DISPUTE_WON"
-	OutcomeDisputeLost     OutcomeType = "DISPUTE_LOST"
-	OutcomeExpired         OutcomeType = "EXPIRED"
+	// OutcomeDisputeOpen is a contested contract awaiting resolution.
+	OutcomeDisputeOpen OutcomeType = "DISPUTE_OPEN"
+	OutcomeDisputeWon  OutcomeType = "DISPUTE_WON"
+	OutcomeDisputeLost OutcomeType = "DISPUTE_LOST"
+	OutcomeExpired     OutcomeType = "EXPIRED"
 )
 
+// FlagSuccessStreak marks a provider whose most recent outcomes are all
+// successes for a single consumer, the pattern of a provider awarding itself
+// contracts through a consumer it controls.
+const FlagSuccessStreak = "SUCCESS_STREAK_SINGLE_CONSUMER"
+
 type TrustRecord struct {
 	ProviderID string `json:"provider_id" bson:"provider_id"`
 
@@ -36,6 +43,9 @@ type TrustRecord struct {
 	EndpointVerified   bool `json:"endpoint_verified" bson:"endpoint_verified"`
 	ComplianceVerified bool `json:"compliance_verified" bson:"compliance_verified"`
 
+	IdentityVerification *VerificationStatus `json:"identity_verification,omitempty" bson:"identity_verification,omitempty"`
+	EndpointVerification *VerificationStatus `json:"endpoint_verification,omitempty" bson:"endpoint_verification,omitempty"`
+
 	TotalContracts      int `json:"total_contracts" bson:"total_contracts"`
 	SuccessfulContracts int `json:"successful_contracts" bson:"successful_contracts"`
 	FailedContracts     int `json:"failed_contracts" bson:"failed_contracts"`
@@ -43,9 +53,174 @@ type TrustRecord struct {
 	DisputesWon         int `json:"disputes_won" bson:"disputes_won"`
 	DisputesLost        int `json:"disputes_lost" bson:"disputes_lost"`
 
+	// SLAAdherence is the recency-weighted share of outcomes with SLA data
+	// that met their SLA; nil until one is reported.
+	SLAAdherence         *float64 `json:"sla_adherence,omitempty" bson:"sla_adherence,omitempty"`
+	SLAReportedContracts int      `json:"sla_reported_contracts" bson:"sla_reported_contracts"`
+	SLABreaches          int      `json:"sla_breaches" bson:"sla_breaches"`
+
 	RegisteredAt   time.Time  `json:"registered_at" bson:"registered_at"`
 	LastContractAt *time.Time `json:"last_contract_at,omitempty" bson:"last_contract_at,omitempty"`
 	LastUpdated    time.Time  `json:"last_updated" bson:"last_updated"`
+
+	ScoreBreakdown *ScoreBreakdown `json:"score_breakdown,omitempty" bson:"score_breakdown,omitempty"`
+	// EffectiveOutcomes is the number of equally weighted outcomes the
+	// recency-weighted BaseScore is as informative as.
+	EffectiveOutcomes float64          `json:"effective_outcomes" bson:"effective_outcomes"`
+	Confidence        *ScoreConfidence `json:"confidence,omitempty" bson:"confidence,omitempty"`
+
+	// PolicyVersion is the scoring policy that produced TrustScore.
+	PolicyVersion int `json:"policy_version" bson:"policy_version"`
+	// OnProbation is set while Probation is in force. It does not change
+	// the score; the bid evaluator filters or penalizes such providers.
+	OnProbation bool       `json:"on_probation" bson:"on_probation"`
+	Probation   *Probation `json:"probation,omitempty" bson:"probation,omitempty"`
+	// Flags are review flags raised by the last recalculation; they do not
+	// change the score.
+	Flags []string `json:"flags,omitempty" bson:"flags,omitempty"`
+	// Adjustments are the unrevoked manual adjustments; expired ones are
+	// ignored when scoring and dropped on the next recalculation.
+	Adjustments []ScoreAdjustment `json:"-" bson:"adjustments,omitempty"`
+}
+
+// Probation ends once Until passes or enough consecutive successes follow
+// the failures that caused it; another such failure pushes Until back. The
+// last probation is kept with EndedAt set.
+type Probation struct {
+	Failures      int        `json:"failures" bson:"failures"`
+	Since         time.Time  `json:"since" bson:"since"`
+	LastFailureAt time.Time  `json:"last_failure_at" bson:"last_failure_at"`
+	Until         time.Time  `json:"until" bson:"until"`
+	EndedAt       *time.Time `json:"ended_at,omitempty" bson:"ended_at,omitempty"`
+	EndReason     string     `json:"end_reason,omitempty" bson:"end_reason,omitempty"`
+}
+
+// Active reports whether the probation is in force at now.
+func (p *Probation) Active(now time.Time) bool {
+	return p != nil && p.EndedAt == nil && now.Before(p.Until)
+}
+
+// ScoreBreakdown shows how TrustScore was derived from BaseScore. The outcome
+// score is blended with SLA adherence by SLAWeight into PerformanceScore, and
+// decay pulls that toward the neutral 0.3 by DecayFactor, which halves every
+// decay half-life without a contract.
+type ScoreBreakdown struct {
+	OutcomeScore        float64  `json:"outcome_score" bson:"outcome_score"`
+	SLAAdherence        *float64 `json:"sla_adherence,omitempty" bson:"sla_adherence,omitempty"`
+	SLAWeight           float64  `json:"sla_weight,omitempty" bson:"sla_weight,omitempty"`
+	PerformanceScore    float64  `json:"performance_score" bson:"performance_score"`
+	InactiveDays        float64  `json:"inactive_days" bson:"inactive_days"`
+	DecayHalfLifeDays   float64  `json:"decay_half_life_days,omitempty" bson:"decay_half_life_days,omitempty"`
+	DecayFactor         float64  `json:"decay_factor" bson:"decay_factor"`
+	DecayedOutcomeScore float64  `json:"decayed_outcome_score" bson:"decayed_outcome_score"`
+	VerificationBonus   float64  `json:"verification_bonus" bson:"verification_bonus"`
+	TenureBonus         float64  `json:"tenure_bonus" bson:"tenure_bonus"`
+	// ManualAdjustment is the sum of Adjustments.
+	ManualAdjustment float64             `json:"manual_adjustment,omitempty" bson:"manual_adjustment,omitempty"`
+	Adjustments      []AppliedAdjustment `json:"adjustments,omitempty" bson:"adjustments,omitempty"`
+}
+
+// ScoreConfidence is a 95% Wilson interval around the outcome score, over
+// the effective number of outcomes, carried over to the trust score: a 1.0
+// resting on two contracts has a far lower Lower than one resting on 200.
+type ScoreConfidence struct {
+	Level             float64 `json:"level" bson:"level"`
+	EffectiveOutcomes float64 `json:"effective_outcomes" bson:"effective_outcomes"`
+	OutcomeLower      float64 `json:"outcome_lower" bson:"outcome_lower"`
+	OutcomeUpper      float64 `json:"outcome_upper" bson:"outcome_upper"`
+	Lower             float64 `json:"lower" bson:"lower"`
+	Upper             float64 `json:"upper" bson:"upper"`
+}
+
+// AppliedAdjustment is an active manual adjustment as shown in a score
+// explanation. The operator who made it is only in the audit log.
+type AppliedAdjustment struct {
+	ID         string               `json:"id" bson:"id"`
+	Delta      float64              `json:"delta" bson:"delta"`
+	ReasonCode AdjustmentReasonCode `json:"reason_code" bson:"reason_code"`
+	Reason     string               `json:"reason" bson:"reason"`
+	ExpiresAt  time.Time            `json:"expires_at" bson:"expires_at"`
+}
+
+type AdjustmentReasonCode string
+
+const (
+	ReasonFraudPenalty      AdjustmentReasonCode = "FRAUD_PENALTY"
+	ReasonPolicyViolation   AdjustmentReasonCode = "POLICY_VIOLATION"
+	ReasonGoodwillCredit    AdjustmentReasonCode = "GOODWILL_CREDIT"
+	ReasonOutcomeCorrection AdjustmentReasonCode = "OUTCOME_CORRECTION"
+)
+
+// ScoreAdjustment is an operator's manual change to a provider's score. It
+// is kept after expiry or revocation as the audit log. Actor and RevokedBy
+// are the authenticated principal, not a name the caller supplied.
+type ScoreAdjustment struct {
+	ID         string               `json:"id" bson:"id"`
+	ProviderID string               `json:"provider_id" bson:"provider_id"`
+	Delta      float64              `json:"delta" bson:"delta"`
+	ReasonCode AdjustmentReasonCode `json:"reason_code" bson:"reason_code"`
+	Reason     string               `json:"reason" bson:"reason"`
+	Actor      string               `json:"actor" bson:"actor"`
+	CreatedAt  time.Time            `json:"created_at" bson:"created_at"`
+	ExpiresAt  time.Time            `json:"expires_at" bson:"expires_at"`
+
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	RevokedBy    string     `json:"revoked_by,omitempty" bson:"revoked_by,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty" bson:"revoke_reason,omitempty"`
+}
+
+// Active reports whether the adjustment counts toward the score at now.
+func (a ScoreAdjustment) Active(now time.Time) bool {
+	return a.RevokedAt == nil && now.Before(a.ExpiresAt)
+}
+
+type ScoreAdjustmentRequest struct {
+	Delta      float64              `json:"delta"`
+	ReasonCode AdjustmentReasonCode `json:"reason_code"`
+	Reason     string               `json:"reason"`
+	ExpiresAt  time.Time            `json:"expires_at"`
+}
+
+type RevokeAdjustmentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ScoringPolicy holds the tier thresholds and recency weights used to score
+// providers. Every change is stored as a new Version.
+type ScoringPolicy struct {
+	Version int `json:"version" bson:"version"`
+	// Tiers are checked from PREFERRED down; the first one the provider
+	// meets applies, otherwise it is UNVERIFIED.
+	Tiers []TierThreshold `json:"tiers" bson:"tiers"`
+	// RecencyWeights weight outcomes by recency rank (0 is the newest): a
+	// band covers the ranks below its Outcomes not covered by earlier bands,
+	// so {10, 1.0}, {50, 0.5} weights the newest 10 by 1 and the next 40 by 0.5.
+	RecencyWeights []RecencyWeight `json:"recency_weights" bson:"recency_weights"`
+	// OlderWeight applies to outcomes beyond the last band.
+	OlderWeight float64 `json:"older_weight" bson:"older_weight"`
+	// SLAWeight is the share of the performance score taken by SLA
+	// adherence for providers with SLA data, in [0, 1). A stored zero is
+	// loaded as the default weight.
+	SLAWeight float64 `json:"sla_weight" bson:"sla_weight"`
+	// ProbationFailures consecutive provider failures or lost disputes put
+	// a provider on probation; zero disables probation. It ends after
+	// ProbationSuccesses consecutive successes, or ProbationDays after the
+	// last of those failures. Stored zeros are loaded as the defaults.
+	ProbationFailures  int       `json:"probation_failures" bson:"probation_failures"`
+	ProbationSuccesses int       `json:"probation_successes" bson:"probation_successes"`
+	ProbationDays      int       `json:"probation_days" bson:"probation_days"`
+	CreatedAt          time.Time `json:"created_at" bson:"created_at"`
+}
+
+type TierThreshold struct {
+	Tier         TrustTier `json:"tier" bson:"tier"`
+	MinScore     float64   `json:"min_score" bson:"min_score"`
+	MinContracts int       `json:"min_contracts" bson:"min_contracts"`
+}
+
+type RecencyWeight struct {
+	Outcomes int     `json:"outcomes" bson:"outcomes"`
+	Weight   float64 `json:"weight" bson:"weight"`
 }
 
 type ContractOutcome struct {
@@ -60,8 +235,106 @@ type ContractOutcome struct {
 	AgreedPrice float64 `json:"agreed_price" bson:"agreed_price"`
 	FinalPrice  float64 `json:"final_price" bson:"final_price"`
 
+	// SLA data, when known: SLABreached, or else LatencyMs compared with
+	// SLALatencyMs, decides whether the contract met its SLA.
+	LatencyMs    *int64 `json:"latency_ms,omitempty" bson:"latency_ms,omitempty"`
+	SLALatencyMs *int64 `json:"sla_latency_ms,omitempty" bson:"sla_latency_ms,omitempty"`
+	SLABreached  *bool  `json:"sla_breached,omitempty" bson:"sla_breached,omitempty"`
+
 	CompletedAt time.Time `json:"completed_at" bson:"completed_at"`
 	RecordedAt  time.Time `json:"recorded_at" bson:"recorded_at"`
+
+	// Supersedes is the ID of the outcome this one replaced, e.g. a
+	// SUCCESS overturned by a lost dispute. Superseded outcomes are kept
+	// with SupersededBy set but no longer count toward the score.
+	Supersedes   string     `json:"supersedes,omitempty" bson:"supersedes,omitempty"`
+	SupersededBy string     `json:"superseded_by,omitempty" bson:"superseded_by,omitempty"`
+	SupersededAt *time.Time `json:"superseded_at,omitempty" bson:"superseded_at,omitempty"`
+}
+
+// SLAMet reports whether the contract met its SLA; known is false when the
+// outcome carries no SLA data.
+func (o ContractOutcome) SLAMet() (met, known bool) {
+	if o.SLABreached != nil {
+		return !*o.SLABreached, true
+	}
+	if o.LatencyMs != nil && o.SLALatencyMs != nil {
+		return *o.LatencyMs <= *o.SLALatencyMs, true
+	}
+	return false, false
+}
+
+// Snapshot triggers: what caused a recalculation.
+const (
+	TriggerOutcome      = "outcome"
+	TriggerVerification = "verification"
+	TriggerAdjustment   = "adjustment"
+	TriggerSupersession = "supersession"
+	TriggerRecompute    = "recompute"
+)
+
+// TrustSnapshot is a provider's score and tier right after a recalculation,
+// kept so providers can follow and contest their trajectory. Outcome is the
+// contract outcome that triggered it, if any.
+type TrustSnapshot struct {
+	ID         string `json:"id" bson:"id"`
+	ProviderID string `json:"provider_id" bson:"provider_id"`
+
+	TrustScore    float64   `json:"trust_score" bson:"trust_score"`
+	TrustTier     TrustTier `json:"trust_tier" bson:"trust_tier"`
+	PreviousScore float64   `json:"previous_score" bson:"previous_score"`
+	PreviousTier  TrustTier `json:"previous_tier" bson:"previous_tier"`
+
+	Trigger   string           `json:"trigger" bson:"trigger"`
+	Outcome   *ContractOutcome `json:"outcome,omitempty" bson:"outcome,omitempty"`
+	Breakdown *ScoreBreakdown  `json:"score_breakdown,omitempty" bson:"score_breakdown,omitempty"`
+
+	PolicyVersion int `json:"policy_version" bson:"policy_version"`
+
+	RecordedAt time.Time `json:"recorded_at" bson:"recorded_at"`
+}
+
+// TrustWebhook is where a provider receives its own trust.score_updated and
+// trust.tier_changed events. Secret signs deliveries and is never returned.
+type TrustWebhook struct {
+	ProviderID string    `json:"provider_id" bson:"provider_id"`
+	URL        string    `json:"url" bson:"url"`
+	Secret     string    `json:"-" bson:"secret"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+type TrustWebhookRequest struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// VerificationUpdate sets verification flags reported by other services.
+// Nil fields are left unchanged. Source and Method record who checked and
+// how; ExpiresAt, when set, overrides the default re-verification interval.
+type VerificationUpdate struct {
+	IdentityVerified *bool      `json:"identity_verified,omitempty"`
+	EndpointVerified *bool      `json:"endpoint_verified,omitempty"`
+	Source           string     `json:"source,omitempty"`
+	Method           string     `json:"method,omitempty"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+}
+
+// VerificationStatus is the provenance of a verification flag. Verified is
+// the result of the last check; once ExpiresAt passes the flag no longer
+// earns its modifier until the provider is verified again.
+type VerificationStatus struct {
+	Verified   bool       `json:"verified" bson:"verified"`
+	Source     string     `json:"source,omitempty" bson:"source,omitempty"`
+	Method     string     `json:"method,omitempty" bson:"method,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty" bson:"verified_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// Expired reports whether a passed verification is due for a new check.
+func (v *VerificationStatus) Expired(now time.Time) bool {
+	return v != nil && v.Verified && v.ExpiresAt != nil && !now.Before(*v.ExpiresAt)
 }
 
 type BatchTrustRequest struct {
@@ -69,5 +342,99 @@ type BatchTrustRequest struct {
 }
 
 type BatchTrustResponse struct {
-	Scores map[string]float64 `json:"scores"`
+	Scores     map[string]float64         `json:"scores"`
+	Tiers      map[string]TrustTier       `json:"tiers"`
+	Confidence map[string]ScoreConfidence `json:"confidence"`
+	// Probation lists the providers on probation.
+	Probation map[string]bool `json:"probation"`
+}
+
+type IncidentKind string
+
+const (
+	IncidentCancellation   IncidentKind = "CANCELLATION"
+	IncidentPaymentFailure IncidentKind = "PAYMENT_FAILURE"
+)
+
+// ConsumerIncident is something a consumer did to a contract that its
+// outcome does not capture: cancelling it, or failing to pay for it.
+type ConsumerIncident struct {
+	ID         string       `json:"id" bson:"id"`
+	ConsumerID string       `json:"consumer_id" bson:"consumer_id"`
+	ContractID string       `json:"contract_id" bson:"contract_id"`
+	ProviderID string       `json:"provider_id,omitempty" bson:"provider_id,omitempty"`
+	Kind       IncidentKind `json:"kind" bson:"kind"`
+	Reason     string       `json:"reason,omitempty" bson:"reason,omitempty"`
+	OccurredAt time.Time    `json:"occurred_at" bson:"occurred_at"`
+	RecordedAt time.Time    `json:"recorded_at" bson:"recorded_at"`
+}
+
+// ConsumerTrust is a consumer's reputation with providers, derived from the
+// outcomes of its recent contracts and its incidents. Rates are shares of
+// TotalContracts; a spurious dispute is one the consumer raised and lost
+// (DISPUTE_WON for the provider). ReputationScore is nil until the consumer
+// has a contract.
+type ConsumerTrust struct {
+	ConsumerID      string   `json:"consumer_id"`
+	ReputationScore *float64 `json:"reputation_score,omitempty"`
+
+	TotalContracts     int `json:"total_contracts"`
+	CompletedContracts int `json:"completed_contracts"`
+	CancelledContracts int `json:"cancelled_contracts"`
+	ConsumerFailures   int `json:"consumer_failures"`
+	PaymentFailures    int `json:"payment_failures"`
+	DisputesRaised     int `json:"disputes_raised"`
+	SpuriousDisputes   int `json:"spurious_disputes"`
+
+	CancellationRate    float64 `json:"cancellation_rate"`
+	PaymentFailureRate  float64 `json:"payment_failure_rate"`
+	DisputeRate         float64 `json:"dispute_rate"`
+	SpuriousDisputeRate float64 `json:"spurious_dispute_rate"`
+
+	LastContractAt *time.Time `json:"last_contract_at,omitempty"`
+	ComputedAt     time.Time  `json:"computed_at"`
+}
+
+// LeaderboardEntry is a provider's position on the trust leaderboard.
+type LeaderboardEntry struct {
+	Rank           int       `json:"rank"`
+	ProviderID     string    `json:"provider_id"`
+	TrustScore     float64   `json:"trust_score"`
+	TrustTier      TrustTier `json:"trust_tier"`
+	TotalContracts int       `json:"total_contracts"`
+	SuccessRate    float64   `json:"success_rate"`
+	// ConfidenceLower is the lower bound of the score's confidence interval.
+	ConfidenceLower *float64 `json:"confidence_lower,omitempty"`
+}
+
+// TrustStats summarizes the trust scores of all providers.
+type TrustStats struct {
+	Providers              int                   `json:"providers"`
+	ProvidersWithContracts int                   `json:"providers_with_contracts"`
+	TotalContracts         int                   `json:"total_contracts"`
+	Tiers                  map[TrustTier]int     `json:"tiers"`
+	AverageScore           float64               `json:"average_score"`
+	MedianScore            float64               `json:"median_score"`
+	AverageScoreByTier     map[TrustTier]float64 `json:"average_score_by_tier"`
+	PolicyVersion          int                   `json:"policy_version"`
+	ComputedAt             time.Time             `json:"computed_at"`
+}
+
+// Line types of the trust export.
+const (
+	ExportTrustRecord = "trust_record"
+	ExportOutcome     = "outcome"
+	ExportPageEnd     = "page_end"
+)
+
+// ExportLine is one NDJSON line of the trust export. A page lists each
+// provider's trust record, if it has one, followed by its current outcomes,
+// and ends with a page_end line whose NextCursor is empty on the last page.
+type ExportLine struct {
+	Type       string           `json:"type"`
+	ProviderID string           `json:"provider_id,omitempty"`
+	Record     *TrustRecord     `json:"record,omitempty"`
+	Outcome    *ContractOutcome `json:"outcome,omitempty"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Providers  int              `json:"providers,omitempty"`
 }
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+const (
+	// maxAdjustment bounds a single manual adjustment and the sum of a
+	// provider's active adjustments, in either direction.
+	maxAdjustment = 0.25
+	// maxAdjustmentTTL bounds how far ahead an adjustment may expire.
+	maxAdjustmentTTL = 365 * 24 * time.Hour
+	// adminActor is the audited actor of adjustments. The admin token is
+	// shared, so the admin principal is the only identity the broker can
+	// vouch for.
+	adminActor = "admin"
+)
+
+var adjustmentReasonCodes = map[model.AdjustmentReasonCode]bool{
+	model.ReasonFraudPenalty:      true,
+	model.ReasonPolicyViolation:   true,
+	model.ReasonGoodwillCredit:    true,
+	model.ReasonOutcomeCorrection: true,
+}
+
+func activeAdjustments(all []model.ScoreAdjustment, now time.Time) []model.ScoreAdjustment {
+	var out []model.ScoreAdjustment
+	for _, a := range all {
+		if a.Active(now) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// HandleCreateAdjustment applies a bounded, expiring manual adjustment to a
+// provider's score, such as a fraud penalty or goodwill credit. The
+// adjustment is kept in the audit log and shown in the score breakdown.
+func (s *Service) HandleCreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	var req model.ScoreAdjustmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	now := time.Now().UTC()
+	switch {
+	case req.Delta == 0 || math.IsNaN(req.Delta) || math.Abs(req.Delta) > maxAdjustment:
+		http.Error(w, fmt.Sprintf("delta must be non-zero and at most %.2f either way", maxAdjustment), http.StatusBadRequest)
+		return
+	case !adjustmentReasonCodes[req.ReasonCode]:
+		http.Error(w, "reason_code must be FRAUD_PENALTY, POLICY_VIOLATION, GOODWILL_CREDIT or OUTCOME_CORRECTION", http.StatusBadRequest)
+		return
+	case req.Reason == "":
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	case !req.ExpiresAt.After(now) || req.ExpiresAt.Sub(now) > maxAdjustmentTTL:
+		http.Error(w, "expires_at must be in the future and within 365 days", http.StatusBadRequest)
+		return
+	}
+
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	rec, err := s.store.GetTrustRecord(ctx, providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if rec == nil {
+		http.Error(w, "trust record not found", http.StatusNotFound)
+		return
+	}
+	active := activeAdjustments(rec.Adjustments, now)
+	total := req.Delta
+	for _, a := range active {
+		total += a.Delta
+	}
+	if math.Abs(total) > maxAdjustment+1e-9 {
+		http.Error(w, fmt.Sprintf("active adjustments would total %.2f; at most %.2f either way is allowed", total, maxAdjustment), http.StatusConflict)
+		return
+	}
+
+	adj := model.ScoreAdjustment{
+		ID:         generateID("adj_"),
+		ProviderID: providerID,
+		Delta:      req.Delta,
+		ReasonCode: req.ReasonCode,
+		Reason:     req.Reason,
+		Actor:      adminActor,
+		CreatedAt:  now,
+		ExpiresAt:  req.ExpiresAt.UTC(),
+	}
+	if err := s.store.SaveAdjustment(ctx, adj); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	rec.Adjustments = append(active, adj)
+	if err := s.store.UpsertTrustRecord(ctx, *rec); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(ctx, "trust adjustment applied",
+		"adjustment_id", adj.ID,
+		"provider_id", providerID,
+		"delta", adj.Delta,
+		"reason_code", adj.ReasonCode,
+		"actor", adj.Actor,
+	)
+
+	updated, prevScore, _, err := s.recalculate(ctx, providerID, model.TriggerAdjustment, nil)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"adjustment":     adj,
+		"previous_score": prevScore,
+		"new_score":      updated.TrustScore,
+	})
+}
+
+// HandleListAdjustments returns the provider's adjustment audit log,
+// including expired and revoked adjustments, newest first.
+func (s *Service) HandleListAdjustments(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	adjustments, err := s.store.ListAdjustments(r.Context(), providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"provider_id": providerID, "adjustments": adjustments})
+}
+
+// HandleRevokeAdjustment withdraws an adjustment before it expires.
+func (s *Service) HandleRevokeAdjustment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	var req model.RevokeAdjustmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	adj, err := s.store.GetAdjustment(ctx, strings.TrimSpace(r.PathValue("adjustment_id")))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if adj == nil || adj.ProviderID != providerID {
+		http.Error(w, "adjustment not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now().UTC()
+	if !adj.Active(now) {
+		http.Error(w, "adjustment is already revoked or expired", http.StatusConflict)
+		return
+	}
+	adj.RevokedAt = &now
+	adj.RevokedBy = adminActor
+	adj.RevokeReason = req.Reason
+	if err := s.store.UpdateAdjustment(ctx, *adj); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	rec, err := s.store.GetTrustRecord(ctx, providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if rec != nil {
+		kept := rec.Adjustments[:0]
+		for _, a := range rec.Adjustments {
+			if a.ID != adj.ID {
+				kept = append(kept, a)
+			}
+		}
+		rec.Adjustments = kept
+		if err := s.store.UpsertTrustRecord(ctx, *rec); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	slog.InfoContext(ctx, "trust adjustment revoked", "adjustment_id", adj.ID, "provider_id", providerID, "actor", adj.RevokedBy)
+
+	updated, prevScore, _, err := s.recalculate(ctx, providerID, model.TriggerAdjustment, nil)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"adjustment":     adj,
+		"previous_score": prevScore,
+		"new_score":      updated.TrustScore,
+	})
+}
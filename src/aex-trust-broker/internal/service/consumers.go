@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+// Weights of the consumer rates subtracted from a perfect reputation.
+const (
+	paymentFailurePenalty  = 0.4
+	spuriousDisputePenalty = 0.3
+	cancellationPenalty    = 0.2
+	consumerFailurePenalty = 0.1
+)
+
+// HandleGetConsumerTrust returns a consumer's reputation, so providers can
+// decide whether to bid on its work. A consumer without contracts has no
+// reputation_score.
+func (s *Service) HandleGetConsumerTrust(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	consumerID := strings.TrimSpace(r.PathValue("consumer_id"))
+	if consumerID == "" {
+		http.Error(w, "consumer_id is required", http.StatusBadRequest)
+		return
+	}
+	outcomes, err := s.store.ListConsumerOutcomes(ctx, consumerID, scoredOutcomes)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	incidents, err := s.store.ListIncidents(ctx, consumerID, scoredOutcomes)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, consumerTrust(consumerID, outcomes, incidents, time.Now().UTC()))
+}
+
+// HandleRecordIncident records a cancellation or payment failure reported
+// directly rather than as an event. An incident of the same kind for the
+// same contract is only recorded once.
+func (s *Service) HandleRecordIncident(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeInternal(w, r) {
+		return
+	}
+	var inc model.ConsumerIncident
+	if err := decodeJSON(r, &inc); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	inc.ConsumerID = strings.TrimSpace(r.PathValue("consumer_id"))
+	if inc.ContractID == "" {
+		http.Error(w, "contract_id is required", http.StatusBadRequest)
+		return
+	}
+	if inc.Kind != model.IncidentCancellation && inc.Kind != model.IncidentPaymentFailure {
+		http.Error(w, "kind must be CANCELLATION or PAYMENT_FAILURE", http.StatusBadRequest)
+		return
+	}
+	recorded, err := s.recordIncident(r.Context(), &inc)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"consumer_id": inc.ConsumerID,
+		"contract_id": inc.ContractID,
+		"kind":        inc.Kind,
+		"recorded":    recorded,
+		"duplicate":   !recorded,
+	})
+}
+
+// recordIncident saves inc unless the consumer already has an incident of
+// its kind for the contract.
+func (s *Service) recordIncident(ctx context.Context, inc *model.ConsumerIncident) (bool, error) {
+	existing, err := s.store.GetIncident(ctx, inc.ConsumerID, inc.Kind, inc.ContractID)
+	if err != nil || existing != nil {
+		return false, err
+	}
+	inc.ID = generateID("inc_")
+	inc.RecordedAt = time.Now().UTC()
+	if inc.OccurredAt.IsZero() {
+		inc.OccurredAt = inc.RecordedAt
+	}
+	if err := s.store.SaveIncident(ctx, *inc); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// consumerTrust derives a consumer's reputation from the outcomes of its
+// recent contracts and its incidents. Cancelled contracts have no outcome,
+// so they are added to the contract count.
+func consumerTrust(consumerID string, outcomes []model.ContractOutcome, incidents []model.ConsumerIncident, now time.Time) model.ConsumerTrust {
+	ct := model.ConsumerTrust{ConsumerID: consumerID, ComputedAt: now}
+	for _, o := range outcomes {
+		switch o.Outcome {
+		case model.OutcomeSuccess, model.OutcomeSuccessPartial:
+			ct.CompletedContracts++
+		case model.OutcomeFailureConsumer:
+			ct.ConsumerFailures++
+		case model.OutcomeDisputeOpen, model.OutcomeDisputeWon, model.OutcomeDisputeLost:
+			ct.DisputesRaised++
+			if o.Outcome == model.OutcomeDisputeWon {
+				ct.SpuriousDisputes++
+			}
+		}
+	}
+	for _, inc := range incidents {
+		switch inc.Kind {
+		case model.IncidentCancellation:
+			ct.CancelledContracts++
+		case model.IncidentPaymentFailure:
+			ct.PaymentFailures++
+		}
+	}
+	ct.TotalContracts = len(outcomes) + ct.CancelledContracts
+	if len(outcomes) > 0 {
+		t := outcomes[0].CompletedAt
+		ct.LastContractAt = &t
+	}
+	if ct.TotalContracts == 0 {
+		return ct
+	}
+
+	total := float64(ct.TotalContracts)
+	ct.CancellationRate = float64(ct.CancelledContracts) / total
+	ct.PaymentFailureRate = float64(ct.PaymentFailures) / total
+	ct.DisputeRate = float64(ct.DisputesRaised) / total
+	ct.SpuriousDisputeRate = float64(ct.SpuriousDisputes) / total
+	score := clamp01(1 -
+		paymentFailurePenalty*ct.PaymentFailureRate -
+		spuriousDisputePenalty*ct.SpuriousDisputeRate -
+		cancellationPenalty*ct.CancellationRate -
+		consumerFailurePenalty*float64(ct.ConsumerFailures)/total)
+	ct.ReputationScore = &score
+	return ct
+}
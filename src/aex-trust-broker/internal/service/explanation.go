@@ -0,0 +1,89 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+// HandleTrustExplanation explains a provider's current score and tier: the
+// weighted outcome score, each modifier's contribution, the scored outcomes
+// by type, and the thresholds of the policy version that produced the tier.
+func (s *Service) HandleTrustExplanation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	rec, err := s.store.GetTrustRecord(ctx, providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if rec == nil {
+		http.Error(w, "trust record not found", http.StatusNotFound)
+		return
+	}
+	outcomes, err := s.store.ListOutcomes(ctx, providerID, scoredOutcomes)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	policy := s.currentPolicy()
+	s.score(rec, time.Now().UTC(), policy)
+	b := rec.ScoreBreakdown
+
+	counts := map[model.OutcomeType]int{}
+	for _, o := range outcomes {
+		counts[o.Outcome]++
+	}
+	identity, endpoint := 0.0, 0.0
+	if rec.IdentityVerified {
+		identity = 0.05
+	}
+	if rec.EndpointVerified {
+		endpoint = 0.05
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":    rec.ProviderID,
+		"trust_score":    rec.TrustScore,
+		"trust_tier":     rec.TrustTier,
+		"policy_version": policy.Version,
+		"base_score":     rec.BaseScore,
+		"breakdown":      b,
+		"modifiers": map[string]float64{
+			"identity":          identity,
+			"endpoint":          endpoint,
+			"tenure":            b.TenureBonus,
+			"manual_adjustment": b.ManualAdjustment,
+			"decay":             b.DecayedOutcomeScore - b.PerformanceScore,
+		},
+		"outcome_counts":      counts,
+		"outcomes_considered": len(outcomes),
+		"total_contracts":     rec.TotalContracts,
+		"tiers":               tierProgress(policy, rec),
+	})
+}
+
+// tierProgress reports, for each tier of the policy, whether the provider
+// meets its thresholds and how far it is from them.
+func tierProgress(policy model.ScoringPolicy, rec *model.TrustRecord) []map[string]any {
+	out := make([]map[string]any, 0, len(policy.Tiers))
+	for _, t := range policy.Tiers {
+		entry := map[string]any{
+			"tier":          t.Tier,
+			"min_score":     t.MinScore,
+			"min_contracts": t.MinContracts,
+			"met":           rec.TrustScore >= t.MinScore && rec.TotalContracts >= t.MinContracts,
+		}
+		if gap := t.MinScore - rec.TrustScore; gap > 0 {
+			entry["score_gap"] = gap
+		}
+		if gap := t.MinContracts - rec.TotalContracts; gap > 0 {
+			entry["contracts_gap"] = gap
+		}
+		out = append(out, entry)
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+// HandleExport streams the stored trust records and current outcomes of up
+// to ?limit= providers (default 100, max 1000) as NDJSON, in provider id
+// order after ?cursor=. Pass the page_end line's next_cursor to fetch the
+// next page. A client whose stream broke can resume with the last provider
+// id it received in full; a missing page_end line means the page is
+// incomplete.
+func (s *Service) HandleExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	cursor := strings.TrimSpace(q.Get("cursor"))
+
+	ids, err := s.knownProviders(ctx)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	ids = ids[sort.SearchStrings(ids, cursor):]
+	if len(ids) > 0 && ids[0] == cursor {
+		ids = ids[1:]
+	}
+	next := ""
+	if len(ids) > limit {
+		ids = ids[:limit]
+		next = ids[limit-1]
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for _, id := range ids {
+		rec, err := s.store.GetTrustRecord(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "trust export aborted", "provider_id", id, "error", err)
+			return
+		}
+		outcomes, err := s.store.ListOutcomes(ctx, id, 0)
+		if err != nil {
+			slog.ErrorContext(ctx, "trust export aborted", "provider_id", id, "error", err)
+			return
+		}
+		if rec != nil {
+			if err := enc.Encode(model.ExportLine{Type: model.ExportTrustRecord, ProviderID: id, Record: rec}); err != nil {
+				return
+			}
+		}
+		for i := range outcomes {
+			if err := enc.Encode(model.ExportLine{Type: model.ExportOutcome, ProviderID: id, Outcome: &outcomes[i]}); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	_ = enc.Encode(model.ExportLine{Type: model.ExportPageEnd, NextCursor: next, Providers: len(ids)})
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxHistorySnapshots bounds one history response; callers page by moving
+// from past the last recorded_at.
+const maxHistorySnapshots = 500
+
+// HandleTrustHistory returns the provider's score and tier snapshots in
+// [from, to] (RFC 3339, both optional), oldest first, each with the outcome
+// or verification change that produced it. Decay between recalculations is
+// not snapshotted; the current decayed score is served by HandleGetTrust.
+func (s *Service) HandleTrustHistory(w http.ResponseWriter, r *http.Request) {
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	if providerID == "" {
+		http.Error(w, "provider_id is required", http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		http.Error(w, "from must be an RFC 3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		http.Error(w, "to must be an RFC 3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	snaps, err := s.store.ListSnapshots(r.Context(), providerID, from, to, maxHistorySnapshots)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	out := map[string]any{
+		"provider_id": providerID,
+		"snapshots":   snaps,
+		"total":       len(snaps),
+		"truncated":   len(snaps) == maxHistorySnapshots,
+	}
+	if !from.IsZero() {
+		out["from"] = from
+	}
+	if !to.IsZero() {
+		out["to"] = to
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	v := strings.TrimSpace(r.URL.Query().Get(name))
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/events"
+)
+
+// contractEventData holds the fields the trust broker reads from the
+// contract engine's contract.completed, contract.failed, contract.disputed
+// and contract.cancelled events, and settlement's settlement.payment_failed.
+type contractEventData struct {
+	ContractID string `json:"contract_id"`
+	ProviderID string `json:"provider_id"`
+	ConsumerID string `json:"consumer_id"`
+
+	Success     *bool          `json:"success"`
+	DurationMs  *int64         `json:"duration_ms"`
+	Metrics     map[string]any `json:"metrics"`
+	CompletedAt *time.Time     `json:"completed_at"`
+
+	FailureReason string     `json:"failure_reason"`
+	ReportedBy    string     `json:"reported_by"`
+	FailedAt      *time.Time `json:"failed_at"`
+
+	DisputedAt *time.Time `json:"disputed_at"`
+
+	Reason      string     `json:"reason"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+}
+
+// HandleContractEvent ingests contract outcome events from the shared event
+// bus, so outcomes no longer have to be POSTed to /internal/v1/outcomes, and
+// cancellations and payment failures as consumer incidents. Other event
+// types are acknowledged and ignored, and a contract's outcome is only
+// recorded once. An event must be signed by the bus with the events secret
+// or carry an internal token, and with a contract engine configured each
+// event is also checked against the contract it names.
+func (s *Service) HandleContractEvent(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !s.signedByBus(r, body) && !s.authorizeInternal(w, r) {
+		return
+	}
+	var env events.Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	switch env.EventType {
+	case events.EventContractCompleted, events.EventContractFailed, events.EventContractDisputed:
+	case events.EventContractCancelled, events.EventSettlementPaymentFailed:
+		s.ingestIncident(w, r, env)
+		return
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"event_id": env.EventID, "ignored": true})
+		return
+	}
+
+	var data contractEventData
+	raw, _ := json.Marshal(env.Data)
+	if err := json.Unmarshal(raw, &data); err != nil {
+		http.Error(w, "invalid event data", http.StatusBadRequest)
+		return
+	}
+	if data.ContractID == "" || data.ProviderID == "" {
+		http.Error(w, "contract_id and provider_id are required", http.StatusBadRequest)
+		return
+	}
+
+	out := outcomeFromEvent(env, data)
+	_, _, _, recorded, err := s.recordOutcome(r.Context(), &out)
+	if err != nil {
+		writeOutcomeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"event_id":    env.EventID,
+		"contract_id": data.ContractID,
+		"outcome":     out.Outcome,
+		"recorded":    recorded,
+		"duplicate":   !recorded,
+	})
+}
+
+// outcomeFromEvent maps a contract event to the outcome it reports. A
+// dispute stays DISPUTE_OPEN until it is resolved.
+func outcomeFromEvent(env events.Envelope, d contractEventData) model.ContractOutcome {
+	out := model.ContractOutcome{
+		ContractID:  d.ContractID,
+		ProviderID:  d.ProviderID,
+		ConsumerID:  d.ConsumerID,
+		Metrics:     d.Metrics,
+		CompletedAt: env.Timestamp,
+	}
+	var at *time.Time
+	switch env.EventType {
+	case events.EventContractCompleted:
+		out.Outcome = model.OutcomeSuccess
+		if d.Success != nil && !*d.Success {
+			out.Outcome = model.OutcomeSuccessPartial
+		}
+		out.LatencyMs = d.DurationMs
+		at = d.CompletedAt
+	case events.EventContractFailed:
+		out.Outcome = failureOutcome(d.FailureReason, d.ReportedBy)
+		at = d.FailedAt
+	case events.EventContractDisputed:
+		out.Outcome = model.OutcomeDisputeOpen
+		at = d.DisputedAt
+	}
+	if at != nil && !at.IsZero() {
+		out.CompletedAt = *at
+	}
+	return out
+}
+
+func failureOutcome(reason, reportedBy string) model.OutcomeType {
+	reason = strings.ToLower(reason)
+	switch {
+	case reportedBy == "consumer":
+		return model.OutcomeFailureConsumer
+	case strings.Contains(reason, "expired"), strings.Contains(reason, "timeout"):
+		return model.OutcomeExpired
+	case strings.Contains(reason, "external"):
+		return model.OutcomeFailureExternal
+	default:
+		return model.OutcomeFailureProvider
+	}
+}
+
+// ingestIncident records a contract.cancelled or settlement.payment_failed
+// event against the consumer.
+func (s *Service) ingestIncident(w http.ResponseWriter, r *http.Request, env events.Envelope) {
+	var data contractEventData
+	raw, _ := json.Marshal(env.Data)
+	if err := json.Unmarshal(raw, &data); err != nil {
+		http.Error(w, "invalid event data", http.StatusBadRequest)
+		return
+	}
+	if data.ContractID == "" || data.ConsumerID == "" {
+		http.Error(w, "contract_id and consumer_id are required", http.StatusBadRequest)
+		return
+	}
+	inc := model.ConsumerIncident{
+		ConsumerID: data.ConsumerID,
+		ContractID: data.ContractID,
+		ProviderID: data.ProviderID,
+		Kind:       model.IncidentCancellation,
+		Reason:     data.Reason,
+		OccurredAt: env.Timestamp,
+	}
+	at := data.CancelledAt
+	if env.EventType == events.EventSettlementPaymentFailed {
+		inc.Kind = model.IncidentPaymentFailure
+		at = data.FailedAt
+	}
+	if at != nil && !at.IsZero() {
+		inc.OccurredAt = *at
+	}
+	recorded, err := s.recordIncident(r.Context(), &inc)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"event_id":    env.EventID,
+		"contract_id": data.ContractID,
+		"kind":        inc.Kind,
+		"recorded":    recorded,
+		"duplicate":   !recorded,
+	})
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/events"
+	"github.com/parlakisik/agent-exchange/internal/serviceauth"
+)
+
+// successStreakLength is how many consecutive successes for one consumer
+// raise model.FlagSuccessStreak.
+const successStreakLength = 20
+
+// Reasons recordOutcome refuses an outcome; writeOutcomeError maps them to
+// responses.
+var (
+	errUnknownContract    = errors.New("contract not found")
+	errContractMismatch   = errors.New("contract does not involve the provider and consumer")
+	errContractIncomplete = errors.New("contract is not completed")
+	errContractLookup     = errors.New("contract engine unavailable")
+	errSuccessRateLimited = errors.New("too many successful outcomes for the provider")
+)
+
+// authorizeInternal requires the internal token or a service account token
+// for the trust broker on the routes that report outcomes and verification.
+// With neither configured those routes refuse every request.
+func (s *Service) authorizeInternal(w http.ResponseWriter, r *http.Request) bool {
+	if s.internalToken != "" && subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(s.internalToken)) == 1 {
+		return true
+	}
+	if s.serviceTokens != nil {
+		_, err := s.serviceTokens.Authenticate(r)
+		if err == nil {
+			return true
+		}
+		if !errors.Is(err, serviceauth.ErrUnauthorized) {
+			slog.Error("failed to verify service token", "error", err)
+			http.Error(w, "service authentication unavailable", http.StatusServiceUnavailable)
+			return false
+		}
+	}
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+// signedByBus reports whether body carries the event bus's signature, which
+// requires an events secret to be configured.
+func (s *Service) signedByBus(r *http.Request, body []byte) bool {
+	if s.eventsSecret == "" {
+		return false
+	}
+	want := events.Sign([]byte(s.eventsSecret), body)
+	return hmac.Equal([]byte(r.Header.Get(events.SignatureHeader)), []byte(want))
+}
+
+// admitOutcome checks a new outcome against its contract in the contract
+// engine, when one is configured: the contract must exist, be between the
+// outcome's provider and consumer, and be completed for a success to count.
+// The consumer and agreed price are taken from the contract when missing.
+// Successes beyond the hourly limit for the provider are refused.
+func (s *Service) admitOutcome(ctx context.Context, out *model.ContractOutcome) error {
+	if s.contracts != nil {
+		c, err := s.contracts.GetContract(ctx, out.ContractID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errContractLookup, err)
+		}
+		if c == nil {
+			return errUnknownContract
+		}
+		if c.ProviderID != out.ProviderID || (out.ConsumerID != "" && c.ConsumerID != out.ConsumerID) {
+			return errContractMismatch
+		}
+		if isSuccess(out.Outcome) && c.Status != "COMPLETED" {
+			return errContractIncomplete
+		}
+		out.ConsumerID = c.ConsumerID
+		if out.AgreedPrice == 0 {
+			out.AgreedPrice = c.AgreedPrice
+		}
+	}
+	if isSuccess(out.Outcome) && !s.successLimit.allow(out.ProviderID, time.Now()) {
+		slog.WarnContext(ctx, "trust outcome refused: success rate limit", "provider_id", out.ProviderID, "contract_id", out.ContractID)
+		return errSuccessRateLimited
+	}
+	return nil
+}
+
+// writeOutcomeError responds to an error from recordOutcome.
+func writeOutcomeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUnknownContract), errors.Is(err, errContractMismatch), errors.Is(err, errContractIncomplete):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, errSuccessRateLimited):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, errContractLookup):
+		slog.Error("trust outcome contract lookup failed", "error", err)
+		http.Error(w, "contract engine unavailable", http.StatusBadGateway)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func validOutcome(o model.OutcomeType) bool {
+	switch o {
+	case model.OutcomeSuccess, model.OutcomeSuccessPartial,
+		model.OutcomeFailureProvider, model.OutcomeFailureExternal, model.OutcomeFailureConsumer,
+		model.OutcomeDisputeOpen, model.OutcomeDisputeWon, model.OutcomeDisputeLost,
+		model.OutcomeExpired:
+		return true
+	}
+	return false
+}
+
+func isSuccess(o model.OutcomeType) bool {
+	return o == model.OutcomeSuccess || o == model.OutcomeSuccessPartial
+}
+
+// reviewFlags returns the review flags raised by outcomes, newest first.
+func reviewFlags(outcomes []model.ContractOutcome) []string {
+	var flags []string
+	if len(outcomes) >= successStreakLength {
+		streak := outcomes[:successStreakLength]
+		consumer := streak[0].ConsumerID
+		single := consumer != ""
+		for _, o := range streak {
+			if !isSuccess(o.Outcome) || o.ConsumerID != consumer {
+				single = false
+				break
+			}
+		}
+		if single {
+			flags = append(flags, model.FlagSuccessStreak)
+		}
+	}
+	return flags
+}
+
+// logRaisedFlags logs the flags in flags that were not in prev.
+func logRaisedFlags(providerID string, prev, flags []string) {
+	for _, f := range flags {
+		if !slices.Contains(prev, f) {
+			slog.Warn("trust review flag raised", "provider_id", providerID, "flag", f)
+		}
+	}
+}
+
+// successLimiter caps the successful outcomes recorded per provider within
+// a sliding hour. A zero limit disables it.
+type successLimiter struct {
+	mu    sync.Mutex
+	limit int
+	seen  map[string][]time.Time
+}
+
+func newSuccessLimiter(limit int) *successLimiter {
+	return &successLimiter{limit: limit, seen: map[string][]time.Time{}}
+}
+
+func (l *successLimiter) allow(providerID string, now time.Time) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	times := l.seen[providerID]
+	i := 0
+	for i < len(times) && !times[i].After(now.Add(-time.Hour)) {
+		i++
+	}
+	times = times[i:]
+	if len(times) >= l.limit {
+		l.seen[providerID] = times
+		return false
+	}
+	l.seen[providerID] = append(times, now)
+	return true
+}
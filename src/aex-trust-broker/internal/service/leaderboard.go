@@ -0,0 +1,149 @@
+package service
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+// HandleLeaderboard ranks providers with at least one contract by trust
+// score, optionally only those in ?tier= or declaring the capability
+// ?category= in the provider registry. Scores are decayed to now, so the
+// ranking matches GET /v1/providers/{id}/trust.
+func (s *Service) HandleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	limit := 25
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	tier := model.TrustTier(strings.ToUpper(strings.TrimSpace(q.Get("tier"))))
+	category := strings.TrimSpace(q.Get("category"))
+
+	var inCategory map[string]bool
+	if category != "" {
+		if s.providerDir == nil {
+			http.Error(w, "category filtering requires the provider registry", http.StatusBadRequest)
+			return
+		}
+		ids, err := s.providerDir.ListProviderIDs(ctx, category)
+		if err != nil {
+			slog.ErrorContext(ctx, "trust leaderboard: provider registry lookup failed", "category", category, "error", err)
+			http.Error(w, "provider registry unavailable", http.StatusBadGateway)
+			return
+		}
+		inCategory = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			inCategory[id] = true
+		}
+	}
+
+	recs, err := s.store.ListTrustRecords(ctx)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	policy := s.currentPolicy()
+	ranked := make([]model.TrustRecord, 0, len(recs))
+	for _, rec := range recs {
+		if rec.TotalContracts == 0 || (inCategory != nil && !inCategory[rec.ProviderID]) {
+			continue
+		}
+		s.score(&rec, now, policy)
+		if tier != "" && rec.TrustTier != tier {
+			continue
+		}
+		ranked = append(ranked, rec)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].TrustScore != ranked[j].TrustScore {
+			return ranked[i].TrustScore > ranked[j].TrustScore
+		}
+		if ranked[i].TotalContracts != ranked[j].TotalContracts {
+			return ranked[i].TotalContracts > ranked[j].TotalContracts
+		}
+		return ranked[i].ProviderID < ranked[j].ProviderID
+	})
+
+	entries := make([]model.LeaderboardEntry, 0, min(limit, len(ranked)))
+	for i, rec := range ranked {
+		if i == limit {
+			break
+		}
+		e := model.LeaderboardEntry{
+			Rank:           i + 1,
+			ProviderID:     rec.ProviderID,
+			TrustScore:     rec.TrustScore,
+			TrustTier:      rec.TrustTier,
+			TotalContracts: rec.TotalContracts,
+			SuccessRate:    float64(rec.SuccessfulContracts) / float64(rec.TotalContracts),
+		}
+		if rec.Confidence != nil {
+			lower := rec.Confidence.Lower
+			e.ConfidenceLower = &lower
+		}
+		entries = append(entries, e)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"category":  category,
+		"tier":      tier,
+		"providers": entries,
+		"total":     len(ranked),
+	})
+}
+
+// HandleTrustStats reports the tier distribution and score averages over
+// all providers, with scores decayed to now.
+func (s *Service) HandleTrustStats(w http.ResponseWriter, r *http.Request) {
+	recs, err := s.store.ListTrustRecords(r.Context())
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	policy := s.currentPolicy()
+	stats := model.TrustStats{
+		Providers:          len(recs),
+		Tiers:              map[model.TrustTier]int{},
+		AverageScoreByTier: map[model.TrustTier]float64{},
+		PolicyVersion:      policy.Version,
+		ComputedAt:         now,
+	}
+	scores := make([]float64, 0, len(recs))
+	var sum float64
+	for _, rec := range recs {
+		s.score(&rec, now, policy)
+		stats.Tiers[rec.TrustTier]++
+		stats.AverageScoreByTier[rec.TrustTier] += rec.TrustScore
+		stats.TotalContracts += rec.TotalContracts
+		if rec.TotalContracts > 0 {
+			stats.ProvidersWithContracts++
+		}
+		scores = append(scores, rec.TrustScore)
+		sum += rec.TrustScore
+	}
+	for t, total := range stats.AverageScoreByTier {
+		stats.AverageScoreByTier[t] = total / float64(stats.Tiers[t])
+	}
+	if n := len(scores); n > 0 {
+		stats.AverageScore = sum / float64(n)
+		slices.Sort(scores)
+		stats.MedianScore = scores[n/2]
+		if n%2 == 0 {
+			stats.MedianScore = (scores[n/2-1] + scores[n/2]) / 2
+		}
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
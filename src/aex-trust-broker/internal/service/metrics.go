@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+// scoreLatencyBuckets are the upper bounds, in seconds, of the score
+// computation latency histogram.
+var scoreLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// allTiers are reported in the tier gauge even when no provider holds them.
+var allTiers = []model.TrustTier{
+	model.TrustTierUnverified,
+	model.TrustTierVerified,
+	model.TrustTierTrusted,
+	model.TrustTierPreferred,
+	model.TrustTierInternal,
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// metrics holds the counters served on /metrics. The tier distribution is
+// read from the store on each scrape instead.
+type metrics struct {
+	mu       sync.Mutex
+	outcomes map[model.OutcomeType]uint64
+	latency  map[string]*histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		outcomes: map[model.OutcomeType]uint64{},
+		latency:  map[string]*histogram{},
+	}
+}
+
+// outcomeIngested counts an outcome saved for scoring.
+func (m *metrics) outcomeIngested(o model.OutcomeType) {
+	m.mu.Lock()
+	m.outcomes[o]++
+	m.mu.Unlock()
+}
+
+// observeScoring records how long a recalculation for trigger took.
+func (m *metrics) observeScoring(trigger string, d time.Duration) {
+	secs := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := m.latency[trigger]
+	if h == nil {
+		h = &histogram{buckets: make([]uint64, len(scoreLatencyBuckets))}
+		m.latency[trigger] = h
+	}
+	for i, le := range scoreLatencyBuckets {
+		if secs <= le {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += secs
+}
+
+// HandleMetrics serves the Prometheus text exposition of outcome ingestion,
+// the current tier distribution and score computation latency.
+func (s *Service) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	recs, err := s.store.ListTrustRecords(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "metrics: trust record listing failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	policy := s.currentPolicy()
+	tiers := map[model.TrustTier]int{}
+	probation := 0
+	for _, rec := range recs {
+		s.score(&rec, now, policy)
+		tiers[rec.TrustTier]++
+		if rec.OnProbation {
+			probation++
+		}
+	}
+
+	m := s.metrics
+	m.mu.Lock()
+	outcomes := make(map[model.OutcomeType]uint64, len(m.outcomes))
+	for o, n := range m.outcomes {
+		outcomes[o] = n
+	}
+	latency := make(map[string]histogram, len(m.latency))
+	for t, h := range m.latency {
+		latency[t] = histogram{buckets: slices.Clone(h.buckets), count: h.count, sum: h.sum}
+	}
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	defer func() { _ = bw.Flush() }()
+
+	fmt.Fprintln(bw, "# HELP aex_trust_outcomes_ingested_total Contract outcomes recorded for scoring, by outcome type.")
+	fmt.Fprintln(bw, "# TYPE aex_trust_outcomes_ingested_total counter")
+	types := make([]string, 0, len(outcomes))
+	for o := range outcomes {
+		types = append(types, string(o))
+	}
+	sort.Strings(types)
+	for _, o := range types {
+		fmt.Fprintf(bw, "aex_trust_outcomes_ingested_total{outcome=%q} %d\n", o, outcomes[model.OutcomeType(o)])
+	}
+
+	fmt.Fprintln(bw, "# HELP aex_trust_providers Providers by current trust tier.")
+	fmt.Fprintln(bw, "# TYPE aex_trust_providers gauge")
+	for _, t := range allTiers {
+		fmt.Fprintf(bw, "aex_trust_providers{tier=%q} %d\n", t, tiers[t])
+	}
+	fmt.Fprintln(bw, "# HELP aex_trust_providers_on_probation Providers currently on probation.")
+	fmt.Fprintln(bw, "# TYPE aex_trust_providers_on_probation gauge")
+	fmt.Fprintf(bw, "aex_trust_providers_on_probation %d\n", probation)
+
+	fmt.Fprintln(bw, "# HELP aex_trust_score_computation_seconds Time taken to rescore a provider from its outcomes, by trigger.")
+	fmt.Fprintln(bw, "# TYPE aex_trust_score_computation_seconds histogram")
+	triggers := make([]string, 0, len(latency))
+	for t := range latency {
+		triggers = append(triggers, t)
+	}
+	sort.Strings(triggers)
+	for _, t := range triggers {
+		h := latency[t]
+		for i, le := range scoreLatencyBuckets {
+			fmt.Fprintf(bw, "aex_trust_score_computation_seconds_bucket{trigger=%q,le=%q} %d\n", t, strconv.FormatFloat(le, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(bw, "aex_trust_score_computation_seconds_bucket{trigger=%q,le=\"+Inf\"} %d\n", t, h.count)
+		fmt.Fprintf(bw, "aex_trust_score_computation_seconds_sum{trigger=%q} %s\n", t, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(bw, "aex_trust_score_computation_seconds_count{trigger=%q} %d\n", t, h.count)
+	}
+}
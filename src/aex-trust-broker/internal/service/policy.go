@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/store"
+)
+
+// tierRank orders the tiers a scoring policy may define.
+var tierRank = map[model.TrustTier]int{
+	model.TrustTierVerified:  1,
+	model.TrustTierTrusted:   2,
+	model.TrustTierPreferred: 3,
+}
+
+// DefaultScoringPolicy is the policy used when none is configured or stored.
+func DefaultScoringPolicy() model.ScoringPolicy {
+	return model.ScoringPolicy{
+		Version: 1,
+		Tiers: []model.TierThreshold{
+			{Tier: model.TrustTierPreferred, MinScore: 0.9, MinContracts: 100},
+			{Tier: model.TrustTierTrusted, MinScore: 0.7, MinContracts: 25},
+			{Tier: model.TrustTierVerified, MinScore: 0.5, MinContracts: 5},
+		},
+		RecencyWeights: []model.RecencyWeight{
+			{Outcomes: 10, Weight: 1.0},
+			{Outcomes: 50, Weight: 0.5},
+			{Outcomes: 100, Weight: 0.25},
+		},
+		OlderWeight:        0.1,
+		SLAWeight:          0.2,
+		ProbationFailures:  3,
+		ProbationSuccesses: 5,
+		ProbationDays:      14,
+	}
+}
+
+// LoadScoringPolicy reads a scoring policy from a JSON file
+// (TRUST_POLICY_FILE). Its version is ignored.
+func LoadScoringPolicy(path string) (model.ScoringPolicy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return model.ScoringPolicy{}, err
+	}
+	var p model.ScoringPolicy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return model.ScoringPolicy{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := normalizePolicy(&p); err != nil {
+		return model.ScoringPolicy{}, fmt.Errorf("%s: %w", path, err)
+	}
+	p.Version = 1
+	return p, nil
+}
+
+// normalizePolicy validates p, orders its tiers from PREFERRED down and fills
+// in unset probation exit criteria. A higher tier may not have lower
+// thresholds than a lower one.
+func normalizePolicy(p *model.ScoringPolicy) error {
+	if len(p.Tiers) == 0 {
+		return errors.New("tiers are required")
+	}
+	seen := map[model.TrustTier]bool{}
+	for _, t := range p.Tiers {
+		if tierRank[t.Tier] == 0 {
+			return fmt.Errorf("tier %q cannot be configured", t.Tier)
+		}
+		if seen[t.Tier] {
+			return fmt.Errorf("tier %s is listed twice", t.Tier)
+		}
+		seen[t.Tier] = true
+		if math.IsNaN(t.MinScore) || t.MinScore < 0 || t.MinScore > 1 {
+			return fmt.Errorf("tier %s min_score must be between 0 and 1", t.Tier)
+		}
+		if t.MinContracts < 0 {
+			return fmt.Errorf("tier %s min_contracts must not be negative", t.Tier)
+		}
+	}
+	sort.Slice(p.Tiers, func(i, j int) bool { return tierRank[p.Tiers[i].Tier] > tierRank[p.Tiers[j].Tier] })
+	for i := 1; i < len(p.Tiers); i++ {
+		hi, lo := p.Tiers[i-1], p.Tiers[i]
+		if hi.MinScore < lo.MinScore || hi.MinContracts < lo.MinContracts {
+			return fmt.Errorf("tier %s thresholds must not be below %s", hi.Tier, lo.Tier)
+		}
+	}
+
+	prev := 0
+	for _, band := range p.RecencyWeights {
+		if band.Outcomes <= prev {
+			return errors.New("recency_weights outcomes must be positive and increasing")
+		}
+		if math.IsNaN(band.Weight) || band.Weight <= 0 {
+			return errors.New("recency_weights weight must be positive")
+		}
+		prev = band.Outcomes
+	}
+	if math.IsNaN(p.OlderWeight) || p.OlderWeight < 0 {
+		return errors.New("older_weight must not be negative")
+	}
+	if len(p.RecencyWeights) == 0 && p.OlderWeight == 0 {
+		return errors.New("recency_weights or older_weight is required")
+	}
+	if math.IsNaN(p.SLAWeight) || p.SLAWeight < 0 || p.SLAWeight >= 1 {
+		return errors.New("sla_weight must be at least 0 and below 1")
+	}
+	if p.ProbationFailures < 0 || p.ProbationSuccesses < 0 || p.ProbationDays < 0 {
+		return errors.New("probation_failures, probation_successes and probation_days must not be negative")
+	}
+	if p.ProbationFailures > 0 {
+		if p.ProbationSuccesses == 0 {
+			p.ProbationSuccesses = 5
+		}
+		if p.ProbationDays == 0 {
+			p.ProbationDays = 14
+		}
+	}
+	return nil
+}
+
+func (s *Service) currentPolicy() model.ScoringPolicy {
+	s.policyMu.RLock()
+	defer s.policyMu.RUnlock()
+	return s.policy
+}
+
+func (s *Service) setPolicy(p model.ScoringPolicy) {
+	s.policyMu.Lock()
+	s.policy = p
+	s.policyMu.Unlock()
+}
+
+// InitPolicy switches to the newest stored scoring policy, or stores the
+// configured one as version 1 when there is none, and returns the policy in
+// effect. Policies changed through the admin API thus survive restarts. A
+// stored policy is backfilled from DefaultScoringPolicy, as policies stored
+// before a setting existed load it as zero.
+func (s *Service) InitPolicy(ctx context.Context) (model.ScoringPolicy, error) {
+	stored, err := s.store.ListPolicies(ctx, 1)
+	if err != nil {
+		return model.ScoringPolicy{}, err
+	}
+	if len(stored) == 0 {
+		p := s.currentPolicy()
+		p.Version = 1
+		p.CreatedAt = time.Now().UTC()
+		err := s.store.SavePolicy(ctx, p)
+		if err == nil {
+			s.setPolicy(p)
+			return p, nil
+		}
+		if !errors.Is(err, store.ErrPolicyVersionExists) {
+			return model.ScoringPolicy{}, err
+		}
+		// Another replica seeded the policy first.
+		if stored, err = s.store.ListPolicies(ctx, 1); err != nil || len(stored) == 0 {
+			return model.ScoringPolicy{}, fmt.Errorf("load scoring policy: %v", err)
+		}
+	}
+	p := stored[0]
+	backfillPolicy(&p)
+	s.setPolicy(p)
+	return p, nil
+}
+
+// backfillPolicy sets the settings p leaves at zero to their defaults.
+func backfillPolicy(p *model.ScoringPolicy) {
+	d := DefaultScoringPolicy()
+	if p.SLAWeight == 0 {
+		p.SLAWeight = d.SLAWeight
+	}
+	if p.ProbationFailures == 0 {
+		p.ProbationFailures = d.ProbationFailures
+	}
+	if p.ProbationSuccesses == 0 {
+		p.ProbationSuccesses = d.ProbationSuccesses
+	}
+	if p.ProbationDays == 0 {
+		p.ProbationDays = d.ProbationDays
+	}
+}
+
+// HandleGetPolicy returns the scoring policy in effect.
+func (s *Service) HandleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.currentPolicy())
+}
+
+// HandlePutPolicy replaces the scoring policy with a new version. Tier
+// thresholds apply to the next score read; recency weights to each
+// provider's next recalculation.
+func (s *Service) HandlePutPolicy(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	var p model.ScoringPolicy
+	if err := decodeJSON(r, &p); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := normalizePolicy(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.policyWrite.Lock()
+	defer s.policyWrite.Unlock()
+	p.Version = s.currentPolicy().Version + 1
+	p.CreatedAt = time.Now().UTC()
+	if err := s.store.SavePolicy(r.Context(), p); err != nil {
+		if errors.Is(err, store.ErrPolicyVersionExists) {
+			http.Error(w, "scoring policy was changed concurrently; retry", http.StatusConflict)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.setPolicy(p)
+	writeJSON(w, http.StatusOK, p)
+}
+
+// HandleListPolicyVersions returns stored scoring policies, newest first, so
+// the policy_version on a score or snapshot can be looked up.
+func (s *Service) HandleListPolicyVersions(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	policies, err := s.store.ListPolicies(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"policies": policies})
+}
+
+// authorizeAdmin requires the admin token as a Bearer token. Without a
+// configured admin token the admin routes are disabled.
+func (s *Service) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		http.Error(w, "admin API is disabled: no admin token is configured", http.StatusServiceUnavailable)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(s.adminToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
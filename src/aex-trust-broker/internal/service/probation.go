@@ -0,0 +1,70 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+// Reasons a probation ended.
+const (
+	probationEndSuccesses = "SUCCESSES"
+	probationEndExpired   = "EXPIRED"
+	probationEndDisabled  = "DISABLED"
+)
+
+// isSevere reports whether an outcome counts toward probation.
+func isSevere(o model.OutcomeType) bool {
+	return o == model.OutcomeFailureProvider || o == model.OutcomeDisputeLost
+}
+
+// updateProbation applies the policy's probation rules to the provider's
+// outcomes, newest first, and returns its probation. Only failures newer
+// than the last one counted start or extend a probation, so rescoring for
+// other reasons does not.
+func updateProbation(providerID string, policy model.ScoringPolicy, p *model.Probation, outcomes []model.ContractOutcome, now time.Time) *model.Probation {
+	end := func(at time.Time, reason string) {
+		p.EndedAt = &at
+		p.EndReason = reason
+		slog.Info("trust probation ended", "provider_id", providerID, "reason", reason)
+	}
+	if p != nil && p.EndedAt == nil {
+		switch {
+		case policy.ProbationFailures <= 0:
+			end(now, probationEndDisabled)
+		case !now.Before(p.Until):
+			end(p.Until, probationEndExpired)
+		case leadingStreak(outcomes, isSuccess) >= policy.ProbationSuccesses:
+			end(now, probationEndSuccesses)
+		}
+	}
+	if policy.ProbationFailures <= 0 {
+		return p
+	}
+
+	failures := leadingStreak(outcomes, isSevere)
+	if failures < policy.ProbationFailures || (p != nil && !outcomes[0].CompletedAt.After(p.LastFailureAt)) {
+		return p
+	}
+	if !p.Active(now) {
+		p = &model.Probation{Since: now}
+		slog.Info("trust probation started", "provider_id", providerID, "failures", failures)
+	}
+	p.Failures = failures
+	p.LastFailureAt = outcomes[0].CompletedAt
+	p.Until = now.Add(time.Duration(policy.ProbationDays) * 24 * time.Hour)
+	return p
+}
+
+// leadingStreak counts the outcomes from the newest for which match holds.
+func leadingStreak(outcomes []model.ContractOutcome, match func(model.OutcomeType) bool) int {
+	n := 0
+	for _, o := range outcomes {
+		if !match(o.Outcome) {
+			break
+		}
+		n++
+	}
+	return n
+}
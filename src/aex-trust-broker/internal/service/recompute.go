@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+// HandleRecompute replays the stored outcomes of ?provider_id=, or of every
+// provider, through the current scoring policy. Run it after changing the
+// policy or fixing a scoring bug; it also creates trust records for
+// providers whose outcomes were backfilled straight into the store. Each
+// provider gets a history snapshot, and only changed scores are listed.
+func (s *Service) HandleRecompute(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	var providerIDs []string
+	if id := strings.TrimSpace(r.URL.Query().Get("provider_id")); id != "" {
+		rec, err := s.store.GetTrustRecord(ctx, id)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if rec == nil {
+			outs, err := s.store.ListOutcomes(ctx, id, 1)
+			if err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if len(outs) == 0 {
+				http.Error(w, "provider not found", http.StatusNotFound)
+				return
+			}
+		}
+		providerIDs = []string{id}
+	} else {
+		var err error
+		if providerIDs, err = s.knownProviders(ctx); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	changed := make([]map[string]any, 0)
+	var failed []string
+	for _, id := range providerIDs {
+		updated, prevScore, prevTier, err := s.recalculate(ctx, id, model.TriggerRecompute, nil)
+		if err != nil {
+			slog.ErrorContext(ctx, "trust recompute failed", "provider_id", id, "error", err)
+			failed = append(failed, id)
+			continue
+		}
+		if updated.TrustScore != prevScore || updated.TrustTier != prevTier {
+			changed = append(changed, map[string]any{
+				"provider_id":    id,
+				"previous_score": prevScore,
+				"new_score":      updated.TrustScore,
+				"previous_tier":  prevTier,
+				"new_tier":       updated.TrustTier,
+			})
+		}
+	}
+	version := s.currentPolicy().Version
+	slog.InfoContext(ctx, "trust recompute finished",
+		"providers", len(providerIDs),
+		"changed", len(changed),
+		"failed", len(failed),
+		"policy_version", version,
+	)
+
+	resp := map[string]any{
+		"policy_version": version,
+		"recomputed":     len(providerIDs) - len(failed),
+		"changed":        changed,
+	}
+	if len(failed) > 0 {
+		resp["failed"] = failed
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// knownProviders returns the sorted ids of providers with a trust record,
+// outcomes or both.
+func (s *Service) knownProviders(ctx context.Context) ([]string, error) {
+	recs, err := s.store.ListTrustRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	withOutcomes, err := s.store.ListOutcomeProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, rec := range recs {
+		seen[rec.ProviderID] = true
+	}
+	for _, id := range withOutcomes {
+		seen[id] = true
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
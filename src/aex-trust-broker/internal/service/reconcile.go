@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/store"
+)
+
+// HandleSupersedeOutcome sets the outcome of a contract, replacing the one
+// recorded before, e.g. when a dispute resolves and SUCCESS or DISPUTE_OPEN
+// becomes DISPUTE_LOST. The replaced outcome is kept but no longer counts,
+// and the new one keeps its position in the recency weighting unless
+// completed_at is given. Without an earlier outcome this records a new one.
+// Either way the new outcome is admitted like any other.
+func (s *Service) HandleSupersedeOutcome(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.authorizeInternal(w, r) {
+		return
+	}
+	var out model.ContractOutcome
+	if err := decodeJSON(r, &out); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	contractID := strings.TrimSpace(r.PathValue("contract_id"))
+	if out.ContractID != "" && out.ContractID != contractID {
+		http.Error(w, "contract_id does not match the path", http.StatusBadRequest)
+		return
+	}
+	out.ContractID = contractID
+	if out.ProviderID == "" || out.Outcome == "" {
+		http.Error(w, "provider_id and outcome are required", http.StatusBadRequest)
+		return
+	}
+	if !validOutcome(out.Outcome) {
+		http.Error(w, "unknown outcome", http.StatusBadRequest)
+		return
+	}
+
+	prev, err := s.store.GetOutcome(ctx, out.ProviderID, contractID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if prev == nil {
+		updated, prevScore, prevTier, _, err := s.recordOutcome(ctx, &out)
+		if err != nil {
+			writeOutcomeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, supersedeResponse(out, nil, updated, prevScore, prevTier))
+		return
+	}
+	if prev.Outcome == out.Outcome {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"contract_id": contractID,
+			"provider_id": out.ProviderID,
+			"outcome":     out.Outcome,
+			"changed":     false,
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	out.ID = generateID("out_")
+	out.Supersedes = prev.ID
+	out.RecordedAt = now
+	if out.CompletedAt.IsZero() {
+		out.CompletedAt = prev.CompletedAt
+	}
+	if out.ConsumerID == "" {
+		out.ConsumerID = prev.ConsumerID
+	}
+	if err := s.admitOutcome(ctx, &out); err != nil {
+		writeOutcomeError(w, err)
+		return
+	}
+	// A provider has one current outcome per contract, so prev is
+	// superseded before out is saved, and restored if that fails.
+	current := *prev
+	prev.SupersededBy = out.ID
+	prev.SupersededAt = &now
+	if err := s.store.UpdateOutcome(ctx, *prev); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.SaveOutcome(ctx, out); err != nil {
+		if rerr := s.store.UpdateOutcome(ctx, current); rerr != nil {
+			slog.ErrorContext(ctx, "failed to restore superseded outcome", "outcome_id", current.ID, "error", rerr)
+		}
+		if errors.Is(err, store.ErrDuplicateOutcome) {
+			http.Error(w, "outcome changed concurrently", http.StatusConflict)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.metrics.outcomeIngested(out.Outcome)
+
+	updated, prevScore, prevTier, err := s.recalculate(ctx, out.ProviderID, model.TriggerSupersession, &out)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, supersedeResponse(out, prev, updated, prevScore, prevTier))
+}
+
+func supersedeResponse(out model.ContractOutcome, prev *model.ContractOutcome, updated model.TrustRecord, prevScore float64, prevTier model.TrustTier) map[string]any {
+	resp := map[string]any{
+		"contract_id":    out.ContractID,
+		"provider_id":    out.ProviderID,
+		"outcome":        out.Outcome,
+		"changed":        true,
+		"previous_score": prevScore,
+		"new_score":      updated.TrustScore,
+		"tier_changed":   prevTier != updated.TrustTier,
+	}
+	if prev != nil {
+		resp["superseded_outcome"] = prev.Outcome
+	}
+	return resp
+}
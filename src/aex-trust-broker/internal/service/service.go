@@ -5,22 +5,152 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"math"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/clients"
 	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/events"
+	"github.com/parlakisik/agent-exchange/internal/serviceauth"
 )
 
+// neutralScore is the score of a provider with no contract history.
+const neutralScore = 0.3
+
+// scoredOutcomes is how many of a provider's most recent outcomes are scored.
+const scoredOutcomes = 200
+
+// Options configures optional Service behaviour.
+type Options struct {
+	// DecayHalfLife is how long a provider can go without a contract before
+	// its outcome-based score loses half of its distance from neutralScore.
+	// Zero disables decay.
+	DecayHalfLife time.Duration
+
+	// EventsURL receives trust events on the shared event bus.
+	EventsURL string
+	// ProviderRegistryURL authenticates providers managing their webhook
+	// and resolves leaderboard categories.
+	ProviderRegistryURL string
+	// WebhookBackoff is the delay before the first webhook retry (default 1s).
+	WebhookBackoff time.Duration
+
+	// VerificationTTL is how long a passed verification counts when the
+	// update sets no expires_at. Zero means verifications never expire.
+	VerificationTTL time.Duration
+
+	// Policy seeds the scoring policy when none is stored yet; nil uses
+	// DefaultScoringPolicy.
+	Policy *model.ScoringPolicy
+	// AdminToken is required as a Bearer token on /admin routes; without it
+	// they are disabled.
+	AdminToken string
+
+	// InternalToken is accepted as a Bearer token by the routes that report
+	// outcomes and verification; without it or ServiceTokens they refuse
+	// every request.
+	InternalToken string
+	// ServiceTokens, when set, lets those routes accept service account
+	// tokens for the trust broker as well as InternalToken.
+	ServiceTokens *serviceauth.Verifier
+	// EventsSecret, when set, admits events on /internal/v1/events signed
+	// with it by the event bus, as well as internally authorized ones.
+	EventsSecret string
+	// ContractEngineURL, when set, has every new outcome checked against its
+	// contract in the contract engine.
+	ContractEngineURL string
+	// SuccessRateLimit caps the successful outcomes recorded per provider
+	// per hour. Zero disables the limit.
+	SuccessRateLimit int
+}
+
+// ProviderKeyValidator resolves a provider API key to its provider id.
+type ProviderKeyValidator interface {
+	ValidateAPIKey(ctx context.Context, apiKey string) (string, error)
+}
+
+// ProviderDirectory lists the providers declaring a capability.
+type ProviderDirectory interface {
+	ListProviderIDs(ctx context.Context, capability string) ([]string, error)
+}
+
+// ContractLookup resolves a contract id to its contract; nil when unknown.
+type ContractLookup interface {
+	GetContract(ctx context.Context, contractID string) (*clients.Contract, error)
+}
+
 type Service struct {
-	store store.Store
+	store           store.Store
+	decayHalfLife   time.Duration
+	verificationTTL time.Duration
+
+	events         *events.Publisher
+	providerAuth   ProviderKeyValidator
+	providerDir    ProviderDirectory
+	webhookHTTP    *http.Client
+	webhookBackoff time.Duration
+
+	internalToken string
+	serviceTokens *serviceauth.Verifier
+	eventsSecret  string
+	contracts     ContractLookup
+	successLimit  *successLimiter
+
+	metrics *metrics
+
+	adminToken string
+	policyMu   sync.RWMutex
+	policy     model.ScoringPolicy
+	// policyWrite serializes policy updates so versions are assigned in order.
+	policyWrite sync.Mutex
 }
 
 func New(st store.Store) *Service {
-	return &Service{store: st}
+	return NewWithOptions(st, Options{})
+}
+
+func NewWithOptions(st store.Store, opts Options) *Service {
+	s := &Service{
+		store:           st,
+		decayHalfLife:   opts.DecayHalfLife,
+		verificationTTL: opts.VerificationTTL,
+		events:          events.NewPublisher("aex-trust-broker"),
+		webhookHTTP:     &http.Client{Timeout: 10 * time.Second},
+		webhookBackoff:  opts.WebhookBackoff,
+		adminToken:      opts.AdminToken,
+		internalToken:   opts.InternalToken,
+		serviceTokens:   opts.ServiceTokens,
+		eventsSecret:    opts.EventsSecret,
+		successLimit:    newSuccessLimiter(opts.SuccessRateLimit),
+		metrics:         newMetrics(),
+		policy:          DefaultScoringPolicy(),
+	}
+	if opts.Policy != nil {
+		s.policy = *opts.Policy
+	}
+	if s.webhookBackoff <= 0 {
+		s.webhookBackoff = time.Second
+	}
+	if opts.EventsURL != "" {
+		s.events.RegisterEndpoint(events.EventTrustScoreUpdated, opts.EventsURL)
+		s.events.RegisterEndpoint(events.EventTrustTierChanged, opts.EventsURL)
+	}
+	if opts.ProviderRegistryURL != "" {
+		registry := clients.NewProviderRegistryClient(opts.ProviderRegistryURL)
+		s.providerAuth = registry
+		s.providerDir = registry
+	}
+	if opts.ContractEngineURL != "" {
+		s.contracts = clients.NewContractEngineClient(opts.ContractEngineURL)
+	}
+	return s
 }
 
 func (s *Service) HandleGetTrust(w http.ResponseWriter, r *http.Request) {
@@ -49,10 +179,17 @@ func (s *Service) HandleGetTrust(w http.ResponseWriter, r *http.Request) {
 		_ = s.store.UpsertTrustRecord(ctx, r)
 		rec = &r
 	}
+	s.score(rec, time.Now().UTC(), s.currentPolicy())
 
 	writeJSON(w, http.StatusOK, rec)
 }
 
+// maxBatchProviders bounds the provider_ids of one batch trust request.
+const maxBatchProviders = 500
+
+// HandleBatchTrust returns the scores and tiers of many providers in one
+// call, so the bid evaluator does not look up each bidder separately.
+// Unknown providers get the neutral score without a record being created.
 func (s *Service) HandleBatchTrust(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var req model.BatchTrustRequest
@@ -60,10 +197,21 @@ func (s *Service) HandleBatchTrust(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	out := model.BatchTrustResponse{Scores: map[string]float64{}}
+	if len(req.ProviderIDs) > maxBatchProviders {
+		http.Error(w, "at most 500 provider_ids are allowed", http.StatusBadRequest)
+		return
+	}
+	now := time.Now().UTC()
+	policy := s.currentPolicy()
+	out := model.BatchTrustResponse{
+		Scores:     map[string]float64{},
+		Tiers:      map[string]model.TrustTier{},
+		Confidence: map[string]model.ScoreConfidence{},
+		Probation:  map[string]bool{},
+	}
 	for _, id := range req.ProviderIDs {
 		id = strings.TrimSpace(id)
-		if id == "" {
+		if _, seen := out.Scores[id]; id == "" || seen {
 			continue
 		}
 		rec, err := s.store.GetTrustRecord(ctx, id)
@@ -72,9 +220,17 @@ func (s *Service) HandleBatchTrust(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if rec == nil {
-			out.Scores[id] = 0.3
-		} else {
-			out.Scores[id] = rec.TrustScore
+			out.Scores[id] = neutralScore
+			out.Tiers[id] = model.TrustTierUnverified
+			out.Confidence[id] = scoreConfidence(neutralScore, 0, neutralScore)
+			continue
+		}
+		s.score(rec, now, policy)
+		out.Scores[id] = rec.TrustScore
+		out.Tiers[id] = rec.TrustTier
+		out.Confidence[id] = *rec.Confidence
+		if rec.OnProbation {
+			out.Probation[id] = true
 		}
 	}
 	writeJSON(w, http.StatusOK, out)
@@ -82,6 +238,9 @@ func (s *Service) HandleBatchTrust(w http.ResponseWriter, r *http.Request) {
 
 func (s *Service) HandleRecordOutcome(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	if !s.authorizeInternal(w, r) {
+		return
+	}
 	var out model.ContractOutcome
 	if err := decodeJSON(r, &out); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -91,24 +250,23 @@ func (s *Service) HandleRecordOutcome(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing required fields", http.StatusBadRequest)
 		return
 	}
-	if out.ID == "" {
-		out.ID = generateID("out_")
-	}
-	if out.RecordedAt.IsZero() {
-		out.RecordedAt = time.Now().UTC()
-	}
-	if out.CompletedAt.IsZero() {
-		out.CompletedAt = out.RecordedAt
-	}
-
-	if err := s.store.SaveOutcome(ctx, out); err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	if (out.LatencyMs != nil && *out.LatencyMs < 0) || (out.SLALatencyMs != nil && *out.SLALatencyMs <= 0) {
+		http.Error(w, "latency_ms must not be negative and sla_latency_ms must be positive", http.StatusBadRequest)
 		return
 	}
 
-	updated, prevScore, prevTier, err := s.recalculate(ctx, out.ProviderID)
+	updated, prevScore, prevTier, recorded, err := s.recordOutcome(ctx, &out)
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		writeOutcomeError(w, err)
+		return
+	}
+	if !recorded {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"recorded":    false,
+			"duplicate":   true,
+			"provider_id": out.ProviderID,
+			"contract_id": out.ContractID,
+		})
 		return
 	}
 
@@ -122,8 +280,118 @@ func (s *Service) HandleRecordOutcome(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
-func (s *Service) recalculate(ctx context.Context, providerID string) (model.TrustRecord, float64, model.TrustTier, error) {
+// HandleSetVerification records identity/endpoint verification results and
+// recalculates the score so the verification modifiers take effect.
+func (s *Service) HandleSetVerification(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeInternal(w, r) {
+		return
+	}
+	ctx := r.Context()
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	if providerID == "" {
+		http.Error(w, "provider_id is required", http.StatusBadRequest)
+		return
+	}
+	var req model.VerificationUpdate
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.IdentityVerified == nil && req.EndpointVerified == nil {
+		http.Error(w, "identity_verified or endpoint_verified is required", http.StatusBadRequest)
+		return
+	}
 	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	rec, err := s.store.GetTrustRecord(ctx, providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if rec == nil {
+		rec = &model.TrustRecord{
+			ProviderID:   providerID,
+			TrustScore:   0.3,
+			BaseScore:    0.3,
+			TrustTier:    model.TrustTierUnverified,
+			RegisteredAt: now,
+			LastUpdated:  now,
+		}
+	}
+	if req.IdentityVerified != nil {
+		rec.IdentityVerified = *req.IdentityVerified
+		rec.IdentityVerification = s.verificationStatus(req, *req.IdentityVerified, now)
+	}
+	if req.EndpointVerified != nil {
+		rec.EndpointVerified = *req.EndpointVerified
+		rec.EndpointVerification = s.verificationStatus(req, *req.EndpointVerified, now)
+	}
+	if err := s.store.UpsertTrustRecord(ctx, *rec); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	updated, prevScore, _, err := s.recalculate(ctx, providerID, model.TriggerVerification, nil)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"provider_id":           providerID,
+		"identity_verified":     updated.IdentityVerified,
+		"endpoint_verified":     updated.EndpointVerified,
+		"identity_verification": updated.IdentityVerification,
+		"endpoint_verification": updated.EndpointVerification,
+		"previous_score":        prevScore,
+		"new_score":             updated.TrustScore,
+	})
+}
+
+// recordOutcome admits out, saves it and rescores its provider. An outcome
+// for a contract the provider already has one for is ignored (recorded is
+// false), since the contract engine may report the same outcome directly and
+// as an event; the store refuses the second of two racing reports.
+func (s *Service) recordOutcome(ctx context.Context, out *model.ContractOutcome) (model.TrustRecord, float64, model.TrustTier, bool, error) {
+	existing, err := s.store.GetOutcome(ctx, out.ProviderID, out.ContractID)
+	if err != nil {
+		return model.TrustRecord{}, 0, "", false, err
+	}
+	if existing != nil {
+		return model.TrustRecord{}, 0, "", false, nil
+	}
+	if err := s.admitOutcome(ctx, out); err != nil {
+		return model.TrustRecord{}, 0, "", false, err
+	}
+	if out.ID == "" {
+		out.ID = generateID("out_")
+	}
+	if out.RecordedAt.IsZero() {
+		out.RecordedAt = time.Now().UTC()
+	}
+	if out.CompletedAt.IsZero() {
+		out.CompletedAt = out.RecordedAt
+	}
+	if err := s.store.SaveOutcome(ctx, *out); err != nil {
+		if errors.Is(err, store.ErrDuplicateOutcome) {
+			return model.TrustRecord{}, 0, "", false, nil
+		}
+		return model.TrustRecord{}, 0, "", false, err
+	}
+	s.metrics.outcomeIngested(out.Outcome)
+	updated, prevScore, prevTier, err := s.recalculate(ctx, out.ProviderID, model.TriggerOutcome, out)
+	return updated, prevScore, prevTier, err == nil, err
+}
+
+// recalculate rescores the provider from its outcomes and records a history
+// snapshot attributing the change to trigger and, if given, outcome.
+func (s *Service) recalculate(ctx context.Context, providerID, trigger string, outcome *model.ContractOutcome) (model.TrustRecord, float64, model.TrustTier, error) {
+	start := time.Now()
+	now := start.UTC()
 	rec, err := s.store.GetTrustRecord(ctx, providerID)
 	if err != nil {
 		return model.TrustRecord{}, 0, "", err
@@ -143,37 +411,26 @@ func (s *Service) recalculate(ctx context.Context, providerID string) (model.Tru
 	prevScore := rec.TrustScore
 	prevTier := rec.TrustTier
 
-	outcomes, err := s.store.ListOutcomes(ctx, providerID, 200)
+	outcomes, err := s.store.ListOutcomes(ctx, providerID, scoredOutcomes)
 	if err != nil {
 		return model.TrustRecord{}, 0, "", err
 	}
-	base := calculateWeightedScore(outcomes)
-	mod := 0.0
-	if rec.IdentityVerified {
-		mod += 0.05
-	}
-	if rec.EndpointVerified {
-		mod += 0.05
-	}
-	tenureMonths := monthsSince(rec.RegisteredAt, now)
-	if tenureMonths > 5 {
-		tenureMonths = 5
-	}
-	mod += float64(tenureMonths) * 0.02
-
-	final := clamp01(base + mod)
-	rec.BaseScore = base
-	rec.TrustScore = final
+	policy := s.currentPolicy()
+	rec.BaseScore = calculateWeightedScore(policy, outcomes)
+	rec.EffectiveOutcomes = effectiveOutcomes(policy, len(outcomes))
+	rec.SLAAdherence, rec.SLAReportedContracts, rec.SLABreaches = calculateSLAAdherence(policy, outcomes)
+	rec.Adjustments = activeAdjustments(rec.Adjustments, now)
 	rec.LastUpdated = now
 
 	// derive stats from outcomes
 	rec.TotalContracts = len(outcomes)
 	rec.SuccessfulContracts, rec.FailedContracts, rec.DisputedContracts = 0, 0, 0
+	rec.DisputesWon, rec.DisputesLost = 0, 0
 	for _, o := range outcomes {
 		switch o.Outcome {
 		case model.OutcomeSuccess, model.OutcomeSuccessPartial:
 			rec.SuccessfulContracts++
-		case model.OutcomeDisputeWon, model.OutcomeDisputeLost:
+		case model.OutcomeDisputeOpen, model.OutcomeDisputeWon, model.OutcomeDisputeLost:
 			rec.DisputedContracts++
 			if o.Outcome == model.OutcomeDisputeWon {
 				rec.DisputesWon++
@@ -188,47 +445,128 @@ func (s *Service) recalculate(ctx context.Context, providerID string) (model.Tru
 		t := outcomes[0].CompletedAt
 		rec.LastContractAt = &t
 	}
+	prevFlags := rec.Flags
+	rec.Flags = reviewFlags(outcomes)
+	logRaisedFlags(providerID, prevFlags, rec.Flags)
+	rec.Probation = updateProbation(providerID, policy, rec.Probation, outcomes, now)
 
-	rec.TrustTier = determineTier(rec.TrustScore, rec.TrustTier, rec.TotalContracts)
+	s.score(rec, now, policy)
 
 	if err := s.store.UpsertTrustRecord(ctx, *rec); err != nil {
 		return model.TrustRecord{}, 0, "", err
 	}
+	s.metrics.observeScoring(trigger, time.Since(start))
+	snap := model.TrustSnapshot{
+		ID:            generateID("snap_"),
+		ProviderID:    providerID,
+		TrustScore:    rec.TrustScore,
+		TrustTier:     rec.TrustTier,
+		PreviousScore: prevScore,
+		PreviousTier:  prevTier,
+		Trigger:       trigger,
+		Outcome:       outcome,
+		Breakdown:     rec.ScoreBreakdown,
+		PolicyVersion: rec.PolicyVersion,
+		RecordedAt:    now,
+	}
+	if err := s.store.SaveSnapshot(ctx, snap); err != nil {
+		slog.WarnContext(ctx, "trust history snapshot not saved", "provider_id", providerID, "error", err)
+	}
+	s.publishChanges(snap)
 	return *rec, prevScore, prevTier, nil
 }
 
-func determineTier(score float64, current model.TrustTier, total int) model.TrustTier {
+// score sets TrustScore, TrustTier and ScoreBreakdown from BaseScore, the
+// verification and tenure modifiers, active manual adjustments and the decay
+// for inactivity up to now.
+// It runs on every read so that decay and policy threshold changes apply
+// without a recalculation; recency weights apply from the next one.
+func (s *Service) score(rec *model.TrustRecord, now time.Time, policy model.ScoringPolicy) {
+	b := model.ScoreBreakdown{OutcomeScore: rec.BaseScore, PerformanceScore: rec.BaseScore, DecayFactor: 1}
+	if rec.SLAAdherence != nil && policy.SLAWeight > 0 {
+		adherence := *rec.SLAAdherence
+		b.SLAAdherence = &adherence
+		b.SLAWeight = policy.SLAWeight
+		b.PerformanceScore = (1-policy.SLAWeight)*rec.BaseScore + policy.SLAWeight*adherence
+	}
+
+	last := rec.RegisteredAt
+	if rec.LastContractAt != nil {
+		last = *rec.LastContractAt
+	}
+	idle := now.Sub(last)
+	if idle > 0 {
+		b.InactiveDays = math.Round(idle.Hours()/24*100) / 100
+	}
+	if s.decayHalfLife > 0 {
+		b.DecayHalfLifeDays = math.Round(s.decayHalfLife.Hours()/24*100) / 100
+		if idle > 0 {
+			b.DecayFactor = math.Pow(0.5, float64(idle)/float64(s.decayHalfLife))
+		}
+	}
+	b.DecayedOutcomeScore = neutralScore + (b.PerformanceScore-neutralScore)*b.DecayFactor
+
+	// Expired verifications stop counting until the provider is re-verified.
+	if rec.IdentityVerification.Expired(now) {
+		rec.IdentityVerified = false
+	}
+	if rec.EndpointVerification.Expired(now) {
+		rec.EndpointVerified = false
+	}
+	if rec.IdentityVerified {
+		b.VerificationBonus += 0.05
+	}
+	if rec.EndpointVerified {
+		b.VerificationBonus += 0.05
+	}
+	tenureMonths := monthsSince(rec.RegisteredAt, now)
+	if tenureMonths > 5 {
+		tenureMonths = 5
+	}
+	b.TenureBonus = float64(tenureMonths) * 0.02
+
+	for _, a := range activeAdjustments(rec.Adjustments, now) {
+		b.ManualAdjustment += a.Delta
+		b.Adjustments = append(b.Adjustments, model.AppliedAdjustment{
+			ID:         a.ID,
+			Delta:      a.Delta,
+			ReasonCode: a.ReasonCode,
+			Reason:     a.Reason,
+			ExpiresAt:  a.ExpiresAt,
+		})
+	}
+
+	rec.OnProbation = rec.Probation.Active(now)
+	rec.TrustScore = clamp01(b.DecayedOutcomeScore + b.VerificationBonus + b.TenureBonus + b.ManualAdjustment)
+	rec.TrustTier = determineTier(policy, rec.TrustScore, rec.TrustTier, rec.TotalContracts)
+	rec.ScoreBreakdown = &b
+	c := scoreConfidence(rec.BaseScore, rec.EffectiveOutcomes, rec.TrustScore)
+	rec.Confidence = &c
+	rec.PolicyVersion = policy.Version
+}
+
+// determineTier returns the highest policy tier whose thresholds are met.
+// Policy tiers are ordered from PREFERRED down by normalizePolicy.
+func determineTier(policy model.ScoringPolicy, score float64, current model.TrustTier, total int) model.TrustTier {
 	if current == model.TrustTierInternal {
 		return model.TrustTierInternal
 	}
-	switch {
-	case score >= 0.9 && total >= 100:
-		return model.TrustTierPreferred
-	case score >= 0.7 && total >= 25:
-		return model.TrustTierTrusted
-	case score >= 0.5 && total >= 5:
-		return model.TrustTierVerified
-	default:
-		return model.TrustTierUnverified
+	for _, t := range policy.Tiers {
+		if score >= t.MinScore && total >= t.MinContracts {
+			return t.Tier
+		}
 	}
+	return model.TrustTierUnverified
 }
 
-func calculateWeightedScore(outcomes []model.ContractOutcome) float64 {
+func calculateWeightedScore(policy model.ScoringPolicy, outcomes []model.ContractOutcome) float64 {
 	if len(outcomes) == 0 {
 		return 0.3
 	}
 	weightedSum := 0.0
 	weightSum := 0.0
 	for i, o := range outcomes {
-		weight := 0.1
-		switch {
-		case i < 10:
-			weight = 1.0
-		case i < 50:
-			weight = 0.5
-		case i < 100:
-			weight = 0.25
-		}
+		weight := recencyWeight(policy, i)
 		score := outcomeToScore(o.Outcome)
 		weightedSum += score * weight
 		weightSum += weight
@@ -239,6 +577,82 @@ func calculateWeightedScore(outcomes []model.ContractOutcome) float64 {
 	return weightedSum / weightSum
 }
 
+// calculateSLAAdherence weights the outcomes with SLA data by recency like
+// calculateWeightedScore and returns the weighted share that met their SLA,
+// or nil when none carries SLA data.
+func calculateSLAAdherence(policy model.ScoringPolicy, outcomes []model.ContractOutcome) (*float64, int, int) {
+	metSum, weightSum := 0.0, 0.0
+	reported, breaches := 0, 0
+	for i, o := range outcomes {
+		met, known := o.SLAMet()
+		if !known {
+			continue
+		}
+		reported++
+		weight := recencyWeight(policy, i)
+		weightSum += weight
+		if met {
+			metSum += weight
+		} else {
+			breaches++
+		}
+	}
+	if reported == 0 {
+		return nil, 0, 0
+	}
+	adherence := 1.0
+	if weightSum > 0 {
+		adherence = metSum / weightSum
+	}
+	return &adherence, reported, breaches
+}
+
+// effectiveOutcomes is Kish's effective sample size of n recency-weighted
+// outcomes, (Σw)² / Σw².
+func effectiveOutcomes(policy model.ScoringPolicy, n int) float64 {
+	sum, sumSq := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		w := recencyWeight(policy, i)
+		sum += w
+		sumSq += w * w
+	}
+	if sumSq == 0 {
+		return 0
+	}
+	return sum * sum / sumSq
+}
+
+// wilsonZ is the normal quantile of the 95% confidence interval.
+const wilsonZ = 1.96
+
+// scoreConfidence computes the Wilson interval of outcome score p over n
+// effective outcomes and shifts trustScore by the same margins. Without
+// outcomes the interval spans [0, 1].
+func scoreConfidence(p, n, trustScore float64) model.ScoreConfidence {
+	c := model.ScoreConfidence{Level: 0.95, EffectiveOutcomes: math.Round(n*100) / 100, OutcomeUpper: 1, Upper: 1}
+	if n <= 0 {
+		return c
+	}
+	z2 := wilsonZ * wilsonZ
+	denom := 1 + z2/n
+	center := (p + z2/(2*n)) / denom
+	margin := wilsonZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / denom
+	c.OutcomeLower = clamp01(center - margin)
+	c.OutcomeUpper = clamp01(center + margin)
+	c.Lower = clamp01(trustScore - (p - c.OutcomeLower))
+	c.Upper = clamp01(trustScore + (c.OutcomeUpper - p))
+	return c
+}
+
+func recencyWeight(policy model.ScoringPolicy, rank int) float64 {
+	for _, band := range policy.RecencyWeights {
+		if rank < band.Outcomes {
+			return band.Weight
+		}
+	}
+	return policy.OlderWeight
+}
+
 func outcomeToScore(out model.OutcomeType) float64 {
 	switch out {
 	case model.OutcomeSuccess:
@@ -251,6 +665,8 @@ func outcomeToScore(out model.OutcomeType) float64 {
 		return 0.5
 	case model.OutcomeFailureConsumer:
 		return 0.8
+	case model.OutcomeDisputeOpen:
+		return 0.5
 	case model.OutcomeDisputeWon:
 		return 0.8
 	case model.OutcomeDisputeLost:
@@ -304,6 +720,14 @@ func generateID(prefix string) string {
 	return prefix + hex.EncodeToString(b[:8])
 }
 
+func bearerToken(r *http.Request) string {
+	h := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
 func pathParam(path string, prefix string, suffix string) string {
 	if !strings.HasPrefix(path, prefix) {
 		return ""
@@ -103,7 +103,7 @@ func TestCalculateWeightedScore(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := calculateWeightedScore(tt.outcomes)
+			got := calculateWeightedScore(DefaultScoringPolicy(), tt.outcomes)
 
 			// Allow small floating point error
 			if !floatNear(got, tt.wantScore, 0.01) {
@@ -193,7 +193,7 @@ func TestDetermineTier(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := determineTier(tt.score, tt.currentTier, tt.totalContracts)
+			got := determineTier(DefaultScoringPolicy(), tt.score, tt.currentTier, tt.totalContracts)
 			if got != tt.wantTier {
 				t.Errorf("determineTier(%v, %v, %v) = %v, want %v",
 					tt.score, tt.currentTier, tt.totalContracts, got, tt.wantTier)
@@ -422,7 +422,7 @@ func TestTrustScoreIntegration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			base := calculateWeightedScore(tt.outcomes)
+			base := calculateWeightedScore(DefaultScoringPolicy(), tt.outcomes)
 			modifier := 0.0
 			if tt.identityVerified {
 				modifier += 0.05
@@ -450,6 +450,34 @@ func TestTrustScoreIntegration(t *testing.T) {
 	}
 }
 
+func TestScoreConfidence(t *testing.T) {
+	policy := DefaultScoringPolicy()
+	tests := []struct {
+		name     string
+		outcomes int
+		minLower float64 // bounds on outcome_lower for a perfect record
+		maxLower float64
+	}{
+		{"no outcomes", 0, 0, 0},
+		{"two outcomes", 2, 0.3, 0.4},
+		{"two hundred outcomes", 200, 0.95, 0.99},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := scoreConfidence(1.0, effectiveOutcomes(policy, tt.outcomes), 1.0)
+			if c.OutcomeLower < tt.minLower || c.OutcomeLower > tt.maxLower {
+				t.Errorf("outcome_lower = %v, want between %v and %v", c.OutcomeLower, tt.minLower, tt.maxLower)
+			}
+			if c.OutcomeUpper != 1 || c.Upper != 1 {
+				t.Errorf("upper bounds = %v/%v, want 1", c.OutcomeUpper, c.Upper)
+			}
+			if !floatNear(c.Lower, c.OutcomeLower, 1e-9) {
+				t.Errorf("lower = %v, want the outcome lower bound %v", c.Lower, c.OutcomeLower)
+			}
+		})
+	}
+}
+
 // Helper function to compare floats with tolerance
 func floatNear(a, b, tolerance float64) bool {
 	diff := a - b
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+)
+
+// verificationStatus records the provenance of a verification result. A
+// passed check expires at req.ExpiresAt, or after the configured TTL.
+func (s *Service) verificationStatus(req model.VerificationUpdate, verified bool, now time.Time) *model.VerificationStatus {
+	st := &model.VerificationStatus{
+		Verified:  verified,
+		Source:    req.Source,
+		Method:    req.Method,
+		UpdatedAt: now,
+	}
+	if !verified {
+		return st
+	}
+	st.VerifiedAt = &now
+	switch {
+	case req.ExpiresAt != nil:
+		exp := req.ExpiresAt.UTC()
+		st.ExpiresAt = &exp
+	case s.verificationTTL > 0:
+		exp := now.Add(s.verificationTTL)
+		st.ExpiresAt = &exp
+	}
+	return st
+}
+
+// HandleVerificationsDue lists passed verifications that expire within
+// ?within= (a Go duration, default 168h) or already have, so the services
+// that verify providers know whom to check again.
+func (s *Service) HandleVerificationsDue(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	within := 7 * 24 * time.Hour
+	if v := q.Get("within"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			http.Error(w, "within must be a non-negative duration", http.StatusBadRequest)
+			return
+		}
+		within = d
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	now := time.Now().UTC()
+	cutoff := now.Add(within)
+	recs, err := s.store.ListVerificationsDue(r.Context(), cutoff, limit)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	due := make([]map[string]any, 0, len(recs))
+	for _, rec := range recs {
+		for _, v := range []struct {
+			kind string
+			st   *model.VerificationStatus
+		}{{"identity", rec.IdentityVerification}, {"endpoint", rec.EndpointVerification}} {
+			kind, st := v.kind, v.st
+			if st == nil || !st.Verified || st.ExpiresAt == nil || st.ExpiresAt.After(cutoff) {
+				continue
+			}
+			due = append(due, map[string]any{
+				"provider_id": rec.ProviderID,
+				"kind":        kind,
+				"source":      st.Source,
+				"method":      st.Method,
+				"verified_at": st.VerifiedAt,
+				"expires_at":  st.ExpiresAt,
+				"expired":     st.Expired(now),
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"due": due, "cutoff": cutoff})
+}
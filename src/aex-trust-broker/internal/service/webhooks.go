@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
+	"github.com/parlakisik/agent-exchange/internal/events"
+)
+
+// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body,
+// keyed with the provider's webhook secret.
+const SignatureHeader = "X-AEX-Signature"
+
+const webhookMaxAttempts = 3
+
+// publishChanges announces a recalculation that moved the score or tier on
+// the event bus and to the provider's webhook. Scores that only drift with
+// decay between recalculations are not announced.
+func (s *Service) publishChanges(snap model.TrustSnapshot) {
+	tierChanged := snap.TrustTier != snap.PreviousTier
+	if snap.TrustScore == snap.PreviousScore && !tierChanged {
+		return
+	}
+	scoreData := map[string]any{
+		"provider_id":    snap.ProviderID,
+		"previous_score": snap.PreviousScore,
+		"new_score":      snap.TrustScore,
+		"previous_tier":  snap.PreviousTier,
+		"new_tier":       snap.TrustTier,
+		"reason":         snap.Trigger,
+	}
+	if snap.Outcome != nil {
+		scoreData["contract_id"] = snap.Outcome.ContractID
+		scoreData["outcome"] = snap.Outcome.Outcome
+	}
+	pending := []events.Envelope{s.envelope(snap, events.EventTrustScoreUpdated, scoreData)}
+	if tierChanged {
+		pending = append(pending, s.envelope(snap, events.EventTrustTierChanged, map[string]any{
+			"provider_id":   snap.ProviderID,
+			"previous_tier": snap.PreviousTier,
+			"new_tier":      snap.TrustTier,
+			"effective_at":  snap.RecordedAt,
+		}))
+	}
+
+	// Delivered in order in the background; failures never fail the
+	// request that caused the change.
+	go func() {
+		ctx := context.Background()
+		for _, ev := range pending {
+			if err := s.events.Publish(ctx, ev.EventType, ev.Data); err != nil {
+				slog.Warn("event publish failed", "event_type", ev.EventType, "provider_id", snap.ProviderID, "error", err)
+			}
+		}
+		wh, err := s.store.GetWebhook(ctx, snap.ProviderID)
+		if err != nil {
+			slog.Error("trust webhook lookup failed", "provider_id", snap.ProviderID, "error", err)
+			return
+		}
+		if wh == nil {
+			return
+		}
+		for _, ev := range pending {
+			s.deliverWebhook(*wh, ev)
+		}
+	}()
+}
+
+func (s *Service) envelope(snap model.TrustSnapshot, eventType string, data map[string]any) events.Envelope {
+	return events.Envelope{
+		EventID:        generateID("evt_"),
+		EventType:      eventType,
+		SchemaVersion:  "1.0",
+		IdempotencyKey: snap.ID + ":" + eventType,
+		Timestamp:      snap.RecordedAt,
+		Source:         "aex-trust-broker",
+		Data:           data,
+	}
+}
+
+// deliverWebhook posts ev to the provider's webhook, retrying with
+// exponential backoff.
+func (s *Service) deliverWebhook(wh model.TrustWebhook, ev events.Envelope) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("trust webhook marshal failed", "event_id", ev.EventID, "error", err)
+		return
+	}
+	wait := s.webhookBackoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		if err = s.postWebhook(wh, body); err == nil {
+			return
+		}
+		if attempt < webhookMaxAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	slog.Warn("trust webhook delivery failed", "event_id", ev.EventID, "provider_id", wh.ProviderID, "attempts", webhookMaxAttempts, "error", err)
+}
+
+func (s *Service) postWebhook(wh model.TrustWebhook, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(wh.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := s.webhookHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// HandlePutWebhook registers or replaces the provider's trust webhook.
+func (s *Service) HandlePutWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	providerID, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+	var req model.TrustWebhookRequest
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		http.Error(w, "url must be an http(s) URL", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	wh := model.TrustWebhook{ProviderID: providerID, URL: req.URL, Secret: req.Secret, CreatedAt: now, UpdatedAt: now}
+	existing, err := s.store.GetWebhook(ctx, providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		wh.CreatedAt = existing.CreatedAt
+	}
+	if err := s.store.UpsertWebhook(ctx, wh); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, wh)
+}
+
+// HandleGetWebhook returns the provider's trust webhook without its secret.
+func (s *Service) HandleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	providerID, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+	wh, err := s.store.GetWebhook(r.Context(), providerID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if wh == nil {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, wh)
+}
+
+func (s *Service) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	providerID, ok := s.authorizeProvider(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteWebhook(r.Context(), providerID); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeProvider checks that the Bearer API key belongs to the provider
+// in the path, as reported by the provider registry.
+func (s *Service) authorizeProvider(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if s.providerAuth == nil {
+		http.Error(w, "provider authentication is not configured", http.StatusServiceUnavailable)
+		return "", false
+	}
+	providerID := strings.TrimSpace(r.PathValue("provider_id"))
+	token := bearerToken(r)
+	if token == "" || providerID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	owner, err := s.providerAuth.ValidateAPIKey(r.Context(), token)
+	if err != nil || owner != providerID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	return providerID, true
+}
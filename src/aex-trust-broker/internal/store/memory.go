@@ -4,20 +4,32 @@ import (
 	"context"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
 )
 
 type MemoryStore struct {
-	mu       sync.RWMutex
-	trust    map[string]model.TrustRecord
-	outcomes map[string][]model.ContractOutcome
+	mu        sync.RWMutex
+	trust     map[string]model.TrustRecord
+	outcomes  map[string][]model.ContractOutcome
+	snapshots map[string][]model.TrustSnapshot
+	webhooks  map[string]model.TrustWebhook
+	policies  []model.ScoringPolicy
+
+	adjustments map[string]model.ScoreAdjustment
+	incidents   map[string][]model.ConsumerIncident
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		trust:    map[string]model.TrustRecord{},
-		outcomes: map[string][]model.ContractOutcome{},
+		trust:     map[string]model.TrustRecord{},
+		outcomes:  map[string][]model.ContractOutcome{},
+		snapshots: map[string][]model.TrustSnapshot{},
+		webhooks:  map[string]model.TrustWebhook{},
+
+		adjustments: map[string]model.ScoreAdjustment{},
+		incidents:   map[string][]model.ConsumerIncident{},
 	}
 }
 
@@ -41,10 +53,48 @@ func (s *MemoryStore) GetTrustRecord(ctx context.Context, providerID string) (*m
 	return &out, nil
 }
 
+func (s *MemoryStore) ListTrustRecords(ctx context.Context) ([]model.TrustRecord, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.TrustRecord, 0, len(s.trust))
+	for _, rec := range s.trust {
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ListVerificationsDue(ctx context.Context, before time.Time, limit int) ([]model.TrustRecord, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	due := func(v *model.VerificationStatus) bool {
+		return v != nil && v.Verified && v.ExpiresAt != nil && !v.ExpiresAt.After(before)
+	}
+	out := make([]model.TrustRecord, 0)
+	for _, rec := range s.trust {
+		if due(rec.IdentityVerification) || due(rec.EndpointVerification) {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ProviderID < out[j].ProviderID })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
 func (s *MemoryStore) SaveOutcome(ctx context.Context, out model.ContractOutcome) error {
 	_ = ctx
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if out.SupersededBy == "" {
+		for _, o := range s.outcomes[out.ProviderID] {
+			if o.ContractID == out.ContractID && o.SupersededBy == "" {
+				return ErrDuplicateOutcome
+			}
+		}
+	}
 	s.outcomes[out.ProviderID] = append(s.outcomes[out.ProviderID], out)
 	// keep most recent first
 	sort.Slice(s.outcomes[out.ProviderID], func(i, j int) bool {
@@ -53,15 +103,229 @@ func (s *MemoryStore) SaveOutcome(ctx context.Context, out model.ContractOutcome
 	return nil
 }
 
+func (s *MemoryStore) UpdateOutcome(ctx context.Context, out model.ContractOutcome) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	outs := s.outcomes[out.ProviderID]
+	for i := range outs {
+		if outs[i].ID == out.ID {
+			outs[i] = out
+		}
+	}
+	sort.Slice(outs, func(i, j int) bool { return outs[i].CompletedAt.After(outs[j].CompletedAt) })
+	return nil
+}
+
+func (s *MemoryStore) GetOutcome(ctx context.Context, providerID, contractID string) (*model.ContractOutcome, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, o := range s.outcomes[providerID] {
+		if o.ContractID == contractID && o.SupersededBy == "" {
+			out := o
+			return &out, nil
+		}
+	}
+	return nil, nil
+}
+
 func (s *MemoryStore) ListOutcomes(ctx context.Context, providerID string, limit int) ([]model.ContractOutcome, error) {
 	_ = ctx
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	outs := s.outcomes[providerID]
-	if limit > 0 && len(outs) > limit {
-		outs = outs[:limit]
+	out := make([]model.ContractOutcome, 0, len(s.outcomes[providerID]))
+	for _, o := range s.outcomes[providerID] {
+		if o.SupersededBy != "" {
+			continue
+		}
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ListOutcomeProviders(ctx context.Context) ([]string, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]string, 0, len(s.outcomes))
+	for id, outs := range s.outcomes {
+		if len(outs) > 0 {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ListConsumerOutcomes(ctx context.Context, consumerID string, limit int) ([]model.ContractOutcome, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.ContractOutcome, 0)
+	for _, outs := range s.outcomes {
+		for _, o := range outs {
+			if o.ConsumerID == consumerID && o.SupersededBy == "" {
+				out = append(out, o)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CompletedAt.After(out[j].CompletedAt) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) SaveIncident(ctx context.Context, inc model.ConsumerIncident) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	incs := append(s.incidents[inc.ConsumerID], inc)
+	sort.Slice(incs, func(i, j int) bool { return incs[i].OccurredAt.After(incs[j].OccurredAt) })
+	s.incidents[inc.ConsumerID] = incs
+	return nil
+}
+
+func (s *MemoryStore) GetIncident(ctx context.Context, consumerID string, kind model.IncidentKind, contractID string) (*model.ConsumerIncident, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, inc := range s.incidents[consumerID] {
+		if inc.Kind == kind && inc.ContractID == contractID {
+			out := inc
+			return &out, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) ListIncidents(ctx context.Context, consumerID string, limit int) ([]model.ConsumerIncident, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	incs := s.incidents[consumerID]
+	if limit > 0 && len(incs) > limit {
+		incs = incs[:limit]
+	}
+	return append([]model.ConsumerIncident(nil), incs...), nil
+}
+
+func (s *MemoryStore) SaveSnapshot(ctx context.Context, snap model.TrustSnapshot) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[snap.ProviderID] = append(s.snapshots[snap.ProviderID], snap)
+	return nil
+}
+
+func (s *MemoryStore) ListSnapshots(ctx context.Context, providerID string, from, to time.Time, limit int) ([]model.TrustSnapshot, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.TrustSnapshot, 0)
+	for _, snap := range s.snapshots[providerID] {
+		if !from.IsZero() && snap.RecordedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && snap.RecordedAt.After(to) {
+			continue
+		}
+		out = append(out, snap)
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) UpsertWebhook(ctx context.Context, wh model.TrustWebhook) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhooks[wh.ProviderID] = wh
+	return nil
+}
+
+func (s *MemoryStore) GetWebhook(ctx context.Context, providerID string) (*model.TrustWebhook, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	wh, ok := s.webhooks[providerID]
+	if !ok {
+		return nil, nil
+	}
+	return &wh, nil
+}
+
+func (s *MemoryStore) DeleteWebhook(ctx context.Context, providerID string) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.webhooks, providerID)
+	return nil
+}
+
+func (s *MemoryStore) SavePolicy(ctx context.Context, p model.ScoringPolicy) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.policies {
+		if existing.Version == p.Version {
+			return ErrPolicyVersionExists
+		}
+	}
+	s.policies = append(s.policies, p)
+	return nil
+}
+
+func (s *MemoryStore) ListPolicies(ctx context.Context, limit int) ([]model.ScoringPolicy, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := append([]model.ScoringPolicy(nil), s.policies...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Version > out[j].Version })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) SaveAdjustment(ctx context.Context, a model.ScoreAdjustment) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adjustments[a.ID] = a
+	return nil
+}
+
+func (s *MemoryStore) UpdateAdjustment(ctx context.Context, a model.ScoreAdjustment) error {
+	return s.SaveAdjustment(ctx, a)
+}
+
+func (s *MemoryStore) GetAdjustment(ctx context.Context, id string) (*model.ScoreAdjustment, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.adjustments[id]
+	if !ok {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (s *MemoryStore) ListAdjustments(ctx context.Context, providerID string) ([]model.ScoreAdjustment, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.ScoreAdjustment, 0)
+	for _, a := range s.adjustments {
+		if a.ProviderID == providerID {
+			out = append(out, a)
+		}
 	}
-	out := make([]model.ContractOutcome, len(outs))
-	copy(out, outs)
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
 	return out, nil
 }
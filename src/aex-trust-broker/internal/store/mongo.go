@@ -11,15 +11,27 @@ import (
 )
 
 type MongoStore struct {
-	trust    *mongo.Collection
-	outcomes *mongo.Collection
+	trust     *mongo.Collection
+	outcomes  *mongo.Collection
+	snapshots *mongo.Collection
+	webhooks  *mongo.Collection
+	policies  *mongo.Collection
+
+	adjustments *mongo.Collection
+	incidents   *mongo.Collection
 }
 
-func NewMongoStore(client *mongo.Client, dbName, trustColl, outcomesColl string) *MongoStore {
+func NewMongoStore(client *mongo.Client, dbName, trustColl, outcomesColl, snapshotsColl, webhooksColl, policiesColl, adjustmentsColl, incidentsColl string) *MongoStore {
 	db := client.Database(dbName)
 	return &MongoStore{
-		trust:    db.Collection(trustColl),
-		outcomes: db.Collection(outcomesColl),
+		trust:     db.Collection(trustColl),
+		outcomes:  db.Collection(outcomesColl),
+		snapshots: db.Collection(snapshotsColl),
+		webhooks:  db.Collection(webhooksColl),
+		policies:  db.Collection(policiesColl),
+
+		adjustments: db.Collection(adjustmentsColl),
+		incidents:   db.Collection(incidentsColl),
 	}
 }
 
@@ -31,8 +43,51 @@ func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	_, err = s.outcomes.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.D{{Key: "provider_id", Value: 1}, {Key: "completed_at", Value: -1}},
+	_, err = s.outcomes.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "provider_id", Value: 1}, {Key: "completed_at", Value: -1}}},
+		{
+			// Current outcomes have no superseded_by, so a provider has at
+			// most one per contract; superseded ones each name a different
+			// outcome.
+			Keys:    bson.D{{Key: "provider_id", Value: 1}, {Key: "contract_id", Value: 1}, {Key: "superseded_by", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "id", Value: 1}}},
+		{Keys: bson.D{{Key: "consumer_id", Value: 1}, {Key: "completed_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.snapshots.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "provider_id", Value: 1}, {Key: "recorded_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.webhooks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "provider_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.policies.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.adjustments.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "provider_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.incidents.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "consumer_id", Value: 1}, {Key: "occurred_at", Value: -1}}},
+		{Keys: bson.D{{Key: "consumer_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "contract_id", Value: 1}}},
 	})
 	return err
 }
@@ -61,21 +116,129 @@ func (s *MongoStore) GetTrustRecord(ctx context.Context, providerID string) (*mo
 	return &rec, nil
 }
 
+func (s *MongoStore) ListTrustRecords(ctx context.Context) ([]model.TrustRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cur, err := s.trust.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+
+	out := make([]model.TrustRecord, 0)
+	for cur.Next(ctx) {
+		var rec model.TrustRecord
+		if err := cur.Decode(&rec); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *MongoStore) ListVerificationsDue(ctx context.Context, before time.Time, limit int) ([]model.TrustRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"identity_verification.verified": true, "identity_verification.expires_at": bson.M{"$lte": before}},
+		bson.M{"endpoint_verification.verified": true, "endpoint_verification.expires_at": bson.M{"$lte": before}},
+	}}
+	opts := options.Find().SetSort(bson.D{{Key: "provider_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := s.trust.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+
+	out := make([]model.TrustRecord, 0)
+	for cur.Next(ctx) {
+		var rec model.TrustRecord
+		if err := cur.Decode(&rec); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (s *MongoStore) SaveOutcome(ctx context.Context, out model.ContractOutcome) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	_, err := s.outcomes.InsertOne(ctx, out)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateOutcome
+	}
 	return err
 }
 
+// currentOutcome matches outcomes that have not been superseded.
+var currentOutcome = bson.M{"$in": bson.A{nil, ""}}
+
+func (s *MongoStore) UpdateOutcome(ctx context.Context, out model.ContractOutcome) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.outcomes.ReplaceOne(ctx, bson.M{"id": out.ID}, out)
+	return err
+}
+
+func (s *MongoStore) GetOutcome(ctx context.Context, providerID, contractID string) (*model.ContractOutcome, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.outcomes.FindOne(ctx, bson.M{"provider_id": providerID, "contract_id": contractID, "superseded_by": currentOutcome})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var o model.ContractOutcome
+	if err := res.Decode(&o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
 func (s *MongoStore) ListOutcomes(ctx context.Context, providerID string, limit int) ([]model.ContractOutcome, error) {
+	return s.findOutcomes(ctx, bson.M{"provider_id": providerID, "superseded_by": currentOutcome}, limit)
+}
+
+func (s *MongoStore) ListOutcomeProviders(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	vals, err := s.outcomes.Distinct(ctx, "provider_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if id, ok := v.(string); ok {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+func (s *MongoStore) ListConsumerOutcomes(ctx context.Context, consumerID string, limit int) ([]model.ContractOutcome, error) {
+	return s.findOutcomes(ctx, bson.M{"consumer_id": consumerID, "superseded_by": currentOutcome}, limit)
+}
+
+func (s *MongoStore) findOutcomes(ctx context.Context, filter bson.M, limit int) ([]model.ContractOutcome, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	opts := options.Find().SetSort(bson.D{{Key: "completed_at", Value: -1}})
 	if limit > 0 {
 		opts.SetLimit(int64(limit))
 	}
-	cur, err := s.outcomes.Find(ctx, bson.M{"provider_id": providerID}, opts)
+	cur, err := s.outcomes.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -94,3 +257,222 @@ func (s *MongoStore) ListOutcomes(ctx context.Context, providerID string, limit
 	}
 	return out, nil
 }
+
+func (s *MongoStore) SaveSnapshot(ctx context.Context, snap model.TrustSnapshot) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.snapshots.InsertOne(ctx, snap)
+	return err
+}
+
+func (s *MongoStore) ListSnapshots(ctx context.Context, providerID string, from, to time.Time, limit int) ([]model.TrustSnapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	filter := bson.M{"provider_id": providerID}
+	recorded := bson.M{}
+	if !from.IsZero() {
+		recorded["$gte"] = from
+	}
+	if !to.IsZero() {
+		recorded["$lte"] = to
+	}
+	if len(recorded) > 0 {
+		filter["recorded_at"] = recorded
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "recorded_at", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := s.snapshots.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+
+	out := make([]model.TrustSnapshot, 0)
+	for cur.Next(ctx) {
+		var snap model.TrustSnapshot
+		if err := cur.Decode(&snap); err != nil {
+			return nil, err
+		}
+		out = append(out, snap)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *MongoStore) UpsertWebhook(ctx context.Context, wh model.TrustWebhook) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.webhooks.ReplaceOne(ctx, bson.M{"provider_id": wh.ProviderID}, wh, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) GetWebhook(ctx context.Context, providerID string) (*model.TrustWebhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.webhooks.FindOne(ctx, bson.M{"provider_id": providerID})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var wh model.TrustWebhook
+	if err := res.Decode(&wh); err != nil {
+		return nil, err
+	}
+	return &wh, nil
+}
+
+func (s *MongoStore) DeleteWebhook(ctx context.Context, providerID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.webhooks.DeleteOne(ctx, bson.M{"provider_id": providerID})
+	return err
+}
+
+func (s *MongoStore) SavePolicy(ctx context.Context, p model.ScoringPolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.policies.InsertOne(ctx, p)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPolicyVersionExists
+	}
+	return err
+}
+
+func (s *MongoStore) ListPolicies(ctx context.Context, limit int) ([]model.ScoringPolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := s.policies.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+
+	out := make([]model.ScoringPolicy, 0)
+	for cur.Next(ctx) {
+		var p model.ScoringPolicy
+		if err := cur.Decode(&p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *MongoStore) SaveAdjustment(ctx context.Context, a model.ScoreAdjustment) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.adjustments.InsertOne(ctx, a)
+	return err
+}
+
+func (s *MongoStore) UpdateAdjustment(ctx context.Context, a model.ScoreAdjustment) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.adjustments.ReplaceOne(ctx, bson.M{"id": a.ID}, a)
+	return err
+}
+
+func (s *MongoStore) GetAdjustment(ctx context.Context, id string) (*model.ScoreAdjustment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.adjustments.FindOne(ctx, bson.M{"id": id})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var a model.ScoreAdjustment
+	if err := res.Decode(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *MongoStore) ListAdjustments(ctx context.Context, providerID string) ([]model.ScoreAdjustment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cur, err := s.adjustments.Find(ctx, bson.M{"provider_id": providerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+
+	out := make([]model.ScoreAdjustment, 0)
+	for cur.Next(ctx) {
+		var a model.ScoreAdjustment
+		if err := cur.Decode(&a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *MongoStore) SaveIncident(ctx context.Context, inc model.ConsumerIncident) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.incidents.InsertOne(ctx, inc)
+	return err
+}
+
+func (s *MongoStore) GetIncident(ctx context.Context, consumerID string, kind model.IncidentKind, contractID string) (*model.ConsumerIncident, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res := s.incidents.FindOne(ctx, bson.M{"consumer_id": consumerID, "kind": kind, "contract_id": contractID})
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	var inc model.ConsumerIncident
+	if err := res.Decode(&inc); err != nil {
+		return nil, err
+	}
+	return &inc, nil
+}
+
+func (s *MongoStore) ListIncidents(ctx context.Context, consumerID string, limit int) ([]model.ConsumerIncident, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "occurred_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := s.incidents.Find(ctx, bson.M{"consumer_id": consumerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(ctx) }()
+
+	out := make([]model.ConsumerIncident, 0)
+	for cur.Next(ctx) {
+		var inc model.ConsumerIncident
+		if err := cur.Decode(&inc); err != nil {
+			return nil, err
+		}
+		out = append(out, inc)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
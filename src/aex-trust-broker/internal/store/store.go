@@ -2,14 +2,68 @@ package store
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
 )
 
+// ErrPolicyVersionExists is returned when saving a scoring policy whose
+// version is already stored.
+var ErrPolicyVersionExists = errors.New("scoring policy version already exists")
+
+// ErrDuplicateOutcome is returned when saving a current outcome for a
+// contract the provider already has a current outcome for.
+var ErrDuplicateOutcome = errors.New("contract already has an outcome for the provider")
+
 type Store interface {
 	UpsertTrustRecord(ctx context.Context, rec model.TrustRecord) error
 	GetTrustRecord(ctx context.Context, providerID string) (*model.TrustRecord, error)
+	// ListTrustRecords returns every provider's trust record.
+	ListTrustRecords(ctx context.Context) ([]model.TrustRecord, error)
+	// ListVerificationsDue returns up to limit records with a passed
+	// identity or endpoint verification expiring at or before before.
+	ListVerificationsDue(ctx context.Context, before time.Time, limit int) ([]model.TrustRecord, error)
 
+	// SaveOutcome returns ErrDuplicateOutcome, and saves nothing, when out
+	// is current and the provider already has a current outcome for its
+	// contract.
 	SaveOutcome(ctx context.Context, out model.ContractOutcome) error
+	// UpdateOutcome replaces the outcome with the same ID.
+	UpdateOutcome(ctx context.Context, out model.ContractOutcome) error
+	// GetOutcome returns the provider's current (not superseded) outcome
+	// for a contract, or nil.
+	GetOutcome(ctx context.Context, providerID, contractID string) (*model.ContractOutcome, error)
+	// ListOutcomes returns the provider's current outcomes, newest first.
 	ListOutcomes(ctx context.Context, providerID string, limit int) ([]model.ContractOutcome, error)
+	// ListOutcomeProviders returns the ids of all providers with outcomes.
+	ListOutcomeProviders(ctx context.Context) ([]string, error)
+	// ListConsumerOutcomes returns the current outcomes of the consumer's
+	// contracts with any provider, newest first.
+	ListConsumerOutcomes(ctx context.Context, consumerID string, limit int) ([]model.ContractOutcome, error)
+
+	SaveIncident(ctx context.Context, inc model.ConsumerIncident) error
+	// GetIncident returns the consumer's incident of kind for a contract, or nil.
+	GetIncident(ctx context.Context, consumerID string, kind model.IncidentKind, contractID string) (*model.ConsumerIncident, error)
+	// ListIncidents returns up to limit of the consumer's incidents, newest first.
+	ListIncidents(ctx context.Context, consumerID string, limit int) ([]model.ConsumerIncident, error)
+
+	SaveSnapshot(ctx context.Context, snap model.TrustSnapshot) error
+	// ListSnapshots returns up to limit snapshots recorded in [from, to],
+	// oldest first. A zero from or to leaves that side open.
+	ListSnapshots(ctx context.Context, providerID string, from, to time.Time, limit int) ([]model.TrustSnapshot, error)
+
+	UpsertWebhook(ctx context.Context, wh model.TrustWebhook) error
+	GetWebhook(ctx context.Context, providerID string) (*model.TrustWebhook, error)
+	DeleteWebhook(ctx context.Context, providerID string) error
+
+	SaveAdjustment(ctx context.Context, a model.ScoreAdjustment) error
+	UpdateAdjustment(ctx context.Context, a model.ScoreAdjustment) error
+	GetAdjustment(ctx context.Context, id string) (*model.ScoreAdjustment, error)
+	// ListAdjustments returns all of the provider's adjustments, newest first.
+	ListAdjustments(ctx context.Context, providerID string) ([]model.ScoreAdjustment, error)
+
+	SavePolicy(ctx context.Context, p model.ScoringPolicy) error
+	// ListPolicies returns up to limit scoring policies, newest version first.
+	ListPolicies(ctx context.Context, limit int) ([]model.ScoringPolicy, error)
 }
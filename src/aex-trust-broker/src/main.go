@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,8 +11,10 @@ import (
 
 	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/config"
 	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/httpapi"
+	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/service"
 	"github.com/parlakisik/agent-exchange/aex-trust-broker/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/serviceauth"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -20,6 +22,12 @@ import (
 func main() {
 	cfg := config.Load()
 
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+
 	var st store.Store
 	var mongoClient *mongo.Client
 	if cfg.MongoURI != "" {
@@ -27,22 +35,69 @@ func main() {
 		defer cancel()
 		c, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
 		if err != nil {
-			log.Fatal(err)
+			slog.Error("failed to connect to mongodb", "error", err)
+			os.Exit(1)
 		}
 		mongoClient = c
 
-		ms := store.NewMongoStore(c, cfg.MongoDatabase, cfg.MongoCollectionTrust, cfg.MongoCollectionOutcomes)
+		ms := store.NewMongoStore(c, cfg.MongoDatabase, cfg.MongoCollectionTrust, cfg.MongoCollectionOutcomes, cfg.MongoCollectionHistory, cfg.MongoCollectionWebhooks, cfg.MongoCollectionPolicies, cfg.MongoCollectionAdjust, cfg.MongoCollectionIncident)
 		if err := ms.EnsureIndexes(ctx); err != nil {
-			log.Printf("mongo index creation failed: %v", err)
+			slog.Warn("mongo index creation failed", "error", err)
 		}
 		st = ms
-		log.Printf("mongo enabled uri=%s db=%s", cfg.MongoURI, cfg.MongoDatabase)
+		slog.Info("mongo enabled", "uri", cfg.MongoURI, "db", cfg.MongoDatabase)
 	} else {
 		st = store.NewMemoryStore()
-		log.Printf("mongo disabled (set MONGO_URI to enable)")
+		slog.Info("mongo disabled (set MONGO_URI to enable)")
 	}
 
-	svc := service.New(st)
+	var policy *model.ScoringPolicy
+	if cfg.PolicyFile != "" {
+		p, err := service.LoadScoringPolicy(cfg.PolicyFile)
+		if err != nil {
+			slog.Error("failed to load scoring policy", "file", cfg.PolicyFile, "error", err)
+			os.Exit(1)
+		}
+		policy = &p
+	}
+
+	var serviceTokens *serviceauth.Verifier
+	if cfg.IdentityURL != "" {
+		serviceTokens = serviceauth.NewVerifier(cfg.IdentityURL, "aex-trust-broker")
+		slog.Info("service account tokens accepted", "identity_url", cfg.IdentityURL)
+	}
+
+	if cfg.InternalToken == "" && serviceTokens == nil {
+		slog.Warn("internal routes refuse all requests (set INTERNAL_TOKEN or IDENTITY_URL)")
+	}
+	if cfg.AdminToken == "" {
+		slog.Warn("admin routes are disabled (set ADMIN_TOKEN)")
+	}
+
+	svc := service.NewWithOptions(st, service.Options{
+		DecayHalfLife:       cfg.DecayHalfLife,
+		VerificationTTL:     cfg.VerificationTTL,
+		EventsURL:           cfg.EventsURL,
+		ProviderRegistryURL: cfg.ProviderRegistryURL,
+		Policy:              policy,
+		AdminToken:          cfg.AdminToken,
+		InternalToken:       cfg.InternalToken,
+		ServiceTokens:       serviceTokens,
+		EventsSecret:        cfg.EventsSecret,
+		ContractEngineURL:   cfg.ContractEngineURL,
+		SuccessRateLimit:    cfg.SuccessRateLimit,
+	})
+	initCtx, initCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	active, err := svc.InitPolicy(initCtx)
+	initCancel()
+	if err != nil {
+		slog.Error("failed to init scoring policy", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("scoring policy loaded", "version", active.Version)
+	if cfg.DecayHalfLife > 0 {
+		slog.Info("trust decay enabled", "half_life", cfg.DecayHalfLife.String())
+	}
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      httpapi.NewRouter(svc),
@@ -52,15 +107,17 @@ func main() {
 	}
 
 	go func() {
-		log.Printf("listening on :%s", cfg.Port)
+		slog.Info("http server listening", "addr", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal(err)
+			slog.Error("http server error", "error", err)
+			os.Exit(1)
 		}
 	}()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
+	slog.Info("shutting down server")
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -6,6 +6,7 @@ require (
 	cloud.google.com/go/firestore v1.14.0
 	github.com/parlakisik/agent-exchange/internal/events v0.0.0
 	github.com/parlakisik/agent-exchange/internal/httpclient v0.0.0
+	github.com/parlakisik/agent-exchange/internal/taxonomy v0.0.0
 	go.mongodb.org/mongo-driver v1.13.1
 	google.golang.org/api v0.150.0
 )
@@ -13,6 +14,7 @@ require (
 replace (
 	github.com/parlakisik/agent-exchange/internal/events => ../internal/events
 	github.com/parlakisik/agent-exchange/internal/httpclient => ../internal/httpclient
+	github.com/parlakisik/agent-exchange/internal/taxonomy => ../internal/taxonomy
 )
 
 require (
@@ -40,3 +40,22 @@ func (c *ProviderRegistryClient) GetSubscribedProviders(ctx context.Context, cat
 
 	return result.Providers, nil
 }
+
+// NotifyWorkAvailable asks the registry to deliver work.available webhooks
+// to the subscriptions matching the work's category.
+func (c *ProviderRegistryClient) NotifyWorkAvailable(ctx context.Context, work model.WorkSpec) error {
+	var result struct {
+		Notified []string `json:"notified_subscriptions"`
+	}
+	return httpclient.NewRequest("POST", c.baseURL).
+		Path("/internal/v1/work/available").
+		JSON(map[string]any{
+			"work_id":            work.ID,
+			"category":           work.Category,
+			"description":        work.Description,
+			"budget":             work.Budget,
+			"bid_window_ends_at": work.BidWindowEndsAt,
+		}).
+		Context(ctx).
+		ExecuteJSON(c.client, &result)
+}
@@ -15,6 +15,10 @@ type Config struct {
 	FirestoreProjectID  string
 	FirestoreCollection string
 	ProviderRegistryURL string
+	// StrictTaxonomy rejects work whose category is not in the capability
+	// taxonomy; TaxonomyFile replaces the built-in taxonomy.
+	StrictTaxonomy bool
+	TaxonomyFile   string
 }
 
 func Load() (*Config, error) {
@@ -28,6 +32,8 @@ func Load() (*Config, error) {
 		FirestoreProjectID:  getEnv("FIRESTORE_PROJECT_ID", ""),
 		FirestoreCollection: getEnv("FIRESTORE_COLLECTION_WORK", "work_specs"),
 		ProviderRegistryURL: getEnv("PROVIDER_REGISTRY_URL", "http://localhost:8086"),
+		StrictTaxonomy:      getEnv("STRICT_TAXONOMY", "false") == "true",
+		TaxonomyFile:        getEnv("TAXONOMY_FILE", ""),
 	}
 
 	if cfg.Environment == "production" && cfg.StoreType == "firestore" && cfg.FirestoreProjectID == "" {
@@ -51,6 +51,7 @@ type WorkSpec struct {
 	SuccessCriteria []SuccessCriterion `json:"success_criteria" firestore:"success_criteria"`
 	BidWindowMs     int64              `json:"bid_window_ms" firestore:"bid_window_ms"`
 	Payload         map[string]any     `json:"payload" firestore:"payload"`
+	CallbackURL     string             `json:"callback_url,omitempty" firestore:"callback_url,omitempty"`
 
 	State             WorkState `json:"status" firestore:"status"`
 	ProvidersNotified int       `json:"providers_notified" firestore:"providers_notified"`
@@ -72,6 +73,7 @@ type WorkSubmission struct {
 	SuccessCriteria []SuccessCriterion `json:"success_criteria"`
 	BidWindowMs     int64              `json:"bid_window_ms"`
 	Payload         map[string]any     `json:"payload"`
+	CallbackURL     string             `json:"callback_url,omitempty"` // receives contract state notifications
 }
 
 // WorkResponse is returned after submitting work
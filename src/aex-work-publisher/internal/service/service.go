@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"strings"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/parlakisik/agent-exchange/aex-work-publisher/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-work-publisher/internal/store"
 	"github.com/parlakisik/agent-exchange/internal/events"
+	"github.com/parlakisik/agent-exchange/internal/taxonomy"
 )
 
 var (
@@ -29,6 +31,7 @@ type Service struct {
 	store            store.WorkStore
 	providerRegistry *clients.ProviderRegistryClient
 	events           *events.Publisher
+	taxonomy         *taxonomy.Taxonomy
 }
 
 func New(st store.WorkStore, providerRegistryURL string) *Service {
@@ -39,6 +42,12 @@ func New(st store.WorkStore, providerRegistryURL string) *Service {
 	}
 }
 
+// SetTaxonomy restricts work categories to the ids of the capability
+// taxonomy shared with the provider registry. Nil accepts any category.
+func (s *Service) SetTaxonomy(t *taxonomy.Taxonomy) {
+	s.taxonomy = t
+}
+
 // PublishWork submits a new work specification
 func (s *Service) PublishWork(ctx context.Context, consumerID string, req model.WorkSubmission) (model.WorkResponse, error) {
 	// 1. Validate work spec
@@ -74,6 +83,7 @@ func (s *Service) PublishWork(ctx context.Context, consumerID string, req model.
 		SuccessCriteria: req.SuccessCriteria,
 		BidWindowMs:     req.BidWindowMs,
 		Payload:         req.Payload,
+		CallbackURL:     strings.TrimSpace(req.CallbackURL),
 		State:           model.WorkStateOpen,
 		CreatedAt:       now,
 		BidWindowEndsAt: now.Add(time.Duration(req.BidWindowMs) * time.Millisecond),
@@ -93,7 +103,8 @@ func (s *Service) PublishWork(ctx context.Context, consumerID string, req model.
 		return model.WorkResponse{}, fmt.Errorf("save work: %w", err)
 	}
 
-	// 6. Broadcast work opportunity (via event for now, webhooks later)
+	// 6. Broadcast work opportunity: webhooks via the registry, plus the event
+	go s.notifyProviders(work)
 	_ = s.events.Publish(ctx, events.EventWorkSubmitted, map[string]any{
 		"work_id":            work.ID,
 		"domain":             work.Category,
@@ -118,6 +129,16 @@ func (s *Service) PublishWork(ctx context.Context, consumerID string, req model.
 	}, nil
 }
 
+// notifyProviders runs detached from the request so slow webhook fan-out
+// never delays the publish response.
+func (s *Service) notifyProviders(work model.WorkSpec) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.providerRegistry.NotifyWorkAvailable(ctx, work); err != nil {
+		slog.WarnContext(ctx, "failed to notify providers", "work_id", work.ID, "error", err)
+	}
+}
+
 // GetWork retrieves a work specification
 func (s *Service) GetWork(ctx context.Context, workID string) (model.WorkSpec, error) {
 	work, err := s.store.GetWork(ctx, workID)
@@ -223,12 +244,21 @@ func (s *Service) validateWorkSpec(req model.WorkSubmission) error {
 	if strings.TrimSpace(req.Category) == "" {
 		return errors.New("category is required")
 	}
+	if s.taxonomy != nil && !s.taxonomy.Contains(req.Category) {
+		return fmt.Errorf("unknown category %q", req.Category)
+	}
 	if strings.TrimSpace(req.Description) == "" {
 		return errors.New("description is required")
 	}
 	if req.Budget.MaxPrice <= 0 {
 		return errors.New("budget.max_price must be positive")
 	}
+	if cb := strings.TrimSpace(req.CallbackURL); cb != "" {
+		u, err := url.Parse(cb)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("callback_url must be an absolute http(s) URL")
+		}
+	}
 	return nil
 }
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/parlakisik/agent-exchange/aex-work-publisher/internal/model"
 	"github.com/parlakisik/agent-exchange/aex-work-publisher/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/taxonomy"
 )
 
 func TestPublishWork(t *testing.T) {
@@ -295,3 +296,27 @@ func TestBidWindowDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestPublishWorkStrictTaxonomy(t *testing.T) {
+	svc := New(store.NewMemoryStore(), "")
+	svc.SetTaxonomy(taxonomy.Default())
+
+	for _, tt := range []struct {
+		category string
+		wantErr  bool
+	}{
+		{"nlp.summarize", false},
+		{"legal", false},
+		{"astrology", true},
+	} {
+		req := model.WorkSubmission{
+			Category:    tt.category,
+			Description: "Test work",
+			Budget:      model.Budget{MaxPrice: 10},
+		}
+		_, err := svc.PublishWork(context.Background(), "tenant_001", req)
+		if gotErr := err != nil; gotErr != tt.wantErr {
+			t.Errorf("PublishWork(%q) error = %v, wantErr %v", tt.category, err, tt.wantErr)
+		}
+	}
+}
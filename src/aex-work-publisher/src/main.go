@@ -13,6 +13,7 @@ import (
 	"github.com/parlakisik/agent-exchange/aex-work-publisher/internal/httpapi"
 	"github.com/parlakisik/agent-exchange/aex-work-publisher/internal/service"
 	"github.com/parlakisik/agent-exchange/aex-work-publisher/internal/store"
+	"github.com/parlakisik/agent-exchange/internal/taxonomy"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -96,6 +97,17 @@ func main() {
 
 	// Initialize service
 	svc := service.New(workStore, cfg.ProviderRegistryURL)
+	if cfg.StrictTaxonomy {
+		tax := taxonomy.Default()
+		if cfg.TaxonomyFile != "" {
+			tax, err = taxonomy.Load(cfg.TaxonomyFile)
+			if err != nil {
+				slog.Error("failed to load taxonomy", "error", err)
+				os.Exit(1)
+			}
+		}
+		svc.SetTaxonomy(tax)
+	}
 
 	// Setup HTTP router
 	router := httpapi.NewRouter(svc)
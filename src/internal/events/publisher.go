@@ -3,12 +3,16 @@ package events
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -16,9 +20,14 @@ import (
 type Publisher struct {
 	source     string
 	httpClient *http.Client
-	endpoints  map[string]string // eventType -> webhook URL
+	endpoints  map[string][]string // eventType -> webhook URLs
+	secret     []byte
 }
 
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook's body, prefixed
+// with "sha256=", when the publisher has a secret.
+const SignatureHeader = "X-AEX-Signature"
+
 // NewPublisher creates a new event publisher
 func NewPublisher(source string) *Publisher {
 	return &Publisher{
@@ -26,13 +35,37 @@ func NewPublisher(source string) *Publisher {
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
-		endpoints: make(map[string]string),
+		endpoints: make(map[string][]string),
 	}
 }
 
 // RegisterEndpoint registers a webhook endpoint for an event type
 func (p *Publisher) RegisterEndpoint(eventType, webhookURL string) {
-	p.endpoints[eventType] = webhookURL
+	p.endpoints[eventType] = []string{webhookURL}
+}
+
+// AddEndpoint registers a further webhook endpoint for an event type; each
+// event is sent to all of them.
+func (p *Publisher) AddEndpoint(eventType, webhookURL string) {
+	for _, u := range p.endpoints[eventType] {
+		if u == webhookURL {
+			return
+		}
+	}
+	p.endpoints[eventType] = append(p.endpoints[eventType], webhookURL)
+}
+
+// SetSecret makes the publisher sign webhook bodies with secret, so
+// receivers can check events came from it.
+func (p *Publisher) SetSecret(secret string) {
+	p.secret = []byte(secret)
+}
+
+// Sign returns the SignatureHeader value of body under secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
 }
 
 // Publish publishes an event (HTTP webhook for now, Pub/Sub later)
@@ -41,7 +74,7 @@ func (p *Publisher) Publish(ctx context.Context, eventType string, data map[stri
 		EventID:        generateEventID(),
 		EventType:      eventType,
 		SchemaVersion:  "1.0",
-		IdempotencyKey: fmt.Sprintf("%s_%s_%d", eventType, data["work_id"], time.Now().Unix()),
+		IdempotencyKey: idempotencyKey(eventType, data),
 		Timestamp:      time.Now().UTC(),
 		Source:         p.source,
 		Data:           data,
@@ -58,13 +91,17 @@ func (p *Publisher) Publish(ctx context.Context, eventType string, data map[stri
 		"source", envelope.Source,
 	)
 
-	// If webhook endpoint registered, send HTTP POST
-	if webhookURL, ok := p.endpoints[eventType]; ok {
-		return p.sendWebhook(ctx, webhookURL, envelope)
+	// If webhook endpoints registered, send HTTP POSTs; one failing
+	// endpoint does not keep the event from the others.
+	var errs []error
+	for _, webhookURL := range p.endpoints[eventType] {
+		if err := p.sendWebhook(ctx, webhookURL, envelope); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
 	// In the future, this will publish to Pub/Sub
-	return nil
+	return errors.Join(errs...)
 }
 
 func (p *Publisher) sendWebhook(ctx context.Context, url string, envelope Envelope) error {
@@ -81,6 +118,9 @@ func (p *Publisher) sendWebhook(ctx context.Context, url string, envelope Envelo
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Event-ID", envelope.EventID)
 	req.Header.Set("X-Event-Type", envelope.EventType)
+	if len(p.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(p.secret, body))
+	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -104,6 +144,26 @@ func (p *Publisher) sendWebhook(ctx context.Context, url string, envelope Envelo
 	return nil
 }
 
+// idempotencyKey follows the {id}_{action}_{epoch} convention for
+// contract, provider and API key events and keeps the historic work-keyed
+// format for everything else.
+func idempotencyKey(eventType string, data map[string]any) string {
+	now := time.Now().Unix()
+	action := eventType[strings.LastIndex(eventType, ".")+1:]
+	if contractID, ok := data["contract_id"].(string); ok && contractID != "" {
+		return fmt.Sprintf("%s_%s_%d", contractID, action, now)
+	}
+	if _, ok := data["work_id"]; !ok {
+		if providerID, ok := data["provider_id"].(string); ok && providerID != "" {
+			return fmt.Sprintf("%s_%s_%d", providerID, action, now)
+		}
+		if keyID, ok := data["key_id"].(string); ok && keyID != "" {
+			return fmt.Sprintf("%s_%s_%d", keyID, action, now)
+		}
+	}
+	return fmt.Sprintf("%s_%s_%d", eventType, data["work_id"], now)
+}
+
 func generateEventID() string {
 	var b [16]byte
 	_, _ = rand.Read(b[:])
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -128,11 +129,58 @@ func TestRegisterEndpoint(t *testing.T) {
 
 	pub.RegisterEndpoint(EventWorkSubmitted, "http://example.com/webhook")
 
-	if pub.endpoints[EventWorkSubmitted] != "http://example.com/webhook" {
+	if got := pub.endpoints[EventWorkSubmitted]; len(got) != 1 || got[0] != "http://example.com/webhook" {
 		t.Errorf("RegisterEndpoint() did not register endpoint correctly")
 	}
 }
 
+func TestPublish_SignedToEveryEndpoint(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(SignatureHeader), Sign([]byte("s3cret"), body); got != want {
+			t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+		}
+		received = append(received, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pub := NewPublisher("test-service")
+	pub.SetSecret("s3cret")
+	pub.AddEndpoint(EventTenantSuspended, server.URL+"/gateway-a")
+	pub.AddEndpoint(EventTenantSuspended, server.URL+"/gateway-b")
+	pub.AddEndpoint(EventTenantSuspended, server.URL+"/gateway-b")
+
+	if err := pub.Publish(context.Background(), EventTenantSuspended, map[string]any{"tenant_id": "tenant_1"}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if strings.Join(received, ",") != "/gateway-a,/gateway-b" {
+		t.Errorf("webhooks received at %v, want /gateway-a and /gateway-b once each", received)
+	}
+}
+
+func TestPublish_FailingEndpointDoesNotStopOthers(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pub := NewPublisher("test-service")
+	pub.AddEndpoint(EventTenantActivated, "http://bad host/gateway-a")
+	pub.AddEndpoint(EventTenantActivated, server.URL+"/gateway-b")
+
+	err := pub.Publish(context.Background(), EventTenantActivated, map[string]any{"tenant_id": "tenant_1"})
+	if err == nil {
+		t.Error("Publish() should report the failing endpoint")
+	}
+	if strings.Join(received, ",") != "/gateway-b" {
+		t.Errorf("webhooks received at %v, want /gateway-b", received)
+	}
+}
+
 func TestPublish_AllEventTypes(t *testing.T) {
 	eventTypes := []string{
 		EventWorkSubmitted,
@@ -141,8 +189,10 @@ func TestPublish_AllEventTypes(t *testing.T) {
 		EventBidSubmitted,
 		EventBidsEvaluated,
 		EventContractAwarded,
+		EventContractStarted,
 		EventContractCompleted,
 		EventContractFailed,
+		EventContractDisputed,
 		EventSettlementCompleted,
 	}
 
@@ -269,3 +319,28 @@ func TestGenerateEventID(t *testing.T) {
 		ids[id] = true
 	}
 }
+
+func TestIdempotencyKey_ContractEvents(t *testing.T) {
+	key := idempotencyKey(EventContractCompleted, map[string]any{
+		"contract_id": "contract_789xyz",
+		"work_id":     "work_123",
+	})
+	if !strings.HasPrefix(key, "contract_789xyz_completed_") {
+		t.Errorf("idempotencyKey() = %v, want contract_789xyz_completed_ prefix", key)
+	}
+
+	key = idempotencyKey(EventWorkSubmitted, map[string]any{"work_id": "work_123"})
+	if !strings.HasPrefix(key, "work.submitted_work_123_") {
+		t.Errorf("idempotencyKey() = %v, want work.submitted_work_123_ prefix", key)
+	}
+
+	key = idempotencyKey(EventProviderSuspended, map[string]any{"provider_id": "prov_abc"})
+	if !strings.HasPrefix(key, "prov_abc_suspended_") {
+		t.Errorf("idempotencyKey() = %v, want prov_abc_suspended_ prefix", key)
+	}
+
+	key = idempotencyKey(EventAPIKeyRotated, map[string]any{"tenant_id": "tenant_1", "key_id": "key_abc"})
+	if !strings.HasPrefix(key, "key_abc_rotated_") {
+		t.Errorf("idempotencyKey() = %v, want key_abc_rotated_ prefix", key)
+	}
+}
@@ -98,6 +98,14 @@ type CPATerms struct {
 	MaxPenaltyRate  float64     `json:"max_penalty_rate"`
 }
 
+type ContractStartedData struct {
+	ContractID string    `json:"contract_id"`
+	WorkID     string    `json:"work_id"`
+	ProviderID string    `json:"provider_id"`
+	ConsumerID string    `json:"consumer_id"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
 type ContractCompletedData struct {
 	ContractID  string         `json:"contract_id"`
 	WorkID      string         `json:"work_id"`
@@ -128,6 +136,36 @@ type ContractFailedData struct {
 	ErrorMessage  string `json:"error_message"`
 }
 
+type ContractDisputedData struct {
+	ContractID string    `json:"contract_id"`
+	WorkID     string    `json:"work_id"`
+	ProviderID string    `json:"provider_id"`
+	ConsumerID string    `json:"consumer_id"`
+	Reason     string    `json:"reason"`
+	Message    string    `json:"message,omitempty"`
+	DisputedAt time.Time `json:"disputed_at"`
+}
+
+type ContractCancelledData struct {
+	ContractID      string    `json:"contract_id"`
+	WorkID          string    `json:"work_id"`
+	ProviderID      string    `json:"provider_id"`
+	ConsumerID      string    `json:"consumer_id"`
+	Reason          string    `json:"reason"`
+	CancellationFee float64   `json:"cancellation_fee"`
+	CancelledAt     time.Time `json:"cancelled_at"`
+}
+
+// Settlement Events
+type SettlementPaymentFailedData struct {
+	ContractID string    `json:"contract_id"`
+	ProviderID string    `json:"provider_id"`
+	ConsumerID string    `json:"consumer_id"`
+	Amount     float64   `json:"amount"`
+	Reason     string    `json:"reason"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
 // Trust Events
 type TrustScoreUpdatedData struct {
 	ProviderID    string  `json:"provider_id"`
@@ -154,15 +192,49 @@ type TenantCreatedData struct {
 	Type       string `json:"type"`
 }
 
+// TenantSuspendedData is published when a tenant is suspended. Consumers
+// caching credentials should stop accepting the tenant's at once.
 type TenantSuspendedData struct {
-	TenantID string `json:"tenant_id"`
-	Reason   string `json:"reason"`
+	TenantID    string    `json:"tenant_id"`
+	Reason      string    `json:"reason"`
+	SuspendedAt time.Time `json:"suspended_at"`
 }
 
+// TenantActivatedData is published when a suspended tenant is activated
+// again.
+type TenantActivatedData struct {
+	TenantID    string    `json:"tenant_id"`
+	ActivatedAt time.Time `json:"activated_at"`
+}
+
+// APIKeyRevokedData is published for each API key revoked, whether on its
+// own or with its user or tenant.
 type APIKeyRevokedData struct {
-	TenantID string `json:"tenant_id"`
-	KeyID    string `json:"key_id"`
-	Prefix   string `json:"prefix"`
+	TenantID  string    `json:"tenant_id"`
+	KeyID     string    `json:"key_id"`
+	Prefix    string    `json:"prefix"`
+	UserID    string    `json:"user_id,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// APIKeyExpiringData is published once for each API key nearing its
+// expiry, so its owner can rotate it in time.
+type APIKeyExpiringData struct {
+	TenantID  string    `json:"tenant_id"`
+	KeyID     string    `json:"key_id"`
+	Prefix    string    `json:"prefix"`
+	UserID    string    `json:"user_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// APIKeyRotatedData is published when an API key is replaced. The old key
+// keeps working until OldKeyExpiresAt.
+type APIKeyRotatedData struct {
+	TenantID        string    `json:"tenant_id"`
+	KeyID           string    `json:"key_id"`
+	NewKeyID        string    `json:"new_key_id"`
+	OldKeyExpiresAt time.Time `json:"old_key_expires_at"`
 }
 
 // Provider Events
@@ -181,6 +253,31 @@ type ProviderStatusChangedData struct {
 	ChangedAt      time.Time `json:"changed_at"`
 }
 
+type ProviderUpdatedData struct {
+	ProviderID      string    `json:"provider_id"`
+	Endpoint        string    `json:"endpoint"`
+	Capabilities    []string  `json:"capabilities"`
+	EndpointChanged bool      `json:"endpoint_changed"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ProviderSuspendedData struct {
+	ProviderID     string    `json:"provider_id"`
+	PreviousStatus string    `json:"previous_status"`
+	Reason         string    `json:"reason"`
+	SuspendedAt    time.Time `json:"suspended_at"`
+}
+
+// ProviderKeyRotatedData is published when a provider API key is issued or
+// revoked. Consumers caching key validation should drop entries for the
+// provider.
+type ProviderKeyRotatedData struct {
+	ProviderID string    `json:"provider_id"`
+	KeyID      string    `json:"key_id"`
+	Action     string    `json:"action"` // created | revoked
+	RotatedAt  time.Time `json:"rotated_at"`
+}
+
 type SubscriptionCreatedData struct {
 	SubscriptionID string    `json:"subscription_id"`
 	ProviderID     string    `json:"provider_id"`
@@ -201,11 +298,15 @@ const (
 
 	// Contract events
 	EventContractAwarded   = "contract.awarded"
+	EventContractStarted   = "contract.started"
 	EventContractCompleted = "contract.completed"
 	EventContractFailed    = "contract.failed"
+	EventContractDisputed  = "contract.disputed"
+	EventContractCancelled = "contract.cancelled"
 
 	// Settlement events
-	EventSettlementCompleted = "settlement.completed"
+	EventSettlementCompleted     = "settlement.completed"
+	EventSettlementPaymentFailed = "settlement.payment_failed"
 
 	// Trust events
 	EventTrustScoreUpdated = "trust.score_updated"
@@ -214,10 +315,16 @@ const (
 	// Identity events
 	EventTenantCreated   = "tenant.created"
 	EventTenantSuspended = "tenant.suspended"
+	EventTenantActivated = "tenant.activated"
 	EventAPIKeyRevoked   = "apikey.revoked"
+	EventAPIKeyExpiring  = "apikey.expiring"
+	EventAPIKeyRotated   = "apikey.rotated"
 
 	// Provider events
 	EventProviderRegistered    = "provider.registered"
+	EventProviderUpdated       = "provider.updated"
+	EventProviderSuspended     = "provider.suspended"
 	EventProviderStatusChanged = "provider.status_changed"
+	EventProviderKeyRotated    = "provider.key_rotated"
 	EventSubscriptionCreated   = "subscription.created"
 )
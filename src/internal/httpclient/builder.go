@@ -18,6 +18,7 @@ type RequestBuilder struct {
 	query   url.Values
 	headers map[string]string
 	body    interface{}
+	auth    AuthProvider
 	ctx     context.Context
 }
 
@@ -57,6 +58,13 @@ func (b *RequestBuilder) JSON(body interface{}) *RequestBuilder {
 	return b
 }
 
+// Auth authenticates the request with auth when it is built; nil leaves
+// it unauthenticated
+func (b *RequestBuilder) Auth(auth AuthProvider) *RequestBuilder {
+	b.auth = auth
+	return b
+}
+
 // Context sets the context
 func (b *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
 	b.ctx = ctx
@@ -96,6 +104,12 @@ func (b *RequestBuilder) Build() (*http.Request, error) {
 		req.Header.Set(k, v)
 	}
 
+	if b.auth != nil {
+		if err := b.auth.Apply(req); err != nil {
+			return nil, fmt.Errorf("authenticate request: %w", err)
+		}
+	}
+
 	return req, nil
 }
 
@@ -109,6 +109,19 @@ func TestRequestBuilder_JSON(t *testing.T) {
 	}
 }
 
+func TestRequestBuilder_Auth(t *testing.T) {
+	req, err := NewRequest("POST", "http://example.com").
+		Auth(&BearerTokenAuth{Token: "secret"}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if got := req.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Auth() Authorization = %v, want Bearer secret", got)
+	}
+}
+
 func TestRequestBuilder_Context(t *testing.T) {
 	ctx := context.Background()
 	req := NewRequest("GET", "http://example.com").
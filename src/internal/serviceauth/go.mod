@@ -0,0 +1,3 @@
+module github.com/parlakisik/agent-exchange/internal/serviceauth
+
+go 1.22
@@ -0,0 +1,178 @@
+package serviceauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeIdentity serves a key set and a token endpoint like the identity
+// service's.
+type fakeIdentity struct {
+	key         *ecdsa.PrivateKey
+	tokenCalls  atomic.Int32
+	tokenExpiry time.Duration
+}
+
+func newFakeIdentity(t *testing.T) (*fakeIdentity, *httptest.Server) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeIdentity{key: key, tokenExpiry: 15 * time.Minute}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC",
+			"crv": "P-256",
+			"kid": "k1",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+	mux.HandleFunc("POST /internal/v1/service-accounts/token", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["client_id"] != "sa_1" || req["client_secret"] != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		f.tokenCalls.Add(1)
+		exp := time.Now().Add(f.tokenExpiry)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": f.sign(t, Claims{Subject: "sa_1", Service: "caller", Audience: req["audience"], ExpiresAt: exp.Unix()}),
+			"expires_at":   exp,
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+// sign issues a service token, filling in the issuer and token use unless
+// c sets them.
+func (f *fakeIdentity) sign(t *testing.T, c Claims) string {
+	t.Helper()
+	if c.Issuer == "" {
+		c.Issuer = DefaultIssuer
+	}
+	if c.TokenUse == "" {
+		c.TokenUse = TokenUse
+	}
+	h, _ := json.Marshal(map[string]string{"alg": "ES256", "typ": "JWT", "kid": "k1"})
+	p, _ := json.Marshal(c)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, f.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	f, srv := newFakeIdentity(t)
+	v := NewVerifier(srv.URL, "aex-settlement")
+	exp := time.Now().Add(time.Hour).Unix()
+
+	c, err := v.Verify(context.Background(), f.sign(t, Claims{Subject: "sa_1", Service: "aex-contract-engine", Audience: "aex-settlement", ExpiresAt: exp}))
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if c.Subject != "sa_1" || c.Service != "aex-contract-engine" {
+		t.Errorf("Verify() claims = %+v", c)
+	}
+
+	tests := map[string]Claims{
+		"other audience": {Subject: "sa_1", Audience: "aex-trust-broker", ExpiresAt: exp},
+		"tenant token":   {Subject: "sa_1", Audience: "aex-settlement", TokenUse: "tenant", ExpiresAt: exp},
+		"other issuer":   {Issuer: "elsewhere", Subject: "sa_1", Audience: "aex-settlement", ExpiresAt: exp},
+		"expired":        {Subject: "sa_1", Audience: "aex-settlement", ExpiresAt: time.Now().Add(-time.Second).Unix()},
+	}
+	for name, claims := range tests {
+		if _, err := v.Verify(context.Background(), f.sign(t, claims)); err != ErrUnauthorized {
+			t.Errorf("%s: Verify() error = %v, want ErrUnauthorized", name, err)
+		}
+	}
+
+	other, _ := newFakeIdentity(t)
+	if _, err := v.Verify(context.Background(), other.sign(t, Claims{Subject: "sa_1", Audience: "aex-settlement", ExpiresAt: exp})); err != ErrUnauthorized {
+		t.Errorf("foreign key: Verify() error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	f, srv := newFakeIdentity(t)
+	v := NewVerifier(srv.URL, "aex-settlement")
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("FromContext() found no claims")
+			return
+		}
+		_, _ = w.Write([]byte(c.Service))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/internal/settlement/complete", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/internal/settlement/complete", nil)
+	req.Header.Set("Authorization", "Bearer "+f.sign(t, Claims{Subject: "sa_1", Service: "caller", Audience: "aex-settlement", ExpiresAt: time.Now().Add(time.Hour).Unix()}))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "caller" {
+		t.Errorf("with token: status = %d body = %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	f, srv := newFakeIdentity(t)
+	src := NewTokenSource(srv.URL, "sa_1", "secret")
+	v := NewVerifier(srv.URL, "aex-settlement")
+
+	req := httptest.NewRequest(http.MethodPost, "/internal/settlement/complete", nil)
+	if err := src.For("aex-settlement").Apply(req); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	if _, err := v.Authenticate(req); err != nil {
+		t.Errorf("Authenticate() error: %v", err)
+	}
+	if _, err := src.Token(context.Background(), "aex-settlement"); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.tokenCalls.Load(); n != 1 {
+		t.Errorf("token requests = %d, want 1 (cached)", n)
+	}
+
+	// Tokens about to expire are replaced.
+	f.tokenExpiry = 30 * time.Second
+	if _, err := src.Token(context.Background(), "aex-trust-broker"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Token(context.Background(), "aex-trust-broker"); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.tokenCalls.Load(); n != 3 {
+		t.Errorf("token requests = %d, want 3", n)
+	}
+
+	if _, err := NewTokenSource(srv.URL, "sa_1", "wrong").Token(context.Background(), "aex-settlement"); err == nil {
+		t.Error("Token() with a wrong secret succeeded")
+	}
+}
@@ -0,0 +1,106 @@
+package serviceauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshBefore is how long before a token expires a TokenSource gets a
+// new one, so tokens do not expire in flight.
+const refreshBefore = time.Minute
+
+// TokenSource gets a service account's tokens from the identity service
+// and reuses them until shortly before they expire.
+type TokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]cachedToken // audience -> token
+}
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// NewTokenSource returns a source of tokens for the service account
+// clientID, from the identity service at identityURL.
+func NewTokenSource(identityURL, clientID, clientSecret string) *TokenSource {
+	return &TokenSource{
+		tokenURL:     strings.TrimRight(identityURL, "/") + "/internal/v1/service-accounts/token",
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       &http.Client{Timeout: 5 * time.Second},
+		tokens:       make(map[string]cachedToken),
+	}
+}
+
+// Token returns a token for calling the service named audience.
+func (s *TokenSource) Token(ctx context.Context, audience string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tokens[audience]; ok && time.Until(t.expiresAt) > refreshBefore {
+		return t.token, nil
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"client_id":     s.clientID,
+		"client_secret": s.clientSecret,
+		"audience":      audience,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("service token for %s: identity returned %d", audience, resp.StatusCode)
+	}
+	var out struct {
+		AccessToken string    `json:"access_token"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	s.tokens[audience] = cachedToken{token: out.AccessToken, expiresAt: out.ExpiresAt}
+	return out.AccessToken, nil
+}
+
+// For returns an authenticator adding tokens for audience to requests as a
+// Bearer token. It satisfies httpclient.AuthProvider.
+func (s *TokenSource) For(audience string) *Authenticator {
+	return &Authenticator{source: s, audience: audience}
+}
+
+// Authenticator adds a service token for one audience to requests.
+type Authenticator struct {
+	source   *TokenSource
+	audience string
+}
+
+func (a *Authenticator) Apply(req *http.Request) error {
+	token, err := a.source.Token(req.Context(), a.audience)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
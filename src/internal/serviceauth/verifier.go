@@ -0,0 +1,231 @@
+// Package serviceauth authenticates calls between the exchange's services
+// with the service account tokens the identity service issues. A caller
+// gets tokens for the service it calls from a TokenSource; the called
+// service checks them with a Verifier against the identity service's
+// published keys, without calling it per request.
+package serviceauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIssuer is the issuer of the identity service's tokens.
+	DefaultIssuer = "aex-identity"
+	// TokenUse is the token_use claim of service account tokens, which
+	// tells them apart from tenant tokens signed with the same key.
+	TokenUse = "service"
+
+	// keysRefreshInterval bounds how often tokens naming an unknown key
+	// make the verifier fetch the identity service's keys again.
+	keysRefreshInterval = time.Minute
+)
+
+// ErrUnauthorized means a request has no service token, or one that is
+// malformed, not signed by the identity service, for another audience or
+// expired.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Claims are the claims of a service account token.
+type Claims struct {
+	Issuer string `json:"iss"`
+	// Subject is the service account's ID.
+	Subject string `json:"sub"`
+	// Service is the calling service's name.
+	Service   string `json:"service"`
+	Audience  string `json:"aud"`
+	TokenUse  string `json:"token_use"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+}
+
+// Verifier checks the service account tokens sent to one service.
+type Verifier struct {
+	jwksURL  string
+	issuer   string
+	audience string
+	client   *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*ecdsa.PublicKey // kid -> key
+	fetchedAt time.Time
+}
+
+// NewVerifier returns a verifier accepting tokens the identity service at
+// identityURL issued for audience, the name of the verifying service.
+func NewVerifier(identityURL, audience string) *Verifier {
+	return &Verifier{
+		jwksURL:  strings.TrimRight(identityURL, "/") + "/.well-known/jwks.json",
+		issuer:   DefaultIssuer,
+		audience: audience,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// SetIssuer sets the issuer tokens must name, when the identity service
+// runs with a JWT_ISSUER other than DefaultIssuer.
+func (v *Verifier) SetIssuer(issuer string) {
+	v.issuer = issuer
+}
+
+// Verify checks the signature, issuer, audience, use and expiry of a token
+// and returns its claims. Errors other than ErrUnauthorized mean the
+// identity service's keys could not be fetched.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrUnauthorized
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "ES256" {
+		return nil, ErrUnauthorized
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return nil, ErrUnauthorized
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return nil, ErrUnauthorized
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, ErrUnauthorized
+	}
+	if c.Issuer != v.issuer || c.Audience != v.audience || c.TokenUse != TokenUse || c.Subject == "" ||
+		time.Now().Unix() >= c.ExpiresAt {
+		return nil, ErrUnauthorized
+	}
+	return &c, nil
+}
+
+// Authenticate verifies the Bearer token of r.
+func (v *Verifier) Authenticate(r *http.Request) (*Claims, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return nil, ErrUnauthorized
+	}
+	return v.Verify(r.Context(), strings.TrimSpace(token))
+}
+
+// Middleware answers 401 to requests without a valid service token, and
+// 503 when the keys to check it cannot be fetched. The handler finds the
+// token's claims with FromContext.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := v.Authenticate(r)
+		if errors.Is(err, ErrUnauthorized) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "service authentication unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, c)))
+	})
+}
+
+type claimsKey struct{}
+
+// FromContext returns the claims Middleware verified for a request.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
+
+// key returns the identity service's key named kid, fetching its key set
+// when the key is unknown.
+func (v *Verifier) key(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if k := v.keys[kid]; k != nil {
+		return k, nil
+	}
+	if v.keys != nil && time.Since(v.fetchedAt) < keysRefreshInterval {
+		return nil, ErrUnauthorized
+	}
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys, v.fetchedAt = keys, time.Now()
+	if k := v.keys[kid]; k != nil {
+		return k, nil
+	}
+	return nil, ErrUnauthorized
+}
+
+// fetchKeys reads the P-256 keys of the identity service's key set.
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]*ecdsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", v.jwksURL, resp.StatusCode)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			Kid string `json:"kid"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*ecdsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "EC" || k.Crv != "P-256" {
+			continue
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			continue
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,49 @@
+package taxonomy
+
+// defaultTaxonomy is served when no TAXONOMY_FILE is configured.
+const defaultTaxonomy = `{
+  "version": "2026-01",
+  "categories": [
+    {"id": "general", "name": "General", "description": "Work that fits no other category"},
+    {"id": "nlp", "name": "Natural language", "children": [
+      {"id": "nlp.summarize", "name": "Summarization"},
+      {"id": "nlp.translate", "name": "Translation"},
+      {"id": "nlp.classify", "name": "Classification"},
+      {"id": "nlp.extract", "name": "Information extraction"},
+      {"id": "nlp.write", "name": "Writing and editing"}
+    ]},
+    {"id": "code", "name": "Software", "children": [
+      {"id": "code.generate", "name": "Code generation"},
+      {"id": "code.review", "name": "Code review"},
+      {"id": "code.test", "name": "Test generation"}
+    ]},
+    {"id": "data", "name": "Data", "children": [
+      {"id": "data.analysis", "name": "Data analysis"},
+      {"id": "data.extraction", "name": "Data extraction"},
+      {"id": "data.visualization", "name": "Visualization"}
+    ]},
+    {"id": "research", "name": "Research", "children": [
+      {"id": "research.web", "name": "Web research"},
+      {"id": "research.academic", "name": "Academic research"}
+    ]},
+    {"id": "legal", "name": "Legal", "children": [
+      {"id": "legal.contract_review", "name": "Contract review"},
+      {"id": "legal.compliance", "name": "Compliance"},
+      {"id": "legal.ip_patent", "name": "IP and patents"},
+      {"id": "legal.real_estate", "name": "Real estate"}
+    ]},
+    {"id": "finance", "name": "Finance", "children": [
+      {"id": "finance.analysis", "name": "Financial analysis"},
+      {"id": "finance.payments", "name": "Payments"}
+    ]},
+    {"id": "travel", "name": "Travel", "children": [
+      {"id": "travel.booking", "name": "Booking"},
+      {"id": "travel.planning", "name": "Trip planning"}
+    ]},
+    {"id": "media", "name": "Media", "children": [
+      {"id": "media.image", "name": "Image generation and editing"},
+      {"id": "media.audio", "name": "Audio"},
+      {"id": "media.video", "name": "Video"}
+    ]}
+  ]
+}`
@@ -0,0 +1,3 @@
+module github.com/parlakisik/agent-exchange/internal/taxonomy
+
+go 1.22
@@ -0,0 +1,166 @@
+// Package taxonomy defines the capability and work category tree shared by
+// the provider registry and the work publisher, so that provider
+// capabilities, subscriptions and published work use the same identifiers.
+package taxonomy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Category is a node of the taxonomy. ID is the full dotted identifier
+// ("nlp.summarize"); children extend their parent's ID by one segment.
+type Category struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Children    []Category `json:"children,omitempty"`
+}
+
+// Taxonomy is an immutable category tree.
+type Taxonomy struct {
+	Version    string     `json:"version"`
+	Categories []Category `json:"categories"`
+
+	ids map[string]bool
+}
+
+// Default returns the built-in taxonomy.
+func Default() *Taxonomy {
+	t, err := Parse([]byte(defaultTaxonomy))
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Load reads a taxonomy from a JSON file.
+func Load(file string) (*Taxonomy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and checks a JSON taxonomy.
+func Parse(data []byte) (*Taxonomy, error) {
+	var t Taxonomy
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parse taxonomy: %w", err)
+	}
+	if len(t.Categories) == 0 {
+		return nil, errors.New("taxonomy has no categories")
+	}
+	t.ids = map[string]bool{}
+	if err := t.index("", t.Categories); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (t *Taxonomy) index(parent string, cats []Category) error {
+	for _, c := range cats {
+		if c.ID == "" || strings.ContainsAny(c.ID, "*?[ ") {
+			return fmt.Errorf("invalid category id %q", c.ID)
+		}
+		if parent != "" && !strings.HasPrefix(c.ID, parent+".") {
+			return fmt.Errorf("category %q is not below %q", c.ID, parent)
+		}
+		if t.ids[c.ID] {
+			return fmt.Errorf("duplicate category %q", c.ID)
+		}
+		t.ids[c.ID] = true
+		if err := t.index(c.ID, c.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Contains reports whether id is a category of the taxonomy, at any level.
+func (t *Taxonomy) Contains(id string) bool {
+	return t.ids[strings.TrimSpace(id)]
+}
+
+// IDs returns every category id in sorted order.
+func (t *Taxonomy) IDs() []string {
+	out := make([]string, 0, len(t.ids))
+	for id := range t.ids {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Covers reports whether pattern matches at least one category, i.e. a
+// subscription using it can ever receive work.
+func (t *Taxonomy) Covers(pattern string) bool {
+	for id := range t.ids {
+		if Match(pattern, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unknown returns the ids that are not in the taxonomy.
+func (t *Taxonomy) Unknown(ids []string) []string {
+	var out []string
+	for _, id := range ids {
+		if !t.Contains(id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// Match reports whether a subscription pattern covers category. "*" matches
+// everything and "nlp.*" matches "nlp" itself and every category below it
+// at any depth ("nlp.summarize", "nlp.text.summarize"). Other patterns use
+// path.Match glob syntax.
+func Match(pattern, category string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "*" || pattern == category {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, ".*"); ok && !strings.ContainsAny(prefix, "*?[") {
+		return category == prefix || strings.HasPrefix(category, prefix+".")
+	}
+	ok, err := path.Match(pattern, category)
+	return err == nil && ok
+}
+
+// IsGlob reports whether pattern uses wildcards other than a lone "*" or a
+// trailing ".*", i.e. whether matching it needs Match rather than a lookup
+// of LiteralPatterns.
+func IsGlob(pattern string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "*" {
+		return false
+	}
+	prefix, _ := strings.CutSuffix(pattern, ".*")
+	return strings.ContainsAny(prefix, "*?[")
+}
+
+// LiteralPatterns returns every non-glob pattern that matches category:
+// "*", the category itself and "x.*" for the category and each of its
+// ancestors. Stores can index subscription patterns and look these up,
+// only falling back to Match for patterns where IsGlob is true.
+func LiteralPatterns(category string) []string {
+	out := []string{"*", category}
+	for prefix := category; prefix != ""; {
+		out = append(out, prefix+".*")
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package taxonomy
+
+import (
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	tx := Default()
+	for _, id := range []string{"general", "nlp", "nlp.summarize", "legal.contract_review"} {
+		if !tx.Contains(id) {
+			t.Errorf("Contains(%q) = false, want true", id)
+		}
+	}
+	if tx.Contains("nlp.summarise") {
+		t.Error("Contains(nlp.summarise) = true, want false")
+	}
+	if got := tx.Unknown([]string{"nlp.translate", "astrology"}); len(got) != 1 || got[0] != "astrology" {
+		t.Errorf("Unknown() = %v, want [astrology]", got)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tx := Default()
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"*", true},
+		{"nlp.*", true},
+		{"nlp.sum*", true},
+		{"legal.contract_review", true},
+		{"astrology.*", false},
+		{"nlp.horoscope", false},
+	}
+	for _, tt := range tests {
+		if got := tx.Covers(tt.pattern); got != tt.want {
+			t.Errorf("Covers(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, category string
+		want              bool
+	}{
+		{"*", "nlp.summarize", true},
+		{"nlp.*", "nlp", true},
+		{"nlp.*", "nlp.text.summarize", true},
+		{"nlp.*", "nlpx.summarize", false},
+		{"travel.book*", "travel.booking", true},
+		{"travel.booking", "travel.planning", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.category); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalidTrees(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":     `{"categories": []}`,
+		"duplicate": `{"categories": [{"id": "a"}, {"id": "a"}]}`,
+		"misplaced": `{"categories": [{"id": "a", "children": [{"id": "b.c"}]}]}`,
+		"wildcard":  `{"categories": [{"id": "a*"}]}`,
+		"not json":  `categories`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: Parse() succeeded, want error", name)
+		}
+	}
+}
+
+func TestLiteralPatterns(t *testing.T) {
+	got := LiteralPatterns("nlp.text.summarize")
+	want := []string{"*", "nlp.text.summarize", "nlp.text.summarize.*", "nlp.text.*", "nlp.*"}
+	if len(got) != len(want) {
+		t.Fatalf("LiteralPatterns() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("LiteralPatterns() = %v, want %v", got, want)
+		}
+	}
+	for _, p := range got {
+		if IsGlob(p) || !Match(p, "nlp.text.summarize") {
+			t.Errorf("literal pattern %q does not match", p)
+		}
+	}
+	if !IsGlob("nlp.sum*") || !IsGlob("*.summarize") {
+		t.Error("IsGlob() = false for a glob pattern")
+	}
+}